@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	chromedphelper "github.com/pesarkhobeee/that-cli-web-toolbox/pkg/chromedp"
+)
+
+// aboveFoldAction reports what the page shows without scrolling, for
+// --above-fold.
+type aboveFoldAction struct{ noopAction }
+
+func (a *aboveFoldAction) Name() string             { return "above-fold" }
+func (a *aboveFoldAction) Enabled(cfg *Config) bool { return cfg.AboveFold }
+func (a *aboveFoldAction) Flags() []string          { return []string{"--above-fold"} }
+
+func (a *aboveFoldAction) Execute(ctx context.Context, run *Run) error {
+	slog.Info("Listing above-the-fold content")
+	fold, err := run.Browser.AboveFold(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to list above-the-fold content: %w", err)
+	}
+	for _, el := range fold.Elements {
+		if el.Lazy {
+			slog.Warn("Lazy-loaded image above the fold delays its display", "selector", el.Selector, "src", el.Src)
+		}
+	}
+	run.Result.AboveFold = fold
+	return nil
+}
+
+func (a *aboveFoldAction) Report(ctx context.Context, run *Run) error {
+	// Batch runs list a one-line overview in the batch summary instead
+	if run.Batch || structuredFormat(run.Config.OutputFormat) {
+		return nil
+	}
+	fold := run.Result.AboveFold
+	fmt.Printf("Above the fold (%.0fx%.0f, %d elements):\n", fold.Viewport.Width, fold.Viewport.Height, len(fold.Elements))
+	for _, el := range fold.Elements {
+		fmt.Printf("  %-8s %s\n", el.Kind, describeFoldElement(el))
+	}
+	if fold.Truncated {
+		fmt.Println("  ... more elements not listed")
+	}
+	if fold.LCP != nil {
+		fmt.Printf("Largest Contentful Paint: %s\n", formatLCP(fold.LCP))
+	}
+	return nil
+}
+
+// describeFoldElement renders el as "<tag> text-or-src (share visible)".
+func describeFoldElement(el chromedphelper.FoldElement) string {
+	s := "<" + el.Tag + ">"
+	if el.Src != "" {
+		s += " " + el.Src
+	}
+	if el.Text != "" {
+		s += fmt.Sprintf(" %q", el.Text)
+	}
+	var notes []string
+	if el.Visible < 1 {
+		notes = append(notes, fmt.Sprintf("%.0f%% visible", el.Visible*100))
+	}
+	if el.Lazy {
+		notes = append(notes, "loading=lazy")
+	}
+	if len(notes) > 0 {
+		s += " (" + strings.Join(notes, ", ") + ")"
+	}
+	return s
+}
+
+// formatLCP renders the LCP element, e.g. "<img> hero.jpg, 512000 px² at 1.2s".
+func formatLCP(lcp *chromedphelper.LCPElement) string {
+	s := "<" + lcp.Tag + ">"
+	if lcp.URL != "" {
+		s += " " + lcp.URL
+	} else if lcp.Selector != "" {
+		s += " " + lcp.Selector
+	}
+	return fmt.Sprintf("%s, %.0f px² at %s", s, lcp.Size, time.Duration(lcp.TimeMS)*time.Millisecond)
+}
+
+// formatAboveFold renders the fold's contents by kind for the batch
+// summary, e.g. "1 heading, 3 text, 2 image; LCP <img> hero.jpg ...".
+func formatAboveFold(fold *chromedphelper.AboveFold) string {
+	counts := make(map[string]int)
+	for _, el := range fold.Elements {
+		counts[el.Kind]++
+	}
+	var parts []string
+	for _, kind := range []string{chromedphelper.FoldHeading, chromedphelper.FoldText, chromedphelper.FoldImage,
+		chromedphelper.FoldVideo, chromedphelper.FoldEmbed, chromedphelper.FoldControl} {
+		if counts[kind] > 0 {
+			parts = append(parts, fmt.Sprintf("%d %s", counts[kind], kind))
+		}
+	}
+	s := "nothing visible"
+	if len(parts) > 0 {
+		s = strings.Join(parts, ", ")
+	}
+	if fold.LCP != nil {
+		s += "; LCP " + formatLCP(fold.LCP)
+	}
+	return s
+}
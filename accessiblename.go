@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	chromedphelper "github.com/pesarkhobeee/that-cli-web-toolbox/pkg/chromedp"
+)
+
+// accessibleNameAction reports the computed accessible name, role and
+// description of the elements matching each --get-accessible-name.
+type accessibleNameAction struct{ noopAction }
+
+func (a *accessibleNameAction) Name() string             { return "accessible-name" }
+func (a *accessibleNameAction) Enabled(cfg *Config) bool { return len(cfg.AccessibleNames) > 0 }
+func (a *accessibleNameAction) Flags() []string          { return []string{"--get-accessible-name"} }
+
+func (a *accessibleNameAction) Validate(cfg *Config) error {
+	return validateSelectors("--get-accessible-name", cfg.AccessibleNames)
+}
+
+func (a *accessibleNameAction) Execute(ctx context.Context, run *Run) error {
+	for _, selector := range run.Config.AccessibleNames {
+		slog.Info("Getting accessible names", "selector", selector)
+		names, err := run.Browser.AccessibleNames(ctx, selector)
+		if err != nil {
+			return fmt.Errorf("failed to get accessible names of %q: %w", selector, err)
+		}
+		for _, el := range names.Elements {
+			if !el.Ignored && el.Name == "" {
+				slog.Warn("Element has no accessible name", "selector", el.Selector, "role", el.Role)
+			}
+		}
+		run.Result.AccessibleNames = append(run.Result.AccessibleNames, names)
+	}
+	return nil
+}
+
+func (a *accessibleNameAction) Report(ctx context.Context, run *Run) error {
+	// Batch runs list a one-line overview in the batch summary instead
+	if run.Batch || structuredFormat(run.Config.OutputFormat) {
+		return nil
+	}
+	for _, names := range run.Result.AccessibleNames {
+		fmt.Printf("Accessible names of %s (%d):\n", names.Selector, len(names.Elements))
+		for _, el := range names.Elements {
+			fmt.Printf("  %s\n", formatAccessibleElement(el))
+			if el.Description != "" {
+				fmt.Printf("    description: %s\n", el.Description)
+			}
+		}
+		if names.Truncated {
+			fmt.Println("  ... more elements not listed")
+		}
+	}
+	return nil
+}
+
+// formatAccessibleElement renders el on one line, e.g.
+// `button "Sign in" (from aria-label) #login`.
+func formatAccessibleElement(el chromedphelper.AccessibleElement) string {
+	var sb strings.Builder
+	role := el.Role
+	if role == "" {
+		role = "-"
+	}
+	fmt.Fprintf(&sb, "%-10s %q", role, el.Name)
+	if el.NameFrom != "" {
+		fmt.Fprintf(&sb, " (from %s)", el.NameFrom)
+	}
+	if el.Ignored {
+		sb.WriteString(" [ignored]")
+	}
+	sb.WriteString(" " + el.Selector)
+	return sb.String()
+}
+
+// formatAccessibleNames renders the names of all selectors on one line for
+// the batch summary, e.g. `#login: button "Sign in"; img.logo: 2 elements, 1 unnamed`.
+func formatAccessibleNames(all []*chromedphelper.AccessibleNames) string {
+	var parts []string
+	for _, names := range all {
+		switch len(names.Elements) {
+		case 0:
+			parts = append(parts, names.Selector+": no elements")
+		case 1:
+			el := names.Elements[0]
+			parts = append(parts, fmt.Sprintf("%s: %s %q", names.Selector, el.Role, el.Name))
+		default:
+			unnamed := 0
+			for _, el := range names.Elements {
+				if !el.Ignored && el.Name == "" {
+					unnamed++
+				}
+			}
+			parts = append(parts, fmt.Sprintf("%s: %d elements, %d unnamed", names.Selector, len(names.Elements), unnamed))
+		}
+	}
+	return strings.Join(parts, "; ")
+}
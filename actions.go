@@ -0,0 +1,994 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	chromedphelper "github.com/pesarkhobeee/that-cli-web-toolbox/pkg/chromedp"
+	"github.com/pesarkhobeee/that-cli-web-toolbox/pkg/events"
+	"github.com/pesarkhobeee/that-cli-web-toolbox/pkg/runstore"
+	"github.com/pesarkhobeee/that-cli-web-toolbox/pkg/sink"
+	"github.com/pesarkhobeee/that-cli-web-toolbox/pkg/sourcemap"
+	"github.com/pesarkhobeee/that-cli-web-toolbox/pkg/textnorm"
+	"github.com/pesarkhobeee/that-cli-web-toolbox/pkg/tracing"
+)
+
+// Run holds the state shared by the actions of a single invocation.
+type Run struct {
+	Config    *Config
+	Browser   *chromedphelper.Browser
+	Artifacts sink.Sink
+	Text      sink.Sink
+	// Prefix is prepended to every output name; batch runs set it to the
+	// slugified target so outputs of different targets don't collide.
+	Prefix string
+	// Batch is set when the run is one of several targets.
+	Batch bool
+	// Signature is recorded by --detect-duplicates.
+	Signature *pageSignature
+	// Sitemap is recorded by --emit-sitemap.
+	Sitemap *sitemapEntry
+	// Visual is recorded by --visual-sitemap.
+	Visual *visualPage
+	// History is recorded by --sink sqlite:PATH.
+	History *runstore.Run
+	// JSON is the response body when the target was served as JSON, which
+	// only the actions in jsonActions run on.
+	JSON *string
+	// Result collects what the actions produced for structured output.
+	Result *chromedphelper.Result
+}
+
+// Action is one step of the pipeline. For every enabled action, Validate is
+// called before the browser starts, Prepare before navigation, Execute on
+// the prepared page, and Report once all actions have executed.
+type Action interface {
+	// Name is the identifier used by --order.
+	Name() string
+	// Enabled reports whether the flags in cfg request this action.
+	Enabled(cfg *Config) bool
+	// Flags lists the flags Enabled looks at, for the error of runs that
+	// enable no action.
+	Flags() []string
+	Validate(cfg *Config) error
+	Prepare(ctx context.Context, run *Run) error
+	Execute(ctx context.Context, run *Run) error
+	Report(ctx context.Context, run *Run) error
+}
+
+// availableActions returns fresh instances of every action in default
+// execution order.
+func availableActions() []Action {
+	return []Action{
+		// Prepare first: a Chrome outside --require-chrome renders nothing
+		&requireChromeAction{},
+		&consoleLogAction{},
+		&selectorAction{},
+		&jsonPathAction{},
+		&presetAction{},
+		&bodyAction{},
+		&outlineAction{},
+		&redactAction{},
+		&htmlAction{},
+		&criticalCSSAction{},
+		&aboveFoldAction{},
+		&contentMapAction{},
+		&landmarksAction{},
+		&accessibleNameAction{},
+		&contrastAction{},
+		&clipboardAction{},
+		&highlightAction{},
+		&annotateAction{},
+		&screenshotAction{},
+		&elementScreenshotAction{},
+		&screenshotEachAction{},
+		&svgAction{},
+		&domSnapshotAction{},
+		&pdfAction{},
+		&mhtmlAction{},
+		&summaryAction{},
+		&techAction{},
+		&compareNoJSAction{},
+		&compareGooglebotAction{},
+		&duplicatesAction{},
+		&sitemapAction{},
+		&visualSitemapAction{},
+		&saveCookiesAction{},
+		&saveStateAction{},
+		&exportAuthAction{},
+		&curlAction{},
+		&keyboardAction{},
+		// List the checksums of the outputs above
+		&metadataAction{},
+		&manifestAction{},
+		&historyAction{},
+		// Report last: checks, header assertions, soft 404s, overlays,
+		// design and baseline differences, --fail-if,
+		// --fail-on-request-error and --fail-threshold fail the pipeline
+		&checkAction{},
+		&headerAction{},
+		&soft404Action{},
+		&overlayAction{},
+		&designAction{},
+		&baselineAction{},
+		&failIfAction{},
+		&networkAction{},
+		&errorBudgetAction{},
+	}
+}
+
+// actionNames lists the identifiers accepted by --order.
+func actionNames() []string {
+	var names []string
+	for _, a := range availableActions() {
+		names = append(names, a.Name())
+	}
+	return names
+}
+
+// actionFlags lists the flags enabling an action, in default order, e.g.
+// "--body, --outline or --html".
+func actionFlags() string {
+	var flags []string
+	for _, a := range availableActions() {
+		flags = append(flags, a.Flags()...)
+	}
+	if len(flags) < 2 {
+		return strings.Join(flags, "")
+	}
+	return strings.Join(flags[:len(flags)-1], ", ") + " or " + flags[len(flags)-1]
+}
+
+// buildPipeline returns the enabled actions, with those named in order
+// first (in that order) followed by the rest in default order.
+func buildPipeline(cfg *Config, order []string) ([]Action, error) {
+	enabled := make(map[string]Action)
+	var defaults []Action
+	for _, a := range availableActions() {
+		if a.Enabled(cfg) {
+			enabled[a.Name()] = a
+			defaults = append(defaults, a)
+		}
+	}
+
+	var pipeline []Action
+	seen := make(map[string]bool)
+	for _, name := range order {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		a, ok := enabled[name]
+		if !ok {
+			if !contains(actionNames(), name) {
+				return nil, fmt.Errorf("unknown action %q in --order (available: %s)", name, strings.Join(actionNames(), ", "))
+			}
+			return nil, fmt.Errorf("action %q in --order is not enabled by any flag", name)
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("action %q listed more than once in --order", name)
+		}
+		seen[name] = true
+		pipeline = append(pipeline, a)
+	}
+	for _, a := range defaults {
+		if !seen[a.Name()] {
+			pipeline = append(pipeline, a)
+		}
+	}
+
+	for _, a := range pipeline {
+		if err := a.Validate(cfg); err != nil {
+			return nil, fmt.Errorf("invalid %s action: %w", a.Name(), err)
+		}
+	}
+	return pipeline, nil
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// timestamp is the suffix used for output names.
+func timestamp() string {
+	return time.Now().Format("20060102150405")
+}
+
+// normalizeText applies the --normalize-text, --strip-emoji,
+// --collapse-whitespace and --redact-pii post-processing to extracted text.
+func normalizeText(ctx context.Context, run *Run, text string) (string, error) {
+	cfg := run.Config
+	if cfg.NormalizeText != "" {
+		normalized, err := textnorm.Normalize(text, cfg.NormalizeText)
+		if err != nil {
+			return "", fmt.Errorf("failed to normalize text to %s: %w", cfg.NormalizeText, err)
+		}
+		text = normalized
+	}
+	if cfg.StripEmoji {
+		text = textnorm.StripEmoji(text)
+	}
+	if cfg.CollapseWhitespace {
+		text = textnorm.CollapseWhitespace(text)
+	}
+	if cfg.RedactPII {
+		text = redactPII(run, text)
+	}
+	return text, nil
+}
+
+// writeText writes extracted text to the run's text sink.
+func writeText(ctx context.Context, run *Run, name, text string) error {
+	return writeTextAs(ctx, run, fmt.Sprintf("%s_%s.txt", name, timestamp()), "text/plain; charset=utf-8", text)
+}
+
+// writeTextAs writes textual output with an explicit file name and content
+// type to the run's text sink, in the --text-encoding and --eol line
+// endings. With JSON output the text is already part of the run's Result,
+// so nothing is written; JUnit reports have no room for it, so it is
+// written unless it would mix with the report on stdout.
+func writeTextAs(ctx context.Context, run *Run, fileName, contentType, text string) error {
+	fileName = run.Prefix + fileName
+	format := run.Config.OutputFormat
+	if format == formatJUnit && isStdout(run.Text) {
+		slog.Warn("Text output not written, stdout holds the JUnit report; use --sink to keep it", "fileName", fileName)
+		return nil
+	}
+	if format == formatJSON || format == formatNDJSON {
+		return nil
+	}
+	if isStdout(run.Text) && run.Batch {
+		// Label text from different targets sharing stdout
+		text = fmt.Sprintf("== %s ==\n%s", run.Result.Target, text)
+	}
+	data, err := textnorm.Encode(text+"\n", run.Config.TextEncoding, run.Config.EOL)
+	if err != nil {
+		return err
+	}
+	contentType = strings.Replace(contentType, "charset=utf-8", "charset="+textnorm.Charset(run.Config.TextEncoding), 1)
+	if _, err := run.Text.Write(ctx, fileName, contentType, data); err != nil {
+		slog.Error("Failed to write text", "fileName", fileName, "error", err)
+		return fmt.Errorf("failed to write %q: %w", fileName, err)
+	}
+	return nil
+}
+
+// writeArtifact writes a binary artifact to the run's artifact sink, records
+// it in the run's Result and tells the user where it went.
+func writeArtifact(ctx context.Context, run *Run, kind, selector, label, fileName, contentType string, data []byte) error {
+	fileName = run.Prefix + fileName
+	slog.Debug("Saving "+label, "fileName", fileName, "size", len(data))
+	location, err := run.Artifacts.Write(ctx, fileName, contentType, data)
+	if err != nil {
+		slog.Error("Failed to save "+label, "fileName", fileName, "error", err)
+		return fmt.Errorf("failed to save %s %q: %w", label, fileName, err)
+	}
+	slog.Info(label+" saved successfully", "fileName", fileName, "location", location)
+	run.Result.AddFile(chromedphelper.File{
+		Kind:        kind,
+		Location:    location,
+		Name:        fileName,
+		ContentType: contentType,
+		Size:        len(data),
+		Selector:    selector,
+		SHA256:      fmt.Sprintf("%x", sha256.Sum256(data)),
+	})
+	if location != "" && !structuredFormat(run.Config.OutputFormat) {
+		fmt.Printf("%s saved as %s\n", label, location)
+	}
+	return nil
+}
+
+// formatRedirects renders a redirect chain as "A -> B (reason)".
+func formatRedirects(chain []chromedphelper.RedirectHop) string {
+	var b strings.Builder
+	for i, hop := range chain {
+		if i > 0 {
+			b.WriteString(" -> ")
+		}
+		b.WriteString(displayURL(hop.URL))
+		if hop.Reason != "initial" {
+			fmt.Fprintf(&b, " (%s)", hop.Reason)
+		}
+	}
+	return b.String()
+}
+
+// formatMissingFile renders a local file the page could not load, e.g.
+// "css/site.css (stylesheet, net::ERR_FILE_NOT_FOUND); did you mean ../assets/css/site.css?".
+func formatMissingFile(m chromedphelper.MissingFile) string {
+	s := fmt.Sprintf("%s (%s, %s)", m.Path, strings.ToLower(m.ResourceType), m.Error)
+	if len(m.Suggestions) > 0 {
+		s += "; did you mean " + strings.Join(m.Suggestions, " or ") + "?"
+	}
+	return s
+}
+
+// noopAction provides empty implementations of the Action phases.
+type noopAction struct{}
+
+func (noopAction) Validate(cfg *Config) error                  { return nil }
+func (noopAction) Prepare(ctx context.Context, run *Run) error { return nil }
+func (noopAction) Execute(ctx context.Context, run *Run) error { return nil }
+func (noopAction) Report(ctx context.Context, run *Run) error  { return nil }
+
+// consoleLogAction logs console messages and exceptions while the page loads.
+type consoleLogAction struct {
+	noopAction
+
+	// sourceMaps, set with --resolve-sourcemaps, resolves exception
+	// positions to the original sources.
+	sourceMaps *sourcemap.Resolver
+	// stream delivers the page's events to the collecting goroutine, which
+	// closes done once it has recorded the last of them.
+	stream <-chan events.Event
+	done   chan struct{}
+}
+
+func (a *consoleLogAction) Name() string             { return "consolelog" }
+func (a *consoleLogAction) Enabled(cfg *Config) bool { return cfg.ConsoleLog }
+func (a *consoleLogAction) Flags() []string          { return []string{"--consolelog"} }
+
+func (a *consoleLogAction) Validate(cfg *Config) error {
+	if cfg.ResolveSourceMaps {
+		a.sourceMaps = &sourcemap.Resolver{Client: newHTTPClient(30 * time.Second)}
+	}
+	return nil
+}
+
+func (a *consoleLogAction) Prepare(ctx context.Context, run *Run) error {
+	// Listeners must be in place before navigation
+	slog.Info("Setting up console log capture")
+	run.Browser.SetupConsoleLogListeners(ctx)
+
+	a.stream = run.Browser.Events()
+	a.done = make(chan struct{})
+	go func() {
+		defer close(a.done)
+		for ev := range a.stream {
+			if ctx.Err() != nil {
+				continue
+			}
+			switch ev := ev.(type) {
+			case events.ConsoleMessage:
+				run.Result.AddConsole(ev)
+			case events.Exception:
+				if a.sourceMaps != nil {
+					resolveException(ctx, a.sourceMaps, &ev)
+				}
+				run.Result.AddException(ev)
+			}
+		}
+	}()
+	return nil
+}
+
+// Report waits until the messages and exceptions logged up to now are
+// recorded, so those of the end of the load are in the output too.
+func (a *consoleLogAction) Report(ctx context.Context, run *Run) error {
+	if a.done == nil {
+		return nil
+	}
+	run.Browser.StopEvents(a.stream)
+	select {
+	case <-a.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// selectorAction extracts the text of elements matching each --gettextbycssselector.
+type selectorAction struct{ noopAction }
+
+func (a *selectorAction) Name() string             { return "selector" }
+func (a *selectorAction) Enabled(cfg *Config) bool { return len(cfg.GetTextByCssSelector) > 0 }
+func (a *selectorAction) Flags() []string          { return []string{"--gettextbycssselector"} }
+
+func (a *selectorAction) Validate(cfg *Config) error {
+	return validateSelectors("--gettextbycssselector", cfg.GetTextByCssSelector)
+}
+
+func (a *selectorAction) Execute(ctx context.Context, run *Run) error {
+	for _, selector := range run.Config.GetTextByCssSelector {
+		slog.Debug("Getting text by CSS selector", "selector", selector)
+		texts, err := run.Browser.GetTextsBySelector(ctx, selector)
+		if err != nil {
+			slog.Error("Failed to get text by selector", "selector", selector, "error", err)
+			return fmt.Errorf("failed to get text by selector %q: %w", selector, err)
+		}
+		for i := range texts {
+			if texts[i], err = normalizeText(ctx, run, texts[i]); err != nil {
+				return err
+			}
+		}
+		slog.Debug("Successfully extracted text", "selector", selector, "elements", len(texts))
+		run.Result.Selectors = append(run.Result.Selectors, chromedphelper.SelectorResult{
+			Selector: selector,
+			Elements: texts,
+		})
+	}
+	return nil
+}
+
+func (a *selectorAction) Report(ctx context.Context, run *Run) error {
+	results := run.Result.Selectors
+	if len(results) == 1 {
+		return writeText(ctx, run, "selector", strings.Join(results[0].Elements, "\n"))
+	}
+	// Label each output so multiple selectors can be told apart
+	for i, r := range results {
+		text := fmt.Sprintf("== %s ==\n%s", r.Selector, strings.Join(r.Elements, "\n"))
+		if err := writeText(ctx, run, fmt.Sprintf("selector-%d", i+1), text); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// bodyAction extracts all visible text of the page.
+type bodyAction struct{ noopAction }
+
+func (a *bodyAction) Name() string             { return "body" }
+func (a *bodyAction) Enabled(cfg *Config) bool { return cfg.GetBody }
+func (a *bodyAction) Flags() []string          { return []string{"--body"} }
+
+func (a *bodyAction) Execute(ctx context.Context, run *Run) error {
+	slog.Info("Getting body text")
+	text, err := run.Browser.GetBodyText(ctx)
+	if err != nil {
+		slog.Error("Failed to get body text", "error", err)
+		return fmt.Errorf("failed to get body text: %w", err)
+	}
+	slog.Debug("Successfully extracted body text", "textLength", len(text))
+	if text, err = normalizeText(ctx, run, text); err != nil {
+		return err
+	}
+	run.Result.Body = text
+	return nil
+}
+
+func (a *bodyAction) Report(ctx context.Context, run *Run) error {
+	return writeText(ctx, run, "body", run.Result.Body)
+}
+
+// htmlAction dumps the rendered markup, optionally sanitized.
+type htmlAction struct{ noopAction }
+
+func (a *htmlAction) Name() string             { return "html" }
+func (a *htmlAction) Enabled(cfg *Config) bool { return cfg.DumpHTML }
+func (a *htmlAction) Flags() []string          { return []string{"--html"} }
+
+func (a *htmlAction) Execute(ctx context.Context, run *Run) error {
+	var html string
+	var err error
+	if run.Config.Sanitize {
+		slog.Info("Getting sanitized HTML")
+		html, err = run.Browser.GetSanitizedHTML(ctx)
+	} else {
+		slog.Info("Getting HTML")
+		html, err = run.Browser.GetHTML(ctx)
+	}
+	if err != nil {
+		slog.Error("Failed to get HTML", "sanitize", run.Config.Sanitize, "error", err)
+		return fmt.Errorf("failed to get HTML: %w", err)
+	}
+	run.Result.HTML = html
+	return nil
+}
+
+func (a *htmlAction) Report(ctx context.Context, run *Run) error {
+	return writeTextAs(ctx, run, fmt.Sprintf("page_%s.html", timestamp()), "text/html; charset=utf-8", run.Result.HTML)
+}
+
+// clipboardAction reports what the page put on the clipboard during the
+// JavaScript and steps, for --read-clipboard.
+type clipboardAction struct{ noopAction }
+
+func (a *clipboardAction) Name() string             { return "clipboard" }
+func (a *clipboardAction) Enabled(cfg *Config) bool { return cfg.ReadClipboard }
+func (a *clipboardAction) Flags() []string          { return []string{"--read-clipboard"} }
+
+func (a *clipboardAction) Execute(ctx context.Context, run *Run) error {
+	slog.Info("Reading clipboard")
+	text, err := run.Browser.ReadClipboard(ctx)
+	if err != nil {
+		slog.Error("Failed to read clipboard", "error", err)
+		return fmt.Errorf("failed to read clipboard: %w", err)
+	}
+	run.Result.Clipboard = &text
+	return nil
+}
+
+func (a *clipboardAction) Report(ctx context.Context, run *Run) error {
+	if *run.Result.Clipboard == "" {
+		slog.Warn("Clipboard is empty")
+	}
+	return writeText(ctx, run, "clipboard", *run.Result.Clipboard)
+}
+
+// screenshotAction captures the whole page, or only the viewport with --full-page=false.
+type screenshotAction struct {
+	noopAction
+	image  []byte
+	format chromedphelper.ImageFormat
+	// layout and clip locate elements on the image for --annotate-json.
+	layout *chromedphelper.Layout
+	clip   chromedphelper.Box
+}
+
+func (a *screenshotAction) Name() string             { return "screenshot" }
+func (a *screenshotAction) Enabled(cfg *Config) bool { return cfg.Screenshot || cfg.ScreenshotAt != "" }
+func (a *screenshotAction) Flags() []string          { return []string{"--screenshot", "--screenshot-at"} }
+
+func (a *screenshotAction) Validate(cfg *Config) error {
+	// Highlights and labels are drawn once the page is prepared, after the
+	// milestone
+	if cfg.ScreenshotAt != "" && (len(cfg.Highlight) > 0 || cfg.AnnotateInteractives || cfg.AnnotateJSON) {
+		return fmt.Errorf("--screenshot-at cannot be combined with --highlight, --annotate-interactives or --annotate-json")
+	}
+	return nil
+}
+
+func (a *screenshotAction) Execute(ctx context.Context, run *Run) error {
+	opts := screenshotOptions(run.Config, chromedphelper.JPEG)
+	a.format = opts.Format
+
+	var imageBuf []byte
+	var err error
+	if run.Config.ScreenshotAt != "" {
+		slog.Info("Using screenshot taken at milestone", "milestone", run.Config.ScreenshotAt)
+		imageBuf, err = run.Browser.MilestoneScreenshot(ctx)
+	} else if run.Config.FullPage {
+		slog.Info("Taking screenshot")
+		imageBuf, err = run.Browser.ScreenshotFullPage(ctx, opts)
+	} else {
+		slog.Info("Taking viewport screenshot")
+		imageBuf, err = run.Browser.ScreenshotViewport(ctx, opts)
+	}
+	if err != nil {
+		slog.Error("Failed to take screenshot", "error", err)
+		return fmt.Errorf("failed to take screenshot: %w", err)
+	}
+	a.image = imageBuf
+
+	if a.layout, err = measureLayout(ctx, run); err != nil || a.layout == nil {
+		return err
+	}
+	if run.Config.FullPage {
+		a.clip = a.layout.Page
+	} else {
+		a.clip = a.layout.Viewport
+	}
+	return nil
+}
+
+func (a *screenshotAction) Report(ctx context.Context, run *Run) error {
+	fileName := fmt.Sprintf("screenshot_%s.%s", timestamp(), a.format.Extension())
+	if err := writeArtifact(ctx, run, "screenshot", "", "Screenshot", fileName, a.format.ContentType(), a.image); err != nil {
+		return err
+	}
+	if a.layout == nil {
+		return nil
+	}
+	return writeAnnotations(ctx, run, a.layout, fileName, a.clip)
+}
+
+// highlightAction outlines the elements matching --highlight for the
+// screenshots and PDFs that follow it.
+type highlightAction struct {
+	noopAction
+}
+
+func (a *highlightAction) Name() string             { return "highlight" }
+func (a *highlightAction) Enabled(cfg *Config) bool { return len(cfg.Highlight) > 0 }
+func (a *highlightAction) Flags() []string          { return []string{"--highlight"} }
+
+func (a *highlightAction) Validate(cfg *Config) error {
+	return validateSelectors("--highlight", cfg.Highlight)
+}
+
+func (a *highlightAction) Execute(ctx context.Context, run *Run) error {
+	counts, err := run.Browser.Highlight(ctx, run.Config.Highlight)
+	if err != nil {
+		slog.Error("Failed to highlight elements", "error", err)
+		return fmt.Errorf("failed to highlight elements: %w", err)
+	}
+	for i, selector := range run.Config.Highlight {
+		if counts[i] == 0 {
+			slog.Warn("No element to highlight", "selector", selector)
+			continue
+		}
+		slog.Info("Elements highlighted", "selector", selector, "count", counts[i])
+	}
+	return nil
+}
+
+// annotateAction numbers the page's interactive elements for
+// --annotate-interactives, so the screenshot that follows shows the labels,
+// and writes the map of numbers to selectors and boxes as JSON.
+type annotateAction struct {
+	noopAction
+}
+
+func (a *annotateAction) Name() string             { return "annotate" }
+func (a *annotateAction) Enabled(cfg *Config) bool { return cfg.AnnotateInteractives }
+func (a *annotateAction) Flags() []string          { return []string{"--annotate-interactives"} }
+
+func (a *annotateAction) Execute(ctx context.Context, run *Run) error {
+	elements, err := run.Browser.AnnotateInteractives(ctx)
+	if err != nil {
+		slog.Error("Failed to annotate interactive elements", "error", err)
+		return fmt.Errorf("failed to annotate interactive elements: %w", err)
+	}
+	slog.Info("Interactive elements annotated", "count", len(elements))
+	run.Result.Interactives = elements
+	return nil
+}
+
+func (a *annotateAction) Report(ctx context.Context, run *Run) error {
+	elements := run.Result.Interactives
+	if elements == nil {
+		elements = []chromedphelper.InteractiveElement{}
+	}
+	data, err := json.MarshalIndent(elements, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode element map: %w", err)
+	}
+	fileName := fmt.Sprintf("elements_%s.json", timestamp())
+	return writeArtifact(ctx, run, "element-map", "", "Element map", fileName, "application/json", data)
+}
+
+// elementScreenshotAction captures the first element matching each --screenshot-selector.
+type elementScreenshotAction struct {
+	noopAction
+	images [][]byte
+	format chromedphelper.ImageFormat
+	layout *chromedphelper.Layout
+}
+
+func (a *elementScreenshotAction) Name() string             { return "screenshot-selector" }
+func (a *elementScreenshotAction) Enabled(cfg *Config) bool { return len(cfg.ScreenshotSelectors) > 0 }
+func (a *elementScreenshotAction) Flags() []string          { return []string{"--screenshot-selector"} }
+
+func (a *elementScreenshotAction) Validate(cfg *Config) error {
+	return validateSelectors("--screenshot-selector", cfg.ScreenshotSelectors)
+}
+
+func (a *elementScreenshotAction) Execute(ctx context.Context, run *Run) error {
+	a.images = nil
+	opts := screenshotOptions(run.Config, chromedphelper.PNG)
+	a.format = opts.Format
+	for _, selector := range run.Config.ScreenshotSelectors {
+		slog.Info("Taking element screenshot", "selector", selector)
+		imageBuf, err := run.Browser.ScreenshotElementAs(ctx, selector, opts)
+		if err != nil {
+			slog.Error("Failed to take element screenshot", "selector", selector, "error", err)
+			return fmt.Errorf("failed to take screenshot of %q: %w", selector, err)
+		}
+		a.images = append(a.images, imageBuf)
+	}
+
+	var err error
+	a.layout, err = measureLayout(ctx, run, run.Config.ScreenshotSelectors...)
+	return err
+}
+
+func (a *elementScreenshotAction) Report(ctx context.Context, run *Run) error {
+	for i, image := range a.images {
+		selector := run.Config.ScreenshotSelectors[i]
+		fileName := fmt.Sprintf("element-%d_%s.%s", i+1, timestamp(), a.format.Extension())
+		if err := writeArtifact(ctx, run, "element-screenshot", selector, "Screenshot of "+selector, fileName, a.format.ContentType(), image); err != nil {
+			return err
+		}
+		if a.layout == nil {
+			continue
+		}
+		// The screenshot is of the first match
+		matches := a.layout.Of(selector)
+		if len(matches) == 0 {
+			slog.Warn("Element not found when measuring the layout, skipping annotations", "selector", selector)
+			continue
+		}
+		if err := writeAnnotations(ctx, run, a.layout, fileName, matches[0].Box); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// screenshotEachAction captures every element matching --screenshot-each,
+// up to --limit, as a separate image.
+type screenshotEachAction struct {
+	noopAction
+	images [][]byte
+	format chromedphelper.ImageFormat
+	layout *chromedphelper.Layout
+}
+
+func (a *screenshotEachAction) Name() string             { return "screenshot-each" }
+func (a *screenshotEachAction) Enabled(cfg *Config) bool { return cfg.ScreenshotEach != "" }
+func (a *screenshotEachAction) Flags() []string          { return []string{"--screenshot-each"} }
+
+func (a *screenshotEachAction) Validate(cfg *Config) error {
+	if err := validateSelectors("--screenshot-each", []string{cfg.ScreenshotEach}); err != nil {
+		return err
+	}
+	if cfg.Limit < 0 {
+		return fmt.Errorf("--limit cannot be negative: %d", cfg.Limit)
+	}
+	return nil
+}
+
+func (a *screenshotEachAction) Execute(ctx context.Context, run *Run) error {
+	opts := screenshotOptions(run.Config, chromedphelper.PNG)
+	a.format = opts.Format
+	selector := run.Config.ScreenshotEach
+	slog.Info("Taking screenshot of each element", "selector", selector, "limit", run.Config.Limit)
+	images, err := run.Browser.ScreenshotEach(ctx, selector, run.Config.Limit, opts)
+	if err != nil {
+		slog.Error("Failed to take element screenshots", "selector", selector, "error", err)
+		return fmt.Errorf("failed to take screenshots of %q: %w", selector, err)
+	}
+	a.images = images
+
+	a.layout, err = measureLayout(ctx, run, selector)
+	return err
+}
+
+func (a *screenshotEachAction) Report(ctx context.Context, run *Run) error {
+	selector := run.Config.ScreenshotEach
+	ts := timestamp()
+	for i, image := range a.images {
+		fileName := fmt.Sprintf("each-%d_%s.%s", i+1, ts, a.format.Extension())
+		label := fmt.Sprintf("Screenshot %d of %s", i+1, selector)
+		if err := writeArtifact(ctx, run, "element-screenshot", selector, label, fileName, a.format.ContentType(), image); err != nil {
+			return err
+		}
+		if a.layout == nil {
+			continue
+		}
+		// Both skip elements without a size, so the Nth image is of the Nth
+		// measured match
+		matches := a.layout.Of(selector)
+		if i >= len(matches) {
+			slog.Warn("Element not found when measuring the layout, skipping annotations", "selector", selector, "index", i)
+			continue
+		}
+		if err := writeAnnotations(ctx, run, a.layout, fileName, matches[i].Box); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func validateSelectors(flag string, selectors []string) error {
+	for _, selector := range selectors {
+		if strings.TrimSpace(selector) == "" {
+			return fmt.Errorf("%s cannot be empty", flag)
+		}
+	}
+	return nil
+}
+
+// pdfAction prints the page to PDF.
+type pdfAction struct {
+	noopAction
+	pdf []byte
+}
+
+func (a *pdfAction) Name() string             { return "pdf" }
+func (a *pdfAction) Enabled(cfg *Config) bool { return cfg.PrintToPDF }
+func (a *pdfAction) Flags() []string          { return []string{"--printtopdf"} }
+
+func (a *pdfAction) Execute(ctx context.Context, run *Run) error {
+	slog.Info("Printing to PDF")
+	if run.Config.PDFTOC > 0 {
+		out, err := run.Browser.PrintToPDFWithTOC(ctx, run.Config.PDFTOC)
+		if err != nil {
+			slog.Error("Failed to print to PDF", "error", err)
+			return fmt.Errorf("failed to print to PDF: %w", err)
+		}
+		if out.Headings > 0 {
+			slog.Info("Added a table of contents to the PDF", "pages", out.Pages, "headings", out.Headings)
+		}
+		a.pdf = out.PDF
+		return nil
+	}
+	pdfBuf, err := run.Browser.PrintToPDF(ctx)
+	if err != nil {
+		slog.Error("Failed to print to PDF", "error", err)
+		return fmt.Errorf("failed to print to PDF: %w", err)
+	}
+	a.pdf = pdfBuf
+	return nil
+}
+
+func (a *pdfAction) Report(ctx context.Context, run *Run) error {
+	return writeArtifact(ctx, run, "pdf", "", "PDF", fmt.Sprintf("page_%s.pdf", timestamp()), "application/pdf", a.pdf)
+}
+
+// runPipeline drives the actions through their phases on run's browser.
+func runPipeline(ctx context.Context, run *Run, pipeline []Action) (err error) {
+	ctx, span := tracing.Start(ctx, "page", tracing.String("url.full", run.Browser.TargetURL))
+	defer func() { endSpan(span, err) }()
+	// Whatever was running when the tab crashed or the page went over a
+	// cap, that is the failure
+	defer func() {
+		var limit *chromedphelper.LimitError
+		if crash := tabCrash(err); crash != nil {
+			run.Result.Crash = crash
+			err = &exitError{code: exitCrash, err: err}
+		} else if errors.As(err, &limit) {
+			run.Result.Limit = &limit.Limit
+			err = &exitError{code: exitLimit, err: err}
+		}
+	}()
+
+	for _, a := range pipeline {
+		if err := a.Prepare(ctx, run); err != nil {
+			return err
+		}
+	}
+
+	// Navigate to target URL, apply delay, and execute custom JS (once for all actions)
+	slog.Info("Navigating to target and preparing page", "url", run.Browser.TargetURL)
+	if err := run.Browser.NavigateAndPrepare(ctx); err != nil {
+		slog.Error("Failed to navigate and prepare page", "error", err)
+		var pathology *chromedphelper.PathologyError
+		if errors.As(err, &pathology) {
+			run.Result.Pathology = &pathology.Pathology
+			return &exitError{code: exitPathology, err: fmt.Errorf("page never settled: %w", err)}
+		}
+		if errors.Is(err, chromedphelper.ErrChromeNotFound) {
+			return &exitError{code: exitBrowser, err: err}
+		}
+		if errors.Is(err, chromedphelper.ErrSelectorNotFound) {
+			return &exitError{code: exitSelector, err: fmt.Errorf("failed to prepare page: %w", err)}
+		}
+		return &exitError{code: exitNavigation, err: fmt.Errorf("failed to navigate and prepare page: %w", err)}
+	}
+	if chain := run.Browser.Redirects(); chain != nil {
+		run.Result.Redirects = chain
+		slog.Info("Followed client-side redirects", "hops", len(chain)-1, "finalURL", chain[len(chain)-1].URL)
+		if !run.Batch && !structuredFormat(run.Config.OutputFormat) {
+			fmt.Printf("Redirect chain: %s\n", formatRedirects(chain))
+		}
+		final := chain[len(chain)-1].URL
+		if reasons := homographReasons(final); reasons != nil {
+			if run.Config.IDNPolicy == "block" {
+				return &exitError{code: exitNavigation, err: fmt.Errorf("redirected to %s, whose host may impersonate another: %s", displayURL(final), strings.Join(reasons, "; "))}
+			}
+			slog.Warn("Page redirected to a host that may impersonate another", "url", displayURL(final), "reasons", strings.Join(reasons, "; "))
+		}
+	}
+	if missing := run.Browser.MissingFiles(); missing != nil {
+		run.Result.MissingFiles = missing
+		slog.Warn("Local resources of the page failed to load", "count", len(missing))
+		if !run.Batch && !structuredFormat(run.Config.OutputFormat) {
+			fmt.Printf("Missing local files (%d):\n", len(missing))
+			for _, m := range missing {
+				fmt.Printf("  %s\n", formatMissingFile(m))
+			}
+		}
+	}
+
+	if replay := run.Browser.Replayed(); replay != nil {
+		run.Result.Replay = replay
+		slog.Info("Replayed the page from the HAR", "served", replay.Served, "approximate", replay.Approximate, "missed", len(replay.Missed))
+		for _, u := range replay.Missed {
+			slog.Debug("Request not in the HAR", "url", u)
+		}
+		if !run.Batch && !structuredFormat(run.Config.OutputFormat) {
+			fmt.Printf("Replayed from HAR: %s\n", formatReplay(replay))
+		}
+	}
+
+	// JSON responses are not rendered, so actions that need a page are
+	// skipped for them
+	if run.JSON, err = run.Browser.JSONDocument(ctx); err != nil {
+		return fmt.Errorf("failed to read document: %w", err)
+	}
+	if run.JSON != nil {
+		var kept []Action
+		for _, a := range pipeline {
+			if contains(jsonActions, a.Name()) {
+				kept = append(kept, a)
+				continue
+			}
+			slog.Info("Skipping action that needs a rendered page, the target was served as JSON", "action", a.Name())
+		}
+		pipeline = kept
+	}
+
+	// With --continue-on-error a failed action is set aside and the others
+	// run on; its failure is returned once they are done
+	var failures []string
+	failed := make(map[Action]bool)
+	code := 0
+	isolate := func(ctx context.Context, a Action, err error) bool {
+		if !run.Config.ContinueOnError || !isolatable(ctx, err) {
+			return false
+		}
+		slog.Error("Action failed, continuing with the others", "action", a.Name(), "error", err)
+		run.Result.AddActionError(chromedphelper.ActionError{Action: a.Name(), Error: err.Error()})
+		failures = append(failures, a.Name()+": "+err.Error())
+		failed[a] = true
+		if code == 0 {
+			code = exitCode(err)
+		}
+		return true
+	}
+	for _, a := range pipeline {
+		slog.Debug("Executing action", "action", a.Name())
+		actionCtx, span := tracing.Start(ctx, "execute "+a.Name())
+		err := a.Execute(actionCtx, run)
+		endSpan(span, err)
+		if err != nil && !isolate(ctx, a, err) {
+			return err
+		}
+	}
+	for _, a := range pipeline {
+		if failed[a] {
+			continue
+		}
+		actionCtx, span := tracing.Start(ctx, "report "+a.Name())
+		err := a.Report(actionCtx, run)
+		endSpan(span, err)
+		if err != nil && !isolate(ctx, a, err) {
+			return err
+		}
+	}
+	if len(failures) > 0 {
+		// Some outputs were delivered: a partial failure. Otherwise the
+		// first failure decides the exit code
+		if len(failed) < len(pipeline) {
+			code = exitPartial
+		}
+		return &exitError{code: code, err: fmt.Errorf("%d of %d actions failed: %s", len(failed), len(pipeline), strings.Join(failures, "; "))}
+	}
+	return nil
+}
+
+// isolatable tells whether err failed only its action, so the others can
+// still run on the page: not when the tab crashed, the page went over a cap
+// or the run's time is up.
+func isolatable(ctx context.Context, err error) bool {
+	var limit *chromedphelper.LimitError
+	return ctx.Err() == nil && tabCrash(err) == nil && !errors.As(err, &limit) &&
+		!errors.Is(err, context.DeadlineExceeded) && !errors.Is(err, context.Canceled)
+}
+
+// validateTextOutput checks --normalize-text, --text-encoding and --eol,
+// and sets the canonical name of the normalization form.
+func validateTextOutput(cfg *Config) error {
+	if cfg.NormalizeText != "" {
+		form, err := textnorm.ValidateForm(cfg.NormalizeText)
+		if err != nil {
+			slog.Error("Invalid normalization form", "form", cfg.NormalizeText)
+			return err
+		}
+		cfg.NormalizeText = form
+	}
+
+	if !contains(textnorm.Encodings, cfg.TextEncoding) {
+		slog.Error("Invalid text encoding", "encoding", cfg.TextEncoding)
+		return fmt.Errorf("unsupported text encoding %q (expected one of %s)", cfg.TextEncoding, strings.Join(textnorm.Encodings, ", "))
+	}
+	if !contains(textnorm.EOLs, cfg.EOL) {
+		slog.Error("Invalid line ending", "eol", cfg.EOL)
+		return fmt.Errorf("unsupported line ending %q (expected one of %s)", cfg.EOL, strings.Join(textnorm.EOLs, ", "))
+	}
+	return nil
+}
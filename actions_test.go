@@ -0,0 +1,45 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	chromedphelper "github.com/pesarkhobeee/that-cli-web-toolbox/pkg/chromedp"
+	"github.com/pesarkhobeee/that-cli-web-toolbox/pkg/sink"
+)
+
+func TestWriteTextAsFollowsRunConfig(t *testing.T) {
+	// The flags say otherwise; the run's Config decides
+	saved := cfg
+	t.Cleanup(func() { cfg = saved })
+	cfg.OutputFormat = formatJSON
+
+	tests := []struct {
+		format string
+		want   bool
+	}{
+		{formatText, true},
+		{formatJUnit, true},
+		{formatJSON, false},
+		{formatNDJSON, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			dir := t.TempDir()
+			run := &Run{
+				Config: &Config{OutputFormat: tt.format, TextEncoding: "utf-8", EOL: "lf"},
+				Text:   &sink.File{Dir: dir},
+				Result: &chromedphelper.Result{},
+			}
+			if err := writeTextAs(context.Background(), run, "body.txt", "text/plain; charset=utf-8", "text"); err != nil {
+				t.Fatal(err)
+			}
+			_, err := os.Stat(filepath.Join(dir, "body.txt"))
+			if written := err == nil; written != tt.want {
+				t.Errorf("written = %v, want %v", written, tt.want)
+			}
+		})
+	}
+}
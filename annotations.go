@@ -0,0 +1,124 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	chromedphelper "github.com/pesarkhobeee/that-cli-web-toolbox/pkg/chromedp"
+)
+
+// defaultAnnotateSelectors are the key elements --annotate-json locates
+// without --annotate-selector: headings, landmarks, forms, buttons and
+// images.
+var defaultAnnotateSelectors = []string{"h1", "h2", "h3", "header", "nav", "main", "aside", "footer", "form", "button", "img"}
+
+// annotations is the sidecar --annotate-json writes next to a screenshot.
+type annotations struct {
+	Image  string `json:"image"`
+	Target string `json:"target"`
+	// Clip is the part of the page the image shows, in CSS pixels.
+	Clip             chromedphelper.Box `json:"clip"`
+	DevicePixelRatio float64            `json:"devicePixelRatio"`
+	Elements         []annotatedElement `json:"elements"`
+}
+
+// annotatedElement is an element shown in a screenshot, with its box in
+// page CSS pixels and in image pixels. Boxes of elements cut by the edges
+// of the image extend past them.
+type annotatedElement struct {
+	chromedphelper.LayoutElement
+	ImageBox chromedphelper.Box `json:"imageBox"`
+}
+
+// validateAnnotateJSON checks --annotate-json and --annotate-selector.
+func validateAnnotateJSON(cfg *Config) error {
+	if !cfg.AnnotateJSON {
+		if len(cfg.AnnotateSelectors) > 0 {
+			slog.Error("--annotate-selector specified without --annotate-json")
+			return fmt.Errorf("--annotate-selector requires --annotate-json")
+		}
+		return nil
+	}
+	if !cfg.Screenshot && len(cfg.ScreenshotSelectors) == 0 && cfg.ScreenshotEach == "" {
+		slog.Error("--annotate-json specified without a screenshot")
+		return fmt.Errorf("--annotate-json requires --screenshot, --screenshot-selector or --screenshot-each")
+	}
+	return validateSelectors("--annotate-selector", cfg.AnnotateSelectors)
+}
+
+// annotateSelectors returns the selectors of the elements --annotate-json
+// locates.
+func annotateSelectors(cfg *Config) []string {
+	if len(cfg.AnnotateSelectors) == 0 {
+		return defaultAnnotateSelectors
+	}
+	return cfg.AnnotateSelectors
+}
+
+// measureLayout returns the layout of the page for --annotate-json, also
+// measuring the given screenshot selectors, or nil without the flag. Call
+// it right after taking the screenshots it describes.
+func measureLayout(ctx context.Context, run *Run, screenshotSelectors ...string) (*chromedphelper.Layout, error) {
+	if !run.Config.AnnotateJSON {
+		return nil, nil
+	}
+	selectors := slices.Clone(annotateSelectors(run.Config))
+	for _, selector := range screenshotSelectors {
+		if !slices.Contains(selectors, selector) {
+			selectors = append(selectors, selector)
+		}
+	}
+	layout, err := run.Browser.Layout(ctx, selectors)
+	if err != nil {
+		return nil, fmt.Errorf("failed to measure page layout: %w", err)
+	}
+	return layout, nil
+}
+
+// writeAnnotations writes the --annotate-json sidecar of the screenshot
+// saved as imageName, which shows clip, next to it.
+func writeAnnotations(ctx context.Context, run *Run, layout *chromedphelper.Layout, imageName string, clip chromedphelper.Box) error {
+	a := annotations{
+		Image:            run.Prefix + imageName,
+		Target:           run.Result.Target,
+		Clip:             clip,
+		DevicePixelRatio: layout.DevicePixelRatio,
+		Elements:         []annotatedElement{},
+	}
+	scale := layout.DevicePixelRatio
+	if scale <= 0 {
+		scale = 1
+	}
+	selectors := annotateSelectors(run.Config)
+	for _, e := range layout.Elements {
+		if !slices.Contains(selectors, e.Selector) || !intersects(e.Box, clip) {
+			continue
+		}
+		a.Elements = append(a.Elements, annotatedElement{
+			LayoutElement: e,
+			ImageBox: chromedphelper.Box{
+				X:      (e.Box.X - clip.X) * scale,
+				Y:      (e.Box.Y - clip.Y) * scale,
+				Width:  e.Box.Width * scale,
+				Height: e.Box.Height * scale,
+			},
+		})
+	}
+
+	data, err := json.MarshalIndent(a, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode annotations: %w", err)
+	}
+	fileName := strings.TrimSuffix(imageName, filepath.Ext(imageName)) + ".json"
+	return writeArtifact(ctx, run, "annotations", "", "Annotations of "+imageName, fileName, "application/json", data)
+}
+
+// intersects reports whether the boxes overlap.
+func intersects(a, b chromedphelper.Box) bool {
+	return a.X < b.X+b.Width && b.X < a.X+a.Width && a.Y < b.Y+b.Height && b.Y < a.Y+a.Height
+}
@@ -0,0 +1,219 @@
+package main
+
+import (
+	"bufio"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Errors of requests turned away by --api-keys.
+var (
+	errUnauthorized = errors.New("missing or unknown API key")
+	errRateLimited  = errors.New("rate limit of the API key exceeded, retry later")
+)
+
+// apiKey is one key of the --api-keys file, with its rate limit and usage.
+type apiKey struct {
+	Name string
+	key  []byte
+	// Limit is how many requests the key may make per Per, or 0 for no
+	// limit. Up to Limit requests may be made at once, after which they
+	// are spread evenly over Per.
+	Limit int
+	Per   time.Duration
+
+	mu     sync.Mutex
+	tokens float64
+	filled time.Time
+	usage  keyUsage
+}
+
+// keyUsage is what a key has used of the server.
+type keyUsage struct {
+	Key         string `json:"key"`
+	Requests    int64  `json:"requests"`
+	Succeeded   int64  `json:"succeeded"`
+	Failed      int64  `json:"failed"`
+	RateLimited int64  `json:"rateLimited"`
+	// RenderSeconds is the time spent serving the key's requests.
+	RenderSeconds float64   `json:"renderSeconds"`
+	LastUsed      time.Time `json:"lastUsed,omitzero"`
+}
+
+// apiKeys authenticates API requests against the keys of --api-keys.
+type apiKeys struct {
+	keys []*apiKey
+}
+
+// loadAPIKeys reads keys from path, one per line as NAME KEY [LIMIT/UNIT],
+// e.g. "search-team 6f1c... 60/m" for 60 requests a minute; UNIT is s, m,
+// h or d. Blank lines and lines starting with # are skipped.
+func loadAPIKeys(path string) (*apiKeys, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read API keys %q: %w", path, err)
+	}
+	defer func() {
+		if err := f.Close(); err != nil {
+			slog.Warn("failed to close API keys file", "file", path, "error", err)
+		}
+	}()
+
+	keys := &apiKeys{}
+	names := make(map[string]bool)
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		// The line holds a secret, so errors only name its number
+		k, err := parseAPIKey(line)
+		if err != nil {
+			return nil, fmt.Errorf("invalid API key on line %d of %q: %w", n, path, err)
+		}
+		if names[k.Name] {
+			return nil, fmt.Errorf("API key name %q on line %d of %q is used twice", k.Name, n, path)
+		}
+		for _, other := range keys.keys {
+			if subtle.ConstantTimeCompare(other.key, k.key) == 1 {
+				return nil, fmt.Errorf("API key on line %d of %q is the same as that of %q", n, path, other.Name)
+			}
+		}
+		names[k.Name] = true
+		keys.keys = append(keys.keys, k)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read API keys %q: %w", path, err)
+	}
+	if len(keys.keys) == 0 {
+		return nil, fmt.Errorf("API keys file %q lists no keys", path)
+	}
+	slog.Debug("API keys loaded", "file", path, "keys", len(keys.keys))
+	return keys, nil
+}
+
+// parseAPIKey parses one line of the API keys file. Errors do not quote the
+// key.
+func parseAPIKey(line string) (*apiKey, error) {
+	fields := strings.Fields(line)
+	if len(fields) < 2 || len(fields) > 3 {
+		return nil, fmt.Errorf("expected NAME KEY [LIMIT/UNIT]")
+	}
+	k := &apiKey{Name: fields[0], key: []byte(fields[1]), usage: keyUsage{Key: fields[0]}}
+	if len(fields[1]) < 16 {
+		return nil, fmt.Errorf("key of %q must be at least 16 characters long", k.Name)
+	}
+	if len(fields) == 3 {
+		limit, unit, ok := strings.Cut(fields[2], "/")
+		n, err := strconv.Atoi(limit)
+		if !ok || err != nil || n < 1 {
+			return nil, fmt.Errorf("rate limit of %q must be LIMIT/UNIT with a positive LIMIT, e.g. 60/m", k.Name)
+		}
+		per, ok := map[string]time.Duration{"s": time.Second, "m": time.Minute, "h": time.Hour, "d": 24 * time.Hour}[unit]
+		if !ok {
+			return nil, fmt.Errorf("rate limit unit of %q must be s, m, h or d, got %q", k.Name, unit)
+		}
+		k.Limit, k.Per, k.tokens = n, per, float64(n)
+	}
+	return k, nil
+}
+
+// authenticate returns the key presented by r, as "Authorization: Bearer
+// KEY" or in an X-API-Key header.
+func (a *apiKeys) authenticate(r *http.Request) (*apiKey, error) {
+	presented := r.Header.Get("X-API-Key")
+	if token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+		presented = strings.TrimSpace(token)
+	}
+	if presented == "" {
+		return nil, errUnauthorized
+	}
+	// Every key is compared, so the time taken does not tell which matched
+	var found *apiKey
+	for _, k := range a.keys {
+		if subtle.ConstantTimeCompare(k.key, []byte(presented)) == 1 {
+			found = k
+		}
+	}
+	if found == nil {
+		return nil, errUnauthorized
+	}
+	return found, nil
+}
+
+// allow takes one request from the key's rate limit. When the limit is
+// exhausted it returns false and how long until the next request is
+// allowed.
+func (k *apiKey) allow(now time.Time) (bool, time.Duration) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.usage.Requests++
+	k.usage.LastUsed = now
+	if k.Limit == 0 {
+		return true, 0
+	}
+
+	interval := k.Per / time.Duration(k.Limit)
+	if !k.filled.IsZero() {
+		k.tokens = math.Min(float64(k.Limit), k.tokens+float64(now.Sub(k.filled))/float64(interval))
+	}
+	k.filled = now
+	if k.tokens < 1 {
+		k.usage.RateLimited++
+		return false, time.Duration((1 - k.tokens) * float64(interval))
+	}
+	k.tokens--
+	return true, 0
+}
+
+// record accounts a request of the key that was let through.
+func (k *apiKey) record(took time.Duration, failed bool) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if failed {
+		k.usage.Failed++
+	} else {
+		k.usage.Succeeded++
+	}
+	k.usage.RenderSeconds += took.Seconds()
+}
+
+// Usage returns a copy of what the key has used so far.
+func (k *apiKey) Usage() keyUsage {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	return k.usage
+}
+
+// name returns the name of the key for logs, or "" for no key.
+func (k *apiKey) name() string {
+	if k == nil {
+		return ""
+	}
+	return k.Name
+}
+
+// logUsage logs the usage of every key, e.g. on shutdown.
+func (a *apiKeys) logUsage() {
+	for _, k := range a.keys {
+		u := k.Usage()
+		slog.Info("API key usage", "key", u.Key, "requests", u.Requests, "succeeded", u.Succeeded,
+			"failed", u.Failed, "rateLimited", u.RateLimited, "renderSeconds", math.Round(u.RenderSeconds*10)/10)
+	}
+}
+
+// retryAfterSeconds formats d for a Retry-After header, rounding up to at
+// least one second.
+func retryAfterSeconds(d time.Duration) string {
+	return strconv.Itoa(max(1, int(math.Ceil(d.Seconds()))))
+}
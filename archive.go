@@ -0,0 +1,243 @@
+package main
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	chromedphelper "github.com/pesarkhobeee/that-cli-web-toolbox/pkg/chromedp"
+)
+
+type archiveConfig struct {
+	Dir string
+	Tar bool
+}
+
+var archiveCfg archiveConfig
+
+var archiveCmd = &cobra.Command{
+	Use:   "archive [flags] URL|FILE... [-- CAPTURE FLAGS]",
+	Short: "Archive pages: screenshot, PDF, MHTML, text and metadata in a dated folder",
+	Long: `Archive pages in one load each: a full page screenshot, a PDF, an MHTML
+snapshot (the page with its resources in one file, which browsers open
+offline), the body text and a metadata JSON file (final URL, status,
+title, description, canonical URL, redirects and the checksums of the
+other files).
+
+The files go into a folder of --dir named after the date, time and page,
+e.g. archives/2024-06-30_142501_example-com-pricing, or with --tar into a
+.tar.gz file of that name. Several pages go into one folder, their files
+prefixed with each page's name.
+
+Flags of the capture, such as --device, --cookies-file or --step, follow
+"--". They can also turn outputs off, e.g. --printtopdf=false.`,
+	Example: `  # Archive a page into archives/
+  that-cli-web-toolbox archive https://example.com/pricing
+
+  # Archive several pages as one tarball into /srv/archive
+  that-cli-web-toolbox archive --dir /srv/archive --tar https://example.com https://example.com/terms
+
+  # Archive the page as logged in, on a phone, without the PDF
+  that-cli-web-toolbox archive https://example.com/account -- --cookies-file session.json --device "iPhone 12" --printtopdf=false`,
+	RunE: runArchive,
+	Args: cobra.MinimumNArgs(1),
+	// Failures are reported like those of any capture
+	SilenceUsage:  true,
+	SilenceErrors: true,
+}
+
+func init() {
+	archiveCmd.Flags().StringVar(&archiveCfg.Dir, "dir", "archives", "Directory the dated archive folders go into")
+	archiveCmd.Flags().BoolVar(&archiveCfg.Tar, "tar", false, "Write each archive as a .tar.gz file instead of a folder")
+	rootCmd.AddCommand(archiveCmd)
+}
+
+// archiveFlags are the root flags archive sets, unless given after "--".
+var archiveFlags = []string{"screenshot", "printtopdf", "mhtml", "body", "metadata"}
+
+func runArchive(cmd *cobra.Command, args []string) error {
+	targets := args
+	if dash := cmd.ArgsLenAtDash(); dash >= 0 {
+		targets = args[:dash]
+		if err := rootCmd.Flags().Parse(args[dash:]); err != nil {
+			return err
+		}
+		if extra := rootCmd.Flags().Args(); len(extra) > 0 {
+			return fmt.Errorf("pages go before \"--\", not after: %v", extra)
+		}
+	}
+	if len(targets) == 0 {
+		return fmt.Errorf("at least one page to archive must be given")
+	}
+	if rootCmd.Flags().Changed("sink") {
+		return fmt.Errorf("archive writes to --dir, --sink cannot be used")
+	}
+
+	name := time.Now().Format("2006-01-02_150405")
+	if len(targets) == 1 {
+		name += "_" + slugify(targets[0])
+	}
+	dir := filepath.Join(archiveCfg.Dir, name)
+	settings := map[string]string{"sink": "file:" + dir}
+	for _, flag := range archiveFlags {
+		settings[flag] = "true"
+	}
+	for flag, value := range settings {
+		if rootCmd.Flags().Changed(flag) {
+			continue
+		}
+		if err := rootCmd.Flags().Set(flag, value); err != nil {
+			return err
+		}
+	}
+
+	rootCmd.SetContext(cmd.Context())
+	err := runThatCliWebBrowser(rootCmd, targets)
+	if _, statErr := os.Stat(dir); statErr != nil {
+		return err
+	}
+	if !archiveCfg.Tar {
+		if !structuredOutput() {
+			fmt.Printf("Archived to %s\n", dir)
+		}
+		return err
+	}
+	file := dir + ".tar.gz"
+	if tarErr := tarDir(dir, file); tarErr != nil {
+		slog.Error("Failed to write archive", "file", file, "error", tarErr)
+		return fmt.Errorf("failed to write archive %q: %w", file, tarErr)
+	}
+	if rmErr := os.RemoveAll(dir); rmErr != nil {
+		slog.Warn("Failed to remove archive folder", "dir", dir, "error", rmErr)
+	}
+	if !structuredOutput() {
+		fmt.Printf("Archived to %s\n", file)
+	}
+	return err
+}
+
+// tarDir writes the files of dir as a gzipped tar archive to file, under
+// a folder named like dir.
+func tarDir(dir, file string) (err error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	f, err := os.Create(file)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+	}()
+	gz := gzip.NewWriter(f)
+	tw := tar.NewWriter(gz)
+	base := filepath.Base(dir)
+	for _, e := range entries {
+		if !e.Type().IsRegular() {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			return err
+		}
+		hdr, err := tar.FileInfoHeader(info, "")
+		if err != nil {
+			return err
+		}
+		hdr.Name = base + "/" + e.Name()
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		src, err := os.Open(filepath.Join(dir, e.Name()))
+		if err != nil {
+			return err
+		}
+		_, err = io.Copy(tw, src)
+		src.Close()
+		if err != nil {
+			return err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+// mhtmlAction saves the page as an MHTML snapshot for --mhtml.
+type mhtmlAction struct {
+	noopAction
+	mhtml []byte
+}
+
+func (a *mhtmlAction) Name() string             { return "mhtml" }
+func (a *mhtmlAction) Enabled(cfg *Config) bool { return cfg.MHTML }
+func (a *mhtmlAction) Flags() []string          { return []string{"--mhtml"} }
+
+func (a *mhtmlAction) Execute(ctx context.Context, run *Run) error {
+	slog.Info("Capturing MHTML")
+	data, err := run.Browser.CaptureMHTML(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to capture MHTML: %w", err)
+	}
+	a.mhtml = data
+	return nil
+}
+
+func (a *mhtmlAction) Report(ctx context.Context, run *Run) error {
+	return writeArtifact(ctx, run, "mhtml", "", "MHTML", fmt.Sprintf("page_%s.mhtml", timestamp()), "multipart/related", a.mhtml)
+}
+
+// pageRecord is the metadata file of --metadata: what was captured, when
+// and from where.
+type pageRecord struct {
+	Target     string                       `json:"target"`
+	CapturedAt time.Time                    `json:"capturedAt"`
+	Page       *chromedphelper.PageView     `json:"page"`
+	Redirects  []chromedphelper.RedirectHop `json:"redirects,omitempty"`
+	// Files are the outputs of the actions before it, with checksums.
+	Files []chromedphelper.File `json:"files"`
+}
+
+// metadataAction writes a metadata JSON file next to the page's outputs
+// for --metadata.
+type metadataAction struct {
+	noopAction
+	record pageRecord
+}
+
+func (a *metadataAction) Name() string             { return "metadata" }
+func (a *metadataAction) Enabled(cfg *Config) bool { return cfg.Metadata }
+func (a *metadataAction) Flags() []string          { return []string{"--metadata"} }
+
+func (a *metadataAction) Execute(ctx context.Context, run *Run) error {
+	slog.Info("Reading page metadata")
+	page, err := run.Browser.PageView(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to read page metadata: %w", err)
+	}
+	a.record = pageRecord{Target: run.Result.Target, CapturedAt: time.Now().UTC(), Page: page}
+	return nil
+}
+
+func (a *metadataAction) Report(ctx context.Context, run *Run) error {
+	a.record.Redirects = run.Result.Redirects
+	a.record.Files = append([]chromedphelper.File{}, run.Result.Files...)
+	data, err := json.MarshalIndent(a.record, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode metadata: %w", err)
+	}
+	return writeArtifact(ctx, run, "metadata", "", "Metadata", fmt.Sprintf("metadata_%s.json", timestamp()), "application/json", data)
+}
@@ -0,0 +1,200 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/user"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/pesarkhobeee/that-cli-web-toolbox/pkg/audit"
+	"github.com/pesarkhobeee/that-cli-web-toolbox/pkg/sink"
+)
+
+// secretFlags are the flags whose values are replaced in the command line
+// recorded by --audit-log: credentials, and the steps and scripts that
+// may type them into the page.
+var secretFlags = map[string]bool{
+	"--basic-auth":     true,
+	"--header":         true,
+	"--cookie":         true,
+	"--consent-cookie": true,
+	"--step":           true,
+	"--consent-step":   true,
+	"--js":             true,
+}
+
+// auditRecorder collects what a run did for --audit-log.
+type auditRecorder struct {
+	start time.Time
+
+	mu        sync.Mutex
+	targets   []string
+	artifacts []audit.Artifact
+}
+
+func newAuditRecorder() *auditRecorder {
+	return &auditRecorder{start: time.Now()}
+}
+
+// setTargets records the targets of the run. It does nothing on a nil
+// recorder, as do the other methods.
+func (r *auditRecorder) setTargets(targets []string) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.targets = append([]string(nil), targets...)
+}
+
+// wrap returns s recording every output written to it.
+func (r *auditRecorder) wrap(s sink.Sink) sink.Sink {
+	if r == nil {
+		return s
+	}
+	return &auditSink{Sink: s, recorder: r}
+}
+
+// append writes the record of a run that ended with err to the log at path.
+// It returns err, or the failure to write the record when the run itself
+// succeeded, so a run that cannot be audited does not pass silently.
+func (r *auditRecorder) append(path string, err error) error {
+	r.mu.Lock()
+	rec := &audit.Record{
+		Time:      r.start,
+		User:      currentUser(),
+		Command:   redactArgs(os.Args),
+		Targets:   r.targets,
+		Artifacts: r.artifacts,
+		Outcome:   "success",
+	}
+	r.mu.Unlock()
+	if rec.Host, _ = os.Hostname(); rec.Host == "" {
+		rec.Host = "unknown"
+	}
+	if err != nil {
+		rec.Outcome = "failure"
+		rec.Error = err.Error()
+		rec.ExitCode = exitCode(err)
+	}
+
+	slog.Debug("Appending audit record", "file", path, "artifacts", len(rec.Artifacts))
+	if auditErr := audit.Append(path, rec); auditErr != nil {
+		slog.Error("Failed to write audit record", "file", path, "error", auditErr)
+		if err == nil {
+			return fmt.Errorf("failed to write audit record to %q: %w", path, auditErr)
+		}
+	}
+	return err
+}
+
+// auditSink is a Sink recording the outputs written through it.
+type auditSink struct {
+	sink.Sink
+	recorder *auditRecorder
+}
+
+// Write implements sink.Sink.
+func (s *auditSink) Write(ctx context.Context, name, contentType string, data []byte) (string, error) {
+	location, err := s.Sink.Write(ctx, name, contentType, data)
+	if err != nil {
+		return location, err
+	}
+	s.recorder.mu.Lock()
+	defer s.recorder.mu.Unlock()
+	s.recorder.artifacts = append(s.recorder.artifacts, audit.NewArtifact(name, location, contentType, data))
+	return location, nil
+}
+
+// isStdout reports whether s writes to standard output, looking through
+// the recording of --audit-log and --support-bundle.
+func isStdout(s sink.Sink) bool {
+	if b, ok := s.(*bundleSink); ok {
+		s = b.Sink
+	}
+	if a, ok := s.(*auditSink); ok {
+		s = a.Sink
+	}
+	_, ok := s.(*sink.Stdout)
+	return ok
+}
+
+// currentUser returns the name of the user running the command.
+func currentUser() string {
+	if u, err := user.Current(); err == nil && u.Username != "" {
+		return u.Username
+	}
+	if name := os.Getenv("USER"); name != "" {
+		return name
+	}
+	return "unknown"
+}
+
+// redactArgs returns args with the values of secretFlags replaced, so the
+// audit log does not become a store of credentials.
+func redactArgs(args []string) []string {
+	redacted := make([]string, len(args))
+	copy(redacted, args)
+	for i := 0; i < len(redacted); i++ {
+		arg := redacted[i]
+		if arg == "--" {
+			break
+		}
+		if name, _, ok := strings.Cut(arg, "="); ok && secretFlags[name] {
+			redacted[i] = name + "=REDACTED"
+		} else if secretFlags[arg] && i+1 < len(redacted) {
+			i++
+			redacted[i] = "REDACTED"
+		}
+	}
+	return redacted
+}
+
+var verifyAuditLogCmd = &cobra.Command{
+	Use:   "verify-audit-log FILE",
+	Short: "Verify the hash chain of an --audit-log file",
+	Long: `Check that no record of an --audit-log file was modified, inserted,
+reordered or removed, and print the number of records and the hash of the
+last one.
+
+Each record holds the SHA-256 hash of its content and the hash of the record
+before it. Removing records from the end of the log leaves a valid chain, so
+keep the printed last hash somewhere else and compare it on the next
+verification.`,
+	Example: `  that-cli-web-toolbox verify-audit-log audit.jsonl`,
+	Args:    cobra.ExactArgs(1),
+	RunE:    runVerifyAuditLog,
+}
+
+func init() {
+	rootCmd.AddCommand(verifyAuditLogCmd)
+}
+
+func runVerifyAuditLog(cmd *cobra.Command, args []string) error {
+	setupLogging(cfg.LogLevel)
+
+	f, err := os.Open(args[0])
+	if err != nil {
+		slog.Error("Failed to open audit log", "file", args[0], "error", err)
+		return fmt.Errorf("failed to open audit log %q: %w", args[0], err)
+	}
+	defer func() {
+		if err := f.Close(); err != nil {
+			slog.Warn("failed to close audit log", "file", args[0], "error", err)
+		}
+	}()
+
+	count, last, err := audit.Verify(f)
+	if err != nil {
+		slog.Error("Audit log does not verify", "file", args[0], "validRecords", count, "error", err)
+		return fmt.Errorf("audit log %q does not verify after %d valid records: %w", args[0], count, err)
+	}
+	fmt.Printf("%s: %d records verified, last hash %s\n", args[0], count, last)
+	return nil
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	chromedphelper "github.com/pesarkhobeee/that-cli-web-toolbox/pkg/chromedp"
+	"github.com/pesarkhobeee/that-cli-web-toolbox/pkg/events"
+)
+
+// parseHeaders parses --header values written as "Name: value".
+func parseHeaders(specs []string) (map[string]string, error) {
+	if len(specs) == 0 {
+		return nil, nil
+	}
+	headers := make(map[string]string, len(specs))
+	for _, spec := range specs {
+		name, value, ok := strings.Cut(spec, ":")
+		name = strings.TrimSpace(name)
+		if !ok || name == "" {
+			return nil, fmt.Errorf("invalid header %q (expected \"Name: value\")", spec)
+		}
+		headers[name] = strings.TrimSpace(value)
+	}
+	return headers, nil
+}
+
+// parseBasicAuth parses --basic-auth written as "user:pass".
+func parseBasicAuth(spec string) (*chromedphelper.Credentials, error) {
+	if spec == "" {
+		return nil, nil
+	}
+	username, password, ok := strings.Cut(spec, ":")
+	if !ok || username == "" {
+		return nil, fmt.Errorf("invalid basic auth (expected user:pass)")
+	}
+	return &chromedphelper.Credentials{Username: username, Password: password}, nil
+}
+
+// loadCookies returns the cookies from --cookies-file followed by the
+// --cookie values written as "name=value".
+func loadCookies(specs []string, file string) ([]chromedphelper.Cookie, error) {
+	var cookies []chromedphelper.Cookie
+	if file != "" {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			slog.Error("Failed to read cookies file", "file", file, "error", err)
+			return nil, fmt.Errorf("failed to read cookies file %q: %w", file, err)
+		}
+		if err := json.Unmarshal(data, &cookies); err != nil {
+			return nil, fmt.Errorf("failed to parse cookies file %q: %w", file, err)
+		}
+		slog.Debug("Cookies file loaded", "file", file, "cookies", len(cookies))
+	}
+
+	for _, spec := range specs {
+		name, value, ok := strings.Cut(spec, "=")
+		name = strings.TrimSpace(name)
+		if !ok || name == "" {
+			return nil, fmt.Errorf("invalid cookie %q (expected name=value)", spec)
+		}
+		cookies = append(cookies, chromedphelper.Cookie{Name: name, Value: strings.TrimSpace(value)})
+	}
+	return cookies, nil
+}
+
+// saveCookiesAction writes the page's cookies to --save-cookies after the
+// JS and steps have run, so a session established there can be reused
+// with --cookies-file.
+type saveCookiesAction struct{ noopAction }
+
+func (a *saveCookiesAction) Name() string             { return "save-cookies" }
+func (a *saveCookiesAction) Enabled(cfg *Config) bool { return cfg.SaveCookies != "" }
+func (a *saveCookiesAction) Flags() []string          { return []string{"--save-cookies"} }
+
+func (a *saveCookiesAction) Execute(ctx context.Context, run *Run) error {
+	slog.Info("Saving cookies")
+	cookies, err := run.Browser.GetCookies(ctx)
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(cookies, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode cookies: %w", err)
+	}
+
+	// Cookies are credentials: write them straight to a private local file
+	// rather than through the sink, so the next run can load them.
+	path := run.Config.SaveCookies
+	if err := os.WriteFile(path, append(data, '\n'), 0o600); err != nil {
+		slog.Error("Failed to save cookies", "file", path, "error", err)
+		return fmt.Errorf("failed to save cookies %q: %w", path, err)
+	}
+	slog.Info("Cookies saved successfully", "file", path, "count", len(cookies))
+	if !structuredFormat(run.Config.OutputFormat) {
+		fmt.Printf("Cookies saved as %s\n", path)
+	}
+	return nil
+}
+
+// exportAuthAction writes the session's cookie header and the bearer tokens
+// the page sent to --export-auth, as shell variables other tools can use.
+type exportAuthAction struct {
+	noopAction
+
+	mu     sync.Mutex
+	tokens []string
+}
+
+func (a *exportAuthAction) Name() string             { return "export-auth" }
+func (a *exportAuthAction) Enabled(cfg *Config) bool { return cfg.ExportAuth != "" }
+func (a *exportAuthAction) Flags() []string          { return []string{"--export-auth"} }
+
+func (a *exportAuthAction) Prepare(ctx context.Context, run *Run) error {
+	// Tokens are only visible in the Authorization headers of requests
+	stream := run.Browser.Events()
+	go func() {
+		for ev := range stream {
+			req, ok := ev.(events.RequestFinished)
+			if !ok {
+				continue
+			}
+			token := bearerToken(headerValue(req.RequestHeaders, "Authorization"))
+			if token == "" {
+				continue
+			}
+			a.mu.Lock()
+			if !contains(a.tokens, token) {
+				a.tokens = append(a.tokens, token)
+			}
+			a.mu.Unlock()
+		}
+	}()
+	return nil
+}
+
+func (a *exportAuthAction) Execute(ctx context.Context, run *Run) error {
+	slog.Info("Exporting session credentials")
+	cookies, err := run.Browser.GetCookies(ctx)
+	if err != nil {
+		return err
+	}
+	pairs := make([]string, 0, len(cookies))
+	for _, c := range cookies {
+		pairs = append(pairs, c.Name+"="+c.Value)
+	}
+	a.mu.Lock()
+	tokens := append([]string(nil), a.tokens...)
+	a.mu.Unlock()
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "# Session of %s, exported %s\n", run.Browser.TargetURL, time.Now().UTC().Format(time.RFC3339))
+	fmt.Fprintf(&b, "# Load with: set -a; . ./%s; set +a\n", run.Config.ExportAuth)
+	fmt.Fprintf(&b, "COOKIE=%s\n", shellQuote(strings.Join(pairs, "; ")))
+	for i, token := range tokens {
+		if i == 0 {
+			fmt.Fprintf(&b, "AUTHORIZATION=%s\n", shellQuote("Bearer "+token))
+			fmt.Fprintf(&b, "BEARER_TOKEN=%s\n", shellQuote(token))
+			continue
+		}
+		fmt.Fprintf(&b, "BEARER_TOKEN_%d=%s\n", i+1, shellQuote(token))
+	}
+
+	// Like --save-cookies, the file holds credentials: keep it private and
+	// out of the sink
+	path := run.Config.ExportAuth
+	if err := os.WriteFile(path, []byte(b.String()), 0o600); err != nil {
+		slog.Error("Failed to export session credentials", "file", path, "error", err)
+		return fmt.Errorf("failed to export session credentials %q: %w", path, err)
+	}
+	slog.Info("Session credentials exported successfully", "file", path, "cookies", len(cookies), "bearerTokens", len(tokens))
+	if !structuredFormat(run.Config.OutputFormat) {
+		fmt.Printf("Session credentials exported as %s\n", path)
+	}
+	return nil
+}
+
+// bearerToken returns the token of an Authorization header using the
+// Bearer scheme, or "".
+func bearerToken(authorization string) string {
+	scheme, token, ok := strings.Cut(strings.TrimSpace(authorization), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return ""
+	}
+	return strings.TrimSpace(token)
+}
@@ -0,0 +1,323 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"image/png"
+	"log/slog"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+
+	"github.com/pesarkhobeee/that-cli-web-toolbox/pkg/baseline"
+	"github.com/pesarkhobeee/that-cli-web-toolbox/pkg/imagediff"
+)
+
+// baselineThreshold is the largest difference of a color channel still
+// counted as the same color when comparing with a baseline. Both images
+// come from the same browser, so it only absorbs rendering noise.
+const baselineThreshold = 16
+
+// baselineAction compares the page's screenshot with its approved
+// baseline in --baseline-dir. A screenshot without a baseline, or differing
+// from it by more than --tolerance, is left as a candidate for "baseline
+// approve"; a differing one fails the page.
+type baselineAction struct {
+	noopAction
+	tolerance float64
+	regions   ignoreRegions
+	diff      []byte
+}
+
+func (a *baselineAction) Name() string             { return "baseline" }
+func (a *baselineAction) Enabled(cfg *Config) bool { return cfg.BaselineDir != "" }
+func (a *baselineAction) Flags() []string          { return []string{"--baseline-dir"} }
+
+func (a *baselineAction) Validate(cfg *Config) error {
+	var err error
+	if a.tolerance, err = parsePercentage(cfg.Tolerance); err != nil {
+		return fmt.Errorf("invalid --tolerance: %w", err)
+	}
+	a.regions, err = parseIgnoreRegions(cfg)
+	return err
+}
+
+func (a *baselineAction) Execute(ctx context.Context, run *Run) error {
+	store := baseline.Store{Dir: run.Config.BaselineDir}
+	entry := baseline.Entry{URL: run.Result.Target, Viewport: baselineViewport(run.Config), Theme: baselineTheme(run.Config)}
+	entry.Key = baseline.Key(entry.URL, entry.Viewport, entry.Theme)
+	slog.Info("Comparing page with baseline", "key", entry.Key)
+
+	shot, err := captureForDiff(ctx, run, a.regions)
+	if err != nil {
+		return err
+	}
+	check := &baseline.Check{Key: entry.Key}
+	run.Result.Baseline = check
+	approved, err := store.Baseline(entry.Key)
+	if errors.Is(err, os.ErrNotExist) {
+		check.Status = baseline.StatusNew
+		check.Candidate, err = store.SaveCandidate(entry, shot.png, nil)
+		return err
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read baseline: %w", err)
+	}
+	expected, err := png.Decode(bytes.NewReader(approved))
+	if err != nil {
+		return fmt.Errorf("failed to decode baseline of %s: %w", entry.Key, err)
+	}
+
+	res := imagediff.Compare(shot.image, expected, imagediff.Options{Threshold: baselineThreshold, Ignore: shot.ignore})
+	check.Ratio, check.Regions = res.Ratio, len(res.Regions)
+	resized := expected.Bounds().Size() != shot.image.Bounds().Size()
+	if !resized && res.Ratio*100 <= a.tolerance {
+		check.Status = baseline.StatusUnchanged
+		if err := store.ClearCandidate(entry.Key); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		return nil
+	}
+	if resized {
+		slog.Warn("Screenshot size differs from baseline", "key", entry.Key,
+			"baseline", sizeOf(expected), "screenshot", sizeOf(shot.image))
+	}
+	if a.diff, err = encodePNG(res.Overlay); err != nil {
+		return fmt.Errorf("failed to encode baseline diff: %w", err)
+	}
+	check.Status = baseline.StatusChanged
+	entry.Ratio = res.Ratio
+	check.Candidate, err = store.SaveCandidate(entry, shot.png, a.diff)
+	return err
+}
+
+func (a *baselineAction) Report(ctx context.Context, run *Run) error {
+	check := run.Result.Baseline
+	// The overlay also goes to the outputs, for reviews away from the
+	// baselines directory such as --github-pr's
+	if a.diff != nil {
+		if err := writeArtifact(ctx, run, "baseline-diff", "", "Baseline diff", fmt.Sprintf("baselinediff_%s.png", timestamp()), "image/png", a.diff); err != nil {
+			return err
+		}
+	}
+	// Batch runs list the outcome in the summary instead
+	if !run.Batch && !structuredFormat(run.Config.OutputFormat) {
+		fmt.Printf("Baseline %s: %s\n", check.Key, formatBaselineCheck(check))
+		if check.Status != baseline.StatusUnchanged {
+			fmt.Printf("  review %s, then: that-cli-web-toolbox baseline approve --dir %s %s\n", check.Candidate, run.Config.BaselineDir, check.Key)
+		}
+	}
+	if check.Status == baseline.StatusChanged {
+		return &exitError{code: exitAssertion, err: fmt.Errorf("page differs from its baseline in %.2f%% of pixels, more than %g%%", check.Ratio*100, a.tolerance)}
+	}
+	return nil
+}
+
+// formatBaselineCheck renders the outcome of a baseline comparison, e.g.
+// "changed, 3.20% of pixels in 4 regions".
+func formatBaselineCheck(check *baseline.Check) string {
+	switch check.Status {
+	case baseline.StatusNew:
+		return "new, no approved baseline yet"
+	case baseline.StatusChanged:
+		return fmt.Sprintf("changed, %.2f%% of pixels in %d regions", check.Ratio*100, check.Regions)
+	}
+	return check.Status
+}
+
+// baselineViewport names the viewport of the screenshots of cfg: the
+// device or viewport size, and whether they show the whole page.
+func baselineViewport(cfg *Config) string {
+	viewport := "default"
+	switch {
+	case cfg.Device != "":
+		viewport = cfg.Device
+	case cfg.Viewport != "":
+		viewport = strings.ToLower(cfg.Viewport)
+	}
+	if cfg.FullPage {
+		viewport += " full"
+	}
+	return viewport
+}
+
+// baselineTheme names the color scheme of the screenshots of cfg.
+func baselineTheme(cfg *Config) string {
+	if cfg.DarkMode {
+		return "dark"
+	}
+	return "light"
+}
+
+func sizeOf(img image.Image) string {
+	return fmt.Sprintf("%dx%d", img.Bounds().Dx(), img.Bounds().Dy())
+}
+
+type baselineConfig struct {
+	Dir    string
+	All    bool
+	Status string
+	Format string
+}
+
+var baselineCfg baselineConfig
+
+var baselineCmd = &cobra.Command{
+	Use:   "baseline",
+	Short: "List, approve and reject the screenshots of visual regression tests",
+	Long: `Manage the baselines directory of --baseline-dir, which holds an approved
+screenshot per URL, viewport and theme.
+
+A capture with --baseline-dir compares its screenshot with the approved one.
+When there is none yet, or the page changed by more than --tolerance, the
+screenshot is kept as a candidate, with an overlay of the differences, until
+it is approved, replacing the baseline, or rejected. A changed page fails
+with exit code 3 until then.
+
+Entries are selected by their key, as printed by the capture and by list, or
+by URL, selecting every viewport and theme of it.`,
+	Example: `  # Capture, then review what changed
+  that-cli-web-toolbox --baseline-dir baselines --input-file urls.txt
+  that-cli-web-toolbox baseline list --dir baselines --status changed
+
+  # The redesign of the pricing page was intended
+  that-cli-web-toolbox baseline approve --dir baselines https://example.com/pricing
+
+  # Drop every candidate
+  that-cli-web-toolbox baseline reject --dir baselines --all`,
+}
+
+var baselineListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the baselines and the candidates waiting for approval",
+	RunE:  runBaselineList,
+	Args:  cobra.NoArgs,
+}
+
+var baselineApproveCmd = &cobra.Command{
+	Use:   "approve KEY|URL...",
+	Short: "Make candidates the baselines of their entries",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return updateBaselines(args, "Approved", baseline.Store.Approve)
+	},
+}
+
+var baselineRejectCmd = &cobra.Command{
+	Use:   "reject KEY|URL...",
+	Short: "Remove candidates, keeping the baselines",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return updateBaselines(args, "Rejected", baseline.Store.Reject)
+	},
+}
+
+func init() {
+	baselineCmd.PersistentFlags().StringVar(&baselineCfg.Dir, "dir", "baselines", "Baselines directory, as given to --baseline-dir")
+	baselineListCmd.Flags().StringVar(&baselineCfg.Status, "status", "", "Only list entries with this status: approved, new or changed")
+	baselineListCmd.Flags().StringVar(&baselineCfg.Format, "format", "text", "Output format: text or json")
+	for _, cmd := range []*cobra.Command{baselineApproveCmd, baselineRejectCmd} {
+		cmd.Flags().BoolVar(&baselineCfg.All, "all", false, "Select every entry with a candidate")
+	}
+	baselineCmd.AddCommand(baselineListCmd, baselineApproveCmd, baselineRejectCmd)
+	rootCmd.AddCommand(baselineCmd)
+}
+
+func runBaselineList(cmd *cobra.Command, args []string) error {
+	setupLogging(cfg.LogLevel)
+	switch baselineCfg.Status {
+	case "", baseline.StatusApproved, baseline.StatusNew, baseline.StatusChanged:
+	default:
+		return fmt.Errorf("unsupported --status %q (expected approved, new or changed)", baselineCfg.Status)
+	}
+	if baselineCfg.Format != "text" && baselineCfg.Format != "json" {
+		return fmt.Errorf("unsupported --format %q (expected text or json)", baselineCfg.Format)
+	}
+	entries, err := baseline.Store{Dir: baselineCfg.Dir}.List()
+	if err != nil {
+		return err
+	}
+	selected := []baseline.Entry{}
+	for _, e := range entries {
+		if baselineCfg.Status == "" || e.Status == baselineCfg.Status {
+			selected = append(selected, e)
+		}
+	}
+	if baselineCfg.Format == "json" {
+		return emitJSON(selected)
+	}
+	if len(selected) == 0 {
+		fmt.Printf("No baselines in %s\n", baselineCfg.Dir)
+		return nil
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "STATUS\tURL\tVIEWPORT\tTHEME\tDIFF\tKEY")
+	for _, e := range selected {
+		diff := "-"
+		if e.Status == baseline.StatusChanged {
+			diff = fmt.Sprintf("%.2f%%", e.Ratio*100)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", e.Status, e.URL, e.Viewport, e.Theme, diff, e.Key)
+	}
+	return w.Flush()
+}
+
+// updateBaselines applies update to the entries selected by args, or with
+// --all to every entry with a candidate.
+func updateBaselines(args []string, done string, update func(baseline.Store, string) error) error {
+	setupLogging(cfg.LogLevel)
+	if baselineCfg.All == (len(args) > 0) {
+		return fmt.Errorf("name the entries to update by key or URL, or use --all")
+	}
+	store := baseline.Store{Dir: baselineCfg.Dir}
+	entries, err := store.List()
+	if err != nil {
+		return err
+	}
+	var keys []string
+	for _, e := range entries {
+		if e.Status == baseline.StatusApproved {
+			continue
+		}
+		if baselineCfg.All || selectsBaseline(args, e) {
+			keys = append(keys, e.Key)
+		}
+	}
+	for _, arg := range args {
+		if !matchesAny(entries, arg) {
+			return fmt.Errorf("no baseline in %s has the key or URL %s", baselineCfg.Dir, arg)
+		}
+	}
+	if len(keys) == 0 {
+		fmt.Println("No candidates to update")
+		return nil
+	}
+	for _, key := range keys {
+		if err := update(store, key); err != nil {
+			return fmt.Errorf("failed to update %s: %w", key, err)
+		}
+		fmt.Printf("%s %s\n", done, key)
+	}
+	return nil
+}
+
+func selectsBaseline(args []string, e baseline.Entry) bool {
+	for _, arg := range args {
+		if arg == e.Key || arg == e.URL {
+			return true
+		}
+	}
+	return false
+}
+
+func matchesAny(entries []baseline.Entry, arg string) bool {
+	for _, e := range entries {
+		if arg == e.Key || arg == e.URL {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,649 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	chromedphelper "github.com/pesarkhobeee/that-cli-web-toolbox/pkg/chromedp"
+	"github.com/pesarkhobeee/that-cli-web-toolbox/pkg/idn"
+	"github.com/pesarkhobeee/that-cli-web-toolbox/pkg/runstore"
+	"github.com/pesarkhobeee/that-cli-web-toolbox/pkg/sink"
+	"github.com/pesarkhobeee/that-cli-web-toolbox/pkg/urlfilter"
+)
+
+// resolveTarget turns a CLI input into a navigable URL: existing local
+// files become file:// URLs, Unicode host names are converted to Punycode
+// and anything else is used as given.
+func resolveTarget(input string) (string, error) {
+	slog.Debug("Processing input", "input", input)
+
+	// Validate input
+	if strings.TrimSpace(input) == "" {
+		slog.Error("Empty target provided")
+		return "", fmt.Errorf("target cannot be empty")
+	}
+
+	// Detect if input is a local file
+	if _, err := os.Stat(input); err == nil {
+		abs, err := filepath.Abs(input)
+		if err != nil {
+			slog.Error("Failed to get absolute path", "input", input, "error", err)
+			return "", fmt.Errorf("failed to get absolute path for %q: %w", input, err)
+		}
+		slog.Debug("Input detected as local file", "absolutePath", abs)
+		return "file://" + abs, nil
+	}
+
+	// Basic URL validation
+	if !strings.HasPrefix(input, "http://") && !strings.HasPrefix(input, "https://") && !strings.HasPrefix(input, "file://") {
+		slog.Warn("Input does not appear to be a valid URL, treating as URL anyway", "input", input)
+	}
+
+	// Navigate to internationalized domains by their Punycode form
+	ascii, err := idn.URLToASCII(input)
+	if err != nil {
+		slog.Error("Invalid internationalized domain name", "input", input, "error", err)
+		return "", fmt.Errorf("invalid internationalized domain name in %q: %w", input, err)
+	}
+	if ascii != input {
+		slog.Info("Converted internationalized domain name to Punycode", "input", input, "url", ascii)
+	}
+	slog.Debug("Input treated as URL", "url", ascii)
+	return ascii, nil
+}
+
+// readInputFile returns the targets listed in path, one per line.
+// Blank lines and lines starting with # are skipped.
+func readInputFile(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := f.Close(); err != nil {
+			slog.Warn("failed to close input file", "file", path, "error", err)
+		}
+	}()
+
+	var inputs []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		inputs = append(inputs, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	slog.Debug("Input file loaded", "file", path, "targets", len(inputs))
+	return inputs, nil
+}
+
+var slugUnsafe = regexp.MustCompile(`[^a-z0-9]+`)
+
+// slugify derives a filename-safe prefix from a target URL.
+func slugify(target string) string {
+	s := strings.ToLower(target)
+	for _, scheme := range []string{"https://", "http://", "file://"} {
+		s = strings.TrimPrefix(s, scheme)
+	}
+	s = strings.Trim(slugUnsafe.ReplaceAllString(s, "-"), "-")
+	if len(s) > 80 {
+		s = strings.TrimRight(s[:80], "-")
+	}
+	if s == "" {
+		s = "target"
+	}
+	return s
+}
+
+// batchTarget is one page load of a run: a URL, optionally under a locale
+// and a consent state.
+type batchTarget struct {
+	URL     string
+	Locale  string
+	Consent string
+}
+
+// labels returns the locale and consent state that are set.
+func (t batchTarget) labels() []string {
+	var labels []string
+	for _, l := range []string{t.Locale, t.Consent} {
+		if l != "" {
+			labels = append(labels, l)
+		}
+	}
+	return labels
+}
+
+// String returns the URL, followed by its labels in brackets if any.
+func (t batchTarget) String() string {
+	labels := t.labels()
+	if len(labels) == 0 {
+		return t.URL
+	}
+	return t.URL + " [" + strings.Join(labels, ", ") + "]"
+}
+
+// expandTargets returns one target per URL, locale and consent state, with
+// the locale substituted for {locale} in the URL, so the variants of a page
+// are processed next to each other. Empty locales or consentStates leave
+// that dimension out.
+func expandTargets(urls, locales, consentStates []string) []batchTarget {
+	if len(locales) == 0 {
+		locales = []string{""}
+	}
+	if len(consentStates) == 0 {
+		consentStates = []string{""}
+	}
+	var targets []batchTarget
+	for _, u := range urls {
+		for _, locale := range locales {
+			for _, consent := range consentStates {
+				target := batchTarget{URL: u, Locale: locale, Consent: consent}
+				if locale != "" {
+					target.URL = strings.ReplaceAll(u, localePlaceholder, locale)
+				}
+				targets = append(targets, target)
+			}
+		}
+	}
+	return targets
+}
+
+// batchResult is the outcome of one target in a batch run.
+type batchResult struct {
+	Target    string
+	Err       error
+	Duration  time.Duration
+	Signature *pageSignature
+	Sitemap   *sitemapEntry
+	Visual    *visualPage
+	History   *runstore.Run
+	Result    *chromedphelper.Result
+}
+
+// runOutcomes collects the outcome of every target of a run for the
+// reports written when it ends, such as --github-pr's and JUnit XML.
+type runOutcomes struct {
+	mu      sync.Mutex
+	results []batchResult
+}
+
+// add records the outcome of a target. It does nothing on nil outcomes.
+func (o *runOutcomes) add(r batchResult) {
+	if o == nil {
+		return
+	}
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.results = append(o.results, r)
+}
+
+func (o *runOutcomes) all() []batchResult {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return append([]batchResult(nil), o.results...)
+}
+
+// outcomeDetails lists what the checks and audits of a target found, in
+// the words of the batch summary.
+func outcomeDetails(r batchResult) []string {
+	var details []string
+	if r.Err != nil {
+		details = append(details, "error: "+r.Err.Error())
+	}
+	res := r.Result
+	if res == nil {
+		return details
+	}
+	if len(res.Checks) > 0 {
+		details = append(details, "checks: "+formatChecks(res.Checks))
+	}
+	if len(res.Headers) > 0 {
+		details = append(details, "headers: "+formatHeaderChecks(res.Headers))
+	}
+	if res.Soft404 != nil {
+		details = append(details, "soft-404: "+formatSoft404(res.Soft404))
+	}
+	if res.Overlays != nil {
+		details = append(details, "overlays: "+formatOverlays(res.Overlays))
+	}
+	if res.DesignDiff != nil {
+		details = append(details, "design: "+formatDesignDiff(res.DesignDiff))
+	}
+	if res.Baseline != nil {
+		details = append(details, "baseline: "+formatBaselineCheck(res.Baseline))
+	}
+	if len(res.FailIf) > 0 {
+		details = append(details, "fail-if: "+formatFailIf(res.FailIf))
+	}
+	if res.Contrast != nil {
+		details = append(details, "contrast: "+formatContrast(res.Contrast))
+	}
+	if res.Keyboard != nil {
+		details = append(details, "keyboard: "+formatKeyboardAudit(res.Keyboard))
+	}
+	return details
+}
+
+// runBatch processes targets concurrently in tabs of a single browser and
+// prints a per-target summary. It fails if any target failed.
+func runBatch(ctx context.Context, targets []batchTarget, jsCode string, setup *pageSetup, artifacts, text sink.Sink) error {
+	slog.Info("Starting batch run", "targets", len(targets), "concurrency", cfg.Concurrency)
+
+	pool := &lazyPool{open: func() (*chromedphelper.Pool, error) {
+		pool, err := chromedphelper.NewPool(ctx, cfg.Concurrency, cfg.Delay, cfg.RemoteDebuggingPort, jsCode, launchOptions(&cfg)...)
+		if err != nil {
+			slog.Error("Failed to initialize browser", "error", err)
+			return nil, fmt.Errorf("failed to initialize browser: %w", err)
+		}
+		pool.Configure(setup.apply)
+		return pool, nil
+	}}
+	defer pool.Close()
+	// Targets extracted without a browser may not need one at all
+	if !staticMode() {
+		if _, err := pool.get(); err != nil {
+			return err
+		}
+	}
+
+	// Disambiguate targets that slugify to the same prefix
+	prefixes := make([]string, len(targets))
+	used := make(map[string]int)
+	for i, target := range targets {
+		slug := slugify(target.URL)
+		for _, label := range target.labels() {
+			slug += "_" + strings.ToLower(label)
+		}
+		used[slug]++
+		if used[slug] > 1 {
+			slug = fmt.Sprintf("%s-%d", slug, used[slug])
+		}
+		prefixes[i] = slug + "_"
+	}
+
+	results := make([]batchResult, len(targets))
+	var wg sync.WaitGroup
+	for i, target := range targets {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			start := time.Now()
+			run, err := runStaticTarget(ctx, target, setup, prefixes[i], true, artifacts, text)
+			if errors.Is(err, errRender) {
+				static := err
+				run, err = runBatchTarget(ctx, pool, target, setup, prefixes[i], artifacts, text)
+				// A dead proxy was blacklisted; try the target with another
+				for attempt := 1; attempt < proxyAttempts && setup.Proxies != nil && proxyFailed(err); attempt++ {
+					slog.Warn("Retrying target with another proxy", "target", target.URL, "attempt", attempt+1)
+					run, err = runBatchTarget(ctx, pool, target, setup, prefixes[i], artifacts, text)
+				}
+				// A crashed tab is gone, but the browser lives on: try once
+				// more in a fresh tab
+				if crash := tabCrash(err); crash != nil {
+					slog.Warn("Retrying target in a new tab after a crash", "target", target.URL, "crash", crash.Kind)
+					run, err = runBatchTarget(ctx, pool, target, setup, prefixes[i], artifacts, text)
+					recoverCrash(run, crash)
+				}
+				recordEscalation(run, static)
+			}
+			results[i] = batchResult{
+				Target:    target.String(),
+				Err:       err,
+				Duration:  time.Since(start),
+				Signature: run.Signature,
+				Sitemap:   run.Sitemap,
+				Visual:    run.Visual,
+				History:   run.History,
+				Result:    run.Result,
+			}
+			if err != nil {
+				slog.Error("Target failed", "target", target.URL, "locale", target.Locale, "consent", target.Consent, "error", err)
+				run.Result.Error = err.Error()
+			} else {
+				slog.Info("Target completed", "target", target.URL, "locale", target.Locale, "consent", target.Consent)
+			}
+			if cfg.OutputFormat == formatNDJSON {
+				if err := emitJSON(run.Result); err != nil {
+					slog.Error("Failed to write result", "target", target, "error", err)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	for _, r := range results {
+		setup.Outcomes.add(r)
+	}
+
+	if cfg.EmitSitemap != "" {
+		var entries []*sitemapEntry
+		for _, r := range results {
+			if r.Err == nil {
+				entries = append(entries, r.Sitemap)
+			}
+		}
+		if err := writeSitemap(ctx, artifacts, cfg.EmitSitemap, entries); err != nil {
+			return err
+		}
+	}
+	if cfg.VisualSitemap != "" {
+		pages := make([]*visualPage, len(results))
+		for i, r := range results {
+			pages[i] = r.Visual
+		}
+		if err := writeVisualSitemap(ctx, artifacts, cfg.VisualSitemap, pages); err != nil {
+			return err
+		}
+	}
+
+	if structuredOutput() {
+		return emitBatchResults(results)
+	}
+	return printBatchSummary(results)
+}
+
+// emitBatchResults writes the JSON array of results (ndjson results were
+// already streamed) and returns an error when at least one target failed.
+func emitBatchResults(results []batchResult) error {
+	if cfg.DetectDuplicates {
+		for _, pair := range nearDuplicates(results) {
+			a, b := results[pair.a], results[pair.b]
+			a.Result.NearDuplicates = append(a.Result.NearDuplicates, b.Target)
+			b.Result.NearDuplicates = append(b.Result.NearDuplicates, a.Target)
+		}
+	}
+
+	all := make([]*chromedphelper.Result, len(results))
+	for i, r := range results {
+		all[i] = r.Result
+	}
+	if cfg.OutputFormat == formatJSON {
+		if err := emitJSON(all); err != nil {
+			return err
+		}
+	}
+
+	return batchError(results)
+}
+
+// runBatchTarget runs the action pipeline for one target in its own tab.
+// The returned Run is never nil and carries what the actions recorded,
+// even on failure.
+func runBatchTarget(ctx context.Context, pool *lazyPool, target batchTarget, setup *pageSetup, prefix string, artifacts, text sink.Sink) (*Run, error) {
+	run := &Run{
+		Config:    &cfg,
+		Artifacts: artifacts,
+		Text:      text,
+		Prefix:    prefix,
+		Batch:     true,
+		Result:    &chromedphelper.Result{Target: target.URL, Locale: target.Locale, Consent: target.Consent},
+	}
+
+	// Every target gets fresh action instances so results never mix
+	pipeline, err := buildPipeline(&cfg, cfg.Order)
+	if err != nil {
+		return run, err
+	}
+
+	if err := setup.Circuits.next(); err != nil {
+		return run, err
+	}
+
+	var proxy *proxyEntry
+	var tabOpts []chromedphelper.TabOption
+	if setup.Proxies != nil {
+		if proxy, err = setup.Proxies.pick(target.URL); err != nil {
+			return run, err
+		}
+		slog.Debug("Using proxy", "target", target.URL, "proxy", proxy.Server)
+		tabOpts = append(tabOpts, chromedphelper.WithTabProxy(proxy.Server))
+		run.Result.Proxy = proxy.Server
+	}
+
+	browser, err := pool.get()
+	if err != nil {
+		return run, err
+	}
+	tab, err := browser.Acquire(ctx, target.URL, tabOpts...)
+	if err != nil {
+		return run, fmt.Errorf("failed to open tab: %w", err)
+	}
+	defer browser.Release(tab)
+	setup.applyTarget(tab, target)
+	visit := setup.Sites.applyTarget(tab, target)
+	if proxy != nil {
+		tab.ProxyAuth = proxy.Auth
+	}
+	run.Browser = tab
+	run.Result.Profile = tab.FingerprintProfile
+
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(cfg.Timeout)*time.Second)
+	defer cancel()
+
+	recordDevToolsURL(ctx, run)
+	err = runPipeline(ctx, run, pipeline)
+	setup.Sites.learn(ctx, visit, tab, err)
+	if proxy != nil && proxyFailed(err) {
+		setup.Proxies.blacklist(proxy, err)
+	}
+	return run, err
+}
+
+// lazyPool starts the browser of a batch when the first target needs it,
+// so batches --no-browser and --auto handle without rendering start none.
+type lazyPool struct {
+	open func() (*chromedphelper.Pool, error)
+
+	once sync.Once
+	pool *chromedphelper.Pool
+	err  error
+}
+
+func (p *lazyPool) get() (*chromedphelper.Pool, error) {
+	p.once.Do(func() { p.pool, p.err = p.open() })
+	return p.pool, p.err
+}
+
+// Close closes the browser if it was started.
+func (p *lazyPool) Close() {
+	if p.pool != nil {
+		p.pool.Close()
+	}
+}
+
+// printBatchSummary reports every target's outcome on stdout and returns
+// an error when at least one target failed.
+func printBatchSummary(results []batchResult) error {
+	failed := 0
+	fmt.Println("\nBatch summary:")
+	for _, r := range sortedResults(results, cfg.SortSummary) {
+		status := "OK"
+		if r.Err != nil {
+			status = "FAILED"
+			failed++
+		}
+		fmt.Printf("  %-6s %s (%s)\n", status, displayURL(r.Target), r.Duration.Round(time.Millisecond))
+		if r.Result.Proxy != "" {
+			fmt.Printf("         proxy: %s\n", r.Result.Proxy)
+		}
+		if r.Result.Static {
+			fmt.Printf("         path: static HTML, without a browser\n")
+		} else if r.Result.Escalated != "" {
+			fmt.Printf("         path: browser, since %s\n", r.Result.Escalated)
+		}
+		if r.Result.Summary != nil {
+			fmt.Printf("         summary: %s\n", formatSummary(r.Result.Summary))
+		}
+		if len(r.Result.JSONPath) > 0 {
+			fmt.Printf("         jsonpath: %s\n", formatJSONPath(r.Result.JSONPath))
+		}
+		if r.Result.Extraction != nil {
+			fmt.Printf("         preset: %s\n", formatExtraction(r.Result.Extraction))
+		}
+		if r.Result.AboveFold != nil {
+			fmt.Printf("         above-fold: %s\n", formatAboveFold(r.Result.AboveFold))
+		}
+		if r.Result.ContentMap != nil {
+			fmt.Printf("         content: %s\n", formatContentMap(r.Result.ContentMap))
+		}
+		if r.Result.Structure != nil {
+			fmt.Printf("         landmarks: %s\n", formatStructure(r.Result.Structure))
+		}
+		if r.Result.Redactions != nil {
+			fmt.Printf("         redacted: %s\n", formatRedactions(r.Result.Redactions))
+		}
+		if len(r.Result.AccessibleNames) > 0 {
+			fmt.Printf("         accessible names: %s\n", formatAccessibleNames(r.Result.AccessibleNames))
+		}
+		if r.Result.Contrast != nil {
+			fmt.Printf("         contrast: %s\n", formatContrast(r.Result.Contrast))
+		}
+		if r.Result.Technologies != nil {
+			fmt.Printf("         tech: %s\n", formatTechnologies(r.Result.Technologies))
+		}
+		if len(r.Result.Checks) > 0 {
+			fmt.Printf("         checks: %s\n", formatChecks(r.Result.Checks))
+		}
+		if len(r.Result.Headers) > 0 {
+			fmt.Printf("         headers: %s\n", formatHeaderChecks(r.Result.Headers))
+		}
+		if r.Result.Soft404 != nil {
+			fmt.Printf("         soft-404: %s\n", formatSoft404(r.Result.Soft404))
+		}
+		if r.Result.Overlays != nil {
+			fmt.Printf("         overlays: %s\n", formatOverlays(r.Result.Overlays))
+		}
+		if r.Result.DesignDiff != nil {
+			fmt.Printf("         design: %s\n", formatDesignDiff(r.Result.DesignDiff))
+		}
+		if r.Result.Baseline != nil {
+			fmt.Printf("         baseline: %s\n", formatBaselineCheck(r.Result.Baseline))
+		}
+		if len(r.Result.FailIf) > 0 {
+			fmt.Printf("         fail-if: %s\n", formatFailIf(r.Result.FailIf))
+		}
+		if r.Result.Keyboard != nil {
+			fmt.Printf("         keyboard: %s\n", formatKeyboardAudit(r.Result.Keyboard))
+		}
+		if r.Result.Crash != nil {
+			fmt.Printf("         crash: %s\n", formatCrash(r.Result.Crash))
+		}
+		if r.Result.Limit != nil {
+			fmt.Printf("         limit: %s\n", formatLimit(r.Result.Limit))
+		}
+		if len(r.Result.Redirects) > 0 {
+			fmt.Printf("         redirects: %s\n", formatRedirects(r.Result.Redirects))
+		}
+		for _, m := range r.Result.MissingFiles {
+			fmt.Printf("         missing: %s\n", formatMissingFile(m))
+		}
+		if r.Result.Replay != nil {
+			fmt.Printf("         replay: %s\n", formatReplay(r.Result.Replay))
+		}
+		if r.Result.Errors != nil {
+			fmt.Printf("         errors: %s\n", formatErrorCounts(r.Result.Errors))
+		}
+		if r.Err != nil {
+			fmt.Printf("         %v\n", r.Err)
+		}
+	}
+	fmt.Printf("%d succeeded, %d failed, %d total\n", len(results)-failed, failed, len(results))
+
+	if cfg.DetectDuplicates {
+		printDuplicateReport(results)
+	}
+
+	return batchError(results)
+}
+
+// batchError returns nil when every target succeeded. Otherwise its exit
+// code is the one shared by all failures, or exitFailure when they differ;
+// with --continue-on-error it is exitPartial when some targets, or some
+// actions of a failed target, succeeded.
+func batchError(results []batchResult) error {
+	failed, code, partial := 0, 0, false
+	for _, r := range results {
+		if r.Err == nil {
+			partial = true
+			continue
+		}
+		failed++
+		c := exitCode(r.Err)
+		if c == exitPartial {
+			partial = true
+		}
+		if code == 0 {
+			code = c
+		} else if c != code {
+			code = exitFailure
+		}
+	}
+	if failed == 0 {
+		return nil
+	}
+	if cfg.ContinueOnError && partial {
+		code = exitPartial
+	}
+	return &exitError{code: code, err: fmt.Errorf("%d of %d targets failed", failed, len(results))}
+}
+
+// filterTargets resolves inputs to URLs, expands them by --param-matrix,
+// --locales and --consent-states, and drops the targets excluded by filter,
+// --allow-hosts, --untrusted and --idn-policy.
+func filterTargets(cfg *Config, inputs []string, filter *urlfilter.Filter) ([]batchTarget, error) {
+	paramAxes, err := parseParamMatrix(cfg.ParamMatrix)
+	if err != nil {
+		slog.Error("Invalid parameter matrix", "error", err)
+		return nil, err
+	}
+
+	var urls []string
+	for _, input := range inputs {
+		target, err := resolveTarget(input)
+		if err != nil {
+			return nil, err
+		}
+		urls = append(urls, target)
+	}
+	if urls, err = expandParamMatrix(urls, paramAxes); err != nil {
+		slog.Error("Invalid target for parameter matrix", "error", err)
+		return nil, err
+	}
+	var targets []batchTarget
+	for _, target := range expandTargets(urls, cfg.Locales, cfg.ConsentStates) {
+		if !filter.Allowed(target.URL) {
+			slog.Warn("Skipping target excluded by --allow/--deny", "target", target.URL)
+			continue
+		}
+		if !allowedHosts.Allowed(target.URL) {
+			slog.Warn("Skipping target whose host is not in --allow-hosts", "target", target.URL)
+			continue
+		}
+		if cfg.Untrusted && !untrustedTarget(target.URL) {
+			slog.Warn("Skipping target --untrusted may not load, only local files and --allow-hosts", "target", target.URL)
+			continue
+		}
+		if !idnTargetAllowed(target.URL) {
+			continue
+		}
+		targets = append(targets, target)
+	}
+	if len(targets) == 0 {
+		slog.Error("All targets excluded by --allow/--deny/--allow-hosts/--untrusted/--idn-policy")
+		return nil, fmt.Errorf("no target left after applying --allow, --deny, --allow-hosts, --untrusted and --idn-policy")
+	}
+	return targets, nil
+}
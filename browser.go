@@ -0,0 +1,267 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/pesarkhobeee/that-cli-web-toolbox/pkg/cache"
+	chromedphelper "github.com/pesarkhobeee/that-cli-web-toolbox/pkg/chromedp"
+	"github.com/pesarkhobeee/that-cli-web-toolbox/pkg/chromefortesting"
+)
+
+// pinnedBrowserVersion is the Chrome for Testing build "browser install"
+// installs without a version: the one this release is tested with, and
+// the last one with --headless-mode old.
+const pinnedBrowserVersion = "131.0.6778.85"
+
+type browserConfig struct {
+	HeadlessShell bool
+	Format        string
+	Timeout       int
+}
+
+var browserCfg browserConfig
+
+var browserCmd = &cobra.Command{
+	Use:   "browser",
+	Short: "Install and manage the pinned Chrome for Testing builds the tool runs",
+	Long: `Download Chrome for Testing, the Chrome flavor published for automation
+with every release, into a directory of the tool, so captures render the
+same on every machine instead of with whatever Chrome is installed there.
+
+The build installed last is used by default to start Chrome, by the root
+command and every subcommand; --system-chrome uses the installed Chrome
+instead. Builds are kept in the cache directory, see "cache info"
+(~/.cache/that-cli-web-toolbox/browsers on Linux).
+
+install takes a version, or a channel (stable, beta, dev or canary) to
+install its current version; without one, it installs the version this
+release is pinned to. With --headless-shell, it installs
+chrome-headless-shell instead, which --headless-mode shell then starts.`,
+	Example: `  # Install the pinned Chrome and capture with it
+  that-cli-web-toolbox browser install
+  that-cli-web-toolbox --target https://example.com --screenshot
+
+  # Try the upcoming release, then go back to the pinned one
+  that-cli-web-toolbox browser install beta
+  that-cli-web-toolbox browser use ` + pinnedBrowserVersion + `
+
+  # chrome-headless-shell for --headless-mode shell
+  that-cli-web-toolbox browser install --headless-shell`,
+}
+
+var browserInstallCmd = &cobra.Command{
+	Use:   "install [VERSION|CHANNEL]",
+	Short: "Download a Chrome for Testing build and use it by default",
+	RunE:  runBrowserInstall,
+	Args:  cobra.MaximumNArgs(1),
+}
+
+var browserListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the installed builds",
+	RunE:  runBrowserList,
+	Args:  cobra.NoArgs,
+}
+
+var browserUseCmd = &cobra.Command{
+	Use:   "use VERSION",
+	Short: "Use an installed build by default",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		setupLogging(cfg.LogLevel)
+		product := browserProduct()
+		if err := browserStore().Use(product, args[0], chromefortesting.CurrentPlatform()); err != nil {
+			return err
+		}
+		fmt.Printf("Using %s %s\n", product, args[0])
+		return nil
+	},
+	Args: cobra.ExactArgs(1),
+}
+
+var browserRemoveCmd = &cobra.Command{
+	Use:   "remove VERSION",
+	Short: "Delete an installed build",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		setupLogging(cfg.LogLevel)
+		product := browserProduct()
+		if err := browserStore().Remove(product, args[0], chromefortesting.CurrentPlatform()); err != nil {
+			return err
+		}
+		fmt.Printf("Removed %s %s\n", product, args[0])
+		return nil
+	},
+	Args: cobra.ExactArgs(1),
+}
+
+func init() {
+	for _, cmd := range []*cobra.Command{browserInstallCmd, browserUseCmd, browserRemoveCmd} {
+		cmd.Flags().BoolVar(&browserCfg.HeadlessShell, "headless-shell", false, "Manage chrome-headless-shell instead of Chrome")
+	}
+	browserInstallCmd.Flags().IntVarP(&browserCfg.Timeout, "timeout", "t", 600, "Timeout in seconds for the download")
+	browserListCmd.Flags().StringVar(&browserCfg.Format, "format", "text", "Output format: text or json")
+	browserCmd.AddCommand(browserInstallCmd, browserListCmd, browserUseCmd, browserRemoveCmd)
+	rootCmd.AddCommand(browserCmd)
+}
+
+// browserStore returns the directory of the installed builds.
+func browserStore() chromefortesting.Store {
+	return chromefortesting.Store{Dir: toolCache().Path(cache.Browsers)}
+}
+
+// browserProduct returns the product the browser subcommands manage.
+func browserProduct() string {
+	if browserCfg.HeadlessShell {
+		return chromefortesting.ProductHeadlessShell
+	}
+	return chromefortesting.ProductChrome
+}
+
+func runBrowserInstall(cmd *cobra.Command, args []string) error {
+	setupLogging(cfg.LogLevel)
+	if browserCfg.Timeout < 1 {
+		return fmt.Errorf("--timeout must be at least 1")
+	}
+	platform := chromefortesting.CurrentPlatform()
+	if platform == "" {
+		return fmt.Errorf("no Chrome for Testing builds are published for this platform; install Chrome instead")
+	}
+	ctx, cancel := context.WithTimeout(cmd.Context(), time.Duration(browserCfg.Timeout)*time.Second)
+	defer cancel()
+
+	client := chromefortesting.New()
+	version := pinnedBrowserVersion
+	if len(args) > 0 {
+		var err error
+		if version, err = client.Resolve(ctx, args[0]); err != nil {
+			return err
+		}
+	}
+	product := browserProduct()
+	store := browserStore()
+	// An installed build is only made the default again
+	if err := store.Use(product, version, platform); err == nil {
+		fmt.Printf("%s %s is already installed, using it\n", product, version)
+		return nil
+	}
+
+	slog.Info("Installing Chrome for Testing", "product", product, "version", version, "platform", platform, "dir", store.Dir)
+	inst, err := store.Install(ctx, client, product, version, platform)
+	if err != nil {
+		slog.Error("Failed to install Chrome for Testing", "product", product, "version", version, "error", err)
+		return fmt.Errorf("failed to install %s %s: %w", product, version, err)
+	}
+	fmt.Printf("Installed %s %s as %s\n", inst.Product, inst.Version, inst.Executable)
+	return nil
+}
+
+func runBrowserList(cmd *cobra.Command, args []string) error {
+	setupLogging(cfg.LogLevel)
+	if browserCfg.Format != "text" && browserCfg.Format != "json" {
+		return fmt.Errorf("unsupported --format %q (expected text or json)", browserCfg.Format)
+	}
+	list, err := browserStore().List()
+	if err != nil {
+		return err
+	}
+	if browserCfg.Format == "json" {
+		if list == nil {
+			list = []chromefortesting.Installation{}
+		}
+		return emitJSON(list)
+	}
+	if len(list) == 0 {
+		fmt.Println("No browsers installed, see: that-cli-web-toolbox browser install")
+		return nil
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "PRODUCT\tVERSION\tPLATFORM\tCURRENT\tEXECUTABLE")
+	for _, inst := range list {
+		current := ""
+		if inst.Current {
+			current = "*"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", inst.Product, inst.Version, inst.Platform, current, inst.Executable)
+	}
+	return w.Flush()
+}
+
+// managedBrowser returns the executable of the current build installed by
+// "browser install" for headlessMode, chrome-headless-shell for
+// --headless-mode shell, unless --system-chrome is set.
+func managedBrowser(headlessMode string) (string, bool) {
+	if cfg.SystemChrome {
+		return "", false
+	}
+	product := chromefortesting.ProductChrome
+	if headlessMode == chromedphelper.HeadlessShell {
+		product = chromefortesting.ProductHeadlessShell
+	}
+	return browserStore().Current(product)
+}
+
+// managedBrowserOptions returns the options starting Chrome with what the
+// tool manages: its profile in the cache directory, after removing those
+// of killed processes, and the build of managedBrowser, if one is
+// installed, leased so "cache clean" keeps it while the process runs.
+func managedBrowserOptions(headlessMode string) []chromedphelper.LaunchOption {
+	pruneProfiles()
+	opts := []chromedphelper.LaunchOption{chromedphelper.WithProfileDir(toolCache().Path(cache.Profiles))}
+	if exe, ok := managedBrowser(headlessMode); ok {
+		slog.Debug("Using installed Chrome for Testing", "executable", exe)
+		useBrowser(exe)
+		opts = append(opts, chromedphelper.WithExecPath(exe))
+	}
+	return opts
+}
+
+// leasedBrowsers holds the executables the process leased, once each.
+var leasedBrowsers sync.Map
+
+// useBrowser leases the build of exe for the rest of the process.
+func useBrowser(exe string) {
+	if _, leased := leasedBrowsers.LoadOrStore(exe, true); leased {
+		return
+	}
+	if err := toolCache().UseBrowser(exe); err != nil {
+		slog.Warn("Failed to mark Chrome for Testing as in use", "executable", exe, "error", err)
+	}
+}
+
+// validateHeadlessMode checks --headless-mode, which is chosen when Chrome
+// starts.
+func validateHeadlessMode(cfg *Config) error {
+	if cfg.HeadlessMode == "" {
+		return nil
+	}
+	if !contains(chromedphelper.HeadlessModes, cfg.HeadlessMode) {
+		slog.Error("Invalid headless mode", "mode", cfg.HeadlessMode)
+		return fmt.Errorf("invalid --headless-mode %q (expected one of %s)", cfg.HeadlessMode, strings.Join(chromedphelper.HeadlessModes, ", "))
+	}
+	if cfg.Headful {
+		slog.Error("--headless-mode specified with --headful")
+		return fmt.Errorf("--headless-mode and --headful are mutually exclusive, use only one")
+	}
+	if cfg.RemoteDebuggingPort != "" {
+		slog.Error("--headless-mode specified with --remote-debugging-port")
+		return fmt.Errorf("--headless-mode cannot be used with --remote-debugging-port; start that Chrome in the mode instead")
+	}
+	if cfg.HeadlessMode == chromedphelper.HeadlessShell {
+		if _, ok := managedBrowser(cfg.HeadlessMode); !ok {
+			if _, err := exec.LookPath(chromedphelper.HeadlessShellExecutable); err != nil {
+				slog.Error("chrome-headless-shell not found", "error", err)
+				return fmt.Errorf("--headless-mode shell requires %s on the PATH, or installed with \"browser install --headless-shell\": %w", chromedphelper.HeadlessShellExecutable, err)
+			}
+		}
+	}
+	return nil
+}
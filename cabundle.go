@@ -0,0 +1,66 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"time"
+)
+
+// caCerts holds the certificates of --ca-bundle, trusted by Chrome and by
+// the tool's own HTTP requests; nil without the flag.
+var caCerts []*x509.Certificate
+
+// loadCABundle reads the PEM certificates in path.
+func loadCABundle(path string) ([]*x509.Certificate, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CA bundle %q: %w", path, err)
+	}
+	var certs []*x509.Certificate
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("invalid certificate in CA bundle %q: %w", path, err)
+		}
+		certs = append(certs, cert)
+	}
+	if len(certs) == 0 {
+		return nil, fmt.Errorf("CA bundle %q contains no PEM certificates", path)
+	}
+	return certs, nil
+}
+
+// newHTTPClient returns a client for requests the tool makes itself, such
+// as sink uploads and source map downloads, trusting the system roots and
+// --ca-bundle.
+func newHTTPClient(timeout time.Duration) *http.Client {
+	client := &http.Client{Timeout: timeout}
+	if len(caCerts) == 0 {
+		return client
+	}
+	roots, err := x509.SystemCertPool()
+	if err != nil {
+		slog.Debug("System certificate pool unavailable, trusting only the CA bundle", "error", err)
+		roots = x509.NewCertPool()
+	}
+	for _, cert := range caCerts {
+		roots.AddCert(cert)
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = &tls.Config{RootCAs: roots}
+	client.Transport = transport
+	return client
+}
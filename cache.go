@@ -0,0 +1,164 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+
+	"github.com/pesarkhobeee/that-cli-web-toolbox/pkg/cache"
+)
+
+type cacheConfig struct {
+	Format string
+	All    bool
+}
+
+var cacheCfg cacheConfig
+
+var cacheCmd = &cobra.Command{
+	Use:   "cache",
+	Short: "Show and clean the tool's cache directory",
+	Long: `Show and clean the cache directory, where the tool keeps what it
+downloads or creates for itself instead of the temporary directory:
+
+  browsers   Chrome for Testing builds of "browser install"
+  captures   results of handle-url without --output-dir
+  profiles   the temporary profiles of the Chromes the tool starts
+
+The directory is that-cli-web-toolbox in $XDG_CACHE_HOME, or else in the
+user's cache directory (~/.cache on Linux, ~/Library/Caches on macOS,
+%LocalAppData% on Windows); --cache-dir moves it, e.g. to a larger disk.
+
+A profile lives as long as its Chrome and is named after the process that
+started it. Profiles left behind by killed processes are removed the next
+time Chrome is started; clean removes them at once. Profiles of running
+processes are never removed, and neither are the browsers they started,
+so clean is safe while other invocations, serve or daemon run.`,
+	Example: `  # Where the cache is and what it holds
+  that-cli-web-toolbox cache info
+
+  # Remove the profiles of killed processes
+  that-cli-web-toolbox cache clean
+
+  # Remove the installed browsers too
+  that-cli-web-toolbox cache clean browsers profiles
+
+  # Remove everything not in use
+  that-cli-web-toolbox cache clean --all`,
+}
+
+var cacheInfoCmd = &cobra.Command{
+	Use:   "info",
+	Short: "Show the cache directory and the disk space of its content",
+	RunE:  runCacheInfo,
+	Args:  cobra.NoArgs,
+}
+
+var cacheCleanCmd = &cobra.Command{
+	Use:   "clean [KIND...]",
+	Short: "Remove content of the cache not in use (default: stale profiles)",
+	RunE:  runCacheClean,
+}
+
+func init() {
+	cacheInfoCmd.Flags().StringVar(&cacheCfg.Format, "format", "text", "Output format: text or json")
+	cacheCleanCmd.Flags().BoolVar(&cacheCfg.All, "all", false, "Remove every kind: "+strings.Join(cache.Kinds, ", "))
+	cacheCmd.AddCommand(cacheInfoCmd, cacheCleanCmd)
+	rootCmd.AddCommand(cacheCmd)
+}
+
+// toolCache returns the cache directory, --cache-dir or the default.
+func toolCache() cache.Cache {
+	if cfg.CacheDir != "" {
+		if dir, err := filepath.Abs(cfg.CacheDir); err == nil {
+			return cache.Cache{Dir: dir}
+		}
+		return cache.Cache{Dir: cfg.CacheDir}
+	}
+	return cache.Default()
+}
+
+// pruneProfilesOnce removes the profiles of killed processes before the
+// first Chrome of the process starts.
+var pruneProfilesOnce sync.Once
+
+func pruneProfiles() {
+	pruneProfilesOnce.Do(func() {
+		cleaned, err := toolCache().Clean([]string{cache.Profiles})
+		if err != nil {
+			slog.Warn("Failed to remove stale Chrome profiles", "error", err)
+			return
+		}
+		if cleaned.Entries > 0 {
+			slog.Info("Removed stale Chrome profiles", "profiles", cleaned.Entries, "bytes", cleaned.Bytes)
+		}
+	})
+}
+
+func runCacheInfo(cmd *cobra.Command, args []string) error {
+	setupLogging(cfg.LogLevel)
+	if cacheCfg.Format != "text" && cacheCfg.Format != "json" {
+		return fmt.Errorf("unsupported --format %q (expected text or json)", cacheCfg.Format)
+	}
+	c := toolCache()
+	usage, err := c.Usage()
+	if err != nil {
+		return fmt.Errorf("failed to read cache %s: %w", c.Dir, err)
+	}
+	if cacheCfg.Format == "json" {
+		var total int64
+		for _, u := range usage {
+			total += u.Bytes
+		}
+		return emitJSON(struct {
+			Dir   string        `json:"dir"`
+			Bytes int64         `json:"bytes"`
+			Kinds []cache.Usage `json:"kinds"`
+		}{c.Dir, total, usage})
+	}
+	fmt.Printf("Cache: %s\n", c.Dir)
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "KIND\tENTRIES\tSIZE\tSTALE")
+	var total int64
+	for _, u := range usage {
+		stale := "-"
+		if u.Kind == cache.Profiles {
+			stale = fmt.Sprint(u.Stale)
+		}
+		fmt.Fprintf(w, "%s\t%d\t%s\t%s\n", u.Kind, u.Entries, formatByteSize(u.Bytes), stale)
+		total += u.Bytes
+	}
+	fmt.Fprintf(w, "total\t\t%s\t\n", formatByteSize(total))
+	return w.Flush()
+}
+
+func runCacheClean(cmd *cobra.Command, args []string) error {
+	setupLogging(cfg.LogLevel)
+	kinds := args
+	switch {
+	case cacheCfg.All && len(args) > 0:
+		return fmt.Errorf("name the kinds to clean or use --all, not both")
+	case cacheCfg.All:
+		kinds = cache.Kinds
+	case len(kinds) == 0:
+		kinds = []string{cache.Profiles}
+	}
+	c := toolCache()
+	cleaned, err := c.Clean(kinds)
+	if err != nil {
+		return fmt.Errorf("failed to clean cache %s: %w", c.Dir, err)
+	}
+	slog.Debug("Cache cleaned", "dir", c.Dir, "kinds", kinds, "entries", cleaned.Entries, "bytes", cleaned.Bytes, "inUse", cleaned.InUse)
+	fmt.Printf("Removed %d entries, %s", cleaned.Entries, formatByteSize(cleaned.Bytes))
+	if cleaned.InUse > 0 {
+		fmt.Printf(", kept %d in use", cleaned.InUse)
+	}
+	fmt.Println()
+	return nil
+}
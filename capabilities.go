@@ -0,0 +1,175 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+
+	chromedphelper "github.com/pesarkhobeee/that-cli-web-toolbox/pkg/chromedp"
+	"github.com/pesarkhobeee/that-cli-web-toolbox/pkg/sink"
+)
+
+// Output formats accepted by capabilities --format.
+const (
+	capabilitiesFormatText = "text"
+	capabilitiesFormatJSON = "json"
+)
+
+type capabilitiesConfig struct {
+	Format    string
+	Timeout   int
+	NoBrowser bool
+}
+
+var capabilitiesCfg capabilitiesConfig
+
+var capabilitiesCmd = &cobra.Command{
+	Use:   "capabilities",
+	Short: "List the actions, output formats, flags and Chrome version this binary supports",
+	Long: `Describe what this installation of the tool can do, so orchestration
+systems can check a binary before dispatching jobs to it: its version, the
+subcommands, the actions of the pipeline (in default order), the output
+formats and sinks, the device presets, the ready strategies, every flag of the root command with
+its type and default, and the version of Chrome.
+
+Chrome is started (or, with --remote-debugging-port, contacted) to read its
+version. When that fails, the reason is reported instead of the version and
+the command still succeeds; --no-browser skips it.`,
+	Example: `  # What can this binary do, as JSON
+  that-cli-web-toolbox capabilities --format json
+
+  # Without starting Chrome
+  that-cli-web-toolbox capabilities --no-browser`,
+	RunE: runCapabilities,
+	Args: cobra.NoArgs,
+}
+
+func init() {
+	capabilitiesCmd.Flags().StringVar(&capabilitiesCfg.Format, "format", capabilitiesFormatText, "Output format: text or json")
+	capabilitiesCmd.Flags().IntVarP(&capabilitiesCfg.Timeout, "timeout", "t", 10, "Timeout in seconds for reading Chrome's version")
+	capabilitiesCmd.Flags().BoolVar(&capabilitiesCfg.NoBrowser, "no-browser", false, "Do not start Chrome to read its version")
+	rootCmd.AddCommand(capabilitiesCmd)
+}
+
+// capabilities is the outcome of the capabilities command.
+type capabilities struct {
+	Version  string `json:"version"`
+	Go       string `json:"go"`
+	Platform string `json:"platform"`
+	// Chrome is nil when its version could not be read, see ChromeError.
+	Chrome          *chromedphelper.BrowserVersion `json:"chrome,omitempty"`
+	ChromeError     string                         `json:"chromeError,omitempty"`
+	Commands        []string                       `json:"commands"`
+	Actions         []string                       `json:"actions"`
+	OutputFormats   []string                       `json:"outputFormats"`
+	Sinks           []string                       `json:"sinks"`
+	Devices         []string                       `json:"devices"`
+	ReadyStrategies []string                       `json:"readyStrategies"`
+	Flags           []capabilityFlag               `json:"flags"`
+}
+
+// capabilityFlag describes a flag of the root command.
+type capabilityFlag struct {
+	Name      string `json:"name"`
+	Shorthand string `json:"shorthand,omitempty"`
+	Type      string `json:"type"`
+	Default   string `json:"default,omitempty"`
+	Usage     string `json:"usage"`
+}
+
+func runCapabilities(cmd *cobra.Command, args []string) error {
+	setupLogging(cfg.LogLevel)
+
+	if capabilitiesCfg.Format != capabilitiesFormatText && capabilitiesCfg.Format != capabilitiesFormatJSON {
+		return fmt.Errorf("unsupported --format %q (expected text or json)", capabilitiesCfg.Format)
+	}
+	if capabilitiesCfg.Timeout < 1 {
+		return fmt.Errorf("--timeout must be at least 1")
+	}
+
+	c := &capabilities{
+		Version:         version,
+		Go:              runtime.Version(),
+		Platform:        runtime.GOOS + "/" + runtime.GOARCH,
+		Actions:         actionNames(),
+		OutputFormats:   outputFormats,
+		Sinks:           sink.Kinds,
+		Devices:         chromedphelper.Devices(),
+		ReadyStrategies: chromedphelper.ReadinessStrategies(),
+	}
+	for _, sub := range rootCmd.Commands() {
+		if sub.IsAvailableCommand() {
+			c.Commands = append(c.Commands, sub.Name())
+		}
+	}
+	rootCmd.LocalFlags().VisitAll(func(f *pflag.Flag) {
+		if f.Hidden {
+			return
+		}
+		c.Flags = append(c.Flags, capabilityFlag{
+			Name:      f.Name,
+			Shorthand: f.Shorthand,
+			Type:      f.Value.Type(),
+			Default:   f.DefValue,
+			Usage:     f.Usage,
+		})
+	})
+
+	if !capabilitiesCfg.NoBrowser {
+		v, err := chromeVersion(cmd.Context(), "", capabilitiesCfg.Timeout)
+		if err != nil {
+			slog.Warn("Could not read Chrome's version", "error", err)
+			c.ChromeError = err.Error()
+		}
+		c.Chrome = v
+	}
+
+	if capabilitiesCfg.Format == capabilitiesFormatJSON {
+		return emitJSON(c)
+	}
+	fmt.Print(formatCapabilities(c))
+	return nil
+}
+
+// chromeVersion starts Chrome, the build of managedBrowser for
+// headlessMode, or connects to the one of --remote-debugging-port, and
+// returns its version.
+func chromeVersion(ctx context.Context, headlessMode string, timeout int) (*chromedphelper.BrowserVersion, error) {
+	b, err := chromedphelper.InitializeChromedpContext(ctx, "", timeout, 0, cfg.RemoteDebuggingPort, "", managedBrowserOptions(headlessMode)...)
+	if err != nil {
+		return nil, err
+	}
+	defer b.Cancel()
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(timeout)*time.Second)
+	defer cancel()
+	return b.Version(ctx)
+}
+
+// formatCapabilities renders c for reading.
+func formatCapabilities(c *capabilities) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Version: %s (%s, %s)\n", c.Version, c.Go, c.Platform)
+	switch {
+	case c.Chrome != nil:
+		fmt.Fprintf(&sb, "Chrome: %s (protocol %s)\n", c.Chrome.Product, c.Chrome.ProtocolVersion)
+	case c.ChromeError != "":
+		fmt.Fprintf(&sb, "Chrome: unavailable: %s\n", c.ChromeError)
+	}
+	fmt.Fprintf(&sb, "Commands: %s\n", strings.Join(c.Commands, ", "))
+	fmt.Fprintf(&sb, "Actions: %s\n", strings.Join(c.Actions, ", "))
+	fmt.Fprintf(&sb, "Output formats: %s\n", strings.Join(c.OutputFormats, ", "))
+	fmt.Fprintf(&sb, "Sinks: %s\n", strings.Join(c.Sinks, ", "))
+	fmt.Fprintf(&sb, "Devices: %s\n", strings.Join(c.Devices, ", "))
+	fmt.Fprintf(&sb, "Ready strategies: %s\n", strings.Join(c.ReadyStrategies, ", "))
+	fmt.Fprintf(&sb, "Flags (%d):\n", len(c.Flags))
+	for _, f := range c.Flags {
+		fmt.Fprintf(&sb, "  --%s %s\n", f.Name, f.Type)
+	}
+	return sb.String()
+}
@@ -0,0 +1,206 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// pageVersion is what --changed-only keeps about a target to tell whether
+// it changed: the validators and a hash of its HTTP response.
+type pageVersion struct {
+	ETag         string `json:"etag,omitempty"`
+	LastModified string `json:"lastModified,omitempty"`
+	// SHA256 is the hash of the response body, for servers without
+	// validators or that ignore conditional requests.
+	SHA256     string    `json:"sha256,omitempty"`
+	CapturedAt time.Time `json:"capturedAt"`
+}
+
+// changeStore holds the page versions of --changed-only by target, and
+// writes those of the targets captured by the run back to its file with
+// save.
+type changeStore struct {
+	path string
+
+	mu    sync.Mutex
+	pages map[string]*pageVersion
+	// seen are the versions found by filter, kept once their target is
+	// captured.
+	seen  map[string]*pageVersion
+	dirty bool
+}
+
+// loadChangeStore reads the store file at path. A missing file is an
+// empty store, as in the first run, which save creates.
+func loadChangeStore(path string) (*changeStore, error) {
+	s := &changeStore{path: path, pages: make(map[string]*pageVersion), seen: make(map[string]*pageVersion)}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		slog.Debug("Changed-only state file does not exist yet", "file", path)
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &s.pages); err != nil {
+		return nil, fmt.Errorf("invalid changed-only state file %s: %w", path, err)
+	}
+	for target, page := range s.pages {
+		if page == nil {
+			return nil, fmt.Errorf("invalid changed-only state file %s: %q has no version", path, target)
+		}
+	}
+	slog.Debug("Changed-only state loaded", "file", path, "pages", len(s.pages))
+	return s, nil
+}
+
+// filter returns the targets that changed since they were last captured,
+// or were never captured. Targets that are not http(s) URLs, and those
+// that cannot be checked, are kept.
+func (s *changeStore) filter(ctx context.Context, targets []batchTarget, setup *pageSetup) []batchTarget {
+	var changed []batchTarget
+	for _, t := range targets {
+		key := t.String()
+		s.mu.Lock()
+		stored := s.pages[key]
+		s.mu.Unlock()
+		version, modified, err := checkPageVersion(ctx, t, setup, stored)
+		if err != nil {
+			slog.Warn("Failed to check whether target changed, capturing it", "target", key, "error", err)
+			changed = append(changed, t)
+			continue
+		}
+		if version == nil {
+			changed = append(changed, t)
+			continue
+		}
+		if !modified {
+			slog.Info("Target unchanged since the last run, skipping it", "target", key, "capturedAt", stored.CapturedAt)
+			continue
+		}
+		s.mu.Lock()
+		s.seen[key] = version
+		s.mu.Unlock()
+		changed = append(changed, t)
+	}
+	return changed
+}
+
+// checkPageVersion fetches target, conditionally on the validators of
+// stored, and returns its version and whether it differs from stored. The
+// version is nil for targets that are not http(s) URLs.
+func checkPageVersion(ctx context.Context, target batchTarget, setup *pageSetup, stored *pageVersion) (*pageVersion, bool, error) {
+	u, err := url.Parse(target.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return nil, true, nil
+	}
+	req, err := newTargetRequest(ctx, target, setup, u)
+	if err != nil {
+		return nil, false, err
+	}
+	if stored != nil {
+		if stored.ETag != "" {
+			req.Header.Set("If-None-Match", stored.ETag)
+		}
+		if stored.LastModified != "" {
+			req.Header.Set("If-Modified-Since", stored.LastModified)
+		}
+	}
+	slog.Debug("Checking whether target changed", "target", target.URL, "etag", req.Header.Get("If-None-Match"), "lastModified", req.Header.Get("If-Modified-Since"))
+	resp, err := newTargetClient(setup).Do(req)
+	if err != nil {
+		return nil, false, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotModified && stored != nil {
+		slog.Debug("Target not modified", "target", target.URL)
+		return stored, false, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		// Errors are captured, and the version kept, until the page is back
+		return nil, true, nil
+	}
+
+	limit := int64(maxStaticBytes)
+	if setup.MaxBytes > 0 {
+		limit = setup.MaxBytes
+	}
+	hash := sha256.New()
+	if _, err := io.Copy(hash, io.LimitReader(resp.Body, limit)); err != nil {
+		return nil, false, fmt.Errorf("failed to read %q: %w", target.URL, err)
+	}
+	version := &pageVersion{
+		ETag:         resp.Header.Get("ETag"),
+		LastModified: resp.Header.Get("Last-Modified"),
+		SHA256:       fmt.Sprintf("%x", hash.Sum(nil)),
+	}
+	if stored != nil && stored.SHA256 == version.SHA256 {
+		slog.Debug("Target served the same content", "target", target.URL)
+		return stored, false, nil
+	}
+	return version, true, nil
+}
+
+// keep records the versions of the targets the run captured without an
+// error, so the next run skips them until they change again.
+func (s *changeStore) keep(outcomes []batchResult) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now().UTC()
+	for _, r := range outcomes {
+		version := s.seen[r.Target]
+		if r.Err != nil || version == nil {
+			continue
+		}
+		version.CapturedAt = now
+		s.pages[r.Target] = version
+		s.dirty = true
+	}
+}
+
+// save writes the store back to its file if it changed.
+func (s *changeStore) save() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.dirty {
+		return nil
+	}
+
+	data, err := json.MarshalIndent(s.pages, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".*")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := os.Remove(tmp.Name()); err != nil && !errors.Is(err, os.ErrNotExist) {
+			slog.Warn("failed to remove temporary changed-only state file", "file", tmp.Name(), "error", err)
+		}
+	}()
+	if _, err := tmp.Write(append(data, '\n')); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), s.path); err != nil {
+		return err
+	}
+	s.dirty = false
+	slog.Debug("Changed-only state saved", "file", s.path, "pages", len(s.pages))
+	return nil
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"regexp"
+
+	chromedphelper "github.com/pesarkhobeee/that-cli-web-toolbox/pkg/chromedp"
+)
+
+// Exit codes, so monitoring jobs can tell failures apart.
+const (
+	exitFailure    = 1
+	exitNavigation = 2
+	exitAssertion  = 3
+	exitTimeout    = 4
+	exitPathology  = 5
+	exitCrash      = 6
+	exitLimit      = 7
+	exitBrowser    = 8
+	exitSelector   = 9
+	exitPartial    = 10
+)
+
+// exitError is an error that ends the process with a specific exit code.
+type exitError struct {
+	code int
+	err  error
+}
+
+func (e *exitError) Error() string { return e.err.Error() }
+func (e *exitError) Unwrap() error { return e.err }
+
+// errReported is the error of an exitError whose outcome the command has
+// already written, so main only sets the exit code.
+var errReported = errors.New("outcome already reported")
+
+// exitCode returns the process exit code for err: exitTimeout when a
+// deadline passed, the code of an exitError, exitBrowser or exitSelector
+// for the typed errors of pkg/chromedp, and exitFailure otherwise.
+func exitCode(err error) int {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return exitTimeout
+	}
+	var exit *exitError
+	switch {
+	case errors.As(err, &exit):
+		return exit.code
+	case errors.Is(err, chromedphelper.ErrChromeNotFound), errors.Is(err, chromedphelper.ErrRemoteUnreachable):
+		return exitBrowser
+	case errors.Is(err, chromedphelper.ErrSelectorNotFound):
+		return exitSelector
+	}
+	return exitFailure
+}
+
+// checkAction evaluates --expect-selector, --assert, --expect-text,
+// --expect-status and --max-load-time and fails the page when any of them
+// does not hold.
+type checkAction struct {
+	noopAction
+
+	expectations chromedphelper.Expectations
+}
+
+func (a *checkAction) Name() string { return "check" }
+func (a *checkAction) Enabled(cfg *Config) bool {
+	return len(cfg.ExpectSelectors) > 0 || len(cfg.Assertions) > 0 || cfg.ExpectText != "" || cfg.ExpectStatus != 0 || cfg.MaxLoadTime != 0
+}
+
+func (a *checkAction) Flags() []string {
+	return []string{"--expect-selector", "--assert", "--expect-text", "--expect-status", "--max-load-time"}
+}
+
+func (a *checkAction) Validate(cfg *Config) error {
+	if err := validateSelectors("--expect-selector", cfg.ExpectSelectors); err != nil {
+		return err
+	}
+	var err error
+	if a.expectations, err = parseExpectations(cfg.ExpectSelectors, cfg.Assertions, cfg.ExpectText); err != nil {
+		return err
+	}
+	a.expectations.Status = cfg.ExpectStatus
+	a.expectations.MaxLoadTime = cfg.MaxLoadTime
+	if cfg.ExpectStatus < 0 || cfg.ExpectStatus > 599 {
+		return fmt.Errorf("--expect-status must be an HTTP status code: %d", cfg.ExpectStatus)
+	}
+	if cfg.MaxLoadTime < 0 {
+		return fmt.Errorf("--max-load-time cannot be negative: %s", cfg.MaxLoadTime)
+	}
+	return nil
+}
+
+// parseExpectations builds the expectations of --expect-selector,
+// --assert and --expect-text.
+func parseExpectations(selectors, assertions []string, text string) (chromedphelper.Expectations, error) {
+	exp := chromedphelper.Expectations{Selectors: selectors}
+	for _, s := range assertions {
+		assertion, err := chromedphelper.ParseAssertion(s)
+		if err != nil {
+			return exp, err
+		}
+		exp.Assertions = append(exp.Assertions, assertion)
+	}
+	if text != "" {
+		re, err := regexp.Compile(text)
+		if err != nil {
+			return exp, fmt.Errorf("invalid --expect-text regexp: %w", err)
+		}
+		exp.Text = re
+	}
+	return exp, nil
+}
+
+func (a *checkAction) Execute(ctx context.Context, run *Run) error {
+	checks, err := run.Browser.Check(ctx, a.expectations)
+	if err != nil {
+		slog.Error("Failed to check page", "error", err)
+		return fmt.Errorf("failed to check page: %w", err)
+	}
+	for _, c := range checks {
+		slog.Debug("Check evaluated", "check", c.Name, "expected", c.Expected, "actual", c.Actual, "passed", c.Passed)
+	}
+	run.Result.Checks = checks
+	return nil
+}
+
+func (a *checkAction) Report(ctx context.Context, run *Run) error {
+	checks := run.Result.Checks
+	// Batch runs list the outcome in the summary instead
+	if !run.Batch && !structuredFormat(run.Config.OutputFormat) {
+		fmt.Println("Checks:")
+		for _, c := range checks {
+			status := "PASS"
+			if !c.Passed {
+				status = "FAIL"
+			}
+			fmt.Printf("  %s %s %s (got %s)\n", status, c.Name, c.Expected, c.Actual)
+		}
+	}
+	if failed := chromedphelper.CheckFailures(checks); failed > 0 {
+		return &exitError{code: exitAssertion, err: fmt.Errorf("%d of %d checks failed", failed, len(checks))}
+	}
+	return nil
+}
+
+// formatChecks renders the outcome of checks for the batch summary.
+func formatChecks(checks []chromedphelper.CheckResult) string {
+	failed := chromedphelper.CheckFailures(checks)
+	return fmt.Sprintf("%d passed, %d failed", len(checks)-failed, failed)
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/pflag"
+
+	"github.com/pesarkhobeee/that-cli-web-toolbox/pkg/miniyaml"
+)
+
+// captureConfig is a config file of --config: the flags of a capture by
+// name, and the pages it captures when none are given on the command line.
+//
+//	targets:
+//	  - https://example.com
+//	flags:
+//	  screenshot: true
+//	  viewport: 1280x800
+//	  step: ["click:#accept", "waitvisible:main"]
+type captureConfig struct {
+	Targets []string       `json:"targets"`
+	Flags   map[string]any `json:"flags"`
+}
+
+// loadCaptureConfig reads the config file at path.
+func loadCaptureConfig(path string) (*captureConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var c captureConfig
+	if err := miniyaml.Unmarshal(data, &c); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// apply sets the flags of c on flags, except those set on the command
+// line, which win, and returns the targets to capture: args, or else the
+// config's.
+func (c *captureConfig) apply(flags *pflag.FlagSet, args []string) ([]string, error) {
+	names := make([]string, 0, len(c.Flags))
+	for name := range c.Flags {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		f := flags.Lookup(name)
+		if f == nil || name == "config" {
+			return nil, fmt.Errorf("unknown flag %q", name)
+		}
+		if f.Changed {
+			continue
+		}
+		values, err := configValues(c.Flags[name])
+		if err != nil {
+			return nil, fmt.Errorf("flag %q: %w", name, err)
+		}
+		for _, v := range values {
+			if err := flags.Set(name, v); err != nil {
+				return nil, fmt.Errorf("flag %q: %w", name, err)
+			}
+		}
+	}
+	if len(args) > 0 {
+		return args, nil
+	}
+	return c.Targets, nil
+}
+
+// configValues returns the values to set a flag to: one per item of a
+// list, for repeatable flags.
+func configValues(v any) ([]string, error) {
+	switch v := v.(type) {
+	case nil:
+		return nil, fmt.Errorf("no value")
+	case []any:
+		var values []string
+		for _, item := range v {
+			s, err := configValues(item)
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, s...)
+		}
+		return values, nil
+	case map[string]any:
+		return nil, fmt.Errorf("expected a value or a list, not a mapping")
+	case float64:
+		return []string{strconv.FormatFloat(v, 'f', -1, 64)}, nil
+	default:
+		return []string{fmt.Sprint(v)}, nil
+	}
+}
+
+// encode returns c as a config file, with header as its leading comment.
+func (c *captureConfig) encode(header string) []byte {
+	var sb strings.Builder
+	for _, line := range strings.Split(strings.TrimRight(header, "\n"), "\n") {
+		sb.WriteString(strings.TrimRight("# "+line, " ") + "\n")
+	}
+	if len(c.Targets) > 0 {
+		sb.WriteString("targets:\n")
+		for _, t := range c.Targets {
+			fmt.Fprintf(&sb, "  - %s\n", strconv.Quote(t))
+		}
+	}
+	names := make([]string, 0, len(c.Flags))
+	for name := range c.Flags {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	sb.WriteString("flags:")
+	if len(names) == 0 {
+		sb.WriteString(" {}")
+	}
+	sb.WriteString("\n")
+	for _, name := range names {
+		fmt.Fprintf(&sb, "  %s: %s\n", name, configScalar(c.Flags[name]))
+	}
+	return []byte(sb.String())
+}
+
+// configScalar writes a flag value of a config file: strings quoted, lists
+// as flow sequences.
+func configScalar(v any) string {
+	switch v := v.(type) {
+	case string:
+		return strconv.Quote(v)
+	case []string:
+		quoted := make([]string, len(v))
+		for i, s := range v {
+			quoted[i] = strconv.Quote(s)
+		}
+		return "[" + strings.Join(quoted, ", ") + "]"
+	default:
+		return fmt.Sprint(v)
+	}
+}
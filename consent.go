@@ -0,0 +1,88 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	chromedphelper "github.com/pesarkhobeee/that-cli-web-toolbox/pkg/chromedp"
+)
+
+// consentNone is the consent state that needs no definition: the page as a
+// first-time visitor sees it.
+const consentNone = "none"
+
+// consentName restricts consent states to names that are safe in file names.
+var consentName = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_-]*$`)
+
+// consentState is how one --consent-states entry is reached: cookies set
+// before navigation and steps, such as clicking a banner button, run before
+// any --step.
+type consentState struct {
+	Steps   []chromedphelper.Step
+	Cookies []chromedphelper.Cookie
+}
+
+// parseConsentStates builds the states named in --consent-states from
+// --consent-step and --consent-cookie entries of the form STATE=SPEC.
+// Every state except "none" must be defined by at least one of them.
+func parseConsentStates(names, stepSpecs, cookieSpecs []string) (map[string]*consentState, error) {
+	if len(names) == 0 {
+		if len(stepSpecs) > 0 || len(cookieSpecs) > 0 {
+			return nil, fmt.Errorf("--consent-step and --consent-cookie require --consent-states")
+		}
+		return nil, nil
+	}
+
+	states := make(map[string]*consentState, len(names))
+	for _, name := range names {
+		if !consentName.MatchString(name) {
+			return nil, fmt.Errorf("invalid consent state %q (use letters, digits, - and _)", name)
+		}
+		if _, ok := states[name]; ok {
+			return nil, fmt.Errorf("consent state %q listed twice", name)
+		}
+		states[name] = &consentState{}
+	}
+
+	lookup := func(flag, spec string) (*consentState, string, error) {
+		name, rest, ok := strings.Cut(spec, "=")
+		if !ok || rest == "" {
+			return nil, "", fmt.Errorf("invalid %s %q (expected STATE=...)", flag, spec)
+		}
+		state, ok := states[name]
+		if !ok {
+			return nil, "", fmt.Errorf("%s %q refers to consent state %q, which is not in --consent-states", flag, spec, name)
+		}
+		return state, rest, nil
+	}
+	for _, spec := range stepSpecs {
+		state, rest, err := lookup("--consent-step", spec)
+		if err != nil {
+			return nil, err
+		}
+		step, err := chromedphelper.ParseStep(rest)
+		if err != nil {
+			return nil, err
+		}
+		state.Steps = append(state.Steps, step)
+	}
+	for _, spec := range cookieSpecs {
+		state, rest, err := lookup("--consent-cookie", spec)
+		if err != nil {
+			return nil, err
+		}
+		cookies, err := loadCookies([]string{rest}, "")
+		if err != nil {
+			return nil, err
+		}
+		state.Cookies = append(state.Cookies, cookies...)
+	}
+
+	for name, state := range states {
+		if name != consentNone && len(state.Steps) == 0 && len(state.Cookies) == 0 {
+			return nil, fmt.Errorf("consent state %q has no --consent-step or --consent-cookie", name)
+		}
+	}
+	return states, nil
+}
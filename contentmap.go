@@ -0,0 +1,153 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"log/slog"
+
+	chromedphelper "github.com/pesarkhobeee/that-cli-web-toolbox/pkg/chromedp"
+)
+
+// Layout of the content map image: the page's grid scaled to about
+// contentMapWidth pixels, then a bar of shares per screen.
+const (
+	contentMapWidth = 320
+	contentMapGap   = 8
+	contentMapBar   = 160
+)
+
+// contentColors are the colors of the kinds of content in the image.
+var contentColors = map[rune]color.RGBA{
+	chromedphelper.ContentWhitespace: {0xf3, 0xf4, 0xf6, 0xff},
+	chromedphelper.ContentText:       {0x3b, 0x82, 0xf6, 0xff},
+	chromedphelper.ContentImage:      {0x22, 0xc5, 0x5e, 0xff},
+	chromedphelper.ContentMedia:      {0xa8, 0x55, 0xf7, 0xff},
+	chromedphelper.ContentAd:         {0xef, 0x44, 0x44, 0xff},
+}
+
+// foldColor marks where each screen ends.
+var foldColor = color.RGBA{0x37, 0x41, 0x51, 0xff}
+
+// contentMapAction maps where content falls along the page for
+// --content-map, as a PNG image and JSON.
+type contentMapAction struct{ noopAction }
+
+func (a *contentMapAction) Name() string             { return "content-map" }
+func (a *contentMapAction) Enabled(cfg *Config) bool { return cfg.ContentMap }
+func (a *contentMapAction) Flags() []string          { return []string{"--content-map"} }
+
+func (a *contentMapAction) Execute(ctx context.Context, run *Run) error {
+	slog.Info("Mapping page content")
+	m, err := run.Browser.ContentMap(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to map page content: %w", err)
+	}
+	if m.Truncated {
+		slog.Warn("Page is too long, content map covers only its top", "height", m.Height)
+	}
+	run.Result.ContentMap = m
+	return nil
+}
+
+func (a *contentMapAction) Report(ctx context.Context, run *Run) error {
+	m := run.Result.ContentMap
+	stamp := timestamp()
+	img, err := renderContentMap(m)
+	if err != nil {
+		return fmt.Errorf("failed to render content map: %w", err)
+	}
+	if err := writeArtifact(ctx, run, "content-map", "", "Content map", fmt.Sprintf("contentmap_%s.png", stamp), "image/png", img); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode content map: %w", err)
+	}
+	if err := writeArtifact(ctx, run, "content-map-data", "", "Content map data", fmt.Sprintf("contentmap_%s.json", stamp), "application/json", data); err != nil {
+		return err
+	}
+
+	// Batch runs list the page's totals in the batch summary instead
+	if run.Batch || structuredFormat(run.Config.OutputFormat) {
+		return nil
+	}
+	fmt.Printf("Content by screen (%.0fpx each):\n", m.Screen)
+	for i, s := range m.Screens {
+		fmt.Printf("  %3d  %s\n", i+1, formatContentShares(s))
+	}
+	fmt.Printf("  all  %s\n", formatContentShares(m.Total))
+	return nil
+}
+
+// formatContentShares renders shares as percentages.
+func formatContentShares(s chromedphelper.ContentShares) string {
+	return fmt.Sprintf("text %2.0f%%, images %2.0f%%, media %2.0f%%, ads %2.0f%%, whitespace %2.0f%%",
+		s.Text*100, s.Image*100, s.Media*100, s.Ad*100, s.Whitespace*100)
+}
+
+// formatContentMap renders a content map on one line for the batch
+// summary.
+func formatContentMap(m *chromedphelper.ContentMap) string {
+	return fmt.Sprintf("%.1f screens, %s", m.Height/m.Screen, formatContentShares(m.Total))
+}
+
+// renderContentMap draws the grid of m, with a line where each screen
+// ends, and next to each screen a bar of its shares.
+func renderContentMap(m *chromedphelper.ContentMap) ([]byte, error) {
+	if len(m.Grid) == 0 || len(m.Grid[0]) == 0 {
+		return nil, fmt.Errorf("page has no content to map")
+	}
+	columns, rows := len(m.Grid[0]), len(m.Grid)
+	scale := max(1, contentMapWidth/columns)
+	mapWidth := columns * scale
+	img := image.NewRGBA(image.Rect(0, 0, mapWidth+contentMapGap+contentMapBar, rows*scale))
+	fill := func(x0, y0, x1, y1 int, c color.RGBA) {
+		for y := y0; y < y1; y++ {
+			for x := x0; x < x1; x++ {
+				img.SetRGBA(x, y, c)
+			}
+		}
+	}
+	fill(0, 0, img.Bounds().Dx(), img.Bounds().Dy(), color.RGBA{0xff, 0xff, 0xff, 0xff})
+
+	for y, row := range m.Grid {
+		for x, kind := range []rune(row) {
+			fill(x*scale, y*scale, (x+1)*scale, (y+1)*scale, contentColors[kind])
+		}
+	}
+
+	rowsPerScreen := max(1, int(m.Screen/m.Cell))
+	for i, s := range m.Screens {
+		y0 := i * rowsPerScreen * scale
+		y1 := min((i+1)*rowsPerScreen*scale, rows*scale)
+		x := mapWidth + contentMapGap
+		for _, part := range []struct {
+			kind  rune
+			share float64
+		}{
+			{chromedphelper.ContentText, s.Text},
+			{chromedphelper.ContentImage, s.Image},
+			{chromedphelper.ContentMedia, s.Media},
+			{chromedphelper.ContentAd, s.Ad},
+			{chromedphelper.ContentWhitespace, s.Whitespace},
+		} {
+			width := int(part.share*contentMapBar + 0.5)
+			fill(x, y0, min(x+width, mapWidth+contentMapGap+contentMapBar), y1, contentColors[part.kind])
+			x += width
+		}
+		if y1 < rows*scale {
+			fill(0, y1-1, img.Bounds().Dx(), y1, foldColor)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
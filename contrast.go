@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	chromedphelper "github.com/pesarkhobeee/that-cli-web-toolbox/pkg/chromedp"
+)
+
+// contrastAction checks the contrast of the page's text against its
+// rendered background for --contrast-check.
+type contrastAction struct{ noopAction }
+
+func (a *contrastAction) Name() string             { return "contrast" }
+func (a *contrastAction) Enabled(cfg *Config) bool { return cfg.ContrastCheck }
+func (a *contrastAction) Flags() []string          { return []string{"--contrast-check"} }
+
+func (a *contrastAction) Validate(cfg *Config) error {
+	switch strings.ToUpper(cfg.ContrastLevel) {
+	case chromedphelper.ContrastAA, chromedphelper.ContrastAAA:
+		return nil
+	}
+	return fmt.Errorf("invalid --contrast-level %q (expected aa or aaa)", cfg.ContrastLevel)
+}
+
+func (a *contrastAction) Execute(ctx context.Context, run *Run) error {
+	slog.Info("Checking text contrast")
+	report, err := run.Browser.ContrastCheck(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to check text contrast: %w", err)
+	}
+	// AAA failures are only reported when asked for
+	if strings.ToUpper(run.Config.ContrastLevel) == chromedphelper.ContrastAA {
+		var issues []chromedphelper.ContrastIssue
+		for _, issue := range report.Issues {
+			if issue.Level == chromedphelper.ContrastAA {
+				issues = append(issues, issue)
+			}
+		}
+		report.Issues = issues
+	}
+	if report.Truncated {
+		slog.Warn("Page has too much text, contrast check covers only the first elements", "checked", report.Checked)
+	}
+	run.Result.Contrast = report
+	return nil
+}
+
+func (a *contrastAction) Report(ctx context.Context, run *Run) error {
+	// Batch runs list the failure counts in the batch summary instead
+	if run.Batch || structuredFormat(run.Config.OutputFormat) {
+		return nil
+	}
+	report := run.Result.Contrast
+	fmt.Printf("Contrast: %d of %d text elements fail WCAG %s\n", len(report.Issues), report.Checked, strings.ToUpper(run.Config.ContrastLevel))
+	for _, issue := range report.Issues {
+		fmt.Printf("  %s\n", describeContrastIssue(issue))
+	}
+	return nil
+}
+
+// describeContrastIssue renders issue as
+// "2.85:1 fails AA  <p> footer > p "Text" (#999999 on #ffffff)".
+func describeContrastIssue(issue chromedphelper.ContrastIssue) string {
+	size := ""
+	if issue.Large {
+		size = ", large text"
+	}
+	return fmt.Sprintf("%5.2f:1 fails %-3s <%s> %s %q (%s on %s%s)",
+		issue.Ratio, issue.Level, issue.Tag, issue.Selector, issue.Text, issue.Color, issue.Background, size)
+}
+
+// formatContrast renders failure counts for the batch summary, e.g.
+// "3 fail AA, 5 fail AAA of 120 text elements".
+func formatContrast(report *chromedphelper.ContrastReport) string {
+	counts := make(map[string]int)
+	for _, issue := range report.Issues {
+		counts[issue.Level]++
+	}
+	s := fmt.Sprintf("%d fail AA", counts[chromedphelper.ContrastAA])
+	if counts[chromedphelper.ContrastAAA] > 0 {
+		s += fmt.Sprintf(", %d fail AAA", counts[chromedphelper.ContrastAAA])
+	}
+	return fmt.Sprintf("%s of %d text elements", s, report.Checked)
+}
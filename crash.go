@@ -0,0 +1,42 @@
+package main
+
+import (
+	"errors"
+
+	chromedphelper "github.com/pesarkhobeee/that-cli-web-toolbox/pkg/chromedp"
+)
+
+// tabCrash returns the crash behind err, or nil when the page failed, if
+// at all, for another reason.
+func tabCrash(err error) *chromedphelper.Crash {
+	var crash *chromedphelper.CrashError
+	if errors.As(err, &crash) {
+		return &crash.Crash
+	}
+	return nil
+}
+
+// recoverCrash records on run, the retry of a page whose first attempt
+// crashed, that first crash unless the retry crashed too. run may be nil
+// when the retry could not start.
+func recoverCrash(run *Run, crash *chromedphelper.Crash) {
+	if run == nil || run.Result.Crash != nil {
+		return
+	}
+	recovered := *crash
+	recovered.Recovered = true
+	run.Result.Crash = &recovered
+}
+
+// formatCrash renders a crash for the batch summary, e.g.
+// "renderer-oom (status oom), recovered on retry".
+func formatCrash(c *chromedphelper.Crash) string {
+	s := c.Kind
+	if c.Status != "" {
+		s += " (status " + c.Status + ")"
+	}
+	if c.Recovered {
+		s += ", recovered on retry"
+	}
+	return s
+}
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+)
+
+// criticalCSSAction extracts the CSS the first screen of the page needs,
+// for --critical-css.
+type criticalCSSAction struct{ noopAction }
+
+func (a *criticalCSSAction) Name() string             { return "critical-css" }
+func (a *criticalCSSAction) Enabled(cfg *Config) bool { return cfg.CriticalCSS }
+func (a *criticalCSSAction) Flags() []string          { return []string{"--critical-css"} }
+
+func (a *criticalCSSAction) Execute(ctx context.Context, run *Run) error {
+	slog.Info("Extracting critical CSS")
+	css, err := run.Browser.CriticalCSS(ctx)
+	if err != nil {
+		slog.Error("Failed to extract critical CSS", "error", err)
+		return fmt.Errorf("failed to extract critical CSS: %w", err)
+	}
+	run.Result.CriticalCSS = css
+	return nil
+}
+
+func (a *criticalCSSAction) Report(ctx context.Context, run *Run) error {
+	return writeTextAs(ctx, run, fmt.Sprintf("critical_%s.css", timestamp()), "text/css; charset=utf-8", run.Result.CriticalCSS)
+}
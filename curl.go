@@ -0,0 +1,124 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/pesarkhobeee/that-cli-web-toolbox/pkg/events"
+)
+
+// curlAction turns the requests the page made into curl commands, so API
+// calls seen during rendering can be replayed from a shell.
+type curlAction struct {
+	noopAction
+
+	// match, from --emit-curl-match, selects requests by URL; without it
+	// fetch and XHR requests are emitted.
+	match *regexp.Regexp
+
+	mu       sync.Mutex
+	requests []events.RequestFinished
+}
+
+func (a *curlAction) Name() string             { return "curl" }
+func (a *curlAction) Enabled(cfg *Config) bool { return cfg.EmitCurl }
+func (a *curlAction) Flags() []string          { return []string{"--emit-curl"} }
+
+func (a *curlAction) Validate(cfg *Config) error {
+	if cfg.EmitCurlMatch == "" {
+		return nil
+	}
+	re, err := regexp.Compile(cfg.EmitCurlMatch)
+	if err != nil {
+		return fmt.Errorf("invalid --emit-curl-match regexp: %w", err)
+	}
+	a.match = re
+	return nil
+}
+
+func (a *curlAction) Prepare(ctx context.Context, run *Run) error {
+	// Requests must be recorded from the start of navigation
+	stream := run.Browser.Events()
+	go func() {
+		for ev := range stream {
+			req, ok := ev.(events.RequestFinished)
+			if !ok || !a.selected(req) {
+				continue
+			}
+			a.mu.Lock()
+			a.requests = append(a.requests, req)
+			a.mu.Unlock()
+		}
+	}()
+	return nil
+}
+
+// selected reports whether a curl command is emitted for req.
+func (a *curlAction) selected(req events.RequestFinished) bool {
+	if !strings.HasPrefix(req.URL, "http://") && !strings.HasPrefix(req.URL, "https://") {
+		return false
+	}
+	if a.match != nil {
+		return a.match.MatchString(req.URL)
+	}
+	return req.ResourceType == "Fetch" || req.ResourceType == "XHR"
+}
+
+func (a *curlAction) Execute(ctx context.Context, run *Run) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for _, req := range a.requests {
+		run.Result.Curl = append(run.Result.Curl, curlCommand(req))
+	}
+	slog.Debug("Curl commands generated", "count", len(run.Result.Curl))
+	return nil
+}
+
+func (a *curlAction) Report(ctx context.Context, run *Run) error {
+	if len(run.Result.Curl) == 0 {
+		slog.Info("No requests to emit as curl commands")
+		return nil
+	}
+	return writeTextAs(ctx, run, fmt.Sprintf("curl_%s.sh", timestamp()), "text/x-shellscript; charset=utf-8",
+		strings.Join(run.Result.Curl, "\n\n"))
+}
+
+// curlCommand renders req as a curl command line with its method, headers
+// and body.
+func curlCommand(req events.RequestFinished) string {
+	args := []string{"curl"}
+	switch {
+	case req.Method == "GET" && req.PostData == "":
+	case req.Method == "POST" && req.PostData != "":
+		// --data-raw implies POST
+	default:
+		args = append(args, "-X "+shellQuote(req.Method))
+	}
+	args = append(args, shellQuote(req.URL))
+
+	names := make([]string, 0, len(req.RequestHeaders))
+	for name := range req.RequestHeaders {
+		// HTTP/2 pseudo-headers are derived from the URL and method
+		if !strings.HasPrefix(name, ":") {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		args = append(args, "-H "+shellQuote(name+": "+req.RequestHeaders[name]))
+	}
+	if req.PostData != "" {
+		args = append(args, "--data-raw "+shellQuote(req.PostData))
+	}
+	return strings.Join(args, " \\\n  ")
+}
+
+// shellQuote quotes s for POSIX shells.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
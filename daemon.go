@@ -0,0 +1,562 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/pesarkhobeee/that-cli-web-toolbox/pkg/cache"
+	chromedphelper "github.com/pesarkhobeee/that-cli-web-toolbox/pkg/chromedp"
+)
+
+// viaTimeout bounds asking the daemon for its browser, which may include
+// restarting Chrome.
+const viaTimeout = 30 * time.Second
+
+type daemonConfig struct {
+	Socket             string
+	MaxBrowserLifetime time.Duration
+	DrainTimeout       time.Duration
+}
+
+var daemonCfg daemonConfig
+
+var daemonCmd = &cobra.Command{
+	Use:   "daemon",
+	Short: "Keep Chrome running for invocations with --via",
+	Long: `Start Chrome once and keep it running, so that invocations with
+--via SOCKET use it instead of starting Chrome of their own, which saves the
+seconds Chrome takes to start on every run of a script.
+
+The daemon listens on the Unix socket given with --socket, which only its
+user may use, and relays Chrome's DevTools protocol over it: clients talk to
+Chrome through the socket, like to a browser given with
+--remote-debugging-port. Every invocation gets a browser context of its own,
+so invocations share no cookies or storage. Outputs are written by the
+client, as without the daemon. Chrome is restarted when it has exited.
+
+With --max-browser-lifetime, Chrome is replaced once it has run that long:
+clients asking from then on get a fresh Chrome, and the old one is closed
+once the pages of its clients are closed, or after --drain-timeout. On
+SIGTERM or an interrupt the daemon drains the same way: it turns new
+clients away with 503 and waits for the pages of connected ones before
+closing Chrome.
+
+Chrome itself still listens for DevTools on a port of 127.0.0.1, which the
+daemon relays. Other local users can reach that port too, so only run the
+daemon on machines you do not share.`,
+	Example: `  # Start the daemon, e.g. from a systemd user unit
+  that-cli-web-toolbox daemon --socket /run/user/1000/toolbox.sock
+
+  # Use it from scripts
+  that-cli-web-toolbox --via /run/user/1000/toolbox.sock --screenshot https://example.com`,
+	RunE: runDaemon,
+	Args: cobra.NoArgs,
+}
+
+func init() {
+	daemonCmd.Flags().StringVar(&daemonCfg.Socket, "socket", "", "Path of the Unix socket to listen on (required)")
+	daemonCmd.Flags().DurationVar(&daemonCfg.MaxBrowserLifetime, "max-browser-lifetime", 0,
+		"Replace Chrome with a fresh instance once it has run this long, e.g. 6h; 0 keeps it")
+	daemonCmd.Flags().DurationVar(&daemonCfg.DrainTimeout, "drain-timeout", shutdownGrace,
+		"How long clients' pages may stay open in a Chrome being replaced or shut down before it is closed")
+	rootCmd.AddCommand(daemonCmd)
+}
+
+// daemonVersion is the response of the daemon's /json/version endpoint,
+// the part of Chrome's that clients need.
+type daemonVersion struct {
+	// WebSocketDebuggerURL is where the daemon relays Chrome's DevTools
+	// websocket; its host is that of the request.
+	WebSocketDebuggerURL string `json:"webSocketDebuggerUrl"`
+}
+
+func runDaemon(cmd *cobra.Command, args []string) error {
+	setupLogging(cfg.LogLevel)
+
+	if daemonCfg.Socket == "" {
+		return fmt.Errorf("--socket is required")
+	}
+	if cfg.RemoteDebuggingPort != "" {
+		return fmt.Errorf("daemon cannot be used with --remote-debugging-port; connect to that browser directly")
+	}
+	if daemonCfg.MaxBrowserLifetime < 0 {
+		return fmt.Errorf("--max-browser-lifetime cannot be negative")
+	}
+	if daemonCfg.DrainTimeout < 0 {
+		return fmt.Errorf("--drain-timeout cannot be negative")
+	}
+
+	ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	ln, err := listenUnix(daemonCfg.Socket)
+	if err != nil {
+		return err
+	}
+
+	// Chrome outlives ctx so in-flight requests can finish on shutdown
+	d := &daemon{ctx: context.Background(), maxLifetime: daemonCfg.MaxBrowserLifetime, drainTimeout: daemonCfg.DrainTimeout}
+	defer d.Close()
+	if _, err := d.browser(ctx); err != nil {
+		if closeErr := ln.Close(); closeErr != nil {
+			slog.Warn("Failed to close socket", "error", closeErr)
+		}
+		return err
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /json/version", func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := d.serving(w, r); !ok {
+			return
+		}
+		data, err := json.Marshal(daemonVersion{WebSocketDebuggerURL: "ws://" + r.Host + daemonDevTools})
+		if err != nil {
+			writeAPIError(w, err)
+			return
+		}
+		writeAPIData(w, "application/json", data)
+	})
+	mux.HandleFunc("GET "+daemonDevTools, func(w http.ResponseWriter, r *http.Request) {
+		c, ok := d.serving(w, r)
+		if !ok {
+			return
+		}
+		slog.Debug("Relaying DevTools connection", "address", c.address)
+		c.relay.ServeHTTP(w, r)
+	})
+	mux.HandleFunc("GET /healthz", func(w http.ResponseWriter, r *http.Request) {
+		if d.draining.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	})
+
+	srv := &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	serveErr := make(chan error, 1)
+	go func() {
+		slog.Info("Serving browser", "socket", daemonCfg.Socket)
+		serveErr <- srv.Serve(ln)
+	}()
+
+	select {
+	case err := <-serveErr:
+		return fmt.Errorf("failed to serve: %w", err)
+	case <-ctx.Done():
+	}
+
+	d.draining.Store(true)
+	slog.Info("Shutting down, waiting for clients to close their pages", "timeout", daemonCfg.DrainTimeout)
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), daemonCfg.DrainTimeout)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		return fmt.Errorf("failed to shut down: %w", err)
+	}
+	d.drain()
+	return nil
+}
+
+// listenUnix listens on the Unix socket path, accessible to the current
+// user only. A socket left behind by a daemon that is gone is replaced.
+func listenUnix(path string) (net.Listener, error) {
+	if conn, err := net.Dial("unix", path); err == nil {
+		if err := conn.Close(); err != nil {
+			slog.Warn("Failed to close connection", "error", err)
+		}
+		return nil, fmt.Errorf("a daemon is already listening on %s", path)
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("failed to remove stale socket %s: %w", path, err)
+	}
+
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on %s: %w", path, err)
+	}
+	if err := os.Chmod(path, 0o600); err != nil {
+		if closeErr := ln.Close(); closeErr != nil {
+			slog.Warn("Failed to close socket", "error", closeErr)
+		}
+		return nil, fmt.Errorf("failed to restrict access to %s: %w", path, err)
+	}
+	return ln, nil
+}
+
+// daemonDevTools is the path of the DevTools websocket the daemon relays to
+// its current Chrome, whichever that is when a client connects.
+const daemonDevTools = "/devtools/browser"
+
+// daemon keeps one Chrome running for its clients.
+type daemon struct {
+	ctx          context.Context
+	maxLifetime  time.Duration
+	drainTimeout time.Duration
+	// draining is set on shutdown, when new clients are turned away.
+	draining atomic.Bool
+	// retiring counts replaced browsers waiting for their clients.
+	retiring sync.WaitGroup
+
+	mu     sync.Mutex
+	chrome *daemonChrome
+}
+
+// daemonChrome is a Chrome started by the daemon.
+type daemonChrome struct {
+	pool *chromedphelper.Pool
+	// profile is Chrome's profile directory, removed when it is closed.
+	profile string
+	// address is the loopback address of Chrome's DevTools port, which
+	// only the daemon connects to.
+	address string
+	// relay forwards DevTools websockets of clients to Chrome.
+	relay   *httputil.ReverseProxy
+	started time.Time
+	// own are the IDs of the pages Chrome was started with, which are not
+	// those of clients.
+	own map[string]bool
+}
+
+// serving returns the daemon's Chrome for a client's request, or answers
+// it with an error if the daemon is draining or Chrome cannot be started.
+func (d *daemon) serving(w http.ResponseWriter, r *http.Request) (*daemonChrome, bool) {
+	if d.draining.Load() {
+		w.Header().Set("Retry-After", retryAfter)
+		writeAPIError(w, errDraining)
+		return nil, false
+	}
+	c, err := d.browser(r.Context())
+	if err != nil {
+		slog.Error("Browser unavailable", "error", err)
+		writeAPIError(w, err)
+		return nil, false
+	}
+	return c, true
+}
+
+// browser returns the daemon's Chrome, starting it first if it is not
+// running (any more) or replacing it once it outlived the maximum
+// lifetime.
+func (d *daemon) browser(ctx context.Context) (*daemonChrome, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if c := d.chrome; c != nil {
+		switch {
+		case !debuggerAlive(ctx, c.address):
+			slog.Warn("Browser is gone, restarting it", "address", c.address)
+			c.close()
+		case d.maxLifetime > 0 && time.Since(c.started) >= d.maxLifetime:
+			slog.Info("Recycling browser", "address", c.address, "age", time.Since(c.started).Round(time.Second))
+			d.retire(c)
+		default:
+			return c, nil
+		}
+		d.chrome = nil
+	}
+
+	c, err := startDaemonChrome(ctx, d.ctx)
+	if err != nil {
+		return nil, err
+	}
+	pages, err := debuggerPages(ctx, c.address)
+	if err != nil {
+		slog.Warn("Failed to list the browser's pages", "error", err)
+	}
+	for _, id := range pages {
+		c.own[id] = true
+	}
+	d.chrome = c
+	return c, nil
+}
+
+// startDaemonChrome starts a Chrome living as long as parent, in a profile
+// of the daemon's process so the cache knows it is in use. Chrome picks
+// its DevTools port itself, which is read back from its profile, so no
+// other process can take it first.
+func startDaemonChrome(ctx, parent context.Context) (*daemonChrome, error) {
+	profile, err := daemonProfile()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Chrome profile: %w", err)
+	}
+	slog.Info("Starting browser", "profile", profile)
+	pool, err := chromedphelper.NewPool(parent, 1, 0, "", "", append(managedBrowserOptions(""), chromedphelper.WithUserDataDir(profile))...)
+	if err != nil {
+		removeProfile(profile)
+		slog.Error("Failed to initialize browser", "error", err)
+		return nil, fmt.Errorf("failed to initialize browser: %w", err)
+	}
+	c := &daemonChrome{pool: pool, profile: profile, started: time.Now(), own: make(map[string]bool)}
+	address, path, err := devToolsActivePort(ctx, profile)
+	if err != nil {
+		c.close()
+		return nil, fmt.Errorf("failed to find the browser's DevTools port: %w", err)
+	}
+	c.address = address
+	c.relay = &httputil.ReverseProxy{
+		Rewrite: func(r *httputil.ProxyRequest) {
+			r.SetURL(&url.URL{Scheme: "http", Host: address})
+			r.Out.URL.Path = path
+		},
+		ErrorLog: slog.NewLogLogger(slog.Default().Handler(), slog.LevelWarn),
+	}
+	return c, nil
+}
+
+// close shuts c down and removes its profile.
+func (c *daemonChrome) close() {
+	c.pool.Close()
+	removeProfile(c.profile)
+}
+
+// daemonProfile creates a profile directory named after the process, like
+// those of the browsers managedBrowserOptions starts.
+func daemonProfile() (string, error) {
+	dir := toolCache().Path(cache.Profiles)
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return "", err
+	}
+	return os.MkdirTemp(dir, fmt.Sprintf("%d-*", os.Getpid()))
+}
+
+// removeProfile removes the profile directory dir.
+func removeProfile(dir string) {
+	if err := os.RemoveAll(dir); err != nil {
+		slog.Warn("Failed to remove Chrome profile", "dir", dir, "error", err)
+	}
+}
+
+// devToolsActivePort reads the DevTools port Chrome listens on, and the
+// path of its browser websocket, from the DevToolsActivePort file Chrome
+// writes into its profile once it listens. It returns the loopback address
+// of the port.
+func devToolsActivePort(ctx context.Context, profile string) (address, path string, err error) {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	file := filepath.Join(profile, "DevToolsActivePort")
+	for {
+		data, err := os.ReadFile(file)
+		if err == nil {
+			// The port and the path, each on a line of its own
+			port, rest, _ := strings.Cut(string(data), "\n")
+			path, _, _ = strings.Cut(rest, "\n")
+			if _, err := strconv.ParseUint(port, 10, 16); err == nil && strings.HasPrefix(path, "/devtools/browser/") {
+				return net.JoinHostPort("127.0.0.1", port), path, nil
+			}
+		} else if !errors.Is(err, os.ErrNotExist) {
+			return "", "", err
+		}
+		// Absent or still being written
+		select {
+		case <-ctx.Done():
+			return "", "", fmt.Errorf("no DevTools port in %s: %w", file, ctx.Err())
+		case <-time.After(50 * time.Millisecond):
+		}
+	}
+}
+
+// retire closes c in the background once its clients closed their pages,
+// or after the drain timeout.
+func (d *daemon) retire(c *daemonChrome) {
+	d.retiring.Add(1)
+	go func() {
+		defer d.retiring.Done()
+		deadline := time.Now().Add(d.drainTimeout)
+		for {
+			pages, err := debuggerPages(context.Background(), c.address)
+			open := 0
+			for _, id := range pages {
+				if !c.own[id] {
+					open++
+				}
+			}
+			if err != nil || open == 0 {
+				break
+			}
+			if !time.Now().Before(deadline) {
+				slog.Warn("Clients still have pages open, closing the browser anyway", "address", c.address, "pages", open)
+				break
+			}
+			time.Sleep(time.Second)
+		}
+		slog.Info("Closing retired browser", "address", c.address)
+		c.close()
+	}()
+}
+
+// drain retires the current Chrome and waits until every retired one is
+// closed.
+func (d *daemon) drain() {
+	d.mu.Lock()
+	if d.chrome != nil {
+		d.retire(d.chrome)
+		d.chrome = nil
+	}
+	d.mu.Unlock()
+	d.retiring.Wait()
+}
+
+// Close shuts Chrome down.
+func (d *daemon) Close() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.chrome != nil {
+		d.chrome.close()
+	}
+}
+
+// debuggerAlive reports whether Chrome answers on its DevTools address.
+func debuggerAlive(ctx context.Context, address string) bool {
+	ctx, cancel := context.WithTimeout(ctx, 2*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://"+address+"/json/version", nil)
+	if err != nil {
+		return false
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return false
+	}
+	if err := resp.Body.Close(); err != nil {
+		slog.Warn("failed to close response body", "error", err)
+	}
+	return resp.StatusCode == http.StatusOK
+}
+
+// debuggerPages returns the IDs of the pages open in the Chrome listening
+// on the DevTools address.
+func debuggerPages(ctx context.Context, address string) ([]string, error) {
+	ctx, cancel := context.WithTimeout(ctx, 2*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://"+address+"/json/list", nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := resp.Body.Close(); err != nil {
+			slog.Warn("failed to close response body", "error", err)
+		}
+	}()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("DevTools returned status %d", resp.StatusCode)
+	}
+	var targets []struct {
+		ID   string `json:"id"`
+		Type string `json:"type"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&targets); err != nil {
+		return nil, err
+	}
+	var pages []string
+	for _, t := range targets {
+		if t.Type == "page" {
+			pages = append(pages, t.ID)
+		}
+	}
+	return pages, nil
+}
+
+// viaBrowser checks that the daemon listening on socket has a Chrome to
+// offer and returns the remote debugging address reaching it over the
+// socket.
+func viaBrowser(ctx context.Context, socket string) (string, error) {
+	client := &http.Client{
+		Timeout: viaTimeout,
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				var dialer net.Dialer
+				return dialer.DialContext(ctx, "unix", socket)
+			},
+		},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://daemon/json/version", nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to reach the daemon on %s: %w (start it with: that-cli-web-toolbox daemon --socket %s)", socket, err, socket)
+	}
+	defer func() {
+		if err := resp.Body.Close(); err != nil {
+			slog.Warn("failed to close response body", "error", err)
+		}
+	}()
+
+	if resp.StatusCode != http.StatusOK {
+		var apiErr struct {
+			Error string `json:"error"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&apiErr); err != nil || apiErr.Error == "" {
+			return "", fmt.Errorf("daemon on %s returned status %d", socket, resp.StatusCode)
+		}
+		return "", fmt.Errorf("daemon on %s: %s", socket, apiErr.Error)
+	}
+	var version daemonVersion
+	if err := json.NewDecoder(resp.Body).Decode(&version); err != nil {
+		return "", fmt.Errorf("invalid response from the daemon on %s: %w", socket, err)
+	}
+	return chromedphelper.UnixSocketPrefix + socket, nil
+}
+
+// validateVia checks that --via is not combined with options for starting
+// Chrome, which cannot apply to the daemon's running Chrome.
+func validateVia(cfg *Config) error {
+	if cfg.Via == "" {
+		return nil
+	}
+	viaConflicts := []struct {
+		set  bool
+		name string
+	}{
+		{cfg.RemoteDebuggingPort != "", "--remote-debugging-port"},
+		{cfg.Tor, "--tor"},
+		{cfg.ProxyPool != "", "--proxy-pool"},
+		{cfg.Untrusted, "--untrusted"},
+		{cfg.Headful, "--headful"},
+		{cfg.HeadlessMode != "", "--headless-mode"},
+	}
+	for _, c := range viaConflicts {
+		if c.set {
+			slog.Error("--via specified with "+c.name, "via", cfg.Via)
+			return fmt.Errorf("--via cannot be used with %s", c.name)
+		}
+	}
+	return nil
+}
+
+// connectVia points cfg at the Chrome of the --via daemon, unless the run
+// needs no browser.
+func connectVia(ctx context.Context, cfg *Config) error {
+	if cfg.Via == "" || cfg.NoBrowser {
+		return nil
+	}
+	address, err := viaBrowser(ctx, cfg.Via)
+	if err != nil {
+		slog.Error("Failed to get the daemon's browser", "socket", cfg.Via, "error", err)
+		return err
+	}
+	slog.Debug("Using the daemon's browser", "socket", cfg.Via, "address", address)
+	cfg.RemoteDebuggingPort = address
+	return nil
+}
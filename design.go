@@ -0,0 +1,277 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	_ "image/jpeg" // decode JPEG design exports
+	"image/png"
+	"log/slog"
+	"math"
+	"os"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	chromedphelper "github.com/pesarkhobeee/that-cli-web-toolbox/pkg/chromedp"
+	"github.com/pesarkhobeee/that-cli-web-toolbox/pkg/imagediff"
+	"github.com/pesarkhobeee/that-cli-web-toolbox/pkg/miniyaml"
+)
+
+// Comparison settings of --design-baseline.
+const (
+	// designThreshold is the largest difference of a color channel still
+	// counted as the same color, absorbing antialiasing and font rendering.
+	designThreshold = 32
+	// designMaxShift is how many pixels the design may be moved to line it
+	// up with the page.
+	designMaxShift = 24
+)
+
+// designAction compares the rendered page against an exported design
+// image for --design-baseline, writes an overlay of the differences and
+// fails when more than --tolerance of the pixels differ.
+type designAction struct {
+	noopAction
+	design    image.Image
+	tolerance float64
+	regions   ignoreRegions
+	overlay   []byte
+}
+
+func (a *designAction) Name() string             { return "design-diff" }
+func (a *designAction) Enabled(cfg *Config) bool { return cfg.DesignBaseline != "" }
+func (a *designAction) Flags() []string          { return []string{"--design-baseline"} }
+
+func (a *designAction) Validate(cfg *Config) error {
+	var err error
+	if a.tolerance, err = parsePercentage(cfg.Tolerance); err != nil {
+		return fmt.Errorf("invalid --tolerance: %w", err)
+	}
+	if a.regions, err = parseIgnoreRegions(cfg); err != nil {
+		return err
+	}
+	data, err := os.ReadFile(cfg.DesignBaseline)
+	if err != nil {
+		return fmt.Errorf("failed to read --design-baseline: %w", err)
+	}
+	if a.design, _, err = image.Decode(bytes.NewReader(data)); err != nil {
+		return fmt.Errorf("failed to decode --design-baseline %s (expected PNG or JPEG): %w", cfg.DesignBaseline, err)
+	}
+	return nil
+}
+
+func (a *designAction) Execute(ctx context.Context, run *Run) error {
+	slog.Info("Comparing page with design", "design", run.Config.DesignBaseline)
+	shot, err := captureForDiff(ctx, run, a.regions)
+	if err != nil {
+		return err
+	}
+	res := imagediff.Compare(shot.image, a.design, imagediff.Options{
+		Threshold: designThreshold,
+		MaxShift:  int(math.Round(designMaxShift * shot.scale)),
+		Ignore:    shot.ignore,
+	})
+	if d := a.design.Bounds(); res.Scale != 1 {
+		slog.Info("Design resized to the width of the page", "design", fmt.Sprintf("%dx%d", d.Dx(), d.Dy()), "scale", res.Scale)
+	}
+	if a.overlay, err = encodePNG(res.Overlay); err != nil {
+		return fmt.Errorf("failed to encode design diff: %w", err)
+	}
+	run.Result.DesignDiff = res
+	return nil
+}
+
+func (a *designAction) Report(ctx context.Context, run *Run) error {
+	res := run.Result.DesignDiff
+	if err := writeArtifact(ctx, run, "design-diff", "", "Design diff", fmt.Sprintf("designdiff_%s.png", timestamp()), "image/png", a.overlay); err != nil {
+		return err
+	}
+	// Batch runs list the difference in the summary instead
+	if !run.Batch && !structuredFormat(run.Config.OutputFormat) {
+		fmt.Printf("Design differs in %s (tolerance %g%%)\n", formatDesignDiff(res), a.tolerance)
+		for i, r := range res.Regions {
+			if i == 5 {
+				fmt.Printf("  ... and %d more regions\n", len(res.Regions)-i)
+				break
+			}
+			fmt.Printf("  region at %d,%d, %dx%d\n", r.X, r.Y, r.Width, r.Height)
+		}
+	}
+	if res.Ratio*100 > a.tolerance {
+		return &exitError{code: exitAssertion, err: fmt.Errorf("page differs from the design in %.2f%% of pixels, more than %g%%", res.Ratio*100, a.tolerance)}
+	}
+	return nil
+}
+
+// ignoreRegions are the regions of --ignore-region and
+// --ignore-regions-file: boxes in CSS pixels of the page, and selectors
+// whose elements are left out.
+type ignoreRegions struct {
+	boxes     []image.Rectangle
+	selectors []string
+}
+
+func parseIgnoreRegions(cfg *Config) (ignoreRegions, error) {
+	var regions ignoreRegions
+	values := cfg.IgnoreRegions
+	if cfg.IgnoreRegionsFile != "" {
+		fromFile, err := loadIgnoreRegions(cfg.IgnoreRegionsFile)
+		if err != nil {
+			return regions, err
+		}
+		values = append(slices.Clone(values), fromFile...)
+	}
+	for _, s := range values {
+		if strings.TrimSpace(s) == "" {
+			return regions, fmt.Errorf("--ignore-region cannot be empty")
+		}
+		if box, ok, err := parseRegion(s); err != nil {
+			return regions, fmt.Errorf("invalid --ignore-region: %w", err)
+		} else if ok {
+			regions.boxes = append(regions.boxes, box)
+		} else {
+			regions.selectors = append(regions.selectors, s)
+		}
+	}
+	return regions, nil
+}
+
+// diffShot is a screenshot for a comparison and the regions to ignore on
+// it.
+type diffShot struct {
+	png   []byte
+	image image.Image
+	// ignore holds the regions in pixels of the image, and scale is the
+	// number of them per CSS pixel.
+	ignore []image.Rectangle
+	scale  float64
+}
+
+// captureForDiff takes a lossless screenshot like --screenshot, whatever
+// --screenshot-format is, and locates regions on it.
+func captureForDiff(ctx context.Context, run *Run, regions ignoreRegions) (*diffShot, error) {
+	opts := screenshotOptions(run.Config, chromedphelper.PNG)
+	opts.Format, opts.Quality = chromedphelper.PNG, 0
+	shot := &diffShot{}
+	var err error
+	if run.Config.FullPage {
+		shot.png, err = run.Browser.ScreenshotFullPage(ctx, opts)
+	} else {
+		shot.png, err = run.Browser.ScreenshotViewport(ctx, opts)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to take screenshot for comparison: %w", err)
+	}
+	if shot.image, err = png.Decode(bytes.NewReader(shot.png)); err != nil {
+		return nil, fmt.Errorf("failed to decode screenshot: %w", err)
+	}
+	layout, err := run.Browser.Layout(ctx, regions.selectors)
+	if err != nil {
+		return nil, fmt.Errorf("failed to measure page layout: %w", err)
+	}
+	boxes := slices.Clone(regions.boxes)
+	for _, selector := range regions.selectors {
+		n := 0
+		for _, el := range layout.Elements {
+			if el.Selector == selector {
+				n++
+				boxes = append(boxes, image.Rect(
+					int(math.Floor(el.Box.X)), int(math.Floor(el.Box.Y)),
+					int(math.Ceil(el.Box.X+el.Box.Width)), int(math.Ceil(el.Box.Y+el.Box.Height)),
+				))
+			}
+		}
+		if n == 0 {
+			slog.Warn("No visible element to ignore in the comparison", "selector", selector)
+		}
+	}
+
+	// Regions are in CSS pixels of the page; the screenshot shows the
+	// viewport or the whole page, scaled by the device pixel ratio
+	clip := layout.Viewport
+	if run.Config.FullPage {
+		clip = layout.Page
+	}
+	shot.scale = float64(shot.image.Bounds().Dx()) / clip.Width
+	for _, r := range boxes {
+		shot.ignore = append(shot.ignore, image.Rect(
+			int(math.Floor((float64(r.Min.X)-clip.X)*shot.scale)), int(math.Floor((float64(r.Min.Y)-clip.Y)*shot.scale)),
+			int(math.Ceil((float64(r.Max.X)-clip.X)*shot.scale)), int(math.Ceil((float64(r.Max.Y)-clip.Y)*shot.scale)),
+		))
+	}
+	return shot, nil
+}
+
+func encodePNG(img image.Image) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// formatDesignDiff renders how much of the page differs from the design,
+// e.g. "3.20% of pixels (4 regions, offset +2,-1)".
+func formatDesignDiff(res *imagediff.Result) string {
+	return fmt.Sprintf("%.2f%% of pixels (%d regions, offset %+d,%+d)", res.Ratio*100, len(res.Regions), res.OffsetX, res.OffsetY)
+}
+
+// parsePercentage parses a percentage from 0 to 100 written as N or N%.
+func parsePercentage(s string) (float64, error) {
+	v, err := strconv.ParseFloat(strings.TrimSuffix(strings.TrimSpace(s), "%"), 64)
+	if err != nil || v < 0 || v > 100 {
+		return 0, fmt.Errorf("%q is not a percentage between 0 and 100", s)
+	}
+	return v, nil
+}
+
+// regionPattern matches regions written as numbers, X,Y,WIDTH,HEIGHT,
+// rather than as selectors.
+var regionPattern = regexp.MustCompile(`^[\s\d,.-]+$`)
+
+// parseRegion parses a region of the page written X,Y,WIDTH,HEIGHT in CSS
+// pixels. It returns false when s is not written as numbers, i.e. is a
+// selector.
+func parseRegion(s string) (image.Rectangle, bool, error) {
+	if !regionPattern.MatchString(s) {
+		return image.Rectangle{}, false, nil
+	}
+	parts := strings.Split(s, ",")
+	if len(parts) != 4 {
+		return image.Rectangle{}, false, fmt.Errorf("%q is not a region (expected X,Y,WIDTH,HEIGHT or a CSS selector)", s)
+	}
+	var v [4]int
+	for i, p := range parts {
+		n, err := strconv.Atoi(strings.TrimSpace(p))
+		if err != nil || n < 0 {
+			return image.Rectangle{}, false, fmt.Errorf("%q is not a region (expected X,Y,WIDTH,HEIGHT or a CSS selector)", s)
+		}
+		v[i] = n
+	}
+	if v[2] == 0 || v[3] == 0 {
+		return image.Rectangle{}, false, fmt.Errorf("region %q is empty", s)
+	}
+	return image.Rect(v[0], v[1], v[0]+v[2], v[1]+v[3]), true, nil
+}
+
+// ignoreRegionsFile is the YAML file of --ignore-regions-file.
+type ignoreRegionsFile struct {
+	Ignore []string `json:"ignore"`
+}
+
+// loadIgnoreRegions reads the regions listed under ignore in the YAML file
+// at path, written like the values of --ignore-region.
+func loadIgnoreRegions(path string) ([]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read --ignore-regions-file: %w", err)
+	}
+	var f ignoreRegionsFile
+	if err := miniyaml.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("invalid ignore regions file %s: %w", path, err)
+	}
+	return f.Ignore, nil
+}
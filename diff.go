@@ -0,0 +1,230 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/net/html"
+
+	chromedphelper "github.com/pesarkhobeee/that-cli-web-toolbox/pkg/chromedp"
+	"github.com/pesarkhobeee/that-cli-web-toolbox/pkg/htmltext"
+	"github.com/pesarkhobeee/that-cli-web-toolbox/pkg/textdiff"
+)
+
+// What diff compares.
+const (
+	diffModeText = "text"
+	diffModeDOM  = "dom"
+)
+
+// Values of diff --color.
+const (
+	colorAuto   = "auto"
+	colorAlways = "always"
+	colorNever  = "never"
+)
+
+type diffConfig struct {
+	Mode     string
+	Context  int
+	WordDiff bool
+	Color    string
+	Timeout  int
+	Delay    int
+}
+
+var diffCfg diffConfig
+
+var diffCmd = &cobra.Command{
+	Use:   "diff [flags] A B",
+	Short: "Compare the text or DOM of two pages or files in the terminal",
+	Long: `Compare two pages, or a page with a file, and print the differences as a
+unified diff with lines of context, colored on a terminal.
+
+A and B are URLs or files. URLs and HTML files (.html, .htm, .xhtml) are
+rendered, and their visible text (--mode text) or rendered DOM (--mode
+dom) compared; other files, such as the text outputs of earlier runs, are
+compared as they are. The DOM is compared one element or text per line,
+indented by depth, with attributes sorted, so that only real changes show.
+
+--word-diff shows changed lines once, with the deleted and inserted words
+marked within them: colored, or as [-deleted-] and {+inserted+} without
+color. The exit code is 0 when A and B are the same and 3 when they differ.`,
+	Example: `  # What changed on the page since its text was saved?
+  that-cli-web-toolbox diff body_20240630_142501.txt https://example.com/pricing
+
+  # Compare staging and production word by word
+  that-cli-web-toolbox diff --word-diff https://staging.example.com/ https://example.com/
+
+  # Compare the rendered DOM of two builds, into a file without colors
+  that-cli-web-toolbox diff --mode dom --color never old/index.html new/index.html > dom.diff`,
+	RunE: runDiff,
+	Args: cobra.ExactArgs(2),
+	// The differences, not usage, are the output of differing pages
+	SilenceUsage:  true,
+	SilenceErrors: true,
+}
+
+func init() {
+	diffCmd.Flags().StringVar(&diffCfg.Mode, "mode", diffModeText, "What to compare: text (the visible text) or dom (the rendered DOM)")
+	diffCmd.Flags().IntVarP(&diffCfg.Context, "context", "U", 3, "Number of unchanged lines shown around each change")
+	diffCmd.Flags().BoolVar(&diffCfg.WordDiff, "word-diff", false, "Show changed lines once, with the deleted and inserted words marked within them")
+	diffCmd.Flags().StringVar(&diffCfg.Color, "color", colorAuto, "Color the output: auto (on a terminal, unless NO_COLOR is set), always or never")
+	diffCmd.Flags().IntVarP(&diffCfg.Timeout, "timeout", "t", 60, "Maximum time in seconds for loading each page")
+	diffCmd.Flags().IntVarP(&diffCfg.Delay, "delay", "d", 0, "Delay in seconds after each page loads")
+	rootCmd.AddCommand(diffCmd)
+}
+
+func runDiff(cmd *cobra.Command, args []string) error {
+	setupLogging(cfg.LogLevel)
+
+	if diffCfg.Mode != diffModeText && diffCfg.Mode != diffModeDOM {
+		return fmt.Errorf("unsupported --mode %q (expected text or dom)", diffCfg.Mode)
+	}
+	if diffCfg.Color != colorAuto && diffCfg.Color != colorAlways && diffCfg.Color != colorNever {
+		return fmt.Errorf("unsupported --color %q (expected auto, always or never)", diffCfg.Color)
+	}
+	if diffCfg.Context < 0 {
+		return fmt.Errorf("--context cannot be negative")
+	}
+	if diffCfg.Timeout < 1 {
+		return fmt.Errorf("--timeout must be at least 1")
+	}
+	if diffCfg.Delay < 0 {
+		return fmt.Errorf("--delay cannot be negative")
+	}
+
+	ctx, stopTracing, err := startTracing(cmd.Context())
+	if err != nil {
+		return err
+	}
+	defer stopTracing()
+
+	d := &differ{}
+	defer d.close()
+	a, err := d.lines(ctx, args[0])
+	if err != nil {
+		return err
+	}
+	b, err := d.lines(ctx, args[1])
+	if err != nil {
+		return err
+	}
+
+	opts := textdiff.Options{Context: diffCfg.Context, Words: diffCfg.WordDiff, Color: useColor(diffCfg.Color)}
+	differs, err := textdiff.Write(os.Stdout, args[0], args[1], a, b, opts)
+	if err != nil {
+		return err
+	}
+	if !differs {
+		slog.Info("No differences", "a", args[0], "b", args[1], "mode", diffCfg.Mode)
+		return nil
+	}
+	return &exitError{code: exitAssertion, err: errReported}
+}
+
+// differ reads the sides of a diff, starting the browser for the first
+// one that needs rendering.
+type differ struct {
+	root *chromedphelper.Browser
+}
+
+// lines returns the lines of arg to compare: the text or DOM of a
+// rendered page, or the content of a file that is not HTML.
+func (d *differ) lines(ctx context.Context, arg string) ([]string, error) {
+	if info, err := os.Stat(arg); err == nil && !info.IsDir() && !isHTMLFile(arg) {
+		data, err := os.ReadFile(arg)
+		if err != nil {
+			return nil, err
+		}
+		return splitLines(string(data)), nil
+	}
+
+	target, err := resolveTarget(arg)
+	if err != nil {
+		return nil, err
+	}
+	if d.root == nil {
+		d.root, err = chromedphelper.InitializeChromedpContext(ctx, target, 0, diffCfg.Delay, cfg.RemoteDebuggingPort, "", managedBrowserOptions("")...)
+		if err != nil {
+			slog.Error("Failed to initialize browser", "error", err)
+			return nil, &exitError{code: exitBrowser, err: fmt.Errorf("failed to initialize browser: %w", err)}
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(diffCfg.Timeout)*time.Second)
+	defer cancel()
+	tab, err := d.root.NewTab(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tab.Cancel()
+	tab.TargetURL = target
+	slog.Info("Loading page", "url", target)
+	if err := tab.NavigateAndPrepare(ctx); err != nil {
+		return nil, &exitError{code: exitNavigation, err: fmt.Errorf("failed to load %s: %w", arg, err)}
+	}
+	if diffCfg.Mode == diffModeDOM {
+		dom, err := tab.GetHTML(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get the DOM of %s: %w", arg, err)
+		}
+		doc, err := html.Parse(strings.NewReader(dom))
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse the DOM of %s: %w", arg, err)
+		}
+		return htmltext.Lines(doc), nil
+	}
+	text, err := tab.GetBodyText(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get the text of %s: %w", arg, err)
+	}
+	return splitLines(text), nil
+}
+
+func (d *differ) close() {
+	if d.root != nil {
+		d.root.Cancel()
+	}
+}
+
+// isHTMLFile reports whether a file is rendered rather than compared as
+// it is.
+func isHTMLFile(path string) bool {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".html", ".htm", ".xhtml":
+		return true
+	}
+	return false
+}
+
+// splitLines splits text into lines, without a trailing empty line.
+func splitLines(text string) []string {
+	text = strings.TrimSuffix(strings.ReplaceAll(text, "\r\n", "\n"), "\n")
+	if text == "" {
+		return nil
+	}
+	return strings.Split(text, "\n")
+}
+
+// useColor reports whether output is colored for --color: always, never,
+// or with auto on a terminal unless NO_COLOR is set.
+func useColor(mode string) bool {
+	switch mode {
+	case colorAlways:
+		return true
+	case colorNever:
+		return false
+	}
+	if os.Getenv("NO_COLOR") != "" {
+		return false
+	}
+	info, err := os.Stdout.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+
+	chromedphelper "github.com/pesarkhobeee/that-cli-web-toolbox/pkg/chromedp"
+)
+
+// defaultSnapshotStyles are the computed styles --dom-snapshot records
+// without --dom-snapshot-styles: those describing how boxes are laid out,
+// stacked and look.
+var defaultSnapshotStyles = []string{
+	"display", "visibility", "opacity", "position", "z-index", "overflow",
+	"float", "flex-direction", "font-family", "font-size", "font-weight",
+	"line-height", "text-align", "color", "background-color",
+	"background-image", "border-top-width", "border-radius",
+}
+
+// domSnapshotAction writes the page's DOMSnapshot.captureSnapshot data to
+// --dom-snapshot.
+type domSnapshotAction struct {
+	noopAction
+	snapshot *chromedphelper.DOMSnapshot
+}
+
+func (a *domSnapshotAction) Name() string             { return "dom-snapshot" }
+func (a *domSnapshotAction) Enabled(cfg *Config) bool { return cfg.DOMSnapshot != "" }
+func (a *domSnapshotAction) Flags() []string          { return []string{"--dom-snapshot"} }
+
+func (a *domSnapshotAction) Validate(cfg *Config) error {
+	return validateSelectors("--dom-snapshot-styles", cfg.DOMSnapshotStyles)
+}
+
+func (a *domSnapshotAction) Execute(ctx context.Context, run *Run) error {
+	slog.Info("Capturing DOM snapshot")
+	snapshot, err := run.Browser.DOMSnapshot(ctx, run.Config.DOMSnapshotStyles)
+	if err != nil {
+		slog.Error("Failed to capture DOM snapshot", "error", err)
+		return fmt.Errorf("failed to capture DOM snapshot: %w", err)
+	}
+	a.snapshot = snapshot
+	return nil
+}
+
+func (a *domSnapshotAction) Report(ctx context.Context, run *Run) error {
+	data, err := json.Marshal(a.snapshot)
+	if err != nil {
+		return fmt.Errorf("failed to encode DOM snapshot: %w", err)
+	}
+	return writeArtifact(ctx, run, "dom-snapshot", "", "DOM snapshot", run.Config.DOMSnapshot, "application/json", data)
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"strings"
+
+	"github.com/pesarkhobeee/that-cli-web-toolbox/pkg/simhash"
+)
+
+// maxDuplicateDistance is the largest SimHash distance, in bits, at which
+// two pages are reported as near-duplicates.
+const maxDuplicateDistance = 3
+
+// minFingerprintWords is the least amount of text a page needs before its
+// fingerprint is compared; near-empty pages would all look alike.
+const minFingerprintWords = 20
+
+// pageSignature is what duplicate detection records about one target.
+type pageSignature struct {
+	FinalURL    string
+	Canonical   string
+	Fingerprint uint64
+	Words       int
+}
+
+// canonicalMismatch reports whether the page declares a canonical URL that
+// differs from the URL it was served at.
+func (s *pageSignature) canonicalMismatch() bool {
+	return s.Canonical != "" && normalizeURL(s.Canonical) != normalizeURL(s.FinalURL)
+}
+
+// normalizeURL makes URLs comparable: scheme and host are lower-cased,
+// default ports, fragments and trailing slashes are dropped.
+func normalizeURL(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return raw
+	}
+	u.Scheme = strings.ToLower(u.Scheme)
+	u.Host = strings.ToLower(u.Host)
+	if (u.Scheme == "http" && u.Port() == "80") || (u.Scheme == "https" && u.Port() == "443") {
+		u.Host = u.Hostname()
+	}
+	u.Fragment = ""
+	u.RawFragment = ""
+	u.Path = strings.TrimSuffix(u.Path, "/")
+	u.RawPath = ""
+	return u.String()
+}
+
+// duplicatesAction records the canonical URL and a text fingerprint of the
+// page for canonical-mismatch and near-duplicate reporting.
+type duplicatesAction struct{ noopAction }
+
+func (a *duplicatesAction) Name() string             { return "duplicates" }
+func (a *duplicatesAction) Enabled(cfg *Config) bool { return cfg.DetectDuplicates }
+func (a *duplicatesAction) Flags() []string          { return []string{"--detect-duplicates"} }
+
+func (a *duplicatesAction) Execute(ctx context.Context, run *Run) error {
+	slog.Info("Checking canonical URL and content fingerprint")
+	meta, err := run.Browser.GetPageMetadata(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get page metadata: %w", err)
+	}
+	text, err := run.Browser.GetBodyText(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get body text: %w", err)
+	}
+
+	run.Result.Page = meta
+	run.Signature = &pageSignature{
+		FinalURL:    meta.URL,
+		Canonical:   meta.Canonical,
+		Fingerprint: simhash.Fingerprint(text),
+		Words:       len(strings.Fields(text)),
+	}
+	run.Result.Fingerprint = fmt.Sprintf("%016x", run.Signature.Fingerprint)
+	slog.Debug("Page signature recorded",
+		"finalURL", meta.URL,
+		"canonical", meta.Canonical,
+		"fingerprint", fmt.Sprintf("%016x", run.Signature.Fingerprint))
+	return nil
+}
+
+func (a *duplicatesAction) Report(ctx context.Context, run *Run) error {
+	// Batch runs compare targets with each other in the summary instead
+	if run.Batch || structuredFormat(run.Config.OutputFormat) {
+		return nil
+	}
+	if run.Signature.canonicalMismatch() {
+		fmt.Printf("Canonical mismatch: %s declares canonical %s\n", run.Signature.FinalURL, run.Signature.Canonical)
+	} else {
+		fmt.Printf("Canonical URL OK: %s\n", run.Signature.FinalURL)
+	}
+	return nil
+}
+
+// printDuplicateReport lists canonical mismatches and near-duplicate pairs
+// among the batch results that recorded a page signature.
+func printDuplicateReport(results []batchResult) {
+	fmt.Println("\nCanonical mismatches:")
+	mismatches := 0
+	for _, r := range results {
+		if r.Signature != nil && r.Signature.canonicalMismatch() {
+			fmt.Printf("  %s -> canonical %s\n", r.Signature.FinalURL, r.Signature.Canonical)
+			mismatches++
+		}
+	}
+	if mismatches == 0 {
+		fmt.Println("  none")
+	}
+
+	fmt.Println("\nNear-duplicate pages:")
+	duplicates := 0
+	for _, pair := range nearDuplicates(results) {
+		fmt.Printf("  %s ~ %s (distance %d)\n", results[pair.a].Target, results[pair.b].Target, pair.distance)
+		duplicates++
+	}
+	if duplicates == 0 {
+		fmt.Println("  none")
+	}
+}
+
+type duplicatePair struct {
+	a, b     int
+	distance int
+}
+
+// nearDuplicates returns the index pairs of results whose text fingerprints
+// are within maxDuplicateDistance bits of each other.
+func nearDuplicates(results []batchResult) []duplicatePair {
+	var pairs []duplicatePair
+	for i := range results {
+		a := results[i].Signature
+		if a == nil || a.Words < minFingerprintWords {
+			continue
+		}
+		for j := i + 1; j < len(results); j++ {
+			b := results[j].Signature
+			if b == nil || b.Words < minFingerprintWords {
+				continue
+			}
+			if d := simhash.Distance(a.Fingerprint, b.Fingerprint); d <= maxDuplicateDistance {
+				pairs = append(pairs, duplicatePair{a: i, b: j, distance: d})
+			}
+		}
+	}
+	return pairs
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sort"
+	"sync"
+
+	chromedphelper "github.com/pesarkhobeee/that-cli-web-toolbox/pkg/chromedp"
+	"github.com/pesarkhobeee/that-cli-web-toolbox/pkg/events"
+)
+
+// Orders accepted by --sort-summary.
+const (
+	sortInput    = "input"
+	sortErrors   = "errors"
+	sortDuration = "duration"
+	sortTarget   = "target"
+)
+
+// errorBudgetAction counts console errors, failed requests and HTTP error
+// responses of the page and fails it when they exceed --fail-threshold.
+type errorBudgetAction struct {
+	noopAction
+
+	mu     sync.Mutex
+	counts chromedphelper.ErrorCounts
+}
+
+func (a *errorBudgetAction) Name() string { return "errors" }
+func (a *errorBudgetAction) Enabled(cfg *Config) bool {
+	return cfg.ErrorSummary || cfg.FailThreshold >= 0
+}
+
+func (a *errorBudgetAction) Flags() []string { return []string{"--error-summary", "--fail-threshold"} }
+
+func (a *errorBudgetAction) Prepare(ctx context.Context, run *Run) error {
+	// Errors must be counted from the start of navigation
+	stream := run.Browser.Events()
+	go func() {
+		for ev := range stream {
+			a.mu.Lock()
+			switch ev := ev.(type) {
+			case events.ConsoleMessage:
+				if ev.Type == "error" || ev.Type == "assert" {
+					a.counts.ConsoleErrors++
+				}
+			case events.Exception:
+				a.counts.ConsoleErrors++
+			case events.RequestFinished:
+				if ev.Failed {
+					a.counts.FailedRequests++
+				} else if ev.Status >= 400 {
+					a.counts.HTTPErrors++
+				}
+			}
+			a.mu.Unlock()
+		}
+	}()
+	return nil
+}
+
+func (a *errorBudgetAction) Execute(ctx context.Context, run *Run) error {
+	a.mu.Lock()
+	counts := a.counts
+	a.mu.Unlock()
+
+	slog.Debug("Page errors counted",
+		"consoleErrors", counts.ConsoleErrors,
+		"failedRequests", counts.FailedRequests,
+		"httpErrors", counts.HTTPErrors)
+	run.Result.Errors = &counts
+	return nil
+}
+
+func (a *errorBudgetAction) Report(ctx context.Context, run *Run) error {
+	counts := run.Result.Errors
+	// Batch runs list the counts in the summary instead
+	if !run.Batch && !structuredFormat(run.Config.OutputFormat) {
+		fmt.Printf("Errors: %s\n", formatErrorCounts(counts))
+	}
+	threshold := run.Config.FailThreshold
+	if threshold >= 0 && counts.Total() > threshold {
+		return fmt.Errorf("error budget exceeded: %d errors, threshold %d", counts.Total(), threshold)
+	}
+	return nil
+}
+
+// formatErrorCounts renders counts for the summary.
+func formatErrorCounts(counts *chromedphelper.ErrorCounts) string {
+	if counts == nil {
+		return "not counted"
+	}
+	return fmt.Sprintf("%d console, %d failed requests, %d HTTP errors",
+		counts.ConsoleErrors, counts.FailedRequests, counts.HTTPErrors)
+}
+
+// validateSummarySort checks --sort-summary.
+func validateSummarySort(order string) error {
+	switch order {
+	case sortInput, sortErrors, sortDuration, sortTarget:
+		return nil
+	}
+	return fmt.Errorf("invalid summary sort %q (expected %s, %s, %s or %s)", order, sortInput, sortErrors, sortDuration, sortTarget)
+}
+
+// sortedResults returns a copy of results in the --sort-summary order:
+// most errors or slowest first, or by target name.
+func sortedResults(results []batchResult, order string) []batchResult {
+	sorted := make([]batchResult, len(results))
+	copy(sorted, results)
+
+	errorTotal := func(r batchResult) int {
+		if r.Result == nil || r.Result.Errors == nil {
+			return 0
+		}
+		return r.Result.Errors.Total()
+	}
+	switch order {
+	case sortErrors:
+		sort.SliceStable(sorted, func(i, j int) bool { return errorTotal(sorted[i]) > errorTotal(sorted[j]) })
+	case sortDuration:
+		sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Duration > sorted[j].Duration })
+	case sortTarget:
+		sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Target < sorted[j].Target })
+	}
+	return sorted
+}
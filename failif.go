@@ -0,0 +1,221 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"maps"
+	"math"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	chromedphelper "github.com/pesarkhobeee/that-cli-web-toolbox/pkg/chromedp"
+	"github.com/pesarkhobeee/that-cli-web-toolbox/pkg/events"
+	"github.com/pesarkhobeee/that-cli-web-toolbox/pkg/expr"
+)
+
+// failIfVars are the variables --fail-if conditions read.
+var failIfVars = []string{
+	"cls", "console_errors", "dom_nodes", "errors", "failed_requests", "fcp_ms",
+	"http_errors", "lcp_ms", "load_ms", "resources", "status", "transfer_kb",
+}
+
+// failIfFuncs are the functions --fail-if conditions call, each with a
+// quoted string: selector(CSS) and count(CSS) test and count the elements
+// matching a selector, text(REGEXP) tests the page text.
+var failIfFuncs = []string{"count", "selector", "text"}
+
+// failIfAction evaluates the --fail-if conditions over the page's metrics
+// and fails the page when any of them holds, so teams can encode their own
+// gating policy.
+type failIfAction struct {
+	noopAction
+
+	conditions []*expr.Expr
+	selectors  []string
+	patterns   map[string]*regexp.Regexp
+
+	mu     sync.Mutex
+	counts chromedphelper.ErrorCounts
+}
+
+func (a *failIfAction) Name() string             { return "fail-if" }
+func (a *failIfAction) Enabled(cfg *Config) bool { return len(cfg.FailIf) > 0 }
+func (a *failIfAction) Flags() []string          { return []string{"--fail-if"} }
+
+func (a *failIfAction) Validate(cfg *Config) error {
+	a.patterns = make(map[string]*regexp.Regexp)
+	for _, src := range cfg.FailIf {
+		e, err := expr.Parse(src)
+		if err != nil {
+			return fmt.Errorf("invalid --fail-if %q: %w", src, err)
+		}
+		for _, name := range e.Idents() {
+			if !slices.Contains(failIfVars, name) {
+				return fmt.Errorf("invalid --fail-if %q: unknown variable %s (expected one of %s)", src, name, strings.Join(failIfVars, ", "))
+			}
+		}
+		for _, c := range e.Calls() {
+			if !slices.Contains(failIfFuncs, c.Name) {
+				return fmt.Errorf("invalid --fail-if %q: unknown function %s (expected one of %s)", src, c.Name, strings.Join(failIfFuncs, ", "))
+			}
+			arg, ok := "", len(c.Args) == 1
+			if ok {
+				arg, ok = c.Args[0].(string)
+			}
+			if !ok || strings.TrimSpace(arg) == "" {
+				return fmt.Errorf("invalid --fail-if %q: %s takes a quoted, non-empty string", src, c.Name)
+			}
+			if c.Name == "text" {
+				if a.patterns[arg], err = regexp.Compile(arg); err != nil {
+					return fmt.Errorf("invalid --fail-if %q: %w", src, err)
+				}
+			} else if !slices.Contains(a.selectors, arg) {
+				a.selectors = append(a.selectors, arg)
+			}
+		}
+		a.conditions = append(a.conditions, e)
+	}
+	return nil
+}
+
+func (a *failIfAction) Prepare(ctx context.Context, run *Run) error {
+	// Errors must be counted from the start of navigation
+	stream := run.Browser.Events()
+	go func() {
+		for ev := range stream {
+			a.mu.Lock()
+			switch ev := ev.(type) {
+			case events.ConsoleMessage:
+				if ev.Type == "error" || ev.Type == "assert" {
+					a.counts.ConsoleErrors++
+				}
+			case events.Exception:
+				a.counts.ConsoleErrors++
+			case events.RequestFinished:
+				if ev.Failed {
+					a.counts.FailedRequests++
+				} else if ev.Status >= 400 {
+					a.counts.HTTPErrors++
+				}
+			}
+			a.mu.Unlock()
+		}
+	}()
+	return nil
+}
+
+func (a *failIfAction) Execute(ctx context.Context, run *Run) error {
+	m, err := run.Browser.Metrics(ctx, a.selectors)
+	if err != nil {
+		return err
+	}
+	for _, s := range a.selectors {
+		if m.Selectors[s] < 0 {
+			return fmt.Errorf("invalid selector %q in --fail-if", s)
+		}
+	}
+	run.Result.Metrics = m
+	var body string
+	if len(a.patterns) > 0 {
+		if body, err = run.Browser.GetBodyText(ctx); err != nil {
+			return fmt.Errorf("failed to get body text: %w", err)
+		}
+	}
+
+	a.mu.Lock()
+	counts := a.counts
+	a.mu.Unlock()
+	vars := map[string]float64{
+		"console_errors":  float64(counts.ConsoleErrors),
+		"failed_requests": float64(counts.FailedRequests),
+		"http_errors":     float64(counts.HTTPErrors),
+		"errors":          float64(counts.Total()),
+		"status":          float64(m.Status),
+		"load_ms":         m.LoadMs,
+		"fcp_ms":          m.FCPMs,
+		"lcp_ms":          m.LCPMs,
+		"cls":             m.CLS,
+		"dom_nodes":       float64(m.DOMNodes),
+		"resources":       float64(m.Resources),
+		"transfer_kb":     float64(m.TransferBytes) / 1024,
+	}
+	env := expr.Env{Vars: make(map[string]expr.Value, len(vars)), Funcs: map[string]expr.Func{
+		// Validate made sure every call has a string argument
+		"selector": func(args []expr.Value) (expr.Value, error) { return m.Selectors[args[0].(string)] > 0, nil },
+		"count":    func(args []expr.Value) (expr.Value, error) { return m.Selectors[args[0].(string)], nil },
+		"text": func(args []expr.Value) (expr.Value, error) {
+			return a.patterns[args[0].(string)].MatchString(body), nil
+		},
+	}}
+	for name, v := range vars {
+		env.Vars[name] = v
+	}
+
+	for _, e := range a.conditions {
+		matched, err := e.Eval(env)
+		if err != nil {
+			return fmt.Errorf("failed to evaluate --fail-if %q: %w", e, err)
+		}
+		res := chromedphelper.ConditionResult{Expression: e.String(), Matched: matched}
+		for _, name := range e.Idents() {
+			if res.Values == nil {
+				res.Values = make(map[string]float64)
+			}
+			res.Values[name] = vars[name]
+		}
+		slog.Debug("Condition evaluated", "condition", e, "matched", matched, "values", res.Values)
+		run.Result.FailIf = append(run.Result.FailIf, res)
+	}
+	return nil
+}
+
+func (a *failIfAction) Report(ctx context.Context, run *Run) error {
+	results := run.Result.FailIf
+	// Batch runs list the outcome in the summary instead
+	if !run.Batch && !structuredFormat(run.Config.OutputFormat) {
+		fmt.Println("Fail-if:")
+		for _, c := range results {
+			status := "PASS"
+			if c.Matched {
+				status = "FAIL"
+			}
+			fmt.Printf("  %s %s%s\n", status, c.Expression, formatConditionValues(c.Values))
+		}
+	}
+	if matched := conditionsMatched(results); matched > 0 {
+		return &exitError{code: exitAssertion, err: fmt.Errorf("%d of %d --fail-if conditions matched", matched, len(results))}
+	}
+	return nil
+}
+
+// formatFailIf renders the outcome of --fail-if for the batch summary.
+func formatFailIf(results []chromedphelper.ConditionResult) string {
+	return fmt.Sprintf("%d of %d conditions matched", conditionsMatched(results), len(results))
+}
+
+func conditionsMatched(results []chromedphelper.ConditionResult) int {
+	matched := 0
+	for _, c := range results {
+		if c.Matched {
+			matched++
+		}
+	}
+	return matched
+}
+
+// formatConditionValues renders the variables of a condition, e.g.
+// " (console_errors = 0, lcp_ms = 4820)".
+func formatConditionValues(values map[string]float64) string {
+	if len(values) == 0 {
+		return ""
+	}
+	var parts []string
+	for _, name := range slices.Sorted(maps.Keys(values)) {
+		parts = append(parts, name+" = "+strconv.FormatFloat(math.Round(values[name]*1000)/1000, 'f', -1, 64))
+	}
+	return " (" + strings.Join(parts, ", ") + ")"
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"math/rand/v2"
+	"os"
+	"sync"
+
+	chromedphelper "github.com/pesarkhobeee/that-cli-web-toolbox/pkg/chromedp"
+)
+
+// fingerprintRandom is the --fingerprint-profile value generating a new
+// profile for every page load.
+const fingerprintRandom = "random"
+
+// fingerprintSource hands out the --fingerprint-profile profile of each
+// page load: a random one, or the profiles of the file in turn.
+type fingerprintSource struct {
+	mu       sync.Mutex
+	rng      *rand.Rand
+	profiles []*chromedphelper.FingerprintProfile
+	next     int
+}
+
+// loadFingerprints returns the source for spec: "random", or a JSON file
+// holding one profile or an array of them.
+func loadFingerprints(spec string) (*fingerprintSource, error) {
+	if spec == fingerprintRandom {
+		return &fingerprintSource{rng: rand.New(rand.NewPCG(rand.Uint64(), rand.Uint64()))}, nil
+	}
+
+	data, err := os.ReadFile(spec)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read fingerprint profiles %q: %w", spec, err)
+	}
+	var profiles []*chromedphelper.FingerprintProfile
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '{' {
+		var profile chromedphelper.FingerprintProfile
+		err = json.Unmarshal(trimmed, &profile)
+		profiles = append(profiles, &profile)
+	} else {
+		err = json.Unmarshal(trimmed, &profiles)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse fingerprint profiles %q: %w", spec, err)
+	}
+	if len(profiles) == 0 {
+		return nil, fmt.Errorf("fingerprint profile file %q lists no profiles", spec)
+	}
+	for i, p := range profiles {
+		if p == nil {
+			return nil, fmt.Errorf("fingerprint profile %d of %q is null", i+1, spec)
+		}
+		if err := p.Validate(); err != nil {
+			return nil, fmt.Errorf("fingerprint profile %d of %q: %w", i+1, spec, err)
+		}
+	}
+	slog.Debug("Fingerprint profiles loaded", "file", spec, "profiles", len(profiles))
+	return &fingerprintSource{profiles: profiles}, nil
+}
+
+// nextProfile returns the profile for the next page load, or nil without
+// --fingerprint-profile.
+func (s *fingerprintSource) nextProfile() *chromedphelper.FingerprintProfile {
+	if s == nil {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.rng != nil {
+		return chromedphelper.RandomFingerprintProfile(s.rng)
+	}
+	p := s.profiles[s.next%len(s.profiles)]
+	s.next++
+	return p
+}
+
+// validateFingerprint checks that --fingerprint-profile is not combined with
+// the user agent of --device or --as-googlebot.
+func validateFingerprint(cfg *Config) error {
+	if cfg.FingerprintProfile != "" && cfg.Device != "" {
+		slog.Error("--fingerprint-profile specified with --device")
+		return fmt.Errorf("--fingerprint-profile and --device are mutually exclusive, use only one")
+	}
+	if cfg.AsGooglebot && cfg.FingerprintProfile != "" {
+		slog.Error("--as-googlebot specified with --fingerprint-profile")
+		return fmt.Errorf("--as-googlebot and --fingerprint-profile are mutually exclusive, use only one")
+	}
+	return nil
+}
@@ -0,0 +1,358 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	chromedphelper "github.com/pesarkhobeee/that-cli-web-toolbox/pkg/chromedp"
+)
+
+// Output formats accepted by flaky-check --format.
+const (
+	flakyFormatText = "text"
+	flakyFormatJSON = "json"
+)
+
+// Verdicts of a selector across the runs of flaky-check.
+const (
+	verdictStable  = "stable"
+	verdictFlaky   = "flaky"
+	verdictMissing = "missing"
+)
+
+type flakyConfig struct {
+	Runs      int
+	Selectors []string
+	Wait      time.Duration
+	Timeout   int
+	Delay     int
+	Format    string
+}
+
+var flakyCfg flakyConfig
+
+var flakyCheckCmd = &cobra.Command{
+	Use:   "flaky-check URL",
+	Short: "Load a page repeatedly and report how reliably selectors resolve",
+	Long: `Load a page several times, each time in a fresh browser context without
+cookies or cache, and report for every --selector how often an element
+matching it appeared, when it appeared and which values it had.
+
+The time a selector resolves is measured in the page, from the start of
+navigation until a matching element is inserted, so it is exact rather than
+bounded by polling. After the page loads, each run waits up to --wait for
+selectors that have not resolved yet. The value of a selector is the text,
+or the value of a form control, of the first matching element at the end of
+the run.
+
+A selector is stable when it resolved in every run that loaded, flaky when
+it resolved in some, and missing when it resolved in none. The exit code is
+0 when every selector is stable, 3 otherwise, and 2 when no run loaded the
+page. Use the timing distribution to pick wait strategies and timeouts for
+monitors, and the values to see whether the content itself varies.`,
+	Example: `  # How reliably does the price show up, and how long does it take?
+  that-cli-web-toolbox flaky-check --runs 10 --selector "#price" https://shop.example.com/item/42
+
+  # Two selectors, waiting up to 20s for each after load, as JSON
+  that-cli-web-toolbox flaky-check --runs 20 --selector "#price" --selector ".reviews" --wait 20s --format json https://shop.example.com/item/42`,
+	RunE: runFlakyCheck,
+	Args: cobra.ExactArgs(1),
+	// The report, not usage, is the output of a flaky selector
+	SilenceUsage:  true,
+	SilenceErrors: true,
+}
+
+func init() {
+	flakyCheckCmd.Flags().IntVar(&flakyCfg.Runs, "runs", 10, "Number of times to load the page")
+	flakyCheckCmd.Flags().StringArrayVar(&flakyCfg.Selectors, "selector", nil, "CSS selector to check (repeatable)")
+	flakyCheckCmd.Flags().DurationVar(&flakyCfg.Wait, "wait", 10*time.Second, "How long to wait after the page loads for selectors that have not resolved")
+	flakyCheckCmd.Flags().IntVarP(&flakyCfg.Timeout, "timeout", "t", 60, "Maximum time in seconds for each run")
+	flakyCheckCmd.Flags().IntVarP(&flakyCfg.Delay, "delay", "d", 0, "Delay in seconds after the page loads, before waiting for selectors")
+	flakyCheckCmd.Flags().StringVar(&flakyCfg.Format, "format", flakyFormatText, "Output format: text or json")
+	rootCmd.AddCommand(flakyCheckCmd)
+}
+
+// flakyReport is the outcome of flaky-check.
+type flakyReport struct {
+	URL string `json:"url"`
+	// Loaded counts the runs that loaded the page.
+	Loaded    int              `json:"loaded"`
+	LoadTime  *distribution    `json:"loadTimeMs,omitempty"`
+	Selectors []*flakySelector `json:"selectors"`
+	Runs      []*flakyRun      `json:"runs"`
+}
+
+// flakySelector sums up a selector across the runs that loaded.
+type flakySelector struct {
+	Selector string        `json:"selector"`
+	Verdict  string        `json:"verdict"`
+	Resolved int           `json:"resolved"`
+	Runs     int           `json:"runs"`
+	Timing   *distribution `json:"timingMs,omitempty"`
+	Values   []valueCount  `json:"values,omitempty"`
+	Error    string        `json:"error,omitempty"`
+}
+
+// valueCount is a value of a selector and the number of runs it had it.
+type valueCount struct {
+	Value string `json:"value"`
+	Count int    `json:"count"`
+}
+
+// flakyRun is one load of the page.
+type flakyRun struct {
+	Run        int                           `json:"run"`
+	LoadTimeMS int64                         `json:"loadTimeMs"`
+	Selectors  []chromedphelper.SelectorTime `json:"selectors,omitempty"`
+	Error      string                        `json:"error,omitempty"`
+}
+
+// distribution summarizes measurements in milliseconds.
+type distribution struct {
+	Min    float64 `json:"min"`
+	Median float64 `json:"median"`
+	P90    float64 `json:"p90"`
+	Max    float64 `json:"max"`
+	Mean   float64 `json:"mean"`
+	StdDev float64 `json:"stdDev"`
+}
+
+func runFlakyCheck(cmd *cobra.Command, args []string) error {
+	setupLogging(cfg.LogLevel)
+
+	if flakyCfg.Format != flakyFormatText && flakyCfg.Format != flakyFormatJSON {
+		return fmt.Errorf("unsupported --format %q (expected text or json)", flakyCfg.Format)
+	}
+	if flakyCfg.Runs < 1 {
+		return fmt.Errorf("--runs must be at least 1")
+	}
+	if len(flakyCfg.Selectors) == 0 {
+		return fmt.Errorf("at least one --selector must be specified")
+	}
+	if err := validateSelectors("--selector", flakyCfg.Selectors); err != nil {
+		return err
+	}
+	if flakyCfg.Wait < 0 {
+		return fmt.Errorf("--wait cannot be negative")
+	}
+	if flakyCfg.Timeout < 1 {
+		return fmt.Errorf("--timeout must be at least 1")
+	}
+	if flakyCfg.Delay < 0 {
+		return fmt.Errorf("--delay cannot be negative")
+	}
+	target, err := resolveTarget(args[0])
+	if err != nil {
+		return err
+	}
+
+	ctx, stopTracing, err := startTracing(cmd.Context())
+	if err != nil {
+		return err
+	}
+	defer stopTracing()
+
+	root, err := chromedphelper.InitializeChromedpContext(ctx, target, 0, flakyCfg.Delay, cfg.RemoteDebuggingPort, "", managedBrowserOptions("")...)
+	if err != nil {
+		slog.Error("Failed to initialize browser", "error", err)
+		return fmt.Errorf("failed to initialize browser: %w", err)
+	}
+	defer root.Cancel()
+	// Every run starts without the cookies, storage and cache of the others
+	root.IsolateTabs = true
+
+	report := &flakyReport{URL: target}
+	for i := 1; i <= flakyCfg.Runs; i++ {
+		slog.Info("Loading page", "run", i, "of", flakyCfg.Runs, "url", target)
+		run := flakyRunOnce(ctx, root, i)
+		if run.Error != "" {
+			slog.Warn("Run failed", "run", i, "error", run.Error)
+		}
+		report.Runs = append(report.Runs, run)
+	}
+	report.summarize(flakyCfg.Selectors)
+
+	if flakyCfg.Format == flakyFormatJSON {
+		if err := emitJSON(report); err != nil {
+			return err
+		}
+	} else {
+		fmt.Print(formatFlakyReport(report))
+	}
+
+	if report.Loaded == 0 {
+		return &exitError{code: exitNavigation, err: errReported}
+	}
+	for _, s := range report.Selectors {
+		if s.Verdict != verdictStable {
+			return &exitError{code: exitAssertion, err: errReported}
+		}
+	}
+	return nil
+}
+
+// flakyRunOnce loads the page in a new tab of root and reads when the
+// selectors resolved.
+func flakyRunOnce(ctx context.Context, root *chromedphelper.Browser, n int) *flakyRun {
+	run := &flakyRun{Run: n}
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(flakyCfg.Timeout)*time.Second)
+	defer cancel()
+
+	tab, err := root.NewTab(ctx)
+	if err != nil {
+		run.Error = err.Error()
+		return run
+	}
+	defer tab.Cancel()
+
+	if err := tab.WatchSelectors(ctx, flakyCfg.Selectors); err != nil {
+		run.Error = err.Error()
+		return run
+	}
+	start := time.Now()
+	if err := tab.NavigateAndPrepare(ctx); err != nil {
+		run.Error = err.Error()
+		return run
+	}
+	run.LoadTimeMS = time.Since(start).Milliseconds()
+	if run.Selectors, err = tab.SelectorTimes(ctx, flakyCfg.Selectors, flakyCfg.Wait); err != nil {
+		run.Error = err.Error()
+	}
+	return run
+}
+
+// summarize computes the load time and selector statistics of the runs
+// that loaded.
+func (r *flakyReport) summarize(selectors []string) {
+	var loadTimes []float64
+	for _, run := range r.Runs {
+		if run.Error == "" {
+			r.Loaded++
+			loadTimes = append(loadTimes, float64(run.LoadTimeMS))
+		}
+	}
+	r.LoadTime = newDistribution(loadTimes)
+
+	for i, selector := range selectors {
+		s := &flakySelector{Selector: selector, Runs: r.Loaded}
+		var times []float64
+		values := make(map[string]int)
+		for _, run := range r.Runs {
+			if run.Error != "" || i >= len(run.Selectors) {
+				continue
+			}
+			t := run.Selectors[i]
+			if t.Error != "" {
+				s.Error = t.Error
+			}
+			if !t.Found {
+				continue
+			}
+			s.Resolved++
+			times = append(times, t.At)
+			values[t.Value]++
+		}
+		s.Timing = newDistribution(times)
+		for value, count := range values {
+			s.Values = append(s.Values, valueCount{Value: value, Count: count})
+		}
+		sort.Slice(s.Values, func(a, b int) bool {
+			if s.Values[a].Count != s.Values[b].Count {
+				return s.Values[a].Count > s.Values[b].Count
+			}
+			return s.Values[a].Value < s.Values[b].Value
+		})
+		switch {
+		case s.Resolved == 0:
+			s.Verdict = verdictMissing
+		case s.Resolved < s.Runs:
+			s.Verdict = verdictFlaky
+		default:
+			s.Verdict = verdictStable
+		}
+		r.Selectors = append(r.Selectors, s)
+	}
+}
+
+// newDistribution summarizes values, or returns nil for none.
+func newDistribution(values []float64) *distribution {
+	if len(values) == 0 {
+		return nil
+	}
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+	// Nearest-rank percentiles
+	rank := func(p float64) float64 {
+		return sorted[max(int(math.Ceil(p*float64(len(sorted))))-1, 0)]
+	}
+	d := &distribution{Min: sorted[0], Median: rank(0.5), P90: rank(0.9), Max: sorted[len(sorted)-1]}
+	for _, v := range sorted {
+		d.Mean += v
+	}
+	d.Mean /= float64(len(sorted))
+	for _, v := range sorted {
+		d.StdDev += (v - d.Mean) * (v - d.Mean)
+	}
+	d.StdDev = math.Sqrt(d.StdDev / float64(len(sorted)))
+	return d
+}
+
+// formatFlakyReport renders the report, e.g.
+//
+//	https://example.com: 10 runs, 10 loaded, load time median 1.2s (min 1.05s, p90 1.4s, max 1.52s)
+//
+//	#price: FLAKY, resolved in 9 of 10 runs (90%)
+//	  appeared after median 830ms (min 700ms, p90 1.2s, max 1.4s, stddev 150ms)
+//	  values: "$19.99" ×7, "$21.99" ×2
+func formatFlakyReport(r *flakyReport) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%s: %d runs, %d loaded", r.URL, len(r.Runs), r.Loaded)
+	if r.LoadTime != nil {
+		fmt.Fprintf(&sb, ", load time %s", formatDistribution(r.LoadTime))
+	}
+	sb.WriteString("\n")
+	for _, run := range r.Runs {
+		if run.Error != "" {
+			fmt.Fprintf(&sb, "  run %d failed: %s\n", run.Run, run.Error)
+		}
+	}
+
+	for _, s := range r.Selectors {
+		sb.WriteString("\n")
+		fmt.Fprintf(&sb, "%s: %s, resolved in %d of %d runs", s.Selector, strings.ToUpper(s.Verdict), s.Resolved, s.Runs)
+		if s.Runs > 0 {
+			fmt.Fprintf(&sb, " (%.0f%%)", 100*float64(s.Resolved)/float64(s.Runs))
+		}
+		sb.WriteString("\n")
+		if s.Error != "" {
+			fmt.Fprintf(&sb, "  error: %s\n", s.Error)
+		}
+		if s.Timing != nil {
+			fmt.Fprintf(&sb, "  appeared after %s\n", formatDistribution(s.Timing))
+		}
+		if len(s.Values) > 0 {
+			values := make([]string, len(s.Values))
+			for i, v := range s.Values {
+				values[i] = fmt.Sprintf("%q ×%d", v.Value, v.Count)
+			}
+			fmt.Fprintf(&sb, "  values: %s\n", strings.Join(values, ", "))
+		}
+	}
+	return sb.String()
+}
+
+// formatDistribution renders d, e.g. "median 830ms (min 700ms, p90 1.2s,
+// max 1.4s, stddev 150ms)".
+func formatDistribution(d *distribution) string {
+	ms := func(v float64) time.Duration {
+		return time.Duration(v * float64(time.Millisecond)).Round(time.Millisecond)
+	}
+	return fmt.Sprintf("median %s (min %s, p90 %s, max %s, stddev %s)", ms(d.Median), ms(d.Min), ms(d.P90), ms(d.Max), ms(d.StdDev))
+}
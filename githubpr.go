@@ -0,0 +1,249 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/pesarkhobeee/that-cli-web-toolbox/pkg/github"
+)
+
+// Environment variables of --github-pr. GITHUB_API_URL and the variables
+// naming the workflow run are set by GitHub Actions.
+const (
+	githubTokenEnv  = "GITHUB_TOKEN"
+	githubAPIEnv    = "GITHUB_API_URL"
+	githubServerEnv = "GITHUB_SERVER_URL"
+	githubRepoEnv   = "GITHUB_REPOSITORY"
+	githubRunEnv    = "GITHUB_RUN_ID"
+)
+
+// githubThumbnailKinds are the kinds of artifacts shown as thumbnails in
+// the pull request comment.
+var githubThumbnailKinds = map[string]string{
+	"design-diff":   "Design diff",
+	"baseline-diff": "Baseline diff",
+}
+
+// githubReporter reports a run on a pull request for --github-pr: a
+// comment summarizing the outcome of every target and a commit status on
+// the head of the pull request.
+type githubReporter struct {
+	client  *github.Client
+	pr      github.PR
+	sha     string
+	context string
+	// artifactURL is the URL outputs are served from, if not where they
+	// were written.
+	artifactURL string
+	outcomes    *runOutcomes
+}
+
+// newGitHubReporter finds the head of the pull request and marks it
+// pending while the targets are checked, whose outcomes are collected in
+// outcomes.
+func newGitHubReporter(ctx context.Context, cfg *Config, outcomes *runOutcomes) (*githubReporter, error) {
+	pr, err := github.ParsePR(cfg.GitHubPR)
+	if err != nil {
+		return nil, fmt.Errorf("invalid --github-pr: %w", err)
+	}
+	if strings.TrimSpace(cfg.GitHubContext) == "" {
+		return nil, fmt.Errorf("--github-context cannot be empty")
+	}
+	token := os.Getenv(githubTokenEnv)
+	if token == "" {
+		return nil, fmt.Errorf("--github-pr requires a token with access to %s/%s in $%s", pr.Owner, pr.Repo, githubTokenEnv)
+	}
+	client := github.New(token)
+	if base := os.Getenv(githubAPIEnv); base != "" {
+		client.BaseURL = base
+	}
+	r := &githubReporter{
+		client:      client,
+		pr:          pr,
+		context:     cfg.GitHubContext,
+		artifactURL: strings.TrimSuffix(cfg.GitHubArtifactURL, "/"),
+		outcomes:    outcomes,
+	}
+	if r.sha, err = client.HeadSHA(ctx, pr); err != nil {
+		return nil, fmt.Errorf("failed to read pull request %s: %w", pr, err)
+	}
+	slog.Info("Reporting on pull request", "pr", pr, "commit", r.sha)
+	err = client.SetStatus(ctx, pr, r.sha, &github.Status{
+		State:       github.StatePending,
+		Context:     r.context,
+		Description: "Checking pages",
+		TargetURL:   workflowRunURL(),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to set commit status on %s: %w", pr, err)
+	}
+	return r, nil
+}
+
+// finish comments on the pull request and sets the commit status from err,
+// the outcome of the run. It returns err, or the failure to report when the
+// run itself succeeded, so a run that cannot be reported does not pass
+// silently.
+func (r *githubReporter) finish(ctx context.Context, err error) error {
+	// Report runs cut short by a signal too
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), time.Minute)
+	defer cancel()
+
+	results := r.outcomes.all()
+	failed := 0
+	for _, res := range results {
+		if res.Err != nil {
+			failed++
+		}
+	}
+	status := &github.Status{Context: r.context, TargetURL: workflowRunURL()}
+	switch {
+	case err == nil:
+		status.State = github.StateSuccess
+		status.Description = fmt.Sprintf("%d of %d pages passed", len(results), len(results))
+	case len(results) == 0:
+		// Nothing was checked, e.g. the browser did not start
+		status.State = github.StateError
+		status.Description = err.Error()
+	case failed == 0:
+		status.State = github.StateFailure
+		status.Description = err.Error()
+	default:
+		status.State = github.StateFailure
+		status.Description = fmt.Sprintf("%d of %d pages failed", failed, len(results))
+	}
+	// Longer descriptions are rejected
+	if d := []rune(status.Description); len(d) > 140 {
+		status.Description = string(d[:139]) + "…"
+	}
+
+	slog.Info("Commenting on pull request", "pr", r.pr, "state", status.State)
+	var reportErr error
+	commentURL, commentErr := r.client.UpsertComment(ctx, r.pr, r.marker(), r.comment(results, err))
+	if commentErr != nil {
+		reportErr = fmt.Errorf("failed to comment on %s: %w", r.pr, commentErr)
+	} else if status.TargetURL == "" {
+		status.TargetURL = commentURL
+	}
+	if statusErr := r.client.SetStatus(ctx, r.pr, r.sha, status); statusErr != nil && reportErr == nil {
+		reportErr = fmt.Errorf("failed to set commit status on %s: %w", r.pr, statusErr)
+	}
+	if reportErr != nil {
+		slog.Error("Failed to report on pull request", "pr", r.pr, "error", reportErr)
+		if err == nil {
+			return reportErr
+		}
+	}
+	return err
+}
+
+// marker identifies the comment of the context, which later runs edit.
+func (r *githubReporter) marker() string {
+	return fmt.Sprintf("<!-- that-cli-web-toolbox: %s -->", r.context)
+}
+
+// comment renders the Markdown summary of a run: a table of the targets
+// and thumbnails of their diffs.
+func (r *githubReporter) comment(results []batchResult, err error) string {
+	var b strings.Builder
+	b.WriteString(r.marker() + "\n")
+	failed := 0
+	for _, res := range results {
+		if res.Err != nil {
+			failed++
+		}
+	}
+	switch {
+	case err == nil:
+		fmt.Fprintf(&b, "### ✅ %s: %d of %d pages passed\n\n", r.context, len(results), len(results))
+	case failed > 0:
+		fmt.Fprintf(&b, "### ❌ %s: %d of %d pages failed\n\n", r.context, failed, len(results))
+	default:
+		fmt.Fprintf(&b, "### ❌ %s: the run failed\n\n%s\n\n", r.context, markdownCell(err.Error()))
+	}
+	fmt.Fprintf(&b, "Commit %s", r.sha)
+	if u := workflowRunURL(); u != "" {
+		fmt.Fprintf(&b, ", [workflow run](%s)", u)
+	}
+	b.WriteString("\n\n")
+
+	if len(results) > 0 {
+		b.WriteString("| | Page | Time | Details |\n| --- | --- | --- | --- |\n")
+		for _, res := range results {
+			icon := "✅"
+			if res.Err != nil {
+				icon = "❌"
+			}
+			fmt.Fprintf(&b, "| %s | %s | %s | %s |\n", icon, markdownCell(displayURL(res.Target)),
+				res.Duration.Round(100*time.Millisecond), markdownCell(strings.Join(outcomeDetails(res), "; ")))
+		}
+	}
+
+	var thumbnails, listed strings.Builder
+	for _, res := range results {
+		if res.Result == nil {
+			continue
+		}
+		for _, f := range res.Result.Files {
+			label, ok := githubThumbnailKinds[f.Kind]
+			if !ok {
+				continue
+			}
+			// Only files served somewhere the reviewer can open show as
+			// images; the others are left in the outputs of the run
+			if u := r.publicURL(f.Name, f.Location); u != "" {
+				fmt.Fprintf(&thumbnails, "**%s** of %s\n\n<a href=\"%s\"><img src=\"%s\" width=\"320\" alt=\"%s\"></a>\n\n",
+					label, markdownCell(displayURL(res.Target)), u, u, label)
+			} else {
+				fmt.Fprintf(&listed, "- %s of %s: `%s`\n", label, markdownCell(displayURL(res.Target)), f.Location)
+			}
+		}
+	}
+	if thumbnails.Len() > 0 || listed.Len() > 0 {
+		b.WriteString("\n<details open><summary>Diffs</summary>\n\n")
+		b.WriteString(thumbnails.String())
+		if listed.Len() > 0 {
+			where := "the outputs of the run"
+			if u := workflowRunURL(); u != "" {
+				where = fmt.Sprintf("the outputs of the [workflow run](%s), if uploaded as its artifacts", u)
+			}
+			fmt.Fprintf(&b, "Saved with %s:\n\n%s\n", where, listed.String())
+		}
+		b.WriteString("</details>\n")
+	}
+	return b.String()
+}
+
+// publicURL returns the URL a reviewer opens an output at: under
+// --github-artifact-url, or where it was uploaded over HTTP. It returns ""
+// for outputs only on the machine of the run, or in private storage.
+func (r *githubReporter) publicURL(name, location string) string {
+	if r.artifactURL != "" {
+		return r.artifactURL + "/" + url.PathEscape(name)
+	}
+	if strings.HasPrefix(location, "https://") || strings.HasPrefix(location, "http://") {
+		return location
+	}
+	return ""
+}
+
+// markdownCell escapes s for a cell of a Markdown table.
+func markdownCell(s string) string {
+	s = strings.ReplaceAll(s, "|", `\|`)
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// workflowRunURL returns the page of the GitHub Actions run, when run by
+// one.
+func workflowRunURL() string {
+	server, repo, run := os.Getenv(githubServerEnv), os.Getenv(githubRepoEnv), os.Getenv(githubRunEnv)
+	if server == "" || repo == "" || run == "" {
+		return ""
+	}
+	return fmt.Sprintf("%s/%s/actions/runs/%s", strings.TrimSuffix(server, "/"), repo, run)
+}
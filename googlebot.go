@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+
+	chromedphelper "github.com/pesarkhobeee/that-cli-web-toolbox/pkg/chromedp"
+)
+
+// compareGooglebotAction loads the page again as Googlebot in a second
+// tab for --compare-googlebot and reports what it is served differently
+// from users, with the differences that suggest cloaking.
+type compareGooglebotAction struct {
+	noopAction
+	image  []byte
+	format chromedphelper.ImageFormat
+}
+
+func (a *compareGooglebotAction) Name() string             { return "compare-googlebot" }
+func (a *compareGooglebotAction) Enabled(cfg *Config) bool { return cfg.CompareGooglebot }
+func (a *compareGooglebotAction) Flags() []string          { return []string{"--compare-googlebot"} }
+
+func (a *compareGooglebotAction) Execute(ctx context.Context, run *Run) error {
+	slog.Info("Comparing the page served to users and to Googlebot")
+	user, err := run.Browser.PageView(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to read the page: %w", err)
+	}
+
+	tab, err := run.Browser.GooglebotTab(ctx)
+	if err != nil {
+		return err
+	}
+	defer tab.Cancel()
+	if err := tab.NavigateAndPrepare(ctx); err != nil {
+		return fmt.Errorf("failed to load the page as Googlebot: %w", err)
+	}
+	bot, err := tab.PageView(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to read the page as Googlebot: %w", err)
+	}
+
+	// With --screenshot, the page served to Googlebot is captured alike
+	if run.Config.Screenshot {
+		opts := screenshotOptions(run.Config, chromedphelper.JPEG)
+		a.format = opts.Format
+		if run.Config.FullPage {
+			a.image, err = tab.ScreenshotFullPage(ctx, opts)
+		} else {
+			a.image, err = tab.ScreenshotViewport(ctx, opts)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to take screenshot as Googlebot: %w", err)
+		}
+	}
+
+	c := chromedphelper.CompareGooglebot(user, bot)
+	slog.Debug("Compared the page served to users and to Googlebot", "indicators", len(c.Indicators), "googlebotOnlyText", len(c.GooglebotOnly.Text), "userOnlyText", len(c.UserOnly.Text))
+	run.Result.Googlebot = c
+	return nil
+}
+
+func (a *compareGooglebotAction) Report(ctx context.Context, run *Run) error {
+	c := run.Result.Googlebot
+	stamp := timestamp()
+	if a.image != nil {
+		fileName := fmt.Sprintf("screenshot-googlebot_%s.%s", stamp, a.format.Extension())
+		if err := writeArtifact(ctx, run, "screenshot-googlebot", "", "Screenshot as Googlebot", fileName, a.format.ContentType(), a.image); err != nil {
+			return err
+		}
+	}
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode comparison: %w", err)
+	}
+	if err := writeArtifact(ctx, run, "googlebot", "", "Googlebot comparison", fmt.Sprintf("googlebot_%s.json", stamp), "application/json", data); err != nil {
+		return err
+	}
+
+	if run.Batch || structuredFormat(run.Config.OutputFormat) {
+		return nil
+	}
+	fmt.Printf("Users:     %s\n", formatPageView(c.User))
+	fmt.Printf("Googlebot: %s\n", formatPageView(c.Googlebot))
+	if len(c.Indicators) == 0 {
+		fmt.Println("No cloaking indicators")
+	} else {
+		fmt.Println("Cloaking indicators:")
+		for _, indicator := range c.Indicators {
+			fmt.Printf("  %s\n", indicator)
+		}
+	}
+	if !c.GooglebotOnly.Empty() {
+		fmt.Println("Only served to Googlebot:")
+		printContentDiff(c.GooglebotOnly)
+	}
+	if !c.UserOnly.Empty() {
+		fmt.Println("Only served to users:")
+		printContentDiff(c.UserOnly)
+	}
+	return nil
+}
+
+// formatPageView renders the status and content counts of v.
+func formatPageView(v *chromedphelper.PageView) string {
+	return fmt.Sprintf("status %d, %d headings, %d text blocks, %d links, %d images, %d words",
+		v.Status, v.Content.Headings, v.Content.Text, v.Content.Links, v.Content.Images, v.Content.Words)
+}
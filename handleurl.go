@@ -0,0 +1,653 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/pesarkhobeee/that-cli-web-toolbox/pkg/cache"
+	chromedphelper "github.com/pesarkhobeee/that-cli-web-toolbox/pkg/chromedp"
+)
+
+const (
+	// urlScheme is the protocol of the deep links handle-url handles.
+	urlScheme = "toolbox"
+	// handlerName is the name of the registered handler in desktop
+	// environments.
+	handlerName = "That CLI Web Toolbox"
+	// handlerID names the Linux desktop entry and the Windows file type.
+	handlerID = "that-cli-web-toolbox-handler"
+	// windowsProgID is the Windows file type opening HTML files with the
+	// handler.
+	windowsProgID = "ThatCliWebToolbox.Capture"
+)
+
+// Deep link actions.
+const (
+	linkScreenshot = "screenshot"
+	linkPDF        = "pdf"
+	linkText       = "text"
+)
+
+// linkParams lists the query parameters each deep link action accepts.
+var linkParams = map[string][]string{
+	linkScreenshot: {"url", "selector", "fullPage", "format", "quality", "viewport", "device", "darkMode"},
+	linkPDF:        {"url", "viewport", "device", "darkMode"},
+	linkText:       {"url", "selector", "viewport", "device", "darkMode"},
+}
+
+type handleURLConfig struct {
+	Register   bool
+	Unregister bool
+	OutputDir  string
+	NoOpen     bool
+	Timeout    int
+	Delay      int
+}
+
+var handleURLCfg handleURLConfig
+
+var handleURLCmd = &cobra.Command{
+	Use:   "handle-url [LINK|FILE]",
+	Short: "Handle toolbox:// deep links and opened HTML files from desktop apps",
+	Long: `Capture the page a toolbox:// deep link names and open the result in the
+default viewer, so other desktop applications, scripts, bookmarks and chat
+messages can start captures. With --register the command registers itself
+as the handler of toolbox:// links and as an "Open with" choice for HTML
+files for the current user; --unregister removes both.
+
+Links name an action and its parameters:
+  toolbox://screenshot?url=URL   screenshot (png by default)
+  toolbox://pdf?url=URL          PDF
+  toolbox://text?url=URL         body text, or the text of every element
+                                 matching selector
+
+Parameters (URL-encoded):
+  url        http(s) URL to load (required)
+  selector   element to capture (screenshot) or extract (text)
+  fullPage   capture the whole page, true or false (default true)
+  format     png, jpeg or webp
+  quality    1 to 100 for jpeg and webp (default 90)
+  viewport   WIDTHxHEIGHT, e.g. 1280x800
+  device     device preset, e.g. "iPhone 12"
+  darkMode   emulate prefers-color-scheme: dark, true or false
+
+Any web page can open a deep link, so links cannot load local files, run
+JavaScript or interaction steps, or send headers or cookies. A FILE given
+instead of a link, as when an HTML file is opened with the handler, is
+captured as a screenshot.
+
+Results are written to --output-dir. Errors are written there too and
+opened like results, since a handler started by the desktop has no
+terminal. The --output-dir, --timeout and --delay given with --register
+are used for every link.`,
+	Example: `  # Register the handler for the current user
+  that-cli-web-toolbox handle-url --register --output-dir ~/Captures
+
+  # Open a deep link, as a desktop application would
+  xdg-open 'toolbox://screenshot?url=https%3A%2F%2Fexample.com&device=iPhone%2012'
+
+  # Handle a link directly
+  that-cli-web-toolbox handle-url 'toolbox://pdf?url=https://example.com'`,
+	RunE: runHandleURL,
+	Args: cobra.MaximumNArgs(1),
+}
+
+func init() {
+	handleURLCmd.Flags().BoolVar(&handleURLCfg.Register, "register", false,
+		"Register as the handler of toolbox:// links and HTML files for the current user")
+	handleURLCmd.Flags().BoolVar(&handleURLCfg.Unregister, "unregister", false, "Remove the registration")
+	handleURLCmd.Flags().StringVar(&handleURLCfg.OutputDir, "output-dir", "",
+		"Directory results are written to (default: captures in the cache directory, see \"cache info\")")
+	handleURLCmd.Flags().BoolVar(&handleURLCfg.NoOpen, "no-open", false, "Print the path of the result instead of opening it")
+	handleURLCmd.Flags().IntVarP(&handleURLCfg.Timeout, "timeout", "t", 60, "Maximum time in seconds for the capture")
+	handleURLCmd.Flags().IntVarP(&handleURLCfg.Delay, "delay", "d", 2, "Delay in seconds to ensure rendering")
+	rootCmd.AddCommand(handleURLCmd)
+}
+
+// deepLink is a parsed toolbox:// link.
+type deepLink struct {
+	Action     string
+	URL        string
+	Selector   string
+	FullPage   bool
+	Screenshot chromedphelper.ScreenshotOptions
+	Emulation  *chromedphelper.Emulation
+}
+
+func runHandleURL(cmd *cobra.Command, args []string) error {
+	setupLogging(cfg.LogLevel)
+
+	switch {
+	case handleURLCfg.Register && handleURLCfg.Unregister:
+		return fmt.Errorf("--register and --unregister are mutually exclusive, use only one")
+	case handleURLCfg.Register || handleURLCfg.Unregister:
+		if len(args) > 0 {
+			return fmt.Errorf("--register and --unregister take no link")
+		}
+		if handleURLCfg.Register {
+			return registerHandler(cmd)
+		}
+		return unregisterHandler()
+	case len(args) == 0:
+		return fmt.Errorf("a toolbox:// link or a file is required")
+	}
+	if handleURLCfg.Timeout < 1 {
+		return fmt.Errorf("--timeout must be at least 1")
+	}
+	if handleURLCfg.Delay < 0 {
+		return fmt.Errorf("--delay cannot be negative")
+	}
+
+	dir, err := handlerOutputDir()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("failed to create output directory %q: %w", dir, err)
+	}
+
+	path, err := handleLink(cmd.Context(), args[0], dir)
+	if err != nil {
+		slog.Error("Failed to handle link", "link", args[0], "error", err)
+		// Show the error where the result would have appeared
+		errPath := filepath.Join(dir, fmt.Sprintf("error_%s.txt", timestamp()))
+		message := fmt.Sprintf("%s could not handle\n\n  %s\n\n%v\n", handlerName, args[0], err)
+		if writeErr := os.WriteFile(errPath, []byte(message), 0o644); writeErr == nil && !handleURLCfg.NoOpen {
+			if openErr := openWithDefaultApp(errPath); openErr != nil {
+				slog.Warn("Failed to open error report", "file", errPath, "error", openErr)
+			}
+		}
+		return err
+	}
+
+	if handleURLCfg.NoOpen {
+		fmt.Println(path)
+		return nil
+	}
+	slog.Debug("Opening result", "file", path)
+	if err := openWithDefaultApp(path); err != nil {
+		slog.Error("Failed to open result", "file", path, "error", err)
+		return fmt.Errorf("failed to open %q: %w", path, err)
+	}
+	return nil
+}
+
+// handlerOutputDir returns --output-dir or the default output directory.
+func handlerOutputDir() (string, error) {
+	if handleURLCfg.OutputDir != "" {
+		return filepath.Abs(handleURLCfg.OutputDir)
+	}
+	return toolCache().Path(cache.Captures), nil
+}
+
+// handleLink captures what arg names, a toolbox:// link or a local file,
+// into dir and returns the path of the result.
+func handleLink(ctx context.Context, arg, dir string) (string, error) {
+	var link *deepLink
+	if strings.HasPrefix(strings.ToLower(arg), urlScheme+":") {
+		var err error
+		if link, err = parseDeepLink(arg); err != nil {
+			return "", err
+		}
+	} else {
+		// Desktops pass opened files as paths or file:// URLs
+		path := arg
+		if u, err := url.Parse(arg); err == nil && u.Scheme == "file" {
+			path = u.Path
+		}
+		target, err := resolveTarget(path)
+		if err != nil {
+			return "", err
+		}
+		if !strings.HasPrefix(target, "file://") {
+			return "", fmt.Errorf("%q is neither a %s:// link nor an existing file", arg, urlScheme)
+		}
+		link = &deepLink{Action: linkScreenshot, URL: target, FullPage: true, Screenshot: chromedphelper.ScreenshotOptions{Format: chromedphelper.PNG, Quality: 90}}
+	}
+	slog.Info("Handling link", "action", link.Action, "url", link.URL)
+
+	b, err := chromedphelper.InitializeChromedpContext(ctx, link.URL, handleURLCfg.Timeout, handleURLCfg.Delay, cfg.RemoteDebuggingPort, "", managedBrowserOptions("")...)
+	if err != nil {
+		return "", fmt.Errorf("failed to initialize browser: %w", err)
+	}
+	defer b.Cancel()
+	b.Emulation = link.Emulation
+	if err := b.NavigateAndPrepare(ctx); err != nil {
+		return "", fmt.Errorf("failed to load page: %w", err)
+	}
+
+	var data []byte
+	ext := ""
+	switch link.Action {
+	case linkScreenshot:
+		switch {
+		case link.Selector != "":
+			data, err = b.ScreenshotElementAs(ctx, link.Selector, link.Screenshot)
+		case link.FullPage:
+			data, err = b.ScreenshotFullPage(ctx, link.Screenshot)
+		default:
+			data, err = b.ScreenshotViewport(ctx, link.Screenshot)
+		}
+		if err != nil {
+			return "", fmt.Errorf("failed to take screenshot: %w", err)
+		}
+		ext = link.Screenshot.Format.Extension()
+	case linkPDF:
+		if data, err = b.PrintToPDF(ctx); err != nil {
+			return "", fmt.Errorf("failed to print PDF: %w", err)
+		}
+		ext = "pdf"
+	case linkText:
+		var text string
+		if link.Selector != "" {
+			texts, err := b.GetTextsBySelector(ctx, link.Selector)
+			if err != nil {
+				return "", fmt.Errorf("failed to get text for selector %q: %w", link.Selector, err)
+			}
+			text = strings.Join(texts, "\n")
+		} else if text, err = b.GetBodyText(ctx); err != nil {
+			return "", fmt.Errorf("failed to get body text: %w", err)
+		}
+		data, ext = []byte(text+"\n"), "txt"
+	}
+
+	path := filepath.Join(dir, fmt.Sprintf("%s_%s_%s.%s", link.Action, slugify(link.URL), timestamp(), ext))
+	slog.Debug("Writing result", "file", path, "size", len(data))
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+// parseDeepLink parses and validates a toolbox:// link. Links may come
+// from any web page, so only remote pages and capture options are accepted.
+func parseDeepLink(raw string) (*deepLink, error) {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid link %q: %w", raw, err)
+	}
+	// toolbox://screenshot?..., but also toolbox:screenshot?... and
+	// toolbox:///screenshot?... as some launchers rewrite links
+	action := u.Host
+	if action == "" {
+		action = u.Opaque
+		if action == "" {
+			action = u.Path
+		}
+	}
+	action = strings.ToLower(strings.Trim(action, "/"))
+	allowed, ok := linkParams[action]
+	if !ok {
+		return nil, fmt.Errorf("unsupported link action %q (expected screenshot, pdf or text)", action)
+	}
+
+	query := u.Query()
+	for name := range query {
+		if !containsString(allowed, name) {
+			return nil, fmt.Errorf("unsupported parameter %q for %s links (expected %s)", name, action, strings.Join(allowed, ", "))
+		}
+	}
+
+	link := &deepLink{
+		Action:     action,
+		URL:        query.Get("url"),
+		Selector:   query.Get("selector"),
+		FullPage:   true,
+		Screenshot: chromedphelper.ScreenshotOptions{Format: chromedphelper.PNG, Quality: 90},
+	}
+	target, err := url.Parse(link.URL)
+	if err != nil || (target.Scheme != "http" && target.Scheme != "https") || target.Host == "" {
+		return nil, fmt.Errorf("url must be an absolute http or https URL, got %q", link.URL)
+	}
+	if v := query.Get("fullPage"); v != "" {
+		if link.FullPage, err = strconv.ParseBool(v); err != nil {
+			return nil, fmt.Errorf("invalid fullPage %q (expected true or false)", v)
+		}
+	}
+	if v := query.Get("format"); v != "" {
+		if link.Screenshot.Format, err = chromedphelper.ParseImageFormat(v); err != nil {
+			return nil, err
+		}
+	}
+	if v := query.Get("quality"); v != "" {
+		if link.Screenshot.Quality, err = strconv.Atoi(v); err != nil || link.Screenshot.Quality < 1 || link.Screenshot.Quality > 100 {
+			return nil, fmt.Errorf("invalid quality %q (expected 1 to 100)", v)
+		}
+	}
+	darkMode := false
+	if v := query.Get("darkMode"); v != "" {
+		if darkMode, err = strconv.ParseBool(v); err != nil {
+			return nil, fmt.Errorf("invalid darkMode %q (expected true or false)", v)
+		}
+	}
+	if link.Emulation, err = parseEmulation(&Config{Viewport: query.Get("viewport"), Device: query.Get("device"), DarkMode: darkMode}); err != nil {
+		return nil, err
+	}
+	return link, nil
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// openWithDefaultApp opens path in the application the desktop associates
+// with its type.
+func openWithDefaultApp(path string) error {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		cmd = exec.Command("open", path)
+	case "windows":
+		cmd = exec.Command("rundll32", "url.dll,FileProtocolHandler", path)
+	default:
+		cmd = exec.Command("xdg-open", path)
+	}
+	return runQuiet(cmd)
+}
+
+// runQuiet runs cmd and includes its output in the error when it fails.
+func runQuiet(cmd *exec.Cmd) error {
+	slog.Debug("Running command", "args", cmd.Args)
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		if msg := strings.TrimSpace(string(out)); msg != "" {
+			return fmt.Errorf("%s: %w: %s", filepath.Base(cmd.Path), err, msg)
+		}
+		return fmt.Errorf("%s: %w", filepath.Base(cmd.Path), err)
+	}
+	return nil
+}
+
+// handlerCommand returns the command line the desktop runs for a link or
+// file, before the link itself: this executable with the handle-url flags
+// given at registration.
+func handlerCommand(cmd *cobra.Command) ([]string, error) {
+	exe, err := os.Executable()
+	if err != nil {
+		return nil, fmt.Errorf("failed to locate the executable: %w", err)
+	}
+	if resolved, err := filepath.EvalSymlinks(exe); err == nil {
+		exe = resolved
+	}
+	command := []string{exe, "handle-url"}
+	if handleURLCfg.OutputDir != "" {
+		dir, err := filepath.Abs(handleURLCfg.OutputDir)
+		if err != nil {
+			return nil, err
+		}
+		command = append(command, "--output-dir", dir)
+	}
+	for _, name := range []string{"timeout", "delay", "loglevel", "remote-debugging-port", "cache-dir"} {
+		if f := cmd.Flags().Lookup(name); f != nil && f.Changed {
+			command = append(command, "--"+name, f.Value.String())
+		}
+	}
+	return command, nil
+}
+
+func registerHandler(cmd *cobra.Command) error {
+	command, err := handlerCommand(cmd)
+	if err != nil {
+		return err
+	}
+	switch runtime.GOOS {
+	case "linux", "freebsd", "openbsd", "netbsd":
+		err = registerXDG(command)
+	case "windows":
+		err = registerWindows(command)
+	case "darwin":
+		err = registerMacOS(command)
+	default:
+		err = fmt.Errorf("registering a URL handler is not supported on %s", runtime.GOOS)
+	}
+	if err != nil {
+		slog.Error("Failed to register handler", "error", err)
+		return fmt.Errorf("failed to register the %s:// handler: %w", urlScheme, err)
+	}
+	fmt.Printf("Registered %s as the handler of %s:// links\n", command[0], urlScheme)
+	return nil
+}
+
+func unregisterHandler() error {
+	var err error
+	switch runtime.GOOS {
+	case "linux", "freebsd", "openbsd", "netbsd":
+		err = unregisterXDG()
+	case "windows":
+		err = unregisterWindows()
+	case "darwin":
+		err = unregisterMacOS()
+	default:
+		err = fmt.Errorf("registering a URL handler is not supported on %s", runtime.GOOS)
+	}
+	if err != nil {
+		slog.Error("Failed to unregister handler", "error", err)
+		return fmt.Errorf("failed to unregister the %s:// handler: %w", urlScheme, err)
+	}
+	fmt.Printf("Unregistered the %s:// handler\n", urlScheme)
+	return nil
+}
+
+// xdgApplicationsDir returns the directory of the user's desktop entries.
+func xdgApplicationsDir() (string, error) {
+	data := os.Getenv("XDG_DATA_HOME")
+	if data == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		data = filepath.Join(home, ".local", "share")
+	}
+	return filepath.Join(data, "applications"), nil
+}
+
+// registerXDG installs a desktop entry for the toolbox URL scheme and HTML
+// files and makes it the default for the scheme.
+func registerXDG(command []string) error {
+	dir, err := xdgApplicationsDir()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	quoted := make([]string, len(command))
+	for i, arg := range command {
+		quoted[i] = desktopQuote(arg)
+	}
+	entry := fmt.Sprintf(`[Desktop Entry]
+Type=Application
+Name=%s
+Comment=Capture web pages from %s:// links
+Exec=%s %%u
+Terminal=false
+NoDisplay=true
+MimeType=x-scheme-handler/%s;text/html;application/xhtml+xml;
+`, handlerName, urlScheme, strings.Join(quoted, " "), urlScheme)
+	path := filepath.Join(dir, handlerID+".desktop")
+	slog.Debug("Writing desktop entry", "file", path)
+	if err := os.WriteFile(path, []byte(entry), 0o644); err != nil {
+		return err
+	}
+
+	if err := runQuiet(exec.Command("xdg-mime", "default", handlerID+".desktop", "x-scheme-handler/"+urlScheme)); err != nil {
+		return fmt.Errorf("wrote %s but could not make it the default handler: %w", path, err)
+	}
+	updateDesktopDatabase(dir)
+	return nil
+}
+
+func unregisterXDG() error {
+	dir, err := xdgApplicationsDir()
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(filepath.Join(dir, handlerID+".desktop")); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	updateDesktopDatabase(dir)
+	return nil
+}
+
+// updateDesktopDatabase refreshes the MIME cache of dir, which not every
+// desktop needs; a missing tool is only logged.
+func updateDesktopDatabase(dir string) {
+	if err := runQuiet(exec.Command("update-desktop-database", dir)); err != nil {
+		slog.Debug("Could not update the desktop database", "dir", dir, "error", err)
+	}
+}
+
+// desktopQuote quotes arg for the Exec key of a desktop entry.
+func desktopQuote(arg string) string {
+	if arg != "" && !strings.ContainsAny(arg, " \t\n\"'\\><~|&;$*?#()`%=") {
+		return arg
+	}
+	r := strings.NewReplacer(`\`, `\\\\`, `"`, `\\"`, "`", "\\\\`", `$`, `\\$`, `%`, `%%`)
+	return `"` + r.Replace(arg) + `"`
+}
+
+// windowsClasses is the registry key of the current user's URL schemes and
+// file types.
+const windowsClasses = `HKCU\Software\Classes`
+
+// registerWindows registers the URL scheme and an "Open with" entry for
+// HTML files under the current user's registry classes.
+func registerWindows(command []string) error {
+	quoted := make([]string, 0, len(command)+1)
+	for _, arg := range command {
+		quoted = append(quoted, windowsArg(arg))
+	}
+	// The shell substitutes %1 into the command line, so it is quoted
+	// for paths with spaces
+	open := strings.Join(append(quoted, `"%1"`), " ")
+
+	scheme := windowsClasses + `\` + urlScheme
+	progID := windowsClasses + `\` + windowsProgID
+	for _, args := range [][]string{
+		{"add", scheme, "/ve", "/d", "URL:" + handlerName, "/f"},
+		{"add", scheme, "/v", "URL Protocol", "/d", "", "/f"},
+		{"add", scheme + `\shell\open\command`, "/ve", "/d", open, "/f"},
+		{"add", progID, "/ve", "/d", handlerName, "/f"},
+		{"add", progID + `\shell\open\command`, "/ve", "/d", open, "/f"},
+		{"add", windowsClasses + `\.html\OpenWithProgids`, "/v", windowsProgID, "/d", "", "/f"},
+		{"add", windowsClasses + `\.htm\OpenWithProgids`, "/v", windowsProgID, "/d", "", "/f"},
+	} {
+		if err := runQuiet(exec.Command("reg", args...)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func unregisterWindows() error {
+	for _, args := range [][]string{
+		{"delete", windowsClasses + `\` + urlScheme, "/f"},
+		{"delete", windowsClasses + `\` + windowsProgID, "/f"},
+		{"delete", windowsClasses + `\.html\OpenWithProgids`, "/v", windowsProgID, "/f"},
+		{"delete", windowsClasses + `\.htm\OpenWithProgids`, "/v", windowsProgID, "/f"},
+	} {
+		// Keys that were never added fail to delete, which is fine
+		if err := runQuiet(exec.Command("reg", args...)); err != nil {
+			slog.Debug("Could not delete registry entry", "args", args, "error", err)
+		}
+	}
+	return nil
+}
+
+// lsregister registers application bundles with Launch Services.
+const lsregister = "/System/Library/Frameworks/CoreServices.framework/Frameworks/LaunchServices.framework/Support/lsregister"
+
+// macOSApp returns the path of the handler application bundle.
+func macOSApp() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, "Applications", handlerName+".app"), nil
+}
+
+// registerMacOS builds an AppleScript application forwarding opened links
+// and files to command, since macOS delivers URLs only to application
+// bundles declaring the scheme, and registers it with Launch Services.
+func registerMacOS(command []string) error {
+	app, err := macOSApp()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(app), 0o755); err != nil {
+		return err
+	}
+	quoted := make([]string, len(command))
+	for i, arg := range command {
+		quoted[i] = "quoted form of " + appleScriptString(arg)
+	}
+	shell := strings.Join(quoted, ` & " " & `)
+	script := []string{
+		"on open location theURL",
+		"do shell script " + shell + ` & " " & quoted form of theURL`,
+		"end open location",
+		"on open theFiles",
+		"repeat with theFile in theFiles",
+		"do shell script " + shell + ` & " " & quoted form of POSIX path of theFile`,
+		"end repeat",
+		"end open",
+	}
+	args := []string{"-o", app}
+	for _, line := range script {
+		args = append(args, "-e", line)
+	}
+	if err := os.RemoveAll(app); err != nil {
+		return err
+	}
+	if err := runQuiet(exec.Command("osacompile", args...)); err != nil {
+		return err
+	}
+
+	plist := filepath.Join(app, "Contents", "Info.plist")
+	for _, entry := range []string{
+		"Add :CFBundleURLTypes array",
+		"Add :CFBundleURLTypes:0 dict",
+		"Add :CFBundleURLTypes:0:CFBundleURLName string " + handlerName,
+		"Add :CFBundleURLTypes:0:CFBundleURLSchemes array",
+		"Add :CFBundleURLTypes:0:CFBundleURLSchemes:0 string " + urlScheme,
+	} {
+		if err := runQuiet(exec.Command("/usr/libexec/PlistBuddy", "-c", entry, plist)); err != nil {
+			return err
+		}
+	}
+	return runQuiet(exec.Command(lsregister, "-f", app))
+}
+
+func unregisterMacOS() error {
+	app, err := macOSApp()
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(app); os.IsNotExist(err) {
+		return nil
+	}
+	if err := runQuiet(exec.Command(lsregister, "-u", app)); err != nil {
+		slog.Debug("Could not unregister application", "app", app, "error", err)
+	}
+	return os.RemoveAll(app)
+}
+
+// appleScriptString returns s as an AppleScript string literal.
+func appleScriptString(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
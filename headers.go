@@ -0,0 +1,138 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sync"
+
+	chromedphelper "github.com/pesarkhobeee/that-cli-web-toolbox/pkg/chromedp"
+	"github.com/pesarkhobeee/that-cli-web-toolbox/pkg/events"
+)
+
+// headerAction evaluates --assert-header rules against the response headers
+// of the page's document, and of the subresources matching
+// --assert-header-match, failing the page when any of them does not hold.
+type headerAction struct {
+	noopAction
+
+	rules []chromedphelper.HeaderRule
+	match *regexp.Regexp
+
+	mu        sync.Mutex
+	responses []events.RequestFinished
+}
+
+func (a *headerAction) Name() string             { return "headers" }
+func (a *headerAction) Enabled(cfg *Config) bool { return len(cfg.AssertHeaders) > 0 }
+func (a *headerAction) Flags() []string          { return []string{"--assert-header"} }
+
+func (a *headerAction) Validate(cfg *Config) error {
+	for _, s := range cfg.AssertHeaders {
+		rule, err := chromedphelper.ParseHeaderRule(s)
+		if err != nil {
+			return err
+		}
+		a.rules = append(a.rules, rule)
+	}
+	if cfg.AssertHeaderMatch != "" {
+		re, err := regexp.Compile(cfg.AssertHeaderMatch)
+		if err != nil {
+			return fmt.Errorf("invalid --assert-header-match regexp: %w", err)
+		}
+		a.match = re
+	}
+	return nil
+}
+
+func (a *headerAction) Prepare(ctx context.Context, run *Run) error {
+	// Responses must be recorded from the start of navigation
+	stream := run.Browser.Events()
+	go func() {
+		for ev := range stream {
+			req, ok := ev.(events.RequestFinished)
+			if !ok || req.Failed {
+				continue
+			}
+			a.mu.Lock()
+			a.responses = append(a.responses, req)
+			a.mu.Unlock()
+		}
+	}()
+	return nil
+}
+
+func (a *headerAction) Execute(ctx context.Context, run *Run) error {
+	meta, err := run.Browser.GetPageMetadata(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get page metadata: %w", err)
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	// The document is the last response for the page's final URL, after
+	// any HTTP redirects
+	var document *events.RequestFinished
+	for i, req := range a.responses {
+		if req.ResourceType == "Document" && normalizeURL(req.URL) == normalizeURL(meta.URL) {
+			document = &a.responses[i]
+		}
+	}
+	checked := []events.RequestFinished{{URL: meta.URL}}
+	if document != nil {
+		checked[0] = *document
+	}
+	if a.match != nil {
+		for _, req := range a.responses {
+			if (document != nil && req.RequestID == document.RequestID) || !a.match.MatchString(req.URL) {
+				continue
+			}
+			checked = append(checked, req)
+		}
+	}
+
+	var checks []chromedphelper.HeaderCheck
+	for _, req := range checked {
+		for _, rule := range a.rules {
+			checks = append(checks, rule.Check(req.URL, req.ResponseHeaders))
+		}
+	}
+	run.Result.Headers = checks
+	return nil
+}
+
+func (a *headerAction) Report(ctx context.Context, run *Run) error {
+	checks := run.Result.Headers
+	failed := chromedphelper.HeaderFailures(checks)
+	// Batch runs list the outcome in the summary instead
+	if !run.Batch && !structuredFormat(run.Config.OutputFormat) {
+		fmt.Printf("Header assertions: %s\n", formatHeaderChecks(checks))
+		url := ""
+		for _, c := range checks {
+			if c.URL != url {
+				url = c.URL
+				fmt.Printf("  %s\n", url)
+			}
+			status := "PASS"
+			if !c.Passed {
+				status = "FAIL"
+			}
+			fmt.Printf("    %s %s (got %s)\n", status, c.Rule, c.Actual)
+		}
+	}
+	if failed > 0 {
+		return &exitError{code: exitAssertion, err: fmt.Errorf("%d of %d header assertions failed", failed, len(checks))}
+	}
+	return nil
+}
+
+// formatHeaderChecks renders the outcome of checks for the batch summary,
+// e.g. "7 passed, 1 failed on 4 response(s)".
+func formatHeaderChecks(checks []chromedphelper.HeaderCheck) string {
+	failed := chromedphelper.HeaderFailures(checks)
+	responses := make(map[string]bool)
+	for _, c := range checks {
+		responses[c.URL] = true
+	}
+	return fmt.Sprintf("%d passed, %d failed on %d response(s)", len(checks)-failed, failed, len(responses))
+}
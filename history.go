@@ -0,0 +1,357 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/pesarkhobeee/that-cli-web-toolbox/pkg/runstore"
+	"github.com/pesarkhobeee/that-cli-web-toolbox/pkg/textdiff"
+)
+
+// historyDB returns the database of a sqlite: --sink, which pages are
+// recorded in, or "".
+func historyDB(cfg *Config) string {
+	path, ok := strings.CutPrefix(cfg.Sink, "sqlite:")
+	if !ok {
+		return ""
+	}
+	return path
+}
+
+// historyAction records the title and visible text of the page when
+// outputs go to a SQLite database, for history diff. The run is stored
+// when it ends, with the checksum of its screenshot, by recordHistory.
+type historyAction struct{ noopAction }
+
+func (a *historyAction) Name() string             { return "history" }
+func (a *historyAction) Enabled(cfg *Config) bool { return historyDB(cfg) != "" }
+func (a *historyAction) Flags() []string          { return []string{"--sink sqlite:PATH"} }
+
+func (a *historyAction) Execute(ctx context.Context, run *Run) error {
+	slog.Info("Recording page for history")
+	title := ""
+	if run.Result.Page != nil {
+		title = run.Result.Page.Title
+	} else {
+		meta, err := run.Browser.GetPageMetadata(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to get page metadata: %w", err)
+		}
+		title = meta.Title
+	}
+	text, err := run.Browser.GetBodyText(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get body text: %w", err)
+	}
+	run.History = &runstore.Run{URL: run.Result.Target, Time: time.Now().UTC(), Title: title, Content: text}
+	return nil
+}
+
+// recordHistory stores the pages of the targets that succeeded, with the
+// checksum of their first screenshot.
+func recordHistory(ctx context.Context, store *runstore.Store, outcomes []batchResult) {
+	for _, r := range outcomes {
+		if r.Err != nil || r.History == nil {
+			continue
+		}
+		run := *r.History
+		for _, f := range r.Result.Files {
+			if f.Kind == "screenshot" {
+				run.ScreenshotSHA256 = f.SHA256
+				break
+			}
+		}
+		if err := store.Record(ctx, run); err != nil {
+			slog.Warn("Failed to record page history", "target", r.Target, "error", err)
+		}
+	}
+}
+
+type historyConfig struct {
+	DB       string
+	URL      string
+	From     string
+	To       string
+	Format   string
+	Context  int
+	WordDiff bool
+	Color    string
+}
+
+var historyCfg historyConfig
+
+var historyCmd = &cobra.Command{
+	Use:   "history",
+	Short: "Show how pages changed across the runs stored in a SQLite database",
+	Long: `Work with the runs recorded in the SQLite database of --sink sqlite:PATH.
+
+Every page rendered in Chrome with a sqlite: sink is recorded in the runs
+table of its database, next to its outputs: its title, its visible text and
+the SHA-256 of its screenshot, if one was taken.`,
+	Example: `  # Capture daily, then see what changed on the pricing page in January
+  that-cli-web-toolbox --screenshot --sink sqlite:captures.db https://example.com/pricing
+  that-cli-web-toolbox history diff --db captures.db --url https://example.com/pricing --from 2024-01-01 --to 2024-01-31`,
+}
+
+var historyDiffCmd = &cobra.Command{
+	Use:   "diff",
+	Short: "Report how the title, text and screenshot of a page changed between runs",
+	Long: `List the stored runs of --url from --from to --to, each compared with the
+run before it: a changed title, the lines of text deleted and inserted, as
+a unified diff, and a changed screenshot checksum. The first run shows what
+the page started with.
+
+--from and --to are dates or times in the local zone, such as 2024-01-01
+or 2024-01-01T09:00, or RFC 3339 times. A date --to includes that day.
+Without them, every run of the URL is listed.`,
+	Example: `  # The changelog of a page in January
+  that-cli-web-toolbox history diff --db captures.db --url https://example.com/pricing --from 2024-01-01 --to 2024-01-31
+
+  # As JSON, for an audit trail
+  that-cli-web-toolbox history diff --db captures.db --url https://example.com/pricing --format json`,
+	RunE:          runHistoryDiff,
+	Args:          cobra.NoArgs,
+	SilenceUsage:  true,
+	SilenceErrors: true,
+}
+
+func init() {
+	historyCmd.PersistentFlags().StringVar(&historyCfg.DB, "db", "", "SQLite database, as given to --sink sqlite:PATH")
+	historyDiffCmd.Flags().StringVar(&historyCfg.URL, "url", "", "URL of the page, as captured")
+	historyDiffCmd.Flags().StringVar(&historyCfg.From, "from", "", "Only runs from this date or time on, e.g. 2024-01-01")
+	historyDiffCmd.Flags().StringVar(&historyCfg.To, "to", "", "Only runs until this date or time, e.g. 2024-02-01")
+	historyDiffCmd.Flags().StringVar(&historyCfg.Format, "format", "text", "Output format: text or json")
+	historyDiffCmd.Flags().IntVarP(&historyCfg.Context, "context", "U", 3, "Number of unchanged lines shown around each change")
+	historyDiffCmd.Flags().BoolVar(&historyCfg.WordDiff, "word-diff", false, "Show changed lines once, with the deleted and inserted words marked within them")
+	historyDiffCmd.Flags().StringVar(&historyCfg.Color, "color", colorAuto, "Color the output: auto (on a terminal, unless NO_COLOR is set), always or never")
+	historyCmd.AddCommand(historyDiffCmd)
+	rootCmd.AddCommand(historyCmd)
+}
+
+// historyChange is how a page changed in a run since the run before it.
+type historyChange struct {
+	Time time.Time `json:"time"`
+	// First marks the first run listed, with the page as it was.
+	First      bool            `json:"first,omitempty"`
+	Title      *historyValue   `json:"title,omitempty"`
+	Screenshot *historyValue   `json:"screenshot,omitempty"`
+	Content    *historyContent `json:"content,omitempty"`
+}
+
+// historyValue is a value before and after a run. From is empty in the
+// first run.
+type historyValue struct {
+	From string `json:"from,omitempty"`
+	To   string `json:"to"`
+}
+
+// historyContent counts the lines of text a run deleted and inserted, the
+// first run inserting all of them, and holds their unified diff.
+type historyContent struct {
+	Deleted  int    `json:"deleted"`
+	Inserted int    `json:"inserted"`
+	Diff     string `json:"diff,omitempty"`
+}
+
+func runHistoryDiff(cmd *cobra.Command, args []string) error {
+	setupLogging(cfg.LogLevel)
+	if historyCfg.DB == "" {
+		return fmt.Errorf("--db is required")
+	}
+	if historyCfg.URL == "" {
+		return fmt.Errorf("--url is required")
+	}
+	if historyCfg.Format != "text" && historyCfg.Format != "json" {
+		return fmt.Errorf("unsupported --format %q (expected text or json)", historyCfg.Format)
+	}
+	if historyCfg.Color != colorAuto && historyCfg.Color != colorAlways && historyCfg.Color != colorNever {
+		return fmt.Errorf("unsupported --color %q (expected auto, always or never)", historyCfg.Color)
+	}
+	if historyCfg.Context < 0 {
+		return fmt.Errorf("--context cannot be negative")
+	}
+	var from, to time.Time
+	var err error
+	if historyCfg.From != "" {
+		if from, _, err = parseHistoryTime(historyCfg.From); err != nil {
+			return fmt.Errorf("invalid --from: %w", err)
+		}
+	}
+	if historyCfg.To != "" {
+		var day bool
+		if to, day, err = parseHistoryTime(historyCfg.To); err != nil {
+			return fmt.Errorf("invalid --to: %w", err)
+		}
+		if day {
+			to = to.AddDate(0, 0, 1)
+		} else {
+			// A time --to includes runs at that very time
+			to = to.Add(time.Nanosecond)
+		}
+	}
+	if !from.IsZero() && !to.IsZero() && !from.Before(to) {
+		return fmt.Errorf("--from %s is not before --to %s", historyCfg.From, historyCfg.To)
+	}
+	if _, err := os.Stat(historyCfg.DB); err != nil {
+		return fmt.Errorf("failed to open history: %w", err)
+	}
+	url, err := resolveTarget(historyCfg.URL)
+	if err != nil {
+		return err
+	}
+
+	store, err := runstore.Open(historyCfg.DB)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+	runs, err := store.Runs(cmd.Context(), url, from, to)
+	if err != nil {
+		return err
+	}
+	if len(runs) == 0 {
+		return fmt.Errorf("no runs of %s in %s%s", url, historyCfg.DB, historyPeriod(from, to))
+	}
+
+	opts := textdiff.Options{Context: historyCfg.Context, Words: historyCfg.WordDiff}
+	if historyCfg.Format == "text" {
+		opts.Color = useColor(historyCfg.Color)
+	}
+	changes, err := historyChanges(runs, opts)
+	if err != nil {
+		return err
+	}
+	if historyCfg.Format == "json" {
+		return emitJSON(changes)
+	}
+	fmt.Printf("Runs of %s%s: %d\n", url, historyPeriod(from, to), len(runs))
+	for _, c := range changes {
+		printHistoryChange(c)
+	}
+	return nil
+}
+
+// parseHistoryTime returns the time a --from or --to value names, and
+// whether it is a whole day.
+func parseHistoryTime(value string) (time.Time, bool, error) {
+	if t, err := time.ParseInLocation("2006-01-02", value, time.Local); err == nil {
+		return t, true, nil
+	}
+	for _, layout := range []string{"2006-01-02T15:04", "2006-01-02T15:04:05"} {
+		if t, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return t, false, nil
+		}
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, false, nil
+	}
+	return time.Time{}, false, fmt.Errorf("%q is not a date or time (expected e.g. 2024-01-01, 2024-01-01T09:00 or RFC 3339)", value)
+}
+
+// historyPeriod describes the period between from and to, an open end
+// being unset.
+func historyPeriod(from, to time.Time) string {
+	switch {
+	case !from.IsZero() && !to.IsZero():
+		return fmt.Sprintf(" from %s until %s", historyStamp(from), historyStamp(to))
+	case !from.IsZero():
+		return " since " + historyStamp(from)
+	case !to.IsZero():
+		return " until " + historyStamp(to)
+	}
+	return ""
+}
+
+// historyStamp renders t in the local zone.
+func historyStamp(t time.Time) string {
+	return t.Local().Format("2006-01-02 15:04:05")
+}
+
+// historyChanges compares every run with the one before it.
+func historyChanges(runs []runstore.Run, opts textdiff.Options) ([]historyChange, error) {
+	changes := make([]historyChange, 0, len(runs))
+	for i, run := range runs {
+		c := historyChange{Time: run.Time}
+		lines := splitLines(run.Content)
+		if i == 0 {
+			c.First = true
+			c.Title = &historyValue{To: run.Title}
+			c.Content = &historyContent{Inserted: len(lines)}
+			if run.ScreenshotSHA256 != "" {
+				c.Screenshot = &historyValue{To: run.ScreenshotSHA256}
+			}
+			changes = append(changes, c)
+			continue
+		}
+		prev := runs[i-1]
+		if run.Title != prev.Title {
+			c.Title = &historyValue{From: prev.Title, To: run.Title}
+		}
+		if run.ScreenshotSHA256 != prev.ScreenshotSHA256 {
+			c.Screenshot = &historyValue{From: prev.ScreenshotSHA256, To: run.ScreenshotSHA256}
+		}
+		prevLines := splitLines(prev.Content)
+		if deleted, inserted := textdiff.Stat(textdiff.Diff(prevLines, lines)); deleted > 0 || inserted > 0 {
+			var diff strings.Builder
+			if _, err := textdiff.Write(&diff, historyStamp(prev.Time), historyStamp(run.Time), prevLines, lines, opts); err != nil {
+				return nil, err
+			}
+			c.Content = &historyContent{Deleted: deleted, Inserted: inserted, Diff: diff.String()}
+		}
+		changes = append(changes, c)
+	}
+	return changes, nil
+}
+
+func printHistoryChange(c historyChange) {
+	fmt.Printf("\n%s", historyStamp(c.Time))
+	switch {
+	case c.First:
+		fmt.Println("  first run")
+	case c.Title == nil && c.Screenshot == nil && c.Content == nil:
+		fmt.Println("  unchanged")
+		return
+	default:
+		fmt.Println()
+	}
+	if c.Title != nil {
+		if c.First {
+			fmt.Printf("  Title: %q\n", c.Title.To)
+		} else {
+			fmt.Printf("  Title: %q -> %q\n", c.Title.From, c.Title.To)
+		}
+	}
+	if c.Screenshot != nil {
+		if c.First {
+			fmt.Printf("  Screenshot: %s\n", shortHash(c.Screenshot.To))
+		} else {
+			fmt.Printf("  Screenshot: %s -> %s\n", shortHash(c.Screenshot.From), shortHash(c.Screenshot.To))
+		}
+	}
+	if c.Content != nil {
+		if c.First {
+			fmt.Printf("  Content: %d lines\n", c.Content.Inserted)
+		} else {
+			fmt.Printf("  Content: +%d -%d lines\n", c.Content.Inserted, c.Content.Deleted)
+			fmt.Print(c.Content.Diff)
+		}
+	}
+}
+
+// shortHash abbreviates a checksum like git does, or renders a missing
+// one as "none".
+func shortHash(sum string) string {
+	if sum == "" {
+		return "none"
+	}
+	if len(sum) > 12 {
+		return sum[:12]
+	}
+	return sum
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"net/url"
+	"strings"
+
+	"github.com/pesarkhobeee/that-cli-web-toolbox/pkg/idn"
+)
+
+// idnPolicies lists the values of --idn-policy: allow ignores lookalike
+// hosts, warn logs them and block skips targets and fails redirects to
+// them.
+var idnPolicies = []string{"allow", "warn", "block"}
+
+// displayURL renders rawURL with an internationalized host in both forms,
+// e.g. "https://xn--bcher-kva.example/ (bücher.example)".
+func displayURL(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil || !idn.IsIDN(u.Hostname()) {
+		return rawURL
+	}
+	return fmt.Sprintf("%s (%s)", rawURL, idn.ToUnicode(u.Hostname()))
+}
+
+// homographReasons returns why the host of rawURL may impersonate another
+// host, or nil when --idn-policy is allow or the host looks safe.
+func homographReasons(rawURL string) []string {
+	if cfg.IDNPolicy == "allow" {
+		return nil
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil || !idn.IsIDN(u.Hostname()) {
+		return nil
+	}
+	return idn.Homograph(u.Hostname())
+}
+
+// idnTargetAllowed checks target against --idn-policy, warning about
+// lookalike hosts, and reports whether it may be loaded.
+func idnTargetAllowed(target string) bool {
+	reasons := homographReasons(target)
+	if reasons == nil {
+		return true
+	}
+	if cfg.IDNPolicy == "block" {
+		slog.Warn("Skipping target whose host may impersonate another, blocked by --idn-policy", "target", displayURL(target), "reasons", strings.Join(reasons, "; "))
+		return false
+	}
+	slog.Warn("Target host may impersonate another", "target", displayURL(target), "reasons", strings.Join(reasons, "; "))
+	return true
+}
+
+// validateIDNPolicy checks --idn-policy.
+func validateIDNPolicy(cfg *Config) error {
+	if !contains(idnPolicies, cfg.IDNPolicy) {
+		slog.Error("Invalid IDN policy", "policy", cfg.IDNPolicy)
+		return fmt.Errorf("invalid --idn-policy %q (expected one of %s)", cfg.IDNPolicy, strings.Join(idnPolicies, ", "))
+	}
+	return nil
+}
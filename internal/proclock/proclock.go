@@ -0,0 +1,117 @@
+// Package proclock coordinates processes of the tool sharing files: lock
+// files naming the process holding them, and a check whether a process
+// still runs, to tell what a killed process left behind from what is in
+// use.
+package proclock
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"runtime"
+	"syscall"
+	"time"
+)
+
+// StaleAge is the age after which a lock is broken whoever holds it, e.g.
+// a process on another host sharing the file, or one whose PID was reused.
+const StaleAge = 10 * time.Minute
+
+// holder is the content of a lock file.
+type holder struct {
+	PID     int       `json:"pid"`
+	Host    string    `json:"host"`
+	Started time.Time `json:"started"`
+}
+
+func newHolder() holder {
+	h := holder{PID: os.Getpid(), Started: time.Now().UTC()}
+	h.Host, _ = os.Hostname()
+	return h
+}
+
+// Lock creates the lock file path exclusively, waiting up to timeout while
+// another process holds it, and returns the function releasing it. The
+// lock names its holder, so that one left behind by a process that crashed
+// or was killed is broken rather than blocking every later one: when its
+// process no longer runs on this host, or it is older than StaleAge.
+func Lock(path string, timeout time.Duration) (func(), error) {
+	deadline := time.Now().Add(timeout)
+	for {
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
+		if err == nil {
+			if err := json.NewEncoder(f).Encode(newHolder()); err != nil {
+				slog.Warn("failed to write lock", "file", path, "error", err)
+			}
+			if err := f.Close(); err != nil {
+				slog.Warn("failed to close lock", "file", path, "error", err)
+			}
+			return func() {
+				if err := os.Remove(path); err != nil {
+					slog.Warn("failed to remove lock", "file", path, "error", err)
+				}
+			}, nil
+		}
+		if !errors.Is(err, os.ErrExist) {
+			return nil, err
+		}
+		if breakStale(path) {
+			continue
+		}
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("locked by %s; remove it if no other process is running", path)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+// breakStale removes the lock at path when its holder is gone and reports
+// whether it did. A lock that cannot be read yet, being written by its
+// holder, is judged by its age only.
+func breakStale(path string) bool {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return false
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return false
+	}
+	var h holder
+	stale := time.Since(info.ModTime()) > StaleAge
+	if !stale && json.Unmarshal(data, &h) == nil && h.PID > 0 {
+		host, _ := os.Hostname()
+		stale = h.Host == host && !Running(h.PID)
+	}
+	if !stale {
+		return false
+	}
+	// Only remove the lock that was judged, not one a faster process
+	// created after breaking it
+	if current, err := os.ReadFile(path); err != nil || !bytes.Equal(current, data) {
+		return false
+	}
+	if err := os.Remove(path); err != nil {
+		return false
+	}
+	slog.Warn("Broke lock left behind by a process that is gone", "file", path, "pid", h.PID, "started", h.Started)
+	return true
+}
+
+// Running reports whether a process with pid exists. On Windows, finding
+// it opens it and fails for processes that do not exist.
+func Running(pid int) bool {
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	defer p.Release()
+	if runtime.GOOS == "windows" {
+		return true
+	}
+	err = p.Signal(syscall.Signal(0))
+	return err == nil || errors.Is(err, syscall.EPERM)
+}
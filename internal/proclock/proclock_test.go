@@ -0,0 +1,93 @@
+package proclock
+
+import (
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// exitedPID returns the PID of a process that has exited.
+func exitedPID(t *testing.T) int {
+	t.Helper()
+	cmd := exec.Command(os.Args[0], "-test.run=^$")
+	if err := cmd.Run(); err != nil {
+		t.Fatal(err)
+	}
+	return cmd.Process.Pid
+}
+
+func writeHolder(t *testing.T, path string, h holder) {
+	t.Helper()
+	data, err := json.Marshal(h)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLock(t *testing.T) {
+	host, _ := os.Hostname()
+	tests := []struct {
+		name string
+		// held writes the lock found by Lock, if any
+		held    func(t *testing.T, path string)
+		wantErr bool
+	}{
+		{"free", func(*testing.T, string) {}, false},
+		{"held by a running process", func(t *testing.T, path string) {
+			writeHolder(t, path, holder{PID: os.Getpid(), Host: host, Started: time.Now()})
+		}, true},
+		{"left by an exited process", func(t *testing.T, path string) {
+			writeHolder(t, path, holder{PID: exitedPID(t), Host: host, Started: time.Now()})
+		}, false},
+		{"held on another host", func(t *testing.T, path string) {
+			writeHolder(t, path, holder{PID: exitedPID(t), Host: host + ".other", Started: time.Now()})
+		}, true},
+		{"older than StaleAge", func(t *testing.T, path string) {
+			writeHolder(t, path, holder{PID: os.Getpid(), Host: host})
+			old := time.Now().Add(-StaleAge - time.Minute)
+			if err := os.Chtimes(path, old, old); err != nil {
+				t.Fatal(err)
+			}
+		}, false},
+		{"unreadable content", func(t *testing.T, path string) {
+			if err := os.WriteFile(path, []byte("{"), 0o600); err != nil {
+				t.Fatal(err)
+			}
+		}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "file.lock")
+			tt.held(t, path)
+			unlock, err := Lock(path, 100*time.Millisecond)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Lock() error = %v, want error %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if _, err := Lock(path, 0); err == nil {
+				t.Error("a held lock was taken again")
+			}
+			unlock()
+			if _, err := os.Stat(path); !os.IsNotExist(err) {
+				t.Errorf("the released lock remains: %v", err)
+			}
+		})
+	}
+}
+
+func TestRunning(t *testing.T) {
+	if !Running(os.Getpid()) {
+		t.Error("the test process is reported as not running")
+	}
+	if pid := exitedPID(t); Running(pid) {
+		t.Errorf("exited process %d is reported as running", pid)
+	}
+}
@@ -0,0 +1,467 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+
+	"github.com/pesarkhobeee/that-cli-web-toolbox/pkg/miniyaml"
+)
+
+type runJobConfig struct {
+	List    bool
+	Crontab bool
+}
+
+var runJobCfg runJobConfig
+
+var runJobCmd = &cobra.Command{
+	Use:   "run-job NAME [ARG...]",
+	Short: "Run a job profile saved with --job-profile",
+	Long: `Run a named job profile: a complete capture configuration saved with
+--job-profile, so recurring jobs don't live in shell aliases.
+
+Profiles are config files (see "init") in the jobs directory of
+that-cli-web-toolbox in the user's config directory ($XDG_CONFIG_HOME or
+~/.config on Linux, ~/Library/Application Support on macOS, %AppData% on
+Windows). Save one by adding --job-profile NAME to a command line: its
+flags and targets are saved instead of run.
+
+Targets and flag values of a profile may hold placeholders {1}, {2}, ...,
+replaced by the arguments after NAME, e.g. a month. A profile without
+targets takes them from the arguments instead. Flags given to run-job,
+such as --loglevel, win over the profile's.
+
+A profile saved with --job-schedule lists a cron schedule; --crontab
+prints the crontab lines running the scheduled profiles.`,
+	Example: `  # Save the monthly invoice capture, the month left as {1}
+  that-cli-web-toolbox --job-profile invoices --job-schedule "0 6 1 * *" \
+    --printtopdf --cookies-file billing.json --sink file:invoices/{1} \
+    "https://billing.example.com/invoices?month={1}"
+
+  # Run it for June 2024
+  that-cli-web-toolbox run-job invoices 2024-06
+
+  # A profile without targets captures the pages given to it
+  that-cli-web-toolbox --job-profile mobile-shots --screenshot --device "iPhone 12"
+  that-cli-web-toolbox run-job mobile-shots https://example.com https://example.com/pricing
+
+  # List the profiles, and schedule them
+  that-cli-web-toolbox run-job --list
+  that-cli-web-toolbox run-job --crontab | crontab -`,
+	RunE: runJob,
+	// A job's failures are reported like those of the capture it runs
+	SilenceUsage:  true,
+	SilenceErrors: true,
+}
+
+func init() {
+	runJobCmd.Flags().BoolVar(&runJobCfg.List, "list", false, "List the job profiles with their schedules and targets")
+	runJobCmd.Flags().BoolVar(&runJobCfg.Crontab, "crontab", false, "Print crontab lines running the profiles saved with --job-schedule")
+	rootCmd.AddCommand(runJobCmd)
+}
+
+// jobProfile is a config file saved by --job-profile, with the schedule of
+// --job-schedule.
+type jobProfile struct {
+	captureConfig
+	Schedule string `json:"schedule,omitempty"`
+}
+
+var (
+	// jobName is a profile name usable as a file name.
+	jobName = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+	// jobPlaceholder is an argument placeholder of a profile, e.g. {1}.
+	jobPlaceholder = regexp.MustCompile(`\{([1-9][0-9]*)\}`)
+	// jobEnvRef is a reference to an environment variable, e.g. ${TOKEN},
+	// which stands for the secrets in the values of secretFlags.
+	jobEnvRef = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+	// cronSchedule is a crontab schedule: five fields or a nickname.
+	cronSchedule = regexp.MustCompile(`^(@(yearly|annually|monthly|weekly|daily|midnight|hourly)|[0-9A-Za-z*,/-]+( +[0-9A-Za-z*,/-]+){4})$`)
+)
+
+// jobProfileFlags are not saved in profiles: they save it, and --config
+// has been read into the other flags already.
+var jobProfileFlags = []string{"job-profile", "job-schedule", "config"}
+
+// jobsDir returns the directory of the job profiles.
+func jobsDir() (string, error) {
+	config, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(config, "that-cli-web-toolbox", "jobs"), nil
+}
+
+// jobProfilePath returns the file of the profile name.
+func jobProfilePath(name string) (string, error) {
+	if !jobName.MatchString(name) {
+		return "", fmt.Errorf("invalid job profile name %q (expected letters, digits, '.', '_' and '-')", name)
+	}
+	dir, err := jobsDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, name+".yaml"), nil
+}
+
+// loadJobProfile reads the profile name.
+func loadJobProfile(name string) (*jobProfile, error) {
+	path, err := jobProfilePath(name)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("no job profile %q (see run-job --list)", name)
+	}
+	if err != nil {
+		return nil, err
+	}
+	var p jobProfile
+	if err := miniyaml.Unmarshal(data, &p); err != nil {
+		return nil, fmt.Errorf("failed to read job profile %q: %w", name, err)
+	}
+	return &p, nil
+}
+
+// saveJobProfile saves the flags set on the command line and the targets
+// as the profile of --job-profile, instead of running them.
+func saveJobProfile(flags *pflag.FlagSet, targets []string) error {
+	if cfg.JobSchedule != "" && !cronSchedule.MatchString(cfg.JobSchedule) {
+		return fmt.Errorf("invalid --job-schedule %q (expected five crontab fields, e.g. \"0 6 1 * *\", or a nickname such as @daily)", cfg.JobSchedule)
+	}
+	path, err := jobProfilePath(cfg.JobProfile)
+	if err != nil {
+		return err
+	}
+	p := jobProfile{
+		captureConfig: captureConfig{Targets: targets, Flags: map[string]any{}},
+		Schedule:      cfg.JobSchedule,
+	}
+	var secretErr error
+	flags.Visit(func(f *pflag.Flag) {
+		if contains(jobProfileFlags, f.Name) {
+			return
+		}
+		value := flagConfigValue(f)
+		if secretFlags["--"+f.Name] {
+			for _, v := range stringValues(value) {
+				if !jobEnvRef.MatchString(v) && secretErr == nil {
+					secretErr = fmt.Errorf("--job-profile does not save the value of --%s, which may hold credentials: "+
+						"refer to an environment variable set when the job runs instead, e.g. --%s '${SECRET}'", f.Name, f.Name)
+				}
+			}
+		}
+		p.Flags[f.Name] = value
+	})
+	if secretErr != nil {
+		return secretErr
+	}
+	if len(p.Flags) == 0 {
+		return fmt.Errorf("--job-profile needs the flags of the job, e.g. --screenshot")
+	}
+
+	// Profiles may name where credentials come from; keep them private
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+	if err := os.Chmod(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, p.encode(), 0o600); err != nil {
+		return err
+	}
+	// WriteFile keeps the mode of a profile saved before
+	if err := os.Chmod(path, 0o600); err != nil {
+		return err
+	}
+	slog.Debug("Job profile saved", "name", cfg.JobProfile, "file", path, "flags", len(p.Flags), "targets", len(targets))
+	usage := "run-job " + cfg.JobProfile
+	for i := range p.arguments() {
+		usage += fmt.Sprintf(" ARG%d", i+1)
+	}
+	if len(targets) == 0 {
+		usage += " URL..."
+	}
+	fmt.Printf("Saved job profile %q to %s\n", cfg.JobProfile, path)
+	fmt.Printf("Run it with: that-cli-web-toolbox %s\n", usage)
+	return nil
+}
+
+// flagConfigValue returns the value of f to write to a config file.
+func flagConfigValue(f *pflag.Flag) any {
+	if s, ok := f.Value.(pflag.SliceValue); ok {
+		return s.GetSlice()
+	}
+	v := f.Value.String()
+	switch t := f.Value.Type(); {
+	case t == "bool":
+		if b, err := strconv.ParseBool(v); err == nil {
+			return b
+		}
+	case strings.HasPrefix(t, "int"), strings.HasPrefix(t, "uint"), strings.HasPrefix(t, "float"):
+		if n, err := strconv.ParseFloat(v, 64); err == nil {
+			return n
+		}
+	}
+	return v
+}
+
+// encode returns p as a config file.
+func (p *jobProfile) encode() []byte {
+	data := p.captureConfig.encode("Job profile saved by --job-profile, run with \"that-cli-web-toolbox run-job\".")
+	if p.Schedule != "" {
+		data = append(data, fmt.Sprintf("schedule: %s\n", strconv.Quote(p.Schedule))...)
+	}
+	return data
+}
+
+// arguments returns the placeholders of p's targets and flags, {1} to the
+// highest one used.
+func (p *jobProfile) arguments() []string {
+	highest := 0
+	visit := func(s string) {
+		for _, m := range jobPlaceholder.FindAllStringSubmatch(s, -1) {
+			if n, err := strconv.Atoi(m[1]); err == nil && n > highest {
+				highest = n
+			}
+		}
+	}
+	for _, t := range p.Targets {
+		visit(t)
+	}
+	for _, v := range p.Flags {
+		for _, s := range stringValues(v) {
+			visit(s)
+		}
+	}
+	args := make([]string, highest)
+	for i := range args {
+		args[i] = fmt.Sprintf("{%d}", i+1)
+	}
+	return args
+}
+
+// stringValues returns the strings of a flag value of a config file.
+func stringValues(v any) []string {
+	switch v := v.(type) {
+	case string:
+		return []string{v}
+	case []string:
+		return v
+	case []any:
+		var out []string
+		for _, item := range v {
+			out = append(out, stringValues(item)...)
+		}
+		return out
+	}
+	return nil
+}
+
+// expand returns p's config with the placeholders replaced by args, and
+// the ${NAME} references in the values of secretFlags by the environment,
+// and the targets to capture.
+func (p *jobProfile) expand(args []string) (*captureConfig, []string, error) {
+	if len(p.Targets) == 0 && len(p.arguments()) > 0 {
+		return nil, nil, fmt.Errorf("job profile has placeholders but no targets")
+	}
+	if want := len(p.arguments()); len(p.Targets) > 0 && len(args) != want {
+		return nil, nil, fmt.Errorf("job profile takes %d arguments, got %d", want, len(args))
+	}
+	replace := func(s string) string {
+		return jobPlaceholder.ReplaceAllStringFunc(s, func(m string) string {
+			n, _ := strconv.Atoi(m[1 : len(m)-1])
+			return args[n-1]
+		})
+	}
+	var expandValue func(v any) any
+	expandValue = func(v any) any {
+		switch v := v.(type) {
+		case string:
+			return replace(v)
+		case []any:
+			out := make([]any, len(v))
+			for i, item := range v {
+				out[i] = expandValue(item)
+			}
+			return out
+		}
+		return v
+	}
+	c := &captureConfig{Flags: make(map[string]any, len(p.Flags))}
+	for name, v := range p.Flags {
+		c.Flags[name] = expandValue(v)
+		if secretFlags["--"+name] {
+			var err error
+			if c.Flags[name], err = expandEnv(c.Flags[name]); err != nil {
+				return nil, nil, fmt.Errorf("flag %q: %w", name, err)
+			}
+		}
+	}
+	if len(p.Targets) == 0 {
+		return c, args, nil
+	}
+	targets := make([]string, len(p.Targets))
+	for i, t := range p.Targets {
+		targets[i] = replace(t)
+	}
+	return c, targets, nil
+}
+
+// expandEnv replaces the ${NAME} references in a flag value of a config
+// file by the environment variables they name, which must be set.
+func expandEnv(v any) (any, error) {
+	switch v := v.(type) {
+	case string:
+		var missing string
+		expanded := jobEnvRef.ReplaceAllStringFunc(v, func(m string) string {
+			name := m[2 : len(m)-1]
+			value, ok := os.LookupEnv(name)
+			if !ok && missing == "" {
+				missing = name
+			}
+			return value
+		})
+		if missing != "" {
+			return nil, fmt.Errorf("environment variable %s is not set", missing)
+		}
+		return expanded, nil
+	case []any:
+		out := make([]any, len(v))
+		for i, item := range v {
+			var err error
+			if out[i], err = expandEnv(item); err != nil {
+				return nil, err
+			}
+		}
+		return out, nil
+	}
+	return v, nil
+}
+
+func runJob(cmd *cobra.Command, args []string) error {
+	switch {
+	case runJobCfg.List && runJobCfg.Crontab:
+		setupLogging(cfg.LogLevel)
+		return fmt.Errorf("use --list or --crontab, not both")
+	case runJobCfg.List:
+		setupLogging(cfg.LogLevel)
+		return listJobProfiles()
+	case runJobCfg.Crontab:
+		setupLogging(cfg.LogLevel)
+		return printJobCrontab()
+	case len(args) == 0:
+		return fmt.Errorf("name the job profile to run, or use --list")
+	}
+
+	name := args[0]
+	p, err := loadJobProfile(name)
+	if err == nil {
+		var c *captureConfig
+		var targets []string
+		if c, targets, err = p.expand(args[1:]); err == nil {
+			args, err = c.apply(rootCmd.Flags(), targets)
+		}
+	}
+	if err != nil {
+		setupLogging(cfg.LogLevel)
+		slog.Error("Failed to load job profile", "name", name, "error", err)
+		return fmt.Errorf("job %q: %w", name, err)
+	}
+	rootCmd.SetContext(cmd.Context())
+	return runThatCliWebBrowser(rootCmd, args)
+}
+
+// jobProfileNames returns the names of the saved profiles, sorted.
+func jobProfileNames() (string, []string, error) {
+	dir, err := jobsDir()
+	if err != nil {
+		return "", nil, err
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return dir, nil, err
+	}
+	var names []string
+	for _, e := range entries {
+		if name, ok := strings.CutSuffix(e.Name(), ".yaml"); ok && !e.IsDir() && jobName.MatchString(name) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return dir, names, nil
+}
+
+func listJobProfiles() error {
+	dir, names, err := jobProfileNames()
+	if err != nil {
+		return fmt.Errorf("failed to list job profiles in %s: %w", dir, err)
+	}
+	if len(names) == 0 {
+		fmt.Printf("No job profiles in %s\n", dir)
+		return nil
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tARGUMENTS\tSCHEDULE\tTARGETS")
+	for _, name := range names {
+		p, err := loadJobProfile(name)
+		if err != nil {
+			slog.Warn("Skipping job profile", "name", name, "error", err)
+			continue
+		}
+		arguments := strings.Join(p.arguments(), " ")
+		targets := strings.Join(p.Targets, " ")
+		if len(p.Targets) == 0 {
+			arguments, targets = "URL...", "-"
+		}
+		schedule := p.Schedule
+		if schedule == "" {
+			schedule = "-"
+		}
+		if arguments == "" {
+			arguments = "-"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", name, arguments, schedule, targets)
+	}
+	return w.Flush()
+}
+
+func printJobCrontab() error {
+	dir, names, err := jobProfileNames()
+	if err != nil {
+		return fmt.Errorf("failed to list job profiles in %s: %w", dir, err)
+	}
+	exe, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	fmt.Println("# Job profiles of that-cli-web-toolbox, from " + dir)
+	for _, name := range names {
+		p, err := loadJobProfile(name)
+		if err != nil {
+			slog.Warn("Skipping job profile", "name", name, "error", err)
+			continue
+		}
+		switch {
+		case p.Schedule == "":
+		case len(p.Targets) == 0 || len(p.arguments()) > 0:
+			// Cron has no arguments to give them
+			fmt.Printf("# %s is scheduled %q but takes arguments\n", name, p.Schedule)
+		default:
+			fmt.Printf("%s %s run-job %s\n", p.Schedule, exe, name)
+		}
+	}
+	return nil
+}
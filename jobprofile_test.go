@@ -0,0 +1,143 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/spf13/pflag"
+)
+
+// jobFlags returns flags like the root command's, set to args.
+func jobFlags(t *testing.T, args ...string) *pflag.FlagSet {
+	t.Helper()
+	flags := pflag.NewFlagSet("test", pflag.ContinueOnError)
+	flags.Bool("screenshot", false, "")
+	flags.String("basic-auth", "", "")
+	flags.StringArray("header", nil, "")
+	if err := flags.Parse(args); err != nil {
+		t.Fatal(err)
+	}
+	return flags
+}
+
+// useJobsDir points the user's config directory to a temporary one and
+// returns the profile file of name in it.
+func useJobsDir(t *testing.T, name string) string {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("file modes are not enforced on Windows")
+	}
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(home, ".config"))
+	saved := cfg
+	t.Cleanup(func() { cfg = saved })
+	cfg.JobProfile = name
+	cfg.JobSchedule = ""
+	path, err := jobProfilePath(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestSaveJobProfileIsPrivate(t *testing.T) {
+	path := useJobsDir(t, "private")
+
+	// A profile saved before with a wider mode is tightened too
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte("flags: {}\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := saveJobProfile(jobFlags(t, "--screenshot", "--basic-auth", "${BILLING_AUTH}"), []string{"https://example.com"}); err != nil {
+		t.Fatal(err)
+	}
+	for file, want := range map[string]os.FileMode{filepath.Dir(path): 0o700, path: 0o600} {
+		info, err := os.Stat(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := info.Mode().Perm(); got != want {
+			t.Errorf("%s has mode %o, want %o", file, got, want)
+		}
+	}
+}
+
+func TestSaveJobProfileSecrets(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    []string
+		wantErr bool
+	}{
+		{"literal credentials", []string{"--basic-auth", "alice:hunter2"}, true},
+		{"literal header", []string{"--header", "Authorization: Bearer abc123"}, true},
+		{"one literal header of two", []string{"--header", "X-Token: ${TOKEN}", "--header", "Cookie: session=abc"}, true},
+		{"credentials reference", []string{"--basic-auth", "${BILLING_AUTH}"}, false},
+		{"header reference", []string{"--header", "Authorization: Bearer ${API_TOKEN}"}, false},
+		{"no secret flags", []string{"--screenshot"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := useJobsDir(t, "secrets")
+			err := saveJobProfile(jobFlags(t, tt.args...), nil)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("a profile with a literal secret was saved")
+				}
+				if _, statErr := os.Stat(path); !os.IsNotExist(statErr) {
+					t.Errorf("the refused profile was written: %v", statErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			data, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, arg := range tt.args {
+				if strings.Contains(arg, "${") && !strings.Contains(string(data), arg) {
+					t.Errorf("profile lacks the reference %q:\n%s", arg, data)
+				}
+			}
+		})
+	}
+}
+
+func TestJobProfileExpandsSecretReferences(t *testing.T) {
+	path := useJobsDir(t, "expand")
+	if err := saveJobProfile(jobFlags(t, "--basic-auth", "${BILLING_AUTH}", "--header", "X-Month: {1}; ${MONTH_TOKEN}"), []string{"https://example.com/{1}"}); err != nil {
+		t.Fatal(err)
+	}
+	p, err := loadJobProfile("expand")
+	if err != nil {
+		t.Fatalf("failed to load %s: %v", path, err)
+	}
+
+	t.Setenv("BILLING_AUTH", "alice:hunter2")
+	t.Setenv("MONTH_TOKEN", "t0k3n")
+	c, targets, err := p.expand([]string{"2024-06"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := c.Flags["basic-auth"]; got != "alice:hunter2" {
+		t.Errorf("basic-auth = %v, want the environment's value", got)
+	}
+	if got := stringValues(c.Flags["header"]); len(got) != 1 || got[0] != "X-Month: 2024-06; t0k3n" {
+		t.Errorf("header = %v, want the placeholder and reference replaced", got)
+	}
+	if len(targets) != 1 || targets[0] != "https://example.com/2024-06" {
+		t.Errorf("targets = %v", targets)
+	}
+
+	os.Unsetenv("BILLING_AUTH")
+	if _, _, err := p.expand([]string{"2024-06"}); err == nil || !strings.Contains(err.Error(), "BILLING_AUTH") {
+		t.Errorf("expanding without BILLING_AUTH set: %v, want an error naming it", err)
+	}
+}
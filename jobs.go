@@ -0,0 +1,415 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/pesarkhobeee/that-cli-web-toolbox/pkg/apiclient"
+	"github.com/pesarkhobeee/that-cli-web-toolbox/pkg/tracing"
+)
+
+const (
+	// callbackAttempts is how often a job's callback is tried.
+	callbackAttempts = 3
+	// callbackTimeout bounds one callback attempt.
+	callbackTimeout = 10 * time.Second
+)
+
+// Errors of POST /jobs and GET /jobs/{id}.
+var (
+	errQueueFull   = errors.New("too many unfinished jobs, retry later")
+	errJobNotFound = errors.New("no such job, or it has expired")
+	errJobPending  = errors.New("job has not finished yet")
+)
+
+// apiJob is a job of the queue and its result.
+type apiJob struct {
+	// key is the API key that submitted the job, nil without --api-keys.
+	key      *apiKey
+	callback string
+
+	mu     sync.Mutex
+	job    apiclient.Job
+	result []byte
+}
+
+// snapshot returns a copy of the job's state.
+func (j *apiJob) snapshot() apiclient.Job {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.job
+}
+
+// jobQueue runs the jobs of POST /jobs in the background and keeps finished
+// ones for a while, so long renders don't hold a connection open.
+type jobQueue struct {
+	// ctx is the context jobs run in; it is cancelled when the drain times
+	// out.
+	ctx context.Context
+	// max bounds the unfinished jobs; ttl is how long finished ones are
+	// kept; timeout bounds a job.
+	max     int
+	ttl     time.Duration
+	timeout time.Duration
+
+	mu         sync.Mutex
+	jobs       map[string]*apiJob
+	unfinished int
+	running    sync.WaitGroup
+}
+
+func newJobQueue(ctx context.Context, max int, ttl, timeout time.Duration) *jobQueue {
+	return &jobQueue{ctx: ctx, max: max, ttl: ttl, timeout: timeout, jobs: make(map[string]*apiJob)}
+}
+
+// add queues a job, or fails with errQueueFull.
+func (q *jobQueue) add(j *apiJob) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.expire()
+	if q.unfinished >= q.max {
+		return errQueueFull
+	}
+	q.jobs[j.job.ID] = j
+	q.unfinished++
+	q.running.Add(1)
+	return nil
+}
+
+// get returns the job with the given ID, if it was submitted with key by
+// tenant.
+func (q *jobQueue) get(id string, key *apiKey, tenant string) (*apiJob, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.expire()
+	j, ok := q.jobs[id]
+	// Other keys' and tenants' jobs are not revealed to exist
+	if !ok || j.key != key || j.job.Tenant != tenant {
+		return nil, errJobNotFound
+	}
+	return j, nil
+}
+
+// expire forgets finished jobs past their expiry. q.mu must be held.
+func (q *jobQueue) expire() {
+	now := time.Now()
+	for id, j := range q.jobs {
+		snap := j.snapshot()
+		if snap.Over() && now.After(snap.Expires) {
+			delete(q.jobs, id)
+		}
+	}
+}
+
+// finished uncounts a job that has finished.
+func (q *jobQueue) finished() {
+	q.mu.Lock()
+	q.unfinished--
+	q.mu.Unlock()
+	q.running.Done()
+}
+
+// wait waits for running jobs to finish, up to ctx.
+func (q *jobQueue) wait(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		q.running.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// submitJob queues the request in the body for the endpoint of its type
+// and responds with the job, 202 Accepted.
+func (s *apiServer) submitJob(w http.ResponseWriter, r *http.Request) {
+	if s.draining.Load() {
+		w.Header().Set("Connection", "close")
+		w.Header().Set("Retry-After", retryAfter)
+		writeAPIError(w, errDraining)
+		return
+	}
+	key, ok := s.authorize(w, r)
+	if !ok {
+		return
+	}
+
+	var body apiclient.JobRequest
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRequestBody))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&body); err != nil {
+		writeAPIError(w, badRequest{fmt.Errorf("invalid request body: %w", err)})
+		return
+	}
+	h, ok := s.handlers()[body.Type]
+	if !ok {
+		writeAPIError(w, badRequest{fmt.Errorf("type must be screenshot, pdf, extract or check, got %q", body.Type)})
+		return
+	}
+	if body.CallbackURL != "" {
+		u, err := url.Parse(body.CallbackURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			writeAPIError(w, badRequest{fmt.Errorf("callbackUrl must be an absolute http or https URL, got %q", body.CallbackURL)})
+			return
+		}
+	}
+	req := &apiRequest{Request: body.Request}
+	if err := req.validate(); err != nil {
+		writeAPIError(w, err)
+		return
+	}
+	tenant, err := s.tenants.tenant(r, key)
+	if err != nil {
+		writeAPIError(w, err)
+		return
+	}
+	req.tenant = tenant
+
+	id := make([]byte, 16)
+	if _, err = rand.Read(id); err != nil {
+		writeAPIError(w, err)
+		return
+	}
+	j := &apiJob{key: key, callback: body.CallbackURL, job: apiclient.Job{
+		ID:      hex.EncodeToString(id),
+		Type:    body.Type,
+		URL:     req.URL,
+		Tenant:  tenant,
+		Status:  apiclient.JobQueued,
+		Created: time.Now(),
+	}}
+	if err := s.jobs.add(j); err != nil {
+		slog.Warn("Job queue is full, turning a job away", "maxJobs", s.jobs.max)
+		w.Header().Set("Retry-After", retryAfter)
+		writeAPIError(w, err)
+		return
+	}
+	go s.runJob(j, req, h)
+
+	slog.Info("Job queued", "id", j.job.ID, "type", body.Type, "url", req.URL, "key", key.name(), "tenant", tenant)
+	data, err := json.Marshal(j.snapshot())
+	if err != nil {
+		writeAPIError(w, err)
+		return
+	}
+	w.Header().Set("Location", "/jobs/"+j.job.ID)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	if _, err := w.Write(data); err != nil {
+		slog.Warn("Failed to write response", "error", err)
+	}
+}
+
+// runJob runs h for req as the endpoint would, keeping its response as the
+// job's result, and reports the job to its callback.
+func (s *apiServer) runJob(j *apiJob, req *apiRequest, h handler) {
+	defer s.jobs.finished()
+	start := time.Now()
+	j.mu.Lock()
+	j.job.Status, j.job.Started = apiclient.JobRunning, start
+	j.mu.Unlock()
+
+	timeout := s.jobs.timeout
+	if req.Timeout > 0 && time.Duration(req.Timeout)*time.Second < timeout {
+		timeout = time.Duration(req.Timeout) * time.Second
+	}
+	ctx, cancel := context.WithTimeout(s.jobs.ctx, timeout)
+	defer cancel()
+	ctx, span := tracing.Start(ctx, "job "+j.job.Type, tracing.String("url.full", req.URL))
+	defer span.End()
+
+	rec := &jobRecorder{header: make(http.Header)}
+	err := s.serve(ctx, req, rec, h)
+	if err != nil {
+		span.RecordError(err)
+		slog.Error("Job failed", "id", j.job.ID, "type", j.job.Type, "url", req.URL, "key", j.key.name(), "tenant", req.tenant, "error", err)
+		rec.reset()
+		writeAPIError(rec, err)
+	} else {
+		slog.Info("Job completed", "id", j.job.ID, "type", j.job.Type, "url", req.URL, "key", j.key.name(), "tenant", req.tenant, "duration", time.Since(start).Round(time.Millisecond))
+	}
+	if j.key != nil {
+		j.key.record(time.Since(start), err != nil)
+	}
+
+	j.mu.Lock()
+	j.job.Finished = time.Now()
+	j.job.Expires = j.job.Finished.Add(s.jobs.ttl)
+	j.job.Status = apiclient.JobDone
+	if err != nil {
+		j.job.Status, j.job.Error = apiclient.JobFailed, err.Error()
+	}
+	j.job.StatusCode = rec.status()
+	j.job.ContentType = rec.header.Get("Content-Type")
+	j.job.Size = rec.body.Len()
+	j.job.Result = "/jobs/" + j.job.ID + "/result"
+	j.result = rec.body.Bytes()
+	j.mu.Unlock()
+
+	if j.callback != "" {
+		s.callback(j)
+	}
+}
+
+// callback posts the finished job to its callback URL, retrying with
+// backoff when the callback fails.
+func (s *apiServer) callback(j *apiJob) {
+	data, err := json.Marshal(j.snapshot())
+	if err != nil {
+		slog.Error("Failed to encode job for callback", "id", j.job.ID, "error", err)
+		return
+	}
+	for attempt := 1; ; attempt++ {
+		err = postCallback(s.jobs.ctx, j.callback, j.job.ID, data)
+		if err == nil {
+			slog.Debug("Job callback delivered", "id", j.job.ID, "callback", j.callback)
+			return
+		}
+		if attempt == callbackAttempts {
+			slog.Warn("Job callback failed, giving up", "id", j.job.ID, "callback", j.callback, "attempts", attempt, "error", err)
+			return
+		}
+		slog.Debug("Job callback failed, retrying", "id", j.job.ID, "callback", j.callback, "attempt", attempt, "error", err)
+		select {
+		case <-time.After(time.Duration(attempt) * time.Second):
+		case <-s.jobs.ctx.Done():
+			return
+		}
+	}
+}
+
+// postCallback sends the job's JSON to callback once.
+func postCallback(ctx context.Context, callback, id string, data []byte) error {
+	ctx, cancel := context.WithTimeout(ctx, callbackTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, callback, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Job-ID", id)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	if err := resp.Body.Close(); err != nil {
+		slog.Warn("failed to close response body", "error", err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("callback returned status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// getJob responds with the job of the ID in the path.
+func (s *apiServer) getJob(w http.ResponseWriter, r *http.Request) {
+	j, ok := s.lookupJob(w, r)
+	if !ok {
+		return
+	}
+	data, err := json.Marshal(j.snapshot())
+	if err != nil {
+		writeAPIError(w, err)
+		return
+	}
+	writeAPIData(w, "application/json", data)
+}
+
+// jobResult responds with the result of the finished job of the ID in the
+// path, as its endpoint would have.
+func (s *apiServer) jobResult(w http.ResponseWriter, r *http.Request) {
+	j, ok := s.lookupJob(w, r)
+	if !ok {
+		return
+	}
+	j.mu.Lock()
+	snap, result := j.job, j.result
+	j.mu.Unlock()
+	if !snap.Over() {
+		w.Header().Set("Retry-After", retryAfter)
+		writeAPIError(w, errJobPending)
+		return
+	}
+	w.Header().Set("Content-Type", snap.ContentType)
+	w.WriteHeader(snap.StatusCode)
+	if _, err := w.Write(result); err != nil {
+		slog.Warn("Failed to write response", "error", err)
+	}
+}
+
+// lookupJob authenticates r and finds the job of its path, writing the
+// error when either fails. Polling does not count against rate limits.
+func (s *apiServer) lookupJob(w http.ResponseWriter, r *http.Request) (*apiJob, bool) {
+	var key *apiKey
+	if s.keys != nil {
+		var err error
+		if key, err = s.keys.authenticate(r); err != nil {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="that-cli-web-toolbox"`)
+			writeAPIError(w, err)
+			return nil, false
+		}
+	}
+	tenant, err := s.tenants.tenant(r, key)
+	if err != nil {
+		writeAPIError(w, err)
+		return nil, false
+	}
+	j, err := s.jobs.get(r.PathValue("id"), key, tenant)
+	if err != nil {
+		writeAPIError(w, err)
+		return nil, false
+	}
+	return j, true
+}
+
+// jobRecorder is the http.ResponseWriter a job's handler writes its
+// response to.
+type jobRecorder struct {
+	header http.Header
+	code   int
+	body   bytes.Buffer
+}
+
+func (r *jobRecorder) Header() http.Header { return r.header }
+
+func (r *jobRecorder) Write(data []byte) (int, error) {
+	if r.code == 0 {
+		r.code = http.StatusOK
+	}
+	return r.body.Write(data)
+}
+
+func (r *jobRecorder) WriteHeader(code int) {
+	if r.code == 0 {
+		r.code = code
+	}
+}
+
+// status returns the status of the response, 200 if none was set.
+func (r *jobRecorder) status() int {
+	if r.code == 0 {
+		return http.StatusOK
+	}
+	return r.code
+}
+
+// reset discards what a failing handler wrote before failing.
+func (r *jobRecorder) reset() {
+	r.header = make(http.Header)
+	r.code = 0
+	r.body.Reset()
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	chromedphelper "github.com/pesarkhobeee/that-cli-web-toolbox/pkg/chromedp"
+	"github.com/pesarkhobeee/that-cli-web-toolbox/pkg/jsonpath"
+)
+
+// jsonActions are the actions that work on a JSON response, which is not
+// rendered; runPipeline skips the others for targets served as JSON.
+var jsonActions = []string{"consolelog", "jsonpath", "body", "save-cookies", "export-auth", "curl", "check", "headers", "network", "errors"}
+
+// jsonPathAction extracts values from targets served as JSON with each
+// --jsonpath expression.
+type jsonPathAction struct {
+	noopAction
+
+	paths []*jsonpath.Path
+}
+
+func (a *jsonPathAction) Name() string             { return "jsonpath" }
+func (a *jsonPathAction) Enabled(cfg *Config) bool { return len(cfg.JSONPaths) > 0 }
+func (a *jsonPathAction) Flags() []string          { return []string{"--jsonpath"} }
+
+func (a *jsonPathAction) Validate(cfg *Config) error {
+	for _, expr := range cfg.JSONPaths {
+		path, err := jsonpath.Compile(expr)
+		if err != nil {
+			return err
+		}
+		a.paths = append(a.paths, path)
+	}
+	return nil
+}
+
+func (a *jsonPathAction) Execute(ctx context.Context, run *Run) error {
+	// HTML pages of a mixed batch are left to the other actions
+	if run.JSON == nil {
+		slog.Info("Skipping --jsonpath, the target was not served as JSON", "target", run.Browser.TargetURL)
+		return nil
+	}
+	for _, path := range a.paths {
+		values, err := path.Select([]byte(*run.JSON))
+		if err != nil {
+			slog.Error("Failed to evaluate JSONPath", "path", path.String(), "error", err)
+			return fmt.Errorf("failed to evaluate JSONPath %q: %w", path, err)
+		}
+		slog.Debug("JSONPath evaluated", "path", path.String(), "values", len(values))
+		run.Result.JSONPath = append(run.Result.JSONPath, chromedphelper.JSONPathResult{
+			Path:   path.String(),
+			Values: values,
+		})
+	}
+	return nil
+}
+
+func (a *jsonPathAction) Report(ctx context.Context, run *Run) error {
+	results := run.Result.JSONPath
+	if len(results) == 1 {
+		return writeText(ctx, run, "jsonpath", formatJSONValues(results[0].Values))
+	}
+	// Label each output so multiple paths can be told apart
+	for i, r := range results {
+		text := fmt.Sprintf("== %s ==\n%s", r.Path, formatJSONValues(r.Values))
+		if err := writeText(ctx, run, fmt.Sprintf("jsonpath-%d", i+1), text); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// formatJSONValues renders one value per line: strings as plain text and
+// everything else as compact JSON.
+func formatJSONValues(values []json.RawMessage) string {
+	lines := make([]string, 0, len(values))
+	for _, v := range values {
+		var s string
+		if err := json.Unmarshal(v, &s); err == nil {
+			lines = append(lines, s)
+			continue
+		}
+		lines = append(lines, string(v))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// formatJSONPath renders the number of values selected for the batch
+// summary, e.g. "12 values from 2 paths".
+func formatJSONPath(results []chromedphelper.JSONPathResult) string {
+	values := 0
+	for _, r := range results {
+		values += len(r.Values)
+	}
+	return fmt.Sprintf("%d values from %d paths", values, len(results))
+}
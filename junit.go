@@ -0,0 +1,208 @@
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// junitKinds name the failures of targets by exit code, as the type of
+// their JUnit failure or error.
+var junitKinds = map[int]string{
+	exitFailure:    "error",
+	exitNavigation: "navigation",
+	exitAssertion:  "assertion",
+	exitTimeout:    "timeout",
+	exitPathology:  "pathology",
+	exitCrash:      "crash",
+	exitLimit:      "limit",
+	exitBrowser:    "browser",
+	exitSelector:   "selector",
+	exitPartial:    "partial",
+}
+
+// junitFailures are the exit codes of targets that loaded and did not
+// meet expectations, JUnit failures; the others are errors.
+var junitFailures = map[int]bool{
+	exitAssertion: true,
+	exitPathology: true,
+	exitLimit:     true,
+	exitSelector:  true,
+	exitPartial:   true,
+}
+
+type junitTestSuites struct {
+	XMLName  xml.Name         `xml:"testsuites"`
+	Name     string           `xml:"name,attr"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Errors   int              `xml:"errors,attr"`
+	Time     string           `xml:"time,attr"`
+	Suites   []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name      string          `xml:"name,attr"`
+	Tests     int             `xml:"tests,attr"`
+	Failures  int             `xml:"failures,attr"`
+	Errors    int             `xml:"errors,attr"`
+	Time      string          `xml:"time,attr"`
+	Timestamp string          `xml:"timestamp,attr"`
+	Hostname  string          `xml:"hostname,attr,omitempty"`
+	Cases     []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	Classname string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitProblem `xml:"failure,omitempty"`
+	Error     *junitProblem `xml:"error,omitempty"`
+	SystemOut string        `xml:"system-out,omitempty"`
+}
+
+type junitProblem struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// junitReport writes the outcomes of a run as JUnit XML for
+// --output-format junit, one test case per target, so CI servers show
+// them in their test reports.
+type junitReport struct {
+	start    time.Time
+	outcomes *runOutcomes
+}
+
+func newJUnitReport(outcomes *runOutcomes) *junitReport {
+	return &junitReport{start: time.Now(), outcomes: outcomes}
+}
+
+// write writes the report of a run that ended with err to stdout. It
+// returns err, or the failure to write the report when the run itself
+// succeeded.
+func (r *junitReport) write(err error) error {
+	suite := junitTestSuite{
+		Name:      "that-cli-web-toolbox",
+		Time:      junitSeconds(time.Since(r.start)),
+		Timestamp: r.start.UTC().Format("2006-01-02T15:04:05"),
+	}
+	suite.Hostname, _ = os.Hostname()
+	results := r.outcomes.all()
+	for _, res := range results {
+		suite.Cases = append(suite.Cases, junitCase(res))
+	}
+	// A run that failed before any target, e.g. without a browser, is one
+	// error, so the report does not look like an empty success
+	if len(results) == 0 && err != nil {
+		suite.Cases = append(suite.Cases, junitCase(batchResult{Target: "that-cli-web-toolbox", Err: err}))
+	}
+	for _, c := range suite.Cases {
+		switch {
+		case c.Failure != nil:
+			suite.Failures++
+		case c.Error != nil:
+			suite.Errors++
+		}
+	}
+	suite.Tests = len(suite.Cases)
+	doc := junitTestSuites{
+		Name:     suite.Name,
+		Tests:    suite.Tests,
+		Failures: suite.Failures,
+		Errors:   suite.Errors,
+		Time:     suite.Time,
+		Suites:   []junitTestSuite{suite},
+	}
+
+	data, marshalErr := xml.MarshalIndent(doc, "", "  ")
+	if marshalErr == nil {
+		stdoutMu.Lock()
+		_, marshalErr = os.Stdout.Write(append([]byte(xml.Header), append(data, '\n')...))
+		stdoutMu.Unlock()
+	}
+	if marshalErr != nil {
+		slog.Error("Failed to write JUnit report", "error", marshalErr)
+		if err == nil {
+			return fmt.Errorf("failed to write JUnit report: %w", marshalErr)
+		}
+	}
+	return err
+}
+
+// junitCase renders the outcome of a target as a test case named after it
+// and classed by its host, listing what was found, and its outputs as
+// attachments, on its standard output.
+func junitCase(r batchResult) junitTestCase {
+	c := junitTestCase{Name: r.Target, Classname: "that-cli-web-toolbox", Time: junitSeconds(r.Duration)}
+	if u, err := url.Parse(r.Target); err == nil && u.Host != "" {
+		c.Classname = u.Host
+	}
+
+	var out []string
+	for _, d := range outcomeDetails(r) {
+		if !strings.HasPrefix(d, "error: ") {
+			out = append(out, d)
+		}
+	}
+	if r.Result != nil {
+		// The attachment syntax of Jenkins' and GitLab's test reports
+		for _, f := range r.Result.Files {
+			if f.Location != "" {
+				out = append(out, fmt.Sprintf("[[ATTACHMENT|%s]]", f.Location))
+			}
+		}
+	}
+	c.SystemOut = strings.Join(out, "\n")
+
+	if r.Err == nil {
+		return c
+	}
+	code := exitCode(r.Err)
+	problem := &junitProblem{Message: r.Err.Error(), Type: junitKinds[code], Text: junitProblemText(r)}
+	if problem.Type == "" {
+		problem.Type = "error"
+	}
+	if junitFailures[code] {
+		c.Failure = problem
+	} else {
+		c.Error = problem
+	}
+	return c
+}
+
+// junitProblemText lists the checks of a failed target that did not pass.
+func junitProblemText(r batchResult) string {
+	if r.Result == nil {
+		return ""
+	}
+	var lines []string
+	for _, check := range r.Result.Checks {
+		if !check.Passed {
+			lines = append(lines, fmt.Sprintf("FAIL %s %s (got %s)", check.Name, check.Expected, check.Actual))
+		}
+	}
+	for _, check := range r.Result.Headers {
+		if !check.Passed {
+			lines = append(lines, fmt.Sprintf("FAIL %s: %s (got %s)", check.URL, check.Rule, check.Actual))
+		}
+	}
+	for _, c := range r.Result.FailIf {
+		if c.Matched {
+			lines = append(lines, fmt.Sprintf("FAIL fail-if %s%s", c.Expression, formatConditionValues(c.Values)))
+		}
+	}
+	for _, e := range r.Result.ActionErrors {
+		lines = append(lines, fmt.Sprintf("%s: %s", e.Action, e.Error))
+	}
+	return strings.Join(lines, "\n")
+}
+
+func junitSeconds(d time.Duration) string {
+	return fmt.Sprintf("%.3f", d.Seconds())
+}
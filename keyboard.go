@@ -0,0 +1,125 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	chromedphelper "github.com/pesarkhobeee/that-cli-web-toolbox/pkg/chromedp"
+)
+
+// keyboardAction presses Tab through the page for --keyboard-audit and
+// reports the focus order, missing focus indicators and keyboard traps.
+type keyboardAction struct{ noopAction }
+
+func (a *keyboardAction) Name() string             { return "keyboard" }
+func (a *keyboardAction) Enabled(cfg *Config) bool { return cfg.KeyboardAudit }
+func (a *keyboardAction) Flags() []string          { return []string{"--keyboard-audit"} }
+
+func (a *keyboardAction) Execute(ctx context.Context, run *Run) error {
+	slog.Info("Auditing keyboard navigation")
+	audit, err := run.Browser.KeyboardAudit(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to audit keyboard navigation: %w", err)
+	}
+	if audit.Trap != nil {
+		slog.Warn("Keyboard focus is trapped", "cycle", formatStops(audit.Trap.Cycle), "unreached", len(audit.Unreached))
+	}
+	if audit.Truncated {
+		slog.Warn("Page has too many focusable elements, keyboard audit covers only the first", "stops", len(audit.Stops))
+	}
+	run.Result.Keyboard = audit
+	return nil
+}
+
+func (a *keyboardAction) Report(ctx context.Context, run *Run) error {
+	// Batch runs list a one-line overview in the batch summary instead
+	if run.Batch || structuredFormat(run.Config.OutputFormat) {
+		return nil
+	}
+	audit := run.Result.Keyboard
+	fmt.Printf("Keyboard focus order (%d stops):\n", len(audit.Stops))
+	for _, stop := range audit.Stops {
+		fmt.Printf("  %3d  %s\n", stop.Index, describeFocusStop(stop))
+	}
+	if audit.Truncated {
+		fmt.Println("  ... more stops not audited")
+	}
+	if audit.Trap != nil {
+		fmt.Printf("Keyboard trap: focus cycles through stops %s and never reaches the rest of the page\n", formatStops(audit.Trap.Cycle))
+	}
+	if len(audit.Unreached) > 0 {
+		fmt.Printf("Not reachable with Tab (%d):\n", len(audit.Unreached))
+		for _, el := range audit.Unreached {
+			fmt.Printf("       %s\n", describeFocusable(el))
+		}
+	}
+	return nil
+}
+
+// describeFocusable renders el as "<tag role> selector "name" (tabindex N)".
+func describeFocusable(el chromedphelper.Focusable) string {
+	s := "<" + el.Tag
+	if el.Role != "" {
+		s += " role=" + el.Role
+	}
+	s += "> " + el.Selector
+	if el.Name != "" {
+		s += fmt.Sprintf(" %q", el.Name)
+	}
+	if el.TabIndex > 0 {
+		s += fmt.Sprintf(" (tabindex %d)", el.TabIndex)
+	}
+	return s
+}
+
+// describeFocusStop renders stop like describeFocusable, followed by its
+// problem, if any.
+func describeFocusStop(stop chromedphelper.FocusStop) string {
+	s := describeFocusable(stop.Focusable)
+	if stop.Hidden {
+		s += " [focused while hidden]"
+	} else if !stop.Indicator {
+		s += " [no focus indicator]"
+	}
+	return s
+}
+
+// formatStops renders stop indexes as e.g. "4-6" or "3".
+func formatStops(stops []int) string {
+	if len(stops) == 1 {
+		return fmt.Sprint(stops[0])
+	}
+	return fmt.Sprintf("%d-%d", stops[0], stops[len(stops)-1])
+}
+
+// formatKeyboardAudit renders an audit on one line for the batch summary,
+// e.g. "42 stops, 3 without focus indicator, trap at 40-42, 12 unreached".
+func formatKeyboardAudit(audit *chromedphelper.KeyboardAudit) string {
+	noIndicator, hidden := 0, 0
+	for _, stop := range audit.Stops {
+		if stop.Hidden {
+			hidden++
+		} else if !stop.Indicator {
+			noIndicator++
+		}
+	}
+	parts := []string{fmt.Sprintf("%d stops", len(audit.Stops))}
+	if audit.Truncated {
+		parts[0] += " or more"
+	}
+	if noIndicator > 0 {
+		parts = append(parts, fmt.Sprintf("%d without focus indicator", noIndicator))
+	}
+	if hidden > 0 {
+		parts = append(parts, fmt.Sprintf("%d focused while hidden", hidden))
+	}
+	if audit.Trap != nil {
+		parts = append(parts, "trap at "+formatStops(audit.Trap.Cycle))
+	}
+	if len(audit.Unreached) > 0 {
+		parts = append(parts, fmt.Sprintf("%d unreached", len(audit.Unreached)))
+	}
+	return strings.Join(parts, ", ")
+}
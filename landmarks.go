@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"maps"
+	"slices"
+	"strings"
+
+	chromedphelper "github.com/pesarkhobeee/that-cli-web-toolbox/pkg/chromedp"
+)
+
+// landmarksAction summarizes the page's landmarks, roles and heading
+// outline for --landmarks.
+type landmarksAction struct{ noopAction }
+
+func (a *landmarksAction) Name() string             { return "landmarks" }
+func (a *landmarksAction) Enabled(cfg *Config) bool { return cfg.Landmarks }
+func (a *landmarksAction) Flags() []string          { return []string{"--landmarks"} }
+
+func (a *landmarksAction) Execute(ctx context.Context, run *Run) error {
+	slog.Info("Summarizing landmarks")
+	s, err := run.Browser.Landmarks(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to summarize landmarks: %w", err)
+	}
+	for _, issue := range s.Issues {
+		slog.Warn("Page structure issue", "issue", issue)
+	}
+	run.Result.Structure = s
+	return nil
+}
+
+func (a *landmarksAction) Report(ctx context.Context, run *Run) error {
+	// Batch runs list a one-line overview in the batch summary instead
+	if run.Batch || structuredFormat(run.Config.OutputFormat) {
+		return nil
+	}
+	s := run.Result.Structure
+	fmt.Printf("Landmarks (%d):\n", len(s.Landmarks))
+	for _, l := range s.Landmarks {
+		label := ""
+		if l.Label != "" {
+			label = fmt.Sprintf(" %q", l.Label)
+		}
+		fmt.Printf("  %s%-13s <%s> %s%s\n", strings.Repeat("  ", l.Depth), l.Role, l.Tag, l.Selector, label)
+	}
+	fmt.Printf("Headings (%d):\n", len(s.Headings))
+	for _, h := range s.Headings {
+		fmt.Printf("  %sh%d %s\n", strings.Repeat("  ", h.Level-1), h.Level, h.Text)
+	}
+	if s.Truncated {
+		fmt.Println("  ... more headings not listed")
+	}
+	if len(s.Roles) > 0 {
+		fmt.Printf("Roles: %s\n", formatRoles(s.Roles))
+	}
+	if len(s.Issues) > 0 {
+		fmt.Println("Issues:")
+		for _, issue := range s.Issues {
+			fmt.Printf("  - %s\n", issue)
+		}
+	}
+	return nil
+}
+
+// formatRoles renders role counts by name, e.g. "button 12, dialog 1".
+func formatRoles(roles map[string]int) string {
+	var parts []string
+	for _, role := range slices.Sorted(maps.Keys(roles)) {
+		parts = append(parts, fmt.Sprintf("%s %d", role, roles[role]))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// formatStructure renders the landmarks and issues of s on one line for the
+// batch summary, e.g. "banner, navigation x2, main; 14 headings; no h1 heading".
+func formatStructure(s *chromedphelper.Structure) string {
+	var roles []string
+	count := make(map[string]int)
+	for _, l := range s.Landmarks {
+		if count[l.Role] == 0 {
+			roles = append(roles, l.Role)
+		}
+		count[l.Role]++
+	}
+	var parts []string
+	for _, role := range roles {
+		if count[role] > 1 {
+			parts = append(parts, fmt.Sprintf("%s x%d", role, count[role]))
+		} else {
+			parts = append(parts, role)
+		}
+	}
+	landmarks := "no landmarks"
+	if len(parts) > 0 {
+		landmarks = strings.Join(parts, ", ")
+	}
+	summary := fmt.Sprintf("%s; %d headings", landmarks, len(s.Headings))
+	if len(s.Issues) > 0 {
+		summary += "; " + strings.Join(s.Issues, ", ")
+	}
+	return summary
+}
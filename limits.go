@@ -0,0 +1,65 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	chromedphelper "github.com/pesarkhobeee/that-cli-web-toolbox/pkg/chromedp"
+)
+
+// byteUnits are the suffixes parseByteSize accepts, longest first so that
+// "MB" is not read as "B". Decimal and binary prefixes both mean powers
+// of 1024.
+var byteUnits = []struct {
+	suffix string
+	size   int64
+}{
+	{"KIB", 1 << 10}, {"MIB", 1 << 20}, {"GIB", 1 << 30},
+	{"KB", 1 << 10}, {"MB", 1 << 20}, {"GB", 1 << 30},
+	{"K", 1 << 10}, {"M", 1 << 20}, {"G", 1 << 30},
+	{"B", 1},
+}
+
+// parseByteSize parses a size such as 20MB, 512KiB, 1.5G or 1000000; an
+// empty string is 0.
+func parseByteSize(s string) (int64, error) {
+	value := strings.ToUpper(strings.TrimSpace(s))
+	if value == "" {
+		return 0, nil
+	}
+	size := int64(1)
+	for _, unit := range byteUnits {
+		if n, ok := strings.CutSuffix(value, unit.suffix); ok {
+			value, size = strings.TrimSpace(n), unit.size
+			break
+		}
+	}
+	n, err := strconv.ParseFloat(value, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid size %q (expected a number with an optional unit, e.g. 20MB)", s)
+	}
+	return int64(n * float64(size)), nil
+}
+
+// formatByteSize renders a size with the largest unit it reaches, e.g.
+// "20.0 MB".
+func formatByteSize(n int64) string {
+	switch {
+	case n >= 1<<30:
+		return fmt.Sprintf("%.1f GB", float64(n)/(1<<30))
+	case n >= 1<<20:
+		return fmt.Sprintf("%.1f MB", float64(n)/(1<<20))
+	case n >= 1<<10:
+		return fmt.Sprintf("%.1f KB", float64(n)/(1<<10))
+	}
+	return fmt.Sprintf("%d B", n)
+}
+
+// formatLimit renders the cap a page went over for the batch summary.
+func formatLimit(l *chromedphelper.Limit) string {
+	if l.Kind == chromedphelper.LimitRequests {
+		return fmt.Sprintf("more than %d requests (--max-requests)", l.Max)
+	}
+	return fmt.Sprintf("%s transferred, over %s (--max-bytes)", formatByteSize(l.Used), formatByteSize(l.Max))
+}
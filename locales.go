@@ -0,0 +1,23 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// localePlaceholder in a target is replaced by each --locales entry, for
+// sites that put the locale in the URL.
+const localePlaceholder = "{locale}"
+
+// localeTag loosely matches a BCP 47 language tag such as de or pt-BR.
+var localeTag = regexp.MustCompile(`^[A-Za-z]{2,3}(-[A-Za-z0-9]{2,8})*$`)
+
+// validateLocales checks the --locales entries.
+func validateLocales(locales []string) error {
+	for _, locale := range locales {
+		if !localeTag.MatchString(locale) {
+			return fmt.Errorf("invalid locale %q (expected a language tag such as en, de or pt-BR)", locale)
+		}
+	}
+	return nil
+}
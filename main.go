@@ -1,37 +1,200 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"io"
 	"log/slog"
 	"os"
-	"path/filepath"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/spf13/cobra"
 
 	chromedphelper "github.com/pesarkhobeee/that-cli-web-toolbox/pkg/chromedp"
+	"github.com/pesarkhobeee/that-cli-web-toolbox/pkg/har"
+	"github.com/pesarkhobeee/that-cli-web-toolbox/pkg/idn"
+	"github.com/pesarkhobeee/that-cli-web-toolbox/pkg/runstore"
+	"github.com/pesarkhobeee/that-cli-web-toolbox/pkg/sink"
+	"github.com/pesarkhobeee/that-cli-web-toolbox/pkg/tracing"
+	"github.com/pesarkhobeee/that-cli-web-toolbox/pkg/urlfilter"
 )
 
 type Config struct {
 	ConsoleLog           bool
 	Screenshot           bool
 	PrintToPDF           bool
+	PDFTOC               int
+	MHTML                bool
+	Metadata             bool
 	GetBody              bool
-	GetTextByCssSelector string
+	Outline              string
+	OnlyLang             string
+	CompareNoJS          bool
+	AsGooglebot          bool
+	CompareGooglebot     bool
+	DumpHTML             bool
+	Sanitize             bool
+	CriticalCSS          bool
+	AboveFold            bool
+	ContentMap           bool
+	Landmarks            bool
+	AccessibleNames      []string
+	ContrastCheck        bool
+	ContrastLevel        string
+	GetTextByCssSelector []string
+	JSONPaths            []string
+	Preset               string
+	ScreenshotSelectors  []string
+	ScreenshotEach       string
+	SVG                  []string
+	DOMSnapshot          string
+	DOMSnapshotStyles    []string
+	Highlight            []string
+	Mask                 []string
+	AnnotateInteractives bool
+	AnnotateJSON         bool
+	AnnotateSelectors    []string
+	ReadClipboard        bool
+	GrantPermissions     []string
+	Limit                int
 	Timeout              int
 	Delay                int
+	ReadyStrategy        string
 	Target               string
 	LogLevel             string
 	RemoteDebuggingPort  string
+	Via                  string
+	Headful              bool
+	HeadlessMode         string
+	SystemChrome         bool
+	CacheDir             string
+	RequireChrome        string
+	Manifest             bool
+	PauseBeforeExit      int
+	KeepTarget           bool
+	OtelEndpoint         string
 	JS                   string
 	JSFile               string
+	Steps                []string
+	StepsFile            string
+	ClickAt              []string
+	TapAt                []string
+	Headers              []string
+	BasicAuth            string
+	Cookies              []string
+	CookiesFile          string
+	SaveCookies          string
+	SaveState            string
+	LoadState            string
+	ExportAuth           string
+	Allow                []string
+	Deny                 []string
+	AllowHosts           []string
+	Untrusted            bool
+	IDNPolicy            string
+	Viewport             string
+	Device               string
+	DarkMode             bool
+	FullPage             bool
+	ScreenshotAt         string
+	ScreenshotFormat     string
+	ScreenshotQuality    int
+	OmitBackground       bool
+	BeyondViewport       bool
+	FromSurface          bool
+	MaxRedirects         int
+	MaxBytes             string
+	MaxRequests          int
+	Sink                 string
+	AuditLog             string
+	SupportBundle        string
+	GitHubPR             string
+	GitHubContext        string
+	GitHubArtifactURL    string
+	CABundle             string
+	Order                []string
+	ContinueOnError      bool
+	SiteSettings         string
+	ChangedOnly          string
+	ConfigFile           string
+	JobProfile           string
+	JobSchedule          string
+	NormalizeText        string
+	StripEmoji           bool
+	CollapseWhitespace   bool
+	RedactPII            bool
+	TextEncoding         string
+	EOL                  string
+	InputFile            string
+	FromBookmarks        string
+	FromHistory          string
+	SourceMatch          string
+	SourceSince          string
+	Pick                 bool
+	DetectDuplicates     bool
+	EmitSitemap          string
+	VisualSitemap        string
+	HAR                  string
+	ReplayHAR            string
+	EmitCurl             bool
+	EmitCurlMatch        string
+	FailOnRequestError   bool
+	ErrorSummary         bool
+	FailThreshold        int
+	FailIf               []string
+	SortSummary          string
+	Locales              []string
+	ConsentStates        []string
+	ConsentSteps         []string
+	ConsentCookies       []string
+	ParamMatrix          string
+	Tor                  bool
+	TorSocks             string
+	TorControl           string
+	TorRotate            int
+	ProxyPool            string
+	ProxyStrategy        string
+	FingerprintProfile   string
+	Summary              bool
+	TechDetect           bool
+	DetectSoft404        bool
+	OverlayReport        bool
+	OverlayThreshold     float64
+	DesignBaseline       string
+	Tolerance            string
+	IgnoreRegions        []string
+	IgnoreRegionsFile    string
+	BaselineDir          string
+	KeyboardAudit        bool
+	ResolveSourceMaps    bool
+	ExpectSelectors      []string
+	Assertions           []string
+	ExpectText           string
+	ExpectStatus         int
+	MaxLoadTime          time.Duration
+	AssertHeaders        []string
+	AssertHeaderMatch    string
+	NoBrowser            bool
+	Auto                 bool
+	Concurrency          int
+	OutputFormat         string
 }
 
 var cfg Config
 
+// version is the version of the tool, set when building with
+// -ldflags "-X main.version=...".
+var version = "dev"
+
+// allowedHosts holds the hosts of --allow-hosts, enforced by every tab and
+// by the DNS of browsers the tool starts.
+var allowedHosts *urlfilter.Hosts
+
 var rootCmd = &cobra.Command{
-	Use:   "that-cli-web-toolbox",
+	Use:   "that-cli-web-toolbox [flags] URL|FILE...",
 	Short: "A powerful CLI tool for web automation tasks including screenshots, PDFs, console logs, and text extraction",
 	Long: `An easy to use Swiss army knife for web in CLI.
 
@@ -43,10 +206,17 @@ Features:
   • Extract text using CSS selectors
   • Support for both local HTML files and remote URLs
   • Connect to existing Chrome instances with remote debugging
+  • Serve screenshots, PDFs and text extraction over an HTTP API (see "serve --help")
+  • Run multi-step synthetic monitors with Nagios-compatible results (see "monitor --help")
+  • Measure how reliably selectors resolve across repeated page loads (see "flaky-check --help")
+  • Capture from other desktop apps through toolbox:// deep links (see "handle-url --help")
   • Configurable logging levels for debugging
   • Configurable delay to ensure proper page rendering (timeout auto-adjusts if needed)
 
 Examples:
+  # Triage a page: title, status, word count, console errors, requests and load time
+  that-cli-web-toolbox --summary https://example.com
+
   # Take a screenshot of a website
   that-cli-web-toolbox --screenshot https://example.com
 
@@ -56,9 +226,114 @@ Examples:
   # Get text by CSS selector from a local HTML file
   that-cli-web-toolbox --gettextbycssselector "h1" ./index.html
 
+  # Extract several selectors and screenshot two elements in one navigation
+  that-cli-web-toolbox -g "h1" -g ".price" --screenshot-selector "#chart" --screenshot-selector "nav" https://example.com
+
+  # Extract product names from a JSON API and headings from HTML pages in one list
+  that-cli-web-toolbox --jsonpath '$.items[*].name' -g "h1" --input-file endpoints.txt
+
+  # Extract prices from thousands of mostly static pages, starting Chrome only for those that need it
+  that-cli-web-toolbox --auto -g ".price" --input-file products.txt --concurrency 16
+
+  # Thumbnail the first 20 product cards of a listing page, one image each
+  that-cli-web-toolbox --screenshot-each "li.product-card" --limit 20 https://example.com/products
+
+  # Point a bug report at the checkout button and the error banner
+  that-cli-web-toolbox --screenshot --highlight "#checkout" --highlight ".alert-error" https://example.com/cart
+
+  # Share a capture of an account page with the e-mail address and API token blacked out
+  that-cli-web-toolbox --screenshot --printtopdf --mask ".user-email" --mask "#api-token" --cookies-file session.json https://example.com/account
+
+  # Render a badge widget onto a transparent PNG for a template
+  that-cli-web-toolbox --screenshot-selector "#badge" --omit-background file:///path/to/widget.html
+
+  # Capture what visitors see at the first contentful paint
+  that-cli-web-toolbox --screenshot-at fcp --full-page=false https://example.com
+
+  # Export the DOM with layout boxes and computed styles for layout analysis
+  that-cli-web-toolbox --dom-snapshot page.json https://example.com
+
+  # Screenshot with numbered clickable elements and a JSON map for agent grounding
+  that-cli-web-toolbox --screenshot --annotate-interactives https://example.com
+
+  # Screenshot with a JSON sidecar of where headings, landmarks and buttons are on it
+  that-cli-web-toolbox --screenshot --annotate-json https://example.com
+
+  # Screenshot a single-page app once its content replaced the loading skeleton
+  that-cli-web-toolbox --screenshot --ready-strategy 'selector:#app .product' --delay 0 https://example.com
+
+  # Extract text normalized for diffing
+  that-cli-web-toolbox --body --normalize-text nfkc --strip-emoji --collapse-whitespace https://example.com
+
+  # Extract text for Windows tools expecting UTF-16 with CRLF line endings
+  that-cli-web-toolbox --body --text-encoding utf-16le --eol crlf --sink file:./out https://example.com
+
+  # Store a page's text under a data policy, with personal data replaced by placeholders
+  that-cli-web-toolbox --body --redact-pii https://example.com/contact
+
+  # Dump the rendered HTML, sanitized for re-hosting
+  that-cli-web-toolbox --html --sanitize --sink file:./kb https://example.com
+
+  # Generate the critical CSS of a page for a phone-sized first screen
+  that-cli-web-toolbox --critical-css --device "iPhone 12" --sink file:./critical https://example.com
+
+  # List what a laptop screen shows before scrolling, and the LCP element
+  that-cli-web-toolbox --above-fold --viewport 1366x768 https://example.com
+
+  # Map where text, images, ads and whitespace fall down an article
+  that-cli-web-toolbox --content-map --sink file:./maps https://example.com/article
+
+  # Check the landmarks and heading outline of a page
+  that-cli-web-toolbox --landmarks https://example.com
+
+  # Check what screen readers announce for the login button and the icons
+  that-cli-web-toolbox --get-accessible-name "#login" --get-accessible-name "button.icon" https://example.com
+
+  # Find text that is hard to read against the images and colors behind it
+  that-cli-web-toolbox --contrast-check --contrast-level aaa https://example.com
+
+  # Fail phone-sized pages whose cookie banners and popups hide over a quarter of the first screen
+  that-cli-web-toolbox --overlay-report --overlay-threshold 25 --device "iPhone 12" --input-file urls.txt
+
+  # Compare the page with the exported mockup, ignoring the date in the header
+  that-cli-web-toolbox --design-baseline figma-export.png --tolerance 5% --ignore-region 1100,20,160,24 https://staging.example.com
+
+  # Check the pages of a preview deployment and report on its pull request
+  that-cli-web-toolbox --baseline-dir baselines --sink s3://ci-artifacts/visual --github-pr acme/shop#123 --input-file urls.txt
+
+  # Walk a page with Tab for an accessibility review of its focus order
+  that-cli-web-toolbox --keyboard-audit https://example.com/checkout
+
   # Generate PDF and capture console logs
   that-cli-web-toolbox --printtopdf --consolelog https://example.com
 
+  # Report exceptions at their original source lines instead of in minified bundles
+  that-cli-web-toolbox --consolelog --resolve-sourcemaps https://example.com
+
+  # Screenshot many pages in parallel within one Chrome instance
+  that-cli-web-toolbox --screenshot --input-file urls.txt --concurrency 4
+
+  # Re-capture everything bookmarked this week, choosing from a list
+  that-cli-web-toolbox --screenshot --from-bookmarks bookmarks.html --source-since 7d --pick
+
+  # Build a sitemap of the pages listed in a file
+  that-cli-web-toolbox --input-file urls.txt --concurrency 4 --emit-sitemap sitemap.xml
+
+  # Browse thumbnails of a whole list of pages in one HTML report
+  that-cli-web-toolbox --input-file urls.txt --concurrency 4 --visual-sitemap site.html
+
+  # Emit text, selector matches, console messages and file paths as JSON
+  that-cli-web-toolbox --body -g "h1" --consolelog --screenshot --output-format json https://example.com
+
+  # Screenshot the viewport of a phone in dark mode as WebP
+  that-cli-web-toolbox --screenshot --device "iPhone 12" --dark-mode --full-page=false --screenshot-format webp https://example.com
+
+  # Capture a landing page only after its meta refresh or JavaScript redirect
+  that-cli-web-toolbox --screenshot --max-redirects 3 https://example.com/promo
+
+  # Keep pages streaming endless media from stalling a batch
+  that-cli-web-toolbox --screenshot --max-bytes 20MB --max-requests 500 --input-file urls.txt --concurrency 4
+
   # Take screenshot with custom delay for slow-loading pages
   that-cli-web-toolbox --screenshot --delay 5 https://example.com
 
@@ -68,47 +343,446 @@ Examples:
   # Connect to existing Chrome with remote debugging
   that-cli-web-toolbox --remote-debugging-port localhost:9222 --screenshot https://example.com
 
+  # Watch a selector miss in a visible browser and inspect the page for a minute
+  that-cli-web-toolbox --headful --pause-before-exit 60 -g ".price" https://example.com
+
+  # Drive a tab of a remote Chrome and leave it open to inspect in DevTools
+  that-cli-web-toolbox --remote-debugging-port localhost:9222 --keep-target -g ".price" https://example.com
+
+  # Render with the pinned Chrome for Testing build on every machine
+  that-cli-web-toolbox browser install
+
+  # Keep Chrome running in a daemon so repeated runs from scripts skip its startup
+  that-cli-web-toolbox daemon --socket /run/user/1000/toolbox.sock &
+  that-cli-web-toolbox --via /run/user/1000/toolbox.sock --screenshot https://example.com
+
+  # Install the daemon as a systemd user unit instead of starting it by hand
+  that-cli-web-toolbox service install daemon
+
+  # Record network activity as a HAR file and fail on broken requests
+  that-cli-web-toolbox --har page.har --fail-on-request-error https://example.com
+
+  # Print the API calls a page makes as curl commands to replay them
+  that-cli-web-toolbox --emit-curl --emit-curl-match "/api/" https://example.com
+
+  # Post-deploy gate: fail pages with more than 2 errors, worst pages first
+  that-cli-web-toolbox --input-file urls.txt --fail-threshold 2 --sort-summary errors
+
+  # Encode a release policy of your own
+  that-cli-web-toolbox --fail-if 'console_errors > 0 || lcp_ms > 4000 || !selector("#footer")' https://example.com
+
+  # Capture an authenticated app without ever following logout or delete links
+  that-cli-web-toolbox --screenshot --cookies-file session.json --allow "/app/*" --deny "/logout,/admin/delete*" --input-file app-urls.txt
+
+  # Render a user-supplied template without letting it reach any other host
+  that-cli-web-toolbox --printtopdf --allow-hosts example.com,*.example-cdn.com template.html
+
+  # Render user-submitted HTML to PDF in a locked-down browser
+  that-cli-web-toolbox --printtopdf --untrusted submission.html
+
+  # Check a list of links, skipping hosts that imitate others with lookalike letters
+  that-cli-web-toolbox --summary --input-file links.txt --idn-policy block
+
   # Execute custom JavaScript before taking screenshot (scroll to bottom, click buttons, etc.)
   that-cli-web-toolbox --screenshot --js "window.scrollTo(0, document.body.scrollHeight)" https://example.com
 
   # Execute async JavaScript with automatic wrapping
   that-cli-web-toolbox --screenshot --js "await new Promise(r => setTimeout(r, 2000)); window.scrollTo(0, document.body.scrollHeight);" https://example.com
 
+  # Accept the cookie banner and search before capturing
+  that-cli-web-toolbox --screenshot --step "click:#accept" --step "type:#q:hello" --step "waitvisible:.results" https://example.com
+
+  # Check a page in cron or CI; exits 2, 3 or 4 on load failure, failed check or timeout
+  that-cli-web-toolbox --expect-status 200 --expect-selector "#main" --expect-text "Welcome" --max-load-time 5s https://example.com
+
+  # Accept a login form: visible and enabled button, three product cards, a greeting
+  that-cli-web-toolbox --assert "#login:visible" --assert "#login button:enabled" --assert ".card:count>=3" --assert "h1:text~=^Welcome" https://example.com
+
+  # Catch CDN and security header regressions on the page and its scripts
+  that-cli-web-toolbox --assert-header "cache-control~=max-age" --assert-header "x-frame-options" --assert-header "!server" --assert-header-match "\\.js$" https://example.com
+
+  # Screenshot the English, German and French versions side by side
+  that-cli-web-toolbox --screenshot --locales en,de,fr "https://example.com/{locale}/"
+
+  # Capture a page with the cookie banner accepted, rejected and unanswered
+  that-cli-web-toolbox --screenshot --consent-states accepted,rejected,none --consent-step "accepted=click:#accept-all" --consent-step "rejected=click:#reject-all" https://example.com
+
+  # Screenshot a landing page for every campaign source and A/B variant
+  that-cli-web-toolbox --screenshot --param-matrix "utm_source=newsletter,ads;variant=a,b" https://example.com/landing
+
+  # Monitor a list of pages, each load with a different random browser fingerprint
+  that-cli-web-toolbox --expect-status 200 --fingerprint-profile random --input-file urls.txt --concurrency 4
+
+  # See which frameworks, CMS, analytics and tag managers a list of sites uses
+  that-cli-web-toolbox --tech-detect --input-file prospects.txt --concurrency 4
+
+  # Capture an intranet page whose certificate is issued by an internal CA
+  that-cli-web-toolbox --screenshot --ca-bundle corp-ca.pem https://intranet.example.com
+
+  # Capture a staging site behind basic auth with a session cookie
+  that-cli-web-toolbox --screenshot --basic-auth user:pass --cookie "session=abc123" https://staging.example.com
+
+  # Check that a "copy link" button puts the expected URL on the clipboard
+  that-cli-web-toolbox --read-clipboard --step "click:#copy-link" https://example.com/share
+
+  # Accept a store locator's geolocation prompt instead of hanging on it
+  that-cli-web-toolbox --screenshot --grant-permissions geolocation,notifications https://example.com/stores
+
+  # Click into a map canvas before taking the screenshot
+  that-cli-web-toolbox --screenshot --step "waitvisible:canvas" --click-at 640,360 https://example.com/map
+
+  # Log in with steps once, save the session and reuse it later
+  that-cli-web-toolbox --step "type:#user:me" --step "type:#pass:secret" --step "click:#login" --save-cookies session.json https://example.com/login
+  that-cli-web-toolbox --screenshot --cookies-file session.json https://example.com/account
+
+  # Log in in one job, resume the session with its web storage in a later one
+  that-cli-web-toolbox --step "type:#user:me" --step "type:#pass:secret" --step "click:#login" --save-state state.tgz https://app.example.com/login
+  that-cli-web-toolbox --screenshot --load-state state.tgz
+
   # Execute JavaScript from file to load dynamic content
-  that-cli-web-toolbox --screenshot --js-file scroll-to-bottom.js https://example.com`,
+  that-cli-web-toolbox --screenshot --js-file scroll-to-bottom.js https://example.com
+
+  # Write screenshot and text into a directory instead of the current one
+  that-cli-web-toolbox --screenshot --body --sink file:./captures https://example.com
+
+  # Trace where rendering time goes in Jaeger, Tempo or another OTLP backend
+  that-cli-web-toolbox --screenshot --otel-endpoint http://localhost:4318 https://example.com
+
+  # Keep a tamper-evident record of who captured what, and check it later
+  that-cli-web-toolbox --screenshot --audit-log audit.jsonl https://example.com
+  that-cli-web-toolbox verify-audit-log audit.jsonl
+
+  # Pipe a PDF to another program
+  that-cli-web-toolbox --printtopdf --sink stdout https://example.com > page.pdf`,
 	RunE: runThatCliWebBrowser,
-	Args: cobra.ExactArgs(1),
+	Args: cobra.ArbitraryArgs,
 }
 
 func init() {
 	rootCmd.Flags().BoolVarP(&cfg.ConsoleLog, "consolelog", "c", false, "Capture console logs from the page")
 	rootCmd.Flags().BoolVarP(&cfg.Screenshot, "screenshot", "s", false, "Take a screenshot of the page")
 	rootCmd.Flags().BoolVarP(&cfg.PrintToPDF, "printtopdf", "p", false, "Print the page to a PDF file")
+	rootCmd.Flags().IntVar(&cfg.PDFTOC, "pdf-toc", 0,
+		"With --printtopdf, when the PDF has more than this many pages, add a leading table of contents page linking to the page's headings (0 disables)")
+	rootCmd.Flags().BoolVar(&cfg.MHTML, "mhtml", false, "Save the page with its stylesheets, images and frames as one MHTML file, which browsers open offline")
+	rootCmd.Flags().BoolVar(&cfg.Metadata, "metadata", false,
+		"Write a JSON file with the page's final URL, status, title, description, canonical URL, redirects and the checksums of the other outputs")
 	rootCmd.Flags().BoolVarP(&cfg.GetBody, "body", "b", false, "Get the body text of the page")
-	rootCmd.Flags().StringVarP(&cfg.GetTextByCssSelector, "gettextbycssselector", "g", "", "Get text by CSS selector")
+	rootCmd.Flags().StringVar(&cfg.Outline, "outline", "",
+		"Get the text of the page with its structure, sections nested by heading, lists, tables and links kept: markdown (without a value) or json for a tree")
+	rootCmd.Flags().Lookup("outline").NoOptDefVal = outlineMarkdown
+	rootCmd.Flags().StringVar(&cfg.OnlyLang, "only-lang", "",
+		"Extract only text in this language, by the lang attributes of its elements, with --body, --outline and --gettextbycssselector, e.g. de (also de-DE, de-CH)")
+	rootCmd.Flags().BoolVar(&cfg.DumpHTML, "html", false, "Get the rendered HTML of the page")
+	rootCmd.Flags().BoolVar(&cfg.Sanitize, "sanitize", false,
+		"With --html, strip scripts, event handlers, embeds and tracking pixels using an allowlist sanitizer")
+	rootCmd.Flags().BoolVar(&cfg.CriticalCSS, "critical-css", false,
+		"Get the critical CSS of the page: the rules used by elements above the fold in the viewport, from CSS coverage")
+	rootCmd.Flags().BoolVar(&cfg.AboveFold, "above-fold", false,
+		"List the headings, text, images, videos and controls visible without scrolling in the viewport, and the Largest Contentful Paint element")
+	rootCmd.Flags().BoolVar(&cfg.ContentMap, "content-map", false,
+		"Map where text, images, media, ads and whitespace fall down the page, per screen, as a PNG image and JSON")
+	rootCmd.Flags().BoolVar(&cfg.Landmarks, "landmarks", false,
+		"Summarize the ARIA landmarks, roles and heading outline of the page, flagging missing main and navigation landmarks")
+	rootCmd.Flags().StringArrayVar(&cfg.AccessibleNames, "get-accessible-name", nil,
+		"Report the computed accessible name, role and description of the elements matching a CSS selector, as screen readers announce them (repeatable)")
+	rootCmd.Flags().BoolVar(&cfg.ContrastCheck, "contrast-check", false,
+		"Check the contrast of the page's text against its rendered background, including images and gradients, and report WCAG failures")
+	rootCmd.Flags().StringVar(&cfg.ContrastLevel, "contrast-level", "aa",
+		"WCAG level --contrast-check reports failures of: aa, or aaa to also report text passing AA but failing AAA")
+	rootCmd.Flags().StringArrayVarP(&cfg.GetTextByCssSelector, "gettextbycssselector", "g", nil, "Get text by CSS selector (repeatable)")
+	rootCmd.Flags().StringArrayVar(&cfg.JSONPaths, "jsonpath", nil,
+		"Extract values from targets served as JSON with this JSONPath expression, e.g. '$.items[*].name' (repeatable)")
+	rootCmd.Flags().StringVar(&cfg.Preset, "preset", "",
+		"Extract the page's items as normalized JSON from its structured data and common selectors: product, article or job-posting")
+	rootCmd.Flags().StringArrayVar(&cfg.ScreenshotSelectors, "screenshot-selector", nil,
+		"Take a screenshot of the first element matching a CSS selector (repeatable)")
+	rootCmd.Flags().StringVar(&cfg.ScreenshotEach, "screenshot-each", "",
+		"Take a separate screenshot of every element matching a CSS selector, e.g. product cards")
+	rootCmd.Flags().StringArrayVar(&cfg.SVG, "svg", nil,
+		"Experimental: export the first element matching a CSS selector as an SVG document, e.g. a chart (repeatable)")
+	rootCmd.Flags().StringVar(&cfg.DOMSnapshot, "dom-snapshot", "",
+		"Write the flattened DOM, layout and computed-style snapshot of the page (DOMSnapshot.captureSnapshot) as JSON to this file")
+	rootCmd.Flags().StringSliceVar(&cfg.DOMSnapshotStyles, "dom-snapshot-styles", defaultSnapshotStyles,
+		"Computed style properties --dom-snapshot records for every layout box")
+	rootCmd.Flags().StringArrayVar(&cfg.Highlight, "highlight", nil,
+		"Outline and number the elements matching a CSS selector in screenshots and PDFs (repeatable)")
+	rootCmd.Flags().StringArrayVar(&cfg.Mask, "mask", nil,
+		"Black out the elements matching a CSS selector, e.g. e-mail addresses or tokens, in screenshots and PDFs; their text is replaced too (repeatable)")
+	rootCmd.Flags().BoolVar(&cfg.AnnotateInteractives, "annotate-interactives", false,
+		"With --screenshot, label every clickable element with a number and write a JSON map of numbers to selectors and boxes")
+	rootCmd.Flags().BoolVar(&cfg.AnnotateJSON, "annotate-json", false,
+		"Write a JSON sidecar next to each screenshot with the boxes of key elements (headings, landmarks, forms, buttons, images) on it")
+	rootCmd.Flags().StringArrayVar(&cfg.AnnotateSelectors, "annotate-selector", nil,
+		"With --annotate-json, locate the elements matching this CSS selector instead of the key elements (repeatable)")
+	rootCmd.Flags().IntVar(&cfg.Limit, "limit", 0,
+		"With --screenshot-each, capture at most this many elements; 0 captures all")
+	rootCmd.Flags().IntVar(&cfg.MaxRedirects, "max-redirects", 0,
+		"Wait for and follow up to this many client-side redirects (meta refresh, JavaScript) after the page loads")
+	rootCmd.Flags().StringVar(&cfg.MaxBytes, "max-bytes", "",
+		"Abort and fail a page once it transferred more than this, e.g. 20MB")
+	rootCmd.Flags().IntVar(&cfg.MaxRequests, "max-requests", 0,
+		"Abort and fail a page once it made more than this many requests")
+	rootCmd.Flags().BoolVar(&cfg.FullPage, "full-page", true,
+		"Capture the whole page with --screenshot; --full-page=false captures only the viewport")
+	rootCmd.Flags().StringVar(&cfg.ScreenshotAt, "screenshot-at", "",
+		"Take the --screenshot the moment the page reaches a milestone: fcp, lcp, load, or a time after navigation starts such as +3s")
+	rootCmd.Flags().StringVar(&cfg.ScreenshotFormat, "screenshot-format", "",
+		"Screenshot image format: png, jpeg or webp (default jpeg for pages, png for elements)")
+	rootCmd.Flags().IntVar(&cfg.ScreenshotQuality, "screenshot-quality", 90,
+		"Compression quality from 1 to 100 for jpeg and webp screenshots")
+	rootCmd.Flags().BoolVar(&cfg.OmitBackground, "omit-background", false,
+		"Make the page's default white background transparent in screenshots, which then default to png")
+	rootCmd.Flags().BoolVar(&cfg.BeyondViewport, "capture-beyond-viewport", true,
+		"Render what lies outside the viewport in full page and element screenshots; =false keeps the viewport's layout")
+	rootCmd.Flags().BoolVar(&cfg.FromSurface, "from-surface", true,
+		"Capture screenshots from the compositor surface; =false captures from the view")
+	rootCmd.Flags().StringVar(&cfg.Viewport, "viewport", "",
+		"Viewport size as WIDTHxHEIGHT in CSS pixels, e.g. 1280x800")
+	rootCmd.Flags().StringVar(&cfg.Device, "device", "",
+		"Emulate a device preset, e.g. \"iPhone 12\" ("+strings.Join(chromedphelper.Devices(), ", ")+")")
+	rootCmd.Flags().BoolVar(&cfg.DarkMode, "dark-mode", false, "Emulate prefers-color-scheme: dark")
+	rootCmd.Flags().BoolVar(&cfg.Tor, "tor", false,
+		"Route all browser traffic, including DNS, through a local Tor daemon's SOCKS5 proxy")
+	rootCmd.Flags().StringVar(&cfg.TorSocks, "tor-socks", "127.0.0.1:9050", "Address of Tor's SOCKS5 proxy for --tor")
+	rootCmd.Flags().StringVar(&cfg.TorControl, "tor-control", "127.0.0.1:9051",
+		"Address of Tor's control port for --tor-rotate (password from $"+torPasswordEnv+", else cookie or no auth)")
+	rootCmd.Flags().IntVar(&cfg.TorRotate, "tor-rotate", 0,
+		"With --tor, request a new circuit every N page loads; 0 keeps one circuit")
+	rootCmd.Flags().StringVar(&cfg.ProxyPool, "proxy-pool", "",
+		"Route page loads through proxies listed in this file, one [scheme://][user:pass@]host:port per line; dead ones are skipped")
+	rootCmd.Flags().StringVar(&cfg.ProxyStrategy, "proxy-strategy", proxyRoundRobin,
+		"How --proxy-pool proxies are assigned: round-robin across page loads, or per-host (same proxy for every URL of a host)")
+	rootCmd.Flags().StringVar(&cfg.FingerprintProfile, "fingerprint-profile", "",
+		"Vary user agent, viewport, languages, timezone and canvas/WebGL output per page load: random, or a JSON file of profiles used in turn")
 	rootCmd.Flags().IntVarP(&cfg.Timeout, "timeout", "t", 10, "Timeout in seconds")
 	rootCmd.Flags().IntVarP(&cfg.Delay, "delay", "d", 2, "Delay in seconds to ensure rendering (timeout auto-adjusts if needed)")
-	rootCmd.Flags().StringVarP(&cfg.LogLevel, "loglevel", "l", "info",
+	rootCmd.Flags().StringVar(&cfg.ReadyStrategy, "ready-strategy", "",
+		"Wait until the page is ready before the delay: selector:SEL (visible), network-idle[:N] (at most N requests in flight for 500ms), js:EXPRESSION (truthy), or react, nextjs, vue, angular (app hydrated and DOM quiet)")
+	rootCmd.PersistentFlags().StringVarP(&cfg.LogLevel, "loglevel", "l", "info",
 		"Set the logging level (debug, info, warn, error)")
-	rootCmd.Flags().StringVarP(&cfg.RemoteDebuggingPort, "remote-debugging-port", "r", "",
+	rootCmd.PersistentFlags().StringVarP(&cfg.RemoteDebuggingPort, "remote-debugging-port", "r", "",
 		"Connect to existing Chrome instance with remote debugging (e.g., localhost:9222)")
+	rootCmd.Flags().StringVar(&cfg.Via, "via", "",
+		"Use the Chrome of the daemon listening on this Unix socket instead of starting one, e.g. /run/user/1000/toolbox.sock")
+	rootCmd.Flags().BoolVar(&cfg.Headful, "headful", false,
+		"Show Chrome's window instead of running it headless")
+	rootCmd.Flags().StringVar(&cfg.HeadlessMode, "headless-mode", "",
+		"Chrome's headless implementation, which renders differently: new, old (removed in Chrome 132) or shell (chrome-headless-shell, installed with \"browser install --headless-shell\" or on the PATH) (default: the Chrome binary's)")
+	rootCmd.PersistentFlags().BoolVar(&cfg.SystemChrome, "system-chrome", false,
+		"Start the installed Chrome even when a build of \"browser install\" is available")
+	rootCmd.PersistentFlags().StringVar(&cfg.CacheDir, "cache-dir", "",
+		"Directory of downloaded browsers, Chrome profiles and other cached files (default: that-cli-web-toolbox in $XDG_CACHE_HOME or the user's cache directory)")
+	rootCmd.Flags().StringVar(&cfg.RequireChrome, "require-chrome", "",
+		"Fail before loading pages unless Chrome's version meets all of these space-separated comparisons, e.g. \">=120 <125\" or 131.0.6778")
+	rootCmd.Flags().BoolVar(&cfg.Manifest, "manifest", false,
+		"Write a manifest next to each page's outputs recording the toolbox and Chrome versions, flags, viewport and fonts they were rendered with, and their SHA-256")
+	rootCmd.Flags().IntVar(&cfg.PauseBeforeExit, "pause-before-exit", 0,
+		"With --headful or --remote-debugging-port, keep the page open this many seconds after the actions, to inspect what the tool saw (Enter closes it sooner)")
+	rootCmd.Flags().BoolVar(&cfg.KeepTarget, "keep-target", false,
+		"With --remote-debugging-port, leave the tab open after the run, to inspect it at the DevTools URL logged")
+	rootCmd.PersistentFlags().StringVar(&cfg.OtelEndpoint, "otel-endpoint", "",
+		"Export OpenTelemetry traces of navigation, actions and CDP calls to this OTLP/HTTP collector, e.g. http://localhost:4318")
 	rootCmd.Flags().StringVar(&cfg.JS, "js", "",
 		"Execute custom JavaScript code before taking action (supports async with 'await')")
 	rootCmd.Flags().StringVar(&cfg.JSFile, "js-file", "",
 		"Execute JavaScript from file before taking action (supports async with 'await')")
+	rootCmd.Flags().StringArrayVar(&cfg.Steps, "step", nil,
+		"Interaction step run after --js and before any action, in order (repeatable): click:SEL, type:SEL:TEXT, waitvisible:SEL, scroll:top|bottom|PIXELS|SEL, sleep:DURATION, click-at:X,Y, tap-at:X,Y")
+	rootCmd.Flags().StringVar(&cfg.StepsFile, "steps-file", "",
+		"Read interaction steps from a file, one per line or as a YAML list; run before any --step")
+	rootCmd.Flags().BoolVar(&cfg.ReadClipboard, "read-clipboard", false,
+		"Grant the page clipboard access and report the clipboard's text after JS and steps have run")
+	rootCmd.Flags().StringSliceVar(&cfg.GrantPermissions, "grant-permissions", nil,
+		"Grant the page these permissions before navigation so their prompts do not hang, e.g. geolocation,notifications,clipboard-read")
+	rootCmd.Flags().StringArrayVar(&cfg.ClickAt, "click-at", nil,
+		"Click at viewport coordinates X,Y after any --step, e.g. on a canvas or map (repeatable)")
+	rootCmd.Flags().StringArrayVar(&cfg.TapAt, "tap-at", nil,
+		"Tap with a touch gesture at viewport coordinates X,Y after any --step and --click-at (repeatable)")
+	rootCmd.Flags().StringArrayVar(&cfg.Headers, "header", nil,
+		"Extra HTTP header sent with every request, as \"Name: value\" (repeatable)")
+	rootCmd.Flags().StringVar(&cfg.BasicAuth, "basic-auth", "",
+		"HTTP basic auth credentials as user:pass, only sent to the target's origin")
+	rootCmd.Flags().StringArrayVar(&cfg.Cookies, "cookie", nil,
+		"Cookie set for the target before navigation, as name=value (repeatable)")
+	rootCmd.Flags().StringVar(&cfg.CookiesFile, "cookies-file", "",
+		"Load cookies from a JSON file, such as one written by --save-cookies")
+	rootCmd.Flags().StringVar(&cfg.SaveCookies, "save-cookies", "",
+		"Save the page's cookies as JSON to this file after JS and steps have run")
+	rootCmd.Flags().StringVar(&cfg.SaveState, "save-state", "",
+		"Save the page's cookies, web storage and URL as a .tgz archive to this file after JS and steps have run")
+	rootCmd.Flags().StringVar(&cfg.LoadState, "load-state", "",
+		"Resume a state saved by --save-state: set its cookies and web storage, and load its URL when no target is given")
+	rootCmd.Flags().StringVar(&cfg.ExportAuth, "export-auth", "",
+		"Write the session's Cookie header and the bearer tokens the page sent as shell variables (COOKIE, AUTHORIZATION, BEARER_TOKEN) to this file")
+	rootCmd.Flags().BoolVar(&cfg.EmitCurl, "emit-curl", false,
+		"Write a curl command, with headers and body, for every fetch/XHR request the page makes")
+	rootCmd.Flags().StringVar(&cfg.EmitCurlMatch, "emit-curl-match", "",
+		"With --emit-curl, emit requests of any type whose URL matches this regular expression instead")
+	rootCmd.Flags().StringVar(&cfg.HAR, "har", "",
+		"Record all network requests and write them as a HAR 1.2 file with this name")
+	rootCmd.Flags().StringVar(&cfg.ReplayHAR, "replay-har", "",
+		"Answer the page's requests with the responses recorded in this HAR file, failing the others, to render it offline as it was")
+	rootCmd.Flags().BoolVar(&cfg.FailOnRequestError, "fail-on-request-error", false,
+		"Exit non-zero when any request fails to load or returns a 4xx/5xx status")
+	rootCmd.Flags().BoolVar(&cfg.ErrorSummary, "error-summary", false,
+		"Count console errors, failed requests and 4xx/5xx responses per page")
+	rootCmd.Flags().IntVar(&cfg.FailThreshold, "fail-threshold", -1,
+		"Fail a page whose error count (see --error-summary) exceeds this number; -1 disables")
+	rootCmd.Flags().StringArrayVar(&cfg.FailIf, "fail-if", nil,
+		"Fail a page when this condition over its metrics holds, e.g. 'console_errors > 0 || lcp_ms > 4000 || !selector(\"#footer\")' (repeatable)")
+	rootCmd.Flags().StringVar(&cfg.SortSummary, "sort-summary", sortInput,
+		"Order of the batch summary: input, errors, duration or target")
+	rootCmd.Flags().StringVar(&cfg.CABundle, "ca-bundle", "",
+		"PEM file of extra CA certificates trusted by Chrome and by the tool's own HTTP requests (sinks, source maps, remote browser check)")
+	rootCmd.Flags().BoolVar(&cfg.ResolveSourceMaps, "resolve-sourcemaps", false,
+		"With --consolelog, map exception stack frames to original files and lines through the scripts' source maps")
+	rootCmd.Flags().BoolVar(&cfg.Summary, "summary", false,
+		"Print a triage summary: title, final URL, status, meta description, word count, console errors, requests and load time")
+	rootCmd.Flags().BoolVar(&cfg.TechDetect, "tech-detect", false,
+		"Report the JavaScript frameworks, CMS, analytics, tag managers and servers detected on the page")
+	rootCmd.Flags().StringArrayVar(&cfg.ExpectSelectors, "expect-selector", nil,
+		"Fail unless an element matches this CSS selector (repeatable)")
+	rootCmd.Flags().StringArrayVar(&cfg.Assertions, "assert", nil,
+		"Fail unless elements are in a state, as SELECTOR:visible, :hidden, :enabled, :disabled, :count>=N or :text~=REGEXP (repeatable)")
+	rootCmd.Flags().StringVar(&cfg.ExpectText, "expect-text", "",
+		"Fail unless the page's body text matches this regular expression")
+	rootCmd.Flags().IntVar(&cfg.ExpectStatus, "expect-status", 0,
+		"Fail unless the document is served with this HTTP status, e.g. 200")
+	rootCmd.Flags().DurationVar(&cfg.MaxLoadTime, "max-load-time", 0,
+		"Fail when the page takes longer than this to load, e.g. 5s")
+	rootCmd.Flags().StringArrayVar(&cfg.AssertHeaders, "assert-header", nil,
+		"Fail unless the document's response has a header, as NAME, !NAME (absent), NAME=VALUE or NAME~=REGEXP (repeatable)")
+	rootCmd.Flags().StringVar(&cfg.AssertHeaderMatch, "assert-header-match", "",
+		"Also check --assert-header on the subresources whose URL matches this regular expression")
+	rootCmd.Flags().StringSliceVar(&cfg.Locales, "locales", nil,
+		"Load every target once per locale, e.g. en,de,fr, sending it as Accept-Language and replacing {locale} in the URL")
+	rootCmd.Flags().StringSliceVar(&cfg.ConsentStates, "consent-states", nil,
+		"Load every target once per consent state, e.g. accepted,rejected,none, each in a fresh browser context")
+	rootCmd.Flags().StringArrayVar(&cfg.ConsentSteps, "consent-step", nil,
+		"Interaction step reaching a consent state, as STATE=STEP, e.g. accepted=click:#accept-all (repeatable)")
+	rootCmd.Flags().StringArrayVar(&cfg.ConsentCookies, "consent-cookie", nil,
+		"Cookie pre-seeding a consent state, as STATE=name=value (repeatable)")
+	rootCmd.Flags().StringVar(&cfg.ParamMatrix, "param-matrix", "",
+		"Load every target once per combination of query parameter values, e.g. \"utm_source=a,b;variant=1,2\"")
+	rootCmd.Flags().StringSliceVar(&cfg.Allow, "allow", nil,
+		"Only visit URLs matching these patterns, e.g. /app/* (paths) or https://example.com/* (comma-separated, repeatable)")
+	rootCmd.Flags().StringSliceVar(&cfg.Deny, "deny", nil,
+		"Never visit URLs matching these patterns, e.g. /logout,/admin/delete*; in-page navigation to them is blocked")
+	rootCmd.Flags().StringSliceVar(&cfg.AllowHosts, "allow-hosts", nil,
+		"Block every network request to hosts other than these, e.g. example.com,*.example-cdn.com (comma-separated, repeatable)")
+	rootCmd.Flags().BoolVar(&cfg.Untrusted, "untrusted", false,
+		"Render untrusted HTML, e.g. user-submitted templates, locked down: no network beyond --allow-hosts, no downloads, no other local files, a fresh profile and a timeout of at most 30s")
+	rootCmd.Flags().StringVar(&cfg.IDNPolicy, "idn-policy", "warn",
+		"What to do with internationalized hosts that may impersonate others, e.g. Cyrillic lookalikes of Latin letters: allow, warn or block")
+	rootCmd.Flags().StringVar(&cfg.Sink, "sink", "",
+		"Where to write outputs: file, file:DIR, stdout, http(s)://URL, s3://BUCKET/PREFIX or sqlite:PATH (default: files in the current directory, text on stdout)")
+	rootCmd.Flags().StringVar(&cfg.AuditLog, "audit-log", "",
+		"Append a hash-chained record of the run (user, time, targets, outputs, outcome) to this file")
+	rootCmd.Flags().StringVar(&cfg.SupportBundle, "support-bundle", "",
+		"Write a zip of the run for bug reports: effective flags, debug log, environment, Chrome version, results and outputs")
+	rootCmd.Flags().StringVar(&cfg.GitHubPR, "github-pr", "",
+		"Report the run on this pull request, owner/repo#123: a summary comment with thumbnails of the diffs and a commit status from pass/fail (token in $GITHUB_TOKEN)")
+	rootCmd.Flags().StringVar(&cfg.GitHubContext, "github-context", "that-cli-web-toolbox",
+		"With --github-pr, the name of the commit status, and of the comment, so several runs can report on one pull request")
+	rootCmd.Flags().StringVar(&cfg.GitHubArtifactURL, "github-artifact-url", "",
+		"With --github-pr, the public URL the outputs are served from, e.g. of the --sink bucket, for the thumbnails of the comment")
+	rootCmd.Flags().StringVar(&cfg.NormalizeText, "normalize-text", "",
+		"Unicode normalization form applied to extracted text (nfc, nfd, nfkc, nfkd)")
+	rootCmd.Flags().BoolVar(&cfg.StripEmoji, "strip-emoji", false, "Remove emoji from extracted text")
+	rootCmd.Flags().BoolVar(&cfg.CollapseWhitespace, "collapse-whitespace", false,
+		"Collapse whitespace runs, drop blank lines and zero-width characters in extracted text")
+	rootCmd.Flags().BoolVar(&cfg.RedactPII, "redact-pii", false,
+		"Replace e-mail addresses, phone numbers, payment card numbers and national ID numbers in extracted text with placeholders, and report how many")
+	rootCmd.Flags().StringVar(&cfg.TextEncoding, "text-encoding", "utf-8",
+		"Encoding of text outputs: utf-8, utf-8-bom or utf-16le (with byte order mark)")
+	rootCmd.Flags().StringVar(&cfg.EOL, "eol", "lf",
+		"Line endings of text outputs: lf or crlf")
+	rootCmd.Flags().StringVarP(&cfg.OutputFormat, "output-format", "o", formatText,
+		"Output format: text, json (one document on stdout), ndjson (one line per target) or junit (JUnit XML on stdout, a test case per target)")
+	rootCmd.Flags().StringVarP(&cfg.InputFile, "input-file", "i", "",
+		"Read additional targets from a file, one URL or path per line (# starts a comment)")
+	rootCmd.Flags().StringVar(&cfg.FromBookmarks, "from-bookmarks", "",
+		"Add the bookmarks in this file as targets: an HTML export of any browser, or Chrome's Bookmarks file")
+	rootCmd.Flags().StringVar(&cfg.FromHistory, "from-history", "",
+		"Add the pages in the history of this Chrome profile directory as targets (without a value: the default profile)")
+	rootCmd.Flags().Lookup("from-history").NoOptDefVal = defaultProfile
+	rootCmd.Flags().StringVar(&cfg.SourceMatch, "source-match", "",
+		"Only add bookmarks and history entries whose URL, title or folder matches this regexp")
+	rootCmd.Flags().StringVar(&cfg.SourceSince, "source-since", "",
+		"Only add bookmarks added or pages visited since this long ago (e.g. 7d, 36h) or this date (e.g. 2026-10-01)")
+	rootCmd.Flags().BoolVar(&cfg.Pick, "pick", false,
+		"List the selected bookmarks and history entries and ask which to capture")
+	rootCmd.Flags().IntVar(&cfg.Concurrency, "concurrency", 1,
+		"Number of targets processed in parallel when running several targets")
+	rootCmd.Flags().BoolVar(&cfg.NoBrowser, "no-browser", false,
+		"Extract --gettextbycssselector text from the HTML fetched with a plain HTTP client, without starting Chrome")
+	rootCmd.Flags().BoolVar(&cfg.Auto, "auto", false,
+		"Like --no-browser, but render a target in Chrome when its HTML is not enough (not HTML, or a selector matches nothing)")
+	rootCmd.Flags().BoolVar(&cfg.CompareNoJS, "compare-nojs", false,
+		"Load the page again with JavaScript disabled and report the headings, text, links and images only JavaScript renders, which crawlers without it miss")
+	rootCmd.Flags().BoolVar(&cfg.AsGooglebot, "as-googlebot", false,
+		"Load pages as Googlebot Smartphone: its user agent and From header")
+	rootCmd.Flags().BoolVar(&cfg.CompareGooglebot, "compare-googlebot", false,
+		"Load the page again as Googlebot and report what differs from the page served to users, with cloaking indicators")
+	rootCmd.Flags().BoolVar(&cfg.DetectSoft404, "detect-soft-404", false,
+		"Fail pages served with a success status that look like error pages (title, headings, text, URL and layout heuristics)")
+	rootCmd.Flags().BoolVar(&cfg.OverlayReport, "overlay-report", false,
+		"Measure how much of the first screen fixed and sticky overlays (cookie banners, modals, chat widgets) cover, failing pages over --overlay-threshold")
+	rootCmd.Flags().Float64Var(&cfg.OverlayThreshold, "overlay-threshold", 30,
+		"With --overlay-report, fail pages whose overlays cover more than this percentage of the viewport")
+	rootCmd.Flags().StringVar(&cfg.DesignBaseline, "design-baseline", "",
+		"Compare the page with this exported design image (PNG or JPEG), aligned to it, writing an overlay of the differences and failing over --tolerance")
+	rootCmd.Flags().StringVar(&cfg.BaselineDir, "baseline-dir", "",
+		"Compare the page's screenshot with its approved baseline in this directory, per URL, viewport and theme, keeping new and changed ones for \"baseline approve\"")
+	rootCmd.Flags().StringVar(&cfg.Tolerance, "tolerance", "0%",
+		"With --design-baseline or --baseline-dir, fail pages where more than this percentage of the pixels differ, e.g. 5%")
+	rootCmd.Flags().StringArrayVar(&cfg.IgnoreRegions, "ignore-region", nil,
+		"With --design-baseline or --baseline-dir, do not compare the elements matching a CSS selector, or a region of the page as X,Y,WIDTH,HEIGHT in CSS pixels (repeatable)")
+	rootCmd.Flags().StringVar(&cfg.IgnoreRegionsFile, "ignore-regions-file", "",
+		"With --design-baseline or --baseline-dir, also ignore the regions listed under \"ignore\" in this YAML file, written like --ignore-region")
+	rootCmd.Flags().BoolVar(&cfg.KeyboardAudit, "keyboard-audit", false,
+		"Press Tab through the page and report the focus order, elements without a visible focus indicator and keyboard traps")
+	rootCmd.Flags().BoolVar(&cfg.DetectDuplicates, "detect-duplicates", false,
+		"Report rel=canonical mismatches and, across several targets, near-duplicate pages by text SimHash")
+	rootCmd.Flags().StringVar(&cfg.EmitSitemap, "emit-sitemap", "",
+		"Write an XML sitemap of the successfully loaded targets, with lastmod from Last-Modified headers, to this file")
+	rootCmd.Flags().StringVar(&cfg.VisualSitemap, "visual-sitemap", "",
+		"Write an HTML report with thumbnails of every target, arranged by URL hierarchy, to this file")
+	rootCmd.Flags().StringSliceVar(&cfg.Order, "order", nil,
+		"Comma-separated order to run actions in, e.g. screenshot,pdf; unlisted actions follow ("+strings.Join(actionNames(), ", ")+")")
+	rootCmd.Flags().BoolVar(&cfg.ContinueOnError, "continue-on-error", false,
+		"Run and report the other actions on the page when one fails, e.g. extract text even if the PDF fails; partial failures exit with code 10")
+	rootCmd.Flags().StringVar(&cfg.ChangedOnly, "changed-only", "",
+		"Only capture the targets whose HTTP response changed (ETag, Last-Modified or content hash) since the runs that kept their versions in this JSON file, created if missing")
+	rootCmd.Flags().StringVar(&cfg.SiteSettings, "site-settings", "",
+		"Keep per-site settings in this JSON file, created if missing: page loads use and update the learned delay and cookie banner button of their site, and send its headers")
+	rootCmd.Flags().StringVar(&cfg.ConfigFile, "config", "",
+		"Read flags and targets from this YAML file, e.g. one written by \"init\"; flags and targets on the command line win")
+	rootCmd.Flags().StringVar(&cfg.JobProfile, "job-profile", "",
+		"Save the flags and targets of the command line as this named job profile, to run with \"run-job NAME\", instead of running them")
+	rootCmd.Flags().StringVar(&cfg.JobSchedule, "job-schedule", "",
+		"With --job-profile, the cron schedule of the job, e.g. \"0 6 1 * *\", for \"run-job --crontab\"")
 }
 
 func main() {
 	if err := rootCmd.Execute(); err != nil {
-		fmt.Println(err)
-		os.Exit(1)
+		switch {
+		case errors.Is(err, errReported):
+		case cfg.OutputFormat == formatJUnit:
+			// Keep stdout a valid report
+			fmt.Fprintln(os.Stderr, err)
+		default:
+			fmt.Println(err)
+		}
+		os.Exit(exitCode(err))
 	}
 }
 
-func runThatCliWebBrowser(cmd *cobra.Command, args []string) error {
-	// Initialize slog directly
+// setupLogging installs the default slog logger at the named level.
+func setupLogging(levelName string) {
 	var level slog.Level
-	switch strings.ToLower(cfg.LogLevel) {
+	switch strings.ToLower(levelName) {
 	case "debug":
 		level = slog.LevelDebug
 	case "info":
@@ -125,52 +799,91 @@ func runThatCliWebBrowser(cmd *cobra.Command, args []string) error {
 	handler := slog.NewTextHandler(os.Stderr, opts)
 	logger := slog.New(handler)
 	slog.SetDefault(logger)
+}
 
-	slog.Debug("Starting that-cli-web-toolbox",
-		"timeout", cfg.Timeout,
-		"delay", cfg.Delay,
-		"logLevel", cfg.LogLevel,
-		"consoleLog", cfg.ConsoleLog,
-		"screenshot", cfg.Screenshot,
-		"printToPDF", cfg.PrintToPDF,
-		"getBody", cfg.GetBody,
-		"cssSelector", cfg.GetTextByCssSelector,
-		"js", cfg.JS,
-		"jsFile", cfg.JSFile)
+func runThatCliWebBrowser(cmd *cobra.Command, args []string) (err error) {
+	if cfg.ConfigFile != "" {
+		c, err := loadCaptureConfig(cfg.ConfigFile)
+		if err == nil {
+			args, err = c.apply(cmd.Flags(), args)
+		}
+		if err != nil {
+			setupLogging(cfg.LogLevel)
+			slog.Error("Failed to read config file", "file", cfg.ConfigFile, "error", err)
+			return fmt.Errorf("failed to read config file %q: %w", cfg.ConfigFile, err)
+		}
+	}
+	setupLogging(cfg.LogLevel)
+
+	if cfg.JobSchedule != "" && cfg.JobProfile == "" {
+		slog.Error("--job-schedule specified without --job-profile")
+		return fmt.Errorf("--job-schedule requires --job-profile")
+	}
+	if cfg.JobProfile != "" {
+		if err := saveJobProfile(cmd.Flags(), args); err != nil {
+			slog.Error("Failed to save job profile", "name", cfg.JobProfile, "error", err)
+			return fmt.Errorf("failed to save job profile %q: %w", cfg.JobProfile, err)
+		}
+		return nil
+	}
 
-	if len(args) == 0 {
-		slog.Error("No target URL or file path provided")
-		return fmt.Errorf("target URL or file path is required")
+	var recorder *auditRecorder
+	if cfg.AuditLog != "" {
+		recorder = newAuditRecorder()
+		defer func() { err = recorder.append(cfg.AuditLog, err) }()
+	}
+	var bundle *supportBundle
+	if cfg.SupportBundle != "" {
+		bundle = newSupportBundle()
+		defer func() { err = bundle.write(cmd.Context(), cfg.SupportBundle, cmd.Flags(), err) }()
 	}
 
-	input := args[0]
-	slog.Debug("Processing input", "input", input)
+	logStart(&cfg)
 
-	// Validate input
-	if strings.TrimSpace(input) == "" {
-		slog.Error("Empty target provided")
-		return fmt.Errorf("target cannot be empty")
+	inputs, state, err := collectInputs(&cfg, args)
+	if err != nil {
+		return err
 	}
+	recorder.setTargets(inputs)
 
-	// Detect if input is a local file
-	var target string
-	if _, err := os.Stat(input); err == nil {
-		abs, err := filepath.Abs(input)
-		if err != nil {
-			slog.Error("Failed to get absolute path", "input", input, "error", err)
-			return fmt.Errorf("failed to get absolute path for %q: %w", input, err)
-		}
-		target = "file://" + abs
-		slog.Debug("Input detected as local file", "absolutePath", abs)
-	} else {
-		// Basic URL validation
-		if !strings.HasPrefix(input, "http://") && !strings.HasPrefix(input, "https://") && !strings.HasPrefix(input, "file://") {
-			slog.Warn("Input does not appear to be a valid URL, treating as URL anyway", "input", input)
-		}
-		target = input
-		slog.Debug("Input treated as URL", "url", target)
+	// Validate URL allow and deny patterns
+	filter, err := urlfilter.New(cfg.Allow, cfg.Deny)
+	if err != nil {
+		slog.Error("Invalid URL filter", "error", err)
+		return err
+	}
+	if allowedHosts, err = urlfilter.NewHosts(cfg.AllowHosts); err != nil {
+		slog.Error("Invalid allowed hosts", "error", err)
+		return err
+	}
+
+	if err := validateIDNPolicy(&cfg); err != nil {
+		return err
+	}
+
+	// Validate locales
+	if err := validateLocales(cfg.Locales); err != nil {
+		slog.Error("Invalid locale", "error", err)
+		return err
+	}
+
+	// Validate the language of extracted text
+	if cfg.OnlyLang != "" && !localeTag.MatchString(cfg.OnlyLang) {
+		slog.Error("Invalid language", "onlyLang", cfg.OnlyLang)
+		return fmt.Errorf("invalid --only-lang %q (expected a language tag such as en, de or pt-BR)", cfg.OnlyLang)
+	}
+
+	targets, err := filterTargets(&cfg, inputs, filter)
+	if err != nil {
+		return err
+	}
+	cfg.Target = targets[0].URL
+
+	// Validate concurrency parameter
+	if cfg.Concurrency < 1 {
+		slog.Error("Invalid concurrency value", "concurrency", cfg.Concurrency)
+		return fmt.Errorf("concurrency must be at least 1: %d", cfg.Concurrency)
 	}
-	cfg.Target = target
 
 	// Validate delay parameter
 	if cfg.Delay < 0 {
@@ -191,10 +904,129 @@ func runThatCliWebBrowser(cmd *cobra.Command, args []string) error {
 			"newTimeout", cfg.Timeout)
 	}
 
+	if err := validateTextOutput(&cfg); err != nil {
+		return err
+	}
+
+	// Actions' HTTP clients are set up with the CA bundle during validation
+	if cfg.CABundle != "" {
+		certs, err := loadCABundle(cfg.CABundle)
+		if err != nil {
+			slog.Error("Invalid CA bundle", "file", cfg.CABundle, "error", err)
+			return err
+		}
+		caCerts = certs
+		slog.Debug("CA bundle loaded", "file", cfg.CABundle, "certificates", len(certs))
+	}
+
 	// Validate that at least one action is specified
-	if !cfg.ConsoleLog && !cfg.Screenshot && !cfg.PrintToPDF && !cfg.GetBody && cfg.GetTextByCssSelector == "" {
+	pipeline, err := buildPipeline(&cfg, cfg.Order)
+	if err != nil {
+		slog.Error("Invalid action configuration", "error", err)
+		return err
+	}
+	if len(pipeline) == 0 {
 		slog.Error("No action specified")
-		return fmt.Errorf("at least one action must be specified (--body, --screenshot, --printtopdf, --consolelog, or --gettextbycssselector)")
+		return fmt.Errorf("at least one action must be specified (%s)", actionFlags())
+	}
+
+	// Validate max redirects parameter
+	if cfg.MaxRedirects < 0 {
+		slog.Error("Invalid max redirects value", "maxRedirects", cfg.MaxRedirects)
+		return fmt.Errorf("max redirects cannot be negative: %d", cfg.MaxRedirects)
+	}
+	if cfg.MaxRequests < 0 {
+		slog.Error("Invalid max requests value", "maxRequests", cfg.MaxRequests)
+		return fmt.Errorf("max requests cannot be negative: %d", cfg.MaxRequests)
+	}
+
+	// Validate screenshot format and quality
+	if cfg.ScreenshotFormat != "" {
+		if _, err := chromedphelper.ParseImageFormat(cfg.ScreenshotFormat); err != nil {
+			slog.Error("Invalid screenshot format", "format", cfg.ScreenshotFormat)
+			return err
+		}
+	}
+	if cfg.ScreenshotQuality < 1 || cfg.ScreenshotQuality > 100 {
+		slog.Error("Invalid screenshot quality", "quality", cfg.ScreenshotQuality)
+		return fmt.Errorf("screenshot quality must be between 1 and 100: %d", cfg.ScreenshotQuality)
+	}
+	if format, _ := chromedphelper.ParseImageFormat(cfg.ScreenshotFormat); cfg.OmitBackground && format == chromedphelper.JPEG {
+		return fmt.Errorf("--omit-background needs --screenshot-format png or webp, since jpeg has no transparency")
+	}
+
+	// Validate summary order
+	if err := validateSummarySort(cfg.SortSummary); err != nil {
+		slog.Error("Invalid summary sort", "sort", cfg.SortSummary)
+		return err
+	}
+
+	// Validate output format
+	if err := validateOutputFormat(); err != nil {
+		slog.Error("Invalid output format", "format", cfg.OutputFormat, "error", err)
+		return err
+	}
+
+	// --sanitize only applies to the HTML dump
+	if cfg.Sanitize && !cfg.DumpHTML {
+		slog.Error("--sanitize specified without --html")
+		return fmt.Errorf("--sanitize requires --html")
+	}
+
+	// --highlight only shows in captures
+	if len(cfg.Highlight) > 0 && !cfg.Screenshot && len(cfg.ScreenshotSelectors) == 0 && cfg.ScreenshotEach == "" && !cfg.PrintToPDF {
+		slog.Error("--highlight specified without a screenshot or PDF")
+		return fmt.Errorf("--highlight requires --screenshot, --screenshot-selector, --screenshot-each or --printtopdf")
+	}
+
+	if cfg.PDFTOC < 0 {
+		slog.Error("Invalid PDF table of contents threshold", "pdfTOC", cfg.PDFTOC)
+		return fmt.Errorf("--pdf-toc must not be negative")
+	}
+	if cfg.PDFTOC > 0 && !cfg.PrintToPDF {
+		slog.Error("--pdf-toc specified without --printtopdf")
+		return fmt.Errorf("--pdf-toc requires --printtopdf")
+	}
+
+	if err := validateSelectors("--mask", cfg.Mask); err != nil {
+		return err
+	}
+	// Milestone screenshots are taken while the page loads, before
+	// anything can be masked
+	if len(cfg.Mask) > 0 && cfg.ScreenshotAt != "" {
+		slog.Error("--mask specified with --screenshot-at")
+		return fmt.Errorf("--mask cannot be combined with --screenshot-at, which captures the page before it is masked")
+	}
+
+	if cfg.AnnotateInteractives && !cfg.Screenshot {
+		slog.Error("--annotate-interactives specified without --screenshot")
+		return fmt.Errorf("--annotate-interactives requires --screenshot")
+	}
+
+	if err := validateAnnotateJSON(&cfg); err != nil {
+		return err
+	}
+
+	// Tabs of one browser share its clipboard
+	if cfg.ReadClipboard && cfg.Concurrency > 1 {
+		slog.Error("--read-clipboard specified with --concurrency", "concurrency", cfg.Concurrency)
+		return fmt.Errorf("--read-clipboard cannot be used with --concurrency above 1, since all tabs share one clipboard")
+	}
+
+	if cfg.Limit != 0 && cfg.ScreenshotEach == "" {
+		slog.Error("--limit specified without --screenshot-each")
+		return fmt.Errorf("--limit requires --screenshot-each")
+	}
+
+	if cfg.EmitCurlMatch != "" && !cfg.EmitCurl {
+		slog.Error("--emit-curl-match specified without --emit-curl")
+		return fmt.Errorf("--emit-curl-match requires --emit-curl")
+	}
+
+	// --resolve-sourcemaps only applies to captured exceptions
+	if cfg.ResolveSourceMaps && !cfg.ConsoleLog {
+		slog.Error("--resolve-sourcemaps specified without --consolelog")
+		return fmt.Errorf("--resolve-sourcemaps requires --consolelog")
 	}
 
 	// Validate --js and --js-file are mutually exclusive
@@ -219,94 +1051,681 @@ func runThatCliWebBrowser(cmd *cobra.Command, args []string) error {
 		slog.Debug("Using inline JavaScript", "codeLength", len(jsCode))
 	}
 
-	// Initialize browser
-	if cfg.RemoteDebuggingPort != "" {
-		slog.Debug("Connecting to existing browser", "target", cfg.Target, "timeout", cfg.Timeout, "delay", cfg.Delay, "remotePort", cfg.RemoteDebuggingPort)
-	} else {
-		slog.Debug("Initializing new browser", "target", cfg.Target, "timeout", cfg.Timeout, "delay", cfg.Delay)
+	if err := validateVia(&cfg); err != nil {
+		return err
+	}
+
+	// Replayed pages are rendered offline, so targets are not fetched
+	if cfg.ReplayHAR != "" && cfg.ChangedOnly != "" {
+		slog.Error("--replay-har specified with --changed-only", "replayHar", cfg.ReplayHAR)
+		return fmt.Errorf("--replay-har cannot be used with --changed-only, which fetches the live pages")
+	}
+
+	if err := validateTor(&cfg); err != nil {
+		return err
+	}
+
+	if err := validateUntrusted(&cfg); err != nil {
+		return err
 	}
-	browser, err := chromedphelper.InitializeChromedp(cfg.Target, cfg.Timeout, cfg.Delay, cfg.RemoteDebuggingPort, jsCode)
+
+	// Validate proxy pool
+	if cfg.ProxyPool != "" && cfg.RemoteDebuggingPort != "" {
+		slog.Error("--proxy-pool specified with --remote-debugging-port")
+		return fmt.Errorf("--proxy-pool cannot be used with --remote-debugging-port")
+	}
+
+	// Keeping the tab needs a browser that outlives the run
+	if cfg.KeepTarget && cfg.RemoteDebuggingPort == "" {
+		slog.Error("--keep-target specified without --remote-debugging-port")
+		return fmt.Errorf("--keep-target requires --remote-debugging-port; a browser started by the tool exits with it")
+	}
+	if cfg.KeepTarget && len(targets) > 1 {
+		slog.Error("--keep-target specified with several targets")
+		return fmt.Errorf("--keep-target requires a single target")
+	}
+
+	if err := validateHeadlessMode(&cfg); err != nil {
+		return err
+	}
+
+	if err := validatePause(&cfg, len(targets)); err != nil {
+		return err
+	}
+
+	if err := validateFingerprint(&cfg); err != nil {
+		return err
+	}
+	// The comparison needs the page as users see it
+	if cfg.AsGooglebot && cfg.CompareGooglebot {
+		slog.Error("--as-googlebot specified with --compare-googlebot")
+		return fmt.Errorf("--as-googlebot and --compare-googlebot are mutually exclusive, --compare-googlebot loads the page as Googlebot itself")
+	}
+
+	if err := validateSavedState(&cfg, len(targets)); err != nil {
+		return err
+	}
+
+	// Parse interaction steps, headers, cookies and credentials
+	setup, err := loadPageSetup(&cfg)
 	if err != nil {
-		slog.Error("Failed to initialize browser", "error", err)
-		return fmt.Errorf("failed to initialize browser: %w", err)
+		slog.Error("Invalid page setup", "error", err)
+		return err
+	}
+	setup.Filter = filter
+	setup.AllowedHosts = allowedHosts
+	if state != nil {
+		// --cookies-file and --cookie override the saved cookies
+		setup.Cookies = append(state.Cookies, setup.Cookies...)
+		setup.Storage = state.Storage
+	}
+	if cfg.OutputFormat == formatJUnit || cfg.GitHubPR != "" || setup.Changes != nil || setup.History != nil || bundle != nil {
+		setup.Outcomes = &runOutcomes{}
 	}
-	defer browser.Cancel()
+	if setup.History != nil {
+		defer func() {
+			// Pages finished before an interruption are recorded too
+			recordHistory(context.WithoutCancel(cmd.Context()), setup.History, setup.Outcomes.all())
+			if err := setup.History.Close(); err != nil {
+				slog.Warn("Failed to close page history", "db", historyDB(&cfg), "error", err)
+			}
+		}()
+	}
+	bundle.watch(setup.Outcomes)
+	if cfg.OutputFormat == formatJUnit {
+		report := newJUnitReport(setup.Outcomes)
+		defer func() { err = report.write(err) }()
+	}
+	if cfg.GitHubPR != "" {
+		reporter, ghErr := newGitHubReporter(cmd.Context(), &cfg, setup.Outcomes)
+		if ghErr != nil {
+			slog.Error("Failed to report on pull request", "pr", cfg.GitHubPR, "error", ghErr)
+			return ghErr
+		}
+		defer func() { err = reporter.finish(cmd.Context(), err) }()
+	}
+	defer func() {
+		if err := setup.Sites.save(); err != nil {
+			slog.Warn("Failed to save site settings", "file", cfg.SiteSettings, "error", err)
+		}
+	}()
 
-	// Setup console log listeners before navigation (if needed)
-	if cfg.ConsoleLog {
-		slog.Info("Setting up console log capture")
-		browser.SetupConsoleLogListeners()
+	if err := validateStatic(&cfg, pipeline, setup); err != nil {
+		slog.Error("Invalid static extraction", "error", err)
+		return err
 	}
 
-	// Navigate to target URL, apply delay, and execute custom JS (once for all actions)
-	slog.Info("Navigating to target and preparing page", "url", cfg.Target)
-	if err := browser.NavigateAndPrepare(); err != nil {
-		slog.Error("Failed to navigate and prepare page", "error", err)
-		return fmt.Errorf("failed to navigate and prepare page: %w", err)
+	artifactSink, textSink, err := openSinks(cfg.Sink)
+	if err != nil {
+		slog.Error("Failed to open output sink", "sink", cfg.Sink, "error", err)
+		return fmt.Errorf("failed to open output sink: %w", err)
+	}
+	if closer, ok := artifactSink.(io.Closer); ok {
+		defer func() {
+			if err := closer.Close(); err != nil {
+				slog.Warn("Failed to close output sink", "sink", cfg.Sink, "error", err)
+			}
+		}()
 	}
+	artifactSink, textSink = recorder.wrap(artifactSink), recorder.wrap(textSink)
+	artifactSink, textSink = bundle.wrap(artifactSink), bundle.wrap(textSink)
 
-	// Handle GetTextByCssSelector
-	if cfg.GetTextByCssSelector != "" {
-		slog.Debug("Getting text by CSS selector", "selector", cfg.GetTextByCssSelector)
-		text, err := browser.GetTextBySelector(cfg.GetTextByCssSelector)
-		if err != nil {
-			slog.Error("Failed to get text by selector", "selector", cfg.GetTextByCssSelector, "error", err)
-			return fmt.Errorf("failed to get text by selector: %w", err)
+	ctx, stopTracing, err := startTracing(cmd.Context())
+	if err != nil {
+		slog.Error("Failed to set up tracing", "endpoint", cfg.OtelEndpoint, "error", err)
+		return err
+	}
+	defer stopTracing()
+	ctx, span := tracing.Start(ctx, "that-cli-web-toolbox", tracing.Int("targets", len(targets)))
+	defer span.End()
+
+	if err := connectVia(ctx, &cfg); err != nil {
+		return err
+	}
+	if setup.Proxies != nil {
+		slog.Info("Checking proxies", "proxies", len(setup.Proxies.proxies))
+		if err := setup.Proxies.healthCheck(ctx); err != nil {
+			slog.Error("Proxy health check failed", "error", err)
+			return err
 		}
-		slog.Debug("Successfully extracted text", "selector", cfg.GetTextByCssSelector, "textLength", len(text))
-		fmt.Println(text)
 	}
+	circuits, stopCircuits, err := startCircuitRotation(ctx, &cfg, len(targets))
+	if err != nil {
+		return err
+	}
+	defer stopCircuits()
+	setup.Circuits = circuits
 
-	// Handle GetBody
-	if cfg.GetBody {
-		slog.Info("Getting body text")
-		text, err := browser.GetBodyText()
-		if err != nil {
-			slog.Error("Failed to get body text", "error", err)
-			return fmt.Errorf("failed to get body text: %w", err)
+	if setup.Changes != nil {
+		all := len(targets)
+		if targets = setup.Changes.filter(ctx, targets, setup); len(targets) == 0 {
+			slog.Info("No target changed since the last run", "checked", all)
+			switch cfg.OutputFormat {
+			case formatText:
+				fmt.Printf("No target changed since the last run (%d checked)\n", all)
+			case formatJSON:
+				// An empty result set rather than no document at all
+				return emitJSON([]*chromedphelper.Result{})
+			}
+			return nil
 		}
-		slog.Debug("Successfully extracted body text", "textLength", len(text))
-		fmt.Println(text)
+		slog.Info("Capturing the targets that changed since the last run", "changed", len(targets), "checked", all)
+		defer func() {
+			setup.Changes.keep(setup.Outcomes.all())
+			if err := setup.Changes.save(); err != nil {
+				slog.Warn("Failed to save changed-only state", "file", cfg.ChangedOnly, "error", err)
+			}
+		}()
+	}
+
+	if len(targets) > 1 {
+		return runBatch(ctx, targets, jsCode, setup, artifactSink, textSink)
 	}
 
-	// Handle screenshot
-	if cfg.Screenshot {
-		slog.Info("Taking screenshot")
-		imageBuf, err := browser.TakeScreenshot()
+	start := time.Now()
+	run, err := runStaticTarget(ctx, targets[0], setup, "", false, artifactSink, textSink)
+	if errors.Is(err, errRender) {
+		static := err
+		run, err = runSingle(ctx, pipeline, targets[0], jsCode, setup, artifactSink, textSink)
+		// The crashed tab took the browser session with it; start over once
+		if crash := tabCrash(err); crash != nil {
+			slog.Warn("Retrying target in a new browser after a crash", "target", cfg.Target, "crash", crash.Kind)
+			if pipeline, err = buildPipeline(&cfg, cfg.Order); err != nil {
+				return err
+			}
+			run, err = runSingle(ctx, pipeline, targets[0], jsCode, setup, artifactSink, textSink)
+			recoverCrash(run, crash)
+		}
+		recordEscalation(run, static)
+	}
+	if run == nil {
+		return err
+	}
+	setup.Outcomes.add(batchResult{Target: targets[0].String(), Err: err, Duration: time.Since(start), History: run.History, Result: run.Result})
+	if structuredOutput() && cfg.OutputFormat != formatJUnit {
 		if err != nil {
-			slog.Error("Failed to take screenshot", "error", err)
-			return fmt.Errorf("failed to take screenshot: %w", err)
+			run.Result.Error = err.Error()
+		}
+		if emitErr := emitJSON(run.Result); emitErr != nil {
+			slog.Error("Failed to write results", "error", emitErr)
+			return emitErr
 		}
+	}
+	if err != nil {
+		return err
+	}
 
-		fileName := fmt.Sprintf("screenshot_%s.jpg", time.Now().Format("20060102150405"))
-		slog.Debug("Saving screenshot", "fileName", fileName, "size", len(imageBuf))
-		if err := os.WriteFile(fileName, imageBuf, 0o644); err != nil {
-			slog.Error("Failed to save screenshot", "fileName", fileName, "error", err)
-			return fmt.Errorf("failed to save screenshot %q: %w", fileName, err)
+	if cfg.EmitSitemap != "" {
+		if err := writeSitemap(ctx, artifactSink, cfg.EmitSitemap, []*sitemapEntry{run.Sitemap}); err != nil {
+			return err
+		}
+	}
+	if cfg.VisualSitemap != "" {
+		if err := writeVisualSitemap(ctx, artifactSink, cfg.VisualSitemap, []*visualPage{run.Visual}); err != nil {
+			return err
 		}
-		slog.Info("Screenshot saved successfully", "fileName", fileName)
-		fmt.Printf("Screenshot saved as %s\n", fileName)
 	}
 
-	// Handle print to PDF
-	if cfg.PrintToPDF {
-		slog.Info("Printing to PDF")
-		pdfBuf, err := browser.PrintToPDF()
+	slog.Debug("Command execution completed successfully")
+	return nil
+}
+
+// logStart logs the configuration of a run.
+func logStart(cfg *Config) {
+	slog.Debug("Starting that-cli-web-toolbox",
+		"timeout", cfg.Timeout,
+		"delay", cfg.Delay,
+		"readyStrategy", cfg.ReadyStrategy,
+		"logLevel", cfg.LogLevel,
+		"otelEndpoint", cfg.OtelEndpoint,
+		"via", cfg.Via,
+		"headful", cfg.Headful,
+		"headlessMode", cfg.HeadlessMode,
+		"systemChrome", cfg.SystemChrome,
+		"cacheDir", cfg.CacheDir,
+		"requireChrome", cfg.RequireChrome,
+		"manifest", cfg.Manifest,
+		"pauseBeforeExit", cfg.PauseBeforeExit,
+		"keepTarget", cfg.KeepTarget,
+		"consoleLog", cfg.ConsoleLog,
+		"screenshot", cfg.Screenshot,
+		"printToPDF", cfg.PrintToPDF,
+		"pdfTOC", cfg.PDFTOC,
+		"mhtml", cfg.MHTML,
+		"metadata", cfg.Metadata,
+		"getBody", cfg.GetBody,
+		"outline", cfg.Outline,
+		"onlyLang", cfg.OnlyLang,
+		"html", cfg.DumpHTML,
+		"sanitize", cfg.Sanitize,
+		"criticalCSS", cfg.CriticalCSS,
+		"aboveFold", cfg.AboveFold,
+		"contentMap", cfg.ContentMap,
+		"landmarks", cfg.Landmarks,
+		"accessibleNames", cfg.AccessibleNames,
+		"contrastCheck", cfg.ContrastCheck,
+		"contrastLevel", cfg.ContrastLevel,
+		"cssSelector", cfg.GetTextByCssSelector,
+		"jsonPaths", cfg.JSONPaths,
+		"preset", cfg.Preset,
+		"screenshotSelectors", cfg.ScreenshotSelectors,
+		"screenshotEach", cfg.ScreenshotEach,
+		"svg", cfg.SVG,
+		"domSnapshot", cfg.DOMSnapshot,
+		"domSnapshotStyles", cfg.DOMSnapshotStyles,
+		"limit", cfg.Limit,
+		"highlight", cfg.Highlight,
+		"mask", cfg.Mask,
+		"annotateInteractives", cfg.AnnotateInteractives,
+		"annotateJSON", cfg.AnnotateJSON,
+		"annotateSelectors", cfg.AnnotateSelectors,
+		"js", cfg.JS != "",
+		"jsFile", cfg.JSFile,
+		"steps", len(cfg.Steps),
+		"stepsFile", cfg.StepsFile,
+		"clickAt", cfg.ClickAt,
+		"tapAt", cfg.TapAt,
+		"readClipboard", cfg.ReadClipboard,
+		"grantPermissions", cfg.GrantPermissions,
+		"headers", len(cfg.Headers),
+		"basicAuth", cfg.BasicAuth != "",
+		"cookies", len(cfg.Cookies),
+		"cookiesFile", cfg.CookiesFile,
+		"saveCookies", cfg.SaveCookies,
+		"saveState", cfg.SaveState,
+		"loadState", cfg.LoadState,
+		"exportAuth", cfg.ExportAuth,
+		"allow", cfg.Allow,
+		"viewport", cfg.Viewport,
+		"device", cfg.Device,
+		"darkMode", cfg.DarkMode,
+		"fullPage", cfg.FullPage,
+		"screenshotAt", cfg.ScreenshotAt,
+		"screenshotFormat", cfg.ScreenshotFormat,
+		"screenshotQuality", cfg.ScreenshotQuality,
+		"omitBackground", cfg.OmitBackground,
+		"captureBeyondViewport", cfg.BeyondViewport,
+		"fromSurface", cfg.FromSurface,
+		"maxRedirects", cfg.MaxRedirects,
+		"maxBytes", cfg.MaxBytes,
+		"maxRequests", cfg.MaxRequests,
+		"deny", cfg.Deny,
+		"allowHosts", cfg.AllowHosts,
+		"untrusted", cfg.Untrusted,
+		"idnPolicy", cfg.IDNPolicy,
+		"sink", cfg.Sink,
+		"auditLog", cfg.AuditLog,
+		"supportBundle", cfg.SupportBundle,
+		"githubPR", cfg.GitHubPR,
+		"githubContext", cfg.GitHubContext,
+		"githubArtifactURL", cfg.GitHubArtifactURL,
+		"caBundle", cfg.CABundle,
+		"order", cfg.Order,
+		"continueOnError", cfg.ContinueOnError,
+		"siteSettings", cfg.SiteSettings,
+		"changedOnly", cfg.ChangedOnly,
+		"config", cfg.ConfigFile,
+		"jobProfile", cfg.JobProfile,
+		"jobSchedule", cfg.JobSchedule,
+		"normalizeText", cfg.NormalizeText,
+		"stripEmoji", cfg.StripEmoji,
+		"collapseWhitespace", cfg.CollapseWhitespace,
+		"redactPII", cfg.RedactPII,
+		"textEncoding", cfg.TextEncoding,
+		"eol", cfg.EOL,
+		"inputFile", cfg.InputFile,
+		"fromBookmarks", cfg.FromBookmarks,
+		"fromHistory", cfg.FromHistory,
+		"sourceMatch", cfg.SourceMatch,
+		"sourceSince", cfg.SourceSince,
+		"pick", cfg.Pick,
+		"concurrency", cfg.Concurrency,
+		"detectDuplicates", cfg.DetectDuplicates,
+		"emitSitemap", cfg.EmitSitemap,
+		"visualSitemap", cfg.VisualSitemap,
+		"har", cfg.HAR,
+		"replayHar", cfg.ReplayHAR,
+		"emitCurl", cfg.EmitCurl,
+		"emitCurlMatch", cfg.EmitCurlMatch,
+		"failOnRequestError", cfg.FailOnRequestError,
+		"errorSummary", cfg.ErrorSummary,
+		"failThreshold", cfg.FailThreshold,
+		"failIf", cfg.FailIf,
+		"sortSummary", cfg.SortSummary,
+		"summary", cfg.Summary,
+		"techDetect", cfg.TechDetect,
+		"compareNoJS", cfg.CompareNoJS,
+		"asGooglebot", cfg.AsGooglebot,
+		"compareGooglebot", cfg.CompareGooglebot,
+		"detectSoft404", cfg.DetectSoft404,
+		"overlayReport", cfg.OverlayReport,
+		"overlayThreshold", cfg.OverlayThreshold,
+		"designBaseline", cfg.DesignBaseline,
+		"tolerance", cfg.Tolerance,
+		"ignoreRegions", cfg.IgnoreRegions,
+		"ignoreRegionsFile", cfg.IgnoreRegionsFile,
+		"baselineDir", cfg.BaselineDir,
+		"keyboardAudit", cfg.KeyboardAudit,
+		"resolveSourceMaps", cfg.ResolveSourceMaps,
+		"locales", cfg.Locales,
+		"consentStates", cfg.ConsentStates,
+		"consentSteps", len(cfg.ConsentSteps),
+		"consentCookies", len(cfg.ConsentCookies),
+		"paramMatrix", cfg.ParamMatrix,
+		"tor", cfg.Tor,
+		"torSocks", cfg.TorSocks,
+		"torControl", cfg.TorControl,
+		"torRotate", cfg.TorRotate,
+		"proxyPool", cfg.ProxyPool,
+		"proxyStrategy", cfg.ProxyStrategy,
+		"fingerprintProfile", cfg.FingerprintProfile,
+		"expectSelectors", cfg.ExpectSelectors,
+		"assertions", cfg.Assertions,
+		"expectText", cfg.ExpectText,
+		"expectStatus", cfg.ExpectStatus,
+		"maxLoadTime", cfg.MaxLoadTime,
+		"assertHeaders", cfg.AssertHeaders,
+		"assertHeaderMatch", cfg.AssertHeaderMatch,
+		"noBrowser", cfg.NoBrowser,
+		"auto", cfg.Auto,
+		"outputFormat", cfg.OutputFormat)
+}
+
+// runSingle runs the action pipeline for the only target in a browser of
+// its own. The returned Run carries what the actions recorded, even on
+// failure; it is nil when the browser could not be started.
+func runSingle(ctx context.Context, pipeline []Action, target batchTarget, jsCode string, setup *pageSetup, artifactSink, textSink sink.Sink) (*Run, error) {
+	// Initialize browser
+	if cfg.RemoteDebuggingPort != "" {
+		slog.Debug("Connecting to existing browser", "target", cfg.Target, "timeout", cfg.Timeout, "delay", cfg.Delay, "remotePort", cfg.RemoteDebuggingPort)
+	} else {
+		slog.Debug("Initializing new browser", "target", cfg.Target, "timeout", cfg.Timeout, "delay", cfg.Delay)
+	}
+	launch := launchOptions(&cfg)
+	var proxy *proxyEntry
+	var err error
+	if setup.Proxies != nil {
+		if proxy, err = setup.Proxies.pick(cfg.Target); err != nil {
+			return nil, err
+		}
+		slog.Debug("Using proxy", "target", cfg.Target, "proxy", proxy.Server)
+		launch = append(launch, chromedphelper.WithProxy(proxy.Server))
+	}
+	browser, err := chromedphelper.InitializeChromedpContext(ctx, cfg.Target, cfg.Timeout, cfg.Delay, cfg.RemoteDebuggingPort, jsCode, launch...)
+	if err != nil {
+		slog.Error("Failed to initialize browser", "error", err)
+		return nil, fmt.Errorf("failed to initialize browser: %w", err)
+	}
+	if cfg.KeepTarget {
+		// The tab stays open when the connection drops as the tool exits
+		slog.Info("Leaving the tab open after the run", "target", cfg.Target)
+	} else {
+		defer browser.Cancel()
+	}
+	setup.apply(browser)
+	setup.applyTarget(browser, target)
+	visit := setup.Sites.applyTarget(browser, target)
+	if proxy != nil {
+		browser.ProxyAuth = proxy.Auth
+	}
+
+	run := &Run{
+		Config:    &cfg,
+		Browser:   browser,
+		Artifacts: artifactSink,
+		Text:      textSink,
+		Result:    &chromedphelper.Result{Target: cfg.Target, Locale: target.Locale, Consent: target.Consent},
+	}
+	if u := idn.URLToUnicode(cfg.Target); u != cfg.Target {
+		run.Result.UnicodeTarget = u
+	}
+	if proxy != nil {
+		run.Result.Proxy = proxy.Server
+	}
+	run.Result.Profile = browser.FingerprintProfile
+	recordDevToolsURL(ctx, run)
+	err = runPipeline(ctx, run, pipeline)
+	setup.Sites.learn(ctx, visit, browser, err)
+	// There is nothing to inspect when Chrome did not start
+	if cfg.PauseBeforeExit > 0 && exitCode(err) != exitBrowser {
+		pauseBeforeExit(ctx, time.Duration(cfg.PauseBeforeExit)*time.Second, err)
+	}
+	return run, err
+}
+
+// pageSetup holds what is applied to every page before and right after
+// navigation.
+type pageSetup struct {
+	Steps   []chromedphelper.Step
+	Headers map[string]string
+	Cookies []chromedphelper.Cookie
+	// Storage, if set, is the web storage of --load-state.
+	Storage   *chromedphelper.WebStorage
+	BasicAuth *chromedphelper.Credentials
+	Emulation *chromedphelper.Emulation
+	Filter    *urlfilter.Filter
+	// AllowedHosts are the hosts of --allow-hosts.
+	AllowedHosts *urlfilter.Hosts
+	// MaxRedirects is the number of client-side redirects to follow.
+	MaxRedirects int
+	// MaxBytes and MaxRequests are the caps of --max-bytes and
+	// --max-requests.
+	MaxBytes    int64
+	MaxRequests int
+	// Permissions are granted to pages for --grant-permissions.
+	Permissions []string
+	// Clipboard grants pages clipboard access for --read-clipboard.
+	Clipboard bool
+	// Mask holds the selectors of --mask.
+	Mask []string
+	// OnlyLang is the language of --only-lang.
+	OnlyLang string
+	// Googlebot loads pages as Googlebot for --as-googlebot.
+	Googlebot bool
+	// Untrusted locks pages down for --untrusted.
+	Untrusted bool
+	// Consent holds the --consent-states definitions by name.
+	Consent map[string]*consentState
+	// Circuits, if set, renews the Tor circuit before page loads.
+	Circuits *circuitRotator
+	// Proxies, if set, is the --proxy-pool each page load picks from.
+	Proxies *proxyPool
+	// Fingerprints, if set, gives each page load its fingerprint profile.
+	Fingerprints *fingerprintSource
+	// ScreenshotAt, if set, takes the --screenshot at a milestone of the
+	// page load.
+	ScreenshotAt *chromedphelper.MilestoneShot
+	// Ready, if set, is the --ready-strategy pages wait for.
+	Ready chromedphelper.ReadinessDetector
+	// Sites, if set, holds the --site-settings page loads consult and
+	// update.
+	Sites *siteSettingsStore
+	// Changes, if set, holds the page versions of --changed-only.
+	Changes *changeStore
+	// History, if set, records pages in the database of --sink
+	// sqlite:PATH.
+	History *runstore.Store
+	// Replay, if set, answers page loads from the --replay-har file.
+	Replay *har.Replay
+	// Outcomes, if set, collects the outcome of every target for the
+	// reports written when the run ends.
+	Outcomes *runOutcomes
+}
+
+// loadPageSetup parses the steps, headers, cookies, credentials and
+// emulation flags.
+func loadPageSetup(cfg *Config) (*pageSetup, error) {
+	setup := pageSetup{MaxRedirects: cfg.MaxRedirects, MaxRequests: cfg.MaxRequests, Clipboard: cfg.ReadClipboard, Mask: cfg.Mask, OnlyLang: cfg.OnlyLang, Googlebot: cfg.AsGooglebot, Untrusted: cfg.Untrusted}
+	var err error
+	if setup.MaxBytes, err = parseByteSize(cfg.MaxBytes); err != nil {
+		return nil, fmt.Errorf("invalid --max-bytes: %w", err)
+	}
+	if setup.Steps, err = loadSteps(stepSpecs(cfg), cfg.StepsFile); err != nil {
+		return nil, err
+	}
+	if setup.Permissions, err = chromedphelper.ParsePermissions(cfg.GrantPermissions); err != nil {
+		return nil, err
+	}
+	if setup.Headers, err = parseHeaders(cfg.Headers); err != nil {
+		return nil, err
+	}
+	if setup.Cookies, err = loadCookies(cfg.Cookies, cfg.CookiesFile); err != nil {
+		return nil, err
+	}
+	if setup.BasicAuth, err = parseBasicAuth(cfg.BasicAuth); err != nil {
+		return nil, err
+	}
+	if setup.Emulation, err = parseEmulation(cfg); err != nil {
+		return nil, err
+	}
+	if setup.Consent, err = parseConsentStates(cfg.ConsentStates, cfg.ConsentSteps, cfg.ConsentCookies); err != nil {
+		return nil, err
+	}
+	if cfg.ProxyPool != "" {
+		if setup.Proxies, err = loadProxyPool(cfg.ProxyPool, cfg.ProxyStrategy); err != nil {
+			return nil, err
+		}
+	}
+	if cfg.FingerprintProfile != "" {
+		if setup.Fingerprints, err = loadFingerprints(cfg.FingerprintProfile); err != nil {
+			return nil, err
+		}
+	}
+	if cfg.ReadyStrategy != "" {
+		if setup.Ready, err = chromedphelper.ParseReadiness(cfg.ReadyStrategy); err != nil {
+			return nil, fmt.Errorf("invalid --ready-strategy: %w", err)
+		}
+	}
+	if cfg.SiteSettings != "" {
+		if setup.Sites, err = loadSiteSettings(cfg.SiteSettings); err != nil {
+			return nil, err
+		}
+	}
+	if cfg.ChangedOnly != "" {
+		if setup.Changes, err = loadChangeStore(cfg.ChangedOnly); err != nil {
+			return nil, err
+		}
+	}
+	if path := historyDB(cfg); path != "" {
+		if setup.History, err = runstore.Open(path); err != nil {
+			return nil, err
+		}
+	}
+	if cfg.ReplayHAR != "" {
+		if setup.Replay, err = loadReplay(cfg.ReplayHAR); err != nil {
+			return nil, err
+		}
+	}
+	if cfg.ScreenshotAt != "" {
+		at, err := chromedphelper.ParseMilestone(cfg.ScreenshotAt)
 		if err != nil {
-			slog.Error("Failed to print to PDF", "error", err)
-			return fmt.Errorf("failed to print to PDF: %w", err)
+			return nil, fmt.Errorf("invalid --screenshot-at: %w", err)
 		}
+		setup.ScreenshotAt = &chromedphelper.MilestoneShot{At: at, FullPage: cfg.FullPage, Options: screenshotOptions(cfg, chromedphelper.JPEG)}
+	}
+	return &setup, nil
+}
+
+// apply sets the setup on b.
+func (s *pageSetup) apply(b *chromedphelper.Browser) {
+	b.Steps = s.Steps
+	b.Headers = s.Headers
+	b.Cookies = s.Cookies
+	b.Storage = s.Storage
+	b.BasicAuth = s.BasicAuth
+	b.Emulation = s.Emulation
+	b.Filter = s.Filter
+	b.AllowedHosts = s.AllowedHosts
+	b.MaxRedirects = s.MaxRedirects
+	b.MaxBytes = s.MaxBytes
+	b.MaxRequests = s.MaxRequests
+	b.Permissions = s.Permissions
+	b.Clipboard = s.Clipboard
+	b.Mask = s.Mask
+	b.OnlyLang = s.OnlyLang
+	b.Googlebot = s.Googlebot
+	b.Untrusted = s.Untrusted
+	b.ScreenshotAt = s.ScreenshotAt
+	b.Ready = s.Ready
+	b.Replay = s.Replay
+	// Consent states of one page must not see each other's cookies, a new
+	// Tor circuit is only used by new connections, shared cookies would
+	// tie page loads with different fingerprints together, and untrusted
+	// documents must not read what an earlier one stored
+	b.IsolateTabs = s.Consent != nil || s.Circuits != nil || s.Fingerprints != nil || s.Untrusted
+}
 
-		fileName := fmt.Sprintf("page_%s.pdf", time.Now().Format("20060102150405"))
-		slog.Debug("Saving PDF", "fileName", fileName, "size", len(pdfBuf))
-		if err := os.WriteFile(fileName, pdfBuf, 0o644); err != nil {
-			slog.Error("Failed to save PDF", "fileName", fileName, "error", err)
-			return fmt.Errorf("failed to save PDF %q: %w", fileName, err)
+// applyTarget sets what differs between the targets of a run on b: the
+// locale, the fingerprint profile, and the cookies and steps of the
+// consent state.
+func (s *pageSetup) applyTarget(b *chromedphelper.Browser, t batchTarget) {
+	b.Locale = t.Locale
+	b.FingerprintProfile = s.Fingerprints.nextProfile()
+	if consent := s.Consent[t.Consent]; consent != nil {
+		b.Cookies = append(append([]chromedphelper.Cookie(nil), s.Cookies...), consent.Cookies...)
+		b.Steps = append(append([]chromedphelper.Step(nil), consent.Steps...), s.Steps...)
+	}
+}
+
+// parseEmulation builds the emulation from --device, --viewport and
+// --dark-mode, or returns nil when none is set.
+func parseEmulation(cfg *Config) (*chromedphelper.Emulation, error) {
+	if cfg.Device == "" && cfg.Viewport == "" && !cfg.DarkMode {
+		return nil, nil
+	}
+	emulation := &chromedphelper.Emulation{Device: cfg.Device, DarkMode: cfg.DarkMode}
+	if cfg.Viewport != "" {
+		width, height, ok := strings.Cut(strings.ToLower(cfg.Viewport), "x")
+		w, werr := strconv.ParseInt(width, 10, 64)
+		h, herr := strconv.ParseInt(height, 10, 64)
+		if !ok || werr != nil || herr != nil || w <= 0 || h <= 0 {
+			return nil, fmt.Errorf("invalid viewport %q (expected WIDTHxHEIGHT, e.g. 1280x800)", cfg.Viewport)
 		}
-		slog.Info("PDF saved successfully", "fileName", fileName)
-		fmt.Printf("PDF saved as %s\n", fileName)
+		emulation.Width, emulation.Height = w, h
+	}
+	if err := emulation.Validate(); err != nil {
+		return nil, err
 	}
+	return emulation, nil
+}
 
-	slog.Debug("Command execution completed successfully")
-	return nil
+// screenshotOptions returns the encoding and capture options for
+// screenshots, using fallback when --screenshot-format is not set, or PNG
+// with --omit-background.
+func screenshotOptions(cfg *Config, fallback chromedphelper.ImageFormat) chromedphelper.ScreenshotOptions {
+	format := fallback
+	if cfg.OmitBackground {
+		format = chromedphelper.PNG
+	}
+	if cfg.ScreenshotFormat != "" {
+		// Validated in runThatCliWebBrowser
+		format, _ = chromedphelper.ParseImageFormat(cfg.ScreenshotFormat)
+	}
+	return chromedphelper.ScreenshotOptions{
+		Format:         format,
+		Quality:        cfg.ScreenshotQuality,
+		OmitBackground: cfg.OmitBackground,
+		BeyondViewport: &cfg.BeyondViewport,
+		FromSurface:    &cfg.FromSurface,
+	}
+}
+
+// openSinks returns the sinks for binary artifacts and for extracted text.
+// Without --sink, artifacts are written as files in the current directory
+// and text is printed on stdout; with it, every output goes to that sink.
+func openSinks(spec string) (artifacts sink.Sink, text sink.Sink, err error) {
+	if spec == "" {
+		return &sink.File{Dir: "."}, &sink.Stdout{}, nil
+	}
+	s, err := sink.New(spec)
+	if err != nil {
+		return nil, nil, err
+	}
+	switch s := s.(type) {
+	case *sink.HTTP:
+		s.Client = newHTTPClient(30 * time.Second)
+	case *sink.S3:
+		s.Client = newHTTPClient(60 * time.Second)
+	}
+	return s, s, nil
 }
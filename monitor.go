@@ -0,0 +1,607 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	chromedphelper "github.com/pesarkhobeee/that-cli-web-toolbox/pkg/chromedp"
+	"github.com/pesarkhobeee/that-cli-web-toolbox/pkg/miniyaml"
+	"github.com/pesarkhobeee/that-cli-web-toolbox/pkg/tracing"
+)
+
+// Nagios plugin states, which are also the exit codes of monitor.
+const (
+	stateOK       = 0
+	stateWarning  = 1
+	stateCritical = 2
+	stateUnknown  = 3
+)
+
+var stateNames = []string{"OK", "WARNING", "CRITICAL", "UNKNOWN"}
+
+// healthStatuses are the statuses of the IETF health check response format
+// for each state; it has no unknown status.
+var healthStatuses = []string{"pass", "warn", "fail", "fail"}
+
+// Output formats accepted by monitor --format.
+const (
+	monitorFormatNagios = "nagios"
+	monitorFormatJSON   = "json"
+)
+
+// checkResponseTime names the response time assertion of a monitor step.
+const checkResponseTime = "response-time"
+
+type monitorConfig struct {
+	Format  string
+	Timeout int
+	Delay   int
+}
+
+var monitorCfg monitorConfig
+
+var monitorCmd = &cobra.Command{
+	Use:   "monitor FILE",
+	Short: "Run a synthetic monitoring check defined in a YAML file",
+	Long: `Run the steps of a monitor file in one browser tab, evaluate their
+assertions and report the outcome like a Nagios plugin.
+
+A monitor file lists steps run in order. A step with a url navigates to it;
+a step without one continues on the current page. Each step may run
+interaction actions, as for --step, and then assert on the page:
+
+  name: checkout
+  headers:
+    X-Synthetic: "true"
+  steps:
+    - name: home
+      url: https://shop.example.com
+      expect:
+        status: 200
+        selectors: ["#search"]
+        maxResponseTime: 5s
+    - name: search
+      actions:
+        - type:#search:socks
+        - click:#search-button
+        - waitvisible:.results
+      expect:
+        text: "[0-9]+ results"
+        warnResponseTime: 2s
+        maxResponseTime: 8s
+  alerts:
+    - webhook: https://hooks.example.com/oncall
+      on: [critical, unknown]
+
+A step is CRITICAL when it fails to load, an action fails, an assertion
+does not hold or it takes longer than maxResponseTime, and WARNING when it
+takes longer than warnResponseTime. The response time covers navigation,
+--delay and actions. A step that cannot be run ends the monitor; failed
+assertions do not. The monitor's state is the worst state of its steps, or
+UNKNOWN when the file is invalid or the browser cannot be started.
+
+The exit code is the Nagios state: 0 OK, 1 WARNING, 2 CRITICAL, 3 UNKNOWN.
+With --format nagios a status line with performance data is printed,
+followed by one line per step; with --format json the result is printed in
+the health check response format (status pass, warn or fail).
+
+Every alert whose "on" list (ok, warning, critical, unknown; by default
+warning, critical and unknown) contains the monitor's state receives the
+result as a JSON POST to its webhook, with the alert's optional headers.`,
+	Example: `  # Run from cron or as a Nagios/Icinga check command
+  that-cli-web-toolbox monitor checkout.yaml
+
+  # Report in the health check format for a status page
+  that-cli-web-toolbox monitor --format json --timeout 120 checkout.yaml`,
+	RunE: runMonitor,
+	Args: cobra.ExactArgs(1),
+	// The result, not usage, is the output of a failed monitor
+	SilenceUsage:  true,
+	SilenceErrors: true,
+}
+
+func init() {
+	monitorCmd.Flags().StringVar(&monitorCfg.Format, "format", monitorFormatNagios, "Output format: nagios or json")
+	monitorCmd.Flags().IntVarP(&monitorCfg.Timeout, "timeout", "t", 60, "Maximum time in seconds for all steps together")
+	monitorCmd.Flags().IntVarP(&monitorCfg.Delay, "delay", "d", 0, "Delay in seconds after each navigation, before the step's actions")
+	rootCmd.AddCommand(monitorCmd)
+}
+
+// monitorFile is the definition read from a monitor file.
+type monitorFile struct {
+	Name    string            `json:"name"`
+	Headers map[string]string `json:"headers"`
+	Steps   []*monitorStep    `json:"steps"`
+	Alerts  []*monitorAlert   `json:"alerts"`
+}
+
+// monitorStep is one step of a monitor file.
+type monitorStep struct {
+	Name    string   `json:"name"`
+	URL     string   `json:"url"`
+	Actions []string `json:"actions"`
+	Expect  struct {
+		Selectors        []string `json:"selectors"`
+		Text             string   `json:"text"`
+		Status           int      `json:"status"`
+		WarnResponseTime string   `json:"warnResponseTime"`
+		MaxResponseTime  string   `json:"maxResponseTime"`
+	} `json:"expect"`
+
+	// steps, expectations and the response time thresholds are parsed
+	// from the fields above by loadMonitor.
+	steps        []chromedphelper.Step
+	expectations chromedphelper.Expectations
+	warnTime     time.Duration
+	maxTime      time.Duration
+}
+
+// monitorAlert routes results in the states listed in On to Webhook.
+type monitorAlert struct {
+	Webhook string            `json:"webhook"`
+	On      []string          `json:"on"`
+	Headers map[string]string `json:"headers"`
+}
+
+// monitorResult is the outcome of a monitor, as sent to alert webhooks.
+type monitorResult struct {
+	Monitor string               `json:"monitor"`
+	State   string               `json:"state"`
+	Output  string               `json:"output"`
+	Steps   []*monitorStepResult `json:"steps"`
+
+	state int
+}
+
+// monitorStepResult is the outcome of one step.
+type monitorStepResult struct {
+	Name           string                       `json:"name"`
+	State          string                       `json:"state"`
+	ResponseTimeMS int64                        `json:"responseTimeMs"`
+	Checks         []chromedphelper.CheckResult `json:"checks,omitempty"`
+	Error          string                       `json:"error,omitempty"`
+
+	state             int
+	warnTime, maxTime time.Duration
+}
+
+func runMonitor(cmd *cobra.Command, args []string) error {
+	setupLogging(cfg.LogLevel)
+
+	if monitorCfg.Format != monitorFormatNagios && monitorCfg.Format != monitorFormatJSON {
+		return &exitError{code: stateUnknown, err: fmt.Errorf("unsupported --format %q (expected nagios or json)", monitorCfg.Format)}
+	}
+	if monitorCfg.Timeout < 1 {
+		return &exitError{code: stateUnknown, err: fmt.Errorf("--timeout must be at least 1")}
+	}
+	if monitorCfg.Delay < 0 {
+		return &exitError{code: stateUnknown, err: fmt.Errorf("--delay cannot be negative")}
+	}
+	m, err := loadMonitor(args[0])
+	if err != nil {
+		slog.Error("Failed to load monitor", "file", args[0], "error", err)
+		return &exitError{code: stateUnknown, err: err}
+	}
+
+	ctx, stopTracing, err := startTracing(cmd.Context())
+	if err != nil {
+		return &exitError{code: stateUnknown, err: err}
+	}
+	defer stopTracing()
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(monitorCfg.Timeout)*time.Second)
+	defer cancel()
+	result := m.run(ctx)
+
+	if monitorCfg.Format == monitorFormatJSON {
+		if err := emitJSON(healthResponse(result)); err != nil {
+			return &exitError{code: stateUnknown, err: err}
+		}
+	} else {
+		fmt.Print(formatNagios(result))
+	}
+
+	// Alerts get their own time, since a timed out monitor needs them most
+	alertCtx, cancelAlerts := context.WithTimeout(cmd.Context(), 30*time.Second)
+	defer cancelAlerts()
+	m.sendAlerts(alertCtx, result)
+
+	if result.state != stateOK {
+		return &exitError{code: result.state, err: errReported}
+	}
+	return nil
+}
+
+// loadMonitor reads and validates the monitor file at path. The monitor is
+// named after the file unless it has a name.
+func loadMonitor(path string) (*monitorFile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read monitor file: %w", err)
+	}
+	var m monitorFile
+	if err := miniyaml.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("invalid monitor file %s: %w", path, err)
+	}
+	if m.Name == "" {
+		m.Name = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	}
+
+	if len(m.Steps) == 0 {
+		return nil, fmt.Errorf("monitor %s has no steps", m.Name)
+	}
+	names := make(map[string]bool, len(m.Steps))
+	for i, step := range m.Steps {
+		if step == nil {
+			return nil, fmt.Errorf("step %d of monitor %s is empty", i+1, m.Name)
+		}
+		if step.Name == "" {
+			step.Name = fmt.Sprintf("step%d", i+1)
+		}
+		if names[step.Name] {
+			return nil, fmt.Errorf("step name %q is used twice", step.Name)
+		}
+		names[step.Name] = true
+		if err := step.parse(i == 0); err != nil {
+			return nil, fmt.Errorf("step %s: %w", step.Name, err)
+		}
+	}
+
+	for i, alert := range m.Alerts {
+		if alert == nil {
+			return nil, fmt.Errorf("alert %d of monitor %s is empty", i+1, m.Name)
+		}
+		u, err := url.Parse(alert.Webhook)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return nil, fmt.Errorf("alert %d: webhook must be an http(s) URL: %q", i+1, alert.Webhook)
+		}
+		if len(alert.On) == 0 {
+			alert.On = []string{"warning", "critical", "unknown"}
+		}
+		for j, state := range alert.On {
+			alert.On[j] = strings.ToUpper(state)
+			if !contains(stateNames, alert.On[j]) {
+				return nil, fmt.Errorf("alert %d: unknown state %q (expected ok, warning, critical or unknown)", i+1, state)
+			}
+		}
+	}
+	return &m, nil
+}
+
+// parse checks the step's fields and parses its actions and assertions.
+// The first step must navigate.
+func (s *monitorStep) parse(first bool) error {
+	if s.URL == "" && first {
+		return fmt.Errorf("the first step needs a url")
+	}
+	if s.URL != "" {
+		u, err := url.Parse(s.URL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+			return fmt.Errorf("url must be an http(s) URL: %q", s.URL)
+		}
+	}
+	for _, spec := range s.Actions {
+		step, err := chromedphelper.ParseStep(spec)
+		if err != nil {
+			return err
+		}
+		s.steps = append(s.steps, step)
+	}
+
+	if err := validateSelectors("selectors", s.Expect.Selectors); err != nil {
+		return err
+	}
+	s.expectations = chromedphelper.Expectations{Selectors: s.Expect.Selectors, Status: s.Expect.Status}
+	if s.Expect.Text != "" {
+		re, err := regexp.Compile(s.Expect.Text)
+		if err != nil {
+			return fmt.Errorf("invalid text regexp: %w", err)
+		}
+		s.expectations.Text = re
+	}
+	if s.Expect.Status < 0 || s.Expect.Status > 599 {
+		return fmt.Errorf("status must be an HTTP status code: %d", s.Expect.Status)
+	}
+
+	var err error
+	if s.warnTime, err = parseResponseTime("warnResponseTime", s.Expect.WarnResponseTime); err != nil {
+		return err
+	}
+	if s.maxTime, err = parseResponseTime("maxResponseTime", s.Expect.MaxResponseTime); err != nil {
+		return err
+	}
+	if s.warnTime > 0 && s.maxTime > 0 && s.warnTime > s.maxTime {
+		return fmt.Errorf("warnResponseTime %s exceeds maxResponseTime %s", s.warnTime, s.maxTime)
+	}
+	return nil
+}
+
+// parseResponseTime parses an optional positive duration such as "2s".
+func parseResponseTime(field, value string) (time.Duration, error) {
+	if value == "" {
+		return 0, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("%s must be a positive duration such as 2s: %q", field, value)
+	}
+	return d, nil
+}
+
+// run performs the steps in one tab and evaluates them.
+func (m *monitorFile) run(ctx context.Context) *monitorResult {
+	result := &monitorResult{Monitor: m.Name}
+	ctx, span := tracing.Start(ctx, "monitor", tracing.String("monitor.name", m.Name))
+	defer func() {
+		span.SetAttributes(tracing.String("monitor.state", result.State))
+		span.End()
+	}()
+
+	b, err := chromedphelper.InitializeChromedpContext(ctx, "", 0, monitorCfg.Delay, cfg.RemoteDebuggingPort, "", managedBrowserOptions("")...)
+	if err != nil {
+		slog.Error("Failed to initialize browser", "error", err)
+		result.setState(stateUnknown, fmt.Sprintf("failed to initialize browser: %v", err))
+		return result
+	}
+	defer b.Cancel()
+	b.Headers = m.Headers
+
+	for _, step := range m.Steps {
+		slog.Info("Running monitor step", "monitor", m.Name, "step", step.Name, "url", step.URL)
+		sr := step.run(ctx, b)
+		result.Steps = append(result.Steps, sr)
+		if sr.Error != "" {
+			break
+		}
+	}
+
+	state, problems := stateOK, []string(nil)
+	for _, sr := range result.Steps {
+		state = max(state, sr.state)
+		if sr.state != stateOK {
+			problems = append(problems, sr.Name+": "+sr.problem())
+		}
+	}
+	output := fmt.Sprintf("%d steps passed in %s", len(result.Steps), result.totalTime())
+	if len(problems) > 0 {
+		output = strings.Join(problems, "; ")
+		if skipped := len(m.Steps) - len(result.Steps); skipped > 0 {
+			output += fmt.Sprintf(" (%d later steps skipped)", skipped)
+		}
+	}
+	result.setState(state, output)
+	return result
+}
+
+// run performs the step on b and evaluates its assertions.
+func (s *monitorStep) run(ctx context.Context, b *chromedphelper.Browser) *monitorStepResult {
+	sr := &monitorStepResult{Name: s.Name, warnTime: s.warnTime, maxTime: s.maxTime}
+	ctx, span := tracing.Start(ctx, "monitor step", tracing.String("monitor.step", s.Name))
+	defer func() {
+		span.SetAttributes(tracing.String("monitor.state", sr.State))
+		span.End()
+	}()
+
+	start := time.Now()
+	var err error
+	if s.URL != "" {
+		b.TargetURL = s.URL
+		b.Steps = s.steps
+		err = b.NavigateAndPrepare(ctx)
+	} else {
+		err = b.ExecuteSteps(ctx, s.steps)
+	}
+	elapsed := time.Since(start)
+	sr.ResponseTimeMS = elapsed.Milliseconds()
+	if err == nil {
+		sr.Checks, err = b.Check(ctx, s.expectations)
+	}
+	if err != nil {
+		slog.Error("Monitor step failed", "step", s.Name, "error", err)
+		span.RecordError(err)
+		sr.Error = err.Error()
+		if errors.Is(err, context.DeadlineExceeded) {
+			sr.Error = "timed out"
+		}
+		sr.setState(stateCritical)
+		return sr
+	}
+
+	if s.maxTime > 0 {
+		sr.Checks = append(sr.Checks, chromedphelper.CheckResult{
+			Name:     checkResponseTime,
+			Expected: "<= " + s.maxTime.String(),
+			Actual:   elapsed.Round(time.Millisecond).String(),
+			Passed:   elapsed <= s.maxTime,
+		})
+	}
+	switch {
+	case chromedphelper.CheckFailures(sr.Checks) > 0:
+		sr.setState(stateCritical)
+	case s.warnTime > 0 && elapsed > s.warnTime:
+		sr.setState(stateWarning)
+	default:
+		sr.setState(stateOK)
+	}
+	return sr
+}
+
+func (r *monitorResult) setState(state int, output string) {
+	r.state, r.State, r.Output = state, stateNames[state], output
+}
+
+func (r *monitorStepResult) setState(state int) {
+	r.state, r.State = state, stateNames[state]
+}
+
+// totalTime is the sum of the steps' response times.
+func (r *monitorResult) totalTime() time.Duration {
+	var total int64
+	for _, sr := range r.Steps {
+		total += sr.ResponseTimeMS
+	}
+	return time.Duration(total) * time.Millisecond
+}
+
+// problem describes why a step is not OK.
+func (r *monitorStepResult) problem() string {
+	if r.Error != "" {
+		return r.Error
+	}
+	var failed []string
+	for _, c := range r.Checks {
+		if !c.Passed {
+			failed = append(failed, fmt.Sprintf("%s %s failed (got %s)", c.Name, c.Expected, c.Actual))
+		}
+	}
+	if len(failed) > 0 {
+		return strings.Join(failed, ", ")
+	}
+	return fmt.Sprintf("took %s (warning above %s)", r.responseTime(), r.warnTime)
+}
+
+func (r *monitorStepResult) responseTime() time.Duration {
+	return time.Duration(r.ResponseTimeMS) * time.Millisecond
+}
+
+// formatNagios renders the result as plugin output: a status line with
+// each step's response time as performance data, then one line per step.
+func formatNagios(r *monitorResult) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%s %s - %s |", r.Monitor, r.State, r.Output)
+	for _, sr := range r.Steps {
+		fmt.Fprintf(&sb, " '%s'=%.3fs;%s;%s;0", strings.ReplaceAll(sr.Name, "'", "''"),
+			sr.responseTime().Seconds(), perfThreshold(sr.warnTime), perfThreshold(sr.maxTime))
+	}
+	sb.WriteString("\n")
+	for _, sr := range r.Steps {
+		fmt.Fprintf(&sb, "[%s] %s (%s)", sr.State, sr.Name, sr.responseTime())
+		if sr.state != stateOK {
+			fmt.Fprintf(&sb, ": %s", sr.problem())
+		}
+		sb.WriteString("\n")
+	}
+	return sb.String()
+}
+
+// perfThreshold renders a response time threshold in seconds, empty when
+// unset.
+func perfThreshold(d time.Duration) string {
+	if d == 0 {
+		return ""
+	}
+	return fmt.Sprintf("%.3f", d.Seconds())
+}
+
+// healthCheck is one measurement of a health check response.
+type healthCheck struct {
+	ComponentID   string `json:"componentId"`
+	ObservedValue any    `json:"observedValue"`
+	ObservedUnit  string `json:"observedUnit,omitempty"`
+	Status        string `json:"status"`
+	Output        string `json:"output,omitempty"`
+}
+
+// healthResponse renders the result in the health check response format
+// (draft-inadarei-api-health-check), with one check per step's response
+// time and per assertion, keyed "STEP:MEASUREMENT".
+func healthResponse(r *monitorResult) map[string]any {
+	checks := map[string][]healthCheck{}
+	for _, sr := range r.Steps {
+		checks[sr.Name+":responseTime"] = append(checks[sr.Name+":responseTime"], healthCheck{
+			ComponentID:   sr.Name,
+			ObservedValue: sr.ResponseTimeMS,
+			ObservedUnit:  "ms",
+			Status:        healthStatuses[sr.state],
+			Output:        sr.Error,
+		})
+		for _, c := range sr.Checks {
+			if c.Name == checkResponseTime {
+				continue
+			}
+			status := healthStatuses[stateOK]
+			if !c.Passed {
+				status = healthStatuses[stateCritical]
+			}
+			key := sr.Name + ":" + c.Name
+			checks[key] = append(checks[key], healthCheck{
+				ComponentID:   sr.Name,
+				ObservedValue: c.Actual,
+				Status:        status,
+				Output:        c.Expected,
+			})
+		}
+	}
+
+	response := map[string]any{
+		"status":      healthStatuses[r.state],
+		"description": r.Monitor,
+		"checks":      checks,
+	}
+	if r.state != stateOK {
+		response["output"] = r.Output
+	}
+	return response
+}
+
+// sendAlerts posts the result to the webhook of every alert routed its
+// state. Failed deliveries are logged and do not change the state.
+func (m *monitorFile) sendAlerts(ctx context.Context, r *monitorResult) {
+	var body []byte
+	for _, alert := range m.Alerts {
+		if !contains(alert.On, r.State) {
+			continue
+		}
+		if body == nil {
+			var err error
+			if body, err = json.Marshal(r); err != nil {
+				slog.Error("Failed to encode alert", "error", err)
+				return
+			}
+		}
+		if err := postAlert(ctx, alert, body); err != nil {
+			slog.Error("Failed to send alert", "webhook", alert.Webhook, "error", err)
+			continue
+		}
+		slog.Info("Alert sent", "webhook", alert.Webhook, "state", r.State)
+	}
+}
+
+func postAlert(ctx context.Context, alert *monitorAlert, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, alert.Webhook, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for name, value := range alert.Headers {
+		req.Header.Set(name, value)
+	}
+
+	resp, err := newHTTPClient(10 * time.Second).Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := resp.Body.Close(); err != nil {
+			slog.Warn("failed to close response body", "error", err)
+		}
+	}()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("webhook returned status %d: %s", resp.StatusCode, bytes.TrimSpace(msg))
+	}
+	return nil
+}
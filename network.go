@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/pesarkhobeee/that-cli-web-toolbox/pkg/events"
+	"github.com/pesarkhobeee/that-cli-web-toolbox/pkg/har"
+)
+
+// harCreator identifies the tool in written HAR files.
+var harCreator = har.Creator{Name: "that-cli-web-toolbox", Version: version}
+
+// requestFailed reports whether req failed to load or got an HTTP error.
+func requestFailed(req events.RequestFinished) bool {
+	return req.Failed || req.Status >= 400
+}
+
+// networkAction records every network request of the page, writes them
+// as a HAR file and reports failed requests.
+type networkAction struct {
+	noopAction
+
+	started  time.Time
+	mu       sync.Mutex
+	requests []events.RequestFinished
+}
+
+func (a *networkAction) Name() string { return "network" }
+func (a *networkAction) Enabled(cfg *Config) bool {
+	return cfg.HAR != "" || cfg.FailOnRequestError
+}
+
+func (a *networkAction) Flags() []string { return []string{"--har", "--fail-on-request-error"} }
+
+func (a *networkAction) Prepare(ctx context.Context, run *Run) error {
+	// Requests must be recorded from the start of navigation
+	slog.Info("Setting up network request capture")
+	a.started = time.Now()
+	stream := run.Browser.Events()
+	go func() {
+		for ev := range stream {
+			req, ok := ev.(events.RequestFinished)
+			if !ok {
+				continue
+			}
+			a.mu.Lock()
+			a.requests = append(a.requests, req)
+			a.mu.Unlock()
+		}
+	}()
+	return nil
+}
+
+func (a *networkAction) Execute(ctx context.Context, run *Run) error {
+	a.mu.Lock()
+	requests := make([]events.RequestFinished, len(a.requests))
+	copy(requests, a.requests)
+	a.mu.Unlock()
+
+	slog.Debug("Network requests recorded", "count", len(requests))
+	for _, req := range requests {
+		if requestFailed(req) {
+			run.Result.FailedRequests = append(run.Result.FailedRequests, req)
+		}
+	}
+
+	if run.Config.HAR == "" {
+		return nil
+	}
+	title := run.Browser.TargetURL
+	if meta, err := run.Browser.GetPageMetadata(ctx); err == nil && meta.Title != "" {
+		title = meta.Title
+	}
+	data, err := json.MarshalIndent(har.New(harCreator, title, a.started, requests), "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode HAR: %w", err)
+	}
+	return writeArtifact(ctx, run, "har", "", "HAR", run.Config.HAR, "application/json", data)
+}
+
+func (a *networkAction) Report(ctx context.Context, run *Run) error {
+	failed := run.Result.FailedRequests
+	if len(failed) > 0 && !structuredFormat(run.Config.OutputFormat) {
+		fmt.Printf("Failed requests (%d):\n", len(failed))
+		for _, req := range failed {
+			fmt.Printf("  %s\n", describeRequest(req))
+		}
+	}
+	if run.Config.FailOnRequestError && len(failed) > 0 {
+		return fmt.Errorf("%d request(s) failed to load or returned an HTTP error", len(failed))
+	}
+	return nil
+}
+
+// describeRequest renders req as a one-line summary.
+func describeRequest(req events.RequestFinished) string {
+	if req.Failed {
+		return fmt.Sprintf("%s %s: %s", req.Method, req.URL, req.ErrorText)
+	}
+	return fmt.Sprintf("%s %s: %d %s", req.Method, req.URL, req.Status, req.StatusText)
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+
+	chromedphelper "github.com/pesarkhobeee/that-cli-web-toolbox/pkg/chromedp"
+)
+
+// noJSListed bounds the items of each kind printed for --compare-nojs;
+// the JSON file lists them all.
+const noJSListed = 10
+
+// compareNoJSAction loads the page again with JavaScript disabled in a
+// second tab for --compare-nojs and reports the content only JavaScript
+// renders, which crawlers without JavaScript miss.
+type compareNoJSAction struct {
+	noopAction
+	image  []byte
+	format chromedphelper.ImageFormat
+}
+
+func (a *compareNoJSAction) Name() string             { return "compare-nojs" }
+func (a *compareNoJSAction) Enabled(cfg *Config) bool { return cfg.CompareNoJS }
+func (a *compareNoJSAction) Flags() []string          { return []string{"--compare-nojs"} }
+
+func (a *compareNoJSAction) Execute(ctx context.Context, run *Run) error {
+	slog.Info("Comparing the page with and without JavaScript")
+	withJS, err := run.Browser.PageContent(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to list page content: %w", err)
+	}
+
+	tab, err := run.Browser.NoJSTab(ctx)
+	if err != nil {
+		return err
+	}
+	defer tab.Cancel()
+	if err := tab.NavigateAndPrepare(ctx); err != nil {
+		return fmt.Errorf("failed to load the page without JavaScript: %w", err)
+	}
+	withoutJS, err := tab.PageContent(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to list page content without JavaScript: %w", err)
+	}
+
+	// With --screenshot, the page without JavaScript is captured alike
+	if run.Config.Screenshot {
+		opts := screenshotOptions(run.Config, chromedphelper.JPEG)
+		a.format = opts.Format
+		if run.Config.FullPage {
+			a.image, err = tab.ScreenshotFullPage(ctx, opts)
+		} else {
+			a.image, err = tab.ScreenshotViewport(ctx, opts)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to take screenshot without JavaScript: %w", err)
+		}
+	}
+
+	c := chromedphelper.CompareNoJS(withJS, withoutJS)
+	slog.Debug("Compared the page with and without JavaScript", "jsOnlyWords", c.JSOnlyWords, "jsOnlyText", len(c.JSOnly.Text), "jsOnlyLinks", len(c.JSOnly.Links))
+	run.Result.NoJS = c
+	return nil
+}
+
+func (a *compareNoJSAction) Report(ctx context.Context, run *Run) error {
+	c := run.Result.NoJS
+	stamp := timestamp()
+	if a.image != nil {
+		fileName := fmt.Sprintf("screenshot-nojs_%s.%s", stamp, a.format.Extension())
+		if err := writeArtifact(ctx, run, "screenshot-nojs", "", "Screenshot without JavaScript", fileName, a.format.ContentType(), a.image); err != nil {
+			return err
+		}
+	}
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode comparison: %w", err)
+	}
+	if err := writeArtifact(ctx, run, "nojs", "", "JavaScript comparison", fmt.Sprintf("nojs_%s.json", stamp), "application/json", data); err != nil {
+		return err
+	}
+
+	if run.Batch || structuredFormat(run.Config.OutputFormat) {
+		return nil
+	}
+	fmt.Printf("Without JavaScript: %s\n", formatNoJSCounts(c))
+	if c.Title != nil {
+		fmt.Printf("Title without JavaScript: %q\n", *c.Title)
+	}
+	if c.Description != nil {
+		fmt.Printf("Description without JavaScript: %q\n", *c.Description)
+	}
+	if c.JSOnly.Empty() {
+		fmt.Println("Nothing is only rendered by JavaScript")
+	} else {
+		fmt.Println("Only with JavaScript:")
+		printContentDiff(c.JSOnly)
+	}
+	if !c.NoJSOnly.Empty() {
+		fmt.Println("Only without JavaScript:")
+		printContentDiff(c.NoJSOnly)
+	}
+	return nil
+}
+
+// formatNoJSCounts renders how much of the content is there without
+// JavaScript, e.g. "3 of 12 headings, ...".
+func formatNoJSCounts(c *chromedphelper.NoJSComparison) string {
+	return fmt.Sprintf("%d of %d headings, %d of %d text blocks, %d of %d links, %d of %d images, %d of %d words (%.0f%% of the words need JavaScript)",
+		c.WithoutJS.Headings, c.WithJS.Headings, c.WithoutJS.Text, c.WithJS.Text,
+		c.WithoutJS.Links, c.WithJS.Links, c.WithoutJS.Images, c.WithJS.Images,
+		c.WithoutJS.Words, c.WithJS.Words, c.JSOnlyWords*100)
+}
+
+// printContentDiff lists the first noJSListed items of each kind of d.
+func printContentDiff(d chromedphelper.ContentDiff) {
+	for _, kind := range []struct {
+		name  string
+		items []string
+	}{
+		{"heading", d.Headings},
+		{"text", d.Text},
+		{"link", d.Links},
+		{"image", d.Images},
+	} {
+		for i, item := range kind.items {
+			if i == noJSListed {
+				fmt.Printf("  %-8s ... %d more\n", kind.name, len(kind.items)-noJSListed)
+				break
+			}
+			fmt.Printf("  %-8s %s\n", kind.name, truncateText(item, 100))
+		}
+	}
+}
+
+// truncateText shortens s to n characters for listing.
+func truncateText(s string, n int) string {
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+	return string(runes[:n]) + "..."
+}
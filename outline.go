@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+
+	chromedphelper "github.com/pesarkhobeee/that-cli-web-toolbox/pkg/chromedp"
+)
+
+// Formats of --outline.
+const (
+	outlineMarkdown = "markdown"
+	outlineJSON     = "json"
+)
+
+// outlineAction extracts the page's text with its structure for
+// --outline: sections nested by heading, lists, quotes, code blocks and
+// tables, with links inline, as Markdown or a JSON tree.
+type outlineAction struct{ noopAction }
+
+func (a *outlineAction) Name() string             { return "outline" }
+func (a *outlineAction) Enabled(cfg *Config) bool { return cfg.Outline != "" }
+func (a *outlineAction) Flags() []string          { return []string{"--outline"} }
+
+func (a *outlineAction) Validate(cfg *Config) error {
+	if cfg.Outline != outlineMarkdown && cfg.Outline != outlineJSON {
+		return fmt.Errorf("invalid --outline %q (expected markdown or json)", cfg.Outline)
+	}
+	return nil
+}
+
+func (a *outlineAction) Execute(ctx context.Context, run *Run) error {
+	slog.Info("Getting text outline")
+	o, err := run.Browser.Outline(ctx)
+	if err != nil {
+		slog.Error("Failed to get text outline", "error", err)
+		return fmt.Errorf("failed to get text outline: %w", err)
+	}
+	if o.Truncated {
+		slog.Warn("Page is too long, outline covers only its beginning")
+	}
+	// Normalized like --body, node by node so the tree stays intact
+	o.Walk(func(n *chromedphelper.OutlineNode) {
+		if err != nil {
+			return
+		}
+		if n.Text, err = normalizeText(ctx, run, n.Text); err != nil {
+			return
+		}
+		for _, row := range n.Rows {
+			for i := range row {
+				if row[i], err = normalizeText(ctx, run, row[i]); err != nil {
+					return
+				}
+			}
+		}
+	})
+	if err != nil {
+		return err
+	}
+	run.Result.Outline = o
+	return nil
+}
+
+func (a *outlineAction) Report(ctx context.Context, run *Run) error {
+	o := run.Result.Outline
+	if run.Config.Outline == outlineJSON {
+		data, err := json.MarshalIndent(o, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to encode outline: %w", err)
+		}
+		return writeTextAs(ctx, run, fmt.Sprintf("outline_%s.json", timestamp()), "application/json; charset=utf-8", string(data))
+	}
+	return writeTextAs(ctx, run, fmt.Sprintf("outline_%s.md", timestamp()), "text/markdown; charset=utf-8", o.Markdown())
+}
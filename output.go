@@ -0,0 +1,64 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+)
+
+// Output formats accepted by --output-format.
+const (
+	formatText   = "text"
+	formatJSON   = "json"
+	formatNDJSON = "ndjson"
+	formatJUnit  = "junit"
+)
+
+var outputFormats = []string{formatText, formatJSON, formatNDJSON, formatJUnit}
+
+// structuredOutput reports whether results are emitted as a JSON or JUnit
+// XML document on stdout instead of being printed by each action.
+func structuredOutput() bool {
+	return structuredFormat(cfg.OutputFormat)
+}
+
+// structuredFormat is structuredOutput for the --output-format format, for
+// actions, which read their run's Config rather than the flags.
+func structuredFormat(format string) bool {
+	return format == formatJSON || format == formatNDJSON || format == formatJUnit
+}
+
+func validateOutputFormat() error {
+	if !contains(outputFormats, cfg.OutputFormat) {
+		return fmt.Errorf("unsupported output format %q (expected one of %s)", cfg.OutputFormat, strings.Join(outputFormats, ", "))
+	}
+	if structuredOutput() && cfg.Sink == "stdout" {
+		return fmt.Errorf("--output-format %s writes to stdout and cannot be combined with --sink stdout", cfg.OutputFormat)
+	}
+	return nil
+}
+
+// stdoutMu serializes JSON documents written from concurrent batch targets.
+var stdoutMu sync.Mutex
+
+// emitJSON writes v to stdout: indented for json, as a single line for
+// ndjson.
+func emitJSON(v any) error {
+	var data []byte
+	var err error
+	if cfg.OutputFormat == formatNDJSON {
+		data, err = json.Marshal(v)
+	} else {
+		data, err = json.MarshalIndent(v, "", "  ")
+	}
+	if err != nil {
+		return fmt.Errorf("failed to encode results: %w", err)
+	}
+
+	stdoutMu.Lock()
+	defer stdoutMu.Unlock()
+	_, err = os.Stdout.Write(append(data, '\n'))
+	return err
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	chromedphelper "github.com/pesarkhobeee/that-cli-web-toolbox/pkg/chromedp"
+)
+
+// overlayAction measures how much of the first screen fixed and sticky
+// overlays cover for --overlay-report, and fails pages where they cover
+// more than --overlay-threshold.
+type overlayAction struct{ noopAction }
+
+func (a *overlayAction) Name() string             { return "overlays" }
+func (a *overlayAction) Enabled(cfg *Config) bool { return cfg.OverlayReport }
+func (a *overlayAction) Flags() []string          { return []string{"--overlay-report"} }
+
+func (a *overlayAction) Validate(cfg *Config) error {
+	if cfg.OverlayThreshold < 0 || cfg.OverlayThreshold > 100 {
+		return fmt.Errorf("--overlay-threshold must be a percentage between 0 and 100, got %g", cfg.OverlayThreshold)
+	}
+	return nil
+}
+
+func (a *overlayAction) Execute(ctx context.Context, run *Run) error {
+	slog.Info("Measuring overlays")
+	report, err := run.Browser.Overlays(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to measure overlays: %w", err)
+	}
+	run.Result.Overlays = report
+	return nil
+}
+
+func (a *overlayAction) Report(ctx context.Context, run *Run) error {
+	report := run.Result.Overlays
+	threshold := run.Config.OverlayThreshold
+	// Batch runs list the coverage in the summary instead
+	if !run.Batch && !structuredFormat(run.Config.OutputFormat) {
+		fmt.Printf("Overlays cover %.0f%% of the viewport (threshold %g%%):\n", report.Coverage*100, threshold)
+		for _, o := range report.Overlays {
+			fmt.Printf("  %-7s %s\n", o.Kind, describeOverlay(o))
+		}
+	}
+	if report.Coverage*100 > threshold {
+		return &exitError{code: exitAssertion, err: fmt.Errorf("overlays cover %.0f%% of the viewport, more than %g%%", report.Coverage*100, threshold)}
+	}
+	return nil
+}
+
+// describeOverlay renders o as "<tag> selector "text" (position, N%)".
+func describeOverlay(o chromedphelper.Overlay) string {
+	s := fmt.Sprintf("<%s> %s", o.Tag, o.Selector)
+	if o.Text != "" {
+		s += fmt.Sprintf(" %q", o.Text)
+	}
+	return fmt.Sprintf("%s (%s, %.0f%%)", s, o.Position, o.Coverage*100)
+}
+
+// formatOverlays renders the coverage and the overlays' kinds for the
+// batch summary, e.g. "42% (consent 35%, header 8%)".
+func formatOverlays(report *chromedphelper.OverlayReport) string {
+	s := fmt.Sprintf("%.0f%%", report.Coverage*100)
+	for i, o := range report.Overlays {
+		if i == 0 {
+			s += " ("
+		} else {
+			s += ", "
+		}
+		s += fmt.Sprintf("%s %.0f%%", o.Kind, o.Coverage*100)
+	}
+	if len(report.Overlays) > 0 {
+		s += ")"
+	}
+	return s
+}
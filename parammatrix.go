@@ -0,0 +1,108 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// maxParamVariants bounds the number of URLs --param-matrix may produce per
+// target, so a typo does not start thousands of page loads.
+const maxParamVariants = 1000
+
+// paramAxis is one query parameter of --param-matrix with the values to try.
+type paramAxis struct {
+	Name   string
+	Values []string
+}
+
+// parseParamMatrix parses a --param-matrix spec such as
+// "utm_source=a,b;variant=1,2" into its axes. Values may be empty, as in
+// "ref=,partner" to try the parameter empty and set.
+func parseParamMatrix(spec string) ([]paramAxis, error) {
+	if strings.TrimSpace(spec) == "" {
+		return nil, nil
+	}
+	var axes []paramAxis
+	variants := 1
+	for _, part := range strings.Split(spec, ";") {
+		name, values, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok || name == "" {
+			return nil, fmt.Errorf("invalid --param-matrix entry %q (expected NAME=VALUE,VALUE)", part)
+		}
+		for _, axis := range axes {
+			if axis.Name == name {
+				return nil, fmt.Errorf("--param-matrix lists parameter %q twice", name)
+			}
+		}
+		axis := paramAxis{Name: name, Values: strings.Split(values, ",")}
+		variants *= len(axis.Values)
+		if variants > maxParamVariants {
+			return nil, fmt.Errorf("--param-matrix %q expands to more than %d variants per target", spec, maxParamVariants)
+		}
+		axes = append(axes, axis)
+	}
+	return axes, nil
+}
+
+// expandParamMatrix returns every URL once per combination of the axes'
+// values, the first axis varying slowest. Parameters named by an axis
+// replace those already in the URL; the others are kept.
+func expandParamMatrix(urls []string, axes []paramAxis) ([]string, error) {
+	if len(axes) == 0 {
+		return urls, nil
+	}
+	var out []string
+	for _, rawURL := range urls {
+		u, err := url.Parse(rawURL)
+		if err != nil {
+			return nil, fmt.Errorf("invalid target %q for --param-matrix: %w", rawURL, err)
+		}
+		// Keep the other parameters as written, in their order
+		var kept []string
+		for _, pair := range strings.Split(u.RawQuery, "&") {
+			if pair == "" {
+				continue
+			}
+			key, _, _ := strings.Cut(pair, "=")
+			if name, err := url.QueryUnescape(key); err == nil && axisNamed(axes, name) {
+				continue
+			}
+			kept = append(kept, pair)
+		}
+
+		combination := make([]int, len(axes))
+		for {
+			query := kept
+			for i, axis := range axes {
+				query = append(query[:len(query):len(query)], url.QueryEscape(axis.Name)+"="+url.QueryEscape(axis.Values[combination[i]]))
+			}
+			variant := *u
+			variant.RawQuery = strings.Join(query, "&")
+			out = append(out, variant.String())
+
+			// Advance the last axis first, like an odometer
+			i := len(axes) - 1
+			for ; i >= 0; i-- {
+				combination[i]++
+				if combination[i] < len(axes[i].Values) {
+					break
+				}
+				combination[i] = 0
+			}
+			if i < 0 {
+				break
+			}
+		}
+	}
+	return out, nil
+}
+
+func axisNamed(axes []paramAxis, name string) bool {
+	for _, axis := range axes {
+		if axis.Name == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"time"
+)
+
+// pauseBeforeExit keeps the browser open for d after the actions ran, or
+// until Enter is pressed or ctx ends, for --pause-before-exit. err is the
+// outcome of the actions, logged so it is at hand while inspecting the
+// page.
+func pauseBeforeExit(ctx context.Context, d time.Duration, err error) {
+	attrs := []any{"pause", d}
+	if err != nil {
+		attrs = append(attrs, "error", err)
+	}
+	slog.Info("Keeping the browser open for inspection, press Enter to close it sooner", attrs...)
+
+	resume := make(chan struct{})
+	go func() {
+		// Without a terminal, stdin ends at once and the pause runs its
+		// course
+		if _, err := bufio.NewReader(os.Stdin).ReadString('\n'); err == nil {
+			close(resume)
+		}
+	}()
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+	case <-resume:
+		slog.Debug("Pause ended by the user")
+	case <-ctx.Done():
+	}
+}
+
+// recordDevToolsURL logs where the tab of run can be inspected, when
+// connected to a remote browser, and records it in the Result. A tab that
+// cannot be opened is left for navigation to report.
+func recordDevToolsURL(ctx context.Context, run *Run) {
+	u, err := run.Browser.DevToolsURL(ctx)
+	if err != nil {
+		slog.Warn("Failed to get the DevTools URL of the tab", "target", run.Result.Target, "error", err)
+		return
+	}
+	if u != "" {
+		slog.Info("Inspect the page in DevTools", "target", run.Result.Target, "url", u)
+		run.Result.DevToolsURL = u
+	}
+}
+
+// validatePause checks --pause-before-exit, which needs a window to look at
+// and a single target, and extends the timeout by the pause.
+func validatePause(cfg *Config, targets int) error {
+	if cfg.Headful && cfg.RemoteDebuggingPort != "" {
+		slog.Error("--headful specified with --remote-debugging-port")
+		return fmt.Errorf("--headful cannot be used with --remote-debugging-port; start that Chrome without --headless instead")
+	}
+	if cfg.PauseBeforeExit < 0 {
+		slog.Error("Invalid pause value", "pauseBeforeExit", cfg.PauseBeforeExit)
+		return fmt.Errorf("pause before exit cannot be negative: %d", cfg.PauseBeforeExit)
+	}
+	if cfg.PauseBeforeExit > 0 {
+		if !cfg.Headful && cfg.RemoteDebuggingPort == "" {
+			slog.Error("--pause-before-exit specified without --headful")
+			return fmt.Errorf("--pause-before-exit requires --headful, or a visible Chrome connected with --remote-debugging-port")
+		}
+		if targets > 1 {
+			slog.Error("--pause-before-exit specified with several targets")
+			return fmt.Errorf("--pause-before-exit requires a single target")
+		}
+		// The browser session ends with the timeout
+		originalTimeout := cfg.Timeout
+		cfg.Timeout += cfg.PauseBeforeExit
+		slog.Debug("Timeout extended by the pause before exit",
+			"originalTimeout", originalTimeout,
+			"pauseBeforeExit", cfg.PauseBeforeExit,
+			"newTimeout", cfg.Timeout)
+	}
+	return nil
+}
@@ -0,0 +1,417 @@
+// Package apiclient is a client for the HTTP API of "that-cli-web-toolbox
+// serve", for Go programs that want captures from a shared server rather
+// than a browser of their own (for that, see package toolbox).
+//
+// Its types follow the OpenAPI definition in openapi.yaml, which the server
+// publishes at GET /openapi.yaml and which is the contract for clients in
+// other languages. The server decodes requests into Request itself, so the
+// two cannot drift apart.
+//
+//	c := apiclient.New("http://localhost:8080", os.Getenv("TOOLBOX_API_KEY"))
+//	png, err := c.Screenshot(ctx, &apiclient.Request{URL: "https://example.com", Format: "png"})
+package apiclient
+
+import (
+	"bytes"
+	"context"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Spec is the OpenAPI 3 definition of the API.
+//
+//go:embed openapi.yaml
+var Spec []byte
+
+// Request is the JSON body of every POST endpoint. Only URL is required;
+// the zero value of every other field leaves the server's default.
+type Request struct {
+	// URL is the absolute http or https URL to load.
+	URL string `json:"url"`
+	// Selector is the element to capture (Screenshot) or whose text to
+	// extract (Extract).
+	Selector string `json:"selector,omitempty"`
+	// Delay is how many seconds to wait after the page loads, the server's
+	// --delay when nil.
+	Delay *int `json:"delay,omitempty"`
+	// Timeout in seconds for the whole request; it can shorten, but not
+	// exceed, the server's --timeout.
+	Timeout  int    `json:"timeout,omitempty"`
+	Viewport string `json:"viewport,omitempty"`
+	Device   string `json:"device,omitempty"`
+	DarkMode bool   `json:"darkMode,omitempty"`
+	// FullPage captures the whole page rather than the viewport; true when
+	// nil.
+	FullPage *bool `json:"fullPage,omitempty"`
+	// Format is png, jpeg or webp; jpeg when empty, png with Selector or
+	// OmitBackground.
+	Format string `json:"format,omitempty"`
+	// Quality from 1 to 100 for jpeg and webp, 90 when zero.
+	Quality int `json:"quality,omitempty"`
+	// JS is run after the delay.
+	JS string `json:"js,omitempty"`
+	// Steps are interaction steps, in the syntax of --step.
+	Steps   []string          `json:"steps,omitempty"`
+	Headers map[string]string `json:"headers,omitempty"`
+	Cookies []Cookie          `json:"cookies,omitempty"`
+
+	OmitBackground        bool  `json:"omitBackground,omitempty"`
+	CaptureBeyondViewport *bool `json:"captureBeyondViewport,omitempty"`
+	FromSurface           *bool `json:"fromSurface,omitempty"`
+
+	// ExpectSelectors, ExpectText, ExpectStatus and MaxLoadTime are the
+	// assertions of Check, as for the --expect-* flags and --max-load-time
+	// (e.g. "5s").
+	ExpectSelectors []string `json:"expectSelectors,omitempty"`
+	ExpectText      string   `json:"expectText,omitempty"`
+	ExpectStatus    int      `json:"expectStatus,omitempty"`
+	MaxLoadTime     string   `json:"maxLoadTime,omitempty"`
+}
+
+// Job types, the endpoint a job does the work of.
+const (
+	JobScreenshot = "screenshot"
+	JobPDF        = "pdf"
+	JobExtract    = "extract"
+	JobCheck      = "check"
+)
+
+// Job statuses.
+const (
+	JobQueued  = "queued"
+	JobRunning = "running"
+	// JobDone jobs have a result, including /check results with failed
+	// checks.
+	JobDone = "done"
+	// JobFailed jobs have an error, and a result with the error response
+	// the endpoint would have sent.
+	JobFailed = "failed"
+)
+
+// JobRequest is the body of POST /jobs: a Request for the endpoint named
+// by Type, run in the background.
+type JobRequest struct {
+	// Type is JobScreenshot, JobPDF, JobExtract or JobCheck.
+	Type string `json:"type"`
+	// CallbackURL, if set, is sent the Job as JSON once it has finished.
+	CallbackURL string `json:"callbackUrl,omitempty"`
+	Request
+}
+
+// Job is a job submitted to POST /jobs.
+type Job struct {
+	ID   string `json:"id"`
+	Type string `json:"type"`
+	URL  string `json:"url"`
+	// Tenant is the tenant the job runs for, on servers with --tenant-by.
+	Tenant string `json:"tenant,omitempty"`
+	Status string `json:"status"`
+	// Created, Started and Finished are when the job was submitted, started
+	// running (including the wait for a page) and finished.
+	Created  time.Time `json:"created"`
+	Started  time.Time `json:"started,omitzero"`
+	Finished time.Time `json:"finished,omitzero"`
+	// StatusCode, ContentType and Size describe the result of a finished
+	// job: the response the endpoint would have sent, served at Result.
+	StatusCode  int    `json:"statusCode,omitempty"`
+	ContentType string `json:"contentType,omitempty"`
+	Size        int    `json:"size,omitempty"`
+	Result      string `json:"result,omitempty"`
+	// Error is why a failed job failed.
+	Error string `json:"error,omitempty"`
+	// Expires is when the server forgets a finished job and its result.
+	Expires time.Time `json:"expires,omitzero"`
+}
+
+// Over tells whether the job is done or has failed.
+func (j *Job) Over() bool {
+	return j.Status == JobDone || j.Status == JobFailed
+}
+
+// Cookie is set in the page before navigation.
+type Cookie struct {
+	Name   string `json:"name"`
+	Value  string `json:"value"`
+	Domain string `json:"domain,omitempty"`
+	Path   string `json:"path,omitempty"`
+	// Expires is in seconds since the epoch; zero means a session cookie.
+	Expires  float64 `json:"expires,omitempty"`
+	HTTPOnly bool    `json:"httpOnly,omitempty"`
+	Secure   bool    `json:"secure,omitempty"`
+	SameSite string  `json:"sameSite,omitempty"`
+}
+
+// Result is the response of Extract and Check.
+type Result struct {
+	// Target is the requested URL.
+	Target    string         `json:"target"`
+	Page      *PageMetadata  `json:"page,omitempty"`
+	Redirects []RedirectHop  `json:"redirects,omitempty"`
+	Body      string         `json:"body,omitempty"`
+	Selectors []SelectorText `json:"selectors,omitempty"`
+	Checks    []CheckResult  `json:"checks,omitempty"`
+}
+
+// PageMetadata describes the loaded document.
+type PageMetadata struct {
+	// URL is the document's final URL after any redirects.
+	URL         string `json:"url"`
+	Title       string `json:"title"`
+	Canonical   string `json:"canonical"`
+	Description string `json:"description"`
+	Lang        string `json:"lang"`
+}
+
+// RedirectHop is one URL on the way to the final page.
+type RedirectHop struct {
+	URL string `json:"url"`
+	// Reason is "initial" for the target itself, otherwise metaTagRefresh,
+	// httpHeaderRefresh or scriptInitiated.
+	Reason string `json:"reason"`
+}
+
+// SelectorText is the text of every element matching a selector.
+type SelectorText struct {
+	Selector string   `json:"selector"`
+	Elements []string `json:"elements"`
+}
+
+// CheckResult is the outcome of one assertion of Check.
+type CheckResult struct {
+	Name     string `json:"name"`
+	Expected string `json:"expected"`
+	Actual   string `json:"actual"`
+	Passed   bool   `json:"passed"`
+}
+
+// Passed tells whether every check of the result passed.
+func (r *Result) Passed() bool {
+	for _, c := range r.Checks {
+		if !c.Passed {
+			return false
+		}
+	}
+	return true
+}
+
+// Usage is what the calling API key has used of the server.
+type Usage struct {
+	Key         string `json:"key"`
+	Requests    int64  `json:"requests"`
+	Succeeded   int64  `json:"succeeded"`
+	Failed      int64  `json:"failed"`
+	RateLimited int64  `json:"rateLimited"`
+	// RenderSeconds is the time spent serving the key's requests.
+	RenderSeconds float64   `json:"renderSeconds"`
+	LastUsed      time.Time `json:"lastUsed,omitzero"`
+}
+
+// Error is a response of the server with an error status.
+type Error struct {
+	StatusCode int
+	Message    string
+	// RetryAfter is when to try again, for 429 and 503 responses that say.
+	RetryAfter time.Duration
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("server returned %d: %s", e.StatusCode, e.Message)
+}
+
+// Temporary tells whether the request may succeed when retried later: the
+// server was busy, the key's rate limit was exceeded, or the server was
+// unavailable or shutting down.
+func (e *Error) Temporary() bool {
+	return e.StatusCode == http.StatusTooManyRequests || e.StatusCode == http.StatusServiceUnavailable
+}
+
+// Client calls a server's API.
+type Client struct {
+	// BaseURL is the server's URL, e.g. "http://localhost:8080".
+	BaseURL string
+	// APIKey is sent as a bearer token, for servers with --api-keys.
+	APIKey string
+	// HTTPClient makes the requests, http.DefaultClient when nil. Bound
+	// calls with their context rather than its Timeout.
+	HTTPClient *http.Client
+}
+
+// New returns a client of the server at baseURL, authenticating with
+// apiKey unless it is empty.
+func New(baseURL, apiKey string) *Client {
+	return &Client{BaseURL: strings.TrimRight(baseURL, "/"), APIKey: apiKey}
+}
+
+// Screenshot returns a screenshot of the page or of req.Selector, in the
+// format of req.Format.
+func (c *Client) Screenshot(ctx context.Context, req *Request) ([]byte, error) {
+	data, _, err := c.do(ctx, http.MethodPost, "/screenshot", req)
+	return data, err
+}
+
+// PDF returns the page printed to PDF.
+func (c *Client) PDF(ctx context.Context, req *Request) ([]byte, error) {
+	data, _, err := c.do(ctx, http.MethodPost, "/pdf", req)
+	return data, err
+}
+
+// Extract returns the page metadata and its body text, or with
+// req.Selector the text of every matching element.
+func (c *Client) Extract(ctx context.Context, req *Request) (*Result, error) {
+	data, _, err := c.do(ctx, http.MethodPost, "/extract", req)
+	if err != nil {
+		return nil, err
+	}
+	return decode[Result](data)
+}
+
+// Check evaluates the Expect fields of req against the page. Failed checks
+// are not an error; see Result.Passed.
+func (c *Client) Check(ctx context.Context, req *Request) (*Result, error) {
+	data, status, err := c.do(ctx, http.MethodPost, "/check", req)
+	var apiErr *Error
+	if errors.As(err, &apiErr) && status == http.StatusExpectationFailed {
+		data, err = []byte(apiErr.Message), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return decode[Result](data)
+}
+
+// Usage returns the usage of the client's API key.
+func (c *Client) Usage(ctx context.Context) (*Usage, error) {
+	data, _, err := c.do(ctx, http.MethodGet, "/usage", nil)
+	if err != nil {
+		return nil, err
+	}
+	return decode[Usage](data)
+}
+
+// SubmitJob queues req to run in the background and returns the job, to
+// be polled with Job or reported to req.CallbackURL.
+func (c *Client) SubmitJob(ctx context.Context, req *JobRequest) (*Job, error) {
+	data, _, err := c.do(ctx, http.MethodPost, "/jobs", req)
+	if err != nil {
+		return nil, err
+	}
+	return decode[Job](data)
+}
+
+// Job returns the job with the given ID.
+func (c *Client) Job(ctx context.Context, id string) (*Job, error) {
+	data, _, err := c.do(ctx, http.MethodGet, "/jobs/"+url.PathEscape(id), nil)
+	if err != nil {
+		return nil, err
+	}
+	return decode[Job](data)
+}
+
+// JobResult returns the result of a finished job: the body of the response
+// the endpoint would have sent. The result of a failed job is returned as
+// *Error, as the endpoint's error would have been; that of a check job
+// whose checks failed is not an error.
+func (c *Client) JobResult(ctx context.Context, id string) ([]byte, error) {
+	data, status, err := c.do(ctx, http.MethodGet, "/jobs/"+url.PathEscape(id)+"/result", nil)
+	var apiErr *Error
+	if errors.As(err, &apiErr) && status == http.StatusExpectationFailed {
+		return []byte(apiErr.Message), nil
+	}
+	return data, err
+}
+
+// WaitJob polls the job every interval until it has finished, and returns
+// it.
+func (c *Client) WaitJob(ctx context.Context, id string, interval time.Duration) (*Job, error) {
+	for {
+		job, err := c.Job(ctx, id)
+		if err != nil || job.Over() {
+			return job, err
+		}
+		select {
+		case <-time.After(interval):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// Health returns nil while the server is up and not shutting down.
+func (c *Client) Health(ctx context.Context) error {
+	_, _, err := c.do(ctx, http.MethodGet, "/healthz", nil)
+	return err
+}
+
+// do sends body, if any, as JSON to path and returns the response body and
+// status. Error statuses are returned as *Error; for 417 its Message is the
+// whole body.
+func (c *Client) do(ctx context.Context, method, path string, body any) ([]byte, int, error) {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return nil, 0, err
+		}
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.BaseURL+path, reader)
+	if err != nil {
+		return nil, 0, err
+	}
+	if reader != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+c.APIKey)
+	}
+
+	client := c.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer func() {
+		if err := resp.Body.Close(); err != nil {
+			slog.Warn("failed to close response body", "error", err)
+		}
+	}()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, resp.StatusCode, fmt.Errorf("failed to read response: %w", err)
+	}
+	if resp.StatusCode < 300 {
+		return data, resp.StatusCode, nil
+	}
+
+	apiErr := &Error{StatusCode: resp.StatusCode, Message: strings.TrimSpace(string(data))}
+	var payload struct {
+		Error string `json:"error"`
+	}
+	if resp.StatusCode != http.StatusExpectationFailed && json.Unmarshal(data, &payload) == nil && payload.Error != "" {
+		apiErr.Message = payload.Error
+	}
+	if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil {
+		apiErr.RetryAfter = time.Duration(seconds) * time.Second
+	}
+	return nil, resp.StatusCode, apiErr
+}
+
+func decode[T any](data []byte) (*T, error) {
+	var v T
+	if err := json.Unmarshal(data, &v); err != nil {
+		return nil, fmt.Errorf("invalid response: %w", err)
+	}
+	return &v, nil
+}
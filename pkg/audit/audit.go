@@ -0,0 +1,210 @@
+// Package audit keeps a tamper-evident log of runs: a file of JSON records,
+// one per line, each carrying the SHA-256 hash of its content and of the
+// record before it. Changing, inserting, reordering or removing a record
+// breaks the chain from there on, which Verify reports.
+package audit
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"time"
+
+	"github.com/pesarkhobeee/that-cli-web-toolbox/internal/proclock"
+)
+
+const (
+	// lockTimeout bounds the wait for another process appending to the
+	// same log.
+	lockTimeout = 10 * time.Second
+	// maxRecord bounds the size of one record when reading a log.
+	maxRecord = 16 << 20
+)
+
+// Record describes one run.
+type Record struct {
+	// Seq numbers the records of a log from 1.
+	Seq  int       `json:"seq"`
+	Time time.Time `json:"time"`
+	// User and Host identify who ran the command where.
+	User    string   `json:"user"`
+	Host    string   `json:"host"`
+	Command []string `json:"command"`
+	Targets []string `json:"targets"`
+	// Artifacts are the outputs written during the run.
+	Artifacts []Artifact `json:"artifacts"`
+	// Outcome is "success" or "failure".
+	Outcome  string `json:"outcome"`
+	Error    string `json:"error,omitempty"`
+	ExitCode int    `json:"exitCode"`
+	// PrevHash is the Hash of the previous record, empty for the first.
+	PrevHash string `json:"prevHash"`
+	// Hash is the hex SHA-256 of the record's JSON encoding with Hash
+	// empty.
+	Hash string `json:"hash"`
+}
+
+// Artifact is an output written during a run.
+type Artifact struct {
+	Name string `json:"name"`
+	// Location is the path or URL the output was written to, empty for
+	// standard output.
+	Location    string `json:"location"`
+	ContentType string `json:"contentType"`
+	Size        int    `json:"size"`
+	SHA256      string `json:"sha256"`
+}
+
+// NewArtifact describes data written as name to location.
+func NewArtifact(name, location, contentType string, data []byte) Artifact {
+	sum := sha256.Sum256(data)
+	return Artifact{
+		Name:        name,
+		Location:    location,
+		ContentType: contentType,
+		Size:        len(data),
+		SHA256:      hex.EncodeToString(sum[:]),
+	}
+}
+
+// digest returns the hash of r with its Hash field empty.
+func (r Record) digest() (string, error) {
+	r.Hash = ""
+	data, err := json.Marshal(r)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// Append chains rec to the log at path, creating the log if needed: it sets
+// rec's Seq, PrevHash and Hash and writes it as the last line. Processes
+// appending to the same log at once take turns through a lock file next to
+// it.
+func Append(path string, rec *Record) error {
+	unlock, err := proclock.Lock(path+".lock", lockTimeout)
+	if err != nil {
+		return fmt.Errorf("failed to lock audit log: %w", err)
+	}
+	defer unlock()
+
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0o600)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := f.Close(); err != nil {
+			slog.Warn("failed to close audit log", "file", path, "error", err)
+		}
+	}()
+
+	last, err := lastRecord(f)
+	if err != nil {
+		return fmt.Errorf("failed to read last audit record of %s: %w", path, err)
+	}
+	rec.Seq, rec.PrevHash = 1, ""
+	if last != nil {
+		rec.Seq, rec.PrevHash = last.Seq+1, last.Hash
+	}
+	rec.Time = rec.Time.UTC()
+	if rec.Hash, err = rec.digest(); err != nil {
+		return err
+	}
+
+	line, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		return err
+	}
+	return f.Sync()
+}
+
+// lastRecord returns the record on the last line of f, or nil when f is
+// empty. It reads backwards from the end, so appending stays cheap however
+// long the log grows.
+func lastRecord(f *os.File) (*Record, error) {
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	end := info.Size()
+	var tail []byte
+	buf := make([]byte, 4096)
+	for pos := end; pos > 0; {
+		n := int64(len(buf))
+		if pos < n {
+			n = pos
+		}
+		pos -= n
+		if _, err := f.ReadAt(buf[:n], pos); err != nil {
+			return nil, err
+		}
+		tail = append(append([]byte(nil), buf[:n]...), tail...)
+		if i := bytes.LastIndexByte(bytes.TrimRight(tail, "\n"), '\n'); i >= 0 {
+			tail = tail[i+1:]
+			break
+		}
+		if len(tail) > maxRecord {
+			return nil, fmt.Errorf("last record exceeds %d bytes", maxRecord)
+		}
+	}
+
+	tail = bytes.TrimSpace(tail)
+	if len(tail) == 0 {
+		return nil, nil
+	}
+	var rec Record
+	if err := json.Unmarshal(tail, &rec); err != nil {
+		return nil, err
+	}
+	return &rec, nil
+}
+
+// Verify checks every record of the log read from r: its hash, its link to
+// the record before and its sequence number. It returns the number of
+// records and the hash of the last one; anchoring that hash elsewhere also
+// makes removing records from the end detectable. The error names the
+// first record that does not verify.
+func Verify(r io.Reader) (int, string, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), maxRecord)
+
+	count, prev := 0, ""
+	for line := 1; scanner.Scan(); line++ {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		var rec Record
+		dec := json.NewDecoder(bytes.NewReader(scanner.Bytes()))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&rec); err != nil {
+			return count, prev, fmt.Errorf("line %d: invalid record: %w", line, err)
+		}
+		digest, err := rec.digest()
+		if err != nil {
+			return count, prev, err
+		}
+		switch {
+		case rec.Hash != digest:
+			return count, prev, fmt.Errorf("line %d: record %d was modified (hash mismatch)", line, rec.Seq)
+		case rec.PrevHash != prev:
+			return count, prev, fmt.Errorf("line %d: record %d does not follow the previous record (chain broken)", line, rec.Seq)
+		case rec.Seq != count+1:
+			return count, prev, fmt.Errorf("line %d: record %d found where record %d was expected", line, rec.Seq, count+1)
+		}
+		count, prev = rec.Seq, rec.Hash
+	}
+	if err := scanner.Err(); err != nil {
+		return count, prev, err
+	}
+	return count, prev, nil
+}
@@ -0,0 +1,282 @@
+// Package baseline keeps the approved screenshots of visual regression
+// tests in a directory, one entry per URL, viewport and theme. A run whose
+// screenshot differs from the approved one, or that has none yet, leaves
+// it as a candidate until it is approved, replacing the baseline, or
+// rejected.
+//
+// Each entry is a directory named by its key, holding baseline.png,
+// candidate.png and diff.png as they exist, and meta.json.
+package baseline
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// File names of an entry.
+const (
+	baselineFile  = "baseline.png"
+	candidateFile = "candidate.png"
+	diffFile      = "diff.png"
+	metaFile      = "meta.json"
+)
+
+// Statuses of an entry.
+const (
+	// StatusApproved entries have a baseline and no candidate.
+	StatusApproved = "approved"
+	// StatusNew entries have a candidate and no baseline yet.
+	StatusNew = "new"
+	// StatusChanged entries have a candidate differing from the baseline.
+	StatusChanged = "changed"
+	// StatusUnchanged checks found the screenshot to match the baseline.
+	StatusUnchanged = "unchanged"
+)
+
+// ErrNotFound is returned for keys without an entry.
+var ErrNotFound = errors.New("no such baseline")
+
+// Entry describes an entry of the store.
+type Entry struct {
+	Key      string `json:"key"`
+	URL      string `json:"url"`
+	Viewport string `json:"viewport"`
+	Theme    string `json:"theme"`
+	Status   string `json:"status,omitempty"`
+	// Approved is when the baseline was approved, and Captured when the
+	// candidate was captured.
+	Approved time.Time `json:"approved,omitzero"`
+	Captured time.Time `json:"captured,omitzero"`
+	// Ratio is the share of the pixels of the candidate that differ from
+	// the baseline.
+	Ratio float64 `json:"ratio,omitempty"`
+}
+
+// Check is the outcome of comparing a screenshot with its baseline.
+type Check struct {
+	Key    string `json:"key"`
+	Status string `json:"status"`
+	// Ratio is the share of the pixels that differ, and Regions the number
+	// of areas they form.
+	Ratio   float64 `json:"ratio,omitempty"`
+	Regions int     `json:"regions,omitempty"`
+	// Candidate is the file of the candidate left for approval, if any.
+	Candidate string `json:"candidate,omitempty"`
+}
+
+// Store is a directory of baselines.
+type Store struct {
+	Dir string
+}
+
+// keySlug removes what is not a letter, digit, dot or dash from keys.
+var keySlug = regexp.MustCompile(`[^a-z0-9.-]+`)
+
+// Key returns the key of the entry of a URL, viewport and theme: a
+// readable slug of them, unique through a hash of all three.
+func Key(target, viewport, theme string) string {
+	slug := target
+	if u, err := url.Parse(target); err == nil && u.Host != "" {
+		slug = u.Host + u.Path
+	}
+	slug = strings.Trim(keySlug.ReplaceAllString(strings.ToLower(slug), "_"), "_")
+	if len(slug) > 60 {
+		slug = slug[:60]
+	}
+	sum := sha256.Sum256([]byte(target + "\n" + viewport + "\n" + theme))
+	return fmt.Sprintf("%s-%s-%s-%s", slug, keySlug.ReplaceAllString(strings.ToLower(viewport), "_"), theme, hex.EncodeToString(sum[:4]))
+}
+
+func (s Store) path(key, file string) string {
+	return filepath.Join(s.Dir, key, file)
+}
+
+// Baseline returns the approved screenshot of key, or an error wrapping
+// os.ErrNotExist when there is none.
+func (s Store) Baseline(key string) ([]byte, error) {
+	return os.ReadFile(s.path(key, baselineFile))
+}
+
+// SaveCandidate stores image as the candidate of e, with diff, the overlay
+// of its differences from the baseline, unless nil. It returns the file
+// of the candidate.
+func (s Store) SaveCandidate(e Entry, image, diff []byte) (string, error) {
+	if err := os.MkdirAll(filepath.Join(s.Dir, e.Key), 0o755); err != nil {
+		return "", err
+	}
+	prev, err := s.meta(e.Key)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return "", err
+	}
+	e.Approved = prev.Approved
+	e.Captured = time.Now().UTC()
+	candidate := s.path(e.Key, candidateFile)
+	if err := os.WriteFile(candidate, image, 0o644); err != nil {
+		return "", err
+	}
+	if diff == nil {
+		err = removeIfExists(s.path(e.Key, diffFile))
+	} else {
+		err = os.WriteFile(s.path(e.Key, diffFile), diff, 0o644)
+	}
+	if err != nil {
+		return "", err
+	}
+	return candidate, s.writeMeta(e)
+}
+
+// ClearCandidate removes the candidate of key, after a run matched the
+// baseline.
+func (s Store) ClearCandidate(key string) error {
+	if err := removeIfExists(s.path(key, candidateFile)); err != nil {
+		return err
+	}
+	if err := removeIfExists(s.path(key, diffFile)); err != nil {
+		return err
+	}
+	e, err := s.meta(key)
+	if err != nil {
+		return err
+	}
+	e.Captured, e.Ratio = time.Time{}, 0
+	return s.writeMeta(e)
+}
+
+// List returns the entries of the store, sorted by URL, viewport and
+// theme. A missing directory has none.
+func (s Store) List() ([]Entry, error) {
+	dirs, err := os.ReadDir(s.Dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var entries []Entry
+	for _, d := range dirs {
+		if !d.IsDir() {
+			continue
+		}
+		e, err := s.Get(d.Name())
+		if errors.Is(err, ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		a, b := entries[i], entries[j]
+		if a.URL != b.URL {
+			return a.URL < b.URL
+		}
+		if a.Viewport != b.Viewport {
+			return a.Viewport < b.Viewport
+		}
+		return a.Theme < b.Theme
+	})
+	return entries, nil
+}
+
+// Get returns the entry of key.
+func (s Store) Get(key string) (Entry, error) {
+	e, err := s.meta(key)
+	if errors.Is(err, os.ErrNotExist) {
+		return Entry{}, fmt.Errorf("%w: %s", ErrNotFound, key)
+	}
+	if err != nil {
+		return Entry{}, err
+	}
+	hasBaseline, hasCandidate := exists(s.path(key, baselineFile)), exists(s.path(key, candidateFile))
+	switch {
+	case hasCandidate && hasBaseline:
+		e.Status = StatusChanged
+	case hasCandidate:
+		e.Status = StatusNew
+	case hasBaseline:
+		e.Status = StatusApproved
+	default:
+		return Entry{}, fmt.Errorf("%w: %s", ErrNotFound, key)
+	}
+	return e, nil
+}
+
+// Approve makes the candidate of key its baseline.
+func (s Store) Approve(key string) error {
+	e, err := s.Get(key)
+	if err != nil {
+		return err
+	}
+	if e.Status == StatusApproved {
+		return fmt.Errorf("%s has no candidate to approve", key)
+	}
+	if err := os.Rename(s.path(key, candidateFile), s.path(key, baselineFile)); err != nil {
+		return err
+	}
+	if err := removeIfExists(s.path(key, diffFile)); err != nil {
+		return err
+	}
+	e.Approved, e.Captured, e.Ratio = time.Now().UTC(), time.Time{}, 0
+	return s.writeMeta(e)
+}
+
+// Reject removes the candidate of key, keeping its baseline. An entry
+// without a baseline is removed entirely.
+func (s Store) Reject(key string) error {
+	e, err := s.Get(key)
+	if err != nil {
+		return err
+	}
+	switch e.Status {
+	case StatusApproved:
+		return fmt.Errorf("%s has no candidate to reject", key)
+	case StatusNew:
+		return os.RemoveAll(filepath.Join(s.Dir, key))
+	}
+	return s.ClearCandidate(key)
+}
+
+func (s Store) meta(key string) (Entry, error) {
+	data, err := os.ReadFile(s.path(key, metaFile))
+	if err != nil {
+		return Entry{}, err
+	}
+	var e Entry
+	if err := json.Unmarshal(data, &e); err != nil {
+		return Entry{}, fmt.Errorf("invalid %s: %w", s.path(key, metaFile), err)
+	}
+	e.Key = key
+	return e, nil
+}
+
+func (s Store) writeMeta(e Entry) error {
+	e.Status = ""
+	data, err := json.MarshalIndent(e, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(s.path(e.Key, metaFile), append(data, '\n'), 0o644)
+}
+
+func exists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+func removeIfExists(path string) error {
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
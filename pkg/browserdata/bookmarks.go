@@ -0,0 +1,145 @@
+package browserdata
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"html"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	// bookmarkToken matches the parts of a Netscape bookmark file that
+	// matter: folder titles, folder starts and ends, and links.
+	bookmarkToken = regexp.MustCompile(`(?is)<h3\b[^>]*>(.*?)</h3>|<dl\b[^>]*>|</dl\s*>|<a\b([^>]*)>(.*?)</a>`)
+	// htmlAttribute matches a quoted attribute inside a tag.
+	htmlAttribute = regexp.MustCompile(`(?is)([a-z_-]+)\s*=\s*"([^"]*)"`)
+	// htmlTag matches tags left in titles.
+	htmlTag = regexp.MustCompile(`<[^>]*>`)
+)
+
+// ReadBookmarks returns the bookmarks in the file at path, in the order
+// they appear. It reads the HTML export format of all major browsers
+// (NETSCAPE-Bookmark-file-1) and Chrome's JSON Bookmarks file.
+func ReadBookmarks(path string) ([]Entry, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '{' {
+		return parseChromeBookmarks(data)
+	}
+	return parseBookmarksHTML(string(data))
+}
+
+// parseBookmarksHTML reads a Netscape bookmark file, in which each folder
+// is an <H3> title followed by a <DL> list of links and subfolders.
+func parseBookmarksHTML(doc string) ([]Entry, error) {
+	if !strings.Contains(strings.ToUpper(doc), "<DL") {
+		return nil, fmt.Errorf("not a bookmark file (expected an HTML export with <DL> lists, or Chrome's Bookmarks JSON)")
+	}
+	var entries []Entry
+	// folders holds the path of each open <DL>; the outermost list is the
+	// root and has no title
+	var folders []string
+	pending := ""
+	for _, m := range bookmarkToken.FindAllStringSubmatch(doc, -1) {
+		token := strings.ToLower(m[0])
+		switch {
+		case strings.HasPrefix(token, "<h3"):
+			pending = cleanTitle(m[1])
+		case strings.HasPrefix(token, "<dl"):
+			folders = append(folders, pending)
+			pending = ""
+		case strings.HasPrefix(token, "</dl"):
+			if len(folders) > 0 {
+				folders = folders[:len(folders)-1]
+			}
+		default:
+			attrs := make(map[string]string)
+			for _, a := range htmlAttribute.FindAllStringSubmatch(m[2], -1) {
+				attrs[strings.ToLower(a[1])] = html.UnescapeString(a[2])
+			}
+			if attrs["href"] == "" {
+				continue
+			}
+			entry := Entry{URL: attrs["href"], Title: cleanTitle(m[3]), Folder: folderPath(folders)}
+			if secs, err := strconv.ParseInt(attrs["add_date"], 10, 64); err == nil && secs > 0 {
+				entry.Time = time.Unix(secs, 0).UTC()
+			}
+			entries = append(entries, entry)
+		}
+	}
+	return entries, nil
+}
+
+func cleanTitle(s string) string {
+	return strings.TrimSpace(html.UnescapeString(htmlTag.ReplaceAllString(s, "")))
+}
+
+// folderPath joins the titled folders of a folder stack.
+func folderPath(folders []string) string {
+	var names []string
+	for _, f := range folders {
+		if f != "" {
+			names = append(names, f)
+		}
+	}
+	return strings.Join(names, "/")
+}
+
+// chromeBookmark is a node of Chrome's Bookmarks file.
+type chromeBookmark struct {
+	Type      string           `json:"type"`
+	Name      string           `json:"name"`
+	URL       string           `json:"url"`
+	DateAdded string           `json:"date_added"`
+	Children  []chromeBookmark `json:"children"`
+}
+
+// parseChromeBookmarks reads the Bookmarks file of a Chrome profile.
+func parseChromeBookmarks(data []byte) ([]Entry, error) {
+	var file struct {
+		Roots map[string]json.RawMessage `json:"roots"`
+	}
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("invalid Chrome bookmarks file: %w", err)
+	}
+	if file.Roots == nil {
+		return nil, fmt.Errorf("invalid Chrome bookmarks file: no roots")
+	}
+
+	var entries []Entry
+	var walk func(node chromeBookmark, folder string)
+	walk = func(node chromeBookmark, folder string) {
+		if node.Type == "url" {
+			us, _ := strconv.ParseInt(node.DateAdded, 10, 64)
+			entries = append(entries, Entry{URL: node.URL, Title: node.Name, Folder: folder, Time: chromeTime(us)})
+			return
+		}
+		for _, child := range node.Children {
+			sub := node.Name
+			if folder != "" {
+				sub = folder + "/" + node.Name
+			}
+			walk(child, sub)
+		}
+	}
+	// Roots in the order Chrome shows them; other keys hold metadata
+	for _, name := range []string{"bookmark_bar", "other", "synced"} {
+		raw, ok := file.Roots[name]
+		if !ok {
+			continue
+		}
+		var root chromeBookmark
+		if err := json.Unmarshal(raw, &root); err != nil {
+			return nil, fmt.Errorf("invalid Chrome bookmarks file: %s: %w", name, err)
+		}
+		walk(root, "")
+	}
+	return entries, nil
+}
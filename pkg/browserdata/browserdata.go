@@ -0,0 +1,67 @@
+// Package browserdata reads the pages a user has bookmarked or visited:
+// bookmark files exported as HTML by any browser, Chrome's Bookmarks file
+// and the history database of a Chrome profile.
+package browserdata
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"time"
+)
+
+// Entry is a bookmarked or visited page.
+type Entry struct {
+	URL   string
+	Title string
+	// Folder is the slash-separated bookmark folder, empty for history.
+	Folder string
+	// Time is when the bookmark was added or the page was last visited;
+	// zero when unknown.
+	Time time.Time
+	// Visits is the number of visits of a history entry.
+	Visits int
+}
+
+// chromeEpochOffset is the number of microseconds between the origin of
+// Chrome's timestamps, 1601-01-01 UTC, and the Unix epoch.
+const chromeEpochOffset = 11644473600000000
+
+// chromeTime converts a Chrome timestamp, with 0 meaning unknown.
+func chromeTime(us int64) time.Time {
+	if us <= 0 {
+		return time.Time{}
+	}
+	return time.UnixMicro(us - chromeEpochOffset).UTC()
+}
+
+// DefaultChromeProfile returns the directory of the default profile of
+// Google Chrome, or of Chromium when only that is installed.
+func DefaultChromeProfile() (string, error) {
+	var candidates []string
+	switch runtime.GOOS {
+	case "darwin":
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		support := filepath.Join(home, "Library", "Application Support")
+		candidates = []string{filepath.Join(support, "Google", "Chrome"), filepath.Join(support, "Chromium")}
+	case "windows":
+		local := os.Getenv("LOCALAPPDATA")
+		candidates = []string{filepath.Join(local, "Google", "Chrome", "User Data"), filepath.Join(local, "Chromium", "User Data")}
+	default:
+		config, err := os.UserConfigDir()
+		if err != nil {
+			return "", err
+		}
+		candidates = []string{filepath.Join(config, "google-chrome"), filepath.Join(config, "chromium")}
+	}
+	for _, dir := range candidates {
+		profile := filepath.Join(dir, "Default")
+		if _, err := os.Stat(profile); err == nil {
+			return profile, nil
+		}
+	}
+	return filepath.Join(candidates[0], "Default"), nil
+}
@@ -0,0 +1,84 @@
+package browserdata
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	// Registers the pure-Go "sqlite" driver, so builds need no C compiler
+	_ "modernc.org/sqlite"
+)
+
+// ReadChromeHistory returns the pages in the history of the Chrome profile
+// in dir, or in the History database at dir itself, most recently visited
+// first. Pages Chrome hides from the history view, such as subframes, are
+// left out.
+//
+// The database is read from a copy of it and its write-ahead log, so it
+// works while Chrome is running and holds the database locked, and
+// includes the visits Chrome has only written to the log.
+func ReadChromeHistory(dir string) ([]Entry, error) {
+	path := dir
+	if info, err := os.Stat(dir); err == nil && info.IsDir() {
+		path = filepath.Join(dir, "History")
+	}
+	tmp, err := os.MkdirTemp("", "history-*")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tmp)
+	snapshot := filepath.Join(tmp, "History")
+	if err := copyFile(path, snapshot); err != nil {
+		return nil, err
+	}
+	if err := copyFile(path+"-wal", snapshot+"-wal"); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+
+	db, err := sql.Open("sqlite", snapshot)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	defer db.Close()
+	rows, err := db.Query(`SELECT url, title, last_visit_time, visit_count FROM urls
+		WHERE hidden = 0 AND url != '' ORDER BY last_visit_time DESC, id`)
+	if err != nil {
+		return nil, fmt.Errorf("%s: failed to read history: %w", path, err)
+	}
+	defer rows.Close()
+	entries := []Entry{}
+	for rows.Next() {
+		var e Entry
+		var visited int64
+		if err := rows.Scan(&e.URL, &e.Title, &visited, &e.Visits); err != nil {
+			return nil, fmt.Errorf("%s: failed to read history: %w", path, err)
+		}
+		e.Time = chromeTime(visited)
+		entries = append(entries, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: failed to read history: %w", path, err)
+	}
+	return entries, nil
+}
+
+// copyFile copies the file src to dst.
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
@@ -0,0 +1,87 @@
+package browserdata
+
+import (
+	"database/sql"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// chromeMicros returns t as a Chrome timestamp.
+func chromeMicros(t time.Time) int64 {
+	return t.UnixMicro() + chromeEpochOffset
+}
+
+func TestReadChromeHistory(t *testing.T) {
+	profile := t.TempDir()
+	path := filepath.Join(profile, "History")
+	// Like Chrome's, the database stays open in WAL mode, so recent visits
+	// are only in History-wal
+	db, err := sql.Open("sqlite", path+"?_pragma=journal_mode(WAL)&_pragma=wal_autocheckpoint(0)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+	if _, err := db.Exec(`CREATE TABLE urls (id INTEGER PRIMARY KEY AUTOINCREMENT, url LONGVARCHAR, title LONGVARCHAR,
+		visit_count INTEGER DEFAULT 0 NOT NULL, typed_count INTEGER DEFAULT 0 NOT NULL,
+		last_visit_time INTEGER NOT NULL, hidden INTEGER DEFAULT 0 NOT NULL)`); err != nil {
+		t.Fatal(err)
+	}
+	old := time.Date(2024, 3, 1, 9, 30, 0, 0, time.UTC)
+	recent := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	for _, row := range []struct {
+		url, title string
+		visits     int
+		at         int64
+		hidden     int
+	}{
+		{"https://example.com/old", "Old", 2, chromeMicros(old), 0},
+		{"https://example.com/recent", "Recent ünïcode", 7, chromeMicros(recent), 0},
+		{"https://ads.example.com/frame", "Frame", 1, chromeMicros(recent), 1},
+		{"https://example.com/unknown", "", 1, 0, 0},
+	} {
+		if _, err := db.Exec(`INSERT INTO urls (url, title, visit_count, last_visit_time, hidden) VALUES (?, ?, ?, ?, ?)`,
+			row.url, row.title, row.visits, row.at, row.hidden); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if info, err := os.Stat(path + "-wal"); err != nil || info.Size() == 0 {
+		t.Fatalf("the visits are not in the write-ahead log: %v", err)
+	}
+
+	for _, dir := range []string{profile, path} {
+		entries, err := ReadChromeHistory(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := []Entry{
+			{URL: "https://example.com/recent", Title: "Recent ünïcode", Time: recent, Visits: 7},
+			{URL: "https://example.com/old", Title: "Old", Time: old, Visits: 2},
+			{URL: "https://example.com/unknown", Visits: 1},
+		}
+		if len(entries) != len(want) {
+			t.Fatalf("ReadChromeHistory(%s) = %+v, want %+v", dir, entries, want)
+		}
+		for i := range want {
+			if entries[i] != want[i] {
+				t.Errorf("entry %d = %+v, want %+v", i, entries[i], want[i])
+			}
+		}
+	}
+}
+
+func TestReadChromeHistoryErrors(t *testing.T) {
+	dir := t.TempDir()
+	if _, err := ReadChromeHistory(dir); err == nil {
+		t.Error("reading a profile without History succeeded")
+	}
+	notDB := filepath.Join(dir, "History")
+	if err := os.WriteFile(notDB, []byte("not a database"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ReadChromeHistory(notDB); err == nil {
+		t.Error("reading a file that is no database succeeded")
+	}
+}
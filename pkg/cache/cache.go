@@ -0,0 +1,350 @@
+// Package cache manages the tool's cache directory, which holds what the
+// tool downloads or creates for itself and can do without: Chrome for
+// Testing builds, the temporary profiles (user data directories) of the
+// Chromes it starts and the captures of desktop deep links.
+//
+// The directory is $XDG_CACHE_HOME/that-cli-web-toolbox, or the
+// platform's user cache directory, with a subdirectory per kind of
+// content. Profiles are named after the process that created them,
+// PID-RANDOM, so the ones left behind by killed processes can be told from
+// those still in use, also by parallel processes sharing the directory.
+// Processes starting a browser build lease it with a file named the same
+// way, so it is not removed under them.
+package cache
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pesarkhobeee/that-cli-web-toolbox/internal/proclock"
+)
+
+// Name is the directory of the tool in the user's cache directory.
+const Name = "that-cli-web-toolbox"
+
+// Kinds of content, each kept in the subdirectory of its name.
+const (
+	// Browsers holds Chrome for Testing builds.
+	Browsers = "browsers"
+	// Captures holds the captures of desktop deep links.
+	Captures = "captures"
+	// Profiles holds the temporary profiles of running Chromes.
+	Profiles = "profiles"
+)
+
+// Kinds lists the kinds of content.
+var Kinds = []string{Browsers, Captures, Profiles}
+
+// leases is the directory in Browsers holding the leases of the browser
+// builds running processes use.
+const leases = ".in-use"
+
+// lockTimeout bounds the wait for another process leasing browser builds
+// or cleaning them.
+const lockTimeout = 30 * time.Second
+
+// Cache is a cache directory.
+type Cache struct {
+	Dir string
+}
+
+// Default returns the cache directory of the user: $XDG_CACHE_HOME if
+// set, else the platform's (~/.cache on Linux, ~/Library/Caches on macOS,
+// %LocalAppData% on Windows), else the temporary directory.
+func Default() Cache {
+	base := os.Getenv("XDG_CACHE_HOME")
+	if !filepath.IsAbs(base) {
+		var err error
+		if base, err = os.UserCacheDir(); err != nil {
+			base = os.TempDir()
+		}
+	}
+	return Cache{Dir: filepath.Join(base, Name)}
+}
+
+// Path returns the directory of kind.
+func (c Cache) Path(kind string) string {
+	return filepath.Join(c.Dir, kind)
+}
+
+// Usage is the disk usage of a kind of content.
+type Usage struct {
+	Kind    string `json:"kind"`
+	Path    string `json:"path"`
+	Entries int    `json:"entries"`
+	Bytes   int64  `json:"bytes"`
+	// Stale counts the profiles whose process has exited.
+	Stale int `json:"stale,omitempty"`
+}
+
+// Usage returns the disk usage of every kind, in the order of Kinds.
+func (c Cache) Usage() ([]Usage, error) {
+	var usage []Usage
+	for _, kind := range Kinds {
+		u := Usage{Kind: kind, Path: c.Path(kind)}
+		entries, err := os.ReadDir(u.Path)
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return nil, err
+		}
+		for _, e := range entries {
+			if kind == Browsers && e.Name() == leases {
+				continue
+			}
+			u.Entries++
+			size, err := diskUsage(filepath.Join(u.Path, e.Name()))
+			if err != nil {
+				return nil, err
+			}
+			u.Bytes += size
+			if kind == Profiles && Stale(e.Name()) {
+				u.Stale++
+			}
+		}
+		usage = append(usage, u)
+	}
+	return usage, nil
+}
+
+// Cleaned is what Clean removed, and kept because it is in use.
+type Cleaned struct {
+	Entries int   `json:"entries"`
+	Bytes   int64 `json:"bytes"`
+	// InUse counts the profiles and browser builds kept for their running
+	// processes.
+	InUse int `json:"inUse"`
+}
+
+// Clean removes the content of kinds. Profiles and leased browser builds
+// are only removed once their processes have exited, so Clean is safe
+// while other processes run.
+func (c Cache) Clean(kinds []string) (*Cleaned, error) {
+	cleaned := &Cleaned{}
+	for _, kind := range kinds {
+		if !slices.Contains(Kinds, kind) {
+			return nil, fmt.Errorf("unknown kind %q (expected one of %s)", kind, strings.Join(Kinds, ", "))
+		}
+		if err := c.clean(kind, cleaned); err != nil {
+			return nil, err
+		}
+	}
+	return cleaned, nil
+}
+
+// clean removes the content of kind not in use, counting it in cleaned.
+func (c Cache) clean(kind string, cleaned *Cleaned) error {
+	dir := c.Path(kind)
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var used map[string]bool
+	if kind == Browsers {
+		// Leasing a build waits until the unused ones are removed
+		unlock, err := c.lockBrowsers()
+		if err != nil {
+			return err
+		}
+		defer unlock()
+		if used, err = c.leasedBrowsers(); err != nil {
+			return err
+		}
+	}
+	for _, e := range entries {
+		switch {
+		case kind == Profiles && !Stale(e.Name()):
+			cleaned.InUse++
+			continue
+		case kind == Browsers && e.Name() == leases:
+			continue
+		case kind == Browsers && used[e.Name()]:
+			// A product with builds in use: remove its others
+			if err := removeUnused(filepath.Join(dir, e.Name()), e.Name(), used, cleaned); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := remove(filepath.Join(dir, e.Name()), cleaned); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// removeUnused removes the builds of the product directory dir, named
+// product, that are not used. The file naming its current build is kept.
+func removeUnused(dir, product string, used map[string]bool, cleaned *Cleaned) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		if !e.IsDir() {
+			continue
+		}
+		if used[product+"/"+e.Name()] {
+			cleaned.InUse++
+			continue
+		}
+		if err := remove(filepath.Join(dir, e.Name()), cleaned); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// remove removes path, counting it in cleaned.
+func remove(path string, cleaned *Cleaned) error {
+	size, err := diskUsage(path)
+	if err != nil {
+		return err
+	}
+	if err := os.RemoveAll(path); err != nil {
+		return err
+	}
+	cleaned.Entries++
+	cleaned.Bytes += size
+	return nil
+}
+
+// UseBrowser leases the browser build holding the file path, such as its
+// executable, to the process until it exits, so Clean keeps the build
+// meanwhile. path must be below the Browsers directory.
+func (c Cache) UseBrowser(path string) error {
+	build, err := c.browserBuild(path)
+	if err != nil {
+		return err
+	}
+	dir := filepath.Join(c.Path(Browsers), leases)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	unlock, err := c.lockBrowsers()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	// A Clean that ran since path was found removed the build
+	if _, err := os.Stat(path); err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(dir, fmt.Sprintf("%d-*", os.Getpid()))
+	if err != nil {
+		return err
+	}
+	_, err = f.WriteString(build + "\n")
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// lockBrowsers locks the leases of browser builds against a concurrent
+// Clean or UseBrowser, with a lock file next to the Browsers directory.
+func (c Cache) lockBrowsers() (func(), error) {
+	unlock, err := proclock.Lock(c.Path(Browsers)+".lock", lockTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("failed to lock browser builds: %w", err)
+	}
+	return unlock, nil
+}
+
+// browserBuild returns the build holding path as PRODUCT/BUILD, the
+// directories below Browsers.
+func (c Cache) browserBuild(path string) (string, error) {
+	rel, err := filepath.Rel(c.Path(Browsers), path)
+	if err != nil {
+		return "", err
+	}
+	parts := strings.Split(filepath.ToSlash(rel), "/")
+	if len(parts) < 3 || parts[0] == ".." || parts[0] == leases {
+		return "", fmt.Errorf("%s is not in a browser build of %s", path, c.Path(Browsers))
+	}
+	return parts[0] + "/" + parts[1], nil
+}
+
+// leasedBrowsers returns the builds leased by running processes, as
+// PRODUCT/BUILD and PRODUCT, and removes the leases of exited ones.
+func (c Cache) leasedBrowsers() (map[string]bool, error) {
+	dir := filepath.Join(c.Path(Browsers), leases)
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	used := make(map[string]bool)
+	for _, e := range entries {
+		path := filepath.Join(dir, e.Name())
+		if Stale(e.Name()) {
+			if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+				return nil, err
+			}
+			continue
+		}
+		data, err := os.ReadFile(path)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		build := strings.TrimSpace(string(data))
+		product, _, ok := strings.Cut(build, "/")
+		if !ok {
+			continue
+		}
+		used[build] = true
+		used[product] = true
+	}
+	return used, nil
+}
+
+// Stale reports whether the profile directory name was created by a
+// process that has exited. Names not made by a process, PID-RANDOM, are
+// stale.
+func Stale(name string) bool {
+	prefix, _, _ := strings.Cut(name, "-")
+	pid, err := strconv.Atoi(prefix)
+	if err != nil || pid <= 0 {
+		return true
+	}
+	return pid != os.Getpid() && !proclock.Running(pid)
+}
+
+// diskUsage returns the size of the files below path, without following
+// symbolic links.
+func diskUsage(path string) (int64, error) {
+	var size int64
+	err := filepath.WalkDir(path, func(_ string, d fs.DirEntry, err error) error {
+		if err != nil {
+			// Removed meanwhile, e.g. by an exiting Chrome
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		if d.Type().IsRegular() {
+			info, err := d.Info()
+			if err != nil {
+				if errors.Is(err, fs.ErrNotExist) {
+					return nil
+				}
+				return err
+			}
+			size += info.Size()
+		}
+		return nil
+	})
+	return size, err
+}
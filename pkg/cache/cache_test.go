@@ -0,0 +1,106 @@
+package cache
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// install creates a fake build of product with an executable, returning
+// the executable's path.
+func install(t *testing.T, c Cache, product, build string) string {
+	t.Helper()
+	dir := filepath.Join(c.Path(Browsers), product, build)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	exe := filepath.Join(dir, "chrome")
+	if err := os.WriteFile(exe, []byte("#!/bin/sh\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	return exe
+}
+
+func exists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+func TestCleanKeepsBrowsersInUse(t *testing.T) {
+	c := Cache{Dir: t.TempDir()}
+	used := install(t, c, "chrome", "131.0.6778.85-linux64")
+	unused := install(t, c, "chrome", "130.0.6723.58-linux64")
+	otherProduct := install(t, c, "chrome-headless-shell", "131.0.6778.85-linux64")
+	if err := os.WriteFile(filepath.Join(c.Path(Browsers), "chrome", "current"), []byte("131.0.6778.85-linux64\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.UseBrowser(used); err != nil {
+		t.Fatal(err)
+	}
+	// The lease of an exited process, for the unused build
+	stale := filepath.Join(c.Path(Browsers), leases, "0-exited")
+	if err := os.WriteFile(stale, []byte("chrome/130.0.6723.58-linux64\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(c.Path(Profiles), fmt.Sprintf("%d-running", os.Getpid())), 0o700); err != nil {
+		t.Fatal(err)
+	}
+
+	cleaned, err := c.Clean(Kinds)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !exists(used) || !exists(filepath.Join(c.Path(Browsers), "chrome", "current")) {
+		t.Error("the build in use or its product's current file was removed")
+	}
+	for _, path := range []string{unused, otherProduct, stale} {
+		if exists(path) {
+			t.Errorf("%s was kept", path)
+		}
+	}
+	if cleaned.InUse != 2 || cleaned.Entries != 2 {
+		t.Errorf("cleaned %+v, want 2 entries removed and 2 (a build and a profile) in use", cleaned)
+	}
+
+	// Once the lease is gone, the build goes too
+	if err := os.RemoveAll(filepath.Join(c.Path(Browsers), leases)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Clean([]string{Browsers}); err != nil {
+		t.Fatal(err)
+	}
+	if exists(used) {
+		t.Error("the build was kept without a lease")
+	}
+}
+
+func TestUseBrowserOutsideBrowsers(t *testing.T) {
+	c := Cache{Dir: t.TempDir()}
+	for _, path := range []string{
+		filepath.Join(t.TempDir(), "chrome"),
+		filepath.Join(c.Path(Browsers), "chrome"),
+		filepath.Join(c.Path(Browsers), leases, "x", "chrome"),
+	} {
+		if err := c.UseBrowser(path); err == nil {
+			t.Errorf("UseBrowser(%s) succeeded", path)
+		}
+	}
+}
+
+func TestStale(t *testing.T) {
+	tests := []struct {
+		name string
+		want bool
+	}{
+		{fmt.Sprintf("%d-abc", os.Getpid()), false},
+		{"0-abc", true},
+		{"-1-abc", true},
+		{"profile", true},
+	}
+	for _, tt := range tests {
+		if got := Stale(tt.name); got != tt.want {
+			t.Errorf("Stale(%q) = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,172 @@
+package chromedphelper
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"github.com/chromedp/cdproto/runtime"
+	"github.com/chromedp/chromedp"
+)
+
+// Kinds of FoldElement.
+const (
+	FoldHeading = "heading"
+	FoldText    = "text"
+	FoldImage   = "image"
+	FoldVideo   = "video"
+	FoldEmbed   = "embed"
+	FoldControl = "control"
+)
+
+// maxFoldElements bounds the elements AboveFold returns, for pages with
+// huge first screens such as data tables.
+const maxFoldElements = 500
+
+// AboveFold is what a page shows without scrolling, at the current
+// viewport.
+type AboveFold struct {
+	// Viewport is the fold: the first screen of the document.
+	Viewport Box `json:"viewport"`
+	// Elements are the visible headings, text blocks, images, videos,
+	// embeds and form controls intersecting the fold, in document order.
+	Elements []FoldElement `json:"elements"`
+	// Truncated is set when there were more than Elements lists.
+	Truncated bool `json:"truncated,omitempty"`
+	// LCP is the Largest Contentful Paint element the browser reported, or
+	// nil if it reported none.
+	LCP *LCPElement `json:"lcp,omitempty"`
+}
+
+// FoldElement is an element visible without scrolling.
+type FoldElement struct {
+	// Kind is FoldHeading, FoldText, FoldImage, FoldVideo, FoldEmbed or
+	// FoldControl.
+	Kind string `json:"kind"`
+	// Selector is a CSS selector matching only this element.
+	Selector string `json:"selector"`
+	Tag      string `json:"tag"`
+	// Text is the element's visible text or accessible name, shortened.
+	Text string `json:"text,omitempty"`
+	// Src is the URL of an image, video poster or embed; for an element
+	// with a CSS background image, that image.
+	Src string `json:"src,omitempty"`
+	Box Box    `json:"box"`
+	// Visible is the share of the element's area inside the fold, from 0
+	// to 1.
+	Visible float64 `json:"visible"`
+	// Lazy marks images with loading="lazy", which delays them when they
+	// are above the fold.
+	Lazy bool `json:"lazy,omitempty"`
+}
+
+// LCPElement is the element of the page's Largest Contentful Paint.
+type LCPElement struct {
+	Selector string `json:"selector,omitempty"`
+	Tag      string `json:"tag,omitempty"`
+	// URL is the image's URL; empty for text.
+	URL string `json:"url,omitempty"`
+	// Size is the painted area in CSS pixels.
+	Size float64 `json:"size"`
+	// TimeMS is when it was painted, in milliseconds since navigation.
+	TimeMS float64 `json:"timeMs"`
+}
+
+// aboveFoldScript lists the content intersecting the first screen of the
+// document. Text blocks are the nearest non-inline ancestors of text, so
+// a paragraph counts once however its text is marked up.
+const aboveFoldScript = `async (limit) => {
+	const selectorOf = ` + selectorOfScript + `;
+	const width = window.innerWidth, height = window.innerHeight;
+	const shorten = (s) => (s || '').replace(/\s+/g, ' ').trim().slice(0, 100);
+	const shortenURL = (url) => url.startsWith('data:') ? url.slice(0, 40) + '...' : url;
+
+	const blocks = new Set();
+	const texts = document.createTreeWalker(document.body || document.documentElement, NodeFilter.SHOW_TEXT);
+	for (let node = texts.nextNode(); node; node = texts.nextNode()) {
+		if (!node.data.trim()) continue;
+		let el = node.parentElement;
+		while (el && el.parentElement && ['inline', 'contents'].includes(getComputedStyle(el).display)) el = el.parentElement;
+		if (el && !['SCRIPT', 'STYLE', 'NOSCRIPT', 'TEMPLATE'].includes(el.tagName)) blocks.add(el);
+	}
+
+	const kindOf = (el) => {
+		const tag = el.localName;
+		if (tag === 'img' || tag === 'canvas' || (tag === 'svg' && !el.parentElement.closest('svg'))) return ['image', el.currentSrc || el.src || ''];
+		if (tag === 'video') return ['video', el.poster || el.currentSrc || ''];
+		if (tag === 'iframe' || tag === 'embed' || tag === 'object') return ['embed', el.src || el.data || ''];
+		if (el.matches('button, input:not([type="hidden"]), select, textarea, [role="button"]')) return ['control', ''];
+		if (/^h[1-6]$/.test(tag) && blocks.has(el)) return ['heading', ''];
+		if (blocks.has(el)) return ['text', ''];
+		const bg = getComputedStyle(el).backgroundImage.match(/url\(["']?([^"')]+)["']?\)/);
+		if (bg) return ['image', new URL(bg[1], document.baseURI).href];
+		return null;
+	};
+
+	const elements = [];
+	let truncated = false;
+	const walker = document.createTreeWalker(document.body || document.documentElement, NodeFilter.SHOW_ELEMENT);
+	for (let el = walker.currentNode; el; el = walker.nextNode()) {
+		const kind = kindOf(el);
+		if (!kind) continue;
+		const r = el.getBoundingClientRect();
+		if (r.width === 0 || r.height === 0) continue;
+		const style = getComputedStyle(el);
+		if (style.visibility === 'hidden' || style.opacity === '0') continue;
+		const x = r.left + window.scrollX, y = r.top + window.scrollY;
+		const visibleWidth = Math.min(x + r.width, width) - Math.max(x, 0);
+		const visibleHeight = Math.min(y + r.height, height) - Math.max(y, 0);
+		if (visibleWidth <= 0 || visibleHeight <= 0) continue;
+		if (elements.length === limit) {
+			truncated = true;
+			break;
+		}
+		const text = kind[0] === 'image' || kind[0] === 'video' || kind[0] === 'embed'
+			? shorten(el.alt || el.title || el.getAttribute('aria-label'))
+			: shorten(el.innerText || el.value || el.getAttribute('aria-label') || el.placeholder);
+		elements.push({kind: kind[0], selector: selectorOf(el), tag: el.localName, text, src: shortenURL(kind[1]),
+			box: {x, y, width: r.width, height: r.height},
+			visible: Math.round(visibleWidth * visibleHeight / (r.width * r.height) * 100) / 100,
+			lazy: el.localName === 'img' && el.loading === 'lazy'});
+	}
+
+	// The LCP entry is only available through a buffered observer
+	const entry = await new Promise((resolve) => {
+		setTimeout(() => resolve(null), 500);
+		try {
+			new PerformanceObserver((list, observer) => {
+				observer.disconnect();
+				const entries = list.getEntries();
+				resolve(entries[entries.length - 1]);
+			}).observe({type: 'largest-contentful-paint', buffered: true});
+		} catch (e) {
+			resolve(null);
+		}
+	});
+	const lcp = entry && {
+		selector: entry.element && entry.element.isConnected ? selectorOf(entry.element) : '',
+		tag: entry.element ? entry.element.localName : '',
+		url: shortenURL(entry.url || ''), size: entry.size, timeMs: Math.round(entry.startTime),
+	};
+
+	return {viewport: {x: 0, y: 0, width, height}, elements, truncated, lcp};
+}`
+
+// AboveFold lists the content visible without scrolling at the current
+// viewport and the page's Largest Contentful Paint element.
+// Assumes NavigateAndPrepare has already been called.
+func (b *Browser) AboveFold(ctx context.Context) (*AboveFold, error) {
+	slog.Debug("Listing above-the-fold content")
+
+	var fold AboveFold
+	err := b.run(ctx, chromedp.Evaluate(fmt.Sprintf("(%s)(%d)", aboveFoldScript, maxFoldElements), &fold, func(p *runtime.EvaluateParams) *runtime.EvaluateParams {
+		return p.WithAwaitPromise(true)
+	}))
+	if err != nil {
+		slog.Error("Failed to list above-the-fold content", "error", err)
+		return nil, err
+	}
+
+	slog.Debug("Above-the-fold content listed", "elements", len(fold.Elements), "truncated", fold.Truncated, "lcp", fold.LCP != nil)
+	return &fold, nil
+}
@@ -0,0 +1,131 @@
+package chromedphelper
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+
+	"github.com/chromedp/cdproto/accessibility"
+	"github.com/chromedp/cdproto/dom"
+	"github.com/chromedp/chromedp"
+)
+
+// maxAccessibleNames bounds the elements AccessibleNames describes per
+// selector.
+const maxAccessibleNames = 200
+
+// AccessibleNames is what screen readers announce for the elements matching
+// a selector.
+type AccessibleNames struct {
+	Selector string              `json:"selector"`
+	Elements []AccessibleElement `json:"elements"`
+	// Truncated is set when more elements matched than Elements lists.
+	Truncated bool `json:"truncated,omitempty"`
+}
+
+// AccessibleElement is the computed accessible name, role and description
+// of an element, as in the accessibility tree of Chrome.
+type AccessibleElement struct {
+	// Selector is a CSS selector matching only this element.
+	Selector    string `json:"selector"`
+	Role        string `json:"role,omitempty"`
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	// NameFrom tells where the name came from: the attribute, such as
+	// aria-label or alt, the native source, such as label, or contents.
+	NameFrom string `json:"nameFrom,omitempty"`
+	// Ignored is set for elements left out of the accessibility tree, e.g.
+	// hidden ones or those with aria-hidden.
+	Ignored bool `json:"ignored,omitempty"`
+}
+
+// AccessibleNames returns the computed accessible name, role and
+// description of the elements matching selector, in document order
+// (Accessibility.getPartialAXTree). No match is not an error.
+// Assumes NavigateAndPrepare has already been called.
+func (b *Browser) AccessibleNames(ctx context.Context, selector string) (*AccessibleNames, error) {
+	slog.Debug("Getting accessible names", "selector", selector)
+
+	encoded, err := json.Marshal(selector)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &AccessibleNames{Selector: selector, Elements: []AccessibleElement{}}
+	var selectors []string
+	err = b.run(ctx,
+		chromedp.Evaluate(`Array.from(document.querySelectorAll(`+string(encoded)+`)).map(`+selectorOfScript+`)`, &selectors),
+		chromedp.ActionFunc(func(ctx context.Context) error {
+			if len(selectors) > maxAccessibleNames {
+				selectors = selectors[:maxAccessibleNames]
+				result.Truncated = true
+			}
+			root, err := dom.GetDocument().WithDepth(0).Do(ctx)
+			if err != nil {
+				return err
+			}
+			for _, sel := range selectors {
+				id, err := dom.QuerySelector(root.NodeID, sel).Do(ctx)
+				if err != nil {
+					return err
+				}
+				nodes, err := accessibility.GetPartialAXTree().WithNodeID(id).WithFetchRelatives(false).Do(ctx)
+				if err != nil {
+					return fmt.Errorf("failed to get accessibility node of %s: %w", sel, err)
+				}
+				el := AccessibleElement{Selector: sel}
+				if len(nodes) > 0 {
+					n := nodes[0]
+					el.Role = axString(n.Role)
+					el.Name = axString(n.Name)
+					el.Description = axString(n.Description)
+					el.NameFrom = nameFrom(n.Name)
+					el.Ignored = n.Ignored
+				}
+				result.Elements = append(result.Elements, el)
+			}
+			return nil
+		}),
+	)
+	if err != nil {
+		slog.Error("Failed to get accessible names", "selector", selector, "error", err)
+		return nil, err
+	}
+
+	slog.Debug("Accessible names retrieved", "selector", selector, "elements", len(result.Elements))
+	return result, nil
+}
+
+// axString returns the value of an accessibility property as a string.
+func axString(v *accessibility.Value) string {
+	if v == nil || len(v.Value) == 0 {
+		return ""
+	}
+	var s string
+	if err := json.Unmarshal(v.Value, &s); err != nil {
+		return string(v.Value)
+	}
+	return s
+}
+
+// nameFrom returns where the accessible name came from: the source that
+// supplied it and was not superseded by another.
+func nameFrom(name *accessibility.Value) string {
+	if name == nil {
+		return ""
+	}
+	for _, src := range name.Sources {
+		if src.Superseded || src.Value == nil || axString(src.Value) == "" {
+			continue
+		}
+		switch {
+		case src.Attribute != "":
+			return src.Attribute
+		case src.NativeSource != "":
+			return string(src.NativeSource)
+		}
+		return string(src.Type)
+	}
+	return ""
+}
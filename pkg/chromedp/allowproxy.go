@@ -0,0 +1,159 @@
+package chromedphelper
+
+import (
+	"errors"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pesarkhobeee/that-cli-web-toolbox/pkg/urlfilter"
+)
+
+// allowlistDialTimeout bounds connecting to an allowed host.
+const allowlistDialTimeout = 10 * time.Second
+
+// allowlistProxy is the HTTP proxy an untrusted Chrome sends all of its
+// traffic through, loopback included. It refuses every connection to a
+// host hosts does not allow, whatever the scheme: http through the proxy,
+// and https, ws and wss through CONNECT tunnels. Unlike request
+// interception, it sees WebSockets, and unlike DNS rules, connections to
+// IP literals. A nil hosts allows nothing.
+type allowlistProxy struct {
+	hosts *urlfilter.Hosts
+	ln    net.Listener
+	srv   *http.Server
+	http  *httputil.ReverseProxy
+
+	// tunnels are the open CONNECT tunnels, closed with the proxy.
+	mu      sync.Mutex
+	tunnels map[net.Conn]bool
+}
+
+// startAllowlistProxy starts an allowlistProxy on a free port of the
+// loopback interface.
+func startAllowlistProxy(hosts *urlfilter.Hosts) (*allowlistProxy, error) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+	p := &allowlistProxy{hosts: hosts, ln: ln, tunnels: make(map[net.Conn]bool)}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	// Never through the proxy of the environment, which may allow more
+	transport.Proxy = nil
+	p.http = &httputil.ReverseProxy{
+		Rewrite: func(r *httputil.ProxyRequest) {
+			// The request line of a proxied request holds the whole URL
+			r.Out.URL = r.In.URL
+		},
+		Transport: transport,
+		ErrorLog:  slog.NewLogLogger(slog.Default().Handler(), slog.LevelDebug),
+	}
+	p.srv = &http.Server{Handler: p, ReadHeaderTimeout: allowlistDialTimeout}
+	go func() {
+		if err := p.srv.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			slog.Error("Allowlist proxy stopped", "error", err)
+		}
+	}()
+	slog.Debug("Started allowlist proxy", "address", p.Addr(), "hosts", hosts.Patterns())
+	return p, nil
+}
+
+// Addr returns the address the proxy listens on, as host:port.
+func (p *allowlistProxy) Addr() string {
+	return p.ln.Addr().String()
+}
+
+// Close stops the proxy and closes its tunnels.
+func (p *allowlistProxy) Close() {
+	if err := p.srv.Close(); err != nil {
+		slog.Debug("Failed to close allowlist proxy", "error", err)
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for conn := range p.tunnels {
+		conn.Close()
+	}
+}
+
+// allowed reports whether the proxy may connect to host, with or without
+// a port.
+func (p *allowlistProxy) allowed(host string) bool {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	return p.hosts != nil && p.hosts.AllowedHost(strings.Trim(host, "[]"))
+}
+
+func (p *allowlistProxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodConnect {
+		p.tunnel(w, r)
+		return
+	}
+	if !r.URL.IsAbs() {
+		http.Error(w, "only proxy requests are served", http.StatusBadRequest)
+		return
+	}
+	if !p.allowed(r.URL.Host) {
+		slog.Debug("Blocked request to disallowed host", "url", r.URL.String())
+		http.Error(w, "host not allowed", http.StatusForbidden)
+		return
+	}
+	p.http.ServeHTTP(w, r)
+}
+
+// tunnel connects the client of a CONNECT request to its allowed host.
+func (p *allowlistProxy) tunnel(w http.ResponseWriter, r *http.Request) {
+	if !p.allowed(r.Host) {
+		slog.Debug("Blocked connection to disallowed host", "host", r.Host)
+		http.Error(w, "host not allowed", http.StatusForbidden)
+		return
+	}
+	upstream, err := net.DialTimeout("tcp", r.Host, allowlistDialTimeout)
+	if err != nil {
+		slog.Debug("Failed to connect to allowed host", "host", r.Host, "error", err)
+		http.Error(w, "failed to connect", http.StatusBadGateway)
+		return
+	}
+	client, buffered, err := http.NewResponseController(w).Hijack()
+	if err != nil {
+		upstream.Close()
+		http.Error(w, "tunnels not supported", http.StatusInternalServerError)
+		return
+	}
+	if _, err := client.Write([]byte("HTTP/1.1 200 Connection established\r\n\r\n")); err != nil {
+		upstream.Close()
+		client.Close()
+		return
+	}
+
+	p.mu.Lock()
+	p.tunnels[client] = true
+	p.tunnels[upstream] = true
+	p.mu.Unlock()
+	defer func() {
+		p.mu.Lock()
+		delete(p.tunnels, client)
+		delete(p.tunnels, upstream)
+		p.mu.Unlock()
+	}()
+
+	done := make(chan struct{}, 2)
+	go func() {
+		// What the client sent along with the CONNECT request
+		io.Copy(upstream, buffered.Reader)
+		done <- struct{}{}
+	}()
+	go func() {
+		io.Copy(client, upstream)
+		done <- struct{}{}
+	}()
+	<-done
+	client.Close()
+	upstream.Close()
+	<-done
+}
@@ -0,0 +1,194 @@
+package chromedphelper
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/chromedp/chromedp"
+
+	"github.com/pesarkhobeee/that-cli-web-toolbox/pkg/urlfilter"
+)
+
+// webSocketServer counts the WebSocket handshakes it receives, answering
+// them with 101 Switching Protocols.
+func webSocketServer(t *testing.T) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+	var handshakes atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.EqualFold(r.Header.Get("Upgrade"), "websocket") {
+			http.Error(w, "not a WebSocket handshake", http.StatusBadRequest)
+			return
+		}
+		handshakes.Add(1)
+		w.Header().Set("Upgrade", "websocket")
+		w.Header().Set("Connection", "Upgrade")
+		w.WriteHeader(http.StatusSwitchingProtocols)
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &handshakes
+}
+
+// dialWebSocket opens a WebSocket to addr through the proxy at proxyAddr
+// as Chrome does, in a CONNECT tunnel, and returns the status of the
+// CONNECT and, once the tunnel is up, of the handshake.
+func dialWebSocket(t *testing.T, proxyAddr, addr string) (connect, handshake int) {
+	t.Helper()
+	conn, err := net.DialTimeout("tcp", proxyAddr, 5*time.Second)
+	if err != nil {
+		t.Fatalf("failed to connect to the proxy: %v", err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	r := bufio.NewReader(conn)
+
+	fmt.Fprintf(conn, "CONNECT %s HTTP/1.1\r\nHost: %s\r\n\r\n", addr, addr)
+	resp, err := http.ReadResponse(r, &http.Request{Method: http.MethodConnect})
+	if err != nil {
+		t.Fatalf("failed to read the CONNECT response: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return resp.StatusCode, 0
+	}
+
+	fmt.Fprintf(conn, "GET /socket HTTP/1.1\r\nHost: %s\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n"+
+		"Sec-WebSocket-Key: dGhlIHNhbXBsZSBub25jZQ==\r\nSec-WebSocket-Version: 13\r\n\r\n", addr)
+	resp, err = http.ReadResponse(r, nil)
+	if err != nil {
+		t.Fatalf("failed to read the handshake response: %v", err)
+	}
+	resp.Body.Close()
+	return http.StatusOK, resp.StatusCode
+}
+
+func TestAllowlistProxyRefusesWebSocketToIPLiteral(t *testing.T) {
+	srv, handshakes := webSocketServer(t)
+	addr := srv.Listener.Addr().String() // 127.0.0.1:PORT
+
+	hosts, err := urlfilter.NewHosts([]string{"example.com", "*.example-cdn.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, hosts := range map[string]*urlfilter.Hosts{"no allowed host": nil, "other allowed hosts": hosts} {
+		t.Run(name, func(t *testing.T) {
+			proxy, err := startAllowlistProxy(hosts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer proxy.Close()
+
+			if connect, _ := dialWebSocket(t, proxy.Addr(), addr); connect != http.StatusForbidden {
+				t.Errorf("CONNECT to %s answered with %d, want %d", addr, connect, http.StatusForbidden)
+			}
+			if n := handshakes.Load(); n != 0 {
+				t.Errorf("the server received %d WebSocket handshakes, want none", n)
+			}
+		})
+	}
+}
+
+func TestAllowlistProxyTunnelsToAllowedHost(t *testing.T) {
+	srv, handshakes := webSocketServer(t)
+	addr := srv.Listener.Addr().String()
+
+	hosts, err := urlfilter.NewHosts([]string{"127.0.0.1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	proxy, err := startAllowlistProxy(hosts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer proxy.Close()
+
+	connect, handshake := dialWebSocket(t, proxy.Addr(), addr)
+	if connect != http.StatusOK || handshake != http.StatusSwitchingProtocols {
+		t.Errorf("WebSocket to allowed %s: CONNECT %d, handshake %d, want %d and %d",
+			addr, connect, handshake, http.StatusOK, http.StatusSwitchingProtocols)
+	}
+	if n := handshakes.Load(); n != 1 {
+		t.Errorf("the server received %d WebSocket handshakes, want 1", n)
+	}
+}
+
+func TestAllowlistProxyRefusesPlainHTTP(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("request to a disallowed host reached it: %s", r.URL)
+	}))
+	defer srv.Close()
+
+	proxy, err := startAllowlistProxy(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer proxy.Close()
+
+	client := &http.Client{Transport: &http.Transport{Proxy: func(*http.Request) (*url.URL, error) {
+		return url.Parse("http://" + proxy.Addr())
+	}}}
+	resp, err := client.Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusForbidden {
+		t.Errorf("GET %s through the proxy answered with %d, want %d", srv.URL, resp.StatusCode, http.StatusForbidden)
+	}
+}
+
+func TestUntrustedBrowserRefusesWebSocketToIPLiteral(t *testing.T) {
+	requireChrome(t)
+	srv, handshakes := webSocketServer(t)
+
+	// The page tries the server by IP address, which no DNS rule sees
+	page := filepath.Join(t.TempDir(), "untrusted.html")
+	html := fmt.Sprintf(`<html><body><p>untrusted</p><script>
+		const ws = new WebSocket("ws://%s/socket");
+		ws.onopen = () => document.title = "open";
+		ws.onerror = () => document.title = "refused";
+	</script></body></html>`, srv.Listener.Addr())
+	if err := os.WriteFile(page, []byte(html), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	b, err := InitializeChromedpContext(ctx, "file://"+page, 0, 0, "", "", WithUntrusted())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer b.Cancel()
+	b.Untrusted = true
+	if err := b.NavigateAndPrepare(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	var title string
+	deadline := time.Now().Add(10 * time.Second)
+	for title == "" {
+		if time.Now().After(deadline) {
+			t.Fatal("the WebSocket neither opened nor failed")
+		}
+		time.Sleep(100 * time.Millisecond)
+		if err := b.run(ctx, chromedp.Title(&title)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if title != "refused" {
+		t.Errorf("the page's WebSocket to %s ended with %q, want it refused", srv.Listener.Addr(), title)
+	}
+	if n := handshakes.Load(); n != 0 {
+		t.Errorf("the server received %d WebSocket handshakes, want none", n)
+	}
+}
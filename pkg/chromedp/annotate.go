@@ -0,0 +1,93 @@
+package chromedphelper
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/chromedp/chromedp"
+)
+
+// InteractiveElement is a numbered clickable element of an annotated page.
+type InteractiveElement struct {
+	// Number is the label drawn on the element, starting at 1.
+	Number int `json:"number"`
+	// Selector is a CSS selector matching only this element.
+	Selector string `json:"selector"`
+	Tag      string `json:"tag"`
+	Role     string `json:"role,omitempty"`
+	// Text is the element's visible text or accessible name, shortened.
+	Text string `json:"text,omitempty"`
+	Box  Box    `json:"box"`
+}
+
+// Box is an element's position and size in CSS pixels, relative to the
+// top-left corner of the document.
+type Box struct {
+	X      float64 `json:"x"`
+	Y      float64 `json:"y"`
+	Width  float64 `json:"width"`
+	Height float64 `json:"height"`
+}
+
+// selectorOfScript is a function returning a CSS selector matching only
+// the element passed to it, anchored at the nearest unique id.
+const selectorOfScript = `(el) => {
+	const unique = (selector) => document.querySelectorAll(selector).length === 1;
+	const parts = [];
+	for (let node = el; node && node !== document.documentElement; node = node.parentElement) {
+		if (node.id && unique('#' + CSS.escape(node.id))) {
+			parts.unshift('#' + CSS.escape(node.id));
+			return parts.join(' > ');
+		}
+		let part = node.localName;
+		const siblings = node.parentElement ? Array.from(node.parentElement.children).filter(c => c.localName === node.localName) : [];
+		if (siblings.length > 1) part += ':nth-of-type(' + (siblings.indexOf(node) + 1) + ')';
+		parts.unshift(part);
+	}
+	return 'html > ' + parts.join(' > ');
+}`
+
+// annotateScript numbers the visible interactive elements in document
+// order, outlines them and labels them in the overlay, and returns them.
+const annotateScript = `(() => {
+	const interactive = 'a[href], area[href], button, input:not([type="hidden"]), select, textarea, summary, ' +
+		'[role="button"], [role="link"], [role="checkbox"], [role="radio"], [role="tab"], [role="menuitem"], ' +
+		'[role="option"], [role="switch"], [contenteditable=""], [contenteditable="true"], [onclick], [tabindex]:not([tabindex="-1"])';
+	const selectorOf = ` + selectorOfScript + `;
+	const host = ` + overlayHost + `;
+	let labels = '';
+	const elements = [];
+	for (const el of document.querySelectorAll(interactive)) {
+		const r = el.getBoundingClientRect();
+		const style = getComputedStyle(el);
+		if (r.width === 0 || r.height === 0 || style.visibility === 'hidden' || el.disabled) continue;
+		const number = elements.length + 1;
+		const box = {x: r.left + window.scrollX, y: r.top + window.scrollY, width: r.width, height: r.height};
+		const text = (el.innerText || el.value || el.getAttribute('aria-label') || el.title || el.alt || el.placeholder || '')
+			.replace(/\s+/g, ' ').trim().slice(0, 100);
+		elements.push({number, selector: selectorOf(el), tag: el.localName, role: el.getAttribute('role') || '', text, box});
+		labels += '<div style="position:absolute;left:' + box.x + 'px;top:' + box.y + 'px;width:' + box.width + 'px;height:' + box.height + 'px;' +
+			'box-sizing:border-box;border:2px solid #e11d48"></div>' +
+			'<span style="position:absolute;left:' + box.x + 'px;top:' + box.y + 'px;background:#e11d48;color:#fff;' +
+			'font:bold 11px/14px monospace;padding:0 3px">' + number + '</span>';
+	}
+	host.shadowRoot.innerHTML += labels;
+	return elements;
+})()`
+
+// AnnotateInteractives numbers the visible links, buttons, form fields and
+// other clickable elements of the page, draws the numbers and outlines over
+// them for screenshots taken afterwards, and returns them in label order.
+// Assumes NavigateAndPrepare has already been called.
+func (b *Browser) AnnotateInteractives(ctx context.Context) ([]InteractiveElement, error) {
+	slog.Debug("Annotating interactive elements")
+
+	var elements []InteractiveElement
+	if err := b.run(ctx, chromedp.Evaluate(annotateScript, &elements)); err != nil {
+		slog.Error("Failed to annotate interactive elements", "error", err)
+		return nil, err
+	}
+
+	slog.Debug("Interactive elements annotated", "count", len(elements))
+	return elements, nil
+}
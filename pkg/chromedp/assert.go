@@ -0,0 +1,199 @@
+package chromedphelper
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/chromedp/chromedp"
+)
+
+// Predicates of an Assertion.
+const (
+	AssertVisible  = "visible"
+	AssertHidden   = "hidden"
+	AssertEnabled  = "enabled"
+	AssertDisabled = "disabled"
+	AssertCount    = "count"
+	AssertText     = "text"
+)
+
+// countOps are the comparisons of count assertions, longest first so that
+// >= is not read as >.
+var countOps = []string{">=", "<=", "==", "!=", ">", "<", "="}
+
+// Assertion is a check of the state of the elements matching a selector,
+// written SELECTOR:PREDICATE:
+//
+//	SELECTOR:visible, SELECTOR:hidden    the first match is (not) shown
+//	SELECTOR:enabled, SELECTOR:disabled  the first match is (not) disabled
+//	SELECTOR:count>=N                    number of matches, also <=, >, <, =, !=
+//	SELECTOR:text~=REGEXP                the first match's text matches REGEXP
+//	SELECTOR:text=TEXT                   the first match's text is TEXT
+//
+// hidden also holds when nothing matches; every other predicate but count
+// fails then.
+type Assertion struct {
+	Selector  string
+	Predicate string
+	// Op and Count are the comparison of a count assertion.
+	Op    string
+	Count int
+	// Text is what the text of a text assertion must match.
+	Text *regexp.Regexp
+
+	raw string
+}
+
+// String returns the assertion as written.
+func (a Assertion) String() string { return a.raw }
+
+// ParseAssertion parses an assertion written SELECTOR:PREDICATE. The
+// predicate follows the last colon, or the first ":text" for text
+// assertions, so selectors may contain pseudo-classes such as :nth-child(2).
+func ParseAssertion(s string) (Assertion, error) {
+	a := Assertion{raw: s}
+	var predicate string
+	if i := textPredicate(s); i >= 0 {
+		a.Selector, predicate = s[:i], s[i+1:]
+	} else if i := strings.LastIndex(s, ":"); i >= 0 {
+		a.Selector, predicate = s[:i], s[i+1:]
+	} else {
+		return a, fmt.Errorf("invalid assertion %q (expected SELECTOR:PREDICATE, e.g. #login:visible)", s)
+	}
+	a.Selector = strings.TrimSpace(a.Selector)
+	if a.Selector == "" {
+		return a, fmt.Errorf("invalid assertion %q: missing selector", s)
+	}
+
+	switch predicate {
+	case AssertVisible, AssertHidden, AssertEnabled, AssertDisabled:
+		a.Predicate = predicate
+		return a, nil
+	}
+	if rest, ok := strings.CutPrefix(predicate, AssertCount); ok {
+		for _, op := range countOps {
+			if n, ok := strings.CutPrefix(rest, op); ok {
+				count, err := strconv.Atoi(strings.TrimSpace(n))
+				if err != nil || count < 0 {
+					return a, fmt.Errorf("invalid assertion %q: count must be compared to a number", s)
+				}
+				a.Predicate, a.Op, a.Count = AssertCount, op, count
+				return a, nil
+			}
+		}
+		return a, fmt.Errorf("invalid assertion %q: expected count followed by >=, <=, >, <, = or != and a number", s)
+	}
+	if pattern, ok := strings.CutPrefix(predicate, "text~="); ok {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return a, fmt.Errorf("invalid assertion %q: %w", s, err)
+		}
+		a.Predicate, a.Text = AssertText, re
+		return a, nil
+	}
+	if text, ok := strings.CutPrefix(predicate, "text="); ok {
+		a.Predicate, a.Text = AssertText, regexp.MustCompile("^"+regexp.QuoteMeta(text)+"$")
+		return a, nil
+	}
+	return a, fmt.Errorf("invalid assertion %q: unknown predicate %q (expected visible, hidden, enabled, disabled, count or text)", s, predicate)
+}
+
+// textPredicate returns the index of the colon starting a text predicate
+// in s, or -1.
+func textPredicate(s string) int {
+	i := -1
+	for _, p := range []string{":text~=", ":text="} {
+		if j := strings.Index(s, p); j >= 0 && (i < 0 || j < i) {
+			i = j
+		}
+	}
+	return i
+}
+
+// elementState is what assertions inspect about the elements matching a
+// selector: their number, and the state of the first one.
+type elementState struct {
+	Count   int    `json:"count"`
+	Visible bool   `json:"visible"`
+	Enabled bool   `json:"enabled"`
+	Text    string `json:"text"`
+}
+
+// elementStateScript returns the elementState of a selector. Visible
+// follows Playwright: a non-empty box and no visibility: hidden.
+const elementStateScript = `(selector) => {
+	const all = document.querySelectorAll(selector);
+	const el = all[0];
+	if (!el) return {count: 0, visible: false, enabled: false, text: ""};
+	const r = el.getBoundingClientRect();
+	const shown = el.checkVisibility ? el.checkVisibility({visibilityProperty: true}) : el.getClientRects().length > 0;
+	return {
+		count: all.length,
+		visible: shown && r.width > 0 && r.height > 0,
+		enabled: !el.matches(":disabled") && el.getAttribute("aria-disabled") !== "true",
+		text: (el.innerText || el.value || el.textContent || "").replace(/\s+/g, " ").trim(),
+	};
+}`
+
+// assert evaluates a against the current page.
+func (b *Browser) assert(ctx context.Context, a Assertion) (CheckResult, error) {
+	sel, err := json.Marshal(a.Selector)
+	if err != nil {
+		return CheckResult{}, err
+	}
+	var state elementState
+	if err := b.run(ctx, chromedp.Evaluate(fmt.Sprintf("(%s)(%s)", elementStateScript, sel), &state)); err != nil {
+		slog.Error("Failed to query selector", "selector", a.Selector, "error", err)
+		return CheckResult{}, fmt.Errorf("failed to query selector %q: %w", a.Selector, err)
+	}
+
+	result := CheckResult{Name: CheckAssert, Expected: a.String()}
+	if state.Count == 0 && a.Predicate != AssertCount {
+		result.Actual = "no element"
+		result.Passed = a.Predicate == AssertHidden
+		return result, nil
+	}
+	switch a.Predicate {
+	case AssertVisible, AssertHidden:
+		result.Actual = AssertHidden
+		if state.Visible {
+			result.Actual = AssertVisible
+		}
+		result.Passed = result.Actual == a.Predicate
+	case AssertEnabled, AssertDisabled:
+		result.Actual = AssertDisabled
+		if state.Enabled {
+			result.Actual = AssertEnabled
+		}
+		result.Passed = result.Actual == a.Predicate
+	case AssertCount:
+		result.Actual = fmt.Sprintf("%d elements", state.Count)
+		result.Passed = compareCount(state.Count, a.Op, a.Count)
+	case AssertText:
+		result.Actual = strconv.Quote(state.Text)
+		result.Passed = a.Text.MatchString(state.Text)
+	}
+	return result, nil
+}
+
+// compareCount reports whether count op n holds.
+func compareCount(count int, op string, n int) bool {
+	switch op {
+	case ">=":
+		return count >= n
+	case "<=":
+		return count <= n
+	case ">":
+		return count > n
+	case "<":
+		return count < n
+	case "!=":
+		return count != n
+	}
+	return count == n
+}
@@ -0,0 +1,219 @@
+package chromedphelper
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"time"
+
+	"github.com/chromedp/cdproto/cdp"
+	"github.com/chromedp/cdproto/fetch"
+	"github.com/chromedp/cdproto/network"
+	"github.com/chromedp/chromedp"
+)
+
+// Credentials are HTTP basic auth credentials. They are only given in
+// answer to authentication challenges from the target URL's origin.
+type Credentials struct {
+	Username string
+	Password string
+}
+
+// Cookie is a browser cookie. Its JSON form follows the cookies reported by
+// Chrome, so files written from GetCookies can be loaded again.
+type Cookie struct {
+	Name   string `json:"name"`
+	Value  string `json:"value"`
+	Domain string `json:"domain,omitempty"`
+	Path   string `json:"path,omitempty"`
+	// Expires is in seconds since the epoch; zero means a session cookie.
+	Expires  float64 `json:"expires,omitempty"`
+	HTTPOnly bool    `json:"httpOnly,omitempty"`
+	Secure   bool    `json:"secure,omitempty"`
+	SameSite string  `json:"sameSite,omitempty"`
+}
+
+// setupNetworkAction installs the extra headers, cookies, basic auth
+// handling, URL filter, host allowlist, caps and HAR replay before
+// navigation.
+func (b *Browser) setupNetworkAction() chromedp.Action {
+	return chromedp.ActionFunc(func(ctx context.Context) error {
+		if headers := b.extraHeaders(); len(headers) > 0 {
+			slog.Debug("Setting extra HTTP headers", "count", len(headers))
+			if err := network.SetExtraHTTPHeaders(headers).Do(ctx); err != nil {
+				return fmt.Errorf("failed to set extra HTTP headers: %w", err)
+			}
+		}
+
+		for _, c := range b.Cookies {
+			slog.Debug("Setting cookie", "name", c.Name, "domain", c.Domain)
+			if err := c.params(b.TargetURL).Do(ctx); err != nil {
+				return fmt.Errorf("failed to set cookie %q: %w", c.Name, err)
+			}
+		}
+
+		handleAuth := b.BasicAuth != nil || b.ProxyAuth != nil
+		if handleAuth || b.Filter != nil || b.interceptAll() {
+			slog.Debug("Enabling request interception", "basicAuth", b.BasicAuth != nil, "proxyAuth", b.ProxyAuth != nil, "filter", b.Filter != nil, "allowedHosts", b.AllowedHosts.Patterns(), "capped", b.capped(), "replay", b.Replay != nil)
+			enable := fetch.Enable().WithHandleAuthRequests(handleAuth)
+			if !handleAuth && !b.interceptAll() {
+				// The filter only needs to see navigations
+				enable = enable.WithPatterns([]*fetch.RequestPattern{
+					{URLPattern: "*", ResourceType: network.ResourceTypeDocument},
+				})
+			}
+			if err := enable.Do(ctx); err != nil {
+				return fmt.Errorf("failed to enable request interception: %w", err)
+			}
+		}
+		return nil
+	})
+}
+
+// params returns the CDP call setting the cookie. Cookies without a domain
+// are scoped to target.
+func (c Cookie) params(target string) *network.SetCookieParams {
+	p := network.SetCookie(c.Name, c.Value)
+	if c.Domain != "" {
+		p = p.WithDomain(c.Domain)
+	} else {
+		p = p.WithURL(target)
+	}
+	if c.Path != "" {
+		p = p.WithPath(c.Path)
+	}
+	if c.Expires > 0 {
+		expires := cdp.TimeSinceEpoch(time.Unix(0, int64(c.Expires*float64(time.Second))))
+		p = p.WithExpires(&expires)
+	}
+	if c.HTTPOnly {
+		p = p.WithHTTPOnly(true)
+	}
+	if c.Secure {
+		p = p.WithSecure(true)
+	}
+	if c.SameSite != "" {
+		p = p.WithSameSite(network.CookieSameSite(c.SameSite))
+	}
+	return p
+}
+
+// GetCookies returns the cookies visible to the current page.
+// Assumes NavigateAndPrepare has already been called.
+func (b *Browser) GetCookies(ctx context.Context) ([]Cookie, error) {
+	slog.Debug("Getting cookies")
+
+	var cookies []Cookie
+	err := b.run(ctx, chromedp.ActionFunc(func(ctx context.Context) error {
+		raw, err := network.GetCookies().Do(ctx)
+		if err != nil {
+			return err
+		}
+		for _, c := range raw {
+			cookie := Cookie{
+				Name:     c.Name,
+				Value:    c.Value,
+				Domain:   c.Domain,
+				Path:     c.Path,
+				HTTPOnly: c.HTTPOnly,
+				Secure:   c.Secure,
+				SameSite: string(c.SameSite),
+			}
+			if !c.Session {
+				cookie.Expires = c.Expires
+			}
+			cookies = append(cookies, cookie)
+		}
+		return nil
+	}))
+	if err != nil {
+		slog.Error("Failed to get cookies", "error", err)
+		return nil, fmt.Errorf("failed to get cookies: %w", err)
+	}
+
+	slog.Debug("Cookies retrieved successfully", "count", len(cookies))
+	return cookies, nil
+}
+
+// handleFetchEvent resumes requests paused by request interception,
+// failing navigations the Filter denies, requests to hosts AllowedHosts
+// does not allow and requests past the caps, and answers the others from
+// Replay when set. It runs outside the listener,
+// which must not block on CDP calls.
+func (b *Browser) handleFetchEvent(ev interface{}) {
+	c := chromedp.FromContext(b.Ctx)
+	if c == nil || c.Target == nil {
+		return
+	}
+	ctx := cdp.WithExecutor(b.Ctx, c.Target)
+
+	var err error
+	switch ev := ev.(type) {
+	case *fetch.EventRequestPaused:
+		if ev.ResourceType == network.ResourceTypeDocument && !b.Filter.Allowed(ev.Request.URL) {
+			slog.Warn("Blocked navigation to denied URL", "url", ev.Request.URL)
+			err = fetch.FailRequest(ev.RequestID, network.ErrorReasonBlockedByClient).Do(ctx)
+			break
+		}
+		if !b.requestAllowed(ev.Request.URL) {
+			slog.Warn("Blocked request the page may not make", "url", ev.Request.URL)
+			err = fetch.FailRequest(ev.RequestID, network.ErrorReasonBlockedByClient).Do(ctx)
+			break
+		}
+		if b.capped() {
+			if allowed, over := b.limits.request(); !allowed {
+				err = fetch.FailRequest(ev.RequestID, network.ErrorReasonBlockedByClient).Do(ctx)
+				if over {
+					b.stopLoading()
+				}
+				break
+			}
+		}
+		if b.Replay != nil {
+			err = b.replayRequest(ctx, ev)
+			break
+		}
+		err = fetch.ContinueRequest(ev.RequestID).Do(ctx)
+	case *fetch.EventAuthRequired:
+		response := &fetch.AuthChallengeResponse{Response: fetch.AuthChallengeResponseResponseDefault}
+		if ev.AuthChallenge != nil && ev.AuthChallenge.Source == fetch.AuthChallengeSourceProxy {
+			if b.ProxyAuth != nil {
+				slog.Debug("Answering proxy auth challenge", "proxy", ev.AuthChallenge.Origin)
+				response = &fetch.AuthChallengeResponse{
+					Response: fetch.AuthChallengeResponseResponseProvideCredentials,
+					Username: b.ProxyAuth.Username,
+					Password: b.ProxyAuth.Password,
+				}
+			}
+		} else if ev.AuthChallenge != nil && b.BasicAuth != nil && ev.AuthChallenge.Origin == origin(b.TargetURL) {
+			slog.Debug("Answering basic auth challenge", "origin", ev.AuthChallenge.Origin, "realm", ev.AuthChallenge.Realm)
+			response = &fetch.AuthChallengeResponse{
+				Response: fetch.AuthChallengeResponseResponseProvideCredentials,
+				Username: b.BasicAuth.Username,
+				Password: b.BasicAuth.Password,
+			}
+		} else if ev.AuthChallenge != nil && b.BasicAuth != nil {
+			slog.Warn("Not sending credentials to foreign origin", "origin", ev.AuthChallenge.Origin)
+		}
+		err = fetch.ContinueWithAuth(ev.RequestID, response).Do(ctx)
+	}
+	if err != nil && b.Ctx.Err() == nil {
+		slog.Warn("Failed to continue intercepted request", "error", err)
+	}
+}
+
+// interceptAll reports whether every request of the tab, not only
+// navigations, must be intercepted.
+func (b *Browser) interceptAll() bool {
+	return b.AllowedHosts != nil || b.Untrusted || b.capped() || b.Replay != nil
+}
+
+// origin returns the scheme://host[:port] part of rawURL.
+func origin(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+	return u.Scheme + "://" + u.Host
+}
@@ -0,0 +1,180 @@
+package chromedphelper
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/chromedp/chromedp"
+)
+
+// Names of the assertions reported by Check.
+const (
+	CheckSelector = "selector"
+	CheckAssert   = "assert"
+	CheckText     = "text"
+	CheckStatus   = "status"
+	CheckLoadTime = "load-time"
+)
+
+// Expectations are assertions about a loaded page. Zero fields are not
+// checked.
+type Expectations struct {
+	// Selectors must each match at least one element.
+	Selectors []string
+	// Assertions must each hold.
+	Assertions []Assertion
+	// Text must match the page's body text.
+	Text *regexp.Regexp
+	// Status is the expected HTTP status of the document.
+	Status int
+	// MaxLoadTime bounds the time from navigation start to the end of the
+	// document's load event.
+	MaxLoadTime time.Duration
+}
+
+// Empty reports whether e asserts nothing.
+func (e Expectations) Empty() bool {
+	return len(e.Selectors) == 0 && len(e.Assertions) == 0 && e.Text == nil && e.Status == 0 && e.MaxLoadTime == 0
+}
+
+// CheckResult is the outcome of one assertion.
+type CheckResult struct {
+	Name     string `json:"name"`
+	Expected string `json:"expected"`
+	Actual   string `json:"actual"`
+	Passed   bool   `json:"passed"`
+}
+
+// CheckFailures returns the number of checks that did not pass.
+func CheckFailures(checks []CheckResult) int {
+	failed := 0
+	for _, c := range checks {
+		if !c.Passed {
+			failed++
+		}
+	}
+	return failed
+}
+
+// Check evaluates exp against the current page and returns one result per
+// assertion, in the order selectors, assertions, text, status, load time. A failed
+// assertion is not an error; the error is only set when the page could
+// not be inspected.
+// Assumes NavigateAndPrepare has already been called.
+func (b *Browser) Check(ctx context.Context, exp Expectations) ([]CheckResult, error) {
+	slog.Debug("Checking page expectations",
+		"selectors", exp.Selectors,
+		"assertions", len(exp.Assertions),
+		"status", exp.Status,
+		"maxLoadTime", exp.MaxLoadTime)
+
+	var checks []CheckResult
+	for _, selector := range exp.Selectors {
+		sel, err := json.Marshal(selector)
+		if err != nil {
+			return nil, err
+		}
+		var count int
+		if err := b.run(ctx, chromedp.Evaluate(fmt.Sprintf(`document.querySelectorAll(%s).length`, sel), &count)); err != nil {
+			slog.Error("Failed to query selector", "selector", selector, "error", err)
+			return nil, fmt.Errorf("failed to query selector %q: %w", selector, err)
+		}
+		checks = append(checks, CheckResult{
+			Name:     CheckSelector,
+			Expected: selector,
+			Actual:   fmt.Sprintf("%d elements", count),
+			Passed:   count > 0,
+		})
+	}
+
+	for _, a := range exp.Assertions {
+		result, err := b.assert(ctx, a)
+		if err != nil {
+			return nil, err
+		}
+		checks = append(checks, result)
+	}
+
+	if exp.Text != nil {
+		body, err := b.GetBodyText(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get body text: %w", err)
+		}
+		actual := "no match"
+		loc := exp.Text.FindStringIndex(body)
+		if loc != nil {
+			actual = strconv.Quote(body[loc[0]:loc[1]])
+		}
+		checks = append(checks, CheckResult{
+			Name:     CheckText,
+			Expected: exp.Text.String(),
+			Actual:   actual,
+			Passed:   loc != nil,
+		})
+	}
+
+	if exp.Status != 0 || exp.MaxLoadTime != 0 {
+		timing, err := b.navigationTiming(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		if exp.Status != 0 {
+			actual := "unknown"
+			if timing.Status != 0 {
+				actual = strconv.Itoa(timing.Status)
+			}
+			checks = append(checks, CheckResult{
+				Name:     CheckStatus,
+				Expected: strconv.Itoa(exp.Status),
+				Actual:   actual,
+				Passed:   timing.Status == exp.Status,
+			})
+		}
+		if exp.MaxLoadTime != 0 {
+			loadTime := time.Duration(timing.LoadTime * float64(time.Millisecond)).Round(time.Millisecond)
+			actual := loadTime.String()
+			if timing.LoadTime == 0 {
+				actual = "not loaded"
+			}
+			checks = append(checks, CheckResult{
+				Name:     CheckLoadTime,
+				Expected: "<= " + exp.MaxLoadTime.String(),
+				Actual:   actual,
+				Passed:   timing.LoadTime > 0 && loadTime <= exp.MaxLoadTime,
+			})
+		}
+	}
+
+	slog.Debug("Page expectations checked", "checks", len(checks), "failed", CheckFailures(checks))
+	return checks, nil
+}
+
+// navigationTiming is the document's HTTP status and load time in
+// milliseconds, both 0 when unknown.
+type navigationTiming struct {
+	Status   int     `json:"status"`
+	LoadTime float64 `json:"loadTime"`
+}
+
+// navigationTiming reads the navigation timing entry, which covers the
+// final document including its HTTP redirects; responseStatus is 0 where
+// the browser does not report it, such as for file:// URLs.
+func (b *Browser) navigationTiming(ctx context.Context) (navigationTiming, error) {
+	var timing navigationTiming
+	err := b.run(ctx, chromedp.Evaluate(`(() => {
+		const nav = performance.getEntriesByType("navigation")[0];
+		if (!nav) return {status: 0, loadTime: 0};
+		return {status: nav.responseStatus || 0, loadTime: nav.loadEventEnd > 0 ? nav.loadEventEnd - nav.startTime : 0};
+	})()`, &timing))
+	if err != nil {
+		slog.Error("Failed to read navigation timing", "error", err)
+		return timing, fmt.Errorf("failed to read navigation timing: %w", err)
+	}
+	return timing, nil
+}
@@ -2,106 +2,416 @@ package chromedphelper
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"log/slog"
 	"net/http"
 	"strings"
+	"sync"
 	"time"
 
+	"github.com/chromedp/cdproto/network"
 	"github.com/chromedp/cdproto/page"
 	"github.com/chromedp/cdproto/runtime"
 	"github.com/chromedp/chromedp"
+
+	"github.com/pesarkhobeee/that-cli-web-toolbox/pkg/events"
+	"github.com/pesarkhobeee/that-cli-web-toolbox/pkg/har"
+	"github.com/pesarkhobeee/that-cli-web-toolbox/pkg/tracing"
+	"github.com/pesarkhobeee/that-cli-web-toolbox/pkg/urlfilter"
 )
 
 // Browser wraps a Chromedp context and target.
+//
+// Every method takes a context.Context that bounds that single operation.
+// Cancelling it, or letting its deadline pass, aborts the operation without
+// tearing down the browser session; use Cancel to end the session itself.
+//
+// A Browser drives a single tab and is safe for concurrent use: operations
+// from different goroutines are serialized, so they never interleave on the
+// page. To work on several pages in parallel, open one tab per goroutine
+// with NewTab. The exported fields must not be modified once methods may be
+// called concurrently.
 type Browser struct {
+	// Ctx is the chromedp session context created by InitializeChromedp.
+	//
+	// Deprecated: pass a context to each Browser method instead. Ctx is kept
+	// so existing callers keep compiling and may be unexported in the future.
 	Ctx       context.Context
 	Cancel    context.CancelFunc
 	TargetURL string
 	Delay     int
 	JSCode    string
+	// Steps are performed by NavigateAndPrepare after the custom JS.
+	Steps []Step
+	// Headers are sent with every request of the tab.
+	Headers map[string]string
+	// Cookies are set before navigation.
+	Cookies []Cookie
+	// Storage, if set, is written to the web storage of its origin when
+	// the page loads, before the page's scripts run.
+	Storage *WebStorage
+	// BasicAuth, if set, answers HTTP authentication challenges.
+	BasicAuth *Credentials
+	// ProxyAuth, if set, answers the proxy's authentication challenges.
+	ProxyAuth *Credentials
+	// Emulation, if set, is applied before navigation.
+	Emulation *Emulation
+	// Locale, if set, is sent as Accept-Language (unless Headers has one)
+	// and used for the page's Intl formatting, e.g. "de" or "fr-CA".
+	Locale string
+	// FingerprintProfile, if set, is applied before navigation, after
+	// Emulation and Locale.
+	FingerprintProfile *FingerprintProfile
+	// MaxRedirects is how many client-side redirects (meta refresh,
+	// JavaScript) NavigateAndPrepare waits for and follows after the page
+	// loads. Zero does not wait for any.
+	MaxRedirects int
+	// Filter, if set, blocks navigation of the page or its frames to URLs
+	// it does not allow, such as logout or delete links.
+	Filter *urlfilter.Filter
+	// AllowedHosts, if set, fails every request of the page to a host it
+	// does not allow, so untrusted content cannot send data elsewhere.
+	AllowedHosts *urlfilter.Hosts
+	// Untrusted locks the page down for rendering untrusted HTML: it may
+	// only reach AllowedHosts, none when nil, and data: and blob: URLs,
+	// cannot download files, and a file: target is loaded as content
+	// rather than opened, so it cannot embed other local files.
+	Untrusted bool
+	// Permissions, names accepted by ParsePermissions, are granted to the
+	// target's origin before navigation.
+	Permissions []string
+	// Clipboard lets the page write and ReadClipboard read the clipboard.
+	Clipboard bool
+	// Mask holds CSS selectors of elements NavigateAndPrepare blacks out
+	// after the steps, such as e-mail addresses or tokens, so no capture
+	// shows them. Their text is replaced as well.
+	Mask []string
+	// Ready, if set, is waited for by NavigateAndPrepare once the page
+	// loaded and followed its redirects, before the delay.
+	Ready ReadinessDetector
+	// DismissConsent holds CSS selectors of cookie banner "accept" buttons,
+	// such as ConsentButtons: NavigateAndPrepare clicks the first visible
+	// one after the delay, before the custom JS. None being visible is not
+	// an error; ConsentDismissed tells which one was clicked.
+	DismissConsent []string
+	// MaxBytes and MaxRequests, if set, cap the bytes a page transfers and
+	// the requests it makes: past either, its load is aborted and further
+	// requests fail, and operations return a *LimitError.
+	MaxBytes    int64
+	MaxRequests int
+	// IsolateTabs makes NewTab open each tab in its own browser context,
+	// so tabs share no cookies or storage with b or each other.
+	IsolateTabs bool
+	// ScreenshotAt, if set, makes NavigateAndPrepare take a screenshot the
+	// moment the page reaches a milestone, which MilestoneScreenshot
+	// returns.
+	ScreenshotAt *MilestoneShot
+	// OnlyLang, if set, limits the text GetTextsBySelector and Outline
+	// extract to the text nodes in this language, by the lang attributes of
+	// their elements, e.g. "de" for de, de-DE and de-CH.
+	OnlyLang string
+	// Googlebot presents the tab as Googlebot Smartphone: its user agent,
+	// and its From header unless Headers has one.
+	Googlebot bool
+	// Replay, if set, answers every request of the page with the response
+	// a HAR file recorded for it, and fails those it has none for, so the
+	// page renders offline as it was recorded.
+	Replay *har.Replay
+
+	// remote is the URL of the remote browser's debugging endpoint, if
+	// connected to one.
+	remote string
+	// noJS disables the page's scripts, for NoJSTab.
+	noJS bool
+
+	mu         sync.Mutex
+	bus        *events.Bus
+	redirects  []RedirectHop
+	cdp        *cdpTracer
+	watch      pageWatch
+	crashes    crashWatch
+	limits     limitWatch
+	replay     replayWatch
+	missing    missingFiles
+	milestones milestoneWatch
+
+	consentDismissed string
 }
 
 // InitializeChromedp creates a new browser session with timeout.
 // If remoteDebuggingPort is provided, connects to existing Chrome instance.
 // jsCode is optional JavaScript code to execute once after navigation and delay.
 func InitializeChromedp(target string, timeout int, delay int, remoteDebuggingPort string, jsCode string) (*Browser, error) {
+	return InitializeChromedpContext(context.Background(), target, timeout, delay, remoteDebuggingPort, jsCode)
+}
+
+// InitializeChromedpContext is like InitializeChromedp but derives the browser
+// session from parent, so cancelling parent shuts the whole session down.
+// A timeout of zero or less leaves the session without an overall deadline,
+// for callers that bound each operation through its context instead.
+// remoteDebuggingPort is host:port, or UnixSocketPrefix and the path of a
+// Unix socket relaying DevTools.
+// opts configure how Chrome is started; apart from WithCABundle,
+// WithAllowedHosts and WithIsolatedSession they cannot be combined with
+// remoteDebuggingPort.
+// When parent carries a tracer (see package tracing), every operation is
+// recorded as a span with the protocol commands it sent as children.
+func InitializeChromedpContext(parent context.Context, target string, timeout int, delay int, remoteDebuggingPort string, jsCode string, opts ...LaunchOption) (*Browser, error) {
 	slog.Debug("Initializing Chrome browser", "target", target, "timeout", timeout, "delay", delay, "remotePort", remoteDebuggingPort, "hasJSCode", jsCode != "")
 
+	launch := newLaunchConfig(opts)
+	if remoteDebuggingPort != "" && (launch.proxy != "" || launch.untrusted || launch.userDataDir != "") {
+		return nil, fmt.Errorf("launch options such as a proxy cannot be applied to a remote browser; start Chrome with them instead")
+	}
+	if launch.untrusted && launch.proxy != "" {
+		return nil, fmt.Errorf("an untrusted browser sends its traffic through an allowlist proxy of its own and cannot use another proxy")
+	}
+	if remoteDebuggingPort != "" && len(launch.caCerts) > 0 {
+		slog.Warn("The remote browser must trust the CA bundle itself; it only applies to the connection check")
+	}
+	if remoteDebuggingPort != "" && launch.hosts != nil {
+		slog.Warn("The remote browser's DNS is not restricted to the allowed hosts; only intercepted requests are checked")
+	}
+
 	var allocCtx context.Context
 	var cancelAlloc context.CancelFunc
+	var cdp *cdpTracer
+	if tracing.Enabled(parent) {
+		cdp = newCDPTracer()
+	}
 
 	if remoteDebuggingPort != "" {
-		// Connect to existing Chrome instance
-		remoteURL := remoteDebuggingPort
-		if !strings.HasPrefix(remoteURL, "http://") && !strings.HasPrefix(remoteURL, "https://") {
-			remoteURL = "http://" + remoteURL
-		}
+		// The browser's debugging endpoint, if reachable over TCP
+		var remoteURL string
+		if socket, ok := strings.CutPrefix(remoteDebuggingPort, UnixSocketPrefix); ok {
+			wsURL, release, err := unixSocketBrowser(parent, socket)
+			if err != nil {
+				return nil, err
+			}
+			slog.Debug("Connecting to DevTools over Unix socket", "socket", socket)
+			allocCtx, cancelAlloc = chromedp.NewRemoteAllocator(parent, wsURL, chromedp.NoModifyURL)
+			context.AfterFunc(allocCtx, release)
+		} else {
+			// Connect to existing Chrome instance
+			remoteURL = remoteDebuggingPort
+			if !strings.HasPrefix(remoteURL, "http://") && !strings.HasPrefix(remoteURL, "https://") {
+				remoteURL = "http://" + remoteURL
+			}
 
-		// Validate format: should contain host:port
-		if !strings.Contains(remoteDebuggingPort, ":") {
-			return nil, fmt.Errorf("invalid remote debugging port format: %s (expected format: localhost:9222)", remoteDebuggingPort)
-		}
+			// Validate format: should contain host:port
+			if !strings.Contains(remoteDebuggingPort, ":") {
+				return nil, fmt.Errorf("invalid remote debugging port format: %s (expected format: localhost:9222)", remoteDebuggingPort)
+			}
 
-		// Additional validation for common mistakes
-		parts := strings.Split(remoteDebuggingPort, ":")
-		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
-			return nil, fmt.Errorf("invalid remote debugging port format: %s (expected format: host:port)", remoteDebuggingPort)
-		}
+			// Additional validation for common mistakes
+			parts := strings.Split(remoteDebuggingPort, ":")
+			if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+				return nil, fmt.Errorf("invalid remote debugging port format: %s (expected format: host:port)", remoteDebuggingPort)
+			}
 
-		// Test connection before proceeding
-		testURL := remoteURL + "/json/version"
-		slog.Debug("Testing connection to remote Chrome instance", "testURL", testURL)
+			// Test connection before proceeding
+			testURL := remoteURL + "/json/version"
+			slog.Debug("Testing connection to remote Chrome instance", "testURL", testURL)
 
-		client := &http.Client{Timeout: 3 * time.Second}
-		resp, err := client.Get(testURL)
-		if err != nil {
-			return nil, fmt.Errorf("failed to connect to remote debugging port %s: %w (ensure Chrome is running with --remote-debugging-port=%s)", remoteDebuggingPort, err, strings.Split(remoteDebuggingPort, ":")[1])
-		}
-		if err := resp.Body.Close(); err != nil {
-			slog.Warn("failed to close response body", "error", err)
-		}
+			client := launch.httpClient(3 * time.Second)
+			req, err := http.NewRequestWithContext(parent, http.MethodGet, testURL, nil)
+			if err != nil {
+				return nil, fmt.Errorf("invalid remote debugging URL %s: %w", testURL, err)
+			}
+			resp, err := client.Do(req)
+			if err != nil {
+				return nil, &RemoteError{Address: remoteDebuggingPort, Err: fmt.Errorf("failed to connect to remote debugging port %s: %w (ensure Chrome is running with --remote-debugging-port=%s)", remoteDebuggingPort, err, strings.Split(remoteDebuggingPort, ":")[1])}
+			}
+			if err := resp.Body.Close(); err != nil {
+				slog.Warn("failed to close response body", "error", err)
+			}
 
-		if resp.StatusCode != http.StatusOK {
-			return nil, fmt.Errorf("remote debugging endpoint returned status %d at %s (ensure Chrome is running with remote debugging enabled)", resp.StatusCode, testURL)
-		}
+			if resp.StatusCode != http.StatusOK {
+				return nil, &RemoteError{Address: remoteDebuggingPort, Err: fmt.Errorf("remote debugging endpoint returned status %d at %s (ensure Chrome is running with remote debugging enabled)", resp.StatusCode, testURL)}
+			}
 
-		slog.Debug("Successfully connected to remote Chrome instance", "url", remoteURL)
-		// Create allocator context for remote debugging
-		allocCtx, cancelAlloc = chromedp.NewRemoteAllocator(context.Background(), remoteURL)
+			slog.Debug("Successfully connected to remote Chrome instance", "url", remoteURL)
+			// Create allocator context for remote debugging
+			allocCtx, cancelAlloc = chromedp.NewRemoteAllocator(parent, remoteURL)
+		}
 
 		// Create a new task context from the allocator context (not a timeout context)
-		taskCtx, cancelTask := chromedp.NewContext(allocCtx)
+		taskCtx, cancelTask := chromedp.NewContext(allocCtx, append(cdp.contextOptions(), launch.contextOptions()...)...)
 
 		// Apply timeout to the task context
-		ctx, cancelCtx := context.WithTimeout(taskCtx, time.Duration(timeout)*time.Second)
+		ctx, cancelCtx := withTimeout(taskCtx, timeout)
 
 		slog.Debug("Remote Chrome context created successfully")
 
-		return &Browser{
+		b := &Browser{
 			Ctx:       ctx,
 			Cancel:    func() { cancelCtx(); cancelTask(); cancelAlloc() },
 			TargetURL: target,
 			Delay:     delay,
 			JSCode:    jsCode,
-		}, nil
+			cdp:       cdp,
+			remote:    remoteURL,
+		}
+		b.listen()
+		return b, nil
 	} else {
 		// Create new headless Chrome instance
 		slog.Debug("Creating new headless Chrome instance")
-		allocCtx, cancelAlloc = chromedp.NewContext(context.Background())
+		execCtx, cancelExec := parent, context.CancelFunc(func() {})
+		if !launch.empty() {
+			var err error
+			if execCtx, cancelExec, err = launch.allocator(parent); err != nil {
+				return nil, err
+			}
+		}
+		allocCtx, cancelAlloc = chromedp.NewContext(execCtx, cdp.contextOptions()...)
 
-		ctx, cancelCtx := context.WithTimeout(allocCtx, time.Duration(timeout)*time.Second)
+		ctx, cancelCtx := withTimeout(allocCtx, timeout)
 
 		slog.Debug("Chrome context created successfully")
 
-		return &Browser{
+		b := &Browser{
 			Ctx:       ctx,
-			Cancel:    func() { cancelCtx(); cancelAlloc() },
+			Cancel:    func() { cancelCtx(); cancelAlloc(); cancelExec() },
 			TargetURL: target,
 			Delay:     delay,
 			JSCode:    jsCode,
-		}, nil
+			cdp:       cdp,
+		}
+		b.listen()
+		return b, nil
+	}
+}
+
+// withTimeout applies a timeout in seconds to ctx; zero or less means none.
+func withTimeout(ctx context.Context, timeout int) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return context.WithCancel(ctx)
 	}
+	return context.WithTimeout(ctx, time.Duration(timeout)*time.Second)
+}
+
+// NewTab opens a new tab in the same browser, sharing its cookies and
+// session unless IsolateTabs or a tab proxy is set. The tab has its own lock, so it can be driven from another
+// goroutine in parallel with b. Call Cancel on the returned Browser to close
+// the tab; cancelling b closes all of its tabs.
+func (b *Browser) NewTab(ctx context.Context, opts ...TabOption) (*Browser, error) {
+	slog.Debug("Opening new tab", "target", b.TargetURL)
+
+	// A tab only joins b's browser if that browser is already running;
+	// otherwise chromedp would start a separate one for it.
+	if c := chromedp.FromContext(b.Ctx); c == nil || c.Browser == nil {
+		if err := b.run(ctx); err != nil {
+			slog.Error("Failed to start browser", "error", err)
+			return nil, fmt.Errorf("failed to start browser: %w", err)
+		}
+	}
+
+	tabCtx, cancelTab := chromedp.NewContext(b.Ctx, b.contextOptions(opts)...)
+	tab := &Browser{
+		Ctx:          tabCtx,
+		Cancel:       cancelTab,
+		TargetURL:    b.TargetURL,
+		Delay:        b.Delay,
+		JSCode:       b.JSCode,
+		Steps:        b.Steps,
+		Headers:      b.Headers,
+		Cookies:      b.Cookies,
+		Storage:      b.Storage,
+		BasicAuth:    b.BasicAuth,
+		ProxyAuth:    b.ProxyAuth,
+		Emulation:    b.Emulation,
+		Locale:       b.Locale,
+		Filter:       b.Filter,
+		AllowedHosts: b.AllowedHosts,
+		Untrusted:    b.Untrusted,
+		MaxRedirects: b.MaxRedirects,
+		Permissions:  b.Permissions,
+		Clipboard:    b.Clipboard,
+		MaxBytes:     b.MaxBytes,
+		MaxRequests:  b.MaxRequests,
+		ScreenshotAt: b.ScreenshotAt,
+
+		FingerprintProfile: b.FingerprintProfile,
+		Googlebot:          b.Googlebot,
+		Replay:             b.Replay,
+
+		cdp:    b.cdp,
+		remote: b.remote,
+	}
+	tab.listen()
+
+	// Running an empty action list allocates the tab up front, so errors
+	// surface here rather than on the first real operation.
+	if err := tab.run(ctx); err != nil {
+		cancelTab()
+		slog.Error("Failed to open new tab", "error", err)
+		return nil, fmt.Errorf("failed to open new tab: %w", err)
+	}
+	return tab, nil
+}
+
+// run executes actions on the session context for the lifetime of ctx,
+// holding the Browser lock so concurrent callers never interleave.
+// When ctx is cancelled or its deadline passes, the actions are aborted and
+// ctx.Err() is returned; the browser session itself stays usable.
+func (b *Browser) run(ctx context.Context, actions ...chromedp.Action) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if !tracing.Enabled(ctx) {
+		return b.runUnlocked(ctx, actions...)
+	}
+
+	ctx, span := tracing.Start(ctx, operationName(), tracing.String("url.full", b.TargetURL))
+	defer span.End()
+	if b.cdp != nil {
+		// The tab's session only exists once it has been used
+		if c := chromedp.FromContext(b.Ctx); c != nil && c.Target == nil {
+			if err := b.runUnlocked(ctx); err != nil {
+				span.RecordError(err)
+				return err
+			}
+		}
+		if c := chromedp.FromContext(b.Ctx); c != nil && c.Target != nil {
+			b.cdp.begin(c.Target.SessionID, span)
+			defer b.cdp.end(c.Target.SessionID, span)
+		}
+	}
+	err := b.runUnlocked(ctx, actions...)
+	span.RecordError(err)
+	return err
+}
+
+// runUnlocked is run without taking the Browser lock. It is only for
+// actions that must proceed while another operation holds the lock, such
+// as dismissing a dialog that blocks an in-flight navigation.
+func (b *Browser) runUnlocked(ctx context.Context, actions ...chromedp.Action) error {
+	opCtx, cancel := context.WithCancel(b.Ctx)
+	defer cancel()
+	stop := context.AfterFunc(ctx, cancel)
+	defer stop()
+	// A crashed renderer never answers: give up on it right away
+	stopCrash := context.AfterFunc(b.crashes.aborted, cancel)
+	defer stopCrash()
+	// Neither does waiting for a page whose load was aborted
+	stopLimit := context.AfterFunc(b.limits.done(), cancel)
+	defer stopLimit()
+
+	if err := chromedp.Run(opCtx, actions...); err != nil {
+		if crash := b.Crashed(); crash != nil {
+			return &CrashError{Crash: *crash, Err: err}
+		}
+		if limit := b.limits.get(); limit != nil {
+			return &LimitError{Limit: *limit}
+		}
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+		return launchError(err)
+	}
+	return nil
 }
 
 // executeJSAction returns a chromedp action that executes the browser's JS code.
@@ -150,140 +460,241 @@ func (b *Browser) executeJSAction() chromedp.Action {
 	})
 }
 
-// NavigateAndPrepare navigates to the target URL, applies delay, and executes custom JS.
+// NavigateAndPrepare sets up Emulation, Locale, FingerprintProfile, Headers, Cookies, BasicAuth and Filter, navigates to the
+// target URL, taking the ScreenshotAt screenshot on the way, follows up to MaxRedirects client-side redirects, waits until the page is Ready,
+// applies delay, dismisses the DismissConsent banner, executes custom JS and performs the interaction Steps.
+// A page stuck in a redirect loop, thrashing its location or, when it
+// times out, loading forever fails with a *PathologyError, and a step or
+// the Ready strategy waiting for an element in vain with a *SelectorError
+// wrapped in the *NavigationError of other failures.
 // This should be called once before performing any actions on the page.
-func (b *Browser) NavigateAndPrepare() error {
+func (b *Browser) NavigateAndPrepare(ctx context.Context) error {
 	slog.Debug("Navigating to target URL", "url", b.TargetURL)
+	b.watch.reset(b.TargetURL)
+	b.limits.reset(b.Ctx, b.MaxBytes, b.MaxRequests)
+	b.replay.reset(b.Replay)
+	b.missing.reset()
+	b.milestones.reset(b.ScreenshotAt)
+
+	var failed Step
+	var followRedirects chromedp.Action = chromedp.Tasks{}
+	if b.MaxRedirects > 0 {
+		stream := b.bus.Subscribe()
+		defer b.bus.Unsubscribe(stream)
+		followRedirects = b.followRedirectsAction(stream)
+	}
 
-	err := chromedp.Run(b.Ctx,
-		chromedp.Navigate(b.TargetURL),
+	err := b.run(ctx,
+		network.Enable(),
+		b.noJSAction(),
+		b.Emulation.action(),
+		b.localeAction(),
+		b.FingerprintProfile.action(),
+		b.googlebotAction(),
+		b.permissionsAction(),
+		b.setupNetworkAction(),
+		b.denyDownloadsAction(),
+		b.milestoneAction(),
+		b.storageAction(b.navigateAction()),
+		followRedirects,
+		b.readinessAction(),
 		chromedp.ActionFunc(func(ctx context.Context) error {
 			slog.Debug("Applying rendering delay", "delay", b.Delay, "url", b.TargetURL)
 			return nil
 		}),
 		chromedp.Sleep(time.Duration(b.Delay)*time.Second),
+		b.dismissConsentAction(),
 		b.executeJSAction(),
+		stepsAction(b.Steps, &failed),
+		b.maskAction(),
 	)
+	err = selectorError(err, failed.Selector, b.TargetURL)
+	var crash *CrashError
+	if errors.As(err, &crash) {
+		slog.Error("Tab crashed while preparing page", "url", b.TargetURL, "crash", crash.Kind, "status", crash.Status)
+		return err
+	}
+	var limit *LimitError
+	if errors.As(err, &limit) {
+		slog.Error("Page exceeded its cap while loading", "url", b.TargetURL, "cap", limit.Kind, "max", limit.Max, "used", limit.Used)
+		return err
+	}
+	if p := b.watch.diagnose(err, time.Now()); p != nil {
+		slog.Error("Page never settled", "url", b.TargetURL, "pathology", p.Kind, "detail", p.Detail, "error", err)
+		return &PathologyError{Pathology: *p, Err: err}
+	}
 	if err != nil {
 		slog.Error("Failed to navigate and prepare page", "url", b.TargetURL, "error", err)
-		return err
+		return &NavigationError{URL: b.TargetURL, Err: err}
 	}
 
 	slog.Debug("Navigation and preparation completed successfully")
 	return nil
 }
 
-// SetupConsoleLogListeners sets up listeners for console logs, exceptions, and dialogs.
+// SetupConsoleLogListeners logs console messages and exceptions from the
+// page's event stream and automatically accepts JavaScript dialogs.
 // This should be called before NavigateAndPrepare if console log capture is needed.
-func (b *Browser) SetupConsoleLogListeners() {
+// Events stop being reported once ctx is done.
+func (b *Browser) SetupConsoleLogListeners(ctx context.Context) {
 	slog.Debug("Setting up console log listeners")
 
-	chromedp.ListenTarget(b.Ctx, func(ev interface{}) {
-		switch ev := ev.(type) {
-		case *runtime.EventConsoleAPICalled:
-			// Combine all arguments into a single message
-			var values []string
-			for _, arg := range ev.Args {
-				// arg.Value is JSON-encoded, trim quotes for strings
-				val := string(arg.Value)
-				if len(val) >= 2 && val[0] == '"' && val[len(val)-1] == '"' {
-					val = val[1 : len(val)-1]
-				}
-				values = append(values, val)
+	stream := b.Events()
+	go func() {
+		for ev := range stream {
+			if ctx.Err() != nil {
+				continue
 			}
-			slog.Info("Console message captured",
-				"type", ev.Type,
-				"value", strings.Join(values, " "))
-		case *runtime.EventExceptionThrown:
-			slog.Error("JavaScript exception captured",
-				"text", ev.ExceptionDetails.Text)
-			if ev.ExceptionDetails.StackTrace != nil {
-				for _, frame := range ev.ExceptionDetails.StackTrace.CallFrames {
+			switch ev := ev.(type) {
+			case events.ConsoleMessage:
+				slog.Info("Console message captured",
+					"type", ev.Type,
+					"value", ev.Text)
+			case events.Exception:
+				slog.Error("JavaScript exception captured",
+					"text", ev.Text)
+				for _, frame := range ev.StackTrace {
 					slog.Debug("Stack trace frame",
-						"function", frame.FunctionName,
+						"function", frame.Function,
 						"url", frame.URL,
-						"line", frame.LineNumber,
-						"column", frame.ColumnNumber)
+						"line", frame.Line,
+						"column", frame.Column)
 				}
-			}
-		case *page.EventJavascriptDialogOpening:
-			slog.Debug("JavaScript dialog detected, handling automatically")
-			go func() {
-				if err := chromedp.Run(b.Ctx, page.HandleJavaScriptDialog(true)); err != nil {
+			case events.Dialog:
+				slog.Debug("JavaScript dialog detected, handling automatically")
+				// The dialog blocks whichever operation holds the lock, so
+				// it has to be dismissed without waiting for it.
+				if err := b.runUnlocked(ctx, page.HandleJavaScriptDialog(true)); err != nil {
 					slog.Error("Failed to handle JavaScript dialog", "error", err)
 				}
-			}()
+			}
 		}
-	})
+	}()
 
 	slog.Debug("Console log listeners set up successfully")
 }
 
 // CaptureConsoleLogs is deprecated - use SetupConsoleLogListeners instead.
 // Kept for backwards compatibility but now just calls SetupConsoleLogListeners.
-func (b *Browser) CaptureConsoleLogs() error {
-	b.SetupConsoleLogListeners()
+func (b *Browser) CaptureConsoleLogs(ctx context.Context) error {
+	b.SetupConsoleLogListeners(ctx)
 	return nil
 }
 
+// CurrentURL returns the URL of the page, which differs from TargetURL
+// after redirects or steps that navigated.
+// Assumes NavigateAndPrepare has already been called.
+func (b *Browser) CurrentURL(ctx context.Context) (string, error) {
+	var u string
+	if err := b.run(ctx, chromedp.Location(&u)); err != nil {
+		slog.Error("Failed to get current URL", "error", err)
+		return "", fmt.Errorf("failed to get current URL: %w", err)
+	}
+	return u, nil
+}
+
 // GetBodyText extracts all visible text from the <body>.
-func (b *Browser) GetBodyText() (string, error) {
-	return b.GetTextBySelector("body")
+func (b *Browser) GetBodyText(ctx context.Context) (string, error) {
+	return b.GetTextBySelector(ctx, "body")
+}
+
+// GetTextBySelector extracts text from elements matching the given CSS selector,
+// one element per line. Assumes NavigateAndPrepare has already been called.
+func (b *Browser) GetTextBySelector(ctx context.Context, selector string) (string, error) {
+	texts, err := b.GetTextsBySelector(ctx, selector)
+	if err != nil {
+		return "", err
+	}
+	return strings.Join(texts, "\n"), nil
 }
 
-// GetTextBySelector extracts text from elements matching the given CSS selector.
+// GetTextsBySelector returns the trimmed, non-empty text of each element
+// matching the given CSS selector, only of OnlyLang if set.
 // Assumes NavigateAndPrepare has already been called.
-func (b *Browser) GetTextBySelector(selector string) (string, error) {
+func (b *Browser) GetTextsBySelector(ctx context.Context, selector string) ([]string, error) {
 	slog.Debug("Extracting text by CSS selector", "selector", selector)
 
+	encoded, err := json.Marshal(selector)
+	if err != nil {
+		return nil, err
+	}
+
+	extract := "el => el.innerText.trim()"
+	if b.OnlyLang != "" {
+		lang, err := json.Marshal(b.OnlyLang)
+		if err != nil {
+			return nil, err
+		}
+		extract = fmt.Sprintf("((inLang) => (el) => (%s)(el, inLang))((%s)(%s))", langTextScript, langMatchScript, lang)
+	}
+
 	var texts []string
-	err := chromedp.Run(b.Ctx,
+	err = b.run(ctx,
 		chromedp.Evaluate(`
-			Array.from(document.querySelectorAll('`+selector+`')).map(el => el.innerText.trim()).filter(text => text.length > 0)
+			Array.from(document.querySelectorAll(`+string(encoded)+`)).map(`+extract+`).filter(text => text.length > 0)
 		`, &texts),
 	)
 	if err != nil {
 		slog.Error("Failed to extract text by selector", "selector", selector, "error", err)
-		return "", err
+		return nil, err
 	}
 
-	result := ""
-	for i, text := range texts {
-		if i > 0 {
-			result += "\n"
-		}
-		result += text
-	}
+	slog.Debug("Successfully extracted text", "selector", selector, "elementsFound", len(texts))
+	return texts, nil
+}
 
-	slog.Debug("Successfully extracted text", "selector", selector, "elementsFound", len(texts), "totalTextLength", len(result))
-	return result, nil
+// TakeScreenshot captures a full page JPEG screenshot of the current page.
+// Assumes NavigateAndPrepare has already been called.
+func (b *Browser) TakeScreenshot(ctx context.Context) ([]byte, error) {
+	return b.ScreenshotFullPage(ctx, ScreenshotOptions{Format: JPEG, Quality: 90})
 }
 
-// TakeScreenshot captures a screenshot of the current page.
+// TakeThumbnail captures the visible viewport as a JPEG scaled down by
+// scale (e.g. 0.25 for a quarter of the viewport size).
 // Assumes NavigateAndPrepare has already been called.
-func (b *Browser) TakeScreenshot() ([]byte, error) {
-	slog.Debug("Taking screenshot")
+func (b *Browser) TakeThumbnail(ctx context.Context, scale float64) ([]byte, error) {
+	slog.Debug("Taking thumbnail", "scale", scale)
 
 	var buf []byte
-	err := chromedp.Run(b.Ctx,
-		chromedp.FullScreenshot(&buf, 90),
-	)
+	err := b.run(ctx, chromedp.ActionFunc(func(ctx context.Context) error {
+		var size struct {
+			Width  float64 `json:"width"`
+			Height float64 `json:"height"`
+		}
+		if err := chromedp.Evaluate(`({width: window.innerWidth, height: window.innerHeight})`, &size).Do(ctx); err != nil {
+			return err
+		}
+		var err error
+		buf, err = page.CaptureScreenshot().
+			WithFormat(page.CaptureScreenshotFormatJpeg).
+			WithQuality(70).
+			WithClip(&page.Viewport{Width: size.Width, Height: size.Height, Scale: scale}).
+			Do(ctx)
+		return err
+	}))
 	if err != nil {
-		slog.Error("Failed to capture screenshot", "error", err)
+		slog.Error("Failed to capture thumbnail", "error", err)
 		return nil, err
 	}
 
-	slog.Debug("Screenshot captured successfully", "size", len(buf))
+	slog.Debug("Thumbnail captured successfully", "size", len(buf))
 	return buf, nil
 }
 
+// ScreenshotElement captures a PNG screenshot of the first element matching
+// the given CSS selector, waiting for it to become visible.
+// Assumes NavigateAndPrepare has already been called.
+func (b *Browser) ScreenshotElement(ctx context.Context, selector string) ([]byte, error) {
+	return b.ScreenshotElementAs(ctx, selector, ScreenshotOptions{Format: PNG})
+}
+
 // PrintToPDF generates a PDF of the current page.
 // Assumes NavigateAndPrepare has already been called.
-func (b *Browser) PrintToPDF() ([]byte, error) {
+func (b *Browser) PrintToPDF(ctx context.Context) ([]byte, error) {
 	slog.Debug("Generating PDF")
 
 	var pdfBuf []byte
-	err := chromedp.Run(b.Ctx,
+	err := b.run(ctx,
 		chromedp.ActionFunc(func(ctx context.Context) error {
 			var err error
 			pdfBuf, _, err = page.PrintToPDF().WithPrintBackground(true).Do(ctx)
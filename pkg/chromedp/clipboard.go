@@ -0,0 +1,45 @@
+package chromedphelper
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+
+	"github.com/chromedp/cdproto/runtime"
+	"github.com/chromedp/chromedp"
+)
+
+// ReadClipboard returns the text on the clipboard, such as what a "copy
+// link" button put there during the steps. Set Clipboard before
+// NavigateAndPrepare so the page may write it.
+// Assumes NavigateAndPrepare has already been called.
+func (b *Browser) ReadClipboard(ctx context.Context) (string, error) {
+	slog.Debug("Reading clipboard")
+
+	var text string
+	err := b.run(ctx, chromedp.ActionFunc(func(ctx context.Context) error {
+		res, exception, err := runtime.Evaluate(`navigator.clipboard.readText()`).
+			WithAwaitPromise(true).
+			WithReturnByValue(true).
+			Do(ctx)
+		if err != nil {
+			return err
+		}
+		if exception != nil {
+			msg := exception.Text
+			if exception.Exception != nil && exception.Exception.Description != "" {
+				msg = exception.Exception.Description
+			}
+			return fmt.Errorf("clipboard read rejected: %s", msg)
+		}
+		return json.Unmarshal(res.Value, &text)
+	}))
+	if err != nil {
+		slog.Error("Failed to read clipboard", "error", err)
+		return "", err
+	}
+
+	slog.Debug("Clipboard read successfully", "length", len(text))
+	return text, nil
+}
@@ -0,0 +1,201 @@
+package chromedphelper
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os/exec"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pesarkhobeee/that-cli-web-toolbox/pkg/events"
+)
+
+// requireChrome skips the test unless one of the Chrome executables
+// chromedp looks for by default is on the PATH.
+func requireChrome(t *testing.T) {
+	t.Helper()
+	for _, name := range []string{"headless_shell", "headless-shell", "chromium", "chromium-browser",
+		"google-chrome", "google-chrome-stable", "google-chrome-beta", "google-chrome-unstable"} {
+		if _, err := exec.LookPath(name); err == nil {
+			return
+		}
+	}
+	t.Skip("Chrome not found on the PATH")
+}
+
+// pages serves /N as a page whose body is "page N" and that logs "log N"
+// to the console, and /dialog as a page opening an alert while it loads.
+func pages(t *testing.T) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		if r.URL.Path == "/dialog" {
+			fmt.Fprint(w, `<html><body><script>alert("blocking")</script><p>after the dialog</p></body></html>`)
+			return
+		}
+		n := strings.TrimPrefix(r.URL.Path, "/")
+		fmt.Fprintf(w, `<html><body><p>page %s</p><script>console.log("log %s")</script></body></html>`, n, n)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// startBrowser starts a browser for the duration of the test.
+func startBrowser(t *testing.T) (context.Context, *Browser) {
+	t.Helper()
+	requireChrome(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	t.Cleanup(cancel)
+	root, err := InitializeChromedpContext(ctx, "about:blank", 0, 0, "", "")
+	if err != nil {
+		t.Fatalf("failed to initialize browser: %v", err)
+	}
+	t.Cleanup(root.Cancel)
+	return ctx, root
+}
+
+func TestParallelTabs(t *testing.T) {
+	ctx, root := startBrowser(t)
+	srv := pages(t)
+
+	const tabs = 6
+	var wg sync.WaitGroup
+	for i := range tabs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			tab, err := root.NewTab(ctx)
+			if err != nil {
+				t.Errorf("tab %d: %v", i, err)
+				return
+			}
+			defer tab.Cancel()
+			tab.TargetURL = fmt.Sprintf("%s/%d", srv.URL, i)
+			if err := tab.NavigateAndPrepare(ctx); err != nil {
+				t.Errorf("tab %d: %v", i, err)
+				return
+			}
+
+			// Operations on one tab from several goroutines take turns
+			var ops sync.WaitGroup
+			for range 4 {
+				ops.Add(2)
+				go func() {
+					defer ops.Done()
+					text, err := tab.GetBodyText(ctx)
+					if err != nil {
+						t.Errorf("tab %d: %v", i, err)
+					} else if want := fmt.Sprintf("page %d", i); text != want {
+						t.Errorf("tab %d shows %q, want %q", i, text, want)
+					}
+				}()
+				go func() {
+					defer ops.Done()
+					if u, err := tab.CurrentURL(ctx); err != nil {
+						t.Errorf("tab %d: %v", i, err)
+					} else if u != tab.TargetURL {
+						t.Errorf("tab %d is at %s, want %s", i, u, tab.TargetURL)
+					}
+				}()
+			}
+			ops.Wait()
+		}()
+	}
+	wg.Wait()
+}
+
+func TestParallelTabEvents(t *testing.T) {
+	ctx, root := startBrowser(t)
+	srv := pages(t)
+
+	const tabs = 4
+	var wg sync.WaitGroup
+	for i := range tabs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			tab, err := root.NewTab(ctx)
+			if err != nil {
+				t.Errorf("tab %d: %v", i, err)
+				return
+			}
+			defer tab.Cancel()
+
+			// Two subscribers of the tab receive the same console messages
+			var logs [2][]string
+			var consumers sync.WaitGroup
+			streams := [2]<-chan events.Event{tab.Events(), tab.Events()}
+			for j, ch := range streams {
+				consumers.Add(1)
+				go func() {
+					defer consumers.Done()
+					for ev := range ch {
+						if m, ok := ev.(events.ConsoleMessage); ok {
+							logs[j] = append(logs[j], m.Text)
+						}
+					}
+				}()
+			}
+
+			tab.TargetURL = fmt.Sprintf("%s/%d", srv.URL, i)
+			if err := tab.NavigateAndPrepare(ctx); err != nil {
+				t.Errorf("tab %d: %v", i, err)
+			}
+			for _, ch := range streams {
+				tab.StopEvents(ch)
+			}
+			consumers.Wait()
+
+			want := fmt.Sprintf("log %d", i)
+			for j, got := range logs {
+				// Messages of the other tabs must not leak into this one
+				if len(got) != 1 || got[0] != want {
+					t.Errorf("subscriber %d of tab %d received %q, want [%q]", j, i, got, want)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestDialogsInParallelTabs(t *testing.T) {
+	ctx, root := startBrowser(t)
+	srv := pages(t)
+
+	const tabs = 4
+	var wg sync.WaitGroup
+	for i := range tabs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			tab, err := root.NewTab(ctx)
+			if err != nil {
+				t.Errorf("tab %d: %v", i, err)
+				return
+			}
+			defer tab.Cancel()
+
+			// The alert blocks the navigation, which holds the tab's lock:
+			// the listener dismisses it through runUnlocked
+			loadCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+			defer cancel()
+			tab.SetupConsoleLogListeners(loadCtx)
+			tab.TargetURL = srv.URL + "/dialog"
+			if err := tab.NavigateAndPrepare(loadCtx); err != nil {
+				t.Errorf("tab %d: the dialog was not dismissed: %v", i, err)
+				return
+			}
+			text, err := tab.GetBodyText(loadCtx)
+			if err != nil {
+				t.Errorf("tab %d: %v", i, err)
+			} else if text != "after the dialog" {
+				t.Errorf("tab %d shows %q, want %q", i, text, "after the dialog")
+			}
+		}()
+	}
+	wg.Wait()
+}
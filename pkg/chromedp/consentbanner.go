@@ -0,0 +1,84 @@
+package chromedphelper
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/chromedp/chromedp"
+)
+
+// ConsentButtons are the "accept" buttons of widespread consent management
+// platforms' cookie banners, for DismissConsent.
+var ConsentButtons = []string{
+	"#onetrust-accept-btn-handler",                           // OneTrust
+	"#CybotCookiebotDialogBodyLevelButtonLevelOptinAllowAll", // Cookiebot
+	"#CybotCookiebotDialogBodyButtonAccept",                  // Cookiebot
+	"#didomi-notice-agree-button",                            // Didomi
+	"#truste-consent-button",                                 // TrustArc
+	".qc-cmp2-summary-buttons button[mode=primary]",          // Quantcast
+	".osano-cm-accept-all",                                   // Osano
+	".cc-btn.cc-allow",                                       // Cookie Consent
+	".cky-btn-accept",                                        // CookieYes
+	".cmplz-btn.cmplz-accept",                                // Complianz
+	"#axeptio_btn_acceptAll",                                 // Axeptio
+	".cm-btn-accept-all",                                     // Klaro
+}
+
+// consentSettle is how long NavigateAndPrepare waits after dismissing a
+// consent banner, for it to go away and the page to react.
+const consentSettle = 500 * time.Millisecond
+
+// dismissConsentScript clicks the first visible element matching one of
+// the selectors and returns that selector, or "" when none is visible.
+const dismissConsentScript = `(selectors) => {
+	for (const selector of selectors) {
+		let el;
+		try {
+			el = document.querySelector(selector);
+		} catch (e) {
+			continue;
+		}
+		if (!el) continue;
+		const rect = el.getBoundingClientRect();
+		const style = getComputedStyle(el);
+		if (rect.width === 0 || rect.height === 0 || style.visibility === 'hidden') continue;
+		el.click();
+		return selector;
+	}
+	return '';
+}`
+
+// dismissConsentAction clicks the first visible button of b.DismissConsent.
+func (b *Browser) dismissConsentAction() chromedp.Action {
+	if len(b.DismissConsent) == 0 {
+		return chromedp.Tasks{}
+	}
+	return chromedp.ActionFunc(func(ctx context.Context) error {
+		arg, err := json.Marshal(b.DismissConsent)
+		if err != nil {
+			return err
+		}
+		var clicked string
+		if err := chromedp.Evaluate(fmt.Sprintf("(%s)(%s)", dismissConsentScript, arg), &clicked).Do(ctx); err != nil {
+			return fmt.Errorf("failed to dismiss consent banner: %w", err)
+		}
+		b.consentDismissed = clicked
+		if clicked == "" {
+			slog.Debug("No consent banner to dismiss", "url", b.TargetURL)
+			return nil
+		}
+		slog.Info("Consent banner dismissed", "url", b.TargetURL, "selector", clicked)
+		return chromedp.Sleep(consentSettle).Do(ctx)
+	})
+}
+
+// ConsentDismissed returns the selector of the consent banner button
+// NavigateAndPrepare clicked for DismissConsent, or "" if it clicked none.
+func (b *Browser) ConsentDismissed() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.consentDismissed
+}
@@ -0,0 +1,235 @@
+package chromedphelper
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"github.com/chromedp/chromedp"
+)
+
+// Kinds of content in a ContentMap grid, by the character marking a cell.
+const (
+	ContentWhitespace = '.'
+	ContentText       = 't'
+	ContentImage      = 'i'
+	ContentMedia      = 'm'
+	ContentAd         = 'a'
+)
+
+// Bounds of the content map grid, so long pages stay cheap to measure and
+// render: cells are made larger rather than exceeding these.
+const (
+	contentMapColumns   = 160
+	contentMapRows      = 2000
+	contentMapMinCell   = 10
+	contentMapMaxHeight = 50000
+)
+
+// ContentMap is where text, images, media, ads and whitespace fall on a
+// page, as a grid of cells and as shares per screen.
+type ContentMap struct {
+	// Width and Height are those of the page in CSS pixels; Height is
+	// capped for endless pages, see Truncated.
+	Width     float64 `json:"width"`
+	Height    float64 `json:"height"`
+	Truncated bool    `json:"truncated,omitempty"`
+	// Screen is the viewport height each of Screens covers.
+	Screen float64 `json:"screen"`
+	// Cell is the side of a grid cell in CSS pixels.
+	Cell float64 `json:"cell"`
+	// Grid has a row of cells per Cell pixels of height, each cell being
+	// the Content character of the kind of content covering it, ads taking
+	// precedence over media, media over images and images over text.
+	Grid []string `json:"grid"`
+	// Screens are the shares of content per screenful, from the top.
+	Screens []ContentShares `json:"screens"`
+	// Total are the shares of the whole page.
+	Total ContentShares `json:"total"`
+	// Ads are the elements counted as ads.
+	Ads []AdElement `json:"ads,omitempty"`
+}
+
+// ContentShares are the shares of an area, from 0 to 1, covered by each
+// kind of content.
+type ContentShares struct {
+	Text       float64 `json:"text"`
+	Image      float64 `json:"image"`
+	Media      float64 `json:"media"`
+	Ad         float64 `json:"ad"`
+	Whitespace float64 `json:"whitespace"`
+}
+
+// AdElement is an element a ContentMap counts as an ad, and why.
+type AdElement struct {
+	Selector string `json:"selector"`
+	Tag      string `json:"tag"`
+	Src      string `json:"src,omitempty"`
+	// Reason is "ad network", "ad markup" or "ad size".
+	Reason string `json:"reason"`
+	Box    Box    `json:"box"`
+}
+
+// contentMapScript rasterizes the page's content into a grid of cells.
+// Text is measured by its line boxes, so the gaps between paragraphs and
+// short lines count as whitespace.
+const contentMapScript = `(columns, maxRows, minCell, maxHeight) => {
+	const selectorOf = ` + selectorOfScript + `;
+	const doc = document.documentElement;
+	const width = Math.max(doc.scrollWidth, window.innerWidth);
+	const fullHeight = Math.max(doc.scrollHeight, document.body ? document.body.scrollHeight : 0, window.innerHeight);
+	const height = Math.min(fullHeight, maxHeight);
+	const cell = Math.max(minCell, Math.ceil(width / columns), Math.ceil(height / maxRows));
+	const cols = Math.ceil(width / cell), rows = Math.ceil(height / cell);
+	const grid = new Uint8Array(cols * rows);
+	const marks = ['.', 't', 'i', 'm', 'a'];
+	const paint = (r, kind) => {
+		const x = r.left + window.scrollX, y = r.top + window.scrollY;
+		if (r.width <= 0 || r.height <= 0) return;
+		const x0 = Math.max(0, Math.floor(x / cell)), x1 = Math.min(cols - 1, Math.floor((x + r.width - 1) / cell));
+		const y0 = Math.max(0, Math.floor(y / cell)), y1 = Math.min(rows - 1, Math.floor((y + r.height - 1) / cell));
+		for (let row = y0; row <= y1; row++)
+			for (let col = x0; col <= x1; col++)
+				if (grid[row * cols + col] < kind) grid[row * cols + col] = kind;
+	};
+	const visible = (el) => {
+		const style = getComputedStyle(el);
+		return style.visibility !== 'hidden' && style.opacity !== '0' && style.display !== 'none';
+	};
+
+	const adNetwork = /doubleclick\.net|googlesyndication\.com|googleadservices\.com|adservice\.google|amazon-adsystem\.com|adnxs\.com|taboola\.com|outbrain\.com|criteo\.(com|net)|pubmatic\.com|rubiconproject\.com|openx\.net|moatads\.com|adform\.net|media\.net/i;
+	const adMarkup = /(^|[-_\s])(ad|ads|adv|advert|advertisement|adslot|ad-slot|adunit|ad-unit|sponsor|sponsored|dfp|gpt-ad)([-_\s]|$)/i;
+	const adSizes = ['300x250', '728x90', '160x600', '300x600', '320x50', '320x100', '970x90', '970x250', '336x280', '468x60', '250x250', '120x600'];
+	const ads = [];
+	const adOf = (el) => {
+		const src = el.src || '';
+		if (src && adNetwork.test(src)) return 'ad network';
+		if (el.matches('ins.adsbygoogle, [data-ad-slot], [data-ad-client], [id^="google_ads"], [id^="div-gpt-ad"]')) return 'ad markup';
+		const names = (el.id || '') + ' ' + (typeof el.className === 'string' ? el.className : '');
+		if (adMarkup.test(names)) return 'ad markup';
+		if (el.localName === 'iframe') {
+			const r = el.getBoundingClientRect();
+			if (adSizes.includes(Math.round(r.width) + 'x' + Math.round(r.height))) return 'ad size';
+		}
+		return '';
+	};
+
+	const walker = document.createTreeWalker(document.body || doc, NodeFilter.SHOW_ELEMENT);
+	for (let el = walker.currentNode; el; el = walker.nextNode()) {
+		if (!visible(el)) continue;
+		const tag = el.localName;
+		const r = el.getBoundingClientRect();
+		const reason = tag === 'html' || tag === 'body' ? '' : adOf(el);
+		if (reason && r.width > 0 && r.height > 0 && !ads.some((ad) => ad.el.contains(el))) {
+			ads.push({el, selector: selectorOf(el), tag, src: (el.src || '').slice(0, 200), reason,
+				box: {x: r.left + window.scrollX, y: r.top + window.scrollY, width: r.width, height: r.height}});
+			paint(r, 4);
+		} else if (tag === 'video' || tag === 'iframe' || tag === 'embed' || tag === 'object' || tag === 'audio') {
+			paint(r, 3);
+		} else if (tag === 'img' || tag === 'canvas' || tag === 'picture' || (tag === 'svg' && !el.parentElement.closest('svg')) ||
+				el.getAttribute('role') === 'img' || /url\(/.test(getComputedStyle(el).backgroundImage)) {
+			paint(r, 2);
+		}
+	}
+
+	const texts = document.createTreeWalker(document.body || doc, NodeFilter.SHOW_TEXT);
+	const range = document.createRange();
+	for (let node = texts.nextNode(); node; node = texts.nextNode()) {
+		if (!node.data.trim() || !node.parentElement || !visible(node.parentElement)) continue;
+		if (['SCRIPT', 'STYLE', 'NOSCRIPT', 'TEMPLATE'].includes(node.parentElement.tagName)) continue;
+		range.selectNodeContents(node);
+		for (const r of range.getClientRects()) paint(r, 1);
+	}
+
+	const lines = [];
+	for (let row = 0; row < rows; row++) {
+		let line = '';
+		for (let col = 0; col < cols; col++) line += marks[grid[row * cols + col]];
+		lines.push(line);
+	}
+	return {
+		width, height, truncated: fullHeight > height, screen: window.innerHeight, cell, grid: lines,
+		ads: ads.map(({el, ...ad}) => ad),
+	};
+}`
+
+// ContentMap maps where text, images, media such as videos and embeds,
+// ads and whitespace fall on the whole page, and their shares per screen
+// at the current viewport. Ads are recognized by ad network URLs, ad
+// markup and standard ad sizes of iframes.
+// Assumes NavigateAndPrepare has already been called.
+func (b *Browser) ContentMap(ctx context.Context) (*ContentMap, error) {
+	slog.Debug("Mapping page content")
+
+	var m ContentMap
+	script := fmt.Sprintf("(%s)(%d, %d, %d, %d)", contentMapScript, contentMapColumns, contentMapRows, contentMapMinCell, contentMapMaxHeight)
+	if err := b.run(ctx, chromedp.Evaluate(script, &m)); err != nil {
+		slog.Error("Failed to map page content", "error", err)
+		return nil, err
+	}
+	m.share()
+
+	slog.Debug("Page content mapped", "height", m.Height, "cell", m.Cell, "screens", len(m.Screens), "ads", len(m.Ads))
+	return &m, nil
+}
+
+// share computes Screens and Total from Grid.
+func (m *ContentMap) share() {
+	m.Screens = nil
+	var total contentCount
+	rowsPerScreen := 1
+	if m.Cell > 0 && m.Screen > m.Cell {
+		rowsPerScreen = int(m.Screen / m.Cell)
+	}
+	for start := 0; start < len(m.Grid); start += rowsPerScreen {
+		var screen contentCount
+		for _, row := range m.Grid[start:min(start+rowsPerScreen, len(m.Grid))] {
+			screen.add(row)
+		}
+		total.merge(screen)
+		m.Screens = append(m.Screens, screen.shares())
+	}
+	m.Total = total.shares()
+}
+
+// contentCount tallies grid cells by kind.
+type contentCount struct {
+	cells map[rune]int
+	all   int
+}
+
+func (c *contentCount) add(row string) {
+	if c.cells == nil {
+		c.cells = make(map[rune]int)
+	}
+	for _, r := range row {
+		c.cells[r]++
+		c.all++
+	}
+}
+
+func (c *contentCount) merge(o contentCount) {
+	if c.cells == nil {
+		c.cells = make(map[rune]int)
+	}
+	for r, n := range o.cells {
+		c.cells[r] += n
+	}
+	c.all += o.all
+}
+
+func (c *contentCount) shares() ContentShares {
+	if c.all == 0 {
+		return ContentShares{Whitespace: 1}
+	}
+	share := func(r rune) float64 {
+		return float64(c.cells[r]) / float64(c.all)
+	}
+	return ContentShares{
+		Text:       share(ContentText),
+		Image:      share(ContentImage),
+		Media:      share(ContentMedia),
+		Ad:         share(ContentAd),
+		Whitespace: share(ContentWhitespace),
+	}
+}
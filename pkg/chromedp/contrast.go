@@ -0,0 +1,308 @@
+package chromedphelper
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"fmt"
+	"image"
+	"image/png"
+	"log/slog"
+	"math"
+	"slices"
+
+	"github.com/chromedp/chromedp"
+)
+
+// WCAG conformance levels a ContrastIssue fails.
+const (
+	ContrastAA  = "AA"
+	ContrastAAA = "AAA"
+)
+
+// Bounds of ContrastCheck, so long pages stay quick to check.
+const (
+	maxContrastElements = 2000
+	maxContrastRects    = 8
+	// contrastPercentile is the share of background samples allowed to
+	// give a lower contrast than the one reported, so a stray pixel of an
+	// image or icon does not decide the result.
+	contrastPercentile = 0.1
+)
+
+// ContrastReport is the result of checking the contrast of the page's
+// text against what is actually rendered behind it.
+type ContrastReport struct {
+	// Checked is the number of text elements checked.
+	Checked int `json:"checked"`
+	// Truncated is set when the page had more text elements than were
+	// checked.
+	Truncated bool `json:"truncated,omitempty"`
+	// Issues are the text elements below the AAA contrast ratio for their
+	// size, worst first.
+	Issues []ContrastIssue `json:"issues,omitempty"`
+}
+
+// ContrastIssue is a text element with too little contrast.
+type ContrastIssue struct {
+	// Selector is a CSS selector matching only this element.
+	Selector string `json:"selector"`
+	Tag      string `json:"tag"`
+	// Text is the element's text, shortened.
+	Text string `json:"text"`
+	// Color is the text color and Background the rendered background
+	// color the ratio was measured against, as #rrggbb.
+	Color      string `json:"color"`
+	Background string `json:"background"`
+	// Ratio is the contrast ratio, from 1 to 21.
+	Ratio float64 `json:"ratio"`
+	// Large is set for large text (24px, or 18.66px bold), which needs a
+	// lower ratio.
+	Large bool `json:"large,omitempty"`
+	// Level is ContrastAA when the ratio fails AA, and ContrastAAA when it
+	// passes AA but fails AAA.
+	Level string `json:"level"`
+	Box   Box    `json:"box"`
+}
+
+// textColorsScript lists the visible elements with text of their own, with
+// their text color, including the opacity of their ancestors, and the boxes
+// of their lines in page coordinates.
+const textColorsScript = `(limit, maxRects) => {
+	const selectorOf = ` + selectorOfScript + `;
+	window.scrollTo(0, 0);
+	const parse = (color) => {
+		const m = color.match(/rgba?\(([\d.]+),?\s*([\d.]+),?\s*([\d.]+)(?:\s*[,\/]\s*([\d.]+%?))?\)/);
+		if (!m) return null;
+		const a = m[4] === undefined ? 1 : m[4].endsWith('%') ? parseFloat(m[4]) / 100 : parseFloat(m[4]);
+		return [Number(m[1]), Number(m[2]), Number(m[3]), a];
+	};
+	const opacityOf = (el) => {
+		let opacity = 1;
+		for (; el; el = el.parentElement) opacity *= Number(getComputedStyle(el).opacity);
+		return opacity;
+	};
+
+	const owners = new Map();
+	const texts = document.createTreeWalker(document.body || document.documentElement, NodeFilter.SHOW_TEXT);
+	for (let node = texts.nextNode(); node; node = texts.nextNode()) {
+		const el = node.parentElement;
+		if (!el || !node.data.trim() || ['SCRIPT', 'STYLE', 'NOSCRIPT', 'TEMPLATE'].includes(el.tagName)) continue;
+		if (!owners.has(el)) owners.set(el, []);
+		owners.get(el).push(node);
+	}
+
+	const elements = [];
+	let truncated = false;
+	const range = document.createRange();
+	for (const [el, nodes] of owners) {
+		const style = getComputedStyle(el);
+		if (style.visibility === 'hidden' || style.display === 'none') continue;
+		const color = parse(style.webkitTextFillColor || style.color) || parse(style.color);
+		if (!color) continue;
+		color[3] *= opacityOf(el);
+		if (color[3] === 0) continue;
+		const rects = [];
+		for (const node of nodes) {
+			range.selectNodeContents(node);
+			for (const r of range.getClientRects()) {
+				if (r.width >= 2 && r.height >= 2 && rects.length < maxRects)
+					rects.push({x: r.left + window.scrollX, y: r.top + window.scrollY, width: r.width, height: r.height});
+			}
+		}
+		if (!rects.length) continue;
+		if (elements.length === limit) {
+			truncated = true;
+			break;
+		}
+		const size = parseFloat(style.fontSize), weight = Number(style.fontWeight) || 400;
+		elements.push({selector: selectorOf(el), tag: el.localName,
+			text: nodes.map((n) => n.data).join(' ').replace(/\s+/g, ' ').trim().slice(0, 80),
+			color, large: size >= 24 || (size >= 18.66 && weight >= 700), rects});
+	}
+	return {elements, truncated, width: Math.max(document.documentElement.scrollWidth, window.innerWidth)};
+}`
+
+// hideTextScript makes all text transparent, without transitions, so a
+// screenshot shows what is behind it; showTextScript restores it.
+const hideTextScript = `(() => {
+	const style = document.createElement('style');
+	style.id = '__that_hide_text';
+	style.textContent = '*, *::before, *::after, ::placeholder { color: transparent !important; ' +
+		'-webkit-text-fill-color: transparent !important; -webkit-text-stroke-color: transparent !important; ' +
+		'text-shadow: none !important; text-decoration-color: transparent !important; caret-color: transparent !important; ' +
+		'transition: none !important }';
+	(document.head || document.documentElement).appendChild(style);
+})()`
+
+const showTextScript = `(() => {
+	const style = document.getElementById('__that_hide_text');
+	if (style) style.remove();
+})()`
+
+// textElement is an element listed by textColorsScript.
+type textElement struct {
+	Selector string `json:"selector"`
+	Tag      string `json:"tag"`
+	Text     string `json:"text"`
+	// Color is RGBA with channels from 0 to 255 and alpha from 0 to 1.
+	Color [4]float64 `json:"color"`
+	Large bool       `json:"large"`
+	Rects []Box      `json:"rects"`
+}
+
+// ContrastCheck measures the contrast of every visible text element of the
+// page against the pixels rendered behind it, taken from a full page
+// screenshot with all text made transparent, so images, gradients and
+// overlapping elements behind text count as they are seen. It returns the
+// elements failing WCAG AA or AAA for their size.
+// Assumes NavigateAndPrepare has already been called.
+func (b *Browser) ContrastCheck(ctx context.Context) (*ContrastReport, error) {
+	slog.Debug("Checking text contrast")
+
+	var texts struct {
+		Elements  []textElement `json:"elements"`
+		Truncated bool          `json:"truncated"`
+		Width     float64       `json:"width"`
+	}
+	err := b.run(ctx,
+		chromedp.Evaluate(fmt.Sprintf("(%s)(%d, %d)", textColorsScript, maxContrastElements, maxContrastRects), &texts),
+		chromedp.Evaluate(hideTextScript, nil),
+	)
+	if err != nil {
+		slog.Error("Failed to check text contrast", "error", err)
+		return nil, err
+	}
+	shot, err := b.ScreenshotFullPage(ctx, ScreenshotOptions{Format: PNG})
+	// Show the text again even when the screenshot failed
+	if restoreErr := b.run(ctx, chromedp.Evaluate(showTextScript, nil)); err == nil {
+		err = restoreErr
+	}
+	if err != nil {
+		slog.Error("Failed to check text contrast", "error", err)
+		return nil, err
+	}
+	background, err := png.Decode(bytes.NewReader(shot))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode screenshot: %w", err)
+	}
+
+	// The screenshot is scaled by the device pixel ratio
+	scale := float64(background.Bounds().Dx()) / texts.Width
+	report := &ContrastReport{Checked: len(texts.Elements), Truncated: texts.Truncated}
+	for _, el := range texts.Elements {
+		if issue, ok := el.contrast(background, scale); ok {
+			report.Issues = append(report.Issues, issue)
+		}
+	}
+	slices.SortStableFunc(report.Issues, func(a, b ContrastIssue) int {
+		return cmp.Compare(a.Ratio, b.Ratio)
+	})
+
+	slog.Debug("Text contrast checked", "checked", report.Checked, "issues", len(report.Issues))
+	return report, nil
+}
+
+// contrast samples the background behind el's lines and returns it as an
+// issue when the contrast ratio fails AAA. scale is the number of
+// screenshot pixels per CSS pixel. Samples outside the screenshot, such as
+// beyond its maximum height, are skipped.
+func (el textElement) contrast(background image.Image, scale float64) (ContrastIssue, bool) {
+	bounds := background.Bounds()
+	var ratios []float64
+	var colors [][3]float64
+	for _, r := range el.Rects {
+		// Sample the centers of up to 8x4 cells of each line box
+		columns := min(8, int(math.Ceil(r.Width/4)))
+		rows := min(4, int(math.Ceil(r.Height/4)))
+		for row := range rows {
+			for col := range columns {
+				x := r.X + (float64(col)+0.5)*r.Width/float64(columns)
+				y := r.Y + (float64(row)+0.5)*r.Height/float64(rows)
+				px, py := int(x*scale), int(y*scale)
+				if !image.Pt(px, py).In(bounds) {
+					continue
+				}
+				cr, cg, cb, _ := background.At(px, py).RGBA()
+				bg := [3]float64{float64(cr >> 8), float64(cg >> 8), float64(cb >> 8)}
+				ratios = append(ratios, contrastRatio(blend(el.Color, bg), bg))
+				colors = append(colors, bg)
+			}
+		}
+	}
+	if len(ratios) == 0 {
+		return ContrastIssue{}, false
+	}
+
+	order := make([]int, len(ratios))
+	for i := range order {
+		order[i] = i
+	}
+	slices.SortFunc(order, func(a, b int) int {
+		return cmp.Compare(ratios[a], ratios[b])
+	})
+	i := order[int(float64(len(order)-1)*contrastPercentile)]
+	ratio := math.Floor(ratios[i]*100) / 100
+
+	aa, aaa := 4.5, 7.0
+	if el.Large {
+		aa, aaa = 3, 4.5
+	}
+	level := ""
+	switch {
+	case ratio < aa:
+		level = ContrastAA
+	case ratio < aaa:
+		level = ContrastAAA
+	default:
+		return ContrastIssue{}, false
+	}
+	return ContrastIssue{
+		Selector:   el.Selector,
+		Tag:        el.Tag,
+		Text:       el.Text,
+		Color:      hexColor(el.Color[:3]),
+		Background: hexColor(colors[i][:]),
+		Ratio:      ratio,
+		Large:      el.Large,
+		Level:      level,
+		Box:        el.Rects[0],
+	}, true
+}
+
+// blend composites color, RGBA with alpha from 0 to 1, over bg.
+func blend(color [4]float64, bg [3]float64) [3]float64 {
+	var out [3]float64
+	for i := range out {
+		out[i] = color[i]*color[3] + bg[i]*(1-color[3])
+	}
+	return out
+}
+
+// contrastRatio is the WCAG contrast ratio of two RGB colors, from 1 to 21.
+func contrastRatio(a, b [3]float64) float64 {
+	la, lb := luminance(a), luminance(b)
+	if la < lb {
+		la, lb = lb, la
+	}
+	return (la + 0.05) / (lb + 0.05)
+}
+
+// luminance is the WCAG relative luminance of an sRGB color.
+func luminance(c [3]float64) float64 {
+	var linear [3]float64
+	for i, v := range c {
+		v /= 255
+		if v <= 0.03928 {
+			linear[i] = v / 12.92
+		} else {
+			linear[i] = math.Pow((v+0.055)/1.055, 2.4)
+		}
+	}
+	return 0.2126*linear[0] + 0.7152*linear[1] + 0.0722*linear[2]
+}
+
+// hexColor renders RGB channels from 0 to 255 as #rrggbb.
+func hexColor(c []float64) string {
+	return fmt.Sprintf("#%02x%02x%02x", int(math.Round(c[0])), int(math.Round(c[1])), int(math.Round(c[2])))
+}
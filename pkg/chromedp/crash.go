@@ -0,0 +1,110 @@
+package chromedphelper
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+
+	"github.com/chromedp/cdproto/target"
+	"github.com/chromedp/chromedp"
+)
+
+// Kinds of Crash.
+const (
+	// CrashRenderer is the tab's renderer process crashing or being killed.
+	CrashRenderer = "renderer-crash"
+	// CrashOOM is the tab's renderer process running out of memory.
+	CrashOOM = "renderer-oom"
+)
+
+// Crash is the tab's renderer going away while the page was processed.
+type Crash struct {
+	// Kind is CrashRenderer or CrashOOM.
+	Kind string `json:"kind"`
+	// Status is Chrome's termination status, e.g. "crashed", "killed" or
+	// "oom", when it reported one.
+	Status string `json:"status,omitempty"`
+	// ErrorCode is the renderer's exit code or signal, when reported.
+	ErrorCode int64 `json:"errorCode,omitempty"`
+	// Recovered is set when the page was processed again in a fresh tab
+	// and that attempt did not crash.
+	Recovered bool `json:"recovered,omitempty"`
+}
+
+// CrashError is returned by every operation of a tab whose renderer
+// crashed, in place of the error the crash caused, usually a timeout or a
+// cancellation. It does not unwrap to that error, so a crash is not
+// mistaken for a slow page.
+type CrashError struct {
+	Crash
+	Err error
+}
+
+func (e *CrashError) Error() string {
+	msg := "tab crashed (" + e.Kind
+	if e.Status != "" {
+		msg += ", status " + e.Status
+	}
+	return msg + ")"
+}
+
+// crashWatch records the crash of a tab's renderer. aborted is cancelled
+// on a crash, so operations waiting for a renderer that is gone stop.
+type crashWatch struct {
+	mu      sync.Mutex
+	crash   *Crash
+	aborted context.Context
+	abort   context.CancelFunc
+}
+
+// reset starts watching a tab living as long as ctx.
+func (w *crashWatch) reset(ctx context.Context) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.crash = nil
+	w.aborted, w.abort = context.WithCancel(ctx)
+}
+
+// record marks the tab as crashed. Chrome announces a crash twice, once
+// without a status (Inspector.targetCrashed) and once with it
+// (Target.targetCrashed), in either order; the status refines the kind.
+func (w *crashWatch) record(status string, errorCode int64) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.crash == nil {
+		w.crash = &Crash{Kind: CrashRenderer}
+		slog.Warn("Tab crashed")
+	}
+	if status != "" {
+		w.crash.Status, w.crash.ErrorCode = status, errorCode
+		if status == "oom" {
+			w.crash.Kind = CrashOOM
+		}
+	}
+	if w.abort != nil {
+		w.abort()
+	}
+}
+
+// get returns a copy of the crash, or nil.
+func (w *crashWatch) get() *Crash {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.crash == nil {
+		return nil
+	}
+	c := *w.crash
+	return &c
+}
+
+// Crashed returns the crash of the tab's renderer, or nil while it is
+// alive. A crashed tab cannot be used anymore; open a new one.
+func (b *Browser) Crashed() *Crash {
+	return b.crashes.get()
+}
+
+// crashOf reports whether a target crash event is about this tab.
+func (b *Browser) crashOf(ev *target.EventTargetCrashed) bool {
+	c := chromedp.FromContext(b.Ctx)
+	return c != nil && c.Target != nil && c.Target.TargetID == ev.TargetID
+}
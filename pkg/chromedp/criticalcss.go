@@ -0,0 +1,147 @@
+package chromedphelper
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"unicode/utf16"
+
+	"github.com/chromedp/cdproto/css"
+	"github.com/chromedp/cdproto/dom"
+	"github.com/chromedp/chromedp"
+
+	"github.com/pesarkhobeee/that-cli-web-toolbox/pkg/criticalcss"
+)
+
+// aboveTheFoldScript reports for each selector passed to it whether it
+// matches an element intersecting the first screen of the page. States
+// and pseudo-elements, which querySelectorAll cannot match, are stripped
+// first; selectors that still cannot be matched are kept to be safe.
+const aboveTheFoldScript = `(selectors) => {
+	const pseudo = /::?(?:before|after|first-line|first-letter|marker|placeholder|selection|backdrop|file-selector-button|-(?:webkit|moz|ms)-[\w-]+)(?![\w-])|:(?:hover|focus|focus-visible|focus-within|active|visited|link|target)(?![\w-])/gi;
+	const width = window.innerWidth, height = window.innerHeight;
+	return selectors.map((selector) => {
+		// A compound left empty, as in "a :hover", matches any element
+		const query = selector.replace(pseudo, (match, at, s) =>
+			/^$|[\s,>+~(]/.test(s.charAt(at - 1)) && /^$|[\s,>+~)]/.test(s.charAt(at + match.length)) ? '*' : '');
+		try {
+			for (const el of document.querySelectorAll(query)) {
+				const r = el.getBoundingClientRect();
+				const top = r.top + window.scrollY, left = r.left + window.scrollX;
+				if (top < height && top + r.height > 0 && left < width && left + r.width > 0) return true;
+			}
+			return false;
+		} catch (e) {
+			return true;
+		}
+	});
+}`
+
+// CriticalCSS returns the CSS rules the page needs to render its first
+// screen, in the current viewport: the rules CSS coverage finds used whose
+// selectors match an element above the fold, with their enclosing at-rules
+// and the fonts and animations they use.
+// Assumes NavigateAndPrepare has already been called.
+func (b *Browser) CriticalCSS(ctx context.Context) (string, error) {
+	slog.Debug("Extracting critical CSS")
+
+	var sheets []*criticalcss.Sheet
+	err := b.run(ctx,
+		dom.Enable(),
+		css.Enable(),
+		chromedp.ActionFunc(func(ctx context.Context) error {
+			// Starting rule usage tracking restyles the whole page, so
+			// the usage covers what already rendered
+			if err := css.StartRuleUsageTracking().Do(ctx); err != nil {
+				return fmt.Errorf("failed to start CSS coverage: %w", err)
+			}
+			usage, err := css.StopRuleUsageTracking().Do(ctx)
+			if err != nil {
+				return fmt.Errorf("failed to take CSS coverage: %w", err)
+			}
+			byID := make(map[css.StyleSheetID]*criticalcss.Sheet)
+			offsets := make(map[css.StyleSheetID][]int)
+			for _, u := range usage {
+				if !u.Used || u.StyleSheetID == "" {
+					continue
+				}
+				sheet, ok := byID[u.StyleSheetID]
+				if !ok {
+					text, err := css.GetStyleSheetText(u.StyleSheetID).Do(ctx)
+					if err != nil {
+						slog.Debug("Skipping stylesheet whose text is unavailable", "styleSheetId", u.StyleSheetID, "error", err)
+						byID[u.StyleSheetID] = nil
+						continue
+					}
+					sheet = &criticalcss.Sheet{Text: text}
+					byID[u.StyleSheetID] = sheet
+					offsets[u.StyleSheetID] = utf16Offsets(text)
+					sheets = append(sheets, sheet)
+				}
+				if sheet == nil {
+					continue
+				}
+				sheet.Used = append(sheet.Used, criticalcss.Rule{Start: byteOffset(offsets[u.StyleSheetID], int(u.StartOffset))})
+			}
+			return nil
+		}),
+	)
+	if err != nil {
+		return "", err
+	}
+
+	var selectors []string
+	index := make(map[string]int)
+	for _, s := range sheets {
+		for _, r := range s.Used {
+			sel := s.Selector(r)
+			if _, ok := index[sel]; !ok && sel != "" {
+				index[sel] = len(selectors)
+				selectors = append(selectors, sel)
+			}
+		}
+	}
+	arg, err := json.Marshal(selectors)
+	if err != nil {
+		return "", err
+	}
+	var visible []bool
+	if err := b.run(ctx, chromedp.Evaluate(fmt.Sprintf("(%s)(%s)", aboveTheFoldScript, arg), &visible)); err != nil {
+		return "", fmt.Errorf("failed to match selectors above the fold: %w", err)
+	}
+	if len(visible) != len(selectors) {
+		return "", fmt.Errorf("matched %d selectors above the fold, expected %d", len(visible), len(selectors))
+	}
+
+	critical := criticalcss.Build(sheets, func(selector string) bool {
+		i, ok := index[selector]
+		return ok && visible[i]
+	})
+	slog.Debug("Critical CSS extracted", "stylesheets", len(sheets), "usedSelectors", len(selectors), "bytes", len(critical))
+	return critical, nil
+}
+
+// utf16Offsets maps the UTF-16 offsets of text, which CDP reports, to
+// byte offsets.
+func utf16Offsets(text string) []int {
+	offsets := make([]int, 0, len(text)+1)
+	for i, r := range text {
+		offsets = append(offsets, i)
+		if utf16.RuneLen(r) == 2 {
+			offsets = append(offsets, i)
+		}
+	}
+	return append(offsets, len(text))
+}
+
+// byteOffset returns the byte offset of UTF-16 offset n.
+func byteOffset(offsets []int, n int) int {
+	if n < 0 {
+		return 0
+	}
+	if n >= len(offsets) {
+		return offsets[len(offsets)-1]
+	}
+	return offsets[n]
+}
@@ -0,0 +1,42 @@
+package chromedphelper
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/url"
+
+	"github.com/chromedp/chromedp"
+)
+
+// DevToolsURL returns the address of the DevTools frontend inspecting the
+// tab, served by the remote browser b is connected to, so a person can
+// open the session in a browser of their own. It opens the tab if no
+// operation has yet, and returns "" for browsers started by this package,
+// whose debugging port is not known, and for those reached over a Unix
+// socket, which a browser cannot open.
+func (b *Browser) DevToolsURL(ctx context.Context) (string, error) {
+	if b.remote == "" {
+		return "", nil
+	}
+	if c := chromedp.FromContext(b.Ctx); c == nil || c.Target == nil {
+		if err := b.run(ctx); err != nil {
+			slog.Error("Failed to open tab", "error", err)
+			return "", fmt.Errorf("failed to open tab: %w", err)
+		}
+	}
+	c := chromedp.FromContext(b.Ctx)
+	if c == nil || c.Target == nil {
+		return "", fmt.Errorf("the tab has no target")
+	}
+	u, err := url.Parse(b.remote)
+	if err != nil {
+		return "", fmt.Errorf("invalid remote debugging URL %s: %w", b.remote, err)
+	}
+	ws := "ws"
+	if u.Scheme == "https" {
+		ws = "wss"
+	}
+	page := u.Host + "/devtools/page/" + string(c.Target.TargetID)
+	return fmt.Sprintf("%s://%s/devtools/inspector.html?%s=%s", u.Scheme, u.Host, ws, page), nil
+}
@@ -0,0 +1,45 @@
+package chromedphelper
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/chromedp/cdproto/domsnapshot"
+	"github.com/chromedp/chromedp"
+)
+
+// DOMSnapshot is the flattened DOM of the page and its frames with their
+// layout and computed styles, as DOMSnapshot.captureSnapshot returns it:
+// nodes, layout boxes and text boxes are parallel arrays per document, and
+// strings are indices into Strings.
+type DOMSnapshot struct {
+	Documents []*domsnapshot.DocumentSnapshot `json:"documents"`
+	Strings   []string                        `json:"strings"`
+}
+
+// DOMSnapshot captures the page's DOM snapshot with the values of the
+// computedStyles properties for every layout box, plus paint order, client
+// and scroll rects, blended background colors and text color opacities.
+// Assumes NavigateAndPrepare has already been called.
+func (b *Browser) DOMSnapshot(ctx context.Context, computedStyles []string) (*DOMSnapshot, error) {
+	slog.Debug("Capturing DOM snapshot", "computedStyles", computedStyles)
+
+	var snapshot DOMSnapshot
+	err := b.run(ctx, chromedp.ActionFunc(func(ctx context.Context) error {
+		var err error
+		snapshot.Documents, snapshot.Strings, err = domsnapshot.CaptureSnapshot(computedStyles).
+			WithIncludePaintOrder(true).
+			WithIncludeDOMRects(true).
+			WithIncludeBlendedBackgroundColors(true).
+			WithIncludeTextColorOpacities(true).
+			Do(ctx)
+		return err
+	}))
+	if err != nil {
+		slog.Error("Failed to capture DOM snapshot", "error", err)
+		return nil, err
+	}
+
+	slog.Debug("DOM snapshot captured", "documents", len(snapshot.Documents), "strings", len(snapshot.Strings))
+	return &snapshot, nil
+}
@@ -0,0 +1,112 @@
+package chromedphelper
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+
+	"github.com/chromedp/cdproto/emulation"
+	"github.com/chromedp/chromedp"
+	"github.com/chromedp/chromedp/device"
+)
+
+// Emulation describes how the page is rendered.
+type Emulation struct {
+	// Device is the name of a device preset, see Devices. It sets the
+	// viewport, scale factor, user agent and touch support.
+	Device string
+	// Width and Height set the viewport in CSS pixels, overriding the
+	// device's. Zero keeps the default.
+	Width  int64
+	Height int64
+	// DarkMode makes the page see prefers-color-scheme: dark.
+	DarkMode bool
+}
+
+// devices are the presets accepted by Emulation.Device.
+var devices = []chromedp.Device{
+	device.GalaxyS5,
+	device.GalaxyS8,
+	device.GalaxyS9,
+	device.IPad,
+	device.IPadMini,
+	device.IPadPro,
+	device.IPhoneSE,
+	device.IPhoneX,
+	device.IPhone11,
+	device.IPhone12,
+	device.IPhone12Pro,
+	device.IPhone12ProMax,
+	device.Pixel2,
+	device.Pixel5,
+}
+
+// Devices returns the names of the supported device presets.
+func Devices() []string {
+	names := make([]string, 0, len(devices))
+	for _, d := range devices {
+		names = append(names, d.Device().Name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// lookupDevice returns the preset named name, ignoring case.
+func lookupDevice(name string) (chromedp.Device, error) {
+	for _, d := range devices {
+		if strings.EqualFold(d.Device().Name, name) {
+			return d, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown device %q (available: %s)", name, strings.Join(Devices(), ", "))
+}
+
+// Validate checks that the device preset exists and the viewport is sane.
+func (e *Emulation) Validate() error {
+	if e.Device != "" {
+		if _, err := lookupDevice(e.Device); err != nil {
+			return err
+		}
+	}
+	if e.Width < 0 || e.Height < 0 || (e.Width == 0) != (e.Height == 0) {
+		return fmt.Errorf("invalid viewport %dx%d", e.Width, e.Height)
+	}
+	return nil
+}
+
+// action returns the chromedp actions applying the emulation.
+func (e *Emulation) action() chromedp.Action {
+	return chromedp.ActionFunc(func(ctx context.Context) error {
+		if e == nil {
+			return nil
+		}
+		if e.Device != "" {
+			d, err := lookupDevice(e.Device)
+			if err != nil {
+				return err
+			}
+			slog.Debug("Emulating device", "device", d.Device().Name)
+			if err := chromedp.Emulate(d).Do(ctx); err != nil {
+				return fmt.Errorf("failed to emulate device %q: %w", e.Device, err)
+			}
+		}
+		if e.Width > 0 && e.Height > 0 {
+			slog.Debug("Setting viewport", "width", e.Width, "height", e.Height)
+			if err := chromedp.EmulateViewport(e.Width, e.Height).Do(ctx); err != nil {
+				return fmt.Errorf("failed to set viewport: %w", err)
+			}
+		}
+		if e.DarkMode {
+			slog.Debug("Emulating dark mode")
+			err := emulation.SetEmulatedMedia().
+				WithFeatures([]*emulation.MediaFeature{{Name: "prefers-color-scheme", Value: "dark"}}).
+				Do(ctx)
+			if err != nil {
+				return fmt.Errorf("failed to emulate dark mode: %w", err)
+			}
+		}
+		return nil
+	})
+}
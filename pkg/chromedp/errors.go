@@ -0,0 +1,93 @@
+package chromedphelper
+
+import (
+	"context"
+	"errors"
+	"os/exec"
+)
+
+// Failures that callers may want to handle, matched with errors.Is. The
+// errors returned are the types below, which carry the URL, selector or
+// address involved; use errors.As to get at them.
+var (
+	// ErrNavigationTimeout is matched by a *NavigationError whose page did
+	// not load and prepare before the deadline.
+	ErrNavigationTimeout = errors.New("navigation timed out")
+	// ErrSelectorNotFound is matched by a *SelectorError.
+	ErrSelectorNotFound = errors.New("no element matches the selector")
+	// ErrRemoteUnreachable is matched by a *RemoteError.
+	ErrRemoteUnreachable = errors.New("remote browser is unreachable")
+	// ErrChromeNotFound is matched by a *LaunchError.
+	ErrChromeNotFound = errors.New("chrome executable not found")
+)
+
+// NavigationError is returned by NavigateAndPrepare when the page failed
+// to load or prepare for a reason other than a crash, a cap or a
+// pathology, which have errors of their own.
+type NavigationError struct {
+	URL string
+	Err error
+}
+
+func (e *NavigationError) Error() string { return "failed to load " + e.URL + ": " + e.Err.Error() }
+func (e *NavigationError) Unwrap() error { return e.Err }
+func (e *NavigationError) Is(target error) bool {
+	return target == ErrNavigationTimeout && errors.Is(e.Err, context.DeadlineExceeded)
+}
+
+// SelectorError is returned when waiting for an element matching Selector,
+// for an element screenshot or an interaction step, ran out of time. Like
+// a *CrashError, it does not unwrap to the timeout, so a missing element
+// is not mistaken for a slow page.
+type SelectorError struct {
+	Selector string
+	URL      string
+	Err      error
+}
+
+func (e *SelectorError) Error() string {
+	return "no element matches " + e.Selector + " on " + e.URL
+}
+func (e *SelectorError) Is(target error) bool { return target == ErrSelectorNotFound }
+
+// selectorError returns a *SelectorError for err if it is the timeout of
+// waiting for selector, and err otherwise.
+func selectorError(err error, selector, url string) error {
+	if selector != "" && errors.Is(err, context.DeadlineExceeded) {
+		return &SelectorError{Selector: selector, URL: url, Err: err}
+	}
+	return err
+}
+
+// RemoteError is returned by InitializeChromedpContext and NewPool when
+// the browser at Address, given as remoteDebuggingPort, does not answer.
+type RemoteError struct {
+	Address string
+	Err     error
+}
+
+func (e *RemoteError) Error() string        { return e.Err.Error() }
+func (e *RemoteError) Unwrap() error        { return e.Err }
+func (e *RemoteError) Is(target error) bool { return target == ErrRemoteUnreachable }
+
+// LaunchError is returned by the first operation of a browser whose Chrome
+// executable could not be found, so Chrome was never started.
+type LaunchError struct {
+	Err error
+}
+
+func (e *LaunchError) Error() string {
+	return "failed to start Chrome, install Chrome or Chromium or connect to a running one with a remote debugging port: " + e.Err.Error()
+}
+func (e *LaunchError) Unwrap() error        { return e.Err }
+func (e *LaunchError) Is(target error) bool { return target == ErrChromeNotFound }
+
+// launchError returns a *LaunchError for err if Chrome's executable could
+// not be run, and err otherwise.
+func launchError(err error) error {
+	var execErr *exec.Error
+	if errors.As(err, &execErr) {
+		return &LaunchError{Err: err}
+	}
+	return err
+}
@@ -0,0 +1,287 @@
+package chromedphelper
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/chromedp/cdproto/browser"
+	"github.com/chromedp/cdproto/cdp"
+	"github.com/chromedp/cdproto/fetch"
+	"github.com/chromedp/cdproto/inspector"
+	"github.com/chromedp/cdproto/network"
+	"github.com/chromedp/cdproto/page"
+	"github.com/chromedp/cdproto/runtime"
+	"github.com/chromedp/cdproto/target"
+	"github.com/chromedp/chromedp"
+
+	"github.com/pesarkhobeee/that-cli-web-toolbox/pkg/events"
+)
+
+// Events returns a channel receiving every page event of this tab from now
+// on. Each call returns an independent subscription; the channel is closed
+// when the browser session ends and must be drained until then.
+func (b *Browser) Events() <-chan events.Event {
+	return b.bus.Subscribe()
+}
+
+// StopEvents ends ch, a channel returned by Events: it receives the events
+// published so far and is then closed, so a consumer ranging over it can
+// be waited for.
+func (b *Browser) StopEvents(ch <-chan events.Event) {
+	b.bus.Drain(ch)
+}
+
+// listen installs the single CDP listener of the tab, translating raw
+// protocol events into typed events on the browser's bus.
+func (b *Browser) listen() {
+	b.bus = &events.Bus{}
+	context.AfterFunc(b.Ctx, b.bus.Close)
+	b.crashes.reset(b.Ctx)
+	b.limits.reset(b.Ctx, b.MaxBytes, b.MaxRequests)
+
+	// Listener callbacks run sequentially, so the in-flight request table
+	// and the main frame ID need no locking.
+	inflight := make(map[network.RequestID]*events.RequestFinished)
+	var mainFrame cdp.FrameID
+
+	chromedp.ListenTarget(b.Ctx, func(ev interface{}) {
+		switch ev := ev.(type) {
+		case *runtime.EventConsoleAPICalled:
+			// Combine all arguments into a single message
+			var values []string
+			for _, arg := range ev.Args {
+				values = append(values, remoteObjectString(arg))
+			}
+			b.bus.Publish(events.ConsoleMessage{
+				Type:      string(ev.Type),
+				Text:      strings.Join(values, " "),
+				Timestamp: time.Now(),
+			})
+		case *runtime.EventExceptionThrown:
+			b.bus.Publish(exceptionEvent(ev.ExceptionDetails))
+		case *page.EventJavascriptDialogOpening:
+			b.bus.Publish(events.Dialog{
+				Type:      string(ev.Type),
+				Message:   ev.Message,
+				URL:       ev.URL,
+				Timestamp: time.Now(),
+			})
+		case *browser.EventDownloadWillBegin:
+			b.bus.Publish(events.Download{
+				URL:               ev.URL,
+				SuggestedFilename: ev.SuggestedFilename,
+				Timestamp:         time.Now(),
+			})
+		case *page.EventFrameNavigated:
+			if ev.Frame.ParentID == "" {
+				mainFrame = ev.Frame.ID
+			}
+		case *page.EventFrameRequestedNavigation:
+			if ev.FrameID == mainFrame {
+				now := time.Now()
+				b.watch.navigation(ev.URL, string(ev.Reason), now)
+				b.bus.Publish(events.Navigation{
+					URL:       ev.URL,
+					Reason:    string(ev.Reason),
+					Timestamp: now,
+				})
+			}
+		case *page.EventNavigatedWithinDocument:
+			if ev.FrameID == mainFrame {
+				b.watch.navigation(ev.URL, "sameDocument", time.Now())
+			}
+		case *page.EventLifecycleEvent:
+			if ev.FrameID == mainFrame && b.milestones.lifecycle(ev.Name) {
+				b.captureMilestone()
+			}
+		case *runtime.EventBindingCalled:
+			if ev.Name == lcpBinding && b.milestones.lcpCandidate() {
+				b.captureMilestone()
+			}
+		case *page.EventLoadEventFired:
+			b.watch.load()
+			b.bus.Publish(events.Load{Timestamp: time.Now()})
+		case *inspector.EventTargetCrashed:
+			b.crashes.record("", 0)
+		case *target.EventTargetCrashed:
+			if b.crashOf(ev) {
+				b.crashes.record(ev.Status, ev.ErrorCode)
+			}
+		case *fetch.EventRequestPaused, *fetch.EventAuthRequired:
+			go b.handleFetchEvent(ev)
+		case *network.EventRequestWillBeSent:
+			document := ev.Type == network.ResourceTypeDocument && (mainFrame == "" || ev.FrameID == mainFrame)
+			b.watch.requestStarted(ev.RequestID, ev.Request.URL, document, time.Now())
+			if document {
+				b.watch.document(ev.Request.URL, ev.RedirectResponse != nil)
+			}
+			// A redirect reuses the request ID: finish the previous hop first
+			if req, ok := inflight[ev.RequestID]; ok && ev.RedirectResponse != nil {
+				delete(inflight, ev.RequestID)
+				applyResponse(req, ev.RedirectResponse)
+				finishRequest(req)
+				b.bus.Publish(*req)
+			}
+			inflight[ev.RequestID] = &events.RequestFinished{
+				RequestID:      string(ev.RequestID),
+				URL:            ev.Request.URL,
+				Method:         ev.Request.Method,
+				ResourceType:   string(ev.Type),
+				RequestHeaders: headerStrings(ev.Request.Headers),
+				PostData:       postData(ev.Request.PostDataEntries),
+				StartedAt:      time.Now(),
+			}
+		case *network.EventResponseReceived:
+			if req, ok := inflight[ev.RequestID]; ok {
+				applyResponse(req, ev.Response)
+			}
+		case *network.EventDataReceived:
+			if b.limits.received(ev.RequestID, ev.EncodedDataLength) {
+				go b.stopLoading()
+			}
+		case *network.EventLoadingFinished:
+			b.watch.requestDone(ev.RequestID)
+			if b.limits.finished(ev.RequestID, int64(ev.EncodedDataLength)) {
+				go b.stopLoading()
+			}
+			if req, ok := inflight[ev.RequestID]; ok {
+				delete(inflight, ev.RequestID)
+				req.EncodedDataLength = ev.EncodedDataLength
+				finishRequest(req)
+				b.bus.Publish(*req)
+			}
+		case *network.EventLoadingFailed:
+			b.watch.requestDone(ev.RequestID)
+			b.limits.finished(ev.RequestID, 0)
+			if req, ok := inflight[ev.RequestID]; ok {
+				delete(inflight, ev.RequestID)
+				req.Failed = true
+				req.ErrorText = ev.ErrorText
+				finishRequest(req)
+				b.recordMissingFile(req)
+				b.bus.Publish(*req)
+			}
+		}
+	})
+}
+
+// remoteObjectString renders a console argument. arg.Value is JSON-encoded,
+// so quotes are trimmed from strings.
+func remoteObjectString(arg *runtime.RemoteObject) string {
+	val := string(arg.Value)
+	if len(val) >= 2 && val[0] == '"' && val[len(val)-1] == '"' {
+		val = val[1 : len(val)-1]
+	}
+	return val
+}
+
+// applyResponse records the response of req.
+func applyResponse(req *events.RequestFinished, resp *network.Response) {
+	req.Status = resp.Status
+	req.StatusText = resp.StatusText
+	req.Protocol = resp.Protocol
+	req.MimeType = resp.MimeType
+	req.ResponseHeaders = headerStrings(resp.Headers)
+	req.RemoteIPAddress = resp.RemoteIPAddress
+	req.FromCache = resp.FromDiskCache
+	if resp.EncodedDataLength > 0 {
+		req.EncodedDataLength = resp.EncodedDataLength
+	}
+	if t := resp.Timing; t != nil {
+		req.Timing = &events.Timing{
+			Blocked: phase(0, firstStart(t.DNSStart, t.ConnectStart, t.SendStart)),
+			DNS:     phase(t.DNSStart, t.DNSEnd),
+			Connect: phase(t.ConnectStart, t.ConnectEnd),
+			SSL:     phase(t.SslStart, t.SslEnd),
+			Send:    phase(t.SendStart, t.SendEnd),
+			Wait:    phase(t.SendEnd, t.ReceiveHeadersEnd),
+			Receive: -1,
+		}
+	}
+}
+
+// finishRequest stamps req as finished now and derives the time spent
+// receiving the body from the overall duration.
+func finishRequest(req *events.RequestFinished) {
+	req.Timestamp = time.Now()
+	if req.Timing == nil {
+		return
+	}
+	total := float64(req.Timestamp.Sub(req.StartedAt)) / float64(time.Millisecond)
+	var spent float64
+	for _, v := range []float64{req.Timing.Blocked, req.Timing.DNS, req.Timing.Connect, req.Timing.Send, req.Timing.Wait} {
+		if v > 0 {
+			spent += v
+		}
+	}
+	req.Timing.Receive = max(total-spent, 0)
+}
+
+// phase returns the duration between two CDP timing offsets, or -1 when
+// the phase did not happen (CDP reports -1 offsets for those).
+func phase(start, end float64) float64 {
+	if start < 0 || end < 0 {
+		return -1
+	}
+	return end - start
+}
+
+// firstStart returns the first non-negative offset, or -1.
+func firstStart(offsets ...float64) float64 {
+	for _, o := range offsets {
+		if o >= 0 {
+			return o
+		}
+	}
+	return -1
+}
+
+// postData joins the base64-encoded entries of a request body.
+func postData(entries []*network.PostDataEntry) string {
+	var body []byte
+	for _, e := range entries {
+		data, err := base64.StdEncoding.DecodeString(e.Bytes)
+		if err != nil {
+			continue
+		}
+		body = append(body, data...)
+	}
+	return string(body)
+}
+
+// headerStrings converts CDP headers, whose values are JSON values, to
+// plain strings.
+func headerStrings(headers network.Headers) map[string]string {
+	if len(headers) == 0 {
+		return nil
+	}
+	out := make(map[string]string, len(headers))
+	for k, v := range headers {
+		out[k] = fmt.Sprint(v)
+	}
+	return out
+}
+
+func exceptionEvent(details *runtime.ExceptionDetails) events.Exception {
+	ex := events.Exception{
+		Text:      details.Text,
+		URL:       details.URL,
+		Line:      details.LineNumber,
+		Column:    details.ColumnNumber,
+		Timestamp: time.Now(),
+	}
+	if details.StackTrace != nil {
+		for _, frame := range details.StackTrace.CallFrames {
+			ex.StackTrace = append(ex.StackTrace, events.Frame{
+				Function: frame.FunctionName,
+				URL:      frame.URL,
+				Line:     frame.LineNumber,
+				Column:   frame.ColumnNumber,
+			})
+		}
+	}
+	return ex
+}
@@ -0,0 +1,218 @@
+package chromedphelper
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"math/rand/v2"
+	"strings"
+
+	"github.com/chromedp/cdproto/emulation"
+	"github.com/chromedp/cdproto/page"
+	"github.com/chromedp/chromedp"
+)
+
+// FingerprintProfile is what the page can learn about the browser through
+// its user agent, viewport, languages, timezone and canvas and WebGL
+// rendering. Zero fields keep the browser's own value.
+type FingerprintProfile struct {
+	UserAgent string `json:"userAgent,omitempty"`
+	// Platform is navigator.platform, e.g. "Win32" or "MacIntel".
+	Platform string `json:"platform,omitempty"`
+	Width    int64  `json:"width,omitempty"`
+	Height   int64  `json:"height,omitempty"`
+	// Languages are navigator.languages and Accept-Language, most
+	// preferred first.
+	Languages []string `json:"languages,omitempty"`
+	// Timezone is an IANA time zone ID, e.g. "Europe/Berlin".
+	Timezone string `json:"timezone,omitempty"`
+	// WebGLVendor and WebGLRenderer are reported as the unmasked WebGL
+	// vendor and renderer.
+	WebGLVendor   string `json:"webglVendor,omitempty"`
+	WebGLRenderer string `json:"webglRenderer,omitempty"`
+	// Noise, if not zero, seeds slight changes to pixels the page reads
+	// back from canvases and WebGL, so the image hash differs per profile.
+	Noise uint32 `json:"noise,omitempty"`
+}
+
+// Validate checks that the viewport is sane.
+func (p *FingerprintProfile) Validate() error {
+	if p.Width < 0 || p.Height < 0 || (p.Width == 0) != (p.Height == 0) {
+		return fmt.Errorf("invalid fingerprint viewport %dx%d", p.Width, p.Height)
+	}
+	return nil
+}
+
+// fingerprintPlatform is a consistent set of values for one operating
+// system, so random profiles don't pair a Mac user agent with Direct3D.
+type fingerprintPlatform struct {
+	userAgent string
+	platform  string
+	webgl     [][2]string
+}
+
+var fingerprintPlatforms = []fingerprintPlatform{
+	{
+		userAgent: "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/%d.0.0.0 Safari/537.36",
+		platform:  "Win32",
+		webgl: [][2]string{
+			{"Google Inc. (NVIDIA)", "ANGLE (NVIDIA, NVIDIA GeForce RTX 3060 Direct3D11 vs_5_0 ps_5_0, D3D11)"},
+			{"Google Inc. (NVIDIA)", "ANGLE (NVIDIA, NVIDIA GeForce GTX 1660 SUPER Direct3D11 vs_5_0 ps_5_0, D3D11)"},
+			{"Google Inc. (Intel)", "ANGLE (Intel, Intel(R) UHD Graphics 620 Direct3D11 vs_5_0 ps_5_0, D3D11)"},
+			{"Google Inc. (AMD)", "ANGLE (AMD, AMD Radeon RX 6600 Direct3D11 vs_5_0 ps_5_0, D3D11)"},
+		},
+	},
+	{
+		userAgent: "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/%d.0.0.0 Safari/537.36",
+		platform:  "MacIntel",
+		webgl: [][2]string{
+			{"Google Inc. (Apple)", "ANGLE (Apple, ANGLE Metal Renderer: Apple M1, Unspecified Version)"},
+			{"Google Inc. (Apple)", "ANGLE (Apple, ANGLE Metal Renderer: Apple M2, Unspecified Version)"},
+			{"Google Inc. (Intel Inc.)", "ANGLE (Intel Inc., Intel(R) Iris(TM) Plus Graphics 655, OpenGL 4.1)"},
+		},
+	},
+	{
+		userAgent: "Mozilla/5.0 (X11; Linux x86_64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/%d.0.0.0 Safari/537.36",
+		platform:  "Linux x86_64",
+		webgl: [][2]string{
+			{"Google Inc. (Intel)", "ANGLE (Intel, Mesa Intel(R) UHD Graphics 620 (KBL GT2), OpenGL 4.6)"},
+			{"Google Inc. (AMD)", "ANGLE (AMD, AMD Radeon RX 580 Series (radeonsi, polaris10, LLVM 15.0.7), OpenGL 4.6)"},
+		},
+	},
+}
+
+var (
+	fingerprintViewports = [][2]int64{{1920, 1080}, {1536, 864}, {1440, 900}, {1366, 768}, {1280, 800}, {2560, 1440}}
+	fingerprintLanguages = [][]string{{"en-US", "en"}, {"en-GB", "en"}, {"de-DE", "de", "en"}, {"fr-FR", "fr", "en"}, {"es-ES", "es", "en"}, {"nl-NL", "nl", "en"}}
+	fingerprintTimezones = []string{"America/New_York", "America/Chicago", "America/Los_Angeles", "Europe/London", "Europe/Berlin", "Europe/Paris", "Europe/Amsterdam", "Asia/Tokyo"}
+)
+
+// RandomFingerprintProfile returns a plausible desktop Chrome profile
+// drawn from r.
+func RandomFingerprintProfile(r *rand.Rand) *FingerprintProfile {
+	pick := func(n int) int { return r.IntN(n) }
+	platform := fingerprintPlatforms[pick(len(fingerprintPlatforms))]
+	viewport := fingerprintViewports[pick(len(fingerprintViewports))]
+	webgl := platform.webgl[pick(len(platform.webgl))]
+	return &FingerprintProfile{
+		UserAgent:     fmt.Sprintf(platform.userAgent, 130+pick(12)),
+		Platform:      platform.platform,
+		Width:         viewport[0],
+		Height:        viewport[1],
+		Languages:     fingerprintLanguages[pick(len(fingerprintLanguages))],
+		Timezone:      fingerprintTimezones[pick(len(fingerprintTimezones))],
+		WebGLVendor:   webgl[0],
+		WebGLRenderer: webgl[1],
+		Noise:         r.Uint32() | 1,
+	}
+}
+
+// action returns the chromedp actions applying the profile. It must run
+// before navigation, as the canvas and WebGL overrides are injected into
+// documents as they are created.
+func (p *FingerprintProfile) action() chromedp.Action {
+	return chromedp.ActionFunc(func(ctx context.Context) error {
+		if p == nil {
+			return nil
+		}
+		slog.Debug("Applying fingerprint profile", "userAgent", p.UserAgent, "timezone", p.Timezone, "languages", p.Languages)
+		if p.UserAgent != "" || p.Platform != "" || len(p.Languages) > 0 {
+			userAgent := p.UserAgent
+			if userAgent == "" {
+				// The override always replaces the user agent, so keep
+				// the browser's own
+				if err := chromedp.Evaluate("navigator.userAgent", &userAgent).Do(ctx); err != nil {
+					return fmt.Errorf("failed to read user agent: %w", err)
+				}
+			}
+			override := emulation.SetUserAgentOverride(userAgent)
+			if p.Platform != "" {
+				override = override.WithPlatform(p.Platform)
+			}
+			if len(p.Languages) > 0 {
+				override = override.WithAcceptLanguage(strings.Join(p.Languages, ","))
+			}
+			if err := override.Do(ctx); err != nil {
+				return fmt.Errorf("failed to override user agent: %w", err)
+			}
+		}
+		if p.Width > 0 && p.Height > 0 {
+			if err := chromedp.EmulateViewport(p.Width, p.Height).Do(ctx); err != nil {
+				return fmt.Errorf("failed to set viewport: %w", err)
+			}
+		}
+		if p.Timezone != "" {
+			if err := emulation.SetTimezoneOverride(p.Timezone).Do(ctx); err != nil {
+				return fmt.Errorf("failed to override timezone %q: %w", p.Timezone, err)
+			}
+		}
+		if p.Noise != 0 || p.WebGLVendor != "" || p.WebGLRenderer != "" {
+			if _, err := page.AddScriptToEvaluateOnNewDocument(p.script()).Do(ctx); err != nil {
+				return fmt.Errorf("failed to inject fingerprint script: %w", err)
+			}
+		}
+		return nil
+	})
+}
+
+// script returns the JavaScript overriding canvas and WebGL readback and
+// the WebGL vendor and renderer.
+func (p *FingerprintProfile) script() string {
+	vendor, _ := json.Marshal(p.WebGLVendor)
+	renderer, _ := json.Marshal(p.WebGLRenderer)
+	return fmt.Sprintf(fingerprintScript, p.Noise, vendor, renderer)
+}
+
+// fingerprintScript takes the noise seed, WebGL vendor and WebGL renderer.
+// Noise flips the lowest bit of a few color values, chosen by the seed and
+// the pixel's position, so the same profile always renders the same image.
+const fingerprintScript = `(() => {
+	const seed = %d, vendor = %s, renderer = %s;
+	const noise = (data) => {
+		if (!seed) return data;
+		for (let i = 0; i < data.length; i += 4) {
+			let h = Math.imul(seed ^ (i >>> 2), 2654435761) >>> 0;
+			if ((h & 63) === 0) data[i + ((h >>> 6) %% 3)] ^= 1;
+		}
+		return data;
+	};
+	const getImageData = CanvasRenderingContext2D.prototype.getImageData;
+	CanvasRenderingContext2D.prototype.getImageData = function (...args) {
+		const image = getImageData.apply(this, args);
+		noise(image.data);
+		return image;
+	};
+	const noisyCopy = (canvas) => {
+		if (!seed || !canvas.width || !canvas.height) return canvas;
+		const copy = document.createElement('canvas');
+		copy.width = canvas.width;
+		copy.height = canvas.height;
+		const ctx = copy.getContext('2d');
+		ctx.drawImage(canvas, 0, 0);
+		ctx.putImageData(ctx.getImageData(0, 0, copy.width, copy.height), 0, 0);
+		return copy;
+	};
+	const toDataURL = HTMLCanvasElement.prototype.toDataURL;
+	HTMLCanvasElement.prototype.toDataURL = function (...args) {
+		return toDataURL.apply(noisyCopy(this), args);
+	};
+	const toBlob = HTMLCanvasElement.prototype.toBlob;
+	HTMLCanvasElement.prototype.toBlob = function (...args) {
+		return toBlob.apply(noisyCopy(this), args);
+	};
+	for (const proto of [WebGLRenderingContext.prototype, WebGL2RenderingContext.prototype]) {
+		const getParameter = proto.getParameter;
+		proto.getParameter = function (name) {
+			// UNMASKED_VENDOR_WEBGL and UNMASKED_RENDERER_WEBGL
+			if (name === 37445 && vendor) return vendor;
+			if (name === 37446 && renderer) return renderer;
+			return getParameter.call(this, name);
+		};
+		const readPixels = proto.readPixels;
+		proto.readPixels = function (...args) {
+			readPixels.apply(this, args);
+			if (ArrayBuffer.isView(args[6])) noise(new Uint8Array(args[6].buffer, args[6].byteOffset, args[6].byteLength));
+		};
+	}
+})();`
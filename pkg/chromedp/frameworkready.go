@@ -0,0 +1,135 @@
+package chromedphelper
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/chromedp/chromedp"
+)
+
+// frameworkAbsentAfter is how long a framework preset waits for the page
+// to show its framework before failing, once the page has loaded.
+const frameworkAbsentAfter = 5 * time.Second
+
+// frameworkPrelude defines the helpers of the framework scripts: quiet
+// tells whether the DOM went without added, removed or changed nodes for
+// 300ms, and react returns the state of the page's React root.
+const frameworkPrelude = `
+	const quiet = () => {
+		if (!window.__thatCliDomQuiet) {
+			const state = {last: performance.now()};
+			new MutationObserver(() => { state.last = performance.now(); })
+				.observe(document, {subtree: true, childList: true, characterData: true});
+			window.__thatCliDomQuiet = state;
+		}
+		return performance.now() - window.__thatCliDomQuiet.last >= 300;
+	};
+	const hasKey = (el, prefix) => !!el && Object.keys(el).some((k) => k.startsWith(prefix));
+	const react = () => {
+		const candidates = [document, document.documentElement, document.body,
+			...document.querySelectorAll('#root, #app, #__next, [data-reactroot]'),
+			...(document.body ? document.body.children : [])];
+		const root = candidates.find((el) => hasKey(el, '__reactContainer$') || (el && el._reactRootContainer));
+		if (!root) return 'absent';
+		const first = root === document ? document.documentElement : root.firstElementChild;
+		if (first && !hasKey(first, '__reactFiber$') && !hasKey(first, '__reactInternalInstance$')) return 'hydrating';
+		// Suspense boundaries still streaming from the server
+		const walker = document.createTreeWalker(document, NodeFilter.SHOW_COMMENT);
+		for (let node = walker.nextNode(); node; node = walker.nextNode()) {
+			if (node.data === '$?') return 'hydrating';
+		}
+		return 'ready';
+	};
+`
+
+// frameworkScripts return the state of the page's app of a framework:
+// "absent", "hydrating" or "ready".
+var frameworkScripts = map[string]string{
+	// The root's first element is bound to React's fibers and no Suspense
+	// boundary is pending.
+	"react": `return react();`,
+	// Hydrated (__NEXT_HYDRATED, or React's root as above) and, with the
+	// pages router, the router is ready.
+	"nextjs": `
+		if (!window.__NEXT_DATA__ && !window.next && !self.__next_f) return 'absent';
+		const router = window.next && window.next.router;
+		if (router && 'isReady' in router && !router.isReady) return 'hydrating';
+		if (window.__NEXT_HYDRATED === true) return 'ready';
+		const state = react();
+		return state === 'absent' ? 'hydrating' : state;`,
+	// A Vue 3 app is mounted (and, with Nuxt, done hydrating), or a Vue 2
+	// root instance is mounted.
+	"vue": `
+		const candidates = [...document.querySelectorAll('[data-v-app], #app, #__nuxt, #__layout'),
+			...(document.body ? document.body.children : [])];
+		for (const el of candidates) {
+			const app = el.__vue_app__;
+			if (app) {
+				const nuxt = app.config && app.config.globalProperties.$nuxt;
+				return nuxt && nuxt.isHydrating ? 'hydrating' : 'ready';
+			}
+			if (el.__vue__) return el.__vue__.$root._isMounted ? 'ready' : 'hydrating';
+		}
+		return 'absent';`,
+	// Every Angular app is stable, i.e. has no pending macrotasks such as
+	// timers and requests, when Angular's testability API is available.
+	"angular": `
+		if (typeof window.getAllAngularTestabilities === 'function') {
+			const all = window.getAllAngularTestabilities();
+			if (all.length > 0) return all.every((t) => t.isStable()) ? 'ready' : 'hydrating';
+		}
+		return document.querySelector('[ng-version]') ? 'ready' : 'absent';`,
+}
+
+func init() {
+	for name := range frameworkScripts {
+		RegisterReadiness(name, func(arg string) (ReadinessDetector, error) {
+			if arg != "" {
+				return nil, fmt.Errorf("ready strategy %s takes no argument", name)
+			}
+			return frameworkReadiness{name: name}, nil
+		})
+	}
+}
+
+// frameworkReadiness waits for the app of a framework to be hydrated and
+// the DOM to be quiet for 300ms, so loading skeletons are not captured.
+type frameworkReadiness struct{ name string }
+
+func (r frameworkReadiness) Wait(ctx context.Context, b *Browser) error {
+	expression := fmt.Sprintf(`(() => {%s
+		const state = (() => {%s})();
+		return {state, quiet: quiet(), loaded: document.readyState === 'complete'};
+	})()`, frameworkPrelude, frameworkScripts[r.name])
+	var absentSince time.Time
+	for {
+		var res struct {
+			State  string `json:"state"`
+			Quiet  bool   `json:"quiet"`
+			Loaded bool   `json:"loaded"`
+		}
+		if err := chromedp.Evaluate(expression, &res).Do(ctx); err != nil {
+			return err
+		}
+		switch {
+		case res.State == "ready" && res.Quiet:
+			return nil
+		case res.State == "absent" && res.Loaded:
+			if absentSince.IsZero() {
+				absentSince = time.Now()
+			} else if time.Since(absentSince) >= frameworkAbsentAfter {
+				return fmt.Errorf("no %s app found on the page", r.name)
+			}
+		default:
+			absentSince = time.Time{}
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(readinessPoll):
+		}
+	}
+}
+
+func (r frameworkReadiness) String() string { return r.name }
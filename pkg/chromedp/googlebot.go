@@ -0,0 +1,182 @@
+package chromedphelper
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"regexp"
+
+	"github.com/chromedp/cdproto/emulation"
+	"github.com/chromedp/chromedp"
+)
+
+// GooglebotFrom is the From header Googlebot sends with its requests.
+const GooglebotFrom = "googlebot(at)googlebot.com"
+
+// googlebotUserAgent is the user agent of Googlebot Smartphone, which
+// Google indexes with, for the browser's Chrome version.
+const googlebotUserAgent = "Mozilla/5.0 (Linux; Android 6.0.1; Nexus 5X Build/MMB29P) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/%s Mobile Safari/537.36 (compatible; Googlebot/2.1; +http://www.google.com/bot.html)"
+
+// chromeVersion finds the Chrome version in a user agent.
+var chromeVersion = regexp.MustCompile(`Chrome/([\d.]+)`)
+
+// Googlebot must be served googlebotMoreWords times as many words as
+// users, and at least googlebotExtraWords more, for a cloaking indicator.
+const (
+	googlebotMoreWords  = 1.5
+	googlebotExtraWords = 50
+)
+
+// googlebotAction presents the tab as Googlebot: its user agent, after
+// Emulation and FingerprintProfile so it wins over theirs.
+func (b *Browser) googlebotAction() chromedp.Action {
+	return chromedp.ActionFunc(func(ctx context.Context) error {
+		if !b.Googlebot {
+			return nil
+		}
+		var userAgent string
+		if err := chromedp.Evaluate("navigator.userAgent", &userAgent).Do(ctx); err != nil {
+			return fmt.Errorf("failed to read user agent: %w", err)
+		}
+		// Googlebot renders with a current Chrome, so it reports the same
+		// version as ours
+		version := "0.0.0.0"
+		if m := chromeVersion.FindStringSubmatch(userAgent); m != nil {
+			version = m[1]
+		}
+		userAgent = fmt.Sprintf(googlebotUserAgent, version)
+		slog.Debug("Presenting as Googlebot", "userAgent", userAgent)
+		if err := emulation.SetUserAgentOverride(userAgent).Do(ctx); err != nil {
+			return fmt.Errorf("failed to override user agent: %w", err)
+		}
+		return nil
+	})
+}
+
+// GooglebotTab opens a tab that loads b's target as Googlebot. The caller
+// navigates it with NavigateAndPrepare and closes it with Cancel.
+func (b *Browser) GooglebotTab(ctx context.Context) (*Browser, error) {
+	tab, err := b.NewTab(ctx)
+	if err != nil {
+		return nil, err
+	}
+	tab.TargetURL = b.TargetURL
+	tab.Ready = b.Ready
+	tab.Googlebot = true
+	return tab, nil
+}
+
+// PageView is what one visitor of a page is served: where it ends up, its
+// status, the metadata search engines read and its content.
+type PageView struct {
+	URL         string `json:"url"`
+	Status      int    `json:"status,omitempty"`
+	Title       string `json:"title"`
+	Canonical   string `json:"canonical,omitempty"`
+	Description string `json:"description,omitempty"`
+	// Robots is the robots meta tag, e.g. "noindex, nofollow".
+	Robots  string        `json:"robots,omitempty"`
+	Lang    string        `json:"lang,omitempty"`
+	Content ContentCounts `json:"content"`
+
+	content *PageContent
+}
+
+// PageView reads what the current page serves.
+// Assumes NavigateAndPrepare has already been called.
+func (b *Browser) PageView(ctx context.Context) (*PageView, error) {
+	meta, err := b.GetPageMetadata(ctx)
+	if err != nil {
+		return nil, err
+	}
+	timing, err := b.navigationTiming(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var robots string
+	if err := b.run(ctx, chromedp.Evaluate(`(() => {
+		const robots = document.querySelector('meta[name="robots" i], meta[name="googlebot" i]');
+		return robots ? (robots.getAttribute('content') || '').trim() : '';
+	})()`, &robots)); err != nil {
+		slog.Error("Failed to read robots meta tag", "error", err)
+		return nil, err
+	}
+	content, err := b.PageContent(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &PageView{
+		URL:         meta.URL,
+		Status:      timing.Status,
+		Title:       meta.Title,
+		Canonical:   meta.Canonical,
+		Description: meta.Description,
+		Robots:      robots,
+		Lang:        meta.Lang,
+		Content:     content.counts(),
+		content:     content,
+	}, nil
+}
+
+// GooglebotComparison is how a page served to Googlebot differs from the
+// page served to users.
+type GooglebotComparison struct {
+	User      *PageView `json:"user"`
+	Googlebot *PageView `json:"googlebot"`
+	// GooglebotOnly is the content only Googlebot is served.
+	GooglebotOnly ContentDiff `json:"googlebotOnly"`
+	// UserOnly is the content only users are served.
+	UserOnly ContentDiff `json:"userOnly"`
+	// Indicators describe the differences that suggest cloaking: serving
+	// search engines something else than users.
+	Indicators []string `json:"indicators"`
+}
+
+// CompareGooglebot compares user, the page as loaded normally, with bot,
+// the same page loaded by a GooglebotTab.
+func CompareGooglebot(user, bot *PageView) *GooglebotComparison {
+	c := &GooglebotComparison{
+		User:          user,
+		Googlebot:     bot,
+		GooglebotOnly: contentDiff(bot.content, user.content),
+		UserOnly:      contentDiff(user.content, bot.content),
+		Indicators:    []string{},
+	}
+	differs := func(field, u, g string) {
+		if u != g {
+			c.Indicators = append(c.Indicators, fmt.Sprintf("%s differs: %q for users, %q for Googlebot", field, u, g))
+		}
+	}
+	differs("final URL", user.URL, bot.URL)
+	if user.Status != bot.Status {
+		c.Indicators = append(c.Indicators, fmt.Sprintf("status differs: %d for users, %d for Googlebot", user.Status, bot.Status))
+	}
+	differs("title", user.Title, bot.Title)
+	differs("description", user.Description, bot.Description)
+	differs("canonical", user.Canonical, bot.Canonical)
+	differs("robots", user.Robots, bot.Robots)
+	differs("language", user.Lang, bot.Lang)
+	if float64(bot.Content.Words) > float64(user.Content.Words)*googlebotMoreWords && bot.Content.Words-user.Content.Words >= googlebotExtraWords {
+		c.Indicators = append(c.Indicators, fmt.Sprintf("Googlebot is served %d words, users %d", bot.Content.Words, user.Content.Words))
+	}
+	if n := len(c.GooglebotOnly.Headings); n > 0 {
+		c.Indicators = append(c.Indicators, fmt.Sprintf("headings only served to Googlebot: %d", n))
+	}
+	var external int
+	for _, link := range c.GooglebotOnly.Links {
+		if u, err := url.Parse(link); err == nil && !sameHost(u.Host, bot.URL) {
+			external++
+		}
+	}
+	if external > 0 {
+		c.Indicators = append(c.Indicators, fmt.Sprintf("links to other sites only served to Googlebot: %d", external))
+	}
+	return c
+}
+
+// sameHost reports whether host is the host of pageURL.
+func sameHost(host, pageURL string) bool {
+	u, err := url.Parse(pageURL)
+	return err == nil && u.Host == host
+}
@@ -0,0 +1,108 @@
+package chromedphelper
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Operators of a HeaderRule.
+const (
+	HeaderPresent = ""
+	HeaderAbsent  = "!"
+	HeaderEquals  = "="
+	HeaderMatches = "~="
+)
+
+// HeaderRule is an assertion about a response header, written:
+//
+//	NAME          the header is present
+//	!NAME         the header is absent
+//	NAME=VALUE    the header's value is VALUE, ignoring case
+//	NAME~=REGEXP  the header's value matches REGEXP
+//
+// Header names are compared ignoring case.
+type HeaderRule struct {
+	Name string
+	Op   string
+	// Value is what the value of an equals or matches rule must match.
+	Value *regexp.Regexp
+
+	raw string
+}
+
+// String returns the rule as written.
+func (r HeaderRule) String() string { return r.raw }
+
+// ParseHeaderRule parses a header rule such as "cache-control~=max-age".
+func ParseHeaderRule(s string) (HeaderRule, error) {
+	r := HeaderRule{raw: s}
+	name, value, found := strings.Cut(s, "=")
+	switch {
+	case !found:
+		r.Name, r.Op = s, HeaderPresent
+		if rest, ok := strings.CutPrefix(s, "!"); ok {
+			r.Name, r.Op = rest, HeaderAbsent
+		}
+	case strings.HasSuffix(name, "~"):
+		re, err := regexp.Compile(value)
+		if err != nil {
+			return r, fmt.Errorf("invalid header assertion %q: %w", s, err)
+		}
+		r.Name, r.Op, r.Value = strings.TrimSuffix(name, "~"), HeaderMatches, re
+	default:
+		r.Name, r.Op = name, HeaderEquals
+		r.Value = regexp.MustCompile(`(?i)^` + regexp.QuoteMeta(strings.TrimSpace(value)) + `$`)
+	}
+	r.Name = strings.TrimSpace(r.Name)
+	if r.Name == "" || strings.ContainsAny(r.Name, " \t:") {
+		return r, fmt.Errorf("invalid header assertion %q (expected NAME, !NAME, NAME=VALUE or NAME~=REGEXP, e.g. cache-control~=max-age)", s)
+	}
+	return r, nil
+}
+
+// HeaderCheck is the outcome of a HeaderRule for one response.
+type HeaderCheck struct {
+	URL    string `json:"url"`
+	Rule   string `json:"rule"`
+	Actual string `json:"actual"`
+	Passed bool   `json:"passed"`
+}
+
+// HeaderFailures returns the number of header checks that did not pass.
+func HeaderFailures(checks []HeaderCheck) int {
+	failed := 0
+	for _, c := range checks {
+		if !c.Passed {
+			failed++
+		}
+	}
+	return failed
+}
+
+// Check evaluates r against the response headers of url. A header sent
+// several times is checked as its values joined by newlines, as Chrome
+// reports it.
+func (r HeaderRule) Check(url string, headers map[string]string) HeaderCheck {
+	check := HeaderCheck{URL: url, Rule: r.String(), Actual: "missing"}
+	value, present := "", false
+	for name, v := range headers {
+		if strings.EqualFold(name, r.Name) {
+			value, present = v, true
+			break
+		}
+	}
+	if present {
+		check.Actual = strconv.Quote(value)
+	}
+	switch r.Op {
+	case HeaderPresent:
+		check.Passed = present
+	case HeaderAbsent:
+		check.Passed = !present
+	default:
+		check.Passed = present && r.Value.MatchString(value)
+	}
+	return check
+}
@@ -0,0 +1,77 @@
+package chromedphelper
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+
+	"github.com/chromedp/chromedp"
+)
+
+// overlayHost evaluates to the element holding the overlays of Highlight
+// and AnnotateInteractives, creating it on first use. Their labels live in
+// its shadow root and it ignores the pointer, so they neither receive
+// clicks nor show up in the page's text.
+const overlayHost = `(() => {
+	let host = document.getElementById('__that_overlay');
+	if (!host) {
+		host = document.createElement('div');
+		host.id = '__that_overlay';
+		host.style.cssText = 'position:absolute;left:0;top:0;width:0;height:0;z-index:2147483647;pointer-events:none';
+		host.attachShadow({mode: 'open'});
+		document.documentElement.appendChild(host);
+	}
+	return host;
+})()`
+
+// highlightScript outlines the elements matching each selector and places a
+// badge numbering them at their top-left corner, one color per selector, and
+// returns the number of elements matched by each selector.
+const highlightScript = `(selectors) => {
+	const colors = ['#e11d48', '#2563eb', '#16a34a', '#d97706', '#9333ea', '#0891b2'];
+	const host = ` + overlayHost + `;
+	const style = document.createElement('style');
+	(document.head || document.documentElement).appendChild(style);
+	let rules = '';
+	let badges = '';
+	const counts = selectors.map((selector, s) => {
+		const color = colors[s % colors.length];
+		const elements = document.querySelectorAll(selector);
+		elements.forEach((el, i) => {
+			el.setAttribute('data-that-highlight', String(s));
+			const r = el.getBoundingClientRect();
+			const x = r.left + window.scrollX, y = r.top + window.scrollY;
+			badges += '<span style="position:absolute;left:' + x + 'px;top:' + Math.max(0, y - 18) + 'px;' +
+				'background:' + color + ';color:#fff;font:bold 12px/16px sans-serif;padding:1px 5px;border-radius:3px">' +
+				(i + 1) + '</span>';
+		});
+		rules += '[data-that-highlight="' + s + '"]{outline:3px solid ' + color + ' !important;outline-offset:2px !important}';
+		return elements.length;
+	});
+	style.textContent = rules;
+	host.shadowRoot.innerHTML += badges;
+	return counts;
+}`
+
+// Highlight outlines the elements matching each selector and labels them
+// with numbered badges, so screenshots and PDFs taken afterwards point at
+// them. It returns how many elements each selector matched; a selector
+// matching nothing is not an error.
+// Assumes NavigateAndPrepare has already been called.
+func (b *Browser) Highlight(ctx context.Context, selectors []string) ([]int, error) {
+	slog.Debug("Highlighting elements", "selectors", selectors)
+
+	arg, err := json.Marshal(selectors)
+	if err != nil {
+		return nil, err
+	}
+	var counts []int
+	if err := b.run(ctx, chromedp.Evaluate(fmt.Sprintf("(%s)(%s)", highlightScript, arg), &counts)); err != nil {
+		slog.Error("Failed to highlight elements", "error", err)
+		return nil, err
+	}
+
+	slog.Debug("Elements highlighted", "counts", counts)
+	return counts, nil
+}
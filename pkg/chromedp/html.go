@@ -0,0 +1,82 @@
+package chromedphelper
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/url"
+
+	"github.com/chromedp/cdproto/page"
+	"github.com/chromedp/chromedp"
+
+	"github.com/pesarkhobeee/that-cli-web-toolbox/pkg/htmlsanitize"
+)
+
+// pixelScript lists the images that loaded as 1x1 pixels. What the page
+// makes of it only decides which images are kept: the markup is
+// sanitized outside of the page.
+const pixelScript = `Array.from(document.images)
+	.filter(img => img.complete && img.naturalWidth <= 1 && img.naturalHeight <= 1 && img.currentSrc)
+	.map(img => img.currentSrc)`
+
+// GetHTML returns the serialized markup of the rendered document.
+// Assumes NavigateAndPrepare has already been called.
+func (b *Browser) GetHTML(ctx context.Context) (string, error) {
+	slog.Debug("Getting page HTML")
+
+	var html string
+	err := b.run(ctx,
+		chromedp.Evaluate(`'<!DOCTYPE html>\n' + document.documentElement.outerHTML`, &html),
+	)
+	if err != nil {
+		slog.Error("Failed to get page HTML", "error", err)
+		return "", err
+	}
+
+	slog.Debug("Page HTML retrieved successfully", "length", len(html))
+	return html, nil
+}
+
+// GetSanitizedHTML returns the rendered document's markup passed through
+// htmlsanitize's allowlist sanitizer: scripts, styles, embeds, forms,
+// inline event handlers, unsafe URLs and tracking pixels are removed, so
+// the result is safe to re-host. The markup and URL are read through the
+// DevTools protocol and sanitized in Go, beyond the reach of the page's
+// scripts. Assumes NavigateAndPrepare has already been called.
+func (b *Browser) GetSanitizedHTML(ctx context.Context) (string, error) {
+	slog.Debug("Getting sanitized page HTML")
+
+	var markup, location string
+	var pixels []string
+	err := b.run(ctx,
+		chromedp.OuterHTML("html", &markup, chromedp.ByQuery),
+		chromedp.ActionFunc(func(ctx context.Context) error {
+			tree, err := page.GetFrameTree().Do(ctx)
+			if err != nil {
+				return err
+			}
+			location = tree.Frame.URL
+			return nil
+		}),
+		chromedp.Evaluate(pixelScript, &pixels),
+	)
+	if err != nil {
+		slog.Error("Failed to get page HTML", "error", err)
+		return "", err
+	}
+	opts := htmlsanitize.Options{Pixels: make(map[string]bool, len(pixels))}
+	if u, err := url.Parse(location); err == nil {
+		opts.Base = u
+	}
+	for _, p := range pixels {
+		opts.Pixels[p] = true
+	}
+	html, err := htmlsanitize.Sanitize(markup, opts)
+	if err != nil {
+		slog.Error("Failed to sanitize page HTML", "error", err)
+		return "", fmt.Errorf("failed to sanitize HTML: %w", err)
+	}
+
+	slog.Debug("Sanitized HTML retrieved successfully", "length", len(html))
+	return html, nil
+}
@@ -0,0 +1,41 @@
+package chromedphelper
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+
+	"github.com/chromedp/chromedp"
+)
+
+// jsonDocumentScript returns the text of the document when it was served
+// as JSON (application/json, text/json or a +json type such as
+// application/ld+json), and null otherwise. Chrome shows JSON as text in a
+// <pre>, next to its pretty-print controls in newer versions.
+const jsonDocumentScript = `(() => {
+	if (!/^(application|text)\/([\w.-]+\+)?json$/i.test(document.contentType)) return null;
+	const pre = document.querySelector('body > pre');
+	return pre ? pre.textContent : document.body ? document.body.textContent : '';
+})()`
+
+// JSONDocument returns the response body of the page when it was served
+// as JSON, and nil for any other content type.
+// Assumes NavigateAndPrepare has already been called.
+func (b *Browser) JSONDocument(ctx context.Context) (*string, error) {
+	var text *string
+	if err := b.run(ctx, chromedp.Evaluate(jsonDocumentScript, &text)); err != nil {
+		slog.Error("Failed to read document content type", "error", err)
+		return nil, err
+	}
+	if text != nil {
+		slog.Debug("Document is JSON", "size", len(*text))
+	}
+	return text, nil
+}
+
+// JSONPathResult is the values a JSONPath expression selected from a JSON
+// response, as compact JSON.
+type JSONPathResult struct {
+	Path   string            `json:"path"`
+	Values []json.RawMessage `json:"values"`
+}
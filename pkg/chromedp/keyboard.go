@@ -0,0 +1,227 @@
+package chromedphelper
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"github.com/chromedp/chromedp"
+	"github.com/chromedp/chromedp/kb"
+)
+
+// Bounds of KeyboardAudit, so pages with thousands of links stay quick to
+// audit.
+const (
+	maxKeyboardStops     = 500
+	maxKeyboardUnreached = 100
+)
+
+// KeyboardAudit is how a keyboard user moves through a page with Tab.
+type KeyboardAudit struct {
+	// Stops are the elements Tab focused, in order.
+	Stops []FocusStop `json:"stops"`
+	// Complete is set when Tab went through the page and back to where it
+	// started, so Stops is the whole focus order.
+	Complete bool `json:"complete"`
+	// Truncated is set when Tab was pressed maxKeyboardStops times without
+	// coming back.
+	Truncated bool `json:"truncated,omitempty"`
+	// Trap is set when focus kept cycling through some stops while other
+	// elements were still out of reach.
+	Trap *KeyboardTrap `json:"trap,omitempty"`
+	// Unreached are the visible elements that take focus from Tab but that
+	// Tab never reached.
+	Unreached []Focusable `json:"unreached,omitempty"`
+}
+
+// Focusable is an element that takes focus.
+type Focusable struct {
+	// Selector is a CSS selector matching only this element.
+	Selector string `json:"selector"`
+	Tag      string `json:"tag"`
+	// Role is the element's role attribute, if any.
+	Role string `json:"role,omitempty"`
+	// Name is the element's accessible name or text, shortened.
+	Name string `json:"name,omitempty"`
+	// TabIndex is the element's tabIndex; a positive one moves the element
+	// ahead of the document order.
+	TabIndex int `json:"tabIndex"`
+}
+
+// FocusStop is an element Tab focused.
+type FocusStop struct {
+	Focusable
+	// Index is the stop's position in the focus order, from 1.
+	Index int `json:"index"`
+	// Box is where the element was while focused, in page coordinates.
+	Box Box `json:"box"`
+	// Indicator is set when focusing the element changed its outline,
+	// shadow, border, colors or underline, so the focus can be seen.
+	Indicator bool `json:"indicator"`
+	// Hidden is set when the focused element was not rendered on screen,
+	// e.g. moved off-screen or of zero size.
+	Hidden bool `json:"hidden,omitempty"`
+}
+
+// KeyboardTrap is a cycle of stops focus could not leave.
+type KeyboardTrap struct {
+	// Cycle are the Index of the stops focus kept cycling through.
+	Cycle []int `json:"cycle"`
+}
+
+// keyboardScript tracks keyboard focus across Tab presses. "start" blurs
+// the page so the first Tab starts from the top, "step" describes the newly
+// focused element and "finish" blurs it again. Whether a stop has a focus
+// indicator is known once it lost focus: its styles while focused are
+// compared with its styles after, which are "settled" with each call.
+const keyboardScript = `(mode, maxUnreached) => {
+	const selectorOf = ` + selectorOfScript + `;
+	const shorten = (s) => (s || '').replace(/\s+/g, ' ').trim().slice(0, 80);
+	const styleOf = (el) => {
+		const s = getComputedStyle(el);
+		return [s.outlineStyle, s.outlineWidth, s.outlineColor, s.outlineOffset, s.boxShadow, s.borderColor,
+			s.backgroundColor, s.color, s.textDecorationLine].join('|');
+	};
+	const describe = (el) => ({
+		selector: selectorOf(el), tag: el.localName, role: el.getAttribute('role') || '',
+		name: shorten(el.getAttribute('aria-label') || el.innerText || el.value || el.title || el.alt || el.placeholder),
+		tabIndex: el.tabIndex,
+	});
+	const active = () => {
+		let el = document.activeElement;
+		while (el && el.shadowRoot && el.shadowRoot.activeElement) el = el.shadowRoot.activeElement;
+		return el === document.body || el === document.documentElement ? null : el;
+	};
+
+	if (mode === 'start') {
+		if (document.activeElement) document.activeElement.blur();
+		window.getSelection().removeAllRanges();
+		window.scrollTo(0, 0);
+		window.__thatKeyboard = {seen: [], pending: []};
+		return {};
+	}
+
+	const state = window.__thatKeyboard;
+	const current = active();
+	if (mode === 'finish' && current) current.blur();
+	const settled = [];
+	state.pending = state.pending.filter((p) => {
+		if (mode !== 'finish' && p.el === current) return true;
+		settled.push({index: p.index, indicator: styleOf(p.el) !== p.style});
+		return false;
+	});
+
+	if (mode === 'finish') {
+		const tabbable = 'a[href], area[href], button, input:not([type="hidden"]), select, textarea, iframe, summary, ' +
+			'audio[controls], video[controls], [contenteditable]:not([contenteditable="false"]), [tabindex]';
+		const unreached = [];
+		for (const el of document.querySelectorAll(tabbable)) {
+			if (el.tabIndex < 0 || el.disabled || el.closest('[inert]') || state.seen.includes(el)) continue;
+			const r = el.getBoundingClientRect();
+			const style = getComputedStyle(el);
+			if (r.width === 0 || r.height === 0 || style.visibility === 'hidden') continue;
+			if (unreached.length === maxUnreached) break;
+			unreached.push(describe(el));
+		}
+		window.scrollTo(0, 0);
+		delete window.__thatKeyboard;
+		return {settled, unreached};
+	}
+
+	if (!current) return {settled};
+	const revisit = state.seen.indexOf(current);
+	if (revisit >= 0) return {settled, revisit: revisit + 1};
+	state.seen.push(current);
+	const index = state.seen.length;
+	state.pending.push({el: current, index, style: styleOf(current)});
+	const r = current.getBoundingClientRect();
+	const style = getComputedStyle(current);
+	const hidden = r.width === 0 || r.height === 0 || style.visibility === 'hidden' || style.opacity === '0' ||
+		r.right <= 0 || r.bottom <= 0 || r.left >= window.innerWidth || r.top >= window.innerHeight;
+	return {settled, stop: {...describe(current), index,
+		box: {x: r.left + window.scrollX, y: r.top + window.scrollY, width: r.width, height: r.height}, hidden}};
+}`
+
+// keyboardStep is what keyboardScript returns.
+type keyboardStep struct {
+	Settled []struct {
+		Index     int  `json:"index"`
+		Indicator bool `json:"indicator"`
+	} `json:"settled"`
+	Stop      *FocusStop  `json:"stop"`
+	Revisit   int         `json:"revisit"`
+	Unreached []Focusable `json:"unreached"`
+}
+
+// KeyboardAudit presses Tab through the page from the top, as a keyboard
+// user would, recording the focus order, whether each focused element shows
+// a focus indicator and whether focus gets trapped. Tab presses are real key
+// events, so the page's :focus-visible styles and key handlers apply. The
+// page is left blurred and scrolled to the top.
+// Assumes NavigateAndPrepare has already been called.
+func (b *Browser) KeyboardAudit(ctx context.Context) (*KeyboardAudit, error) {
+	slog.Debug("Auditing keyboard navigation")
+
+	var audit KeyboardAudit
+	step := func(mode string) (*keyboardStep, error) {
+		var s keyboardStep
+		if err := b.run(ctx, chromedp.Evaluate(fmt.Sprintf("(%s)(%q, %d)", keyboardScript, mode, maxKeyboardUnreached), &s)); err != nil {
+			return nil, err
+		}
+		for _, settled := range s.Settled {
+			audit.Stops[settled.Index-1].Indicator = settled.Indicator
+		}
+		return &s, nil
+	}
+
+	if _, err := step("start"); err != nil {
+		slog.Error("Failed to audit keyboard navigation", "error", err)
+		return nil, err
+	}
+	var cycle []int
+	audit.Truncated = true
+	for range maxKeyboardStops {
+		if err := b.run(ctx, chromedp.KeyEvent(kb.Tab)); err != nil {
+			slog.Error("Failed to press Tab", "error", err)
+			return nil, err
+		}
+		s, err := step("step")
+		if err != nil {
+			slog.Error("Failed to audit keyboard navigation", "error", err)
+			return nil, err
+		}
+		if s.Stop == nil {
+			// Focus left the page after its last element, or nothing on
+			// the page takes focus
+			audit.Complete, audit.Truncated = true, false
+			break
+		}
+		if s.Revisit > 0 {
+			for i := s.Revisit; i <= len(audit.Stops); i++ {
+				cycle = append(cycle, i)
+			}
+			audit.Truncated = false
+			break
+		}
+		audit.Stops = append(audit.Stops, *s.Stop)
+	}
+
+	s, err := step("finish")
+	if err != nil {
+		slog.Error("Failed to audit keyboard navigation", "error", err)
+		return nil, err
+	}
+	audit.Unreached = s.Unreached
+	// A page may send focus from its last element back to its first
+	// itself; that is only a trap when it leaves elements out
+	if cycle != nil {
+		if len(audit.Unreached) > 0 {
+			audit.Trap = &KeyboardTrap{Cycle: cycle}
+		} else {
+			audit.Complete = true
+		}
+	}
+
+	slog.Debug("Keyboard navigation audited", "stops", len(audit.Stops), "complete", audit.Complete, "trap", audit.Trap != nil, "unreached", len(audit.Unreached))
+	return &audit, nil
+}
@@ -0,0 +1,168 @@
+package chromedphelper
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"github.com/chromedp/chromedp"
+)
+
+// maxOutlineHeadings bounds the headings Landmarks returns, for pages
+// such as long documentation.
+const maxOutlineHeadings = 500
+
+// Structure is a page's ARIA landmarks, roles and heading outline, with
+// the problems found in them.
+type Structure struct {
+	// Landmarks are in document order.
+	Landmarks []Landmark `json:"landmarks"`
+	// Roles counts the explicit role attributes of visible elements.
+	Roles map[string]int `json:"roles,omitempty"`
+	// Headings are the page's headings in document order.
+	Headings []OutlineHeading `json:"headings"`
+	// Truncated is set when there were more headings than Headings lists.
+	Truncated bool `json:"truncated,omitempty"`
+	// Issues describe missing or duplicated landmarks and gaps in the
+	// heading outline.
+	Issues []string `json:"issues,omitempty"`
+}
+
+// Landmark is an element exposed as an ARIA landmark, by its role attribute
+// or implicitly by its tag.
+type Landmark struct {
+	// Role is banner, navigation, main, complementary, contentinfo, search,
+	// form or region.
+	Role string `json:"role"`
+	// Selector is a CSS selector matching only this element.
+	Selector string `json:"selector"`
+	Tag      string `json:"tag"`
+	// Label is the landmark's aria-label, or the text of its
+	// aria-labelledby elements.
+	Label string `json:"label,omitempty"`
+	// Depth is how many landmarks contain this one.
+	Depth int `json:"depth"`
+}
+
+// OutlineHeading is a heading of the page's outline.
+type OutlineHeading struct {
+	// Level is 1 to 6, from the tag or aria-level.
+	Level    int    `json:"level"`
+	Text     string `json:"text"`
+	Selector string `json:"selector"`
+}
+
+// landmarksScript lists the visible landmarks, explicit roles and headings
+// of the page. header and footer are only landmarks outside sectioning
+// elements, and form and section only when they have a label, as in
+// HTML-AAM.
+const landmarksScript = `(limit) => {
+	const selectorOf = ` + selectorOfScript + `;
+	const shorten = (s) => (s || '').replace(/\s+/g, ' ').trim().slice(0, 100);
+	const landmarkRoles = ['banner', 'navigation', 'main', 'complementary', 'contentinfo', 'search', 'form', 'region'];
+	const hidden = (el) => {
+		if (el.closest('[aria-hidden="true"], [hidden]')) return true;
+		const style = getComputedStyle(el);
+		return style.display === 'none' || style.visibility === 'hidden';
+	};
+	const labelOf = (el) => {
+		const ids = (el.getAttribute('aria-labelledby') || '').split(/\s+/).filter(Boolean);
+		const labelled = ids.map((id) => document.getElementById(id)).filter(Boolean).map((l) => l.textContent).join(' ');
+		return shorten(labelled || el.getAttribute('aria-label') || el.title);
+	};
+	const roleOf = (el) => {
+		const explicit = (el.getAttribute('role') || '').trim().split(/\s+/)[0];
+		if (explicit) return explicit;
+		const sectioning = 'article, aside, main, nav, section, [role="article"], [role="complementary"], [role="main"], [role="navigation"], [role="region"]';
+		switch (el.localName) {
+		case 'main': return 'main';
+		case 'nav': return 'navigation';
+		case 'aside': return 'complementary';
+		case 'search': return 'search';
+		case 'header': return el.parentElement.closest(sectioning) ? '' : 'banner';
+		case 'footer': return el.parentElement.closest(sectioning) ? '' : 'contentinfo';
+		case 'form': return labelOf(el) ? 'form' : '';
+		case 'section': return labelOf(el) ? 'region' : '';
+		}
+		return '';
+	};
+
+	const landmarks = [], headings = [], roles = {};
+	let truncated = false;
+	const open = [];
+	const walker = document.createTreeWalker(document.body || document.documentElement, NodeFilter.SHOW_ELEMENT);
+	for (let el = walker.currentNode; el; el = walker.nextNode()) {
+		if (el.hasAttribute('role') && !hidden(el)) {
+			const role = el.getAttribute('role').trim().split(/\s+/)[0];
+			if (role) roles[role] = (roles[role] || 0) + 1;
+		}
+		const role = roleOf(el);
+		if (landmarkRoles.includes(role) && !hidden(el)) {
+			while (open.length && !open[open.length - 1].contains(el)) open.pop();
+			landmarks.push({role, selector: selectorOf(el), tag: el.localName, label: labelOf(el), depth: open.length});
+			open.push(el);
+		}
+		const level = /^h[1-6]$/.test(el.localName) && !el.hasAttribute('role') ? Number(el.localName[1])
+			: role === 'heading' ? Number(el.getAttribute('aria-level')) || Number(el.localName[1]) || 2 : 0;
+		if (level && !hidden(el)) {
+			if (headings.length === limit) {
+				truncated = true;
+			} else {
+				headings.push({level, text: shorten(el.textContent), selector: selectorOf(el)});
+			}
+		}
+	}
+	return {landmarks, roles, headings, truncated};
+}`
+
+// Landmarks summarizes the page's ARIA landmarks, explicit roles and
+// heading outline, and flags a missing main or navigation landmark, more
+// than one main landmark, no h1 and skipped heading levels.
+// Assumes NavigateAndPrepare has already been called.
+func (b *Browser) Landmarks(ctx context.Context) (*Structure, error) {
+	slog.Debug("Summarizing landmarks")
+
+	var s Structure
+	if err := b.run(ctx, chromedp.Evaluate(fmt.Sprintf("(%s)(%d)", landmarksScript, maxOutlineHeadings), &s)); err != nil {
+		slog.Error("Failed to summarize landmarks", "error", err)
+		return nil, err
+	}
+	s.Issues = s.issues()
+
+	slog.Debug("Landmarks summarized", "landmarks", len(s.Landmarks), "headings", len(s.Headings), "issues", len(s.Issues))
+	return &s, nil
+}
+
+// issues checks the landmarks and outline of s.
+func (s *Structure) issues() []string {
+	var issues []string
+	count := make(map[string]int)
+	for _, l := range s.Landmarks {
+		count[l.Role]++
+	}
+	switch {
+	case count["main"] == 0:
+		issues = append(issues, "no main landmark")
+	case count["main"] > 1:
+		issues = append(issues, fmt.Sprintf("%d main landmarks", count["main"]))
+	}
+	if count["navigation"] == 0 {
+		issues = append(issues, "no navigation landmark")
+	}
+	if len(s.Headings) == 0 {
+		return append(issues, "no headings")
+	}
+	h1 := 0
+	for i, h := range s.Headings {
+		if h.Level == 1 {
+			h1++
+		}
+		if i > 0 && h.Level > s.Headings[i-1].Level+1 {
+			issues = append(issues, fmt.Sprintf("heading level skipped: h%d %q follows h%d", h.Level, h.Text, s.Headings[i-1].Level))
+		}
+	}
+	if h1 == 0 {
+		issues = append(issues, "no h1 heading")
+	}
+	return issues
+}
@@ -0,0 +1,42 @@
+package chromedphelper
+
+// langMatchScript returns, for a language tag, a function telling whether
+// an element's language, from the lang attribute of the element or its
+// nearest ancestor, is that language or one of its regional variants: de
+// matches de, de-DE and de-CH, de-CH only de-CH. Elements without a
+// language do not match.
+const langMatchScript = `(lang) => {
+	const want = lang.toLowerCase();
+	return (el) => {
+		const tagged = el && el.closest('[lang]');
+		const have = tagged ? tagged.getAttribute('lang').trim().toLowerCase() : '';
+		return have === want || have.startsWith(want + '-');
+	};
+}`
+
+// langTextScript returns the visible text of an element like innerText,
+// one line per block, but only of the text nodes inLang accepts.
+const langTextScript = `(root, inLang) => {
+	let out = '';
+	const walk = (node) => {
+		for (const child of node.childNodes) {
+			if (child.nodeType === Node.TEXT_NODE) {
+				if (inLang(child.parentElement)) out += child.textContent;
+				continue;
+			}
+			if (child.nodeType !== Node.ELEMENT_NODE || ['script', 'style', 'noscript', 'template'].includes(child.localName)) continue;
+			const style = getComputedStyle(child);
+			if (style.display === 'none' || style.visibility === 'hidden') continue;
+			if (child.localName === 'br') {
+				out += '\n';
+				continue;
+			}
+			const block = !style.display.startsWith('inline');
+			if (block) out += '\n';
+			walk(child);
+			if (block) out += '\n';
+		}
+	};
+	walk(root);
+	return out.split('\n').map((line) => line.replace(/\s+/g, ' ').trim()).filter(Boolean).join('\n');
+}`
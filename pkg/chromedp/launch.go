@@ -0,0 +1,387 @@
+package chromedphelper
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/chromedp/cdproto/cdp"
+	"github.com/chromedp/cdproto/target"
+	"github.com/chromedp/chromedp"
+
+	"github.com/pesarkhobeee/that-cli-web-toolbox/pkg/urlfilter"
+)
+
+// LaunchOption configures a browser started by InitializeChromedpContext
+// or NewPool. Options only apply to browsers started by this package, not
+// to one connected through a remote debugging port.
+type LaunchOption func(*launchConfig)
+
+type launchConfig struct {
+	proxy     string
+	caCerts   []*x509.Certificate
+	hosts     *urlfilter.Hosts
+	untrusted bool
+	isolated  bool
+	headful   bool
+	headless  string
+	execPath  string
+	profiles  string
+	// userDataDir is the profile of WithUserDataDir.
+	userDataDir string
+}
+
+// Headless modes of WithHeadlessMode.
+const (
+	// HeadlessNew is the headless mode of the full Chrome browser, the
+	// default since Chrome 112.
+	HeadlessNew = "new"
+	// HeadlessOld is the separate headless implementation Chrome used
+	// before, removed from the Chrome binary in Chrome 132.
+	HeadlessOld = "old"
+	// HeadlessShell runs HeadlessShellExecutable, the old headless
+	// implementation shipped on its own.
+	HeadlessShell = "shell"
+)
+
+// HeadlessModes lists the modes WithHeadlessMode accepts.
+var HeadlessModes = []string{HeadlessNew, HeadlessOld, HeadlessShell}
+
+// HeadlessShellExecutable is the name of the chrome-headless-shell
+// executable HeadlessShell runs, looked up on the PATH.
+const HeadlessShellExecutable = "chrome-headless-shell"
+
+// WithProxy routes all of the browser's traffic through proxy, e.g.
+// socks5://127.0.0.1:9050. For SOCKS proxies, host names are resolved by
+// the proxy and WebRTC may not bypass it, so neither DNS lookups nor peer
+// connections reveal the machine's own address.
+func WithProxy(proxy string) LaunchOption {
+	return func(c *launchConfig) {
+		c.proxy = proxy
+	}
+}
+
+// WithCABundle makes Chrome accept server certificates issued by certs, such
+// as an internal CA, and the package's own HTTP requests trust them in
+// addition to the system's roots. Chrome matches them by public key against
+// the chain the server presents, so certs should include the intermediates
+// servers send. A remote browser must be set up to trust them itself; for
+// it, only the connection check uses them.
+func WithCABundle(certs []*x509.Certificate) LaunchOption {
+	return func(c *launchConfig) {
+		c.caCerts = certs
+	}
+}
+
+// WithAllowedHosts makes Chrome fail to resolve host names hosts does not
+// allow, backing up Browser.AllowedHosts for connections request
+// interception does not see, such as WebSockets and prefetches. Behind a
+// proxy, which resolves the names of the hosts it connects to, only the
+// proxy's own name is resolved. WebRTC is kept from connecting to peers
+// directly.
+func WithAllowedHosts(hosts *urlfilter.Hosts) LaunchOption {
+	return func(c *launchConfig) {
+		c.hosts = hosts
+	}
+}
+
+// WithUntrusted starts Chrome for rendering untrusted HTML: like
+// WithAllowedHosts, with no host allowed when none was given, and with
+// popups blocked, as the tabs they open escape request interception.
+// All of Chrome's traffic, loopback included, goes through a proxy of the
+// package refusing connections to other hosts, so neither WebSockets nor
+// IP literals escape the allowlist. It cannot be combined with WithProxy,
+// and tabs must not be given a WithTabProxy of their own.
+// Like every browser started by this package, Chrome gets a fresh
+// temporary profile, removed when it exits. Use it together with
+// Browser.Untrusted.
+func WithUntrusted() LaunchOption {
+	return func(c *launchConfig) {
+		c.untrusted = true
+	}
+}
+
+// WithUserDataDir starts Chrome with its profile in dir instead of one
+// of its own, so the caller can read the files Chrome writes there, such
+// as DevToolsActivePort with the port Chrome picked for DevTools. It
+// overrides WithProfileDir; the caller creates and removes dir.
+func WithUserDataDir(dir string) LaunchOption {
+	return func(c *launchConfig) {
+		c.userDataDir = dir
+	}
+}
+
+// WithIsolatedSession opens the session in a browser context of its own,
+// removed when the session ends, so it shares no cookies or storage with
+// other clients of the same browser. It is meant for remote browsers; a
+// browser started by this package has a fresh profile anyway.
+func WithIsolatedSession() LaunchOption {
+	return func(c *launchConfig) {
+		c.isolated = true
+	}
+}
+
+// WithHeadful shows Chrome's window instead of running it headless, so a
+// person can watch and inspect the page.
+func WithHeadful() LaunchOption {
+	return func(c *launchConfig) {
+		c.headful = true
+	}
+}
+
+// WithHeadlessMode starts Chrome in mode, one of HeadlessModes, instead of
+// the default of the Chrome binary. The modes render differently, so
+// pinning one keeps screenshots comparable when Chrome is upgraded.
+func WithHeadlessMode(mode string) LaunchOption {
+	return func(c *launchConfig) {
+		c.headless = mode
+	}
+}
+
+// WithExecPath starts the Chrome executable at path instead of looking for
+// an installed Chrome, e.g. a pinned Chrome for Testing build. With
+// WithHeadlessMode(HeadlessShell), path is the chrome-headless-shell to
+// start.
+func WithExecPath(path string) LaunchOption {
+	return func(c *launchConfig) {
+		c.execPath = path
+	}
+}
+
+// WithProfileDir creates Chrome's temporary profile in dir instead of the
+// system's temporary directory, named PID-RANDOM after the process, and
+// removes it when Chrome exits. A profile left behind by a killed process
+// can so be recognized as such, see the cache package.
+func WithProfileDir(dir string) LaunchOption {
+	return func(c *launchConfig) {
+		c.profiles = dir
+	}
+}
+
+// newLaunchConfig applies opts.
+func newLaunchConfig(opts []LaunchOption) *launchConfig {
+	c := &launchConfig{}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// empty reports whether no option changes how Chrome is started.
+func (c *launchConfig) empty() bool {
+	return c.proxy == "" && len(c.caCerts) == 0 && c.hosts == nil && !c.untrusted && c.userDataDir == "" && !c.headful && c.headless == "" && c.execPath == "" && c.profiles == ""
+}
+
+// contextOptions returns the options of the session's first context.
+func (c *launchConfig) contextOptions() []chromedp.ContextOption {
+	if c.isolated {
+		return []chromedp.ContextOption{chromedp.WithNewBrowserContext()}
+	}
+	return nil
+}
+
+// httpClient returns a client with timeout trusting the CA bundle.
+func (c *launchConfig) httpClient(timeout time.Duration) *http.Client {
+	client := &http.Client{Timeout: timeout}
+	if len(c.caCerts) == 0 {
+		return client
+	}
+	roots, err := x509.SystemCertPool()
+	if err != nil {
+		slog.Debug("System certificate pool unavailable, trusting only the CA bundle", "error", err)
+		roots = x509.NewCertPool()
+	}
+	for _, cert := range c.caCerts {
+		roots.AddCert(cert)
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = &tls.Config{RootCAs: roots}
+	client.Transport = transport
+	return client
+}
+
+// allocator returns an allocator context starting Chrome with the default
+// flags plus those required by the options. It fails when the allowlist
+// proxy of WithUntrusted cannot be started.
+func (c *launchConfig) allocator(parent context.Context) (context.Context, context.CancelFunc, error) {
+	opts := append([]chromedp.ExecAllocatorOption(nil), chromedp.DefaultExecAllocatorOptions[:]...)
+	// Host names Chrome may still resolve itself, when it must not resolve
+	// any other
+	var resolvable []string
+	restrictDNS := false
+	stopProxy := func() {}
+	if c.untrusted {
+		proxy, err := startAllowlistProxy(c.hosts)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to start allowlist proxy: %w", err)
+		}
+		stopProxy = proxy.Close
+		opts = append(opts,
+			chromedp.ProxyServer("http://"+proxy.Addr()),
+			// Chrome connects to loopback hosts directly unless told not to
+			chromedp.Flag("proxy-bypass-list", "<-loopback>"),
+		)
+	} else if c.proxy != "" {
+		slog.Debug("Routing browser traffic through proxy", "proxy", c.proxy)
+		opts = append(opts, chromedp.ProxyServer(c.proxy))
+		if u, err := url.Parse(c.proxy); err == nil {
+			resolvable = append(resolvable, u.Hostname())
+			// Fail local DNS lookups (the proxy resolves navigation
+			// hosts), except for reaching the proxy itself
+			restrictDNS = u.Scheme == "socks5" || u.Scheme == "socks4"
+		}
+	}
+	if c.hosts != nil || c.untrusted {
+		slog.Debug("Restricting browser DNS to allowed hosts", "hosts", c.hosts.Patterns())
+		// Behind the allowlist proxy, Chrome resolves no name at all
+		if c.proxy == "" && !c.untrusted {
+			resolvable = append(resolvable, c.hosts.Patterns()...)
+		}
+		restrictDNS = true
+	}
+	if c.untrusted {
+		opts = append(opts, chromedp.Flag("disable-popup-blocking", false))
+	}
+	execPath := c.execPath
+	switch c.headless {
+	case HeadlessNew, HeadlessOld:
+		slog.Debug("Starting Chrome in headless mode", "mode", c.headless)
+		opts = append(opts, chromedp.Flag("headless", c.headless))
+	case HeadlessShell:
+		if execPath == "" {
+			execPath = HeadlessShellExecutable
+		}
+		slog.Debug("Starting chrome-headless-shell", "executable", execPath)
+	}
+	if execPath != "" {
+		slog.Debug("Starting Chrome executable", "path", execPath)
+		opts = append(opts, chromedp.ExecPath(execPath))
+	}
+	if c.headful {
+		// Undo chromedp.Headless of the default flags
+		opts = append(opts,
+			chromedp.Flag("headless", false),
+			chromedp.Flag("hide-scrollbars", false),
+			chromedp.Flag("mute-audio", false),
+		)
+	}
+	if restrictDNS {
+		rules := "MAP * ~NOTFOUND"
+		for _, host := range resolvable {
+			rules += " , EXCLUDE " + host
+		}
+		opts = append(opts,
+			chromedp.Flag("host-resolver-rules", rules),
+			chromedp.Flag("force-webrtc-ip-handling-policy", "disable_non_proxied_udp"),
+		)
+	}
+	if len(c.caCerts) > 0 {
+		hashes := make([]string, 0, len(c.caCerts))
+		for _, cert := range c.caCerts {
+			sum := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+			hashes = append(hashes, base64.StdEncoding.EncodeToString(sum[:]))
+		}
+		slog.Debug("Trusting CA bundle in browser", "certificates", len(hashes))
+		// Honored because the allocator always passes --user-data-dir
+		opts = append(opts, chromedp.Flag("ignore-certificate-errors-spki-list", strings.Join(hashes, ",")))
+	}
+	if c.userDataDir != "" {
+		slog.Debug("Starting Chrome with profile", "dir", c.userDataDir)
+		ctx, cancel := chromedp.NewExecAllocator(parent, append(opts, chromedp.UserDataDir(c.userDataDir))...)
+		return ctx, func() { cancel(); stopProxy() }, nil
+	}
+	if c.profiles != "" {
+		profile, err := newProfileDir(c.profiles)
+		if err == nil {
+			slog.Debug("Starting Chrome with profile", "dir", profile)
+			ctx, cancel := chromedp.NewExecAllocator(parent, append(opts, chromedp.UserDataDir(profile))...)
+			return ctx, func() {
+				// Chrome has exited once cancel returns
+				cancel()
+				stopProxy()
+				if err := os.RemoveAll(profile); err != nil {
+					slog.Warn("failed to remove Chrome profile", "dir", profile, "error", err)
+				}
+			}, nil
+		}
+		slog.Warn("Failed to create Chrome profile, using a temporary one", "dir", c.profiles, "error", err)
+	}
+	ctx, cancel := chromedp.NewExecAllocator(parent, opts...)
+	return ctx, func() { cancel(); stopProxy() }, nil
+}
+
+// newProfileDir creates a profile directory in dir named after the
+// process.
+func newProfileDir(dir string) (string, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return "", err
+	}
+	return os.MkdirTemp(dir, fmt.Sprintf("%d-*", os.Getpid()))
+}
+
+// TabOption configures a tab opened by NewTab or Pool.Acquire.
+type TabOption func(*tabConfig)
+
+type tabConfig struct {
+	proxy   string
+	profile string
+	// browserContext is the browser context of profile, resolved by
+	// Pool.Acquire.
+	browserContext cdp.BrowserContextID
+}
+
+// WithTabProxy routes the tab's traffic through proxy, e.g.
+// http://host:3128 or socks5://host:1080, instead of the browser's. The tab
+// gets its own browser context, as with IsolateTabs.
+func WithTabProxy(proxy string) TabOption {
+	return func(c *tabConfig) {
+		c.proxy = proxy
+	}
+}
+
+// WithTabProfile opens the tab in the browser context of the named profile
+// of a Pool, created on first use: tabs of one profile share cookies,
+// storage and cache, which tabs of other profiles and the default context
+// never see. It only applies to Pool.Acquire and cannot be combined with
+// WithTabProxy.
+func WithTabProfile(name string) TabOption {
+	return func(c *tabConfig) {
+		c.profile = name
+	}
+}
+
+// newTabConfig applies opts.
+func newTabConfig(opts []TabOption) *tabConfig {
+	c := &tabConfig{}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// contextOptions returns the chromedp options creating a tab for opts.
+func (b *Browser) contextOptions(opts []TabOption) []chromedp.ContextOption {
+	c := newTabConfig(opts)
+	switch {
+	case c.browserContext != "":
+		return []chromedp.ContextOption{chromedp.WithExistingBrowserContext(c.browserContext)}
+	case c.proxy != "":
+		slog.Debug("Opening tab behind proxy", "proxy", c.proxy)
+		return []chromedp.ContextOption{chromedp.WithNewBrowserContext(
+			func(p *target.CreateBrowserContextParams) *target.CreateBrowserContextParams {
+				return p.WithProxyServer(c.proxy)
+			})}
+	case b.IsolateTabs:
+		return []chromedp.ContextOption{chromedp.WithNewBrowserContext()}
+	}
+	return nil
+}
@@ -0,0 +1,98 @@
+package chromedphelper
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+
+	"github.com/chromedp/chromedp"
+)
+
+// Layout is the geometry of a page at one moment: what a screenshot taken
+// right before shows and where elements are on it.
+type Layout struct {
+	// Page is the whole scrollable document, as captured by
+	// ScreenshotFullPage.
+	Page Box `json:"page"`
+	// Viewport is the visible part of the page, as captured by
+	// ScreenshotViewport.
+	Viewport Box `json:"viewport"`
+	// DevicePixelRatio is the number of image pixels per CSS pixel in
+	// screenshots.
+	DevicePixelRatio float64 `json:"devicePixelRatio"`
+	// Elements lists the elements matching each selector, by selector and
+	// then in document order.
+	Elements []LayoutElement `json:"elements"`
+}
+
+// LayoutElement is an element found by Layout.
+type LayoutElement struct {
+	// Selector is the selector that matched the element and Index its
+	// position among the selector's matches, counted from 0.
+	Selector string `json:"selector"`
+	Index    int    `json:"index"`
+	Tag      string `json:"tag"`
+	ID       string `json:"id,omitempty"`
+	// Text is the element's visible text or accessible name, shortened.
+	Text string `json:"text,omitempty"`
+	Box  Box    `json:"box"`
+}
+
+// layoutScript measures the page and every element matching the selectors
+// passed to it. Elements without a size are skipped, as ScreenshotEach
+// skips them.
+const layoutScript = `(selectors) => {
+	const elements = [];
+	for (const selector of selectors) {
+		let index = 0;
+		for (const el of document.querySelectorAll(selector)) {
+			const r = el.getBoundingClientRect();
+			if (r.width === 0 || r.height === 0) continue;
+			const text = (el.innerText || el.value || el.getAttribute('aria-label') || el.title || el.alt || '')
+				.replace(/\s+/g, ' ').trim().slice(0, 100);
+			elements.push({selector, index: index++, tag: el.localName, id: el.id, text,
+				box: {x: r.left + window.scrollX, y: r.top + window.scrollY, width: r.width, height: r.height}});
+		}
+	}
+	return {
+		page: {x: 0, y: 0,
+			width: Math.max(document.documentElement.scrollWidth, window.innerWidth),
+			height: Math.max(document.documentElement.scrollHeight, window.innerHeight)},
+		viewport: {x: window.scrollX, y: window.scrollY, width: window.innerWidth, height: window.innerHeight},
+		devicePixelRatio: window.devicePixelRatio,
+		elements,
+	};
+}`
+
+// Layout measures the page and the visible elements matching selectors,
+// with boxes in CSS pixels relative to the top-left corner of the document.
+// Call it right after a screenshot to learn where elements are on it.
+// Assumes NavigateAndPrepare has already been called.
+func (b *Browser) Layout(ctx context.Context, selectors []string) (*Layout, error) {
+	slog.Debug("Measuring page layout", "selectors", selectors)
+
+	arg, err := json.Marshal(selectors)
+	if err != nil {
+		return nil, err
+	}
+	var layout Layout
+	if err := b.run(ctx, chromedp.Evaluate(fmt.Sprintf("(%s)(%s)", layoutScript, arg), &layout)); err != nil {
+		slog.Error("Failed to measure page layout", "error", err)
+		return nil, err
+	}
+
+	slog.Debug("Page layout measured", "elements", len(layout.Elements))
+	return &layout, nil
+}
+
+// Of returns the elements matched by selector.
+func (l *Layout) Of(selector string) []LayoutElement {
+	var elements []LayoutElement
+	for _, e := range l.Elements {
+		if e.Selector == selector {
+			elements = append(elements, e)
+		}
+	}
+	return elements
+}
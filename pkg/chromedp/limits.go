@@ -0,0 +1,164 @@
+package chromedphelper
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+
+	"github.com/chromedp/cdproto/cdp"
+	"github.com/chromedp/cdproto/network"
+	"github.com/chromedp/cdproto/page"
+	"github.com/chromedp/chromedp"
+)
+
+// Kinds of Limit.
+const (
+	// LimitBytes caps the bytes a page transfers, MaxBytes.
+	LimitBytes = "bytes"
+	// LimitRequests caps the requests a page makes, MaxRequests.
+	LimitRequests = "requests"
+)
+
+// Limit is a cap a page went over.
+type Limit struct {
+	// Kind is LimitBytes or LimitRequests.
+	Kind string `json:"kind"`
+	Max  int64  `json:"max"`
+	// Used is how much the page had used when it went over.
+	Used int64 `json:"used"`
+}
+
+// LimitError is returned by every operation of a tab whose page went over
+// MaxBytes or MaxRequests, in place of the error aborting the load caused.
+type LimitError struct {
+	Limit
+}
+
+func (e *LimitError) Error() string {
+	if e.Kind == LimitRequests {
+		return fmt.Sprintf("page made more than %d requests", e.Max)
+	}
+	return fmt.Sprintf("page transferred more than %d bytes (%d so far)", e.Max, e.Used)
+}
+
+// limitWatch counts the requests and bytes of a page against its caps.
+// aborted is cancelled once a cap is exceeded, so operations waiting for
+// the page to load stop.
+type limitWatch struct {
+	mu          sync.Mutex
+	maxBytes    int64
+	maxRequests int64
+	bytes       int64
+	requests    int64
+	// chunks are the bytes counted so far per request, reconciled with
+	// the total reported when it finishes.
+	chunks   map[network.RequestID]int64
+	exceeded *Limit
+	aborted  context.Context
+	abort    context.CancelFunc
+}
+
+// reset starts counting against the caps, zero meaning none, for a tab
+// living as long as ctx.
+func (w *limitWatch) reset(ctx context.Context, maxBytes int64, maxRequests int) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.maxBytes, w.maxRequests = maxBytes, int64(maxRequests)
+	w.bytes, w.requests = 0, 0
+	w.chunks = make(map[network.RequestID]int64)
+	w.exceeded = nil
+	if w.abort != nil {
+		w.abort()
+	}
+	w.aborted, w.abort = context.WithCancel(ctx)
+}
+
+// done returns a context cancelled once a cap is exceeded.
+func (w *limitWatch) done() context.Context {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.aborted
+}
+
+// request counts a request about to be sent and reports whether it may
+// be, and whether it went over the request cap.
+func (w *limitWatch) request() (allowed, over bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.exceeded != nil {
+		return false, false
+	}
+	w.requests++
+	if w.maxRequests > 0 && w.requests > w.maxRequests {
+		w.exceed(LimitRequests, w.maxRequests, w.requests)
+		return false, true
+	}
+	return true, false
+}
+
+// received counts n bytes of a request's response. It reports whether
+// this went over the byte cap.
+func (w *limitWatch) received(id network.RequestID, n int64) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.chunks[id] += n
+	return w.add(n)
+}
+
+// finished reconciles the bytes counted for a request with its total, as
+// some responses report their size only at the end. It reports whether
+// this went over the byte cap.
+func (w *limitWatch) finished(id network.RequestID, total int64) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	counted := w.chunks[id]
+	delete(w.chunks, id)
+	return w.add(max(total-counted, 0))
+}
+
+func (w *limitWatch) add(n int64) bool {
+	w.bytes += n
+	if w.exceeded != nil || w.maxBytes <= 0 || w.bytes <= w.maxBytes {
+		return false
+	}
+	w.exceed(LimitBytes, w.maxBytes, w.bytes)
+	return true
+}
+
+func (w *limitWatch) exceed(kind string, limit, used int64) {
+	slog.Warn("Page exceeded its cap, aborting the load", "cap", kind, "max", limit, "used", used)
+	w.exceeded = &Limit{Kind: kind, Max: limit, Used: used}
+	if w.abort != nil {
+		w.abort()
+	}
+}
+
+// get returns a copy of the exceeded cap, or nil.
+func (w *limitWatch) get() *Limit {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.exceeded == nil {
+		return nil
+	}
+	l := *w.exceeded
+	return &l
+}
+
+// capped reports whether the tab has a cap.
+func (b *Browser) capped() bool {
+	return b.MaxBytes > 0 || b.MaxRequests > 0
+}
+
+// stopLoading aborts what the page is still loading once it went over a
+// cap. Requests it starts afterwards are failed by handleFetchEvent.
+// It runs outside the listener, which must not block on CDP calls.
+func (b *Browser) stopLoading() {
+	c := chromedp.FromContext(b.Ctx)
+	if c == nil || c.Target == nil {
+		return
+	}
+	if err := page.StopLoading().Do(cdp.WithExecutor(b.Ctx, c.Target)); err != nil && b.Ctx.Err() == nil {
+		slog.Warn("Failed to stop loading the page", "error", err)
+	}
+}
@@ -0,0 +1,52 @@
+package chromedphelper
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/chromedp/cdproto/emulation"
+	"github.com/chromedp/cdproto/network"
+	"github.com/chromedp/chromedp"
+)
+
+// extraHeaders returns Headers plus an Accept-Language header for Locale
+// and a From header for Googlebot, unless Headers already sets them.
+func (b *Browser) extraHeaders() network.Headers {
+	headers := make(network.Headers, len(b.Headers)+2)
+	for name, value := range b.Headers {
+		headers[name] = value
+	}
+	if b.Locale != "" && !b.hasHeader("Accept-Language") {
+		headers["Accept-Language"] = b.Locale
+	}
+	if b.Googlebot && !b.hasHeader("From") {
+		headers["From"] = GooglebotFrom
+	}
+	return headers
+}
+
+// hasHeader reports whether Headers sets the header name.
+func (b *Browser) hasHeader(name string) bool {
+	for have := range b.Headers {
+		if strings.EqualFold(have, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// localeAction makes the page format dates and numbers (Intl) for Locale.
+func (b *Browser) localeAction() chromedp.Action {
+	return chromedp.ActionFunc(func(ctx context.Context) error {
+		if b.Locale == "" {
+			return nil
+		}
+		slog.Debug("Overriding locale", "locale", b.Locale)
+		if err := emulation.SetLocaleOverride().WithLocale(b.Locale).Do(ctx); err != nil {
+			return fmt.Errorf("failed to override locale %q: %w", b.Locale, err)
+		}
+		return nil
+	})
+}
@@ -0,0 +1,75 @@
+package chromedphelper
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+
+	"github.com/chromedp/chromedp"
+)
+
+// maskScript blacks out the elements matching each selector and returns
+// the number of elements each matched. Style rules paint the elements and
+// everything in them black, also elements matching later; the text and
+// form values of the elements matching now are replaced by bullets of the
+// same length, so that it is not left in PDFs under the black boxes, nor
+// in extracted text.
+const maskScript = `(selectors) => {
+	const style = document.createElement('style');
+	style.id = '__that_mask';
+	(document.head || document.documentElement).appendChild(style);
+	const bullets = (s) => s.replace(/\S/g, '•');
+	let rules = '';
+	const counts = selectors.map((selector) => {
+		rules += selector + '{background:#000 !important;color:#000 !important;border-color:#000 !important;' +
+			'text-shadow:none !important;filter:brightness(0) !important}';
+		const elements = document.querySelectorAll(selector);
+		for (const el of elements) {
+			const walker = document.createTreeWalker(el, NodeFilter.SHOW_TEXT);
+			for (let node = walker.nextNode(); node; node = walker.nextNode()) {
+				node.data = bullets(node.data);
+			}
+			for (const field of [el, ...el.querySelectorAll('input, textarea')]) {
+				if (field.localName === 'input' || field.localName === 'textarea') {
+					field.value = bullets(field.value);
+					if (field.placeholder) field.placeholder = bullets(field.placeholder);
+				}
+			}
+			for (const attr of ['title', 'alt', 'aria-label']) {
+				for (const e of [el, ...el.querySelectorAll('[' + attr + ']')]) {
+					if (e.hasAttribute(attr)) e.setAttribute(attr, bullets(e.getAttribute(attr)));
+				}
+			}
+		}
+		return elements.length;
+	});
+	style.textContent = rules;
+	return counts;
+}`
+
+// maskAction blacks out the elements matching b.Mask.
+func (b *Browser) maskAction() chromedp.Action {
+	if len(b.Mask) == 0 {
+		return chromedp.Tasks{}
+	}
+	return chromedp.ActionFunc(func(ctx context.Context) error {
+		slog.Debug("Masking elements", "selectors", b.Mask)
+		arg, err := json.Marshal(b.Mask)
+		if err != nil {
+			return err
+		}
+		var counts []int
+		if err := chromedp.Evaluate(fmt.Sprintf("(%s)(%s)", maskScript, arg), &counts).Do(ctx); err != nil {
+			return fmt.Errorf("failed to mask elements: %w", err)
+		}
+		for i, selector := range b.Mask {
+			if counts[i] == 0 {
+				slog.Warn("No element to mask", "selector", selector)
+				continue
+			}
+			slog.Info("Elements masked", "selector", selector, "count", counts[i])
+		}
+		return nil
+	})
+}
@@ -0,0 +1,48 @@
+package chromedphelper
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/chromedp/chromedp"
+)
+
+// PageMetadata describes the loaded document.
+type PageMetadata struct {
+	// URL is the document's final URL after any redirects.
+	URL   string `json:"url"`
+	Title string `json:"title"`
+	// Canonical is the absolute rel=canonical URL, or "" if none is declared.
+	Canonical   string `json:"canonical"`
+	Description string `json:"description"`
+	Lang        string `json:"lang"`
+}
+
+// GetPageMetadata returns the final URL, title, canonical URL, meta
+// description and language of the current page.
+// Assumes NavigateAndPrepare has already been called.
+func (b *Browser) GetPageMetadata(ctx context.Context) (*PageMetadata, error) {
+	slog.Debug("Getting page metadata")
+
+	var meta PageMetadata
+	err := b.run(ctx,
+		chromedp.Evaluate(`(() => {
+			const canonical = document.querySelector('link[rel~="canonical" i][href]');
+			const description = document.querySelector('meta[name="description" i]');
+			return {
+				url: location.href,
+				title: document.title,
+				canonical: canonical ? canonical.href : '',
+				description: description ? (description.getAttribute('content') || '').trim() : '',
+				lang: document.documentElement.lang || ''
+			};
+		})()`, &meta),
+	)
+	if err != nil {
+		slog.Error("Failed to get page metadata", "error", err)
+		return nil, err
+	}
+
+	slog.Debug("Page metadata retrieved successfully", "url", meta.URL, "canonical", meta.Canonical)
+	return &meta, nil
+}
@@ -0,0 +1,95 @@
+package chromedphelper
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+
+	"github.com/chromedp/cdproto/runtime"
+	"github.com/chromedp/chromedp"
+)
+
+// PageMetrics are the measurements of a loaded page that --fail-if
+// conditions read. Times are in milliseconds from navigation start, 0 when
+// the browser did not report them.
+type PageMetrics struct {
+	Status int     `json:"status"`
+	LoadMs float64 `json:"loadMs"`
+	FCPMs  float64 `json:"fcpMs"`
+	LCPMs  float64 `json:"lcpMs"`
+	// CLS is the Cumulative Layout Shift so far: the layout shifts not
+	// caused by input, summed.
+	CLS           float64 `json:"cls"`
+	DOMNodes      int     `json:"domNodes"`
+	Resources     int     `json:"resources"`
+	TransferBytes int64   `json:"transferBytes"`
+	// Selectors counts the elements matching each selector asked for, -1
+	// for invalid selectors.
+	Selectors map[string]int `json:"selectors,omitempty"`
+}
+
+// ConditionResult is the outcome of a --fail-if condition.
+type ConditionResult struct {
+	Expression string `json:"expression"`
+	Matched    bool   `json:"matched"`
+	// Values are those of the variables the condition reads.
+	Values map[string]float64 `json:"values,omitempty"`
+}
+
+const metricsScript = `async (selectors) => {
+	const buffered = (type) => new Promise((resolve) => {
+		setTimeout(() => resolve([]), 500);
+		try {
+			new PerformanceObserver((list, observer) => {
+				observer.disconnect();
+				resolve(list.getEntries());
+			}).observe({type, buffered: true});
+		} catch (e) {
+			resolve([]);
+		}
+	});
+	const [lcp, shifts] = await Promise.all([buffered('largest-contentful-paint'), buffered('layout-shift')]);
+	const nav = performance.getEntriesByType('navigation')[0];
+	const fcp = performance.getEntriesByName('first-contentful-paint')[0];
+	const resources = performance.getEntriesByType('resource');
+	const counts = {};
+	for (const s of selectors) {
+		try {
+			counts[s] = document.querySelectorAll(s).length;
+		} catch (e) {
+			counts[s] = -1;
+		}
+	}
+	return {
+		status: nav && nav.responseStatus || 0,
+		loadMs: nav && nav.loadEventEnd > 0 ? nav.loadEventEnd - nav.startTime : 0,
+		fcpMs: fcp ? fcp.startTime : 0,
+		lcpMs: lcp.length ? lcp[lcp.length - 1].startTime : 0,
+		cls: shifts.filter((s) => !s.hadRecentInput).reduce((sum, s) => sum + s.value, 0),
+		domNodes: document.getElementsByTagName('*').length,
+		resources: resources.length,
+		transferBytes: resources.reduce((sum, r) => sum + (r.transferSize || 0), (nav && nav.transferSize) || 0),
+		selectors: counts,
+	};
+}`
+
+// Metrics measures the page and counts the elements matching selectors.
+// Assumes NavigateAndPrepare has already been called.
+func (b *Browser) Metrics(ctx context.Context, selectors []string) (*PageMetrics, error) {
+	slog.Debug("Measuring page", "selectors", len(selectors))
+	args, err := json.Marshal(selectors)
+	if err != nil {
+		return nil, err
+	}
+	var m PageMetrics
+	err = b.run(ctx, chromedp.Evaluate(fmt.Sprintf("(%s)(%s)", metricsScript, args), &m, func(p *runtime.EvaluateParams) *runtime.EvaluateParams {
+		return p.WithAwaitPromise(true)
+	}))
+	if err != nil {
+		slog.Error("Failed to measure page", "error", err)
+		return nil, fmt.Errorf("failed to measure page: %w", err)
+	}
+	slog.Debug("Page measured", "status", m.Status, "loadMs", m.LoadMs, "lcpMs", m.LCPMs, "cls", m.CLS)
+	return &m, nil
+}
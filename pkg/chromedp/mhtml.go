@@ -0,0 +1,33 @@
+package chromedphelper
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/chromedp/cdproto/page"
+	"github.com/chromedp/chromedp"
+)
+
+// CaptureMHTML returns the current page as an MHTML archive: the rendered
+// document with its stylesheets, images and frames in one file, which
+// browsers open offline.
+// Assumes NavigateAndPrepare has already been called.
+func (b *Browser) CaptureMHTML(ctx context.Context) ([]byte, error) {
+	slog.Debug("Capturing MHTML")
+
+	var data string
+	err := b.run(ctx,
+		chromedp.ActionFunc(func(ctx context.Context) error {
+			var err error
+			data, err = page.CaptureSnapshot().WithFormat(page.CaptureSnapshotFormatMhtml).Do(ctx)
+			return err
+		}),
+	)
+	if err != nil {
+		slog.Error("Failed to capture MHTML", "error", err)
+		return nil, err
+	}
+
+	slog.Debug("MHTML captured successfully", "size", len(data))
+	return []byte(data), nil
+}
@@ -0,0 +1,253 @@
+package chromedphelper
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/chromedp/cdproto/cdp"
+	"github.com/chromedp/cdproto/page"
+	"github.com/chromedp/cdproto/runtime"
+	"github.com/chromedp/chromedp"
+)
+
+// Named milestones of a page load.
+const (
+	// MilestoneFCP is the first contentful paint: the first time text, an
+	// image or a canvas is painted.
+	MilestoneFCP = "fcp"
+	// MilestoneLCP is the largest contentful paint: the paint of the
+	// largest text block or image of the first screen, as far as the page
+	// got before it was prepared.
+	MilestoneLCP = "lcp"
+	// MilestoneLoad is the load event.
+	MilestoneLoad = "load"
+)
+
+// lifecycleNames maps named milestones to the Page.lifecycleEvent that
+// marks them. LCP has none and is reported by the page itself.
+var lifecycleNames = map[string]string{
+	MilestoneFCP:  "firstContentfulPaint",
+	MilestoneLoad: "load",
+}
+
+// lcpBinding is the function through which lcpScript reports LCP
+// candidates.
+const lcpBinding = "__thatCliWebToolboxLCP"
+
+// lcpScript reports every Largest Contentful Paint candidate of the page as
+// it is painted.
+const lcpScript = `new PerformanceObserver((list) => {
+	for (const entry of list.getEntries()) ` + lcpBinding + `(String(entry.startTime));
+}).observe({type: 'largest-contentful-paint', buffered: true});`
+
+// Milestone is a moment of a page load: a named milestone, or a time after
+// navigation started.
+type Milestone struct {
+	// Name is MilestoneFCP, MilestoneLCP or MilestoneLoad, or empty.
+	Name string
+	// After is the time since navigation started, when Name is empty.
+	After time.Duration
+}
+
+// ParseMilestone returns the milestone named by s: fcp, lcp, load, or a
+// duration after navigation started such as +3s or +500ms.
+func ParseMilestone(s string) (Milestone, error) {
+	name := strings.ToLower(strings.TrimSpace(s))
+	if _, ok := lifecycleNames[name]; ok || name == MilestoneLCP {
+		return Milestone{Name: name}, nil
+	}
+	if rest, ok := strings.CutPrefix(name, "+"); ok {
+		if d, err := time.ParseDuration(rest); err == nil && d > 0 {
+			return Milestone{After: d}, nil
+		}
+	}
+	return Milestone{}, fmt.Errorf("invalid milestone %q (expected fcp, lcp, load or a duration after navigation such as +3s)", s)
+}
+
+func (m Milestone) String() string {
+	if m.Name != "" {
+		return m.Name
+	}
+	return "+" + m.After.String()
+}
+
+// MilestoneShot is a screenshot NavigateAndPrepare takes when the page
+// reaches a milestone.
+type MilestoneShot struct {
+	At Milestone
+	// FullPage captures the whole page, as it is laid out at that moment,
+	// rather than the viewport.
+	FullPage bool
+	Options  ScreenshotOptions
+}
+
+// milestoneWatch takes the ScreenshotAt screenshot of the current page
+// load.
+type milestoneWatch struct {
+	mu      sync.Mutex
+	shot    *MilestoneShot
+	reached bool
+	timer   *time.Timer
+	// fired is closed once the timer of an After milestone started its
+	// capture.
+	fired chan struct{}
+	// seq numbers captures as they start; kept is the number of the one
+	// image and err come from, so a late LCP candidate's capture is never
+	// replaced by an earlier one finishing after it, nor the page load's by
+	// one of the previous load. base is seq when the load started.
+	seq, kept, base int
+	// pending counts captures in flight; idle is signalled as they finish.
+	pending int
+	idle    *sync.Cond
+	image   []byte
+	err     error
+}
+
+// reset starts watching a page load for shot, which may be nil.
+func (w *milestoneWatch) reset(shot *MilestoneShot) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.timer != nil {
+		w.timer.Stop()
+	}
+	if w.idle == nil {
+		w.idle = sync.NewCond(&w.mu)
+	}
+	w.shot, w.reached, w.timer = shot, false, nil
+	w.fired = make(chan struct{})
+	w.kept, w.base = w.seq, w.seq
+	w.image, w.err = nil, nil
+}
+
+// lifecycle tells whether the main frame's lifecycle event name is the
+// named milestone being watched for, reached for the first time.
+func (w *milestoneWatch) lifecycle(name string) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.shot == nil || w.reached || lifecycleNames[w.shot.At.Name] != name {
+		return false
+	}
+	w.reached = true
+	return true
+}
+
+// lcpCandidate tells whether an LCP candidate is being watched for. Every
+// candidate is captured, and the last one wins.
+func (w *milestoneWatch) lcpCandidate() bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.shot != nil && w.shot.At.Name == MilestoneLCP
+}
+
+// milestoneAction prepares the page load for the ScreenshotAt milestone:
+// it installs the LCP observer, or starts the timer of a milestone after
+// navigation started. It runs right before navigation.
+func (b *Browser) milestoneAction() chromedp.Action {
+	return chromedp.ActionFunc(func(ctx context.Context) error {
+		// The lock is not held while talking to the tab: the listener that
+		// receives its events takes it
+		w := &b.milestones
+		w.mu.Lock()
+		shot, fired := w.shot, w.fired
+		w.mu.Unlock()
+		if shot == nil {
+			return nil
+		}
+		slog.Debug("Watching for screenshot milestone", "milestone", shot.At)
+		if shot.At.Name == MilestoneLCP {
+			if err := runtime.AddBinding(lcpBinding).Do(ctx); err != nil {
+				return fmt.Errorf("failed to watch for LCP: %w", err)
+			}
+			if _, err := page.AddScriptToEvaluateOnNewDocument(lcpScript).Do(ctx); err != nil {
+				return fmt.Errorf("failed to watch for LCP: %w", err)
+			}
+		}
+		if shot.At.After > 0 {
+			w.mu.Lock()
+			defer w.mu.Unlock()
+			w.timer = time.AfterFunc(shot.At.After, func() {
+				b.captureMilestone()
+				close(fired)
+			})
+		}
+		return nil
+	})
+}
+
+// captureMilestone starts capturing the ScreenshotAt screenshot. It runs
+// next to whatever NavigateAndPrepare is doing, so it goes to the tab
+// directly rather than through run.
+func (b *Browser) captureMilestone() {
+	w := &b.milestones
+	w.mu.Lock()
+	shot := w.shot
+	w.seq++
+	seq := w.seq
+	w.pending++
+	w.mu.Unlock()
+
+	go func() {
+		image, err := b.milestoneScreenshot(shot)
+		if err != nil {
+			slog.Warn("Failed to take milestone screenshot", "milestone", shot.At, "error", err)
+		} else {
+			slog.Debug("Milestone screenshot captured", "milestone", shot.At, "size", len(image))
+		}
+		w.mu.Lock()
+		defer w.mu.Unlock()
+		if seq > w.kept {
+			w.kept, w.image, w.err = seq, image, err
+		}
+		w.pending--
+		w.idle.Broadcast()
+	}()
+}
+
+func (b *Browser) milestoneScreenshot(shot *MilestoneShot) ([]byte, error) {
+	c := chromedp.FromContext(b.Ctx)
+	if c == nil || c.Target == nil {
+		return nil, errors.New("no tab to capture")
+	}
+	ctx := cdp.WithExecutor(b.Ctx, c.Target)
+	if shot.FullPage {
+		return screenshotFullPage(ctx, shot.Options)
+	}
+	return shot.Options.capture(ctx, nil)
+}
+
+// MilestoneScreenshot returns the screenshot NavigateAndPrepare took at
+// ScreenshotAt. A milestone after navigation started that lies beyond the
+// end of NavigateAndPrepare is waited for; for MilestoneLCP, it is the
+// screenshot of the last candidate painted until then. A page that never
+// reached the milestone, such as a page without content and FCP, fails.
+func (b *Browser) MilestoneScreenshot(ctx context.Context) ([]byte, error) {
+	w := &b.milestones
+	w.mu.Lock()
+	shot, fired := w.shot, w.fired
+	w.mu.Unlock()
+	if shot == nil {
+		return nil, errors.New("no milestone screenshot was requested")
+	}
+	if shot.At.After > 0 {
+		select {
+		case <-fired:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for w.pending > 0 {
+		w.idle.Wait()
+	}
+	if w.kept == w.base {
+		return nil, fmt.Errorf("page never reached %s", shot.At)
+	}
+	return w.image, w.err
+}
@@ -0,0 +1,178 @@
+package chromedphelper
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"github.com/chromedp/cdproto/emulation"
+	"github.com/chromedp/chromedp"
+)
+
+// maxContentItems bounds the text blocks, links and images PageContent
+// lists of each kind.
+const maxContentItems = 2000
+
+// PageContent is what a crawler indexes of a page: its title, meta
+// description, headings, text blocks, links and images.
+type PageContent struct {
+	Title       string   `json:"title"`
+	Description string   `json:"description,omitempty"`
+	Headings    []string `json:"headings"`
+	// Text are the visible text blocks, without the headings.
+	Text   []string `json:"text"`
+	Links  []string `json:"links"`
+	Images []string `json:"images"`
+	Words  int      `json:"words"`
+}
+
+// NoJSComparison is what a page shows with JavaScript that it does not
+// without, as crawlers that do not run JavaScript see it.
+type NoJSComparison struct {
+	// WithJS and WithoutJS count the content of both loads.
+	WithJS    ContentCounts `json:"withJs"`
+	WithoutJS ContentCounts `json:"withoutJs"`
+	// Title and Description are set when they differ without JavaScript,
+	// to the value without it.
+	Title       *string `json:"title,omitempty"`
+	Description *string `json:"description,omitempty"`
+	// JSOnly is the content only present with JavaScript.
+	JSOnly ContentDiff `json:"jsOnly"`
+	// NoJSOnly is the content only present without JavaScript, such as
+	// noscript notices.
+	NoJSOnly ContentDiff `json:"noJsOnly"`
+	// JSOnlyWords is the share of the words that only JavaScript renders,
+	// from 0 to 1.
+	JSOnlyWords float64 `json:"jsOnlyWords"`
+}
+
+// ContentCounts counts the content of a PageContent.
+type ContentCounts struct {
+	Headings int `json:"headings"`
+	Text     int `json:"text"`
+	Links    int `json:"links"`
+	Images   int `json:"images"`
+	Words    int `json:"words"`
+}
+
+// ContentDiff is the content of one load missing from the other.
+type ContentDiff struct {
+	Headings []string `json:"headings,omitempty"`
+	Text     []string `json:"text,omitempty"`
+	Links    []string `json:"links,omitempty"`
+	Images   []string `json:"images,omitempty"`
+}
+
+// Empty reports whether d lists nothing.
+func (d ContentDiff) Empty() bool {
+	return len(d.Headings) == 0 && len(d.Text) == 0 && len(d.Links) == 0 && len(d.Images) == 0
+}
+
+// pageContentScript lists the content of the page a crawler indexes. Text
+// blocks are the lines of the visible text that are not headings.
+const pageContentScript = `(limit) => {
+	const clean = (s) => (s || '').replace(/\s+/g, ' ').trim();
+	const unique = (list) => [...new Set(list.filter(Boolean))].slice(0, limit);
+	const description = document.querySelector('meta[name="description" i]');
+	const headings = [...document.querySelectorAll('h1, h2, h3, h4, h5, h6')].map((h) => clean(h.innerText));
+	const headingSet = new Set(headings);
+	const body = document.body ? document.body.innerText : '';
+	const text = body.split('\n').map(clean).filter((line) => line && !headingSet.has(line));
+	const links = [...document.querySelectorAll('a[href]')].map((a) => a.href).filter((href) => /^https?:/.test(href)).map((href) => href.split('#')[0]);
+	const images = [...document.querySelectorAll('img')].map((img) => img.currentSrc || img.src).filter((src) => /^https?:/.test(src));
+	return {
+		title: document.title,
+		description: description ? clean(description.content) : '',
+		headings: unique(headings),
+		text: unique(text),
+		links: unique(links),
+		images: unique(images),
+		words: clean(body).split(' ').filter(Boolean).length,
+	};
+}`
+
+// PageContent lists what a crawler indexes of the page.
+// Assumes NavigateAndPrepare has already been called.
+func (b *Browser) PageContent(ctx context.Context) (*PageContent, error) {
+	var c PageContent
+	if err := b.run(ctx, chromedp.Evaluate(fmt.Sprintf("(%s)(%d)", pageContentScript, maxContentItems), &c)); err != nil {
+		slog.Error("Failed to list page content", "error", err)
+		return nil, err
+	}
+	return &c, nil
+}
+
+// NoJSTab opens a tab that loads b's target with JavaScript disabled, as
+// crawlers without JavaScript do: without b's custom JS, steps, ready
+// strategy and delay, which need the page's scripts. The caller
+// navigates it with NavigateAndPrepare and closes it with Cancel.
+func (b *Browser) NoJSTab(ctx context.Context) (*Browser, error) {
+	tab, err := b.NewTab(ctx)
+	if err != nil {
+		return nil, err
+	}
+	tab.TargetURL = b.TargetURL
+	tab.JSCode = ""
+	tab.Steps = nil
+	tab.Delay = 0
+	tab.ScreenshotAt = nil
+	tab.noJS = true
+	return tab, nil
+}
+
+// noJSAction disables the page's scripts for a NoJSTab. Evaluating the
+// tool's own scripts keeps working.
+func (b *Browser) noJSAction() chromedp.Action {
+	if !b.noJS {
+		return chromedp.Tasks{}
+	}
+	return emulation.SetScriptExecutionDisabled(true)
+}
+
+// CompareNoJS compares the content of withJS, the page as rendered, with
+// withoutJS, the same page loaded by a NoJSTab.
+func CompareNoJS(withJS, withoutJS *PageContent) *NoJSComparison {
+	c := &NoJSComparison{
+		WithJS:    withJS.counts(),
+		WithoutJS: withoutJS.counts(),
+		JSOnly:    contentDiff(withJS, withoutJS),
+		NoJSOnly:  contentDiff(withoutJS, withJS),
+	}
+	if withJS.Title != withoutJS.Title {
+		c.Title = &withoutJS.Title
+	}
+	if withJS.Description != withoutJS.Description {
+		c.Description = &withoutJS.Description
+	}
+	if withJS.Words > 0 {
+		c.JSOnlyWords = float64(max(0, withJS.Words-withoutJS.Words)) / float64(withJS.Words)
+	}
+	return c
+}
+
+func (c *PageContent) counts() ContentCounts {
+	return ContentCounts{Headings: len(c.Headings), Text: len(c.Text), Links: len(c.Links), Images: len(c.Images), Words: c.Words}
+}
+
+// contentDiff returns the content of a missing from b.
+func contentDiff(a, b *PageContent) ContentDiff {
+	missing := func(have, other []string) []string {
+		seen := make(map[string]bool, len(other))
+		for _, s := range other {
+			seen[s] = true
+		}
+		var out []string
+		for _, s := range have {
+			if !seen[s] {
+				out = append(out, s)
+			}
+		}
+		return out
+	}
+	return ContentDiff{
+		Headings: missing(a.Headings, b.Headings),
+		Text:     missing(a.Text, b.Text),
+		Links:    missing(a.Links, b.Links),
+		Images:   missing(a.Images, b.Images),
+	}
+}
@@ -0,0 +1,360 @@
+package chromedphelper
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/chromedp/chromedp"
+)
+
+// maxOutlineBlocks bounds the blocks Outline reads, for pages such as
+// long documentation.
+const maxOutlineBlocks = 5000
+
+// Kinds of OutlineNode.
+const (
+	OutlineSection   = "section"
+	OutlineParagraph = "paragraph"
+	OutlineList      = "list"
+	OutlineItem      = "item"
+	OutlineQuote     = "quote"
+	OutlineCode      = "code"
+	OutlineTable     = "table"
+)
+
+// Outline is the visible text of a page with its structure: sections
+// nested by their headings, with the paragraphs, lists, quotes, code
+// blocks and tables in them.
+type Outline struct {
+	Title string `json:"title,omitempty"`
+	// Nodes are the sections of the top level and the content before the
+	// first heading.
+	Nodes []*OutlineNode `json:"nodes"`
+	// Truncated is set when the page had more blocks than the outline
+	// holds.
+	Truncated bool `json:"truncated,omitempty"`
+}
+
+// OutlineNode is a block of an Outline. Text is the block's inline text,
+// with links written as [text](URL) and inline code as `code`.
+type OutlineNode struct {
+	Kind string `json:"kind"`
+	// Level is the heading level of a section, 1 to 6.
+	Level int `json:"level,omitempty"`
+	// Text is a section's heading, or the text of a paragraph, quote,
+	// item or code block.
+	Text string `json:"text,omitempty"`
+	// Ordered is set for numbered lists.
+	Ordered bool `json:"ordered,omitempty"`
+	// Rows are the cells of a table, the header row first if it has one.
+	Rows [][]string `json:"rows,omitempty"`
+	// Children are a section's blocks, a list's items, or an item's
+	// nested lists.
+	Children []*OutlineNode `json:"children,omitempty"`
+}
+
+// outlineBlock is a block as read from the page, in document order;
+// headings are only nested into sections afterwards.
+type outlineBlock struct {
+	Kind    string         `json:"kind"`
+	Level   int            `json:"level"`
+	Text    string         `json:"text"`
+	Ordered bool           `json:"ordered"`
+	Rows    [][]string     `json:"rows"`
+	Items   []*outlineItem `json:"items"`
+}
+
+type outlineItem struct {
+	Text  string          `json:"text"`
+	Lists []*outlineBlock `json:"lists"`
+}
+
+// outlineScript reads the visible blocks of the page in document order:
+// headings, paragraphs, lists, quotes, code blocks and tables, and the
+// text of other block elements as paragraphs.
+const outlineScript = `(limit, lang) => {
+	const inLang = lang ? (` + langMatchScript + `)(lang) : () => true;
+	const skip = new Set(['script', 'style', 'noscript', 'template', 'svg', 'canvas', 'iframe', 'object', 'head', 'select', 'button', 'textarea']);
+	const hidden = (el) => {
+		if (el.hasAttribute('hidden') || el.getAttribute('aria-hidden') === 'true') return true;
+		const style = getComputedStyle(el);
+		return style.display === 'none' || style.visibility === 'hidden';
+	};
+	const isBlock = (el) => {
+		const display = getComputedStyle(el).display;
+		return !display.startsWith('inline') && display !== 'contents';
+	};
+	const clean = (s) => s.replace(/\s+/g, ' ').trim();
+
+	// inline writes the text below node, links as [text](URL), skipping
+	// the elements of except.
+	const inline = (node, except) => {
+		let out = '';
+		for (const child of node.childNodes) {
+			if (child.nodeType === Node.TEXT_NODE) {
+				if (inLang(child.parentElement)) out += child.textContent;
+				continue;
+			}
+			if (child.nodeType !== Node.ELEMENT_NODE || skip.has(child.localName) || hidden(child)) continue;
+			if (except && except(child)) continue;
+			if (child.localName === 'br') {
+				out += ' ';
+			} else if (child.localName === 'img') {
+				if (child.alt && inLang(child)) out += ' ' + child.alt + ' ';
+			} else if (child.localName === 'a' && child.href && !child.href.startsWith('javascript:')) {
+				const text = clean(inline(child));
+				out += text ? '[' + text + '](' + child.href + ')' : '';
+			} else if (child.localName === 'code' && !child.closest('pre')) {
+				const text = inLang(child) ? clean(child.textContent) : '';
+				out += text ? '` + "`" + `' + text + '` + "`" + `' : '';
+			} else {
+				const text = inline(child, except);
+				out += isBlock(child) ? ' ' + text + ' ' : text;
+			}
+		}
+		return out;
+	};
+
+	const blocks = [];
+	let truncated = false;
+	const push = (block) => {
+		if (blocks.length === limit) {
+			truncated = true;
+			return false;
+		}
+		blocks.push(block);
+		return true;
+	};
+	const isList = (el) => el.localName === 'ul' || el.localName === 'ol';
+	const list = (el) => {
+		const items = [];
+		for (const li of el.children) {
+			if (li.localName !== 'li' || hidden(li)) continue;
+			const lists = [...li.querySelectorAll('ul, ol')].filter((l) => l.parentElement.closest('li') === li && !hidden(l)).map(list).filter((l) => l.items.length);
+			const text = clean(inline(li, isList));
+			if (text || lists.length) items.push({text, lists});
+		}
+		return {kind: 'list', ordered: el.localName === 'ol', items};
+	};
+	const table = (el) => {
+		const rows = [];
+		for (const tr of el.rows) {
+			if (hidden(tr)) continue;
+			const cells = [...tr.cells].map((cell) => clean(inline(cell)));
+			if (cells.some(Boolean)) rows.push(cells);
+		}
+		return {kind: 'table', rows};
+	};
+
+	// walk adds the blocks below el, collecting its inline content into
+	// paragraphs between its block children.
+	const walk = (el) => {
+		let run = [];
+		const flush = () => {
+			const text = clean(run.map((n) => inline({childNodes: [n]})).join(''));
+			run = [];
+			return !text || push({kind: 'paragraph', text});
+		};
+		for (const child of el.childNodes) {
+			if (child.nodeType === Node.TEXT_NODE) {
+				run.push(child);
+				continue;
+			}
+			if (child.nodeType !== Node.ELEMENT_NODE || skip.has(child.localName) || hidden(child)) continue;
+			if (!isBlock(child) && !/^(h[1-6]|ul|ol|pre|blockquote|table)$/.test(child.localName)) {
+				run.push(child);
+				continue;
+			}
+			if (!flush()) return false;
+			let ok = true;
+			const name = child.localName;
+			if (/^h[1-6]$/.test(name)) {
+				const text = clean(inline(child));
+				if (text) ok = push({kind: 'heading', level: Number(name[1]), text});
+			} else if (child.getAttribute('role') === 'heading') {
+				const text = clean(inline(child));
+				if (text) ok = push({kind: 'heading', level: Number(child.getAttribute('aria-level')) || 2, text});
+			} else if (isList(child)) {
+				const l = list(child);
+				if (l.items.length) ok = push(l);
+			} else if (name === 'pre') {
+				const text = inLang(child) ? child.textContent.replace(/^\n+|\s+$/g, '') : '';
+				if (text) ok = push({kind: 'code', text});
+			} else if (name === 'blockquote') {
+				const text = clean(inline(child));
+				if (text) ok = push({kind: 'quote', text});
+			} else if (name === 'table') {
+				const t = table(child);
+				if (t.rows.length) ok = push(t);
+			} else if (name === 'p') {
+				const text = clean(inline(child));
+				if (text) ok = push({kind: 'paragraph', text});
+			} else {
+				ok = walk(child);
+			}
+			if (!ok) return false;
+		}
+		return flush();
+	};
+	walk(document.body || document.documentElement);
+	return {title: document.title, blocks, truncated};
+}`
+
+// Outline reads the visible text of the page with its structure, see
+// Outline, only of OnlyLang if set. Hidden elements, scripts and form
+// controls are left out.
+// Assumes NavigateAndPrepare has already been called.
+func (b *Browser) Outline(ctx context.Context) (*Outline, error) {
+	slog.Debug("Reading page outline")
+
+	var page struct {
+		Title     string          `json:"title"`
+		Blocks    []*outlineBlock `json:"blocks"`
+		Truncated bool            `json:"truncated"`
+	}
+	lang, err := json.Marshal(b.OnlyLang)
+	if err != nil {
+		return nil, err
+	}
+	if err := b.run(ctx, chromedp.Evaluate(fmt.Sprintf("(%s)(%d, %s)", outlineScript, maxOutlineBlocks, lang), &page)); err != nil {
+		slog.Error("Failed to read page outline", "error", err)
+		return nil, err
+	}
+	o := &Outline{Title: page.Title, Nodes: nestOutline(page.Blocks), Truncated: page.Truncated}
+
+	slog.Debug("Page outline read", "blocks", len(page.Blocks), "truncated", page.Truncated)
+	return o, nil
+}
+
+// nestOutline turns blocks into a tree: each heading starts a section
+// holding the blocks up to the next heading of the same or a higher
+// level.
+func nestOutline(blocks []*outlineBlock) []*OutlineNode {
+	var nodes []*OutlineNode
+	var open []*OutlineNode
+	add := func(n *OutlineNode) {
+		if len(open) == 0 {
+			nodes = append(nodes, n)
+			return
+		}
+		parent := open[len(open)-1]
+		parent.Children = append(parent.Children, n)
+	}
+	for _, b := range blocks {
+		if b.Kind == "heading" {
+			for len(open) > 0 && open[len(open)-1].Level >= b.Level {
+				open = open[:len(open)-1]
+			}
+			section := &OutlineNode{Kind: OutlineSection, Level: b.Level, Text: b.Text}
+			add(section)
+			open = append(open, section)
+			continue
+		}
+		add(outlineNode(b))
+	}
+	if nodes == nil {
+		nodes = []*OutlineNode{}
+	}
+	return nodes
+}
+
+// outlineNode converts a block other than a heading.
+func outlineNode(b *outlineBlock) *OutlineNode {
+	n := &OutlineNode{Kind: b.Kind, Text: b.Text, Ordered: b.Ordered, Rows: b.Rows}
+	for _, item := range b.Items {
+		child := &OutlineNode{Kind: OutlineItem, Text: item.Text}
+		for _, l := range item.Lists {
+			child.Children = append(child.Children, outlineNode(l))
+		}
+		n.Children = append(n.Children, child)
+	}
+	return n
+}
+
+// Walk calls fn for every node of o, parents before their children.
+func (o *Outline) Walk(fn func(*OutlineNode)) {
+	var walk func([]*OutlineNode)
+	walk = func(nodes []*OutlineNode) {
+		for _, n := range nodes {
+			fn(n)
+			walk(n.Children)
+		}
+	}
+	walk(o.Nodes)
+}
+
+// Markdown renders o as Markdown: sections as # headings, lists indented
+// by nesting, quotes as > lines, code blocks fenced and tables as pipe
+// tables.
+func (o *Outline) Markdown() string {
+	var sb strings.Builder
+	for _, n := range o.Nodes {
+		writeOutlineNode(&sb, n)
+	}
+	if o.Truncated {
+		sb.WriteString("...\n")
+	}
+	return strings.TrimRight(sb.String(), "\n")
+}
+
+func writeOutlineNode(sb *strings.Builder, n *OutlineNode) {
+	switch n.Kind {
+	case OutlineSection:
+		fmt.Fprintf(sb, "%s %s\n\n", strings.Repeat("#", n.Level), n.Text)
+		for _, child := range n.Children {
+			writeOutlineNode(sb, child)
+		}
+	case OutlineList:
+		writeOutlineList(sb, n, "")
+		sb.WriteString("\n")
+	case OutlineQuote:
+		fmt.Fprintf(sb, "> %s\n\n", n.Text)
+	case OutlineCode:
+		fence := "```"
+		for strings.Contains(n.Text, fence) {
+			fence += "`"
+		}
+		fmt.Fprintf(sb, "%s\n%s\n%s\n\n", fence, n.Text, fence)
+	case OutlineTable:
+		if len(n.Rows) == 0 {
+			return
+		}
+		columns := 0
+		for _, row := range n.Rows {
+			columns = max(columns, len(row))
+		}
+		for i, row := range n.Rows {
+			cells := make([]string, columns)
+			for j := range cells {
+				if j < len(row) {
+					cells[j] = strings.ReplaceAll(row[j], "|", `\|`)
+				}
+			}
+			fmt.Fprintf(sb, "| %s |\n", strings.Join(cells, " | "))
+			if i == 0 {
+				fmt.Fprintf(sb, "|%s\n", strings.Repeat(" --- |", columns))
+			}
+		}
+		sb.WriteString("\n")
+	default:
+		fmt.Fprintf(sb, "%s\n\n", n.Text)
+	}
+}
+
+// writeOutlineList writes the items of a list, and their nested lists
+// indented below them.
+func writeOutlineList(sb *strings.Builder, list *OutlineNode, indent string) {
+	for i, item := range list.Children {
+		marker := "- "
+		if list.Ordered {
+			marker = fmt.Sprintf("%d. ", i+1)
+		}
+		fmt.Fprintf(sb, "%s%s%s\n", indent, marker, item.Text)
+		for _, nested := range item.Children {
+			writeOutlineList(sb, nested, indent+strings.Repeat(" ", len(marker)))
+		}
+	}
+}
@@ -0,0 +1,122 @@
+package chromedphelper
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/chromedp/chromedp"
+)
+
+// Kinds of Overlay.
+const (
+	OverlayConsent = "consent"
+	OverlayChat    = "chat"
+	OverlayModal   = "modal"
+	OverlayHeader  = "header"
+	OverlayFooter  = "footer"
+	OverlayOther   = "other"
+)
+
+// OverlayReport is how much of the first screen fixed and sticky elements
+// cover, such as cookie banners, modals and chat widgets.
+type OverlayReport struct {
+	Viewport Box `json:"viewport"`
+	// Coverage is the share of the viewport the overlays cover together,
+	// from 0 to 1, overlapping overlays counting once.
+	Coverage float64   `json:"coverage"`
+	Overlays []Overlay `json:"overlays"`
+}
+
+// Overlay is a fixed or sticky element over the first screen.
+type Overlay struct {
+	// Kind is OverlayConsent, OverlayChat, OverlayModal, OverlayHeader,
+	// OverlayFooter or OverlayOther.
+	Kind string `json:"kind"`
+	// Selector is a CSS selector matching only this element.
+	Selector string `json:"selector"`
+	Tag      string `json:"tag"`
+	// Position is the element's CSS position, fixed or sticky.
+	Position string `json:"position"`
+	// Text is the element's visible text or accessible name, shortened.
+	Text string `json:"text,omitempty"`
+	Box  Box    `json:"box"`
+	// Coverage is the share of the viewport this overlay covers.
+	Coverage float64 `json:"coverage"`
+}
+
+// overlayScript scrolls to the top of the document, then finds the
+// outermost visible fixed and sticky elements in the viewport, classifies
+// them and measures the viewport area they cover on a grid of 4px cells.
+// Elements ignoring the pointer are decorations rather than overlays and
+// are skipped.
+const overlayScript = `(() => {
+	const selectorOf = ` + selectorOfScript + `;
+	window.scrollTo(0, 0);
+	const width = window.innerWidth, height = window.innerHeight;
+	const cell = 4, cols = Math.ceil(width / cell), rows = Math.ceil(height / cell);
+	const covered = new Uint8Array(cols * rows);
+	const consent = /cookie|consent|gdpr|ccpa|privacy|onetrust|cookiebot|didomi|usercentrics|quantcast|trustarc|truste|cmp|sp_message|fc-consent/i;
+	const chat = /intercom|drift|zendesk|zopim|hubspot-messages|crisp|tawk|livechat|olark|freshchat|tidio|chat/i;
+
+	const overlays = [];
+	const walker = document.createTreeWalker(document.body || document.documentElement, NodeFilter.SHOW_ELEMENT);
+	for (let el = walker.nextNode(); el; el = walker.nextNode()) {
+		const style = getComputedStyle(el);
+		if (style.position !== 'fixed' && style.position !== 'sticky') continue;
+		if (overlays.some((o) => o.el.contains(el))) continue;
+		if (style.visibility === 'hidden' || style.opacity === '0' || style.display === 'none' || style.pointerEvents === 'none') continue;
+		const r = el.getBoundingClientRect();
+		const x0 = Math.max(0, r.left), y0 = Math.max(0, r.top);
+		const x1 = Math.min(width, r.right), y1 = Math.min(height, r.bottom);
+		if (x1 <= x0 || y1 <= y0) continue;
+
+		let own = 0;
+		for (let row = Math.floor(y0 / cell); row < Math.ceil(y1 / cell); row++) {
+			for (let col = Math.floor(x0 / cell); col < Math.ceil(x1 / cell); col++) {
+				own++;
+				covered[row * cols + col] = 1;
+			}
+		}
+
+		const names = [el.id, typeof el.className === 'string' ? el.className : '', el.getAttribute('aria-label') || '',
+			el.localName === 'iframe' ? el.src + ' ' + el.title : ''].join(' ');
+		const text = (el.innerText || el.getAttribute('aria-label') || el.title || '').replace(/\s+/g, ' ').trim();
+		let kind = 'other';
+		if (consent.test(names) || /\b(cookies?|consent)\b/i.test(text.slice(0, 500))) kind = 'consent';
+		else if (chat.test(names)) kind = 'chat';
+		else if (el.matches('[role="dialog"], [role="alertdialog"], [aria-modal="true"], dialog')) kind = 'modal';
+		else if (r.width >= width * 0.9 && r.top <= 0 && r.height < height / 3) kind = 'header';
+		else if (r.width >= width * 0.9 && r.bottom >= height && r.height < height / 3) kind = 'footer';
+		else if (r.width >= width * 0.9 && r.height >= height * 0.9) kind = 'modal';
+
+		overlays.push({el, kind, selector: selectorOf(el), tag: el.localName, position: style.position,
+			text: text.slice(0, 100),
+			box: {x: r.left + window.scrollX, y: r.top + window.scrollY, width: r.width, height: r.height},
+			coverage: Math.round(own / (cols * rows) * 1000) / 1000});
+	}
+
+	const total = covered.reduce((sum, c) => sum + c, 0);
+	return {
+		viewport: {x: window.scrollX, y: window.scrollY, width, height},
+		coverage: Math.round(total / (cols * rows) * 1000) / 1000,
+		overlays: overlays.map(({el, ...o}) => o),
+	};
+})()`
+
+// Overlays measures how much of the viewport fixed and sticky elements
+// cover, such as cookie banners, modals, chat widgets and sticky headers,
+// and classifies them. It scrolls back to the top first, so it measures
+// the first screen whatever actions ran before it.
+// Assumes NavigateAndPrepare has already been called.
+func (b *Browser) Overlays(ctx context.Context) (*OverlayReport, error) {
+	slog.Debug("Measuring overlays")
+
+	var report OverlayReport
+	if err := b.run(ctx, chromedp.Evaluate(overlayScript, &report)); err != nil {
+		slog.Error("Failed to measure overlays", "error", err)
+		return nil, err
+	}
+
+	slog.Debug("Overlays measured", "overlays", len(report.Overlays), "coverage", report.Coverage)
+	return &report, nil
+}
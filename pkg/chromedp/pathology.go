@@ -0,0 +1,274 @@
+package chromedphelper
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/chromedp/cdproto/network"
+)
+
+// Kinds of Pathology.
+const (
+	// PathologyRedirectLoop is a page redirecting, over HTTP or client-side,
+	// back to a URL it already redirected from.
+	PathologyRedirectLoop = "redirect-loop"
+	// PathologyLocationThrash is a page navigating the main frame over and
+	// over, e.g. a router fighting over the URL or reloading itself.
+	PathologyLocationThrash = "location-thrash"
+	// PathologyPerpetualLoading is a page whose document arrived but that
+	// timed out because requests never finished, e.g. behind a spinner that
+	// never goes away.
+	PathologyPerpetualLoading = "perpetual-loading"
+)
+
+const (
+	// loopVisits is how many times a client-side redirect chain must reach
+	// the same URL to be a loop rather than, say, a login round trip.
+	loopVisits = 3
+	// thrashNavigations main-frame navigations within thrashWindow are
+	// location thrash.
+	thrashNavigations = 10
+	thrashWindow      = 5 * time.Second
+	// stalledAfter is how long a request must be pending when the page
+	// times out to count as never finishing.
+	stalledAfter = 5 * time.Second
+	// maxPathologyURLs caps the URLs reported with a Pathology.
+	maxPathologyURLs = 10
+)
+
+// Pathology is a behavior of a page that keeps it from ever settling.
+type Pathology struct {
+	// Kind is one of PathologyRedirectLoop, PathologyLocationThrash and
+	// PathologyPerpetualLoading.
+	Kind string `json:"kind"`
+	// Detail describes what was observed.
+	Detail string `json:"detail"`
+	// URLs are those of the loop, the thrashing navigations or the pending
+	// requests.
+	URLs []string `json:"urls,omitempty"`
+}
+
+// PathologyError is returned by NavigateAndPrepare for a page showing a
+// Pathology, in place of the error the pathology caused, often a timeout.
+// It does not unwrap to that error, so a page stuck in a redirect loop is
+// not mistaken for a slow one.
+type PathologyError struct {
+	Pathology
+	// Err is the error the pathology caused, nil when the page was prepared
+	// in spite of it.
+	Err error
+}
+
+func (e *PathologyError) Error() string { return e.Kind + ": " + e.Detail }
+
+// pageWatch records what the main frame and the network do while a page is
+// prepared, so that diagnose can tell why it never settled. It is written
+// by the tab's event listener and read after NavigateAndPrepare.
+type pageWatch struct {
+	mu sync.Mutex
+	// documents are the URLs of the main frame's last document request,
+	// preceded by the HTTP redirects leading to it.
+	documents []string
+	// redirects are the target and the URLs of the client-side redirects
+	// followed since.
+	redirects   []string
+	navigations []watchedNavigation
+	pending     map[network.RequestID]pendingRequest
+	loaded      bool
+}
+
+type watchedNavigation struct {
+	url    string
+	reason string
+	at     time.Time
+}
+
+type pendingRequest struct {
+	url      string
+	document bool
+	started  time.Time
+}
+
+// reset starts watching the preparation of target.
+func (w *pageWatch) reset(target string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.documents = nil
+	w.redirects = []string{target}
+	w.navigations = nil
+	w.pending = make(map[network.RequestID]pendingRequest)
+	w.loaded = false
+}
+
+// document records a request of the main frame's document, an HTTP
+// redirect of the previous one when redirect is set.
+func (w *pageWatch) document(url string, redirect bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if !redirect {
+		w.documents = nil
+	}
+	w.documents = append(w.documents, url)
+}
+
+// navigation records a navigation of the main frame, requested by the page
+// or within the document (history API, fragment).
+func (w *pageWatch) navigation(url, reason string, at time.Time) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.navigations = append(w.navigations, watchedNavigation{url: url, reason: reason, at: at})
+	if clientRedirect(reason) {
+		w.redirects = append(w.redirects, url)
+	}
+}
+
+// requestStarted records a request, of the main frame's document when
+// document is set, as pending until requestDone.
+func (w *pageWatch) requestStarted(id network.RequestID, url string, document bool, at time.Time) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.pending != nil {
+		w.pending[id] = pendingRequest{url: url, document: document, started: at}
+	}
+}
+
+func (w *pageWatch) requestDone(id network.RequestID) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	delete(w.pending, id)
+}
+
+// inflight returns the number of pending requests.
+func (w *pageWatch) inflight() int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return len(w.pending)
+}
+
+func (w *pageWatch) load() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.loaded = true
+}
+
+// diagnose returns the pathology behind err, the outcome of preparing the
+// page, or nil. Redirect loops and location thrash are reported even when
+// err is nil; perpetual loading only explains timeouts, and not those of a
+// server that has yet to send the document.
+func (w *pageWatch) diagnose(err error, now time.Time) *Pathology {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if cycle := httpCycle(w.documents); cycle != nil {
+		return &Pathology{Kind: PathologyRedirectLoop, Detail: "HTTP redirects " + formatURLs(cycle), URLs: capURLs(cycle)}
+	}
+	if err != nil && strings.Contains(err.Error(), "ERR_TOO_MANY_REDIRECTS") {
+		return &Pathology{Kind: PathologyRedirectLoop, Detail: fmt.Sprintf("too many HTTP redirects (%d followed)", max(len(w.documents)-1, 0)), URLs: capURLs(w.documents)}
+	}
+	if cycle := clientCycle(w.redirects); cycle != nil {
+		return &Pathology{Kind: PathologyRedirectLoop, Detail: "client-side redirects " + formatURLs(cycle), URLs: capURLs(cycle)}
+	}
+	if thrash := thrashing(w.navigations); thrash != nil {
+		urls := make([]string, 0, len(thrash))
+		reasons := make(map[string]int)
+		for _, nav := range thrash {
+			urls = append(urls, nav.url)
+			reasons[nav.reason]++
+		}
+		return &Pathology{
+			Kind:   PathologyLocationThrash,
+			Detail: fmt.Sprintf("%d main-frame navigations within %s (%s)", len(thrash), thrashWindow, formatReasons(reasons)),
+			URLs:   capURLs(urls),
+		}
+	}
+
+	if !errors.Is(err, context.DeadlineExceeded) {
+		return nil
+	}
+	var stalled []string
+	var longest time.Duration
+	for _, req := range w.pending {
+		if req.document {
+			return nil
+		}
+		if age := now.Sub(req.started); age >= stalledAfter {
+			stalled = append(stalled, req.url)
+			longest = max(longest, age)
+		}
+	}
+	if len(stalled) == 0 {
+		return nil
+	}
+	slices.Sort(stalled)
+	detail := fmt.Sprintf("pending requests: %d, the oldest for %s", len(stalled), longest.Round(time.Second))
+	if !w.loaded {
+		detail += "; the load event never fired"
+	}
+	return &Pathology{Kind: PathologyPerpetualLoading, Detail: detail, URLs: capURLs(stalled)}
+}
+
+// httpCycle returns the HTTP redirects from the first URL the chain
+// reaches twice back to it, or nil.
+func httpCycle(chain []string) []string {
+	seen := make(map[string]int)
+	for i, url := range chain {
+		if first, ok := seen[url]; ok {
+			return chain[first : i+1]
+		}
+		seen[url] = i
+	}
+	return nil
+}
+
+// clientCycle returns the client-side redirects between the first two
+// visits of a URL the chain reaches loopVisits times, or nil.
+func clientCycle(chain []string) []string {
+	visits := make(map[string][]int)
+	for i, url := range chain {
+		visits[url] = append(visits[url], i)
+		if v := visits[url]; len(v) >= loopVisits {
+			return chain[v[0] : v[1]+1]
+		}
+	}
+	return nil
+}
+
+// thrashing returns the first thrashNavigations navigations within
+// thrashWindow, or nil.
+func thrashing(navs []watchedNavigation) []watchedNavigation {
+	for i := 0; i+thrashNavigations <= len(navs); i++ {
+		window := navs[i : i+thrashNavigations]
+		if window[len(window)-1].at.Sub(window[0].at) <= thrashWindow {
+			return window
+		}
+	}
+	return nil
+}
+
+// formatURLs renders a chain of URLs as "A -> B -> A".
+func formatURLs(urls []string) string {
+	return strings.Join(capURLs(urls), " -> ")
+}
+
+// formatReasons renders navigation counts by reason, e.g. "8 scriptInitiated, 2 reload".
+func formatReasons(reasons map[string]int) string {
+	var parts []string
+	for reason, n := range reasons {
+		parts = append(parts, fmt.Sprintf("%d %s", n, reason))
+	}
+	slices.Sort(parts)
+	return strings.Join(parts, ", ")
+}
+
+// capURLs returns at most maxPathologyURLs of urls.
+func capURLs(urls []string) []string {
+	if len(urls) > maxPathologyURLs {
+		urls = urls[:maxPathologyURLs]
+	}
+	return append([]string(nil), urls...)
+}
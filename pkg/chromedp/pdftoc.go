@@ -0,0 +1,121 @@
+package chromedphelper
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"regexp"
+
+	"github.com/chromedp/cdproto/page"
+	"github.com/chromedp/chromedp"
+)
+
+// pdfPage matches the page objects of a PDF, not the page tree (/Pages).
+// Chrome writes no object streams, so every page object is readable.
+var pdfPage = regexp.MustCompile(`/Type\s*/Page\b`)
+
+// PDFPageCount returns the number of pages of a PDF printed by Chrome.
+func PDFPageCount(pdf []byte) int {
+	return len(pdfPage.FindAllIndex(pdf, -1))
+}
+
+// maxTOCEntries bounds the headings listed on a table of contents page.
+const maxTOCEntries = 500
+
+// tocScript inserts a table of contents page before the page's content:
+// its title and the visible headings, indented by level, each linking to
+// its heading, which Chrome keeps as links within the PDF. Headings
+// without an id get one. Returns the number of headings listed.
+const tocScript = `(limit) => {
+	const visible = (el) => {
+		const style = getComputedStyle(el);
+		return style.display !== 'none' && style.visibility !== 'hidden' && el.getClientRects().length > 0;
+	};
+	const headings = [...document.querySelectorAll('h1, h2, h3, h4, h5, h6')]
+		.filter((h) => visible(h) && h.innerText.trim())
+		.slice(0, limit);
+	if (!headings.length || !document.body) return 0;
+	const top = Math.min(...headings.map((h) => Number(h.localName[1])));
+	const nav = document.createElement('nav');
+	nav.id = 'that-cli-web-toolbox-toc';
+	nav.setAttribute('style', 'all: initial; display: block; break-after: page; padding: 2em; font: 12pt/1.5 sans-serif; color: #000; background: #fff');
+	const title = document.createElement('div');
+	title.setAttribute('style', 'all: initial; display: block; margin-bottom: 1em; font: bold 18pt/1.3 sans-serif; color: #000');
+	title.textContent = document.title || 'Contents';
+	nav.append(title);
+	headings.forEach((h, i) => {
+		if (!h.id) {
+			h.id = 'that-cli-web-toolbox-toc-' + (i + 1);
+			h.dataset.thatCliWebToolboxToc = '';
+		}
+		const link = document.createElement('a');
+		link.href = '#' + encodeURIComponent(h.id);
+		link.textContent = h.innerText.replace(/\s+/g, ' ').trim();
+		const indent = (Number(h.localName[1]) - top) * 1.5;
+		link.setAttribute('style', 'all: initial; display: block; padding-left: ' + indent + 'em; font: 12pt/1.5 sans-serif; color: #00c; text-decoration: none');
+		nav.append(link);
+	});
+	document.body.prepend(nav);
+	return headings.length;
+}`
+
+// tocRemoveScript undoes tocScript.
+const tocRemoveScript = `(() => {
+	const nav = document.getElementById('that-cli-web-toolbox-toc');
+	if (nav) nav.remove();
+	for (const h of document.querySelectorAll('[data-that-cli-web-toolbox-toc]')) {
+		h.removeAttribute('id');
+		delete h.dataset.thatCliWebToolboxToc;
+	}
+})()`
+
+// PDFWithTOC is a PDF printed by PrintToPDFWithTOC.
+type PDFWithTOC struct {
+	PDF   []byte
+	Pages int
+	// Headings is the number of headings on the table of contents page,
+	// 0 if the PDF has none.
+	Headings int
+}
+
+// PrintToPDFWithTOC generates a PDF of the current page like PrintToPDF
+// and, if it has more than minPages pages and the page has headings,
+// prints it again with a leading table of contents page linking to them
+// and the headings as PDF bookmarks. The page is left as it was.
+// Assumes NavigateAndPrepare has already been called.
+func (b *Browser) PrintToPDFWithTOC(ctx context.Context, minPages int) (*PDFWithTOC, error) {
+	pdf, err := b.PrintToPDF(ctx)
+	if err != nil {
+		return nil, err
+	}
+	out := &PDFWithTOC{PDF: pdf, Pages: PDFPageCount(pdf)}
+	if out.Pages <= minPages {
+		slog.Debug("PDF is short enough without a table of contents", "pages", out.Pages, "minPages", minPages)
+		return out, nil
+	}
+
+	if err := b.run(ctx, chromedp.Evaluate(fmt.Sprintf("(%s)(%d)", tocScript, maxTOCEntries), &out.Headings)); err != nil {
+		slog.Error("Failed to insert table of contents", "error", err)
+		return nil, fmt.Errorf("failed to insert table of contents: %w", err)
+	}
+	if out.Headings == 0 {
+		slog.Warn("Page has no headings, PDF has no table of contents", "pages", out.Pages)
+		return out, nil
+	}
+	slog.Debug("Generating PDF with a table of contents", "pages", out.Pages, "headings", out.Headings)
+	err = b.run(ctx, chromedp.ActionFunc(func(ctx context.Context) error {
+		var err error
+		pdf, _, err = page.PrintToPDF().WithPrintBackground(true).WithGenerateDocumentOutline(true).Do(ctx)
+		return err
+	}))
+	if restoreErr := b.run(ctx, chromedp.Evaluate(tocRemoveScript, nil)); restoreErr != nil {
+		slog.Warn("Failed to remove table of contents", "error", restoreErr)
+	}
+	if err != nil {
+		slog.Error("Failed to generate PDF with a table of contents", "error", err)
+		return nil, err
+	}
+	out.PDF = pdf
+	out.Pages = PDFPageCount(pdf)
+	return out, nil
+}
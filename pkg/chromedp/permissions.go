@@ -0,0 +1,108 @@
+package chromedphelper
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/chromedp/cdproto/browser"
+	"github.com/chromedp/cdproto/emulation"
+	"github.com/chromedp/chromedp"
+)
+
+// permissionTypes maps the Permissions API names accepted by
+// ParsePermissions to the CDP permissions granting them.
+var permissionTypes = map[string][]browser.PermissionType{
+	"background-sync":    {browser.PermissionTypeBackgroundSync},
+	"camera":             {browser.PermissionTypeVideoCapture},
+	"clipboard-read":     {browser.PermissionTypeClipboardReadWrite},
+	"clipboard-write":    {browser.PermissionTypeClipboardSanitizedWrite},
+	"display-capture":    {browser.PermissionTypeDisplayCapture},
+	"geolocation":        {browser.PermissionTypeGeolocation},
+	"idle-detection":     {browser.PermissionTypeIdleDetection},
+	"local-fonts":        {browser.PermissionTypeLocalFonts},
+	"microphone":         {browser.PermissionTypeAudioCapture},
+	"midi":               {browser.PermissionTypeMidi},
+	"notifications":      {browser.PermissionTypeNotifications},
+	"payment-handler":    {browser.PermissionTypePaymentHandler},
+	"persistent-storage": {browser.PermissionTypeDurableStorage},
+	"screen-wake-lock":   {browser.PermissionTypeWakeLockScreen},
+	"sensors":            {browser.PermissionTypeSensors},
+	"storage-access":     {browser.PermissionTypeStorageAccess},
+	"window-management":  {browser.PermissionTypeWindowManagement},
+}
+
+// PermissionNames lists the names accepted by ParsePermissions.
+func PermissionNames() []string {
+	names := make([]string, 0, len(permissionTypes))
+	for name := range permissionTypes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ParsePermissions checks that every name is one of PermissionNames
+// (case-insensitive) and returns them in lower case.
+func ParsePermissions(names []string) ([]string, error) {
+	var permissions []string
+	for _, name := range names {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if _, ok := permissionTypes[name]; !ok {
+			return nil, fmt.Errorf("unknown permission %q (expected one of %s)", name, strings.Join(PermissionNames(), ", "))
+		}
+		permissions = append(permissions, name)
+	}
+	return permissions, nil
+}
+
+// permissionsAction grants the target's origin Permissions, plus clipboard
+// access when Clipboard is set, so the page's permission prompts resolve
+// instead of waiting for an answer headless Chrome never gives. Headless
+// pages never have focus, which the Clipboard API requires, so focus is
+// emulated for clipboard access.
+func (b *Browser) permissionsAction() chromedp.Action {
+	return chromedp.ActionFunc(func(ctx context.Context) error {
+		permissions := b.Permissions
+		if b.Clipboard {
+			permissions = append(slices.Clone(permissions), "clipboard-read", "clipboard-write")
+		}
+		if len(permissions) == 0 {
+			return nil
+		}
+		if slices.Contains(permissions, "clipboard-read") || slices.Contains(permissions, "clipboard-write") {
+			if err := emulation.SetFocusEmulationEnabled(true).Do(ctx); err != nil {
+				return fmt.Errorf("failed to emulate focus: %w", err)
+			}
+		}
+
+		u, err := url.Parse(b.TargetURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+			slog.Warn("Permissions can only be granted to http(s) pages", "url", b.TargetURL)
+			return nil
+		}
+		origin := u.Scheme + "://" + u.Host
+
+		var types []browser.PermissionType
+		for _, name := range permissions {
+			for _, t := range permissionTypes[name] {
+				if !slices.Contains(types, t) {
+					types = append(types, t)
+				}
+			}
+		}
+		slog.Debug("Granting permissions", "origin", origin, "permissions", permissions)
+		grant := browser.GrantPermissions(types).WithOrigin(origin)
+		if c := chromedp.FromContext(ctx); c != nil && c.BrowserContextID != "" {
+			grant = grant.WithBrowserContextID(c.BrowserContextID)
+		}
+		if err := grant.Do(ctx); err != nil {
+			return fmt.Errorf("failed to grant permissions: %w", err)
+		}
+		return nil
+	})
+}
@@ -0,0 +1,270 @@
+package chromedphelper
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/chromedp/cdproto/cdp"
+	"github.com/chromedp/cdproto/target"
+	"github.com/chromedp/chromedp"
+)
+
+// Pool runs up to a fixed number of tabs concurrently inside one browser,
+// so many targets can be processed without starting Chrome for each one.
+//
+// Each Acquire opens a fresh tab and Release closes it: tabs are never
+// reused, so page state and event listeners cannot leak between targets.
+type Pool struct {
+	// MaxLifetime, if set, replaces the browser with a newly started one
+	// once it has been running this long, so a long-running service does
+	// not accumulate Chrome's leaks: tabs acquired from then on open in the
+	// new browser, and the old one is closed when its last tab is released.
+	// Set it before the first Acquire.
+	MaxLifetime time.Duration
+	// MaxProfiles, if set, bounds the profiles of WithTabProfile open at
+	// once: beyond it, the least recently used profile without open tabs
+	// is closed, losing its cookies and storage, and when every profile
+	// has tabs open Acquire fails with ErrTooManyProfiles.
+	MaxProfiles int
+
+	slots chan struct{}
+	// start starts a browser configured like the first one.
+	start func(ctx context.Context) (*Browser, error)
+
+	mu        sync.Mutex
+	current   *pooledBrowser
+	owners    map[*Browser]pooledTab
+	configure []func(defaults *Browser)
+}
+
+// ErrTooManyProfiles is returned by Pool.Acquire for a new profile when
+// MaxProfiles profiles all have tabs open.
+var ErrTooManyProfiles = errors.New("too many profiles in use")
+
+// pooledBrowser is a browser of a Pool and the number of its open tabs.
+type pooledBrowser struct {
+	root    *Browser
+	started time.Time
+	tabs    int
+	// retired browsers are closed once their last tab is released.
+	retired bool
+	// profiles are the browser contexts of WithTabProfile, by name.
+	profiles map[string]*profile
+}
+
+// profile is the browser context of a WithTabProfile profile.
+type profile struct {
+	id   cdp.BrowserContextID
+	tabs int
+	used time.Time
+}
+
+// pooledTab is what Release needs to know about an acquired tab.
+type pooledTab struct {
+	browser *pooledBrowser
+	profile *profile
+}
+
+// NewPool starts a browser (or connects to remoteDebuggingPort) that serves
+// at most size concurrent tabs. delay and jsCode are the defaults for every
+// tab. The browser has no overall deadline; bound each tab's work through
+// the contexts passed to its methods. opts configure how Chrome is started.
+// Close the pool when done.
+func NewPool(ctx context.Context, size int, delay int, remoteDebuggingPort string, jsCode string, opts ...LaunchOption) (*Pool, error) {
+	if size < 1 {
+		return nil, fmt.Errorf("pool size must be at least 1, got %d", size)
+	}
+	slog.Debug("Creating browser pool", "size", size, "remotePort", remoteDebuggingPort)
+
+	p := &Pool{
+		slots:  make(chan struct{}, size),
+		owners: make(map[*Browser]pooledTab),
+	}
+	// Browsers live as long as the pool's context, not as long as the
+	// request that happens to start one
+	p.start = func(startCtx context.Context) (*Browser, error) {
+		root, err := InitializeChromedpContext(ctx, "", 0, delay, remoteDebuggingPort, jsCode, opts...)
+		if err != nil {
+			return nil, err
+		}
+		// Start the browser now so every tab joins the same instance
+		if err := root.run(startCtx); err != nil {
+			root.Cancel()
+			slog.Error("Failed to start pooled browser", "error", err)
+			return nil, fmt.Errorf("failed to start browser: %w", err)
+		}
+		return root, nil
+	}
+
+	root, err := p.start(ctx)
+	if err != nil {
+		return nil, err
+	}
+	p.current = &pooledBrowser{root: root, started: time.Now(), profiles: make(map[string]*profile)}
+	return p, nil
+}
+
+// Acquire waits for a free slot and opens a new tab that will navigate to
+// target. The tab must be handed back with Release.
+func (p *Pool) Acquire(ctx context.Context, target string, opts ...TabOption) (*Browser, error) {
+	tc := newTabConfig(opts)
+	if tc.profile != "" && tc.proxy != "" {
+		return nil, errors.New("a tab cannot have both a profile and a proxy")
+	}
+	select {
+	case p.slots <- struct{}{}:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	pb := p.browser(ctx)
+	pt := pooledTab{browser: pb}
+	if tc.profile != "" {
+		prof, err := p.profile(ctx, pb, tc.profile)
+		if err != nil {
+			p.mu.Lock()
+			p.done(pt)
+			p.mu.Unlock()
+			<-p.slots
+			return nil, err
+		}
+		pt.profile = prof
+		opts = append(opts, func(c *tabConfig) { c.browserContext = prof.id })
+	}
+	tab, err := pb.root.NewTab(ctx, opts...)
+	if err != nil {
+		p.mu.Lock()
+		p.done(pt)
+		p.mu.Unlock()
+		<-p.slots
+		return nil, err
+	}
+	tab.TargetURL = target
+
+	p.mu.Lock()
+	p.owners[tab] = pt
+	p.mu.Unlock()
+	slog.Debug("Acquired tab from pool", "target", target)
+	return tab, nil
+}
+
+// browser returns the browser to open the next tab in, counting the tab,
+// after replacing the current browser if it outlived MaxLifetime.
+func (p *Pool) browser(ctx context.Context) *pooledBrowser {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.MaxLifetime > 0 && time.Since(p.current.started) >= p.MaxLifetime {
+		slog.Info("Recycling browser", "age", time.Since(p.current.started).Round(time.Second))
+		root, err := p.start(ctx)
+		if err != nil {
+			// Keep serving from the old browser and try again next time
+			slog.Warn("Failed to start a new browser, keeping the old one", "error", err)
+		} else {
+			for _, fn := range p.configure {
+				fn(root)
+			}
+			old := p.current
+			p.current = &pooledBrowser{root: root, started: time.Now(), profiles: make(map[string]*profile)}
+			old.retired = true
+			if old.tabs == 0 {
+				old.root.Cancel()
+			}
+		}
+	}
+	p.current.tabs++
+	return p.current
+}
+
+// profile returns the profile called name of pb, creating its browser
+// context on first use, and counts a tab of it.
+func (p *Pool) profile(ctx context.Context, pb *pooledBrowser, name string) (*profile, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if prof, ok := pb.profiles[name]; ok {
+		prof.tabs++
+		prof.used = time.Now()
+		return prof, nil
+	}
+
+	c := chromedp.FromContext(pb.root.Ctx)
+	if c == nil || c.Browser == nil {
+		return nil, errors.New("browser is not running")
+	}
+	exec := cdp.WithExecutor(ctx, c.Browser)
+	if p.MaxProfiles > 0 && len(pb.profiles) >= p.MaxProfiles {
+		var lru string
+		for n, prof := range pb.profiles {
+			if prof.tabs == 0 && (lru == "" || prof.used.Before(pb.profiles[lru].used)) {
+				lru = n
+			}
+		}
+		if lru == "" {
+			return nil, ErrTooManyProfiles
+		}
+		slog.Debug("Closing least recently used profile", "profile", lru)
+		if err := target.DisposeBrowserContext(pb.profiles[lru].id).Do(exec); err != nil {
+			slog.Warn("Failed to close profile", "profile", lru, "error", err)
+		}
+		delete(pb.profiles, lru)
+	}
+
+	id, err := target.CreateBrowserContext().Do(exec)
+	if err != nil {
+		slog.Error("Failed to create profile", "profile", name, "error", err)
+		return nil, fmt.Errorf("failed to create profile: %w", err)
+	}
+	slog.Debug("Created profile", "profile", name)
+	prof := &profile{id: id, tabs: 1, used: time.Now()}
+	pb.profiles[name] = prof
+	return prof, nil
+}
+
+// done uncounts a tab, closing its browser once it is retired and has no
+// tabs left. p.mu must be held.
+func (p *Pool) done(pt pooledTab) {
+	if pt.profile != nil {
+		pt.profile.tabs--
+		pt.profile.used = time.Now()
+	}
+	pb := pt.browser
+	pb.tabs--
+	if pb.retired && pb.tabs == 0 {
+		slog.Debug("Closing retired browser", "age", time.Since(pb.started).Round(time.Second))
+		pb.root.Cancel()
+	}
+}
+
+// Release closes tab and frees its slot for the next Acquire.
+func (p *Pool) Release(tab *Browser) {
+	tab.Cancel()
+	p.mu.Lock()
+	if pt, ok := p.owners[tab]; ok {
+		delete(p.owners, tab)
+		p.done(pt)
+	}
+	p.mu.Unlock()
+	<-p.slots
+}
+
+// Close shuts the browsers down, closing any tabs still open.
+func (p *Pool) Close() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, pt := range p.owners {
+		pt.browser.root.Cancel()
+	}
+	p.current.root.Cancel()
+}
+
+// Configure lets fn set the exported fields (Steps, Headers, ...) that
+// every subsequently acquired tab starts with.
+func (p *Pool) Configure(fn func(defaults *Browser)) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.configure = append(p.configure, fn)
+	fn(p.current.root)
+}
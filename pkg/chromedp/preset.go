@@ -0,0 +1,1000 @@
+package chromedphelper
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"html"
+	"log/slog"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/chromedp/chromedp"
+)
+
+// Extraction presets.
+const (
+	PresetProduct    = "product"
+	PresetArticle    = "article"
+	PresetJobPosting = "job-posting"
+)
+
+// Presets lists the extraction presets of ExtractPreset.
+var Presets = []string{PresetProduct, PresetArticle, PresetJobPosting}
+
+// Sources of the fields of an extracted item, from most to least reliable.
+const (
+	SourceJSONLD    = "json-ld"
+	SourceMicrodata = "microdata"
+	SourceMeta      = "meta"
+	SourceHeuristic = "heuristic"
+)
+
+// presetTypes are the schema.org types each preset extracts.
+var presetTypes = map[string][]string{
+	PresetProduct:    {"Product", "ProductGroup", "IndividualProduct", "ProductModel"},
+	PresetArticle:    {"Article", "NewsArticle", "BlogPosting", "TechArticle", "ScholarlyArticle", "Report", "LiveBlogPosting", "AnalysisNewsArticle", "OpinionNewsArticle", "ReportageNewsArticle"},
+	PresetJobPosting: {"JobPosting"},
+}
+
+// presetSelectors are the heuristic selectors of each preset by field,
+// tried in order for fields the page's structured data and meta tags
+// leave empty. Images and links give their URL, time elements their
+// datetime attribute and meta elements their content.
+var presetSelectors = map[string]map[string][]string{
+	PresetProduct: {
+		"name":         {"[itemprop=name]", "h1", ".product-title", ".product-name", "#productTitle"},
+		"description":  {".product-description", "#productDescription", "[class*=description]"},
+		"brand":        {".brand", ".product-brand", "[class*=brand]"},
+		"sku":          {".sku", "[class*=sku]", "[data-sku]"},
+		"image":        {".product-image img", "[class*=gallery] img", "main img"},
+		"price":        {"[data-price]", ".price", ".product-price", "[class*=price]", "#priceblock_ourprice"},
+		"availability": {".availability", ".stock", "[class*=availability]", "[class*=stock]"},
+	},
+	PresetArticle: {
+		"headline":      {"article h1", "h1", ".headline", ".entry-title", ".post-title"},
+		"description":   {".dek", ".subtitle", ".standfirst", "article p"},
+		"author":        {"[rel=author]", ".author", ".byline", "[class*=author]"},
+		"datePublished": {"article time[datetime]", "time[datetime]", ".published", ".date"},
+		"section":       {".section", ".category", "[rel=category]"},
+		"image":         {"article img", "main img"},
+		"body":          {"article", "[itemprop=articleBody]", ".article-body", ".entry-content", ".post-content", "main"},
+	},
+	PresetJobPosting: {
+		"title":          {"h1", ".job-title", ".posting-headline h2", "[class*=job-title]"},
+		"description":    {".job-description", "#job-description", "[class*=description]", "main"},
+		"organization":   {".company", ".company-name", "[class*=company]", "[class*=employer]"},
+		"location":       {".location", ".job-location", "[class*=location]"},
+		"employmentType": {".employment-type", ".job-type", "[class*=employment]", "[class*=commitment]"},
+		"salary":         {".salary", "[class*=salary]", "[class*=compensation]"},
+		"apply":          {"a[href*=apply]", "a[class*=apply]", "[class*=apply] a"},
+	},
+}
+
+// maxPresetItems bounds the structured data items read from a page.
+const maxPresetItems = 200
+
+// presetScript reads the page's structured data for a preset: the JSON-LD
+// items, with @graph lists flattened, the microdata items as JSON-LD
+// objects, the meta tags by property or name, and the text of the first
+// visible match of each field's heuristic selectors.
+const presetScript = `(selectors, limit) => {
+	const text = (s) => (s || '').replace(/\s+/g, ' ').trim();
+	const jsonld = [];
+	const add = (v) => {
+		if (jsonld.length >= limit || !v || typeof v !== 'object') return;
+		if (Array.isArray(v)) { v.forEach(add); return; }
+		if (Array.isArray(v['@graph'])) v['@graph'].forEach(add);
+		if (v['@type']) jsonld.push(v);
+	};
+	for (const s of document.querySelectorAll('script[type="application/ld+json"]')) {
+		try { add(JSON.parse(s.textContent)); } catch (e) {}
+	}
+
+	const propValue = (el) => {
+		if (el.hasAttribute('itemscope')) return item(el);
+		if (el.hasAttribute('content')) return el.getAttribute('content');
+		switch (el.localName) {
+		case 'a': case 'link': case 'area': return el.href;
+		case 'img': case 'audio': case 'video': case 'source': case 'iframe': case 'embed': return el.src;
+		case 'time': return el.getAttribute('datetime') || text(el.textContent);
+		case 'data': case 'meter': return el.getAttribute('value');
+		}
+		return text(el.textContent);
+	};
+	const item = (el) => {
+		const out = {};
+		const type = (el.getAttribute('itemtype') || '').split(/\s+/)[0];
+		if (type) out['@type'] = type.replace(/^.*[\/#]/, '');
+		const props = [];
+		const walk = (node) => {
+			for (const child of node.children) {
+				if (child.hasAttribute('itemprop')) props.push(child);
+				if (!child.hasAttribute('itemscope')) walk(child);
+			}
+		};
+		walk(el);
+		for (const p of props) {
+			const value = propValue(p);
+			for (const name of p.getAttribute('itemprop').split(/\s+/)) {
+				if (!name) continue;
+				if (name in out) out[name] = [].concat(out[name], value);
+				else out[name] = value;
+			}
+		}
+		return out;
+	};
+	const microdata = [...document.querySelectorAll('[itemscope]:not([itemprop])')]
+		.slice(0, limit).map(item).filter((i) => i['@type']);
+
+	const meta = {};
+	for (const m of document.querySelectorAll('meta[content]')) {
+		const key = (m.getAttribute('property') || m.getAttribute('name') || m.getAttribute('itemprop') || '').toLowerCase();
+		if (key && !(key in meta)) meta[key] = m.getAttribute('content').trim();
+	}
+	const canonical = document.querySelector('link[rel=canonical]');
+	if (canonical && !('canonical' in meta)) meta.canonical = canonical.href;
+
+	const visible = (el) => {
+		if (el.localName === 'meta') return true;
+		const style = getComputedStyle(el);
+		return style.display !== 'none' && style.visibility !== 'hidden' && el.getClientRects().length > 0;
+	};
+	const value = (el) => {
+		if (el.localName === 'meta') return el.getAttribute('content') || '';
+		if (el.localName === 'img') return el.currentSrc || el.src;
+		if (el.localName === 'a') return el.href;
+		if (el.localName === 'time') return el.getAttribute('datetime') || text(el.innerText);
+		if (el.hasAttribute('data-price')) return el.getAttribute('data-price');
+		if (el.hasAttribute('data-sku')) return el.getAttribute('data-sku');
+		return text(el.innerText);
+	};
+	const heuristic = {};
+	for (const [field, list] of Object.entries(selectors)) {
+		for (const sel of list) {
+			let found;
+			try {
+				found = [...document.querySelectorAll(sel)].find((el) => visible(el) && value(el));
+			} catch (e) {}
+			if (found) {
+				heuristic[field] = value(found);
+				break;
+			}
+		}
+	}
+	return {url: location.href, lang: document.documentElement.lang || '', title: document.title, jsonld, microdata, meta, heuristic};
+}`
+
+// presetPage is what presetScript reads from the page.
+type presetPage struct {
+	URL       string            `json:"url"`
+	Lang      string            `json:"lang"`
+	Title     string            `json:"title"`
+	JSONLD    []map[string]any  `json:"jsonld"`
+	Microdata []map[string]any  `json:"microdata"`
+	Meta      map[string]string `json:"meta"`
+	Heuristic map[string]string `json:"heuristic"`
+}
+
+// Extraction is the items a preset extracted from a page, as normalized
+// objects: one per matching structured data item, or one built from the
+// page's meta tags and heuristic selectors when it has none. Only the
+// slice of the preset is set.
+type Extraction struct {
+	Preset      string        `json:"preset"`
+	URL         string        `json:"url"`
+	Products    []*Product    `json:"products,omitempty"`
+	Articles    []*Article    `json:"articles,omitempty"`
+	JobPostings []*JobPosting `json:"jobPostings,omitempty"`
+}
+
+// Len returns the number of items extracted.
+func (e *Extraction) Len() int {
+	return len(e.Products) + len(e.Articles) + len(e.JobPostings)
+}
+
+// Product is a product normalized by the product preset. Prices are
+// numbers in the currency's unit; availability is the schema.org name,
+// such as InStock or OutOfStock.
+type Product struct {
+	Name         string   `json:"name,omitempty"`
+	Description  string   `json:"description,omitempty"`
+	Brand        string   `json:"brand,omitempty"`
+	SKU          string   `json:"sku,omitempty"`
+	GTIN         string   `json:"gtin,omitempty"`
+	URL          string   `json:"url,omitempty"`
+	Images       []string `json:"images,omitempty"`
+	Price        *float64 `json:"price,omitempty"`
+	HighPrice    *float64 `json:"highPrice,omitempty"`
+	Currency     string   `json:"currency,omitempty"`
+	Availability string   `json:"availability,omitempty"`
+	Rating       *float64 `json:"rating,omitempty"`
+	ReviewCount  int      `json:"reviewCount,omitempty"`
+	// Sources tells where each field was found, by its JSON name.
+	Sources map[string]string `json:"sources"`
+}
+
+// Article is an article normalized by the article preset. Dates are RFC
+// 3339 when they can be parsed.
+type Article struct {
+	Headline      string            `json:"headline,omitempty"`
+	Description   string            `json:"description,omitempty"`
+	Authors       []string          `json:"authors,omitempty"`
+	Publisher     string            `json:"publisher,omitempty"`
+	DatePublished string            `json:"datePublished,omitempty"`
+	DateModified  string            `json:"dateModified,omitempty"`
+	Section       string            `json:"section,omitempty"`
+	Keywords      []string          `json:"keywords,omitempty"`
+	Language      string            `json:"language,omitempty"`
+	Image         string            `json:"image,omitempty"`
+	URL           string            `json:"url,omitempty"`
+	WordCount     int               `json:"wordCount,omitempty"`
+	Sources       map[string]string `json:"sources"`
+}
+
+// JobPosting is a job posting normalized by the job-posting preset.
+type JobPosting struct {
+	Title          string            `json:"title,omitempty"`
+	Description    string            `json:"description,omitempty"`
+	Organization   string            `json:"organization,omitempty"`
+	Locations      []string          `json:"locations,omitempty"`
+	Remote         bool              `json:"remote,omitempty"`
+	EmploymentType []string          `json:"employmentType,omitempty"`
+	DatePosted     string            `json:"datePosted,omitempty"`
+	ValidThrough   string            `json:"validThrough,omitempty"`
+	Salary         *Salary           `json:"salary,omitempty"`
+	ApplyURL       string            `json:"applyUrl,omitempty"`
+	URL            string            `json:"url,omitempty"`
+	Sources        map[string]string `json:"sources"`
+}
+
+// Salary is the pay of a JobPosting. Unit is the schema.org unit, such as
+// HOUR, MONTH or YEAR.
+type Salary struct {
+	Min      *float64 `json:"min,omitempty"`
+	Max      *float64 `json:"max,omitempty"`
+	Currency string   `json:"currency,omitempty"`
+	Unit     string   `json:"unit,omitempty"`
+}
+
+// ExtractPreset extracts the items of a preset from the current page,
+// combining its JSON-LD and microdata with its meta tags and heuristic
+// selectors. Assumes NavigateAndPrepare has already been called.
+func (b *Browser) ExtractPreset(ctx context.Context, preset string) (*Extraction, error) {
+	selectors, ok := presetSelectors[preset]
+	if !ok {
+		return nil, fmt.Errorf("unknown preset %q (expected %s)", preset, strings.Join(Presets, ", "))
+	}
+	arg, err := json.Marshal(selectors)
+	if err != nil {
+		return nil, err
+	}
+	var page presetPage
+	if err := b.run(ctx, chromedp.Evaluate(fmt.Sprintf("(%s)(%s, %d)", presetScript, arg, maxPresetItems), &page)); err != nil {
+		slog.Error("Failed to read structured data", "error", err)
+		return nil, fmt.Errorf("failed to read structured data: %w", err)
+	}
+	slog.Debug("Structured data read", "preset", preset, "jsonld", len(page.JSONLD), "microdata", len(page.Microdata), "meta", len(page.Meta), "heuristic", len(page.Heuristic))
+	return extractPreset(preset, &page), nil
+}
+
+// structuredItem is a schema.org item and where it was found.
+type structuredItem struct {
+	fields map[string]any
+	source string
+}
+
+// presetItems returns the page's structured data items of the preset's
+// types, JSON-LD first, skipping microdata items that repeat a JSON-LD
+// one.
+func presetItems(preset string, page *presetPage) []structuredItem {
+	var items []structuredItem
+	for _, list := range []struct {
+		items  []map[string]any
+		source string
+	}{{page.JSONLD, SourceJSONLD}, {page.Microdata, SourceMicrodata}} {
+		for _, fields := range list.items {
+			if !hasType(fields, presetTypes[preset]) {
+				continue
+			}
+			if list.source == SourceMicrodata && len(items) > 0 && repeats(fields, items) {
+				continue
+			}
+			items = append(items, structuredItem{fields, list.source})
+		}
+	}
+	return items
+}
+
+// repeats reports whether the microdata item fields has the name or
+// headline of an item already found.
+func repeats(fields map[string]any, items []structuredItem) bool {
+	name := ldString(first(fields, "name", "headline", "title"))
+	for _, i := range items {
+		if name == "" || strings.EqualFold(name, ldString(first(i.fields, "name", "headline", "title"))) {
+			return true
+		}
+	}
+	return false
+}
+
+// hasType reports whether an item is of one of types.
+func hasType(fields map[string]any, types []string) bool {
+	for _, t := range ldStrings(fields["@type"]) {
+		t = t[strings.LastIndexAny(t, "/#:")+1:]
+		for _, want := range types {
+			if t == want {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// extractPreset normalizes the items of a preset from what presetScript
+// read. The page's meta tags and heuristics describe its main item, so
+// they only fill the first one.
+func extractPreset(preset string, page *presetPage) *Extraction {
+	e := &Extraction{Preset: preset, URL: page.URL}
+	items := presetItems(preset, page)
+	fromPage := len(items) == 0
+	if fromPage {
+		items = []structuredItem{{fields: map[string]any{}}}
+	}
+	for i, item := range items {
+		f := &fieldFill{sources: make(map[string]string)}
+		if i == 0 {
+			f.meta, f.heuristic = page.Meta, page.Heuristic
+		}
+		switch preset {
+		case PresetProduct:
+			p := normalizeProduct(item, f)
+			if !fromPage || p.Name != "" {
+				e.Products = append(e.Products, p)
+			}
+		case PresetArticle:
+			a := normalizeArticle(item, f, page)
+			if !fromPage || a.Headline != "" {
+				e.Articles = append(e.Articles, a)
+			}
+		case PresetJobPosting:
+			j := normalizeJobPosting(item, f)
+			if !fromPage || j.Title != "" {
+				e.JobPostings = append(e.JobPostings, j)
+			}
+		}
+	}
+	return e
+}
+
+// fieldFill fills the fields of an item from its structured data, then
+// the page's meta tags, then the heuristic selectors, and records where
+// each came from.
+type fieldFill struct {
+	meta      map[string]string
+	heuristic map[string]string
+	sources   map[string]string
+}
+
+// pick returns the first non-empty value for field: the structured value
+// v, the first of the meta tags and the heuristic value of key.
+func (f *fieldFill) pick(field string, item structuredItem, v string, metas []string, key string) string {
+	if v = strings.TrimSpace(v); v != "" {
+		f.sources[field] = item.source
+		return v
+	}
+	for _, m := range metas {
+		if v := strings.TrimSpace(f.meta[m]); v != "" {
+			f.sources[field] = SourceMeta
+			return v
+		}
+	}
+	if v := strings.TrimSpace(f.heuristic[key]); key != "" && v != "" {
+		f.sources[field] = SourceHeuristic
+		return v
+	}
+	return ""
+}
+
+// set records the source of a field filled otherwise than by pick.
+func (f *fieldFill) set(field, source string) {
+	f.sources[field] = source
+}
+
+func normalizeProduct(item structuredItem, f *fieldFill) *Product {
+	fields := item.fields
+	p := &Product{}
+	p.Name = f.pick("name", item, ldString(fields["name"]), []string{"og:title", "twitter:title"}, "name")
+	p.Description = plainText(f.pick("description", item, ldString(fields["description"]), []string{"og:description", "description"}, "description"))
+	p.Brand = f.pick("brand", item, ldString(first(fields, "brand", "manufacturer")), []string{"product:brand", "og:brand"}, "brand")
+	p.SKU = f.pick("sku", item, ldString(first(fields, "sku", "mpn", "productID")), []string{"product:retailer_item_id"}, "sku")
+	if f.sources["sku"] == SourceHeuristic {
+		p.SKU = skuLabel.ReplaceAllString(p.SKU, "")
+	}
+	gtin := ldString(first(fields, "gtin", "gtin13", "gtin12", "gtin14", "gtin8"))
+	p.GTIN = f.pick("gtin", item, gtin, []string{"product:ean", "product:upc", "product:gtin"}, "")
+	p.URL = f.pick("url", item, ldString(fields["url"]), []string{"og:url", "canonical"}, "")
+	if images := ldURLs(fields["image"]); len(images) > 0 {
+		p.Images = images
+		f.set("images", item.source)
+	} else if image := f.pick("images", item, "", []string{"og:image", "twitter:image"}, "image"); image != "" {
+		p.Images = []string{image}
+	}
+
+	offer := offerOf(fields["offers"])
+	price := ldString(first(offer, "price", "lowPrice"))
+	if price == "" {
+		if spec, ok := offer["priceSpecification"].(map[string]any); ok {
+			price = ldString(spec["price"])
+			if _, ok := offer["priceCurrency"]; !ok {
+				offer["priceCurrency"] = spec["priceCurrency"]
+			}
+		}
+	}
+	priceText := f.pick("price", item, price, []string{"product:price:amount", "og:price:amount"}, "price")
+	amount, symbol := parseAmount(priceText)
+	p.Price = amount
+	if high, _ := parseAmount(ldString(offer["highPrice"])); high != nil && amount != nil && *high != *amount {
+		p.HighPrice = high
+		f.set("highPrice", item.source)
+	}
+	p.Currency = strings.ToUpper(f.pick("currency", item, ldString(offer["priceCurrency"]), []string{"product:price:currency", "og:price:currency"}, ""))
+	if p.Currency == "" && symbol != "" {
+		p.Currency = symbol
+		f.set("currency", f.sources["price"])
+	}
+	p.Availability = normalizeAvailability(f.pick("availability", item, ldString(offer["availability"]), []string{"product:availability", "og:availability"}, "availability"))
+
+	if rating, ok := fields["aggregateRating"].(map[string]any); ok {
+		if v, _ := parseAmount(ldString(rating["ratingValue"])); v != nil {
+			p.Rating = v
+			f.set("rating", item.source)
+		}
+		if n, _ := parseAmount(ldString(first(rating, "reviewCount", "ratingCount"))); n != nil {
+			p.ReviewCount = int(*n)
+			f.set("reviewCount", item.source)
+		}
+	}
+	p.Sources = f.sources
+	return p
+}
+
+// skuLabel matches the label before a SKU in the text of the page, such
+// as "SKU:" or "Item #".
+var skuLabel = regexp.MustCompile(`(?i)^(sku|item|article|art\.?-?nr\.?|model|part)\s*(no\.?|number|#)?\s*[:#]?\s*`)
+
+// offerOf returns the first offer of an offers value, which may be one
+// offer, an AggregateOffer or a list of offers.
+func offerOf(v any) map[string]any {
+	switch o := v.(type) {
+	case map[string]any:
+		return o
+	case []any:
+		for _, e := range o {
+			if m, ok := e.(map[string]any); ok {
+				return m
+			}
+		}
+	}
+	return map[string]any{}
+}
+
+// availabilityWords maps the wording of shops to schema.org availability.
+var availabilityWords = []struct {
+	pattern *regexp.Regexp
+	value   string
+}{
+	{regexp.MustCompile(`(?i)out\s*of\s*stock|sold\s*out|unavailable|\boos\b`), "OutOfStock"},
+	{regexp.MustCompile(`(?i)pre-?order`), "PreOrder"},
+	{regexp.MustCompile(`(?i)back-?order`), "BackOrder"},
+	{regexp.MustCompile(`(?i)discontinued`), "Discontinued"},
+	{regexp.MustCompile(`(?i)limited|only \d+ left|few left`), "LimitedAvailability"},
+	{regexp.MustCompile(`(?i)in\s*stock|available|add to (cart|basket|bag)`), "InStock"},
+}
+
+// normalizeAvailability returns the schema.org name of an availability,
+// given as a schema.org URL, an Open Graph value such as "instock" or the
+// text of the page.
+func normalizeAvailability(s string) string {
+	if s == "" {
+		return ""
+	}
+	if i := strings.LastIndexAny(s, "/#:"); i >= 0 && strings.Contains(strings.ToLower(s), "schema.org") {
+		return s[i+1:]
+	}
+	switch strings.ToLower(strings.ReplaceAll(strings.ReplaceAll(s, " ", ""), "_", "")) {
+	case "instock":
+		return "InStock"
+	case "outofstock", "oos":
+		return "OutOfStock"
+	case "preorder":
+		return "PreOrder"
+	case "backorder":
+		return "BackOrder"
+	}
+	for _, w := range availabilityWords {
+		if w.pattern.MatchString(s) {
+			return w.value
+		}
+	}
+	return s
+}
+
+func normalizeArticle(item structuredItem, f *fieldFill, page *presetPage) *Article {
+	fields := item.fields
+	a := &Article{}
+	a.Headline = f.pick("headline", item, ldString(first(fields, "headline", "name")), []string{"og:title", "twitter:title"}, "headline")
+	a.Description = plainText(f.pick("description", item, ldString(fields["description"]), []string{"og:description", "description", "twitter:description"}, "description"))
+	if authors := ldStrings(fields["author"]); len(authors) > 0 {
+		a.Authors = authors
+		f.set("authors", item.source)
+	} else if author := f.pick("authors", item, "", []string{"article:author", "author", "byl"}, "author"); author != "" {
+		a.Authors = []string{strings.TrimSpace(strings.TrimPrefix(strings.TrimPrefix(author, "By "), "by "))}
+	}
+	a.Publisher = f.pick("publisher", item, ldString(fields["publisher"]), []string{"og:site_name", "publisher"}, "")
+	a.DatePublished = normalizeDate(f.pick("datePublished", item, ldString(first(fields, "datePublished", "dateCreated")), []string{"article:published_time", "date", "pubdate"}, "datePublished"))
+	a.DateModified = normalizeDate(f.pick("dateModified", item, ldString(fields["dateModified"]), []string{"article:modified_time", "og:updated_time"}, ""))
+	a.Section = f.pick("section", item, ldString(fields["articleSection"]), []string{"article:section"}, "section")
+	if keywords := splitKeywords(fields["keywords"]); len(keywords) > 0 {
+		a.Keywords = keywords
+		f.set("keywords", item.source)
+	} else if kw := f.pick("keywords", item, "", []string{"article:tag", "keywords", "news_keywords"}, ""); kw != "" {
+		a.Keywords = splitKeywords(kw)
+	}
+	a.Language = f.pick("language", item, ldString(fields["inLanguage"]), []string{"og:locale", "language"}, "")
+	if a.Language == "" && page.Lang != "" {
+		a.Language = page.Lang
+		f.set("language", SourceHeuristic)
+	}
+	var image string
+	if images := ldURLs(fields["image"]); len(images) > 0 {
+		image = images[0]
+	}
+	a.Image = f.pick("image", item, image, []string{"og:image", "twitter:image"}, "image")
+	a.URL = f.pick("url", item, ldString(first(fields, "url", "mainEntityOfPage")), []string{"og:url", "canonical"}, "")
+	if n, _ := parseAmount(ldString(fields["wordCount"])); n != nil {
+		a.WordCount = int(*n)
+		f.set("wordCount", item.source)
+	} else if body := ldString(fields["articleBody"]); body != "" {
+		a.WordCount = len(strings.Fields(body))
+		f.set("wordCount", item.source)
+	} else if body := f.heuristic["body"]; body != "" {
+		a.WordCount = len(strings.Fields(body))
+		f.set("wordCount", SourceHeuristic)
+	}
+	a.Sources = f.sources
+	return a
+}
+
+func normalizeJobPosting(item structuredItem, f *fieldFill) *JobPosting {
+	fields := item.fields
+	j := &JobPosting{}
+	j.Title = f.pick("title", item, ldString(first(fields, "title", "name")), []string{"og:title"}, "title")
+	j.Description = plainText(f.pick("description", item, ldString(fields["description"]), []string{"og:description", "description"}, "description"))
+	j.Organization = f.pick("organization", item, ldString(fields["hiringOrganization"]), []string{"og:site_name"}, "organization")
+	if locations := jobLocations(fields["jobLocation"]); len(locations) > 0 {
+		j.Locations = locations
+		f.set("locations", item.source)
+	} else if location := f.pick("locations", item, "", nil, "location"); location != "" {
+		j.Locations = []string{location}
+	}
+	if strings.EqualFold(ldString(fields["jobLocationType"]), "TELECOMMUTE") {
+		j.Remote = true
+		f.set("remote", item.source)
+	} else if anyMatch(remoteWork, j.Locations...) {
+		j.Remote = true
+		f.set("remote", f.sources["locations"])
+	}
+	if types := ldStrings(fields["employmentType"]); len(types) > 0 {
+		for _, t := range types {
+			j.EmploymentType = append(j.EmploymentType, normalizeEmploymentType(t))
+		}
+		f.set("employmentType", item.source)
+	} else if t := f.pick("employmentType", item, "", nil, "employmentType"); t != "" {
+		j.EmploymentType = []string{normalizeEmploymentType(t)}
+	}
+	j.DatePosted = normalizeDate(f.pick("datePosted", item, ldString(fields["datePosted"]), nil, ""))
+	j.ValidThrough = normalizeDate(f.pick("validThrough", item, ldString(fields["validThrough"]), nil, ""))
+	if s := ldSalary(fields["baseSalary"], fields["salaryCurrency"]); s != nil {
+		j.Salary = s
+		f.set("salary", item.source)
+	} else if text := f.heuristic["salary"]; text != "" {
+		if s := parseSalary(text); s != nil {
+			j.Salary = s
+			f.set("salary", SourceHeuristic)
+		}
+	}
+	// schema.org has no apply link, only whether the page is one
+	j.ApplyURL = f.pick("applyUrl", item, "", nil, "apply")
+	j.URL = f.pick("url", item, ldString(fields["url"]), []string{"og:url", "canonical"}, "")
+	j.Sources = f.sources
+	return j
+}
+
+// remoteWork matches locations of remote jobs.
+var remoteWork = regexp.MustCompile(`(?i)\b(remote|anywhere|work from home|home office|telecommute)\b`)
+
+func anyMatch(re *regexp.Regexp, values ...string) bool {
+	for _, v := range values {
+		if re.MatchString(v) {
+			return true
+		}
+	}
+	return false
+}
+
+// jobLocations renders the places of a jobLocation value as
+// "locality, region, country".
+func jobLocations(v any) []string {
+	var out []string
+	for _, place := range ldList(v) {
+		m, ok := place.(map[string]any)
+		if !ok {
+			if s := ldString(place); s != "" {
+				out = append(out, s)
+			}
+			continue
+		}
+		address := m["address"]
+		a, ok := address.(map[string]any)
+		if !ok {
+			if s := ldString(first(m, "address", "name")); s != "" {
+				out = append(out, s)
+			}
+			continue
+		}
+		var parts []string
+		for _, key := range []string{"addressLocality", "addressRegion", "addressCountry"} {
+			if s := ldString(a[key]); s != "" {
+				parts = append(parts, s)
+			}
+		}
+		if len(parts) > 0 {
+			out = append(out, strings.Join(parts, ", "))
+		}
+	}
+	return out
+}
+
+// normalizeEmploymentType returns the schema.org employment type of t,
+// such as FULL_TIME, or t if it is not one.
+func normalizeEmploymentType(t string) string {
+	key := strings.ToUpper(strings.NewReplacer("-", "_", " ", "_").Replace(strings.TrimSpace(t)))
+	switch key {
+	case "FULL_TIME", "FULLTIME", "PERMANENT":
+		return "FULL_TIME"
+	case "PART_TIME", "PARTTIME":
+		return "PART_TIME"
+	case "CONTRACT", "CONTRACTOR", "FREELANCE":
+		return "CONTRACTOR"
+	case "TEMPORARY", "TEMP":
+		return "TEMPORARY"
+	case "INTERN", "INTERNSHIP":
+		return "INTERN"
+	case "VOLUNTEER", "PER_DIEM", "OTHER":
+		return key
+	}
+	return strings.TrimSpace(t)
+}
+
+// ldSalary reads a baseSalary, a MonetaryAmount whose value is a number
+// or a QuantitativeValue with a range.
+func ldSalary(v, currency any) *Salary {
+	if v == nil {
+		return nil
+	}
+	s := &Salary{Currency: strings.ToUpper(ldString(currency))}
+	m, ok := v.(map[string]any)
+	if !ok {
+		s.Min, _ = parseAmount(ldString(v))
+		s.Max = s.Min
+		if s.Min == nil {
+			return nil
+		}
+		return s
+	}
+	if c := ldString(m["currency"]); c != "" {
+		s.Currency = strings.ToUpper(c)
+	}
+	value := m["value"]
+	if q, ok := value.(map[string]any); ok {
+		s.Min, _ = parseAmount(ldString(first(q, "minValue", "value")))
+		s.Max, _ = parseAmount(ldString(first(q, "maxValue", "value")))
+		s.Unit = strings.ToUpper(ldString(q["unitText"]))
+	} else {
+		s.Min, _ = parseAmount(ldString(value))
+		s.Max = s.Min
+	}
+	if s.Unit == "" {
+		s.Unit = strings.ToUpper(ldString(m["unitText"]))
+	}
+	if s.Min == nil && s.Max == nil {
+		return nil
+	}
+	return s
+}
+
+// salaryRange matches the amounts of a salary in text, such as
+// "$90,000 - $120,000 a year" or "45k–60k €".
+var salaryRange = regexp.MustCompile(`([$€£¥]?\s*\d[\d.,]*(?:\s*[kK]\b)?)\s*(?:-|–|—|to|bis)\s*([$€£¥]?\s*\d[\d.,]*(?:\s*[kK]\b)?)|([$€£¥]?\s*\d[\d.,]*(?:\s*[kK]\b)?)`)
+
+// salaryUnits maps the wording of pay periods to schema.org unit texts.
+var salaryUnits = []struct {
+	pattern *regexp.Regexp
+	unit    string
+}{
+	{regexp.MustCompile(`(?i)\b(hour|hourly|hr)\b|/h\b`), "HOUR"},
+	{regexp.MustCompile(`(?i)\b(day|daily)\b`), "DAY"},
+	{regexp.MustCompile(`(?i)\b(week|weekly)\b`), "WEEK"},
+	{regexp.MustCompile(`(?i)\b(month|monthly)\b|/mo\b`), "MONTH"},
+	{regexp.MustCompile(`(?i)\b(year|yearly|annual|annually|annum|p\.a\.)|/yr\b`), "YEAR"},
+}
+
+// parseSalary reads a salary from the text of the page.
+func parseSalary(text string) *Salary {
+	m := salaryRange.FindStringSubmatch(text)
+	if m == nil {
+		return nil
+	}
+	s := &Salary{}
+	var symbol string
+	if m[3] != "" {
+		s.Min, symbol = parseAmount(m[3])
+		s.Max = s.Min
+	} else {
+		var maxSymbol string
+		s.Min, symbol = parseAmount(m[1])
+		s.Max, maxSymbol = parseAmount(m[2])
+		if symbol == "" {
+			symbol = maxSymbol
+		}
+	}
+	if s.Min == nil {
+		return nil
+	}
+	s.Currency = symbol
+	if s.Currency == "" {
+		s.Currency = currencyCode(text)
+	}
+	for _, u := range salaryUnits {
+		if u.pattern.MatchString(text) {
+			s.Unit = u.unit
+			break
+		}
+	}
+	return s
+}
+
+// currencySymbols maps currency symbols to ISO 4217 codes, in the order
+// they are looked for; "$" is taken as USD. Symbols of letters only count
+// as words of their own, so "kr" is not found in "Ukraine".
+var currencySymbols = []struct{ symbol, code string }{
+	{"$", "USD"}, {"€", "EUR"}, {"£", "GBP"}, {"¥", "JPY"}, {"₹", "INR"}, {"₩", "KRW"}, {"₽", "RUB"}, {"₺", "TRY"}, {"₪", "ILS"},
+	{"zł", "PLN"}, {"kr", "SEK"}, {"CHF", "CHF"},
+}
+
+// currencyCodeRE matches an ISO 4217 code in text.
+var currencyCodeRE = regexp.MustCompile(`\b(USD|EUR|GBP|JPY|CHF|CAD|AUD|NZD|SEK|NOK|DKK|PLN|CZK|INR|CNY|BRL|MXN)\b`)
+
+// currencyCode returns the currency of a price's text, by code or symbol.
+func currencyCode(text string) string {
+	if m := currencyCodeRE.FindString(text); m != "" {
+		return m
+	}
+	for _, c := range currencySymbols {
+		if containsWord(text, c.symbol) {
+			return c.code
+		}
+	}
+	return ""
+}
+
+// containsWord reports whether text contains symbol without a letter
+// right before or after it.
+func containsWord(text, symbol string) bool {
+	for i := 0; ; {
+		j := strings.Index(text[i:], symbol)
+		if j < 0 {
+			return false
+		}
+		start, end := i+j, i+j+len(symbol)
+		before, _ := utf8.DecodeLastRuneInString(text[:start])
+		after, _ := utf8.DecodeRuneInString(text[end:])
+		if !unicode.IsLetter(before) && !unicode.IsLetter(after) {
+			return true
+		}
+		i = end
+	}
+}
+
+// amountRE matches the number of an amount, with thousands separators and
+// a decimal part in either convention, and a "k" for thousands.
+var amountRE = regexp.MustCompile(`\d[\d.,' ]*(\s*[kK]\b)?`)
+
+// parseAmount returns the number in s, such as a price like "$1,299.99",
+// "1.299,99 €" or "45k", and the currency it is written in, if any.
+func parseAmount(s string) (*float64, string) {
+	m := amountRE.FindString(s)
+	if m == "" {
+		return nil, ""
+	}
+	thousands := strings.HasSuffix(strings.ToLower(strings.TrimSpace(m)), "k")
+	digits := strings.NewReplacer(" ", "", "'", "", "k", "", "K", "").Replace(m)
+	digits = strings.TrimRight(digits, ".,")
+	dot, comma := strings.LastIndex(digits, "."), strings.LastIndex(digits, ",")
+	switch {
+	case dot >= 0 && comma >= 0:
+		// The last separator is the decimal one
+		if comma > dot {
+			digits = strings.ReplaceAll(strings.ReplaceAll(digits, ".", ""), ",", ".")
+		} else {
+			digits = strings.ReplaceAll(digits, ",", "")
+		}
+	case comma >= 0:
+		digits = oneSeparator(digits, ",")
+	case dot >= 0:
+		digits = oneSeparator(digits, ".")
+	}
+	v, err := strconv.ParseFloat(digits, 64)
+	if err != nil {
+		return nil, ""
+	}
+	if thousands {
+		v *= 1000
+	}
+	return &v, currencyCode(s)
+}
+
+// oneSeparator returns digits, written with sep only, with sep as the
+// decimal point "." if it is one: used once, with other than three digits
+// after it or with a whole part of 0 ("0,125"). Otherwise it separates
+// thousands, as in "1,250" and "1.250", and is removed.
+func oneSeparator(digits, sep string) string {
+	whole, fraction, _ := strings.Cut(digits, sep)
+	if strings.Count(digits, sep) == 1 && (len(fraction) != 3 || whole == "0") {
+		return whole + "." + fraction
+	}
+	return strings.ReplaceAll(digits, sep, "")
+}
+
+// dateLayouts are the date formats normalizeDate reads, besides RFC 3339.
+var dateLayouts = []string{
+	"2006-01-02T15:04:05Z0700",
+	"2006-01-02T15:04:05",
+	"2006-01-02T15:04",
+	"2006-01-02 15:04:05",
+	time.RFC1123Z,
+	time.RFC1123,
+	"January 2, 2006",
+	"Jan 2, 2006",
+	"2 January 2006",
+	"02.01.2006",
+}
+
+// normalizeDate returns a date as RFC 3339, as YYYY-MM-DDTHH:MM:SS for
+// times without a zone, which are not made UTC, or as YYYY-MM-DD for dates
+// without a time, and s as it is if it cannot be parsed.
+func normalizeDate(s string) string {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return ""
+	}
+	if _, err := time.Parse("2006-01-02", s); err == nil {
+		return s
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t.Format(time.RFC3339)
+	}
+	for _, layout := range dateLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			switch {
+			case !strings.Contains(layout, "15"):
+				return t.Format("2006-01-02")
+			case !strings.Contains(layout, "07") && !strings.Contains(layout, "MST"):
+				return t.Format("2006-01-02T15:04:05")
+			}
+			return t.Format(time.RFC3339)
+		}
+	}
+	return s
+}
+
+// splitKeywords returns keywords given as a list or as comma separated
+// text.
+func splitKeywords(v any) []string {
+	var out []string
+	for _, s := range ldStrings(v) {
+		for _, k := range strings.Split(s, ",") {
+			if k = strings.TrimSpace(k); k != "" {
+				out = append(out, k)
+			}
+		}
+	}
+	return out
+}
+
+// tags matches HTML tags, which job and product descriptions in JSON-LD
+// often have.
+var tags = regexp.MustCompile(`<[^>]*>`)
+
+// plainText returns s without HTML tags and entities, its whitespace
+// collapsed.
+func plainText(s string) string {
+	if strings.Contains(s, "<") {
+		s = tags.ReplaceAllString(s, " ")
+	}
+	return strings.Join(strings.Fields(html.UnescapeString(s)), " ")
+}
+
+// first returns the first of keys that item has.
+func first(item map[string]any, keys ...string) any {
+	for _, k := range keys {
+		if v, ok := item[k]; ok && v != nil {
+			return v
+		}
+	}
+	return nil
+}
+
+// ldList returns a JSON-LD value as a list: itself if it is one.
+func ldList(v any) []any {
+	switch l := v.(type) {
+	case nil:
+		return nil
+	case []any:
+		return l
+	}
+	return []any{v}
+}
+
+// ldString returns a JSON-LD value as text: strings and numbers as they
+// are, the name, value or URL of objects, and the first of lists.
+func ldString(v any) string {
+	switch s := v.(type) {
+	case string:
+		return strings.TrimSpace(s)
+	case float64:
+		return strconv.FormatFloat(s, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(s)
+	case map[string]any:
+		return ldString(first(s, "name", "@value", "value", "url", "contentUrl", "@id"))
+	case []any:
+		for _, e := range s {
+			if text := ldString(e); text != "" {
+				return text
+			}
+		}
+	}
+	return ""
+}
+
+// ldURLs returns the URLs of a JSON-LD value of URLs or objects with one,
+// such as ImageObjects.
+func ldURLs(v any) []string {
+	var out []string
+	for _, e := range ldList(v) {
+		if m, ok := e.(map[string]any); ok {
+			e = first(m, "url", "contentUrl", "@id")
+		}
+		if s := ldString(e); s != "" {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+// ldStrings returns each value of a JSON-LD value as text.
+func ldStrings(v any) []string {
+	var out []string
+	for _, e := range ldList(v) {
+		if s := ldString(e); s != "" {
+			out = append(out, s)
+		}
+	}
+	return out
+}
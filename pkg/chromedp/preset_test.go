@@ -0,0 +1,127 @@
+package chromedphelper
+
+import "testing"
+
+func float(v float64) *float64 { return &v }
+
+func equalAmount(a, b *float64) bool {
+	return a == nil && b == nil || a != nil && b != nil && *a == *b
+}
+
+func TestParseAmount(t *testing.T) {
+	tests := []struct {
+		in       string
+		want     *float64
+		currency string
+	}{
+		{"$1,299.99", float(1299.99), "USD"},
+		{"1.299,99 €", float(1299.99), "EUR"},
+		{"£0.99", float(0.99), "GBP"},
+		{"¥1000", float(1000), "JPY"},
+		{"1 234,56 zł", float(1234.56), "PLN"},
+		{"CHF 1'234.50", float(1234.5), "CHF"},
+		{"499 kr", float(499), "SEK"},
+		{"USD 15", float(15), "USD"},
+		{"10,000.5", float(10000.5), ""},
+		{"1.234.567", float(1234567), ""},
+		{"1,234,567", float(1234567), ""},
+		// One separator before three digits separates thousands
+		{"1,250", float(1250), ""},
+		{"1.250", float(1250), ""},
+		{"12,5", float(12.5), ""},
+		{"4.5", float(4.5), ""},
+		{"0,125", float(0.125), ""},
+		{"0.125", float(0.125), ""},
+		{"45k", float(45000), ""},
+		{"€45 K", float(45000), "EUR"},
+		{"12.", float(12), ""},
+		// "kr" in a word is not the currency
+		{"Ukraine 300", float(300), ""},
+		{"", nil, ""},
+		{"free", nil, ""},
+		{"$", nil, ""},
+		{"k", nil, ""},
+	}
+	for _, tt := range tests {
+		got, currency := parseAmount(tt.in)
+		if !equalAmount(got, tt.want) || currency != tt.currency {
+			t.Errorf("parseAmount(%q) = %v, %q, want %v, %q", tt.in, deref(got), currency, deref(tt.want), tt.currency)
+		}
+	}
+}
+
+func deref(v *float64) any {
+	if v == nil {
+		return nil
+	}
+	return *v
+}
+
+func TestParseSalary(t *testing.T) {
+	tests := []struct {
+		in   string
+		want *Salary
+	}{
+		{"$90,000 - $120,000 a year", &Salary{Min: float(90000), Max: float(120000), Currency: "USD", Unit: "YEAR"}},
+		{"45k–60k €", &Salary{Min: float(45000), Max: float(60000), Currency: "EUR"}},
+		{"€50,000 to €60,000 per annum", &Salary{Min: float(50000), Max: float(60000), Currency: "EUR", Unit: "YEAR"}},
+		{"3.000 bis 4.000 EUR monatlich, 40 Stunden", &Salary{Min: float(3000), Max: float(4000), Currency: "EUR"}},
+		{"3.000 bis 4.000 EUR monthly", &Salary{Min: float(3000), Max: float(4000), Currency: "EUR", Unit: "MONTH"}},
+		{"£30,000 – 40,000 p.a.", &Salary{Min: float(30000), Max: float(40000), Currency: "GBP", Unit: "YEAR"}},
+		{"$25/hour", &Salary{Min: float(25), Max: float(25), Currency: "USD", Unit: "HOUR"}},
+		{"USD 100,000", &Salary{Min: float(100000), Max: float(100000), Currency: "USD"}},
+		{"Up to £40k", &Salary{Min: float(40000), Max: float(40000), Currency: "GBP"}},
+		{"25.000 kr om måneden", &Salary{Min: float(25000), Max: float(25000), Currency: "SEK"}},
+		{"$4,000 weekly, Ukraine office", &Salary{Min: float(4000), Max: float(4000), Currency: "USD", Unit: "WEEK"}},
+		{"Competitive", nil},
+		{"", nil},
+	}
+	for _, tt := range tests {
+		got := parseSalary(tt.in)
+		if (got == nil) != (tt.want == nil) {
+			t.Errorf("parseSalary(%q) = %+v, want %+v", tt.in, got, tt.want)
+			continue
+		}
+		if got == nil {
+			continue
+		}
+		if !equalAmount(got.Min, tt.want.Min) || !equalAmount(got.Max, tt.want.Max) || got.Currency != tt.want.Currency || got.Unit != tt.want.Unit {
+			t.Errorf("parseSalary(%q) = %v-%v %s %s, want %v-%v %s %s", tt.in,
+				deref(got.Min), deref(got.Max), got.Currency, got.Unit,
+				deref(tt.want.Min), deref(tt.want.Max), tt.want.Currency, tt.want.Unit)
+		}
+	}
+}
+
+func TestNormalizeDate(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"2024-03-05", "2024-03-05"},
+		{"  2024-03-05\n", "2024-03-05"},
+		{"2024-03-05T10:20:30Z", "2024-03-05T10:20:30Z"},
+		{"2024-03-05T10:20:30+02:00", "2024-03-05T10:20:30+02:00"},
+		{"2024-03-05T10:20:30.5-05:00", "2024-03-05T10:20:30-05:00"},
+		{"2024-03-05T10:20:30+0200", "2024-03-05T10:20:30+02:00"},
+		// Times without a zone are not made UTC
+		{"2024-03-05T10:20:30", "2024-03-05T10:20:30"},
+		{"2024-03-05T10:20", "2024-03-05T10:20:00"},
+		{"2024-03-05 10:20:30", "2024-03-05T10:20:30"},
+		{"Tue, 05 Mar 2024 10:20:30 +0100", "2024-03-05T10:20:30+01:00"},
+		{"Tue, 05 Mar 2024 10:20:30 GMT", "2024-03-05T10:20:30Z"},
+		{"March 5, 2024", "2024-03-05"},
+		{"Mar 5, 2024", "2024-03-05"},
+		{"5 March 2024", "2024-03-05"},
+		{"05.03.2024", "2024-03-05"},
+		{"", ""},
+		{"   ", ""},
+		{"2024-13-45", "2024-13-45"},
+		{"31.02.2024", "31.02.2024"},
+		{"yesterday", "yesterday"},
+	}
+	for _, tt := range tests {
+		if got := normalizeDate(tt.in); got != tt.want {
+			t.Errorf("normalizeDate(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,196 @@
+package chromedphelper
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/chromedp/chromedp"
+)
+
+// A ReadinessDetector tells when a page is ready to be captured, e.g. once
+// its content is rendered rather than a loading skeleton. NavigateAndPrepare
+// calls Wait after the page loaded and followed its redirects, before the
+// delay.
+type ReadinessDetector interface {
+	// Wait returns once the page of b is ready, or with an error when ctx
+	// is done first. ctx runs commands on the page: use chromedp actions
+	// with their Do method, not the methods of b, which would wait for the
+	// preparation to finish.
+	Wait(ctx context.Context, b *Browser) error
+	// String returns the detector as its ready strategy, e.g.
+	// "selector:#app".
+	String() string
+}
+
+// A ReadinessFactory makes the detector of a ready strategy from its
+// argument, the part after the colon, or "" when there is none.
+type ReadinessFactory func(arg string) (ReadinessDetector, error)
+
+// readinessPoll is how often detectors re-check the page.
+const readinessPoll = 100 * time.Millisecond
+
+// networkQuiet is how long no more than the allowed requests must be in
+// flight for the network-idle strategy.
+const networkQuiet = 500 * time.Millisecond
+
+var (
+	readinessMu        sync.RWMutex
+	readinessFactories = map[string]ReadinessFactory{
+		"selector":     newSelectorReadiness,
+		"network-idle": newNetworkIdleReadiness,
+		"js":           newJSReadiness,
+	}
+)
+
+// RegisterReadiness adds a ready strategy, so ParseReadiness accepts
+// name and name:ARG. It replaces a strategy of the same name.
+func RegisterReadiness(name string, factory ReadinessFactory) {
+	readinessMu.Lock()
+	defer readinessMu.Unlock()
+	readinessFactories[strings.ToLower(name)] = factory
+}
+
+// ReadinessStrategies returns the names of the ready strategies.
+func ReadinessStrategies() []string {
+	readinessMu.RLock()
+	defer readinessMu.RUnlock()
+	names := make([]string, 0, len(readinessFactories))
+	for name := range readinessFactories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ParseReadiness returns the detector of a ready strategy written as NAME
+// or NAME:ARG:
+//
+//	selector:SELECTOR    an element matching SELECTOR is visible
+//	network-idle[:N]     at most N requests (default 0) were in flight for 500ms
+//	js:EXPRESSION        the JavaScript EXPRESSION is truthy
+//	react, nextjs, vue, angular
+//	                     the app of the framework is hydrated and the DOM
+//	                     was quiet for 300ms
+func ParseReadiness(spec string) (ReadinessDetector, error) {
+	name, arg, _ := strings.Cut(strings.TrimSpace(spec), ":")
+	readinessMu.RLock()
+	factory, ok := readinessFactories[strings.ToLower(name)]
+	readinessMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown ready strategy %q (expected one of %s)", name, strings.Join(ReadinessStrategies(), ", "))
+	}
+	return factory(arg)
+}
+
+// readinessAction waits for b.Ready.
+func (b *Browser) readinessAction() chromedp.Action {
+	if b.Ready == nil {
+		return chromedp.Tasks{}
+	}
+	return chromedp.ActionFunc(func(ctx context.Context) error {
+		slog.Debug("Waiting for the page to be ready", "url", b.TargetURL, "strategy", b.Ready.String())
+		start := time.Now()
+		if err := b.Ready.Wait(ctx, b); err != nil {
+			var selector *SelectorError
+			if errors.As(err, &selector) {
+				return err
+			}
+			return fmt.Errorf("page never became ready (%s): %w", b.Ready, err)
+		}
+		slog.Debug("Page is ready", "url", b.TargetURL, "strategy", b.Ready.String(), "waited", time.Since(start))
+		return nil
+	})
+}
+
+// selectorReadiness waits for an element matching its selector to be
+// visible.
+type selectorReadiness struct{ selector string }
+
+func newSelectorReadiness(arg string) (ReadinessDetector, error) {
+	if strings.TrimSpace(arg) == "" {
+		return nil, fmt.Errorf("ready strategy selector needs a selector, e.g. selector:#app")
+	}
+	return selectorReadiness{selector: arg}, nil
+}
+
+func (r selectorReadiness) Wait(ctx context.Context, b *Browser) error {
+	err := chromedp.WaitVisible(r.selector, chromedp.ByQuery).Do(ctx)
+	return selectorError(err, r.selector, b.TargetURL)
+}
+
+func (r selectorReadiness) String() string { return "selector:" + r.selector }
+
+// networkIdleReadiness waits until at most max requests were in flight
+// for networkQuiet.
+type networkIdleReadiness struct{ max int }
+
+func newNetworkIdleReadiness(arg string) (ReadinessDetector, error) {
+	if arg == "" {
+		return networkIdleReadiness{}, nil
+	}
+	n, err := strconv.Atoi(arg)
+	if err != nil || n < 0 {
+		return nil, fmt.Errorf("invalid number of requests %q for ready strategy network-idle", arg)
+	}
+	return networkIdleReadiness{max: n}, nil
+}
+
+func (r networkIdleReadiness) Wait(ctx context.Context, b *Browser) error {
+	var quietSince time.Time
+	for {
+		if b.watch.inflight() > r.max {
+			quietSince = time.Time{}
+		} else if quietSince.IsZero() {
+			quietSince = time.Now()
+		} else if time.Since(quietSince) >= networkQuiet {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(readinessPoll):
+		}
+	}
+}
+
+func (r networkIdleReadiness) String() string {
+	if r.max == 0 {
+		return "network-idle"
+	}
+	return "network-idle:" + strconv.Itoa(r.max)
+}
+
+// jsReadiness waits for a JavaScript expression to be truthy.
+type jsReadiness struct{ expression string }
+
+func newJSReadiness(arg string) (ReadinessDetector, error) {
+	if strings.TrimSpace(arg) == "" {
+		return nil, fmt.Errorf("ready strategy js needs an expression, e.g. js:window.appReady === true")
+	}
+	return jsReadiness{expression: arg}, nil
+}
+
+func (r jsReadiness) Wait(ctx context.Context, b *Browser) error {
+	var res json.RawMessage
+	return PollJS(ctx, r.expression, &res)
+}
+
+func (r jsReadiness) String() string { return "js:" + r.expression }
+
+// PollJS evaluates expression in the page every 100ms until it is truthy,
+// storing its value in res, or until ctx is done. Ready strategies use it
+// to wait for signals of the page's scripts.
+func PollJS(ctx context.Context, expression string, res any) error {
+	return chromedp.Poll(expression, res,
+		chromedp.WithPollingInterval(readinessPoll),
+		chromedp.WithPollingTimeout(0),
+	).Do(ctx)
+}
@@ -0,0 +1,119 @@
+package chromedphelper
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/chromedp/chromedp"
+
+	"github.com/pesarkhobeee/that-cli-web-toolbox/pkg/events"
+)
+
+// redirectSettle is how long to wait after a page has loaded for it to
+// start a client-side redirect.
+const redirectSettle = time.Second
+
+// RedirectHop is one step of the redirect chain of a page.
+type RedirectHop struct {
+	URL string `json:"url"`
+	// Reason is "initial" for the target itself, otherwise the CDP client
+	// navigation reason (metaTagRefresh, httpHeaderRefresh or scriptInitiated).
+	Reason string `json:"reason"`
+}
+
+// clientRedirect reports whether a navigation with reason is a redirect
+// rather than, say, a clicked link or a submitted form.
+func clientRedirect(reason string) bool {
+	switch reason {
+	case "metaTagRefresh", "httpHeaderRefresh", "scriptInitiated":
+		return true
+	}
+	return false
+}
+
+// Redirects returns the client-side redirect chain followed by the last
+// NavigateAndPrepare, starting with the target, or nil if the page did not
+// redirect.
+func (b *Browser) Redirects() []RedirectHop {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if len(b.redirects) < 2 {
+		return nil
+	}
+	return append([]RedirectHop(nil), b.redirects...)
+}
+
+// followRedirectsAction waits for meta refresh and JavaScript redirects of
+// the loaded page, following up to MaxRedirects of them. stream must have
+// been subscribed before navigation so early redirects are not missed.
+func (b *Browser) followRedirectsAction(stream <-chan events.Event) chromedp.Action {
+	return chromedp.ActionFunc(func(ctx context.Context) error {
+		b.redirects = []RedirectHop{{URL: b.TargetURL, Reason: "initial"}}
+		for len(b.redirects) <= b.MaxRedirects {
+			// A meta refresh with a delay only navigates once it elapses
+			wait := redirectSettle
+			var refresh float64
+			err := chromedp.Evaluate(`(() => {
+				const meta = document.querySelector('meta[http-equiv="refresh" i][content]');
+				if (!meta) return -1;
+				const delay = parseFloat(meta.content);
+				return isNaN(delay) ? 0 : delay;
+			})()`, &refresh).Do(ctx)
+			if err == nil && refresh >= 0 {
+				wait += time.Duration(refresh * float64(time.Second))
+			}
+
+			nav, ok, err := waitForRedirect(ctx, stream, wait)
+			if err != nil || !ok {
+				return err
+			}
+			slog.Debug("Following client-side redirect", "url", nav.URL, "reason", nav.Reason)
+			b.redirects = append(b.redirects, RedirectHop{URL: nav.URL, Reason: nav.Reason})
+			if err := waitForLoad(ctx, stream); err != nil {
+				return err
+			}
+		}
+		slog.Warn("Stopped following client-side redirects", "maxRedirects", b.MaxRedirects)
+		return nil
+	})
+}
+
+// waitForRedirect returns the next client-side redirect on stream, or
+// false if none starts within wait.
+func waitForRedirect(ctx context.Context, stream <-chan events.Event, wait time.Duration) (events.Navigation, bool, error) {
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	for {
+		select {
+		case ev, ok := <-stream:
+			if !ok {
+				return events.Navigation{}, false, nil
+			}
+			if nav, isNav := ev.(events.Navigation); isNav && clientRedirect(nav.Reason) {
+				return nav, true, nil
+			}
+		case <-timer.C:
+			return events.Navigation{}, false, nil
+		case <-ctx.Done():
+			return events.Navigation{}, false, ctx.Err()
+		}
+	}
+}
+
+// waitForLoad waits for the next load event of the main frame.
+func waitForLoad(ctx context.Context, stream <-chan events.Event) error {
+	for {
+		select {
+		case ev, ok := <-stream:
+			if !ok {
+				return nil
+			}
+			if _, isLoad := ev.(events.Load); isLoad {
+				return nil
+			}
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
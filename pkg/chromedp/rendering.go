@@ -0,0 +1,131 @@
+package chromedphelper
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"log/slog"
+	"slices"
+
+	"github.com/chromedp/cdproto/css"
+	"github.com/chromedp/cdproto/dom"
+	"github.com/chromedp/chromedp"
+)
+
+// maxFontSamples bounds the elements Rendering asks for the fonts of.
+const maxFontSamples = 100
+
+// Rendering describes what the current page was rendered with, beyond the
+// browser's version: the viewport and the fonts its text was drawn in.
+type Rendering struct {
+	Viewport RenderedViewport `json:"viewport"`
+	Fonts    []RenderedFont   `json:"fonts"`
+}
+
+// RenderedViewport is the viewport as the page saw it.
+type RenderedViewport struct {
+	Width             int     `json:"width"`
+	Height            int     `json:"height"`
+	DeviceScaleFactor float64 `json:"deviceScaleFactor"`
+	ScreenWidth       int     `json:"screenWidth"`
+	ScreenHeight      int     `json:"screenHeight"`
+	// ColorScheme is the prefers-color-scheme the page matched.
+	ColorScheme string `json:"colorScheme"`
+}
+
+// RenderedFont is a font the platform drew the page's text with.
+type RenderedFont struct {
+	Family         string `json:"family"`
+	PostScriptName string `json:"postScriptName,omitempty"`
+	// Custom is set for web fonts, which were downloaded or resolved
+	// locally by @font-face, rather than installed fonts.
+	Custom bool `json:"custom"`
+	// Glyphs counts the glyphs drawn with the font in the sampled
+	// elements.
+	Glyphs int `json:"glyphs"`
+}
+
+// renderingViewportScript reads the viewport the page sees.
+const renderingViewportScript = `({
+	width: window.innerWidth,
+	height: window.innerHeight,
+	deviceScaleFactor: window.devicePixelRatio,
+	screenWidth: screen.width,
+	screenHeight: screen.height,
+	colorScheme: matchMedia('(prefers-color-scheme: dark)').matches ? 'dark' : 'light',
+})`
+
+// fontSamplesScript returns a selector of the first element holding text
+// in each distinct font style (family, weight and style) of the page, up to
+// max of them.
+const fontSamplesScript = `(max) => {
+	const selectorOf = ` + selectorOfScript + `;
+	const seen = new Set();
+	const samples = [];
+	for (const el of document.querySelectorAll('body, body *')) {
+		if (samples.length >= max) break;
+		if (!Array.from(el.childNodes).some(n => n.nodeType === Node.TEXT_NODE && n.textContent.trim())) continue;
+		const style = getComputedStyle(el);
+		const key = style.fontFamily + '|' + style.fontWeight + '|' + style.fontStyle;
+		if (seen.has(key)) continue;
+		seen.add(key);
+		samples.push(selectorOf(el));
+	}
+	return samples;
+}`
+
+// Rendering returns the viewport of the current page and the platform
+// fonts of its text (CSS.getPlatformFontsForNode), sampled on one element
+// per font style.
+// Assumes NavigateAndPrepare has already been called.
+func (b *Browser) Rendering(ctx context.Context) (*Rendering, error) {
+	slog.Debug("Getting rendering environment")
+
+	r := &Rendering{Fonts: []RenderedFont{}}
+	var samples []string
+	type fontKey struct {
+		family, postScript string
+		custom             bool
+	}
+	glyphs := make(map[fontKey]int)
+	err := b.run(ctx,
+		chromedp.Evaluate(renderingViewportScript, &r.Viewport),
+		chromedp.Evaluate(fmt.Sprintf("(%s)(%d)", fontSamplesScript, maxFontSamples), &samples),
+		dom.Enable(),
+		css.Enable(),
+		chromedp.ActionFunc(func(ctx context.Context) error {
+			root, err := dom.GetDocument().WithDepth(0).Do(ctx)
+			if err != nil {
+				return err
+			}
+			for _, sel := range samples {
+				id, err := dom.QuerySelector(root.NodeID, sel).Do(ctx)
+				if err != nil || id == 0 {
+					slog.Debug("Skipping font sample that is gone", "selector", sel, "error", err)
+					continue
+				}
+				fonts, err := css.GetPlatformFontsForNode(id).Do(ctx)
+				if err != nil {
+					return fmt.Errorf("failed to get fonts of %s: %w", sel, err)
+				}
+				for _, f := range fonts {
+					glyphs[fontKey{f.FamilyName, f.PostScriptName, f.IsCustomFont}] += int(f.GlyphCount)
+				}
+			}
+			return nil
+		}),
+	)
+	if err != nil {
+		slog.Error("Failed to get rendering environment", "error", err)
+		return nil, err
+	}
+
+	for k, n := range glyphs {
+		r.Fonts = append(r.Fonts, RenderedFont{Family: k.family, PostScriptName: k.postScript, Custom: k.custom, Glyphs: n})
+	}
+	slices.SortFunc(r.Fonts, func(a, b RenderedFont) int {
+		return cmp.Or(b.Glyphs-a.Glyphs, cmp.Compare(a.Family, b.Family), cmp.Compare(a.PostScriptName, b.PostScriptName))
+	})
+	slog.Debug("Rendering environment retrieved", "viewport", fmt.Sprintf("%dx%d@%g", r.Viewport.Width, r.Viewport.Height, r.Viewport.DeviceScaleFactor), "fonts", len(r.Fonts))
+	return r, nil
+}
@@ -0,0 +1,85 @@
+package chromedphelper
+
+import (
+	"context"
+	"encoding/base64"
+	"log/slog"
+	"sync"
+
+	"github.com/chromedp/cdproto/fetch"
+	"github.com/chromedp/cdproto/network"
+
+	"github.com/pesarkhobeee/that-cli-web-toolbox/pkg/har"
+)
+
+// replayWatch holds the session matching the requests of the current
+// page load against Replay.
+type replayWatch struct {
+	mu      sync.Mutex
+	session *har.ReplaySession
+}
+
+// reset starts a new session for a page load, or none without a replay.
+func (w *replayWatch) reset(replay *har.Replay) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.session = nil
+	if replay != nil {
+		w.session = replay.Session()
+	}
+}
+
+func (w *replayWatch) get() *har.ReplaySession {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.session
+}
+
+// replayRequest answers a paused request with the response Replay
+// recorded for it, and fails it as if offline when there is none, so the
+// page never reaches the network.
+func (b *Browser) replayRequest(ctx context.Context, ev *fetch.EventRequestPaused) error {
+	session := b.replay.get()
+	if session == nil {
+		// Paused before NavigateAndPrepare started a session
+		session = b.Replay.Session()
+	}
+	entry := session.Match(ev.Request.Method, ev.Request.URL, postData(ev.Request.PostDataEntries))
+	if entry == nil {
+		slog.Debug("Request not in the HAR, failing it", "method", ev.Request.Method, "url", ev.Request.URL)
+		return fetch.FailRequest(ev.RequestID, network.ErrorReasonInternetDisconnected).Do(ctx)
+	}
+	resp := entry.Response
+	if resp.Status == 0 {
+		slog.Debug("Request failed when recorded, failing it", "url", ev.Request.URL, "error", entry.Comment)
+		return fetch.FailRequest(ev.RequestID, network.ErrorReasonFailed).Do(ctx)
+	}
+	body, err := resp.Content.Body()
+	if err != nil {
+		slog.Warn("Response body in the HAR is not valid base64, serving it empty", "url", ev.Request.URL, "error", err)
+		body = nil
+	}
+	headers := make([]*fetch.HeaderEntry, 0, len(resp.Headers))
+	for _, h := range resp.ReplayHeaders() {
+		headers = append(headers, &fetch.HeaderEntry{Name: h.Name, Value: h.Value})
+	}
+	slog.Debug("Replaying response from the HAR", "url", ev.Request.URL, "status", resp.Status, "size", len(body))
+	fulfill := fetch.FulfillRequest(ev.RequestID, resp.Status).
+		WithResponseHeaders(headers).
+		WithBody(base64.StdEncoding.EncodeToString(body))
+	if resp.StatusText != "" {
+		fulfill = fulfill.WithResponsePhrase(resp.StatusText)
+	}
+	return fulfill.Do(ctx)
+}
+
+// Replayed returns how the requests of the page were served from Replay
+// since the last NavigateAndPrepare, or nil without a replay.
+func (b *Browser) Replayed() *har.ReplayStats {
+	session := b.replay.get()
+	if session == nil {
+		return nil
+	}
+	stats := session.Stats()
+	return &stats
+}
@@ -0,0 +1,197 @@
+package chromedphelper
+
+import (
+	"errors"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/pesarkhobeee/that-cli-web-toolbox/pkg/events"
+)
+
+// Bounds of the search for files a missing resource may have meant, so a
+// document in a large tree, such as a home directory, stays quick.
+const (
+	suggestDepth   = 5
+	suggestEntries = 20000
+	maxSuggestions = 3
+)
+
+// MissingFile is a local file a file: page referenced but could not load,
+// typically a stylesheet or image with a wrong relative path.
+type MissingFile struct {
+	URL string `json:"url"`
+	// Path is the file the URL names, relative to the document's directory
+	// when inside it.
+	Path         string `json:"path"`
+	ResourceType string `json:"resourceType"`
+	Error        string `json:"error"`
+	// Suggestions are existing files the reference may have meant,
+	// relative to the document's directory, best match first.
+	Suggestions []string `json:"suggestions,omitempty"`
+}
+
+// missingFiles collects the failed file: loads of a file: page, from the
+// listener while operations hold the browser's lock.
+type missingFiles struct {
+	mu       sync.Mutex
+	requests []events.RequestFinished
+}
+
+func (m *missingFiles) reset() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.requests = nil
+}
+
+func (m *missingFiles) add(req events.RequestFinished) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.requests = append(m.requests, req)
+}
+
+func (m *missingFiles) get() []events.RequestFinished {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]events.RequestFinished(nil), m.requests...)
+}
+
+// recordMissingFile notes req if it is a failed file: load of a file:
+// target, other than the target itself. Untrusted pages are not allowed
+// local files, so theirs are blocked rather than missing.
+func (b *Browser) recordMissingFile(req *events.RequestFinished) {
+	if !req.Failed || b.Untrusted || !strings.HasPrefix(req.URL, "file:") || !strings.HasPrefix(b.TargetURL, "file:") || req.URL == b.TargetURL {
+		return
+	}
+	b.missing.add(*req)
+}
+
+// MissingFiles returns the local files the page failed to load since the
+// last NavigateAndPrepare, with suggested fixes for files that do not
+// exist, or nil if the target is not a file: URL or everything loaded.
+func (b *Browser) MissingFiles() []MissingFile {
+	failed := b.missing.get()
+	if len(failed) == 0 {
+		return nil
+	}
+	doc, err := url.Parse(b.TargetURL)
+	if err != nil {
+		return nil
+	}
+	docDir := filepath.Dir(filepath.FromSlash(doc.Path))
+
+	var missing []MissingFile
+	var index *fileIndex
+	seen := make(map[string]bool)
+	for _, req := range failed {
+		u, err := url.Parse(req.URL)
+		if err != nil || seen[u.Path] {
+			continue
+		}
+		seen[u.Path] = true
+		path := filepath.FromSlash(u.Path)
+		m := MissingFile{
+			URL:          req.URL,
+			Path:         relativeTo(docDir, path),
+			ResourceType: req.ResourceType,
+			Error:        req.ErrorText,
+		}
+		if _, err := os.Stat(path); errors.Is(err, fs.ErrNotExist) {
+			if index == nil {
+				index = indexFiles(docDir)
+			}
+			m.Suggestions = index.suggest(docDir, path)
+		}
+		missing = append(missing, m)
+	}
+	return missing
+}
+
+// relativeTo returns path relative to dir, with forward slashes, if it is
+// inside dir, else path unchanged.
+func relativeTo(dir, path string) string {
+	rel, err := filepath.Rel(dir, path)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return path
+	}
+	return filepath.ToSlash(rel)
+}
+
+// fileIndex lists the files near a document by lowercased base name.
+type fileIndex struct {
+	byName map[string][]string
+}
+
+// indexFiles walks the document's directory and its parent, as assets
+// often sit next to the folder of the template, skipping hidden and
+// dependency directories.
+func indexFiles(docDir string) *fileIndex {
+	index := &fileIndex{byName: make(map[string][]string)}
+	root := filepath.Dir(docDir)
+	entries := 0
+	filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if entries++; entries > suggestEntries {
+			return filepath.SkipAll
+		}
+		if d.IsDir() {
+			name := d.Name()
+			if path != root && (strings.HasPrefix(name, ".") || name == "node_modules") {
+				return filepath.SkipDir
+			}
+			if rel, err := filepath.Rel(root, path); err == nil && strings.Count(rel, string(filepath.Separator)) >= suggestDepth {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		key := strings.ToLower(d.Name())
+		index.byName[key] = append(index.byName[key], path)
+		return nil
+	})
+	return index
+}
+
+// suggest returns the files with the base name of path, ignoring case,
+// ranked by how many trailing path components they share with it, so
+// "css/site.css" is preferred over "old/site.css" for "/css/site.css",
+// then by how close they are to the document.
+func (x *fileIndex) suggest(docDir, path string) []string {
+	want := strings.Split(strings.ToLower(filepath.ToSlash(path)), "/")
+	type candidate struct {
+		rel    string
+		shared int
+	}
+	var candidates []candidate
+	for _, found := range x.byName[strings.ToLower(filepath.Base(path))] {
+		rel, err := filepath.Rel(docDir, found)
+		if err != nil {
+			continue
+		}
+		have := strings.Split(strings.ToLower(filepath.ToSlash(found)), "/")
+		shared := 0
+		for shared < len(want) && shared < len(have) && want[len(want)-1-shared] == have[len(have)-1-shared] {
+			shared++
+		}
+		candidates = append(candidates, candidate{rel: filepath.ToSlash(rel), shared: shared})
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		if candidates[i].shared != candidates[j].shared {
+			return candidates[i].shared > candidates[j].shared
+		}
+		return strings.Count(candidates[i].rel, "/") < strings.Count(candidates[j].rel, "/")
+	})
+	var suggestions []string
+	for _, c := range candidates {
+		if len(suggestions) == maxSuggestions {
+			break
+		}
+		suggestions = append(suggestions, c.rel)
+	}
+	return suggestions
+}
@@ -0,0 +1,171 @@
+package chromedphelper
+
+import (
+	"encoding/json"
+	"sync"
+
+	"github.com/pesarkhobeee/that-cli-web-toolbox/pkg/baseline"
+	"github.com/pesarkhobeee/that-cli-web-toolbox/pkg/events"
+	"github.com/pesarkhobeee/that-cli-web-toolbox/pkg/har"
+	"github.com/pesarkhobeee/that-cli-web-toolbox/pkg/imagediff"
+	"github.com/pesarkhobeee/that-cli-web-toolbox/pkg/soft404"
+	"github.com/pesarkhobeee/that-cli-web-toolbox/pkg/techdetect"
+)
+
+// Result collects everything produced for one target so it can be
+// reported as a single structured document. Console messages and
+// exceptions may be added from event goroutines while actions run; use the
+// Add methods for those and JSON to serialize.
+type Result struct {
+	Target          string                   `json:"target"`
+	UnicodeTarget   string                   `json:"unicodeTarget,omitempty"`
+	Locale          string                   `json:"locale,omitempty"`
+	Consent         string                   `json:"consent,omitempty"`
+	Proxy           string                   `json:"proxy,omitempty"`
+	DevToolsURL     string                   `json:"devtoolsUrl,omitempty"`
+	Static          bool                     `json:"static,omitempty"`
+	Escalated       string                   `json:"escalated,omitempty"`
+	Profile         *FingerprintProfile      `json:"profile,omitempty"`
+	Page            *PageMetadata            `json:"page,omitempty"`
+	Redirects       []RedirectHop            `json:"redirects,omitempty"`
+	MissingFiles    []MissingFile            `json:"missingFiles,omitempty"`
+	Replay          *har.ReplayStats         `json:"replay,omitempty"`
+	Pathology       *Pathology               `json:"pathology,omitempty"`
+	Crash           *Crash                   `json:"crash,omitempty"`
+	Limit           *Limit                   `json:"limit,omitempty"`
+	Body            string                   `json:"body,omitempty"`
+	Outline         *Outline                 `json:"outline,omitempty"`
+	HTML            string                   `json:"html,omitempty"`
+	CriticalCSS     string                   `json:"criticalCss,omitempty"`
+	Selectors       []SelectorResult         `json:"selectors,omitempty"`
+	Redactions      map[string]int           `json:"redactions,omitempty"`
+	JSONPath        []JSONPathResult         `json:"jsonPath,omitempty"`
+	Extraction      *Extraction              `json:"extraction,omitempty"`
+	Interactives    []InteractiveElement     `json:"interactives,omitempty"`
+	Summary         *PageSummary             `json:"summary,omitempty"`
+	AboveFold       *AboveFold               `json:"aboveFold,omitempty"`
+	ContentMap      *ContentMap              `json:"contentMap,omitempty"`
+	Structure       *Structure               `json:"structure,omitempty"`
+	AccessibleNames []*AccessibleNames       `json:"accessibleNames,omitempty"`
+	Contrast        *ContrastReport          `json:"contrast,omitempty"`
+	Technologies    []techdetect.Technology  `json:"technologies,omitempty"`
+	NoJS            *NoJSComparison          `json:"noJs,omitempty"`
+	Googlebot       *GooglebotComparison     `json:"googlebot,omitempty"`
+	Soft404         *soft404.Verdict         `json:"soft404,omitempty"`
+	Overlays        *OverlayReport           `json:"overlays,omitempty"`
+	DesignDiff      *imagediff.Result        `json:"designDiff,omitempty"`
+	Baseline        *baseline.Check          `json:"baseline,omitempty"`
+	Keyboard        *KeyboardAudit           `json:"keyboard,omitempty"`
+	Chrome          *BrowserVersion          `json:"chrome,omitempty"`
+	Rendering       *Rendering               `json:"rendering,omitempty"`
+	Console         []events.ConsoleMessage  `json:"console,omitempty"`
+	Exceptions      []events.Exception       `json:"exceptions,omitempty"`
+	Files           []File                   `json:"files,omitempty"`
+	FailedRequests  []events.RequestFinished `json:"failedRequests,omitempty"`
+	Curl            []string                 `json:"curl,omitempty"`
+	Clipboard       *string                  `json:"clipboard,omitempty"`
+	Errors          *ErrorCounts             `json:"errors,omitempty"`
+	Checks          []CheckResult            `json:"checks,omitempty"`
+	Headers         []HeaderCheck            `json:"headers,omitempty"`
+	Metrics         *PageMetrics             `json:"metrics,omitempty"`
+	FailIf          []ConditionResult        `json:"failIf,omitempty"`
+	Fingerprint     string                   `json:"fingerprint,omitempty"`
+	NearDuplicates  []string                 `json:"nearDuplicates,omitempty"`
+	ActionErrors    []ActionError            `json:"actionErrors,omitempty"`
+	Error           string                   `json:"error,omitempty"`
+
+	mu sync.Mutex
+}
+
+// SelectorResult is the text of every element matching a selector.
+type SelectorResult struct {
+	Selector string   `json:"selector"`
+	Elements []string `json:"elements"`
+}
+
+// ErrorCounts tallies what went wrong while loading a page.
+type ErrorCounts struct {
+	// ConsoleErrors counts console.error/console.assert calls and uncaught
+	// exceptions.
+	ConsoleErrors int `json:"consoleErrors"`
+	// FailedRequests counts requests that got no response.
+	FailedRequests int `json:"failedRequests"`
+	// HTTPErrors counts responses with a 4xx or 5xx status.
+	HTTPErrors int `json:"httpErrors"`
+}
+
+// Total returns the number of errors of all kinds.
+func (c ErrorCounts) Total() int {
+	return c.ConsoleErrors + c.FailedRequests + c.HTTPErrors
+}
+
+// ActionError is the failure of one action of a run that went on with the
+// others.
+type ActionError struct {
+	Action string `json:"action"`
+	Error  string `json:"error"`
+}
+
+// File is an artifact written for the target.
+type File struct {
+	Kind string `json:"kind"`
+	// Location is the path or URL the sink reported, empty for stdout.
+	Location    string `json:"location"`
+	Name        string `json:"name"`
+	ContentType string `json:"contentType"`
+	Size        int    `json:"size"`
+	Selector    string `json:"selector,omitempty"`
+	// SHA256 is the hex-encoded checksum of the content.
+	SHA256 string `json:"sha256,omitempty"`
+}
+
+// AddConsole records a console message.
+func (r *Result) AddConsole(msg events.ConsoleMessage) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.Console = append(r.Console, msg)
+}
+
+// AddException records an uncaught exception.
+func (r *Result) AddException(ex events.Exception) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.Exceptions = append(r.Exceptions, ex)
+}
+
+// AddActionError records the failure of an action.
+func (r *Result) AddActionError(e ActionError) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.ActionErrors = append(r.ActionErrors, e)
+}
+
+// AddRedactions adds to the counts of replaced personal data.
+func (r *Result) AddRedactions(counts map[string]int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.Redactions == nil {
+		r.Redactions = make(map[string]int)
+	}
+	for kind, n := range counts {
+		r.Redactions[kind] += n
+	}
+}
+
+// AddFile records a written artifact.
+func (r *Result) AddFile(f File) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.Files = append(r.Files, f)
+}
+
+// resultJSON has Result's fields without its methods, so marshaling does
+// not recurse into MarshalJSON.
+type resultJSON Result
+
+// MarshalJSON serializes the result while holding its lock.
+func (r *Result) MarshalJSON() ([]byte, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return json.Marshal((*resultJSON)(r))
+}
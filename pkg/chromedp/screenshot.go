@@ -0,0 +1,279 @@
+package chromedphelper
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/chromedp/cdproto/cdp"
+	"github.com/chromedp/cdproto/emulation"
+	"github.com/chromedp/cdproto/page"
+	"github.com/chromedp/chromedp"
+)
+
+// ImageFormat is the encoding of a screenshot.
+type ImageFormat string
+
+// Supported screenshot formats.
+const (
+	PNG  ImageFormat = "png"
+	JPEG ImageFormat = "jpeg"
+	WebP ImageFormat = "webp"
+)
+
+// ParseImageFormat returns the format named by s (png, jpeg, jpg or webp).
+func ParseImageFormat(s string) (ImageFormat, error) {
+	switch strings.ToLower(s) {
+	case "png":
+		return PNG, nil
+	case "jpeg", "jpg":
+		return JPEG, nil
+	case "webp":
+		return WebP, nil
+	}
+	return "", fmt.Errorf("invalid image format %q (expected png, jpeg or webp)", s)
+}
+
+// Extension returns the file extension for the format, without the dot.
+func (f ImageFormat) Extension() string {
+	if f == JPEG {
+		return "jpg"
+	}
+	return string(f)
+}
+
+// ContentType returns the MIME type of the format.
+func (f ImageFormat) ContentType() string {
+	return "image/" + string(f)
+}
+
+// ScreenshotOptions controls the encoding of a screenshot and how it is
+// captured.
+type ScreenshotOptions struct {
+	Format ImageFormat
+	// Quality is the compression quality from 1 to 100 for JPEG and WebP.
+	Quality int
+	// OmitBackground replaces the page's default white background with a
+	// transparent one, so pages and elements without a background of their
+	// own are captured with transparent pixels in PNG and WebP. JPEG has no
+	// alpha channel and gets a black background instead.
+	OmitBackground bool
+	// BeyondViewport, if set, tells whether full page and element captures
+	// render what lies outside the viewport. By default they do, for which
+	// Chrome lays the page out at the captured size, stretching elements
+	// sized in vh units; false captures the layout of the viewport, leaving
+	// what lies outside it blank.
+	BeyondViewport *bool
+	// FromSurface, if set, tells whether to capture from the compositor
+	// surface, the default, or from the view.
+	FromSurface *bool
+}
+
+// params returns the CDP call capturing clip (nil for the viewport).
+func (o ScreenshotOptions) params(clip *page.Viewport) *page.CaptureScreenshotParams {
+	format := o.Format
+	if format == "" {
+		format = PNG
+	}
+	fromSurface := true
+	if o.FromSurface != nil {
+		fromSurface = *o.FromSurface
+	}
+	p := page.CaptureScreenshot().
+		WithFormat(page.CaptureScreenshotFormat(format)).
+		WithFromSurface(fromSurface)
+	if format != PNG && o.Quality > 0 {
+		p = p.WithQuality(int64(o.Quality))
+	}
+	if clip != nil {
+		beyond := true
+		if o.BeyondViewport != nil {
+			beyond = *o.BeyondViewport
+		}
+		p = p.WithClip(clip).WithCaptureBeyondViewport(beyond)
+	}
+	return p
+}
+
+// capture takes the screenshot of clip (nil for the viewport), with a
+// transparent default background for OmitBackground.
+func (o ScreenshotOptions) capture(ctx context.Context, clip *page.Viewport) ([]byte, error) {
+	if o.OmitBackground {
+		if err := emulation.SetDefaultBackgroundColorOverride().WithColor(&cdp.RGBA{}).Do(ctx); err != nil {
+			return nil, fmt.Errorf("failed to make the background transparent: %w", err)
+		}
+		defer func() {
+			// Without a color the override is cleared
+			if err := emulation.SetDefaultBackgroundColorOverride().Do(ctx); err != nil {
+				slog.Warn("Failed to restore the default background", "error", err)
+			}
+		}()
+	}
+	return o.params(clip).Do(ctx)
+}
+
+// ScreenshotFullPage captures the whole scrollable page.
+// Assumes NavigateAndPrepare has already been called.
+func (b *Browser) ScreenshotFullPage(ctx context.Context, opts ScreenshotOptions) ([]byte, error) {
+	slog.Debug("Taking full page screenshot", "format", opts.Format, "quality", opts.Quality)
+
+	var buf []byte
+	err := b.run(ctx, chromedp.ActionFunc(func(ctx context.Context) error {
+		var err error
+		buf, err = screenshotFullPage(ctx, opts)
+		return err
+	}))
+	if err != nil {
+		slog.Error("Failed to capture screenshot", "error", err)
+		return nil, err
+	}
+
+	slog.Debug("Screenshot captured successfully", "size", len(buf))
+	return buf, nil
+}
+
+// screenshotFullPage captures the whole scrollable page as it is laid out
+// now.
+func screenshotFullPage(ctx context.Context, opts ScreenshotOptions) ([]byte, error) {
+	var size struct {
+		Width  float64 `json:"width"`
+		Height float64 `json:"height"`
+	}
+	err := chromedp.Evaluate(`({
+		width: Math.max(document.documentElement.scrollWidth, window.innerWidth),
+		height: Math.max(document.documentElement.scrollHeight, window.innerHeight)
+	})`, &size).Do(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return opts.capture(ctx, &page.Viewport{Width: size.Width, Height: size.Height, Scale: 1})
+}
+
+// ScreenshotViewport captures only the visible part of the page.
+// Assumes NavigateAndPrepare has already been called.
+func (b *Browser) ScreenshotViewport(ctx context.Context, opts ScreenshotOptions) ([]byte, error) {
+	slog.Debug("Taking viewport screenshot", "format", opts.Format, "quality", opts.Quality)
+
+	var buf []byte
+	err := b.run(ctx, chromedp.ActionFunc(func(ctx context.Context) error {
+		var err error
+		buf, err = opts.capture(ctx, nil)
+		return err
+	}))
+	if err != nil {
+		slog.Error("Failed to capture viewport screenshot", "error", err)
+		return nil, err
+	}
+
+	slog.Debug("Viewport screenshot captured successfully", "size", len(buf))
+	return buf, nil
+}
+
+// ScreenshotElementAs captures the first element matching the given CSS
+// selector, waiting for it to become visible.
+// Assumes NavigateAndPrepare has already been called.
+func (b *Browser) ScreenshotElementAs(ctx context.Context, selector string, opts ScreenshotOptions) ([]byte, error) {
+	slog.Debug("Taking element screenshot", "selector", selector, "format", opts.Format, "quality", opts.Quality)
+
+	sel, err := json.Marshal(selector)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf []byte
+	err = b.run(ctx,
+		chromedp.WaitVisible(selector, chromedp.ByQuery),
+		chromedp.ActionFunc(func(ctx context.Context) error {
+			var err error
+			buf, err = screenshotElement(ctx, fmt.Sprintf("document.querySelector(%s)", sel), opts)
+			if errors.Is(err, errNoSize) {
+				return fmt.Errorf("element %q has no size", selector)
+			}
+			return err
+		}),
+	)
+	if err != nil {
+		slog.Error("Failed to capture element screenshot", "selector", selector, "error", err)
+		return nil, selectorError(err, selector, b.TargetURL)
+	}
+
+	slog.Debug("Element screenshot captured successfully", "selector", selector, "size", len(buf))
+	return buf, nil
+}
+
+// ScreenshotEach captures every element matching the given CSS selector
+// as a separate image, in document order, waiting for the first to become
+// visible. Elements without a size, such as hidden ones, are skipped. A
+// limit greater than zero stops after that many images.
+// Assumes NavigateAndPrepare has already been called.
+func (b *Browser) ScreenshotEach(ctx context.Context, selector string, limit int, opts ScreenshotOptions) ([][]byte, error) {
+	slog.Debug("Taking screenshot of each element", "selector", selector, "limit", limit, "format", opts.Format, "quality", opts.Quality)
+
+	sel, err := json.Marshal(selector)
+	if err != nil {
+		return nil, err
+	}
+
+	var images [][]byte
+	err = b.run(ctx,
+		chromedp.WaitVisible(selector, chromedp.ByQuery),
+		chromedp.ActionFunc(func(ctx context.Context) error {
+			var count int
+			if err := chromedp.Evaluate(fmt.Sprintf("document.querySelectorAll(%s).length", sel), &count).Do(ctx); err != nil {
+				return err
+			}
+			for i := 0; i < count && (limit <= 0 || len(images) < limit); i++ {
+				buf, err := screenshotElement(ctx, fmt.Sprintf("document.querySelectorAll(%s)[%d]", sel, i), opts)
+				if errors.Is(err, errNoSize) {
+					slog.Debug("Skipping element without size", "selector", selector, "index", i)
+					continue
+				}
+				if err != nil {
+					return fmt.Errorf("element %d: %w", i+1, err)
+				}
+				images = append(images, buf)
+			}
+			return nil
+		}),
+	)
+	if err != nil {
+		slog.Error("Failed to capture element screenshots", "selector", selector, "error", err)
+		return nil, selectorError(err, selector, b.TargetURL)
+	}
+
+	slog.Debug("Element screenshots captured successfully", "selector", selector, "count", len(images))
+	return images, nil
+}
+
+// errNoSize means an element has no width or height and cannot be
+// captured.
+var errNoSize = errors.New("element has no size")
+
+// screenshotElement scrolls the element expr evaluates to into view and
+// captures it.
+func screenshotElement(ctx context.Context, expr string, opts ScreenshotOptions) ([]byte, error) {
+	// Page coordinates of the element, so it can be captured even when it
+	// extends past the viewport
+	var box struct {
+		X      float64 `json:"x"`
+		Y      float64 `json:"y"`
+		Width  float64 `json:"width"`
+		Height float64 `json:"height"`
+	}
+	err := chromedp.Evaluate(fmt.Sprintf(`(() => {
+		const el = %s;
+		el.scrollIntoView({block: "nearest", inline: "nearest"});
+		const r = el.getBoundingClientRect();
+		return {x: r.left + window.scrollX, y: r.top + window.scrollY, width: r.width, height: r.height};
+	})()`, expr), &box).Do(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if box.Width == 0 || box.Height == 0 {
+		return nil, errNoSize
+	}
+	return opts.capture(ctx, &page.Viewport{X: box.X, Y: box.Y, Width: box.Width, Height: box.Height, Scale: 1})
+}
@@ -0,0 +1,124 @@
+package chromedphelper
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/chromedp/cdproto/page"
+	"github.com/chromedp/chromedp"
+)
+
+// selectorWatchScript records, in every document it is added to, when an
+// element matching each of the selectors passed to it first appears, in
+// milliseconds since navigation started.
+const selectorWatchScript = `((selectors) => {
+	const seen = window.__thatCliWebToolboxSelectors = {};
+	const check = () => {
+		for (const selector of selectors) {
+			if (seen[selector]) continue;
+			try {
+				if (document.querySelector(selector)) seen[selector] = {at: performance.now()};
+			} catch (e) {
+				seen[selector] = {error: e.message};
+			}
+		}
+		return selectors.every((selector) => seen[selector]);
+	};
+	if (check()) return;
+	const observer = new MutationObserver(() => {
+		if (check()) observer.disconnect();
+	});
+	observer.observe(document, {childList: true, subtree: true, attributes: true, characterData: true});
+})`
+
+// selectorTimesScript reads what selectorWatchScript recorded, along with
+// the current value of the first element matching each selector.
+const selectorTimesScript = `((selectors) => {
+	const seen = window.__thatCliWebToolboxSelectors || {};
+	return selectors.map((selector) => {
+		const entry = {selector, ...seen[selector]};
+		if (entry.at === undefined) return entry;
+		entry.found = true;
+		const el = document.querySelector(selector);
+		if (el) {
+			const value = 'value' in el && typeof el.value === 'string' ? el.value : el.textContent;
+			entry.value = value.replace(/\s+/g, ' ').trim();
+		}
+		return entry;
+	});
+})`
+
+// SelectorTime is when an element matching Selector first appeared on the
+// page.
+type SelectorTime struct {
+	Selector string `json:"selector"`
+	Found    bool   `json:"found"`
+	// At is the time from the start of navigation until the element
+	// appeared, in milliseconds.
+	At float64 `json:"at,omitempty"`
+	// Value is the text, or the value of a form control, of the first
+	// matching element when the times were read, whitespace collapsed.
+	Value string `json:"value,omitempty"`
+	// Error is set for an invalid selector.
+	Error string `json:"error,omitempty"`
+}
+
+// WatchSelectors records when elements matching selectors first appear in
+// the documents the tab loads from now on, down to the moment they are
+// inserted rather than when the page is prepared. Call it before
+// NavigateAndPrepare and read the times with SelectorTimes.
+func (b *Browser) WatchSelectors(ctx context.Context, selectors []string) error {
+	slog.Debug("Watching selectors", "selectors", selectors)
+
+	sel, err := json.Marshal(selectors)
+	if err != nil {
+		return err
+	}
+	err = b.run(ctx, chromedp.ActionFunc(func(ctx context.Context) error {
+		_, err := page.AddScriptToEvaluateOnNewDocument(fmt.Sprintf("(%s)(%s)", selectorWatchScript, sel)).Do(ctx)
+		return err
+	}))
+	if err != nil {
+		slog.Error("Failed to watch selectors", "error", err)
+		return fmt.Errorf("failed to watch selectors: %w", err)
+	}
+	return nil
+}
+
+// SelectorTimes returns when the selectors passed to WatchSelectors first
+// matched on the current page, waiting up to wait for those that have not
+// matched yet.
+func (b *Browser) SelectorTimes(ctx context.Context, selectors []string, wait time.Duration) ([]SelectorTime, error) {
+	sel, err := json.Marshal(selectors)
+	if err != nil {
+		return nil, err
+	}
+	expr := fmt.Sprintf("(%s)(%s)", selectorTimesScript, sel)
+
+	deadline := time.Now().Add(wait)
+	for {
+		var times []SelectorTime
+		if err := b.run(ctx, chromedp.Evaluate(expr, &times)); err != nil {
+			slog.Error("Failed to read selector times", "error", err)
+			return nil, fmt.Errorf("failed to read selector times: %w", err)
+		}
+		pending := 0
+		for _, t := range times {
+			if !t.Found && t.Error == "" {
+				pending++
+			}
+		}
+		if pending == 0 || !time.Now().Before(deadline) {
+			slog.Debug("Selector times read", "selectors", len(times), "missing", pending)
+			return times, nil
+		}
+		select {
+		case <-time.After(100 * time.Millisecond):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
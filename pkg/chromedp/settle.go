@@ -0,0 +1,44 @@
+package chromedphelper
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/chromedp/chromedp"
+)
+
+// settleScript returns how many milliseconds after its load event the page
+// received the last response of a request started within windowMs of it,
+// or -1 before the load event. Beacons are left out, as nothing waits for
+// them.
+const settleScript = `(windowMs) => {
+	const nav = performance.getEntriesByType('navigation')[0];
+	if (!nav || !nav.loadEventEnd) return -1;
+	let last = nav.loadEventEnd;
+	for (const r of performance.getEntriesByType('resource')) {
+		if (r.initiatorType === 'beacon' || r.startTime > nav.loadEventEnd + windowMs) continue;
+		last = Math.max(last, r.responseEnd);
+	}
+	return last - nav.loadEventEnd;
+}`
+
+// SettleTime returns how long after its load event the page kept loading
+// resources, counting requests started up to window after the load event,
+// from the Resource Timing entries of the page. Requests started later,
+// e.g. by actions scrolling the page, are left out.
+// Assumes NavigateAndPrepare has already been called.
+func (b *Browser) SettleTime(ctx context.Context, window time.Duration) (time.Duration, error) {
+	var ms float64
+	err := b.run(ctx, chromedp.Evaluate(fmt.Sprintf("(%s)(%d)", settleScript, window.Milliseconds()), &ms))
+	if err != nil {
+		return 0, err
+	}
+	if ms < 0 {
+		return 0, fmt.Errorf("the page has not finished loading")
+	}
+	settle := time.Duration(ms * float64(time.Millisecond))
+	slog.Debug("Page settle time measured", "url", b.TargetURL, "settle", settle)
+	return settle, nil
+}
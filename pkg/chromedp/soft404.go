@@ -0,0 +1,57 @@
+package chromedphelper
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"github.com/chromedp/chromedp"
+
+	"github.com/pesarkhobeee/that-cli-web-toolbox/pkg/soft404"
+)
+
+// soft404Text caps how much body text Soft404Signals returns.
+const soft404Text = 4000
+
+// Soft404Signals collects the final URL, status, title, headings, text,
+// layout ids and class names and robots directives of the current page
+// for soft404.
+// Assumes NavigateAndPrepare has already been called.
+func (b *Browser) Soft404Signals(ctx context.Context) (*soft404.Signals, error) {
+	slog.Debug("Collecting soft 404 signals")
+
+	signals := &soft404.Signals{}
+	err := b.run(ctx,
+		chromedp.Evaluate(fmt.Sprintf(`(() => {
+			const text = (document.body ? document.body.innerText : '').replace(/\s+/g, ' ').trim();
+			const markers = [];
+			for (const el of document.querySelectorAll('html, body, main, body > *, main > *')) {
+				if (el.id) markers.push(el.id);
+				for (const c of el.classList) markers.push(c);
+			}
+			const robots = document.querySelector('meta[name="robots" i]');
+			return {
+				URL: location.href,
+				Title: document.title.trim(),
+				Headings: Array.from(document.querySelectorAll('h1, h2'), h => h.innerText.replace(/\s+/g, ' ').trim()).filter(Boolean).slice(0, 10),
+				Text: text.slice(0, %d),
+				Words: text ? text.split(' ').length : 0,
+				Markers: markers,
+				Robots: robots ? robots.content : ''
+			};
+		})()`, soft404Text), signals),
+	)
+	if err != nil {
+		slog.Error("Failed to collect soft 404 signals", "error", err)
+		return nil, fmt.Errorf("failed to collect soft 404 signals: %w", err)
+	}
+
+	timing, err := b.navigationTiming(ctx)
+	if err != nil {
+		return nil, err
+	}
+	signals.Status = timing.Status
+
+	slog.Debug("Soft 404 signals collected", "url", signals.URL, "status", signals.Status, "words", signals.Words)
+	return signals, nil
+}
@@ -0,0 +1,179 @@
+package chromedphelper
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/chromedp/cdproto/input"
+	"github.com/chromedp/chromedp"
+)
+
+// StepKind is the type of an interaction step.
+type StepKind string
+
+// Supported interaction steps.
+const (
+	// StepClick clicks the first element matching Selector.
+	StepClick StepKind = "click"
+	// StepType types Value into the first element matching Selector.
+	StepType StepKind = "type"
+	// StepWaitVisible waits until an element matching Selector is visible.
+	StepWaitVisible StepKind = "waitvisible"
+	// StepScroll scrolls to "top", "bottom", a pixel offset, or the first
+	// element matching Selector.
+	StepScroll StepKind = "scroll"
+	// StepSleep pauses for the duration in Value (e.g. "500ms", "2s").
+	StepSleep StepKind = "sleep"
+	// StepClickAt clicks at the viewport coordinates "X,Y" in Value, for
+	// targets without elements such as canvas apps and maps.
+	StepClickAt StepKind = "click-at"
+	// StepTapAt taps with a touch gesture at the viewport coordinates "X,Y"
+	// in Value.
+	StepTapAt StepKind = "tap-at"
+)
+
+// Step is one interaction performed on the page before any capture, such
+// as dismissing a cookie banner or searching for content.
+type Step struct {
+	Kind     StepKind
+	Selector string
+	Value    string
+}
+
+// String returns the step in the KIND:ARGS form accepted by ParseStep.
+func (s Step) String() string {
+	switch s.Kind {
+	case StepType:
+		return string(s.Kind) + ":" + s.Selector + ":" + s.Value
+	case StepSleep, StepClickAt, StepTapAt:
+		return string(s.Kind) + ":" + s.Value
+	default:
+		return string(s.Kind) + ":" + s.Selector
+	}
+}
+
+// redacted returns the step like String, with the text of a type step,
+// which may be a password, masked for logs and errors.
+func (s Step) redacted() string {
+	if s.Kind == StepType {
+		return string(s.Kind) + ":" + s.Selector + ":REDACTED"
+	}
+	return s.String()
+}
+
+// ParseStep parses a step written as KIND:ARGS:
+//
+//	click:SELECTOR
+//	type:SELECTOR:TEXT   (the selector ends at the first colon)
+//	waitvisible:SELECTOR
+//	scroll:top|bottom|PIXELS|SELECTOR
+//	sleep:DURATION
+//	click-at:X,Y         (viewport coordinates in CSS pixels)
+//	tap-at:X,Y
+func ParseStep(spec string) (Step, error) {
+	kind, args, ok := strings.Cut(strings.TrimSpace(spec), ":")
+	if !ok || args == "" {
+		return Step{}, fmt.Errorf("invalid step %q (expected KIND:ARGS, e.g. click:#accept)", spec)
+	}
+
+	step := Step{Kind: StepKind(strings.ToLower(kind))}
+	switch step.Kind {
+	case StepClick, StepWaitVisible, StepScroll:
+		step.Selector = args
+	case StepType:
+		selector, text, ok := strings.Cut(args, ":")
+		if !ok || selector == "" {
+			// Not quoting spec, whose text may be a password
+			return Step{}, fmt.Errorf("invalid type step on %q (expected type:SELECTOR:TEXT)", selector)
+		}
+		step.Selector, step.Value = selector, text
+	case StepSleep:
+		if _, err := time.ParseDuration(args); err != nil {
+			return Step{}, fmt.Errorf("invalid step %q: %w", spec, err)
+		}
+		step.Value = args
+	case StepClickAt, StepTapAt:
+		if _, _, err := parsePoint(args); err != nil {
+			return Step{}, fmt.Errorf("invalid step %q: %w", spec, err)
+		}
+		step.Value = args
+	default:
+		return Step{}, fmt.Errorf("unknown step kind %q (expected click, type, waitvisible, scroll, sleep, click-at or tap-at)", kind)
+	}
+	return step, nil
+}
+
+// parsePoint parses viewport coordinates written as "X,Y".
+func parsePoint(s string) (x, y float64, err error) {
+	xs, ys, ok := strings.Cut(s, ",")
+	x, xerr := strconv.ParseFloat(strings.TrimSpace(xs), 64)
+	y, yerr := strconv.ParseFloat(strings.TrimSpace(ys), 64)
+	if !ok || xerr != nil || yerr != nil || x < 0 || y < 0 {
+		return 0, 0, fmt.Errorf("invalid coordinates %q (expected X,Y, e.g. 200,150)", s)
+	}
+	return x, y, nil
+}
+
+// action returns the chromedp action performing the step.
+func (s Step) action() chromedp.Action {
+	switch s.Kind {
+	case StepClick:
+		return chromedp.Click(s.Selector, chromedp.ByQuery, chromedp.NodeVisible)
+	case StepType:
+		return chromedp.SendKeys(s.Selector, s.Value, chromedp.ByQuery, chromedp.NodeVisible)
+	case StepWaitVisible:
+		return chromedp.WaitVisible(s.Selector, chromedp.ByQuery)
+	case StepScroll:
+		switch s.Selector {
+		case "top":
+			return chromedp.Evaluate(`window.scrollTo(0, 0)`, nil)
+		case "bottom":
+			return chromedp.Evaluate(`window.scrollTo(0, document.body.scrollHeight)`, nil)
+		}
+		if y, err := strconv.Atoi(s.Selector); err == nil {
+			return chromedp.Evaluate(fmt.Sprintf(`window.scrollTo(0, %d)`, y), nil)
+		}
+		return chromedp.ScrollIntoView(s.Selector, chromedp.ByQuery)
+	case StepSleep:
+		d, _ := time.ParseDuration(s.Value)
+		return chromedp.Sleep(d)
+	case StepClickAt:
+		x, y, _ := parsePoint(s.Value)
+		return chromedp.MouseClickXY(x, y)
+	case StepTapAt:
+		x, y, _ := parsePoint(s.Value)
+		return input.SynthesizeTapGesture(x, y).WithGestureSourceType(input.GestureTouch)
+	}
+	return chromedp.ActionFunc(func(ctx context.Context) error {
+		return fmt.Errorf("unknown step kind %q", s.Kind)
+	})
+}
+
+// stepsAction returns a chromedp action running steps in order, stopping
+// at the first failing step, which it stores in failed.
+func stepsAction(steps []Step, failed *Step) chromedp.Action {
+	return chromedp.ActionFunc(func(ctx context.Context) error {
+		for i, step := range steps {
+			slog.Debug("Executing interaction step", "index", i+1, "step", step.redacted())
+			if err := step.action().Do(ctx); err != nil {
+				*failed = step
+				slog.Error("Interaction step failed", "index", i+1, "step", step.redacted(), "error", err)
+				return fmt.Errorf("step %d (%s) failed: %w", i+1, step.redacted(), err)
+			}
+		}
+		return nil
+	})
+}
+
+// ExecuteSteps performs steps on the current page in order.
+// Assumes NavigateAndPrepare has already been called.
+func (b *Browser) ExecuteSteps(ctx context.Context, steps []Step) error {
+	slog.Debug("Executing interaction steps", "count", len(steps))
+	var failed Step
+	err := b.run(ctx, stepsAction(steps, &failed))
+	return selectorError(err, failed.Selector, b.TargetURL)
+}
@@ -0,0 +1,88 @@
+package chromedphelper
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+
+	"github.com/chromedp/cdproto/page"
+	"github.com/chromedp/chromedp"
+)
+
+// WebStorage is the localStorage and sessionStorage of an origin.
+type WebStorage struct {
+	Origin  string            `json:"origin"`
+	Local   map[string]string `json:"localStorage,omitempty"`
+	Session map[string]string `json:"sessionStorage,omitempty"`
+}
+
+const getStorageScript = `(() => {
+	const dump = (storage) => {
+		const items = {};
+		for (let i = 0; i < storage.length; i++) {
+			const key = storage.key(i);
+			items[key] = storage.getItem(key);
+		}
+		return items;
+	};
+	return {origin: location.origin, localStorage: dump(localStorage), sessionStorage: dump(sessionStorage)};
+})()`
+
+// restoreStorageScript writes a WebStorage into the storage of documents
+// of its origin before their scripts run.
+const restoreStorageScript = `((state) => {
+	if (location.origin !== state.origin) {
+		return;
+	}
+	try {
+		for (const [key, value] of Object.entries(state.localStorage || {})) {
+			localStorage.setItem(key, value);
+		}
+		for (const [key, value] of Object.entries(state.sessionStorage || {})) {
+			sessionStorage.setItem(key, value);
+		}
+	} catch (e) {}
+})`
+
+// GetStorage returns the web storage of the current page's origin.
+// Assumes NavigateAndPrepare has already been called.
+func (b *Browser) GetStorage(ctx context.Context) (*WebStorage, error) {
+	slog.Debug("Getting web storage")
+
+	var storage WebStorage
+	if err := b.run(ctx, chromedp.Evaluate(getStorageScript, &storage)); err != nil {
+		slog.Error("Failed to get web storage", "error", err)
+		return nil, fmt.Errorf("failed to get web storage: %w", err)
+	}
+
+	slog.Debug("Web storage retrieved successfully", "origin", storage.Origin, "localStorage", len(storage.Local), "sessionStorage", len(storage.Session))
+	return &storage, nil
+}
+
+// storageAction runs navigate with the Storage restored into the first
+// document of its origin. The storage is only written on that load, so
+// later navigations see what the page itself stored.
+func (b *Browser) storageAction(navigate chromedp.Action) chromedp.Action {
+	if b.Storage == nil {
+		return navigate
+	}
+	return chromedp.ActionFunc(func(ctx context.Context) error {
+		state, err := json.Marshal(b.Storage)
+		if err != nil {
+			return err
+		}
+		slog.Debug("Restoring web storage", "origin", b.Storage.Origin, "localStorage", len(b.Storage.Local), "sessionStorage", len(b.Storage.Session))
+		id, err := page.AddScriptToEvaluateOnNewDocument(fmt.Sprintf("(%s)(%s)", restoreStorageScript, state)).Do(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to restore web storage: %w", err)
+		}
+		if err := navigate.Do(ctx); err != nil {
+			return err
+		}
+		if err := page.RemoveScriptToEvaluateOnNewDocument(id).Do(ctx); err != nil {
+			return fmt.Errorf("failed to restore web storage: %w", err)
+		}
+		return nil
+	})
+}
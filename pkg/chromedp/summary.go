@@ -0,0 +1,60 @@
+package chromedphelper
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math"
+
+	"github.com/chromedp/chromedp"
+)
+
+// PageSummary is a quick overview of a loaded page for triage.
+type PageSummary struct {
+	Title string `json:"title"`
+	// URL is the document's final URL after any redirects.
+	URL string `json:"url"`
+	// Status is the document's HTTP status, 0 when unknown.
+	Status      int    `json:"status"`
+	Description string `json:"description"`
+	WordCount   int    `json:"wordCount"`
+	// ConsoleErrors and Requests are counted by the caller from the
+	// browser's events.
+	ConsoleErrors int `json:"consoleErrors"`
+	Requests      int `json:"requests"`
+	// LoadTimeMS is the time in milliseconds from navigation start to the
+	// end of the load event, 0 when the page has not finished loading.
+	LoadTimeMS int64 `json:"loadTimeMs"`
+}
+
+// Summary returns the title, final URL, status, meta description, word
+// count and load time of the current page.
+// Assumes NavigateAndPrepare has already been called.
+func (b *Browser) Summary(ctx context.Context) (*PageSummary, error) {
+	slog.Debug("Summarizing page")
+
+	meta, err := b.GetPageMetadata(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get page metadata: %w", err)
+	}
+	timing, err := b.navigationTiming(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var words int
+	if err := b.run(ctx, chromedp.Evaluate(`(document.body ? document.body.innerText : '').split(/\s+/).filter(Boolean).length`, &words)); err != nil {
+		slog.Error("Failed to count words", "error", err)
+		return nil, fmt.Errorf("failed to count words: %w", err)
+	}
+
+	summary := &PageSummary{
+		Title:       meta.Title,
+		URL:         meta.URL,
+		Status:      timing.Status,
+		Description: meta.Description,
+		WordCount:   words,
+		LoadTimeMS:  int64(math.Round(timing.LoadTime)),
+	}
+	slog.Debug("Page summarized", "url", summary.URL, "status", summary.Status, "words", words, "loadTimeMs", summary.LoadTimeMS)
+	return summary, nil
+}
@@ -0,0 +1,188 @@
+package chromedphelper
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+
+	"github.com/chromedp/chromedp"
+)
+
+// Kinds of SVGExport.
+const (
+	// SVGVector is an inline <svg> element, kept as vector graphics.
+	SVGVector = "svg"
+	// SVGForeignObject is any other element, embedded as XHTML in a
+	// <foreignObject>: browsers render it, but many design tools do not.
+	SVGForeignObject = "html"
+	// SVGRaster is a <canvas>, embedded as a PNG image.
+	SVGRaster = "canvas"
+)
+
+// svgScript serializes the element matching the selector passed to it as
+// a standalone SVG document. Computed styles that differ from those of a
+// fresh element of the same kind are inlined, so the document does not
+// depend on the page's stylesheets; images get absolute URLs, canvases
+// become PNG images, scripts are dropped, and elements referenced through
+// href="#id" or url(#id) from outside the element, such as gradients and
+// symbols, are copied into <defs>.
+const svgScript = `(selector) => {
+	const el = document.querySelector(selector);
+	if (!el) return null;
+	const SVG = 'http://www.w3.org/2000/svg', XHTML = 'http://www.w3.org/1999/xhtml';
+	const rect = el.getBoundingClientRect();
+	const width = Math.ceil(rect.width), height = Math.ceil(rect.height);
+	const here = location.href.split('#')[0];
+
+	const sandbox = document.createElement('div');
+	sandbox.style.cssText = 'all: initial; position: absolute; width: 0; height: 0; overflow: hidden';
+	const sandboxSVG = document.createElementNS(SVG, 'svg');
+	sandbox.appendChild(sandboxSVG);
+	document.documentElement.appendChild(sandbox);
+	const defaults = new Map();
+	const defaultStyle = (node) => {
+		const key = node.namespaceURI + ' ' + node.localName;
+		if (!defaults.has(key)) {
+			const probe = document.createElementNS(node.namespaceURI, node.localName);
+			(node.namespaceURI === SVG ? sandboxSVG : sandbox).appendChild(probe);
+			const style = {}, cs = getComputedStyle(probe);
+			for (const prop of cs) style[prop] = cs.getPropertyValue(prop);
+			probe.remove();
+			defaults.set(key, style);
+		}
+		return defaults.get(key);
+	};
+	const inline = (src, dst) => {
+		const cs = getComputedStyle(src), def = defaultStyle(src);
+		let style = '';
+		for (const prop of cs) {
+			const value = cs.getPropertyValue(prop);
+			if (value !== def[prop]) style += prop + ':' + value.split('url("' + here + '#').join('url("#') + ';';
+		}
+		if (style) dst.setAttribute('style', style);
+		else dst.removeAttribute('style');
+		for (let i = 0; i < src.children.length; i++) inline(src.children[i], dst.children[i]);
+	};
+	const canvasImage = (canvas) => {
+		try {
+			return canvas.toDataURL('image/png');
+		} catch (e) {
+			// A canvas that drew images of other origins cannot be read
+			return '';
+		}
+	};
+
+	let root, kind;
+	try {
+		if (el instanceof HTMLCanvasElement) {
+			kind = 'canvas';
+			root = document.createElementNS(SVG, 'svg');
+			const image = document.createElementNS(SVG, 'image');
+			image.setAttribute('width', width);
+			image.setAttribute('height', height);
+			image.setAttribute('href', canvasImage(el));
+			root.appendChild(image);
+		} else {
+			const clone = el.cloneNode(true);
+			inline(el, clone);
+			const all = (node, query) => [node, ...node.querySelectorAll(query)].filter((n) => n.matches(query));
+			const images = all(el, 'img'), cloneImages = all(clone, 'img');
+			cloneImages.forEach((img, i) => {
+				img.setAttribute('src', images[i].currentSrc || images[i].src);
+				img.removeAttribute('srcset');
+			});
+			for (const image of all(clone, 'image')) {
+				for (const name of ['href', 'xlink:href']) {
+					const value = image.getAttribute(name);
+					if (value && !value.startsWith('#')) image.setAttribute(name, new URL(value, document.baseURI).href);
+				}
+			}
+			const canvases = all(el, 'canvas');
+			all(clone, 'canvas').forEach((canvas, i) => {
+				const img = document.createElementNS(XHTML, 'img');
+				img.setAttribute('src', canvasImage(canvases[i]));
+				img.setAttribute('style', canvas.getAttribute('style') || '');
+				canvas.replaceWith(img);
+			});
+			clone.querySelectorAll('script').forEach((script) => script.remove());
+
+			if (el instanceof SVGSVGElement) {
+				kind = 'svg';
+				root = clone;
+			} else {
+				kind = 'html';
+				// The element is placed at the origin of the document
+				clone.setAttribute('style', (clone.getAttribute('style') || '') + 'margin:0;position:static;');
+				root = document.createElementNS(SVG, 'svg');
+				const object = document.createElementNS(SVG, 'foreignObject');
+				object.setAttribute('width', width);
+				object.setAttribute('height', height);
+				object.appendChild(clone);
+				root.appendChild(object);
+			}
+		}
+	} finally {
+		sandbox.remove();
+	}
+	root.setAttribute('width', width);
+	root.setAttribute('height', height);
+	if (!root.hasAttribute('viewBox')) root.setAttribute('viewBox', '0 0 ' + width + ' ' + height);
+
+	const ids = new Set();
+	for (const node of [root, ...root.querySelectorAll('*')]) {
+		for (const attr of node.attributes) {
+			if (node.namespaceURI === SVG && attr.localName === 'href' && attr.value.startsWith('#')) ids.add(attr.value.slice(1));
+			for (const m of attr.value.matchAll(/url\(["']?#([^"')]+)["']?\)/g)) ids.add(m[1]);
+		}
+	}
+	const referenced = [...ids]
+		.filter((id) => !root.querySelector('#' + CSS.escape(id)))
+		.map((id) => document.getElementById(id))
+		.filter((node) => node && node.namespaceURI === SVG);
+	if (referenced.length > 0) {
+		const defs = document.createElementNS(SVG, 'defs');
+		referenced.forEach((node) => defs.appendChild(node.cloneNode(true)));
+		root.insertBefore(defs, root.firstChild);
+	}
+	return {svg: new XMLSerializer().serializeToString(root), kind};
+}`
+
+// SVGExport is an element serialized as a standalone SVG document.
+type SVGExport struct {
+	SVG string `json:"svg"`
+	// Kind tells how the element is rendered: SVGVector, SVGForeignObject
+	// or SVGRaster.
+	Kind string `json:"kind"`
+}
+
+// ElementSVG serializes the first element matching selector, waiting for
+// it to become visible, as a standalone SVG document. Inline SVG, such as
+// most charts and diagrams, stays vector graphics; see SVGExport.Kind for
+// other elements. Images are referenced by URL, as are fonts, so the
+// document shows them only where they can be loaded.
+// Assumes NavigateAndPrepare has already been called.
+func (b *Browser) ElementSVG(ctx context.Context, selector string) (*SVGExport, error) {
+	slog.Debug("Exporting element as SVG", "selector", selector)
+
+	sel, err := json.Marshal(selector)
+	if err != nil {
+		return nil, err
+	}
+
+	var export *SVGExport
+	err = b.run(ctx,
+		chromedp.WaitVisible(selector, chromedp.ByQuery),
+		chromedp.Evaluate(fmt.Sprintf("(%s)(%s)", svgScript, sel), &export),
+	)
+	if err != nil {
+		slog.Error("Failed to export element as SVG", "selector", selector, "error", err)
+		return nil, selectorError(err, selector, b.TargetURL)
+	}
+	if export == nil {
+		return nil, fmt.Errorf("no element matches %q", selector)
+	}
+
+	slog.Debug("Element exported as SVG", "selector", selector, "kind", export.Kind, "size", len(export.SVG))
+	return export, nil
+}
@@ -0,0 +1,74 @@
+package chromedphelper
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+
+	"github.com/chromedp/chromedp"
+
+	"github.com/pesarkhobeee/that-cli-web-toolbox/pkg/techdetect"
+)
+
+// maxTechHTML caps how much of the document TechSignals returns.
+const maxTechHTML = 2 << 20
+
+// TechSignals collects the markup, script URLs, meta elements, cookie
+// names and the values of globals of the current page for techdetect.
+// The main document's response headers are not known to the page; callers
+// fill in Headers from the page's events.
+// Assumes NavigateAndPrepare has already been called.
+func (b *Browser) TechSignals(ctx context.Context, globals []string) (*techdetect.Signals, error) {
+	slog.Debug("Collecting technology signals", "globals", len(globals))
+
+	paths, err := json.Marshal(globals)
+	if err != nil {
+		return nil, err
+	}
+	signals := &techdetect.Signals{}
+	err = b.run(ctx,
+		chromedp.Evaluate(fmt.Sprintf(`(() => {
+			const meta = {};
+			for (const m of document.querySelectorAll('meta[content]')) {
+				const name = (m.getAttribute('name') || m.getAttribute('property') || '').toLowerCase();
+				if (name && !(name in meta)) meta[name] = m.getAttribute('content');
+			}
+			const globals = {};
+			for (const path of %s) {
+				let value = window;
+				try {
+					for (const key of path.split('.')) {
+						if (value === null || value === undefined) break;
+						value = value[key];
+					}
+				} catch (e) {
+					value = undefined;
+				}
+				if (value === null || value === undefined) continue;
+				globals[path] = (typeof value === 'string' || typeof value === 'number') ? String(value) : '';
+			}
+			return {
+				HTML: document.documentElement.outerHTML.slice(0, %d),
+				ScriptSrcs: Array.from(document.scripts, s => s.src).filter(Boolean),
+				Meta: meta,
+				Globals: globals
+			};
+		})()`, paths, maxTechHTML), signals),
+	)
+	if err != nil {
+		slog.Error("Failed to collect technology signals", "error", err)
+		return nil, fmt.Errorf("failed to collect technology signals: %w", err)
+	}
+
+	cookies, err := b.GetCookies(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, c := range cookies {
+		signals.Cookies = append(signals.Cookies, c.Name)
+	}
+
+	slog.Debug("Technology signals collected", "scripts", len(signals.ScriptSrcs), "meta", len(signals.Meta), "globals", len(signals.Globals))
+	return signals, nil
+}
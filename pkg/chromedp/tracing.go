@@ -0,0 +1,132 @@
+package chromedphelper
+
+import (
+	"encoding/json"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/chromedp/cdproto/target"
+	"github.com/chromedp/chromedp"
+
+	"github.com/pesarkhobeee/that-cli-web-toolbox/pkg/tracing"
+)
+
+// cdpTracer turns the protocol messages of a browser into spans: every
+// command a tab sends becomes a child of the operation running on that tab.
+// Browsers started from a context with a tracer receive chromedp's
+// protocol log through debugf.
+type cdpTracer struct {
+	mu sync.Mutex
+	// active is the span of the operation running on each tab's session.
+	active map[target.SessionID]*tracing.Span
+	// pending holds the commands awaiting a response, by message ID.
+	pending map[int64]cdpCall
+}
+
+type cdpCall struct {
+	method string
+	start  time.Time
+	span   *tracing.Span
+}
+
+// cdpMessage holds the fields of a protocol message that identify it.
+type cdpMessage struct {
+	ID        int64            `json:"id"`
+	SessionID target.SessionID `json:"sessionId"`
+	Method    string           `json:"method"`
+	Error     *struct {
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+func newCDPTracer() *cdpTracer {
+	return &cdpTracer{
+		active:  make(map[target.SessionID]*tracing.Span),
+		pending: make(map[int64]cdpCall),
+	}
+}
+
+// contextOptions returns the chromedp option feeding the protocol log to c.
+func (c *cdpTracer) contextOptions() []chromedp.ContextOption {
+	if c == nil {
+		return nil
+	}
+	return []chromedp.ContextOption{chromedp.WithDebugf(c.debugf)}
+}
+
+// debugf receives chromedp's protocol log, in which sent messages are
+// logged as "-> %s" and received ones as "<- %s" with the raw JSON.
+func (c *cdpTracer) debugf(format string, args ...any) {
+	if len(args) != 1 || (format != "-> %s" && format != "<- %s") {
+		return
+	}
+	data, ok := args[0].([]byte)
+	if !ok {
+		return
+	}
+	var msg cdpMessage
+	if err := json.Unmarshal(data, &msg); err != nil || msg.ID == 0 {
+		// Events have no ID
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if format == "-> %s" {
+		if span := c.active[msg.SessionID]; span != nil {
+			c.pending[msg.ID] = cdpCall{method: msg.Method, start: time.Now(), span: span}
+		}
+		return
+	}
+	call, ok := c.pending[msg.ID]
+	if !ok {
+		return
+	}
+	delete(c.pending, msg.ID)
+	errMsg := ""
+	if msg.Error != nil {
+		errMsg = msg.Error.Message
+	}
+	call.span.Record(call.method, call.start, time.Now(), errMsg, tracing.String("rpc.system", "cdp"))
+}
+
+// begin attributes the commands sent on session to span until end.
+func (c *cdpTracer) begin(session target.SessionID, span *tracing.Span) {
+	if c == nil || span == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.active[session] = span
+}
+
+func (c *cdpTracer) end(session target.SessionID, span *tracing.Span) {
+	if c == nil || span == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.active[session] == span {
+		delete(c.active, session)
+	}
+}
+
+// operationName names the span of a run call after the Browser method
+// making it, such as "Browser.TakeScreenshot".
+func operationName() string {
+	pcs := make([]uintptr, 1)
+	// Skip runtime.Callers, operationName and run
+	if runtime.Callers(3, pcs) == 0 {
+		return "Browser.run"
+	}
+	frame, _ := runtime.CallersFrames(pcs).Next()
+	name := frame.Function[strings.LastIndex(frame.Function, "/")+1:]
+	_, name, _ = strings.Cut(name, ".")
+	name = strings.NewReplacer("(*", "", ")", "").Replace(name)
+	if i := strings.Index(name, ".func"); i >= 0 {
+		name = name[:i]
+	}
+	return name
+}
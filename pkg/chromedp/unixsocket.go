@@ -0,0 +1,104 @@
+package chromedphelper
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/gobwas/ws"
+)
+
+// UnixSocketPrefix marks a remoteDebuggingPort of the form unix:PATH, a
+// server relaying DevTools over the Unix socket at PATH, such as the
+// daemon command.
+const UnixSocketPrefix = "unix:"
+
+// unixSocketDomain ends the host names standing for a Unix socket in
+// DevTools websocket URLs. Being reserved, it never resolves.
+const unixSocketDomain = ".unix.invalid"
+
+// unixSockets maps the host names handed out by unixSocketBrowser to their
+// sockets, for as long as the allocator using them lives.
+var unixSockets sync.Map
+
+// routeUnixSockets makes the gobwas/ws dialer chromedp uses dial hosts of
+// unixSockets over their socket, and all others as before. Neither chromedp
+// nor gobwas/ws take a dialer per connection, so this is done once, by the
+// first allocator for a socket rather than for every process.
+var routeUnixSockets = sync.OnceFunc(func() {
+	next := ws.DefaultDialer.NetDial
+	if next == nil {
+		var dialer net.Dialer
+		next = dialer.DialContext
+	}
+	ws.DefaultDialer.NetDial = func(ctx context.Context, network, addr string) (net.Conn, error) {
+		if host, _, err := net.SplitHostPort(addr); err == nil {
+			if socket, ok := unixSockets.Load(host); ok {
+				var dialer net.Dialer
+				return dialer.DialContext(ctx, "unix", socket.(string))
+			}
+		}
+		return next(ctx, network, addr)
+	}
+})
+
+// registerUnixSocket returns a new host name standing for socket until
+// release is called.
+func registerUnixSocket(socket string) (host string, release func(), err error) {
+	token := make([]byte, 16)
+	if _, err := rand.Read(token); err != nil {
+		return "", nil, err
+	}
+	host = hex.EncodeToString(token) + unixSocketDomain
+	unixSockets.Store(host, socket)
+	routeUnixSockets()
+	return host, func() { unixSockets.Delete(host) }, nil
+}
+
+// unixSocketBrowser asks the server on socket for the URL of its DevTools
+// websocket, which dials the socket until release is called.
+func unixSocketBrowser(ctx context.Context, socket string) (wsURL string, release func(), err error) {
+	client := &http.Client{
+		Timeout: 30 * time.Second,
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				var dialer net.Dialer
+				return dialer.DialContext(ctx, "unix", socket)
+			},
+		},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://localhost/json/version", nil)
+	if err != nil {
+		return "", nil, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", nil, &RemoteError{Address: socket, Err: fmt.Errorf("failed to connect to DevTools on %s: %w", socket, err)}
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", nil, &RemoteError{Address: socket, Err: fmt.Errorf("DevTools on %s returned status %d", socket, resp.StatusCode)}
+	}
+	var version struct {
+		WebSocketDebuggerURL string `json:"webSocketDebuggerUrl"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&version); err != nil {
+		return "", nil, fmt.Errorf("invalid response from DevTools on %s: %w", socket, err)
+	}
+	u, err := url.Parse(version.WebSocketDebuggerURL)
+	if err != nil || u.Scheme != "ws" || u.Path == "" {
+		return "", nil, fmt.Errorf("invalid DevTools websocket URL %q from %s", version.WebSocketDebuggerURL, socket)
+	}
+	// Whatever host the server names, the websocket goes over its socket
+	if u.Host, release, err = registerUnixSocket(socket); err != nil {
+		return "", nil, fmt.Errorf("failed to name socket %s: %w", socket, err)
+	}
+	return u.String(), release, nil
+}
@@ -0,0 +1,89 @@
+package chromedphelper
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gobwas/ws"
+)
+
+func TestUnixSocketDevTools(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "devtools.sock")
+	ln, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	upgraded := make(chan string, 1)
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /json/version", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"webSocketDebuggerUrl": "ws://%s/devtools/browser"}`, r.Host)
+	})
+	mux.HandleFunc("GET /devtools/browser", func(w http.ResponseWriter, r *http.Request) {
+		conn, _, _, err := ws.UpgradeHTTP(r, w)
+		if err != nil {
+			t.Errorf("failed to upgrade: %v", err)
+			return
+		}
+		upgraded <- r.URL.Path
+		conn.Close()
+	})
+	srv := &http.Server{Handler: mux}
+	go srv.Serve(ln)
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	wsURL, release, err := unixSocketBrowser(ctx, socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	u, err := url.Parse(wsURL)
+	if err != nil || u.Scheme != "ws" || !strings.HasSuffix(u.Hostname(), unixSocketDomain) || u.Path != "/devtools/browser" {
+		t.Errorf("websocket URL %s, want ws://*%s/devtools/browser", wsURL, unixSocketDomain)
+	}
+
+	// The URL reaches the socket through the dialer chromedp uses
+	conn, _, _, err := ws.Dial(ctx, wsURL)
+	if err != nil {
+		t.Fatalf("failed to dial %s: %v", wsURL, err)
+	}
+	conn.Close()
+	select {
+	case path := <-upgraded:
+		if path != "/devtools/browser" {
+			t.Errorf("the server upgraded %s, want /devtools/browser", path)
+		}
+	case <-ctx.Done():
+		t.Fatal("the server received no websocket")
+	}
+
+	// Only hosts handed out are routed, and only until they are released
+	forged := "ws://" + hex.EncodeToString([]byte(socket)) + unixSocketDomain + "/devtools/browser"
+	release()
+	for _, wsURL := range []string{wsURL, forged} {
+		if conn, _, _, err := ws.Dial(ctx, wsURL); err == nil {
+			conn.Close()
+			t.Errorf("dialing %s reached the socket", wsURL)
+		}
+	}
+}
+
+func TestUnixSocketUnreachable(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "missing.sock")
+	_, err := InitializeChromedpContext(context.Background(), "", 0, 0, UnixSocketPrefix+socket, "")
+	if err == nil {
+		t.Fatal("connecting to a missing socket succeeded")
+	}
+	if !errors.Is(err, ErrRemoteUnreachable) {
+		t.Errorf("got %v, want ErrRemoteUnreachable", err)
+	}
+}
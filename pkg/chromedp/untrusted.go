@@ -0,0 +1,84 @@
+package chromedphelper
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/chromedp/cdproto/browser"
+	"github.com/chromedp/cdproto/page"
+	"github.com/chromedp/chromedp"
+)
+
+// requestAllowed reports whether the page may make a request to rawURL.
+// Untrusted pages may only load data: and blob: URLs and, over the
+// network, the AllowedHosts; other pages may load anything AllowedHosts
+// allows.
+func (b *Browser) requestAllowed(rawURL string) bool {
+	if !b.Untrusted {
+		return b.AllowedHosts.Allowed(rawURL)
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return false
+	}
+	switch strings.ToLower(u.Scheme) {
+	case "data", "blob", "about":
+		return true
+	case "http", "https", "ws", "wss":
+		return b.AllowedHosts != nil && b.AllowedHosts.AllowedHost(u.Hostname())
+	}
+	return false
+}
+
+// navigateAction loads the target. An Untrusted file: target is read here
+// and its content loaded into an empty page, instead of being opened from
+// a file: URL, whose pages may embed other local files.
+func (b *Browser) navigateAction() chromedp.Action {
+	if !b.Untrusted || !strings.HasPrefix(b.TargetURL, "file://") {
+		return chromedp.Navigate(b.TargetURL)
+	}
+	return chromedp.ActionFunc(func(ctx context.Context) error {
+		u, err := url.Parse(b.TargetURL)
+		if err != nil {
+			return fmt.Errorf("invalid target %q: %w", b.TargetURL, err)
+		}
+		html, err := os.ReadFile(u.Path)
+		if err != nil {
+			return fmt.Errorf("failed to read untrusted document: %w", err)
+		}
+		slog.Debug("Loading untrusted document into an empty page", "file", u.Path, "bytes", len(html))
+
+		if err := chromedp.Navigate("about:blank").Do(ctx); err != nil {
+			return err
+		}
+		tree, err := page.GetFrameTree().Do(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to get frame tree: %w", err)
+		}
+		if err := page.SetDocumentContent(tree.Frame.ID, string(html)).Do(ctx); err != nil {
+			return fmt.Errorf("failed to load untrusted document: %w", err)
+		}
+		return chromedp.Poll(`document.readyState === "complete"`, nil).Do(ctx)
+	})
+}
+
+// denyDownloadsAction keeps Untrusted pages from saving files.
+func (b *Browser) denyDownloadsAction() chromedp.Action {
+	return chromedp.ActionFunc(func(ctx context.Context) error {
+		if !b.Untrusted {
+			return nil
+		}
+		deny := browser.SetDownloadBehavior(browser.SetDownloadBehaviorBehaviorDeny)
+		if c := chromedp.FromContext(ctx); c != nil && c.BrowserContextID != "" {
+			deny = deny.WithBrowserContextID(c.BrowserContextID)
+		}
+		if err := deny.Do(ctx); err != nil {
+			return fmt.Errorf("failed to deny downloads: %w", err)
+		}
+		return nil
+	})
+}
@@ -0,0 +1,48 @@
+package chromedphelper
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+
+	"github.com/chromedp/cdproto/browser"
+	"github.com/chromedp/chromedp"
+)
+
+// BrowserVersion identifies the Chrome a Browser runs in.
+type BrowserVersion struct {
+	// Product is the browser's name and version, e.g.
+	// "HeadlessChrome/139.0.7258.5".
+	Product         string `json:"product"`
+	ProtocolVersion string `json:"protocolVersion"`
+	Revision        string `json:"revision"`
+	UserAgent       string `json:"userAgent"`
+	JSVersion       string `json:"jsVersion"`
+}
+
+// Version returns the version of the browser, starting Chrome (or
+// connecting to the remote one) if that has not happened yet. It does not
+// need NavigateAndPrepare.
+func (b *Browser) Version(ctx context.Context) (*BrowserVersion, error) {
+	slog.Debug("Getting browser version")
+
+	var v BrowserVersion
+	err := b.run(ctx, chromedp.ActionFunc(func(ctx context.Context) error {
+		var err error
+		v.ProtocolVersion, v.Product, v.Revision, v.UserAgent, v.JSVersion, err = browser.GetVersion().Do(ctx)
+		return err
+	}))
+	if err != nil {
+		slog.Error("Failed to get browser version", "error", err)
+		return nil, err
+	}
+
+	slog.Debug("Browser version retrieved", "product", v.Product)
+	return &v, nil
+}
+
+// Number returns the version number of Product, e.g. "139.0.7258.5".
+func (v *BrowserVersion) Number() string {
+	_, number, _ := strings.Cut(v.Product, "/")
+	return number
+}
@@ -0,0 +1,440 @@
+// Package chromefortesting downloads builds of Chrome for Testing, the
+// Chrome flavor published for automation with every release, and keeps
+// them in a directory, so every machine renders with the same Chrome.
+//
+//	s := chromefortesting.Store{Dir: dir}
+//	c := chromefortesting.New()
+//	version, err := c.Resolve(ctx, "stable")
+//	inst, err := s.Install(ctx, c, chromefortesting.ProductChrome, version, chromefortesting.CurrentPlatform())
+//	exe, ok := s.Current(chromefortesting.ProductChrome)
+package chromefortesting
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"slices"
+	"strings"
+	"time"
+)
+
+// DefaultDownloadURL is where Chrome for Testing builds are published, as
+// VERSION/PLATFORM/PRODUCT-PLATFORM.zip.
+const DefaultDownloadURL = "https://storage.googleapis.com/chrome-for-testing-public"
+
+// DefaultVersionsURL lists the current version of each release channel.
+const DefaultVersionsURL = "https://googlechromelabs.github.io/chrome-for-testing/last-known-good-versions.json"
+
+// Products of Chrome for Testing.
+const (
+	ProductChrome        = "chrome"
+	ProductHeadlessShell = "chrome-headless-shell"
+)
+
+// Products lists the products Install accepts.
+var Products = []string{ProductChrome, ProductHeadlessShell}
+
+// Channels lists the release channels Resolve accepts.
+var Channels = []string{"stable", "beta", "dev", "canary"}
+
+// Platforms lists the platforms builds are published for.
+var Platforms = []string{"linux64", "mac-arm64", "mac-x64", "win32", "win64"}
+
+var versionPattern = regexp.MustCompile(`^[0-9]+\.[0-9]+\.[0-9]+\.[0-9]+$`)
+
+// CurrentPlatform returns the platform of the running system, or "" when
+// no builds are published for it, such as Linux on ARM.
+func CurrentPlatform() string {
+	return platform(runtime.GOOS, runtime.GOARCH)
+}
+
+func platform(goos, goarch string) string {
+	switch {
+	case goos == "linux" && goarch == "amd64":
+		return "linux64"
+	case goos == "darwin" && goarch == "arm64":
+		return "mac-arm64"
+	case goos == "darwin" && goarch == "amd64":
+		return "mac-x64"
+	case goos == "windows" && goarch == "386":
+		return "win32"
+	case goos == "windows" && goarch == "amd64":
+		return "win64"
+	}
+	return ""
+}
+
+// executable returns the path of product's executable in the archive
+// of platform.
+func executable(product, platform string) string {
+	dir := product + "-" + platform
+	switch {
+	case strings.HasPrefix(platform, "win"):
+		return filepath.Join(dir, product+".exe")
+	case product == ProductChrome && strings.HasPrefix(platform, "mac"):
+		return filepath.Join(dir, "Google Chrome for Testing.app", "Contents", "MacOS", "Google Chrome for Testing")
+	}
+	return filepath.Join(dir, product)
+}
+
+// Client fetches versions and builds.
+type Client struct {
+	DownloadURL string
+	VersionsURL string
+	HTTP        *http.Client
+}
+
+// New returns a client of the public Chrome for Testing endpoints.
+func New() *Client {
+	return &Client{
+		DownloadURL: DefaultDownloadURL,
+		VersionsURL: DefaultVersionsURL,
+		// Builds are over 100MB; the context bounds the download instead
+		HTTP: &http.Client{},
+	}
+}
+
+// Resolve returns the version a channel, e.g. "stable", currently ships,
+// or version itself if it is one, e.g. "131.0.6778.85".
+func (c *Client) Resolve(ctx context.Context, version string) (string, error) {
+	if versionPattern.MatchString(version) {
+		return version, nil
+	}
+	channel := strings.ToLower(version)
+	if !slices.Contains(Channels, channel) {
+		return "", fmt.Errorf("%q is neither a version such as 131.0.6778.85 nor a channel (%s)", version, strings.Join(Channels, ", "))
+	}
+	slog.Debug("Resolving Chrome for Testing channel", "channel", channel, "url", c.VersionsURL)
+	resp, err := c.get(ctx, c.VersionsURL)
+	if err != nil {
+		return "", err
+	}
+	defer closeBody(resp)
+	var versions struct {
+		Channels map[string]struct {
+			Version string `json:"version"`
+		} `json:"channels"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&versions); err != nil {
+		return "", fmt.Errorf("failed to parse %s: %w", c.VersionsURL, err)
+	}
+	for name, ch := range versions.Channels {
+		if strings.EqualFold(name, channel) && versionPattern.MatchString(ch.Version) {
+			return ch.Version, nil
+		}
+	}
+	return "", fmt.Errorf("no version of channel %s in %s", channel, c.VersionsURL)
+}
+
+// download writes the archive of product at version for platform to w.
+func (c *Client) download(ctx context.Context, product, version, platform string, w io.Writer) (int64, error) {
+	url := fmt.Sprintf("%s/%s/%s/%s-%s.zip", strings.TrimSuffix(c.DownloadURL, "/"), version, platform, product, platform)
+	slog.Debug("Downloading Chrome for Testing", "url", url)
+	resp, err := c.get(ctx, url)
+	if err != nil {
+		return 0, err
+	}
+	defer closeBody(resp)
+	n, err := io.Copy(w, resp.Body)
+	if err != nil {
+		return n, fmt.Errorf("failed to download %s: %w", url, err)
+	}
+	return n, nil
+}
+
+func (c *Client) get(ctx context.Context, url string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.HTTP.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch %s: %w", url, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		closeBody(resp)
+		if resp.StatusCode == http.StatusNotFound {
+			return nil, fmt.Errorf("%s: not found, no such version or platform", url)
+		}
+		return nil, fmt.Errorf("%s: %s", url, resp.Status)
+	}
+	return resp, nil
+}
+
+func closeBody(resp *http.Response) {
+	if err := resp.Body.Close(); err != nil {
+		slog.Warn("failed to close response body", "error", err)
+	}
+}
+
+// Installation is a build in a Store.
+type Installation struct {
+	Product  string `json:"product"`
+	Version  string `json:"version"`
+	Platform string `json:"platform"`
+	// Executable is the path of the browser's executable.
+	Executable string `json:"executable"`
+	// Current tells whether the build is the one Current returns.
+	Current bool `json:"current"`
+}
+
+// Store keeps builds in Dir, one directory per product, version and
+// platform, and remembers the current build of each product.
+type Store struct {
+	Dir string
+}
+
+// currentFile names the file holding the current build of a product.
+const currentFile = "current"
+
+func (s Store) path(product, version, platform string) string {
+	return filepath.Join(s.Dir, product, version+"-"+platform)
+}
+
+// Install downloads and unpacks product at version for platform, replacing
+// an earlier installation of it, and makes it the current build.
+func (s Store) Install(ctx context.Context, c *Client, product, version, platform string) (*Installation, error) {
+	if !slices.Contains(Products, product) {
+		return nil, fmt.Errorf("unknown product %q (expected one of %s)", product, strings.Join(Products, ", "))
+	}
+	if !slices.Contains(Platforms, platform) {
+		return nil, fmt.Errorf("no Chrome for Testing builds for platform %q (expected one of %s)", platform, strings.Join(Platforms, ", "))
+	}
+	if !versionPattern.MatchString(version) {
+		return nil, fmt.Errorf("invalid version %q", version)
+	}
+	parent := filepath.Join(s.Dir, product)
+	if err := os.MkdirAll(parent, 0o755); err != nil {
+		return nil, err
+	}
+
+	archive, err := os.CreateTemp(parent, ".download-*.zip")
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := os.Remove(archive.Name()); err != nil {
+			slog.Warn("failed to remove download", "file", archive.Name(), "error", err)
+		}
+	}()
+	start := time.Now()
+	size, err := c.download(ctx, product, version, platform, archive)
+	if closeErr := archive.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return nil, err
+	}
+	slog.Debug("Downloaded Chrome for Testing", "product", product, "version", version, "bytes", size, "duration", time.Since(start))
+
+	// Unpack next to the final directory and move it in place, so an
+	// interrupted install never leaves a partial build behind
+	tmp, err := os.MkdirTemp(parent, ".unpack-*")
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := os.RemoveAll(tmp); err != nil {
+			slog.Warn("failed to remove unpacked files", "dir", tmp, "error", err)
+		}
+	}()
+	if err := unzip(archive.Name(), tmp); err != nil {
+		return nil, fmt.Errorf("failed to unpack %s %s: %w", product, version, err)
+	}
+	exe := executable(product, platform)
+	if _, err := os.Stat(filepath.Join(tmp, exe)); err != nil {
+		return nil, fmt.Errorf("the archive of %s %s has no %s", product, version, exe)
+	}
+	dir := s.path(product, version, platform)
+	if err := os.RemoveAll(dir); err != nil {
+		return nil, err
+	}
+	if err := os.Rename(tmp, dir); err != nil {
+		return nil, err
+	}
+	if err := s.Use(product, version, platform); err != nil {
+		return nil, err
+	}
+	return &Installation{Product: product, Version: version, Platform: platform, Executable: filepath.Join(dir, exe), Current: true}, nil
+}
+
+// Use makes an installed build the current one of its product.
+func (s Store) Use(product, version, platform string) error {
+	if _, err := os.Stat(filepath.Join(s.path(product, version, platform), executable(product, platform))); err != nil {
+		return fmt.Errorf("%s %s for %s is not installed", product, version, platform)
+	}
+	return os.WriteFile(filepath.Join(s.Dir, product, currentFile), []byte(version+"-"+platform+"\n"), 0o644)
+}
+
+// Current returns the executable of the current build of product, if one
+// is installed.
+func (s Store) Current(product string) (string, bool) {
+	data, err := os.ReadFile(filepath.Join(s.Dir, product, currentFile))
+	if err != nil {
+		return "", false
+	}
+	name := strings.TrimSpace(string(data))
+	i := strings.Index(name, "-")
+	if i < 0 {
+		return "", false
+	}
+	exe := filepath.Join(s.Dir, product, name, executable(product, name[i+1:]))
+	if _, err := os.Stat(exe); err != nil {
+		return "", false
+	}
+	return exe, true
+}
+
+// List returns the installed builds, by product and version.
+func (s Store) List() ([]Installation, error) {
+	var list []Installation
+	for _, product := range Products {
+		entries, err := os.ReadDir(filepath.Join(s.Dir, product))
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		current, _ := s.Current(product)
+		for _, e := range entries {
+			version, platform, ok := strings.Cut(e.Name(), "-")
+			if !e.IsDir() || !ok || !versionPattern.MatchString(version) {
+				continue
+			}
+			exe := filepath.Join(s.Dir, product, e.Name(), executable(product, platform))
+			list = append(list, Installation{Product: product, Version: version, Platform: platform, Executable: exe, Current: exe == current})
+		}
+	}
+	slices.SortFunc(list, func(a, b Installation) int {
+		if a.Product != b.Product {
+			return strings.Compare(a.Product, b.Product)
+		}
+		return compareVersions(a.Version, b.Version)
+	})
+	return list, nil
+}
+
+// Remove deletes an installed build. Removing the current build leaves the
+// product without one.
+func (s Store) Remove(product, version, platform string) error {
+	dir := s.path(product, version, platform)
+	if _, err := os.Stat(dir); err != nil {
+		return fmt.Errorf("%s %s for %s is not installed", product, version, platform)
+	}
+	if current, ok := s.Current(product); ok && strings.HasPrefix(current, dir+string(filepath.Separator)) {
+		if err := os.Remove(filepath.Join(s.Dir, product, currentFile)); err != nil {
+			return err
+		}
+	}
+	return os.RemoveAll(dir)
+}
+
+// compareVersions orders dotted versions numerically.
+func compareVersions(a, b string) int {
+	pa, pb := strings.Split(a, "."), strings.Split(b, ".")
+	for i := 0; i < len(pa) && i < len(pb); i++ {
+		if d := len(pa[i]) - len(pb[i]); d != 0 {
+			return d
+		}
+		if c := strings.Compare(pa[i], pb[i]); c != 0 {
+			return c
+		}
+	}
+	return len(pa) - len(pb)
+}
+
+// unzip extracts the archive at path into dir, keeping file modes and
+// symbolic links, which the macOS app bundle relies on.
+func unzip(path, dir string) error {
+	r, err := zip.OpenReader(path)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := r.Close(); err != nil {
+			slog.Warn("failed to close archive", "file", path, "error", err)
+		}
+	}()
+	for _, f := range r.File {
+		name := filepath.FromSlash(f.Name)
+		if !filepath.IsLocal(name) {
+			return fmt.Errorf("archive entry %q escapes the directory", f.Name)
+		}
+		target := filepath.Join(dir, name)
+		mode := f.Mode()
+		switch {
+		case mode.IsDir():
+			if err := os.MkdirAll(target, 0o755); err != nil {
+				return err
+			}
+			continue
+		case mode&os.ModeSymlink != 0:
+			if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+				return err
+			}
+			link, err := readEntry(f)
+			if err != nil {
+				return err
+			}
+			if filepath.IsAbs(link) || !filepath.IsLocal(filepath.Join(filepath.Dir(name), link)) {
+				return fmt.Errorf("archive link %q escapes the directory", f.Name)
+			}
+			if err := os.Symlink(link, target); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+			return err
+		}
+		if err := extractFile(f, target, mode.Perm()|0o600); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func readEntry(f *zip.File) (string, error) {
+	rc, err := f.Open()
+	if err != nil {
+		return "", err
+	}
+	defer func() {
+		if err := rc.Close(); err != nil {
+			slog.Warn("failed to close archive entry", "entry", f.Name, "error", err)
+		}
+	}()
+	data, err := io.ReadAll(rc)
+	return string(data), err
+}
+
+func extractFile(f *zip.File, target string, perm os.FileMode) error {
+	rc, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := rc.Close(); err != nil {
+			slog.Warn("failed to close archive entry", "entry", f.Name, "error", err)
+		}
+	}()
+	out, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(out, rc)
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
@@ -0,0 +1,253 @@
+// Package criticalcss assembles critical CSS, the rules a page needs to
+// render its first screen, from the stylesheets of the page, the rules
+// CSS coverage found used, and which of their selectors match elements
+// above the fold. Rules keep their enclosing @media, @supports and
+// similar blocks, and the @font-face and @keyframes rules they refer to
+// are kept too.
+package criticalcss
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Rule is a rule of a stylesheet by its offset, in bytes, where its
+// selector starts, as coverage reports it.
+type Rule struct {
+	Start int
+}
+
+// Sheet is a stylesheet and the rules of it the page used.
+type Sheet struct {
+	Text string
+	Used []Rule
+
+	blocks []*block
+}
+
+// block is a {} block of a stylesheet: a rule or an at-rule's body.
+type block struct {
+	prelude string
+	// start is where the prelude starts, open the offset of "{" and end
+	// the offset after "}".
+	start, open, end int
+	parent           *block
+}
+
+// parse finds the blocks of the sheet, in order, once.
+func (s *Sheet) parse() []*block {
+	if s.blocks == nil {
+		s.blocks = parseBlocks(s.Text)
+	}
+	return s.blocks
+}
+
+// parseBlocks scans text for {} blocks, skipping comments and strings.
+func parseBlocks(text string) []*block {
+	var blocks, open []*block
+	stmt := 0
+	for i := 0; i < len(text); i++ {
+		switch c := text[i]; c {
+		case '/':
+			if i+1 < len(text) && text[i+1] == '*' {
+				end := strings.Index(text[i+2:], "*/")
+				if end < 0 {
+					return blocks
+				}
+				// A comment before a statement is not part of it
+				if strings.TrimSpace(text[stmt:i]) == "" {
+					stmt = i + end + 4
+				}
+				i += end + 3
+			}
+		case '"', '\'':
+			for i++; i < len(text) && text[i] != c; i++ {
+				if text[i] == '\\' {
+					i++
+				}
+			}
+		case '\\':
+			i++
+		case '{':
+			b := &block{prelude: strings.TrimSpace(text[stmt:i]), start: stmt, open: i}
+			if len(open) > 0 {
+				b.parent = open[len(open)-1]
+			}
+			blocks = append(blocks, b)
+			open = append(open, b)
+			stmt = i + 1
+		case '}':
+			if len(open) > 0 {
+				open[len(open)-1].end = i + 1
+				open = open[:len(open)-1]
+			}
+			stmt = i + 1
+		case ';':
+			stmt = i + 1
+		}
+	}
+	// Blocks left open by a truncated sheet end with it
+	for _, b := range open {
+		b.end = len(text)
+	}
+	return blocks
+}
+
+// rule returns the block of the rule starting at start: the first block
+// opening after it.
+func (s *Sheet) rule(start int) *block {
+	blocks := s.parse()
+	i := sort.Search(len(blocks), func(i int) bool { return blocks[i].open >= start })
+	if i == len(blocks) {
+		return nil
+	}
+	return blocks[i]
+}
+
+// Selector returns the selector of r, or "" if r is not in the sheet.
+func (s *Sheet) Selector(r Rule) string {
+	if b := s.rule(r.Start); b != nil {
+		return b.prelude
+	}
+	return ""
+}
+
+// Build returns the used rules of sheets for whose selector critical
+// returns true, in stylesheet order, with the at-rules enclosing them and
+// the @font-face and @keyframes rules they refer to.
+func Build(sheets []*Sheet, critical func(selector string) bool) string {
+	type item struct {
+		sheet *Sheet
+		block *block
+	}
+	var rules []item
+	var referable []item
+	var used strings.Builder
+	for _, s := range sheets {
+		seen := make(map[*block]bool)
+		for _, r := range s.Used {
+			b := s.rule(r.Start)
+			if b == nil || seen[b] || strings.HasPrefix(b.prelude, "@") || !critical(b.prelude) {
+				continue
+			}
+			seen[b] = true
+			rules = append(rules, item{s, b})
+			used.WriteString(s.Text[b.open:b.end])
+		}
+		for _, b := range s.parse() {
+			if name := referableName(b.prelude, s.Text[b.open:b.end]); name != "" {
+				referable = append(referable, item{s, b})
+			}
+		}
+	}
+	declarations := strings.ToLower(used.String())
+
+	var out writer
+	for _, s := range sheets {
+		var items []*block
+		for _, it := range referable {
+			if it.sheet == s && referenced(declarations, referableName(it.block.prelude, s.Text[it.block.open:it.block.end])) {
+				items = append(items, it.block)
+			}
+		}
+		for _, it := range rules {
+			if it.sheet == s {
+				items = append(items, it.block)
+			}
+		}
+		sort.SliceStable(items, func(i, j int) bool { return items[i].start < items[j].start })
+		for _, b := range items {
+			out.rule(s.Text, b)
+		}
+		out.close(0)
+	}
+	return out.String()
+}
+
+var fontFamily = regexp.MustCompile(`(?i)font-family\s*:\s*([^;}]+)`)
+
+// referableName returns the name a @font-face or @keyframes block is
+// referred to by, or "" for other blocks.
+func referableName(prelude, body string) string {
+	lower := strings.ToLower(prelude)
+	switch {
+	case lower == "@font-face":
+		if m := fontFamily.FindStringSubmatch(body); m != nil {
+			return strings.Trim(strings.TrimSpace(m[1]), `"'`)
+		}
+	case strings.HasPrefix(lower, "@keyframes "), strings.HasPrefix(lower, "@-webkit-keyframes "):
+		_, name, _ := strings.Cut(prelude, " ")
+		return strings.Trim(strings.TrimSpace(name), `"'`)
+	}
+	return ""
+}
+
+// referenced reports whether name appears in declarations as a whole
+// word, such as a font family or an animation name.
+func referenced(declarations, name string) bool {
+	name = strings.ToLower(name)
+	if name == "" {
+		return false
+	}
+	for rest := declarations; ; {
+		i := strings.Index(rest, name)
+		if i < 0 {
+			return false
+		}
+		before, after := byte(' '), byte(' ')
+		if i > 0 {
+			before = rest[i-1]
+		}
+		if j := i + len(name); j < len(rest) {
+			after = rest[j]
+		}
+		if !wordByte(before) && !wordByte(after) {
+			return true
+		}
+		rest = rest[i+len(name):]
+	}
+}
+
+func wordByte(c byte) bool {
+	return c == '-' || c == '_' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z'
+}
+
+// writer prints rules, opening and closing their enclosing blocks as
+// consecutive rules need.
+type writer struct {
+	strings.Builder
+	open []*block
+}
+
+func (w *writer) rule(text string, b *block) {
+	var chain []*block
+	for p := b.parent; p != nil; p = p.parent {
+		chain = append([]*block{p}, chain...)
+	}
+	shared := 0
+	for shared < len(chain) && shared < len(w.open) && chain[shared] == w.open[shared] {
+		shared++
+	}
+	w.close(shared)
+	for _, p := range chain[shared:] {
+		w.indent()
+		w.WriteString(p.prelude + " {\n")
+		w.open = append(w.open, p)
+	}
+	w.indent()
+	w.WriteString(b.prelude + " " + text[b.open:b.end] + "\n")
+}
+
+// close closes the open blocks past the first n.
+func (w *writer) close(n int) {
+	for len(w.open) > n {
+		w.open = w.open[:len(w.open)-1]
+		w.indent()
+		w.WriteString("}\n")
+	}
+}
+
+func (w *writer) indent() {
+	w.WriteString(strings.Repeat("  ", len(w.open)))
+}
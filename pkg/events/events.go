@@ -0,0 +1,289 @@
+// Package events defines the typed page events emitted by a browser session
+// and a Bus that fans them out to any number of subscribers.
+package events
+
+import (
+	"sync"
+	"time"
+)
+
+// Event is a single page event. The concrete type is one of ConsoleMessage,
+// Exception, RequestFinished, Dialog, Download, Navigation or Load.
+type Event interface {
+	// Kind returns a short, stable name for the event type.
+	Kind() string
+	// Time returns when the event was observed.
+	Time() time.Time
+}
+
+// ConsoleMessage is a call to one of the console API methods (console.log,
+// console.error, ...).
+type ConsoleMessage struct {
+	Type      string    `json:"type"`
+	Text      string    `json:"text"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// Frame is a single entry of a JavaScript stack trace.
+type Frame struct {
+	Function string `json:"function"`
+	URL      string `json:"url"`
+	Line     int64  `json:"line"`
+	Column   int64  `json:"column"`
+	// Original is the frame's position in the original source, when it was
+	// resolved through a source map.
+	Original *SourcePosition `json:"original,omitempty"`
+}
+
+// SourcePosition is a position in an original source file. Like all
+// positions Chrome reports, Line and Column are zero-based.
+type SourcePosition struct {
+	Source string `json:"source"`
+	Line   int64  `json:"line"`
+	Column int64  `json:"column"`
+	// Name is the original name of the function or identifier, if known.
+	Name string `json:"name,omitempty"`
+}
+
+// Exception is an uncaught JavaScript exception.
+type Exception struct {
+	Text   string `json:"text"`
+	URL    string `json:"url,omitempty"`
+	Line   int64  `json:"line"`
+	Column int64  `json:"column"`
+	// Original is URL, Line and Column resolved through a source map.
+	Original   *SourcePosition `json:"original,omitempty"`
+	StackTrace []Frame         `json:"stackTrace,omitempty"`
+	Timestamp  time.Time       `json:"timestamp"`
+}
+
+// RequestFinished is a network request that completed or failed to load.
+// A redirect is reported as its own finished request carrying the 3xx
+// response.
+type RequestFinished struct {
+	RequestID      string            `json:"requestId"`
+	URL            string            `json:"url"`
+	Method         string            `json:"method"`
+	ResourceType   string            `json:"resourceType"`
+	RequestHeaders map[string]string `json:"requestHeaders,omitempty"`
+	// PostData is the request body. Chrome omits very large bodies.
+	PostData   string `json:"postData,omitempty"`
+	Status     int64  `json:"status"`
+	StatusText string `json:"statusText,omitempty"`
+	Protocol   string `json:"protocol,omitempty"`
+	MimeType   string `json:"mimeType,omitempty"`
+	// ResponseHeaders are the response headers as sent by the server.
+	ResponseHeaders   map[string]string `json:"responseHeaders,omitempty"`
+	RemoteIPAddress   string            `json:"remoteIPAddress,omitempty"`
+	FromCache         bool              `json:"fromCache,omitempty"`
+	EncodedDataLength float64           `json:"encodedDataLength"`
+	Failed            bool              `json:"failed,omitempty"`
+	ErrorText         string            `json:"errorText,omitempty"`
+	Timing            *Timing           `json:"timing,omitempty"`
+	// StartedAt is when the request was sent; Timestamp when it finished.
+	StartedAt time.Time `json:"startedAt"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// Timing splits the time of a request into phases, in milliseconds.
+// Phases that did not happen, such as DNS for a reused connection, are -1.
+type Timing struct {
+	Blocked float64 `json:"blocked"`
+	DNS     float64 `json:"dns"`
+	Connect float64 `json:"connect"`
+	SSL     float64 `json:"ssl"`
+	Send    float64 `json:"send"`
+	Wait    float64 `json:"wait"`
+	Receive float64 `json:"receive"`
+}
+
+// Dialog is a JavaScript dialog (alert, confirm, prompt, beforeunload).
+type Dialog struct {
+	Type      string    `json:"type"`
+	Message   string    `json:"message"`
+	URL       string    `json:"url"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// Download is a download started by the page.
+type Download struct {
+	URL               string    `json:"url"`
+	SuggestedFilename string    `json:"suggestedFilename"`
+	Timestamp         time.Time `json:"timestamp"`
+}
+
+// Navigation is a navigation of the main frame requested by the page
+// itself, such as a meta refresh or a script assigning location.
+type Navigation struct {
+	URL string `json:"url"`
+	// Reason is the CDP client navigation reason, e.g. metaTagRefresh,
+	// scriptInitiated or anchorClick.
+	Reason    string    `json:"reason"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// Load is the load event of the main frame's document.
+type Load struct {
+	Timestamp time.Time `json:"timestamp"`
+}
+
+func (e ConsoleMessage) Kind() string  { return "console" }
+func (e Exception) Kind() string       { return "exception" }
+func (e RequestFinished) Kind() string { return "request" }
+func (e Dialog) Kind() string          { return "dialog" }
+func (e Download) Kind() string        { return "download" }
+func (e Navigation) Kind() string      { return "navigation" }
+func (e Load) Kind() string            { return "load" }
+
+func (e ConsoleMessage) Time() time.Time  { return e.Timestamp }
+func (e Exception) Time() time.Time       { return e.Timestamp }
+func (e RequestFinished) Time() time.Time { return e.Timestamp }
+func (e Dialog) Time() time.Time          { return e.Timestamp }
+func (e Download) Time() time.Time        { return e.Timestamp }
+func (e Navigation) Time() time.Time      { return e.Timestamp }
+func (e Load) Time() time.Time            { return e.Timestamp }
+
+// Bus delivers published events to every subscriber in publish order.
+// Publish never blocks: each subscriber has its own unbounded queue, so a
+// slow consumer cannot stall the browser's event loop. The zero value is
+// ready to use.
+type Bus struct {
+	mu     sync.Mutex
+	subs   []*subscription
+	closed bool
+}
+
+// Subscribe returns a channel receiving every event published from now on.
+// The channel is closed after Close once all queued events are delivered;
+// consumers must keep receiving until then.
+func (b *Bus) Subscribe() <-chan Event {
+	s := &subscription{out: make(chan Event), done: make(chan struct{})}
+	s.cond = sync.NewCond(&s.mu)
+
+	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+		close(s.out)
+		return s.out
+	}
+	b.subs = append(b.subs, s)
+	b.mu.Unlock()
+
+	go s.deliver()
+	return s.out
+}
+
+// Unsubscribe stops delivery to ch, a channel returned by Subscribe, and
+// drops its queued events. ch is closed; it need not be drained.
+func (b *Bus) Unsubscribe(ch <-chan Event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for i, s := range b.subs {
+		if s.out == ch {
+			b.subs = append(b.subs[:i], b.subs[i+1:]...)
+			s.cancel()
+			return
+		}
+	}
+}
+
+// Drain stops delivery to ch, a channel returned by Subscribe, of events
+// published from now on. Unlike Unsubscribe, the events queued so far are
+// still delivered; ch is closed after them.
+func (b *Bus) Drain(ch <-chan Event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for i, s := range b.subs {
+		if s.out == ch {
+			b.subs = append(b.subs[:i], b.subs[i+1:]...)
+			s.close()
+			return
+		}
+	}
+}
+
+// Publish queues ev for all current subscribers. It is a no-op after Close.
+func (b *Bus) Publish(ev Event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return
+	}
+	for _, s := range b.subs {
+		s.push(ev)
+	}
+}
+
+// Close stops accepting events and closes every subscriber channel once
+// its queue is drained. It is safe to call more than once.
+func (b *Bus) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return
+	}
+	b.closed = true
+	for _, s := range b.subs {
+		s.close()
+	}
+	b.subs = nil
+}
+
+type subscription struct {
+	mu     sync.Mutex
+	cond   *sync.Cond
+	queue  []Event
+	closed bool
+	out    chan Event
+	// done is closed when the subscriber goes away without draining.
+	done chan struct{}
+}
+
+func (s *subscription) push(ev Event) {
+	s.mu.Lock()
+	s.queue = append(s.queue, ev)
+	s.mu.Unlock()
+	s.cond.Signal()
+}
+
+func (s *subscription) close() {
+	s.mu.Lock()
+	s.closed = true
+	s.mu.Unlock()
+	s.cond.Signal()
+}
+
+// cancel discards the queue and ends delivery without waiting for the
+// subscriber.
+func (s *subscription) cancel() {
+	s.mu.Lock()
+	s.queue = nil
+	s.closed = true
+	s.mu.Unlock()
+	close(s.done)
+	s.cond.Signal()
+}
+
+func (s *subscription) deliver() {
+	defer close(s.out)
+	for {
+		s.mu.Lock()
+		for len(s.queue) == 0 && !s.closed {
+			s.cond.Wait()
+		}
+		if len(s.queue) == 0 {
+			s.mu.Unlock()
+			return
+		}
+		ev := s.queue[0]
+		s.queue[0] = nil
+		s.queue = s.queue[1:]
+		s.mu.Unlock()
+
+		select {
+		case s.out <- ev:
+		case <-s.done:
+			return
+		}
+	}
+}
@@ -0,0 +1,177 @@
+package events
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+// message is the n-th event of publisher p.
+func message(p, n int) ConsoleMessage {
+	return ConsoleMessage{Type: "log", Text: string(rune('a' + p)), Timestamp: time.Unix(int64(n), 0)}
+}
+
+// collect receives from ch until it is closed, failing the test if that
+// takes longer than a few seconds. It may be called from any goroutine.
+func collect(t *testing.T, ch <-chan Event) []Event {
+	t.Helper()
+	var got []Event
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case ev, ok := <-ch:
+			if !ok {
+				return got
+			}
+			got = append(got, ev)
+		case <-timeout:
+			t.Errorf("channel not closed, %d events received", len(got))
+			return got
+		}
+	}
+}
+
+func TestBusConcurrentPublishers(t *testing.T) {
+	const publishers, perPublisher, subscribers = 8, 500, 4
+
+	var bus Bus
+	streams := make([]<-chan Event, subscribers)
+	for i := range streams {
+		streams[i] = bus.Subscribe()
+	}
+	results := make([][]Event, subscribers)
+	var received sync.WaitGroup
+	for i, ch := range streams {
+		received.Add(1)
+		go func() {
+			defer received.Done()
+			results[i] = collect(t, ch)
+		}()
+	}
+
+	var published sync.WaitGroup
+	for p := range publishers {
+		published.Add(1)
+		go func() {
+			defer published.Done()
+			for n := range perPublisher {
+				bus.Publish(message(p, n))
+			}
+		}()
+	}
+	published.Wait()
+	bus.Close()
+	received.Wait()
+
+	for i, got := range results {
+		if len(got) != publishers*perPublisher {
+			t.Fatalf("subscriber %d received %d events, want %d", i, len(got), publishers*perPublisher)
+		}
+		// Events of one publisher arrive in the order it published them
+		next := make(map[string]int64)
+		for _, ev := range got {
+			m := ev.(ConsoleMessage)
+			if m.Timestamp.Unix() != next[m.Text] {
+				t.Fatalf("subscriber %d received event %d of publisher %s, want %d", i, m.Timestamp.Unix(), m.Text, next[m.Text])
+			}
+			next[m.Text]++
+		}
+	}
+}
+
+func TestBusSubscribeWhilePublishing(t *testing.T) {
+	var bus Bus
+	stop := make(chan struct{})
+	var published sync.WaitGroup
+	published.Add(1)
+	go func() {
+		defer published.Done()
+		for n := 0; ; n++ {
+			select {
+			case <-stop:
+				return
+			default:
+				bus.Publish(message(0, n))
+			}
+		}
+	}()
+
+	var subscribers sync.WaitGroup
+	for i := range 16 {
+		subscribers.Add(1)
+		go func() {
+			defer subscribers.Done()
+			ch := bus.Subscribe()
+			// Read a few events, then leave in one of the three ways
+			for range 10 {
+				<-ch
+			}
+			switch i % 3 {
+			case 0:
+				bus.Unsubscribe(ch)
+				for range ch {
+				}
+			case 1:
+				bus.Drain(ch)
+				got := collect(t, ch)
+				for j := 1; j < len(got); j++ {
+					if got[j].Time().Unix() != got[j-1].Time().Unix()+1 {
+						t.Errorf("drained events out of order: %d after %d", got[j].Time().Unix(), got[j-1].Time().Unix())
+						return
+					}
+				}
+			default:
+				// Never receiving again must not block the publisher
+				bus.Unsubscribe(ch)
+			}
+		}()
+	}
+	subscribers.Wait()
+	close(stop)
+	published.Wait()
+	bus.Close()
+}
+
+func TestBusDrainDeliversQueuedEvents(t *testing.T) {
+	var bus Bus
+	ch := bus.Subscribe()
+	for n := range 100 {
+		bus.Publish(message(0, n))
+	}
+	bus.Drain(ch)
+	bus.Publish(message(0, 100))
+
+	got := collect(t, ch)
+	if len(got) != 100 {
+		t.Fatalf("received %d events after Drain, want the 100 published before it", len(got))
+	}
+}
+
+func TestBusCloseConcurrently(t *testing.T) {
+	var bus Bus
+	streams := make([]<-chan Event, 8)
+	for i := range streams {
+		streams[i] = bus.Subscribe()
+	}
+	var wg sync.WaitGroup
+	for range 4 {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			bus.Close()
+		}()
+		go func() {
+			defer wg.Done()
+			bus.Publish(Load{Timestamp: time.Now()})
+		}()
+	}
+	for _, ch := range streams {
+		collect(t, ch)
+	}
+	wg.Wait()
+
+	// Subscribing after Close yields a closed channel
+	if _, ok := <-bus.Subscribe(); ok {
+		t.Fatal("Subscribe after Close returned an open channel")
+	}
+}
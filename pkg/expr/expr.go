@@ -0,0 +1,515 @@
+// Package expr is the small expression language of --fail-if: numbers,
+// strings and booleans combined with arithmetic, comparisons and logic,
+// over variables and functions the caller provides.
+//
+//	e, err := expr.Parse(`console_errors > 0 || lcp_ms > 4000 || !selector("#footer")`)
+//	failed, err := e.Eval(expr.Env{Vars: vars, Funcs: funcs})
+//
+// Operators, loosest first: ||, &&, the comparisons == != < <= > >=, + and
+// -, * / and %, and the unary ! and -. && and || short-circuit. Strings are
+// written in double or single quotes and compare with == and !=.
+package expr
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Value is a number (float64), string or bool.
+type Value any
+
+// Func is a function callable from expressions.
+type Func func(args []Value) (Value, error)
+
+// Env holds what an expression is evaluated over.
+type Env struct {
+	Vars  map[string]Value
+	Funcs map[string]Func
+}
+
+// Call is a call to a function with literal arguments, see Expr.Calls.
+type Call struct {
+	Name string
+	Args []Value
+}
+
+// Expr is a parsed expression.
+type Expr struct {
+	src  string
+	root node
+}
+
+// String returns the expression as written.
+func (e *Expr) String() string { return e.src }
+
+// Idents returns the variables the expression reads, in order of
+// appearance.
+func (e *Expr) Idents() []string {
+	var idents []string
+	walk(e.root, func(n node) {
+		if id, ok := n.(*ident); ok {
+			idents = append(idents, id.name)
+		}
+	})
+	return idents
+}
+
+// Calls returns the function calls of the expression, in order of
+// appearance, with the arguments that are literals; the others are nil.
+// Callers use them to validate names and gather what the calls need
+// before evaluating.
+func (e *Expr) Calls() []Call {
+	var calls []Call
+	walk(e.root, func(n node) {
+		if c, ok := n.(*call); ok {
+			args := make([]Value, len(c.args))
+			for i, a := range c.args {
+				if l, ok := a.(*literal); ok {
+					args[i] = l.v
+				}
+			}
+			calls = append(calls, Call{Name: c.name, Args: args})
+		}
+	})
+	return calls
+}
+
+// Eval evaluates the expression, which must yield a bool.
+func (e *Expr) Eval(env Env) (bool, error) {
+	v, err := e.root.eval(env)
+	if err != nil {
+		return false, err
+	}
+	b, ok := v.(bool)
+	if !ok {
+		return false, fmt.Errorf("%s is %s, not a condition", e.src, describe(v))
+	}
+	return b, nil
+}
+
+// Parse parses an expression.
+func Parse(src string) (*Expr, error) {
+	p := &parser{lex: lexer{src: src}}
+	p.next()
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.tok.kind != tokEOF {
+		return nil, p.errorf("unexpected %s", p.tok)
+	}
+	return &Expr{src: src, root: root}, nil
+}
+
+type node interface {
+	eval(env Env) (Value, error)
+}
+
+type literal struct{ v Value }
+
+type ident struct{ name string }
+
+type unary struct {
+	op string
+	x  node
+}
+
+type binary struct {
+	op   string
+	x, y node
+}
+
+type call struct {
+	name string
+	args []node
+}
+
+func walk(n node, f func(node)) {
+	f(n)
+	switch n := n.(type) {
+	case *unary:
+		walk(n.x, f)
+	case *binary:
+		walk(n.x, f)
+		walk(n.y, f)
+	case *call:
+		for _, a := range n.args {
+			walk(a, f)
+		}
+	}
+}
+
+func (n *literal) eval(Env) (Value, error) { return n.v, nil }
+
+func (n *ident) eval(env Env) (Value, error) {
+	v, ok := env.Vars[n.name]
+	if !ok {
+		return nil, fmt.Errorf("unknown variable %s", n.name)
+	}
+	return normalize(v), nil
+}
+
+func (n *unary) eval(env Env) (Value, error) {
+	v, err := n.x.eval(env)
+	if err != nil {
+		return nil, err
+	}
+	switch n.op {
+	case "!":
+		b, ok := v.(bool)
+		if !ok {
+			return nil, fmt.Errorf("! needs a condition, got %s", describe(v))
+		}
+		return !b, nil
+	default:
+		f, ok := v.(float64)
+		if !ok {
+			return nil, fmt.Errorf("- needs a number, got %s", describe(v))
+		}
+		return -f, nil
+	}
+}
+
+func (n *binary) eval(env Env) (Value, error) {
+	x, err := n.x.eval(env)
+	if err != nil {
+		return nil, err
+	}
+	if n.op == "&&" || n.op == "||" {
+		b, ok := x.(bool)
+		if !ok {
+			return nil, fmt.Errorf("%s needs conditions, got %s", n.op, describe(x))
+		}
+		if b == (n.op == "||") {
+			return b, nil
+		}
+		y, err := n.y.eval(env)
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := y.(bool); !ok {
+			return nil, fmt.Errorf("%s needs conditions, got %s", n.op, describe(y))
+		}
+		return y, nil
+	}
+	y, err := n.y.eval(env)
+	if err != nil {
+		return nil, err
+	}
+	switch n.op {
+	case "==":
+		return equal(x, y)
+	case "!=":
+		eq, err := equal(x, y)
+		if err != nil {
+			return nil, err
+		}
+		return !eq.(bool), nil
+	}
+	a, ok1 := x.(float64)
+	b, ok2 := y.(float64)
+	if !ok1 || !ok2 {
+		return nil, fmt.Errorf("%s needs numbers, got %s and %s", n.op, describe(x), describe(y))
+	}
+	switch n.op {
+	case "<":
+		return a < b, nil
+	case "<=":
+		return a <= b, nil
+	case ">":
+		return a > b, nil
+	case ">=":
+		return a >= b, nil
+	case "+":
+		return a + b, nil
+	case "-":
+		return a - b, nil
+	case "*":
+		return a * b, nil
+	case "/":
+		if b == 0 {
+			return nil, fmt.Errorf("division by zero")
+		}
+		return a / b, nil
+	default: // %
+		if b == 0 {
+			return nil, fmt.Errorf("division by zero")
+		}
+		return math.Mod(a, b), nil
+	}
+}
+
+func (n *call) eval(env Env) (Value, error) {
+	f, ok := env.Funcs[n.name]
+	if !ok {
+		return nil, fmt.Errorf("unknown function %s", n.name)
+	}
+	args := make([]Value, len(n.args))
+	for i, a := range n.args {
+		v, err := a.eval(env)
+		if err != nil {
+			return nil, err
+		}
+		args[i] = v
+	}
+	v, err := f(args)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", n.name, err)
+	}
+	return normalize(v), nil
+}
+
+func equal(x, y Value) (Value, error) {
+	switch a := x.(type) {
+	case float64:
+		if b, ok := y.(float64); ok {
+			return a == b, nil
+		}
+	case string:
+		if b, ok := y.(string); ok {
+			return a == b, nil
+		}
+	case bool:
+		if b, ok := y.(bool); ok {
+			return a == b, nil
+		}
+	}
+	return nil, fmt.Errorf("cannot compare %s with %s", describe(x), describe(y))
+}
+
+// normalize turns the integer types callers provide into float64.
+func normalize(v Value) Value {
+	switch n := v.(type) {
+	case int:
+		return float64(n)
+	case int64:
+		return float64(n)
+	case float32:
+		return float64(n)
+	}
+	return v
+}
+
+func describe(v Value) string {
+	switch v := v.(type) {
+	case float64:
+		return "the number " + strconv.FormatFloat(v, 'g', -1, 64)
+	case string:
+		return "the string " + strconv.Quote(v)
+	case bool:
+		return "the condition " + strconv.FormatBool(v)
+	}
+	return fmt.Sprintf("%v", v)
+}
+
+// Parser: precedence climbing over the tokens of the lexer.
+
+type parser struct {
+	lex lexer
+	tok token
+	err error
+}
+
+func (p *parser) next() {
+	if p.err == nil {
+		p.tok, p.err = p.lex.next()
+	}
+}
+
+func (p *parser) errorf(format string, args ...any) error {
+	return fmt.Errorf("at column %d: %s", p.tok.pos+1, fmt.Sprintf(format, args...))
+}
+
+// levels are the binary operators by precedence, loosest first.
+var levels = [][]string{
+	{"||"},
+	{"&&"},
+	{"==", "!=", "<", "<=", ">", ">="},
+	{"+", "-"},
+	{"*", "/", "%"},
+}
+
+func (p *parser) parseOr() (node, error) { return p.parseLevel(0) }
+
+func (p *parser) parseLevel(level int) (node, error) {
+	if level == len(levels) {
+		return p.parseUnary()
+	}
+	x, err := p.parseLevel(level + 1)
+	if err != nil {
+		return nil, err
+	}
+	for p.err == nil && p.tok.kind == tokOp && slices.Contains(levels[level], p.tok.text) {
+		op := p.tok.text
+		p.next()
+		y, err := p.parseLevel(level + 1)
+		if err != nil {
+			return nil, err
+		}
+		x = &binary{op: op, x: x, y: y}
+		// Comparisons do not chain: a < b < c is an error
+		if level == 2 && p.tok.kind == tokOp && slices.Contains(levels[level], p.tok.text) {
+			return nil, p.errorf("comparisons cannot be chained; use &&")
+		}
+	}
+	return x, p.err
+}
+
+func (p *parser) parseUnary() (node, error) {
+	if p.err != nil {
+		return nil, p.err
+	}
+	if p.tok.kind == tokOp && (p.tok.text == "!" || p.tok.text == "-") {
+		op := p.tok.text
+		p.next()
+		x, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &unary{op: op, x: x}, nil
+	}
+	return p.parsePrimary()
+}
+
+func (p *parser) parsePrimary() (node, error) {
+	if p.err != nil {
+		return nil, p.err
+	}
+	tok := p.tok
+	switch tok.kind {
+	case tokNumber:
+		f, err := strconv.ParseFloat(tok.text, 64)
+		if err != nil {
+			return nil, p.errorf("invalid number %s", tok.text)
+		}
+		p.next()
+		return &literal{v: f}, p.err
+	case tokString:
+		p.next()
+		return &literal{v: tok.text}, p.err
+	case tokIdent:
+		p.next()
+		switch tok.text {
+		case "true":
+			return &literal{v: true}, p.err
+		case "false":
+			return &literal{v: false}, p.err
+		}
+		if p.tok.kind != tokOp || p.tok.text != "(" {
+			return &ident{name: tok.text}, p.err
+		}
+		p.next()
+		c := &call{name: tok.text}
+		for p.err == nil && !(p.tok.kind == tokOp && p.tok.text == ")") {
+			if len(c.args) > 0 {
+				if p.tok.kind != tokOp || p.tok.text != "," {
+					return nil, p.errorf("expected , or ) in the arguments of %s, got %s", c.name, p.tok)
+				}
+				p.next()
+			}
+			arg, err := p.parseOr()
+			if err != nil {
+				return nil, err
+			}
+			c.args = append(c.args, arg)
+		}
+		p.next()
+		return c, p.err
+	case tokOp:
+		if tok.text == "(" {
+			p.next()
+			x, err := p.parseOr()
+			if err != nil {
+				return nil, err
+			}
+			if p.tok.kind != tokOp || p.tok.text != ")" {
+				return nil, p.errorf("expected ), got %s", p.tok)
+			}
+			p.next()
+			return x, p.err
+		}
+	}
+	return nil, p.errorf("unexpected %s", tok)
+}
+
+// Lexer.
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokNumber
+	tokString
+	tokIdent
+	tokOp
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	pos  int
+}
+
+func (t token) String() string {
+	if t.kind == tokEOF {
+		return "end of expression"
+	}
+	return strconv.Quote(t.text)
+}
+
+type lexer struct {
+	src string
+	pos int
+}
+
+// operators are matched longest first.
+var operators = []string{"||", "&&", "==", "!=", "<=", ">=", "<", ">", "!", "+", "-", "*", "/", "%", "(", ")", ","}
+
+func (l *lexer) next() (token, error) {
+	for l.pos < len(l.src) && unicode.IsSpace(rune(l.src[l.pos])) {
+		l.pos++
+	}
+	start := l.pos
+	if l.pos == len(l.src) {
+		return token{kind: tokEOF, pos: start}, nil
+	}
+	c := l.src[l.pos]
+	switch {
+	case c >= '0' && c <= '9' || c == '.':
+		for l.pos < len(l.src) && (l.src[l.pos] >= '0' && l.src[l.pos] <= '9' || l.src[l.pos] == '.' || l.src[l.pos] == '_') {
+			l.pos++
+		}
+		return token{kind: tokNumber, text: strings.ReplaceAll(l.src[start:l.pos], "_", ""), pos: start}, nil
+	case c == '_' || unicode.IsLetter(rune(c)):
+		for l.pos < len(l.src) && (l.src[l.pos] == '_' || unicode.IsLetter(rune(l.src[l.pos])) || unicode.IsDigit(rune(l.src[l.pos]))) {
+			l.pos++
+		}
+		return token{kind: tokIdent, text: l.src[start:l.pos], pos: start}, nil
+	case c == '"' || c == '\'':
+		var b strings.Builder
+		for l.pos++; l.pos < len(l.src); l.pos++ {
+			switch l.src[l.pos] {
+			case c:
+				l.pos++
+				return token{kind: tokString, text: b.String(), pos: start}, nil
+			case '\\':
+				if l.pos+1 < len(l.src) {
+					l.pos++
+				}
+			}
+			b.WriteByte(l.src[l.pos])
+		}
+		return token{}, fmt.Errorf("at column %d: unterminated string", start+1)
+	}
+	for _, op := range operators {
+		if strings.HasPrefix(l.src[l.pos:], op) {
+			l.pos += len(op)
+			return token{kind: tokOp, text: op, pos: start}, nil
+		}
+	}
+	return token{}, fmt.Errorf("at column %d: unexpected character %q", start+1, c)
+}
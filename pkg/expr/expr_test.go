@@ -0,0 +1,168 @@
+package expr
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func testEnv() Env {
+	return Env{
+		Vars: map[string]Value{
+			"errors":   2,
+			"lcp_ms":   int64(3500),
+			"ratio":    float32(0.5),
+			"score":    0.92,
+			"status":   "ok",
+			"passed":   true,
+			"_private": 1,
+		},
+		Funcs: map[string]Func{
+			"selector": func(args []Value) (Value, error) {
+				return args[0] == "#footer", nil
+			},
+			"count": func(args []Value) (Value, error) { return len(args), nil },
+			"fail": func([]Value) (Value, error) {
+				return nil, errors.New("boom")
+			},
+		},
+	}
+}
+
+func TestEval(t *testing.T) {
+	tests := []struct {
+		src  string
+		want bool
+	}{
+		{"true", true},
+		{"false", false},
+		{"errors > 0", true},
+		{"errors >= 2 && errors <= 2", true},
+		{"errors < 2", false},
+		{"lcp_ms > 4000 || errors > 0", true},
+		{"ratio == 0.5", true},
+		{"score > .9", true},
+		{"1_000 == 1000", true},
+		{"1 + 2 * 3 == 7", true},
+		{"(1 + 2) * 3 == 9", true},
+		{"10 - 2 - 3 == 5", true},
+		{"7 % 4 == 3", true},
+		{"7 / 2 == 3.5", true},
+		{"-errors == -2", true},
+		{"--errors == 2", true},
+		{"!passed", false},
+		{"!!passed", true},
+		{"!(errors > 0) || status == 'ok'", true},
+		{`status == "ok"`, true},
+		{`status != 'fail'`, true},
+		{`'it\'s' == "it's"`, true},
+		{"passed == true", true},
+		{`selector("#footer")`, true},
+		{`!selector('#header')`, true},
+		{"count(1, 'a', true) == 3", true},
+		{"count() == 0", true},
+		{"_private == 1", true},
+		{"  errors\t>\n1  ", true},
+		// && and || short-circuit, so the unknown variable is not read
+		{"passed || missing > 0", true},
+		{"!passed && missing > 0", false},
+		{"passed || fail()", true},
+	}
+	for _, tt := range tests {
+		e, err := Parse(tt.src)
+		if err != nil {
+			t.Errorf("Parse(%q) error = %v", tt.src, err)
+			continue
+		}
+		got, err := e.Eval(testEnv())
+		if err != nil {
+			t.Errorf("Eval(%q) error = %v", tt.src, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("Eval(%q) = %v, want %v", tt.src, got, tt.want)
+		}
+	}
+}
+
+func TestEvalErrors(t *testing.T) {
+	tests := []struct {
+		src string
+		// want is part of the error message
+		want string
+	}{
+		{"errors", "not a condition"},
+		{"status", "not a condition"},
+		{"missing > 0", "unknown variable missing"},
+		{"nope()", "unknown function nope"},
+		{"fail() == 1", "fail: boom"},
+		{"!errors", "! needs a condition"},
+		{"-status == 1", "- needs a number"},
+		{"errors && passed", "&& needs conditions"},
+		{"passed && errors", "&& needs conditions"},
+		{"!passed || status", "|| needs conditions"},
+		{"status > 1", "> needs numbers"},
+		{"status + 'x' == 'okx'", "+ needs numbers"},
+		{"status == 1", `cannot compare the string "ok" with the number 1`},
+		{"passed != 1", "cannot compare"},
+		{"errors / 0 > 1", "division by zero"},
+		{"errors % 0 > 1", "division by zero"},
+	}
+	for _, tt := range tests {
+		e, err := Parse(tt.src)
+		if err != nil {
+			t.Errorf("Parse(%q) error = %v", tt.src, err)
+			continue
+		}
+		_, err = e.Eval(testEnv())
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("Eval(%q) error = %v, want one containing %q", tt.src, err, tt.want)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		src  string
+		want string
+	}{
+		{"", "at column 1: unexpected end of expression"},
+		{"errors >", "unexpected end of expression"},
+		{"1 < 2 < 3", "comparisons cannot be chained"},
+		{"1 == 1 != false", "comparisons cannot be chained"},
+		{"(1 < 2", "expected ), got end of expression"},
+		{"1 < 2)", `unexpected ")"`},
+		{"errors 1", `unexpected "1"`},
+		{"f(1 2)", "expected , or ) in the arguments of f"},
+		{"f(1,", "unexpected end of expression"},
+		{"'open", "at column 1: unterminated string"},
+		{"errors > 0 # comment", `at column 12: unexpected character '#'`},
+		{"1.2.3 > 0", "invalid number 1.2.3"},
+		{"a = 1", `unexpected character '='`},
+	}
+	for _, tt := range tests {
+		_, err := Parse(tt.src)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("Parse(%q) error = %v, want one containing %q", tt.src, err, tt.want)
+		}
+	}
+}
+
+func TestIdentsAndCalls(t *testing.T) {
+	e, err := Parse(`errors > 0 || !selector("#footer") && count(lcp_ms, 2) > errors`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := e.Idents(), []string{"errors", "lcp_ms", "errors"}; !slices.Equal(got, want) {
+		t.Errorf("Idents() = %q, want %q", got, want)
+	}
+	calls := e.Calls()
+	if len(calls) != 2 || calls[0].Name != "selector" || calls[0].Args[0] != "#footer" ||
+		calls[1].Name != "count" || calls[1].Args[0] != nil || calls[1].Args[1] != 2.0 {
+		t.Errorf("Calls() = %#v", calls)
+	}
+	if e.String() != `errors > 0 || !selector("#footer") && count(lcp_ms, 2) > errors` {
+		t.Errorf("String() = %q", e.String())
+	}
+}
@@ -0,0 +1,188 @@
+// Package github is the small part of the GitHub REST API the toolbox uses
+// to report runs on pull requests: reading a pull request's head commit,
+// commenting on it and setting commit statuses.
+//
+//	c := github.New(os.Getenv("GITHUB_TOKEN"))
+//	pr, err := github.ParsePR("owner/repo#123")
+//	sha, err := c.HeadSHA(ctx, pr)
+//	err = c.SetStatus(ctx, pr, sha, &github.Status{State: github.StateSuccess, Context: "ci/pages"})
+package github
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultBaseURL is the API of github.com; GitHub Enterprise Server serves
+// it under /api/v3 of its host.
+const DefaultBaseURL = "https://api.github.com"
+
+// Commit status states.
+const (
+	StatePending = "pending"
+	StateSuccess = "success"
+	StateFailure = "failure"
+	StateError   = "error"
+)
+
+// PR names a pull request.
+type PR struct {
+	Owner  string
+	Repo   string
+	Number int
+}
+
+func (p PR) String() string {
+	return fmt.Sprintf("%s/%s#%d", p.Owner, p.Repo, p.Number)
+}
+
+var prPattern = regexp.MustCompile(`^([A-Za-z0-9_.-]+)/([A-Za-z0-9_.-]+)#([1-9][0-9]*)$`)
+
+// ParsePR parses a pull request written owner/repo#123.
+func ParsePR(s string) (PR, error) {
+	m := prPattern.FindStringSubmatch(strings.TrimSpace(s))
+	if m == nil {
+		return PR{}, fmt.Errorf("%q is not a pull request (expected owner/repo#123)", s)
+	}
+	n, err := strconv.Atoi(m[3])
+	if err != nil {
+		return PR{}, fmt.Errorf("%q is not a pull request (expected owner/repo#123)", s)
+	}
+	return PR{Owner: m[1], Repo: m[2], Number: n}, nil
+}
+
+// Status is a commit status.
+type Status struct {
+	State string `json:"state"`
+	// Context tells the statuses of different tools apart; a new status
+	// replaces the one with the same context.
+	Context     string `json:"context"`
+	Description string `json:"description,omitempty"`
+	TargetURL   string `json:"target_url,omitempty"`
+}
+
+// Client calls the API with a token.
+type Client struct {
+	BaseURL string
+	Token   string
+	HTTP    *http.Client
+}
+
+// New returns a client of github.com authenticated by token.
+func New(token string) *Client {
+	return &Client{BaseURL: DefaultBaseURL, Token: token, HTTP: &http.Client{Timeout: 30 * time.Second}}
+}
+
+// HeadSHA returns the commit at the head of the pull request.
+func (c *Client) HeadSHA(ctx context.Context, pr PR) (string, error) {
+	var resp struct {
+		Head struct {
+			SHA string `json:"sha"`
+		} `json:"head"`
+	}
+	if err := c.do(ctx, http.MethodGet, fmt.Sprintf("/repos/%s/%s/pulls/%d", pr.Owner, pr.Repo, pr.Number), nil, &resp); err != nil {
+		return "", err
+	}
+	if resp.Head.SHA == "" {
+		return "", fmt.Errorf("pull request %s has no head commit", pr)
+	}
+	return resp.Head.SHA, nil
+}
+
+// SetStatus sets a status of the commit sha.
+func (c *Client) SetStatus(ctx context.Context, pr PR, sha string, s *Status) error {
+	return c.do(ctx, http.MethodPost, fmt.Sprintf("/repos/%s/%s/statuses/%s", pr.Owner, pr.Repo, sha), s, nil)
+}
+
+// UpsertComment comments body on the pull request, editing the first of
+// its comments containing marker, if any, rather than adding another. It
+// returns the URL of the comment.
+func (c *Client) UpsertComment(ctx context.Context, pr PR, marker, body string) (string, error) {
+	type comment struct {
+		ID      int64  `json:"id"`
+		Body    string `json:"body"`
+		HTMLURL string `json:"html_url"`
+	}
+	for page := 1; ; page++ {
+		var comments []comment
+		path := fmt.Sprintf("/repos/%s/%s/issues/%d/comments?per_page=100&page=%d", pr.Owner, pr.Repo, pr.Number, page)
+		if err := c.do(ctx, http.MethodGet, path, nil, &comments); err != nil {
+			return "", err
+		}
+		for _, existing := range comments {
+			if strings.Contains(existing.Body, marker) {
+				var updated comment
+				path := fmt.Sprintf("/repos/%s/%s/issues/comments/%d", pr.Owner, pr.Repo, existing.ID)
+				err := c.do(ctx, http.MethodPatch, path, map[string]string{"body": body}, &updated)
+				return updated.HTMLURL, err
+			}
+		}
+		if len(comments) < 100 {
+			break
+		}
+	}
+	var created comment
+	path := fmt.Sprintf("/repos/%s/%s/issues/%d/comments", pr.Owner, pr.Repo, pr.Number)
+	err := c.do(ctx, http.MethodPost, path, map[string]string{"body": body}, &created)
+	return created.HTMLURL, err
+}
+
+// do sends a request with in as its JSON body, unless nil, and decodes the
+// response into out, unless nil.
+func (c *Client) do(ctx context.Context, method, path string, in, out any) error {
+	var body io.Reader
+	if in != nil {
+		data, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, strings.TrimSuffix(c.BaseURL, "/")+path, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	req.Header.Set("X-GitHub-Api-Version", "2022-11-28")
+	if c.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.Token)
+	}
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := c.HTTP.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := resp.Body.Close(); err != nil {
+			slog.Warn("failed to close response body", "error", err)
+		}
+	}()
+	if resp.StatusCode >= 300 {
+		var apiErr struct {
+			Message string `json:"message"`
+		}
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+		if json.Unmarshal(data, &apiErr) != nil || apiErr.Message == "" {
+			apiErr.Message = strings.TrimSpace(string(data))
+		}
+		return fmt.Errorf("%s %s: %s: %s", method, path, resp.Status, apiErr.Message)
+	}
+	if out == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("%s %s: invalid response: %w", method, path, err)
+	}
+	return nil
+}
@@ -0,0 +1,260 @@
+// Package har builds HTTP Archive (HAR) 1.2 documents from the network
+// requests recorded while loading a page.
+package har
+
+import (
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pesarkhobeee/that-cli-web-toolbox/pkg/events"
+)
+
+// HAR is the root of a HAR file.
+type HAR struct {
+	Log Log `json:"log"`
+}
+
+// Log holds the pages and requests of a HAR file.
+type Log struct {
+	Version string  `json:"version"`
+	Creator Creator `json:"creator"`
+	Pages   []Page  `json:"pages"`
+	Entries []Entry `json:"entries"`
+}
+
+// Creator names the application that wrote the file.
+type Creator struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+// Page is a loaded page; entries refer to it by ID.
+type Page struct {
+	StartedDateTime time.Time   `json:"startedDateTime"`
+	ID              string      `json:"id"`
+	Title           string      `json:"title"`
+	PageTimings     PageTimings `json:"pageTimings"`
+}
+
+// PageTimings are page load milestones in milliseconds, -1 when unknown.
+type PageTimings struct {
+	OnContentLoad float64 `json:"onContentLoad"`
+	OnLoad        float64 `json:"onLoad"`
+}
+
+// Entry is a single request and its response.
+type Entry struct {
+	Pageref         string    `json:"pageref,omitempty"`
+	StartedDateTime time.Time `json:"startedDateTime"`
+	Time            float64   `json:"time"`
+	Request         Request   `json:"request"`
+	Response        Response  `json:"response"`
+	Cache           struct{}  `json:"cache"`
+	Timings         Timings   `json:"timings"`
+	ServerIPAddress string    `json:"serverIPAddress,omitempty"`
+	// Comment carries the network error of a failed request.
+	Comment string `json:"comment,omitempty"`
+}
+
+// Request is the request part of an entry.
+type Request struct {
+	Method      string   `json:"method"`
+	URL         string   `json:"url"`
+	HTTPVersion string   `json:"httpVersion"`
+	Cookies     []Cookie `json:"cookies"`
+	Headers     []Header `json:"headers"`
+	QueryString []Header `json:"queryString"`
+	HeadersSize int      `json:"headersSize"`
+	BodySize    int      `json:"bodySize"`
+	// PostData is the request body. It is not recorded, but read from
+	// HAR files of other tools.
+	PostData *PostData `json:"postData,omitempty"`
+}
+
+// PostData is the body of a request.
+type PostData struct {
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text,omitempty"`
+}
+
+// Response is the response part of an entry. Status is 0 for requests
+// that failed before a response arrived.
+type Response struct {
+	Status      int64    `json:"status"`
+	StatusText  string   `json:"statusText"`
+	HTTPVersion string   `json:"httpVersion"`
+	Cookies     []Cookie `json:"cookies"`
+	Headers     []Header `json:"headers"`
+	Content     Content  `json:"content"`
+	RedirectURL string   `json:"redirectURL"`
+	HeadersSize int      `json:"headersSize"`
+	BodySize    int      `json:"bodySize"`
+}
+
+// Header is a name/value pair, also used for query string parameters.
+type Header struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// Cookie is a cookie sent or received. Cookies are not recorded, so the
+// lists are always empty.
+type Cookie struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// Content describes the response body. Bodies are not recorded, but read
+// from HAR files of other tools, such as those exported by browsers.
+type Content struct {
+	Size     int    `json:"size"`
+	MimeType string `json:"mimeType"`
+	// Text is the decoded body, base64 encoded when Encoding is "base64".
+	Text     string `json:"text,omitempty"`
+	Encoding string `json:"encoding,omitempty"`
+}
+
+// Timings are the phases of an entry in milliseconds, -1 when a phase did
+// not happen.
+type Timings struct {
+	Blocked float64 `json:"blocked"`
+	DNS     float64 `json:"dns"`
+	Connect float64 `json:"connect"`
+	SSL     float64 `json:"ssl"`
+	Send    float64 `json:"send"`
+	Wait    float64 `json:"wait"`
+	Receive float64 `json:"receive"`
+}
+
+// New returns a HAR written by creator with a single page titled title,
+// started at started, containing requests in the order they were sent.
+func New(creator Creator, title string, started time.Time, requests []events.RequestFinished) *HAR {
+	const pageID = "page_1"
+
+	sorted := make([]events.RequestFinished, len(requests))
+	copy(sorted, requests)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].StartedAt.Before(sorted[j].StartedAt) })
+
+	h := &HAR{Log: Log{
+		Version: "1.2",
+		Creator: creator,
+		Pages: []Page{{
+			StartedDateTime: started,
+			ID:              pageID,
+			Title:           title,
+			PageTimings:     PageTimings{OnContentLoad: -1, OnLoad: -1},
+		}},
+		Entries: make([]Entry, 0, len(sorted)),
+	}}
+	for _, req := range sorted {
+		entry := newEntry(req)
+		entry.Pageref = pageID
+		h.Log.Entries = append(h.Log.Entries, entry)
+	}
+	return h
+}
+
+func newEntry(req events.RequestFinished) Entry {
+	version := httpVersion(req.Protocol)
+	entry := Entry{
+		StartedDateTime: req.StartedAt,
+		Time:            float64(req.Timestamp.Sub(req.StartedAt)) / float64(time.Millisecond),
+		Request: Request{
+			Method:      req.Method,
+			URL:         req.URL,
+			HTTPVersion: version,
+			Cookies:     []Cookie{},
+			Headers:     headers(req.RequestHeaders),
+			QueryString: queryString(req.URL),
+			HeadersSize: -1,
+			BodySize:    -1,
+		},
+		Response: Response{
+			Status:      req.Status,
+			StatusText:  req.StatusText,
+			HTTPVersion: version,
+			Cookies:     []Cookie{},
+			Headers:     headers(req.ResponseHeaders),
+			Content:     Content{Size: -1, MimeType: req.MimeType},
+			RedirectURL: headerValue(req.ResponseHeaders, "Location"),
+			HeadersSize: -1,
+			BodySize:    int(req.EncodedDataLength),
+		},
+		Timings:         Timings{Blocked: -1, DNS: -1, Connect: -1, SSL: -1, Send: 0, Wait: 0, Receive: 0},
+		ServerIPAddress: strings.Trim(req.RemoteIPAddress, "[]"),
+	}
+	if req.Failed {
+		entry.Response.BodySize = -1
+		entry.Comment = req.ErrorText
+	}
+	if t := req.Timing; t != nil {
+		// send, wait and receive are required and must not be negative
+		entry.Timings = Timings{
+			Blocked: t.Blocked,
+			DNS:     t.DNS,
+			Connect: t.Connect,
+			SSL:     t.SSL,
+			Send:    max(t.Send, 0),
+			Wait:    max(t.Wait, 0),
+			Receive: max(t.Receive, 0),
+		}
+	} else {
+		entry.Timings.Wait = max(entry.Time, 0)
+	}
+	return entry
+}
+
+// httpVersion maps a CDP protocol name to the HAR form.
+func httpVersion(protocol string) string {
+	switch strings.ToLower(protocol) {
+	case "":
+		return "unknown"
+	case "h2":
+		return "HTTP/2"
+	case "h3":
+		return "HTTP/3"
+	default:
+		return strings.ToUpper(protocol)
+	}
+}
+
+// headers returns h as a list sorted by name.
+func headers(h map[string]string) []Header {
+	list := make([]Header, 0, len(h))
+	for name, value := range h {
+		list = append(list, Header{Name: name, Value: value})
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
+
+func headerValue(h map[string]string, name string) string {
+	for k, v := range h {
+		if strings.EqualFold(k, name) {
+			return v
+		}
+	}
+	return ""
+}
+
+// queryString returns the query parameters of rawURL in order.
+func queryString(rawURL string) []Header {
+	list := []Header{}
+	u, err := url.Parse(rawURL)
+	if err != nil || u.RawQuery == "" {
+		return list
+	}
+	for _, pair := range strings.Split(u.RawQuery, "&") {
+		name, value, _ := strings.Cut(pair, "=")
+		if n, err := url.QueryUnescape(name); err == nil {
+			name = n
+		}
+		if v, err := url.QueryUnescape(value); err == nil {
+			value = v
+		}
+		list = append(list, Header{Name: name, Value: value})
+	}
+	return list
+}
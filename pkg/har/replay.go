@@ -0,0 +1,227 @@
+package har
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Load reads the HAR file at path.
+func Load(path string) (*HAR, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var h HAR
+	if err := json.Unmarshal(data, &h); err != nil {
+		return nil, fmt.Errorf("invalid HAR file %s: %w", path, err)
+	}
+	if len(h.Log.Entries) == 0 {
+		return nil, fmt.Errorf("HAR file %s has no entries", path)
+	}
+	return &h, nil
+}
+
+// Body returns the decoded response body.
+func (c Content) Body() ([]byte, error) {
+	if c.Encoding == "base64" {
+		return base64.StdEncoding.DecodeString(c.Text)
+	}
+	return []byte(c.Text), nil
+}
+
+// HasBody reports whether the body of the response was recorded: it has
+// text, or is known to be empty.
+func (r Response) HasBody() bool {
+	return r.Content.Text != "" || r.Content.Size == 0 || r.Status == 204 || r.Status == 304 || (r.Status >= 300 && r.Status < 400)
+}
+
+// ReplayHeaders returns the response headers fit for serving its decoded
+// body again: without HTTP/2 pseudo-headers and the headers describing
+// the encoding and length of the body as it was transferred.
+func (r Response) ReplayHeaders() []Header {
+	var out []Header
+	for _, h := range r.Headers {
+		switch strings.ToLower(h.Name) {
+		case "content-encoding", "content-length", "transfer-encoding":
+			continue
+		}
+		if strings.HasPrefix(h.Name, ":") {
+			continue
+		}
+		out = append(out, h)
+	}
+	return out
+}
+
+// Replay indexes the entries of a HAR by request, to serve their recorded
+// responses again. It is not changed by matching, so the pages of a run
+// share it, each with its own ReplaySession.
+type Replay struct {
+	// exact holds the entries by method and URL, in recorded order.
+	exact map[string][]*Entry
+	// loose holds the entries by method and URL without the query.
+	loose map[string][]*Entry
+	// Bodiless is the number of entries whose body was not recorded.
+	Bodiless int
+}
+
+// NewReplay indexes the entries of h.
+func NewReplay(h *HAR) *Replay {
+	r := &Replay{exact: make(map[string][]*Entry), loose: make(map[string][]*Entry)}
+	for i := range h.Log.Entries {
+		e := &h.Log.Entries[i]
+		exact, loose, ok := requestKeys(e.Request.Method, e.Request.URL)
+		if !ok {
+			continue
+		}
+		r.exact[exact] = append(r.exact[exact], e)
+		r.loose[loose] = append(r.loose[loose], e)
+		if e.Response.Status != 0 && !e.Response.HasBody() {
+			r.Bodiless++
+		}
+	}
+	return r
+}
+
+// Len returns the number of entries indexed.
+func (r *Replay) Len() int {
+	n := 0
+	for _, entries := range r.exact {
+		n += len(entries)
+	}
+	return n
+}
+
+// requestKeys returns the keys of a request: its method and URL with the
+// query parameters sorted and without the fragment, and its method and URL
+// without the query.
+func requestKeys(method, rawURL string) (exact, loose string, ok bool) {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Host == "" {
+		return "", "", false
+	}
+	u.Fragment, u.RawFragment = "", ""
+	u.Host = strings.ToLower(u.Host)
+	query := u.Query()
+	u.RawQuery = ""
+	method = strings.ToUpper(method)
+	loose = method + " " + u.String()
+	u.RawQuery = query.Encode()
+	return method + " " + u.String(), loose, true
+}
+
+// ReplaySession matches the requests of one page load against a Replay.
+// A request made again gets the next entry recorded for it, the last one
+// once they are used up, so pages polling an endpoint replay its sequence.
+type ReplaySession struct {
+	replay *Replay
+
+	mu     sync.Mutex
+	served map[string]int
+	stats  ReplayStats
+}
+
+// ReplayStats counts how the requests of a page load were served.
+type ReplayStats struct {
+	// Served is the number of requests answered from the HAR.
+	Served int `json:"served"`
+	// Approximate is the number of those answered by an entry for the
+	// same URL with other query parameters.
+	Approximate int `json:"approximate,omitempty"`
+	// Missed are the URLs of the requests the HAR has no entry for.
+	Missed []string `json:"missed,omitempty"`
+}
+
+// Session starts matching the requests of a page load.
+func (r *Replay) Session() *ReplaySession {
+	return &ReplaySession{replay: r, served: make(map[string]int)}
+}
+
+// Match returns the entry to answer a request with, or nil if the HAR has
+// none for it. Entries for the same method and URL come first, those
+// with the same request body when it has one; otherwise the entry for the
+// same URL sharing the most query parameters with it.
+func (s *ReplaySession) Match(method, rawURL, body string) *Entry {
+	exact, loose, ok := requestKeys(method, rawURL)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !ok {
+		s.stats.Missed = append(s.stats.Missed, rawURL)
+		return nil
+	}
+	if entries := withBody(s.replay.exact[exact], body); len(entries) > 0 {
+		key := exact + "\n" + body
+		i := min(s.served[key], len(entries)-1)
+		s.served[key]++
+		s.stats.Served++
+		return entries[i]
+	}
+	if e := closest(s.replay.loose[loose], rawURL); e != nil {
+		s.stats.Served++
+		s.stats.Approximate++
+		return e
+	}
+	s.stats.Missed = append(s.stats.Missed, rawURL)
+	return nil
+}
+
+// Stats returns how the requests of the page load were served so far.
+func (s *ReplaySession) Stats() ReplayStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stats := s.stats
+	stats.Missed = append([]string(nil), s.stats.Missed...)
+	return stats
+}
+
+// withBody returns the entries recorded with body as their request body,
+// or all of them if none was, or the request has none.
+func withBody(entries []*Entry, body string) []*Entry {
+	if body == "" {
+		return entries
+	}
+	var same []*Entry
+	for _, e := range entries {
+		if e.Request.PostData != nil && e.Request.PostData.Text == body {
+			same = append(same, e)
+		}
+	}
+	if len(same) == 0 {
+		return entries
+	}
+	return same
+}
+
+// closest returns the entry whose URL shares the most query parameters
+// with rawURL, the first recorded of equals.
+func closest(entries []*Entry, rawURL string) *Entry {
+	u, err := url.Parse(rawURL)
+	if err != nil || len(entries) == 0 {
+		return nil
+	}
+	want := u.Query()
+	type scored struct {
+		entry  *Entry
+		shared int
+	}
+	candidates := make([]scored, 0, len(entries))
+	for _, e := range entries {
+		shared := 0
+		if eu, err := url.Parse(e.Request.URL); err == nil {
+			for name, values := range eu.Query() {
+				if strings.Join(want[name], ",") == strings.Join(values, ",") {
+					shared++
+				}
+			}
+		}
+		candidates = append(candidates, scored{e, shared})
+	}
+	sort.SliceStable(candidates, func(i, j int) bool { return candidates[i].shared > candidates[j].shared })
+	return candidates[0].entry
+}
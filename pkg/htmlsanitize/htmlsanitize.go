@@ -0,0 +1,235 @@
+// Package htmlsanitize cleans documents for re-hosting with an allowlist,
+// outside of the page they come from, so that no script of the page can
+// take part in it.
+package htmlsanitize
+
+import (
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// allowedTags are kept; other elements are unwrapped, their content kept.
+var allowedTags = set(
+	"html", "head", "body", "title",
+	"a", "abbr", "address", "article", "aside", "b", "blockquote", "br", "caption", "cite", "code",
+	"col", "colgroup", "dd", "del", "details", "dfn", "div", "dl", "dt", "em", "figcaption", "figure",
+	"footer", "h1", "h2", "h3", "h4", "h5", "h6", "header", "hr", "i", "img", "ins", "kbd", "li",
+	"main", "mark", "nav", "ol", "p", "picture", "pre", "q", "s", "samp", "section", "small", "span",
+	"strong", "sub", "summary", "sup", "table", "tbody", "td", "tfoot", "th", "thead", "time", "tr",
+	"u", "ul", "var")
+
+// droppedTags are active or embedding elements, removed with their
+// content.
+var droppedTags = set(
+	"script", "style", "noscript", "template", "iframe", "frame", "frameset", "object", "embed",
+	"applet", "link", "meta", "base", "form", "input", "button", "select", "textarea", "svg", "math",
+	"canvas", "audio", "video", "source", "track")
+
+// allowedAttrs are kept on allowed elements.
+var allowedAttrs = set(
+	"href", "src", "alt", "title", "width", "height", "colspan", "rowspan", "scope", "headers",
+	"lang", "dir", "datetime", "cite", "start", "reversed", "open")
+
+// urlAttrs hold URLs, kept only with a scheme of allowedSchemes.
+var urlAttrs = set("href", "src", "cite")
+
+// allowedSchemes are the schemes of URLs that are kept.
+var allowedSchemes = set("http", "https", "mailto", "tel")
+
+// trackerPattern matches the URLs of known beacons and tracking pixels.
+var trackerPattern = regexp.MustCompile(`(?i)(google-analytics\.com|googletagmanager\.com|doubleclick\.net|facebook\.com/tr|bat\.bing\.com|analytics\.|/pixel(\.gif|\.png)?(\?|$)|/beacon|/track(ing)?(\.gif|\?))`)
+
+// Options tell Sanitize about the page the document was rendered in.
+type Options struct {
+	// Base is the URL of the page, which relative URLs are resolved
+	// against unless the document has a <base>.
+	Base *url.URL
+	// Pixels are the absolute URLs of images that loaded as 1x1 pixels,
+	// dropped as tracking pixels.
+	Pixels map[string]bool
+}
+
+// Sanitize returns a sanitized copy of the document markup. Elements not
+// on the allowlist are unwrapped, while active or embedding elements are
+// dropped with their content, and so are comments. Only allowlisted
+// attributes survive, URLs are made absolute and kept only with an
+// http(s), mailto or tel scheme, links get rel="nofollow noopener
+// noreferrer", and tracking pixels (1x1 images, those in opts.Pixels or
+// on known beacon hosts) are dropped.
+func Sanitize(markup string, opts Options) (string, error) {
+	doc, err := html.Parse(strings.NewReader(markup))
+	if err != nil {
+		return "", err
+	}
+	s := &sanitizer{base: documentBase(doc, opts.Base), pixels: opts.Pixels}
+	s.clean(doc)
+
+	var b strings.Builder
+	b.WriteString("<!DOCTYPE html>\n")
+	for n := doc.FirstChild; n != nil; n = n.NextSibling {
+		if n.Type == html.ElementNode {
+			if err := html.Render(&b, n); err != nil {
+				return "", err
+			}
+		}
+	}
+	return b.String(), nil
+}
+
+type sanitizer struct {
+	base   *url.URL
+	pixels map[string]bool
+}
+
+func (s *sanitizer) clean(parent *html.Node) {
+	for n := parent.FirstChild; n != nil; {
+		next := n.NextSibling
+		switch n.Type {
+		case html.CommentNode, html.DoctypeNode:
+			parent.RemoveChild(n)
+		case html.ElementNode:
+			tag := strings.ToLower(n.Data)
+			if n.Namespace != "" || droppedTags[tag] || (tag == "img" && s.trackingPixel(n)) {
+				parent.RemoveChild(n)
+				break
+			}
+			s.clean(n)
+			if !allowedTags[tag] {
+				// Unwrap: the cleaned children take the element's place
+				for c := n.FirstChild; c != nil; c = n.FirstChild {
+					n.RemoveChild(c)
+					parent.InsertBefore(c, n)
+				}
+				parent.RemoveChild(n)
+				break
+			}
+			s.cleanAttrs(n, tag)
+		}
+		n = next
+	}
+}
+
+func (s *sanitizer) cleanAttrs(n *html.Node, tag string) {
+	attrs := n.Attr[:0]
+	hasHref := false
+	for _, a := range n.Attr {
+		name := strings.ToLower(a.Key)
+		if a.Namespace != "" || !allowedAttrs[name] {
+			continue
+		}
+		if urlAttrs[name] {
+			u, ok := SafeURL(s.base, a.Val)
+			if !ok {
+				continue
+			}
+			a.Val = u
+		}
+		hasHref = hasHref || name == "href"
+		attrs = append(attrs, html.Attribute{Key: name, Val: a.Val})
+	}
+	if tag == "a" && hasHref {
+		attrs = append(attrs, html.Attribute{Key: "rel", Val: "nofollow noopener noreferrer"})
+	}
+	n.Attr = attrs
+}
+
+// trackingPixel reports whether the img element n is a tracking pixel.
+func (s *sanitizer) trackingPixel(n *html.Node) bool {
+	src, _ := SafeURL(s.base, attr(n, "src"))
+	if s.pixels[src] || trackerPattern.MatchString(src) {
+		return true
+	}
+	w, errW := strconv.Atoi(strings.TrimSpace(attr(n, "width")))
+	h, errH := strconv.Atoi(strings.TrimSpace(attr(n, "height")))
+	return errW == nil && errH == nil && w <= 1 && h <= 1
+}
+
+// SafeURL returns value resolved against base, if it is a URL with one of
+// the allowed schemes, or, without a base, a relative URL. Like browsers,
+// it ignores ASCII tabs and newlines anywhere in the value and leading and
+// trailing control characters and spaces, so "java\tscript:" is the
+// javascript: URL it would be in a link.
+func SafeURL(base *url.URL, value string) (string, bool) {
+	value = strings.TrimFunc(value, func(r rune) bool { return r <= ' ' })
+	value = strings.Map(func(r rune) rune {
+		if r == '\t' || r == '\n' || r == '\r' {
+			return -1
+		}
+		return r
+	}, value)
+	if value == "" || strings.ContainsFunc(value, func(r rune) bool { return r < ' ' || r == 0x7f }) {
+		return "", false
+	}
+	u, err := url.Parse(value)
+	if err != nil {
+		return "", false
+	}
+	if base == nil && u.Scheme == "" {
+		return u.String(), true
+	}
+	if base != nil {
+		u = base.ResolveReference(u)
+	}
+	if !allowedSchemes[strings.ToLower(u.Scheme)] {
+		return "", false
+	}
+	return u.String(), true
+}
+
+// documentBase returns the URL relative URLs of doc resolve against: the
+// href of its first <base>, resolved against page, or page.
+func documentBase(doc *html.Node, page *url.URL) *url.URL {
+	var base *url.URL
+	var find func(n *html.Node) bool
+	find = func(n *html.Node) bool {
+		if n.Type == html.ElementNode && n.Data == "base" && n.Namespace == "" && hasAttr(n, "href") {
+			if u, err := url.Parse(strings.TrimSpace(attr(n, "href"))); err == nil {
+				base = u
+				if page != nil {
+					base = page.ResolveReference(u)
+				}
+			}
+			return true
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			if find(c) {
+				return true
+			}
+		}
+		return false
+	}
+	if find(doc); base != nil && base.IsAbs() {
+		return base
+	}
+	return page
+}
+
+func hasAttr(n *html.Node, key string) bool {
+	for _, a := range n.Attr {
+		if a.Namespace == "" && a.Key == key {
+			return true
+		}
+	}
+	return false
+}
+
+func attr(n *html.Node, key string) string {
+	for _, a := range n.Attr {
+		if a.Namespace == "" && a.Key == key {
+			return a.Val
+		}
+	}
+	return ""
+}
+
+func set(names ...string) map[string]bool {
+	m := make(map[string]bool, len(names))
+	for _, n := range names {
+		m[n] = true
+	}
+	return m
+}
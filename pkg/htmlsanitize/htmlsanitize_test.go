@@ -0,0 +1,190 @@
+package htmlsanitize
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func mustParse(t *testing.T, raw string) *url.URL {
+	t.Helper()
+	u, err := url.Parse(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return u
+}
+
+func TestSafeURL(t *testing.T) {
+	base := mustParse(t, "https://example.com/docs/page.html")
+	tests := []struct {
+		name  string
+		value string
+		want  string
+		ok    bool
+	}{
+		{"absolute https", "https://example.com/a", "https://example.com/a", true},
+		{"relative path", "other.html#top", "https://example.com/docs/other.html#top", true},
+		{"protocol relative", "//cdn.example.com/x.png", "https://cdn.example.com/x.png", true},
+		{"mailto", "mailto:team@example.com", "mailto:team@example.com", true},
+		{"tel", "tel:+15550100", "tel:+15550100", true},
+		{"padded", "  https://example.com/a\n", "https://example.com/a", true},
+		{"javascript", "javascript:alert(1)", "", false},
+		{"javascript uppercase", "JaVaScRiPt:alert(1)", "", false},
+		{"javascript leading space", "  javascript:alert(1)", "", false},
+		{"javascript with tab", "java\tscript:alert(1)", "", false},
+		{"javascript with newline", "java\nscript:alert(1)", "", false},
+		{"javascript with carriage return", "java\r\nscript:alert(1)", "", false},
+		{"javascript leading control", "\x01javascript:alert(1)", "", false},
+		{"javascript inner control", "java\x00script:alert(1)", "", false},
+		{"vbscript", "vbscript:msgbox(1)", "", false},
+		{"data", "data:text/html,<script>alert(1)</script>", "", false},
+		{"data with tab", "da\tta:text/html,x", "", false},
+		{"file", "file:///etc/passwd", "", false},
+		{"empty", "", "", false},
+		{"only whitespace", " \t\n", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := SafeURL(base, tt.value)
+			if got != tt.want || ok != tt.ok {
+				t.Errorf("SafeURL(%q) = %q, %v, want %q, %v", tt.value, got, ok, tt.want, tt.ok)
+			}
+		})
+	}
+}
+
+func TestSafeURLWithoutBase(t *testing.T) {
+	if got, ok := SafeURL(nil, "docs/a.html"); !ok || got != "docs/a.html" {
+		t.Errorf("relative URL without a base = %q, %v, want it kept", got, ok)
+	}
+	if _, ok := SafeURL(nil, "java\tscript:alert(1)"); ok {
+		t.Error("javascript: URL without a base was kept")
+	}
+}
+
+func TestSanitize(t *testing.T) {
+	base := mustParse(t, "https://example.com/docs/")
+	tests := []struct {
+		name    string
+		markup  string
+		pixels  map[string]bool
+		want    []string
+		notWant []string
+	}{
+		{
+			name:    "scripts and styles dropped with content",
+			markup:  `<p>keep</p><script>alert(1)</script><style>p{}</style><noscript>ns</noscript>`,
+			want:    []string{"<p>keep</p>"},
+			notWant: []string{"alert", "p{}", "ns", "<script", "<style"},
+		},
+		{
+			name:    "event handlers and styles removed",
+			markup:  `<p onclick="alert(1)" style="color:red" title="t">x</p>`,
+			want:    []string{`<p title="t">x</p>`},
+			notWant: []string{"onclick", "style"},
+		},
+		{
+			name:    "javascript link with tab",
+			markup:  "<a href=\"java\tscript:alert(1)\">x</a>",
+			want:    []string{"<a>x</a>"},
+			notWant: []string{"script:", "rel="},
+		},
+		{
+			name:    "javascript link with newline entity",
+			markup:  `<a href="java&#10;script:alert(1)">x</a>`,
+			want:    []string{"<a>x</a>"},
+			notWant: []string{"script:"},
+		},
+		{
+			name:    "javascript link with tab entity",
+			markup:  `<a href="java&Tab;script:alert(1)">x</a>`,
+			notWant: []string{"script:"},
+		},
+		{
+			name:   "links made absolute and nofollow",
+			markup: `<a href="../about">about</a>`,
+			want:   []string{`<a href="https://example.com/about" rel="nofollow noopener noreferrer">about</a>`},
+		},
+		{
+			name:    "page rel replaced",
+			markup:  `<a href="/x" rel="opener">x</a>`,
+			want:    []string{`rel="nofollow noopener noreferrer"`},
+			notWant: []string{`rel="opener"`},
+		},
+		{
+			name:   "base element honoured",
+			markup: `<head><base href="https://static.example.com/v2/"></head><img src="logo.png" alt="logo">`,
+			want:   []string{`<img src="https://static.example.com/v2/logo.png" alt="logo"/>`},
+		},
+		{
+			name:    "data image dropped",
+			markup:  `<img src="data:image/svg+xml,<svg onload=alert(1)>" alt="a">`,
+			want:    []string{`<img alt="a"/>`},
+			notWant: []string{"data:"},
+		},
+		{
+			name:    "unknown elements unwrapped",
+			markup:  `<custom-widget><p>inner</p></custom-widget><font color="red">red</font>`,
+			want:    []string{"<p>inner</p>red"},
+			notWant: []string{"custom-widget", "font"},
+		},
+		{
+			name:    "embeds and forms dropped",
+			markup:  `<iframe src="https://evil.example"></iframe><form><input name="q"></form><object data="x"></object><p>ok</p>`,
+			want:    []string{"<p>ok</p>"},
+			notWant: []string{"iframe", "form", "input", "object"},
+		},
+		{
+			name:    "svg and math dropped",
+			markup:  `<svg><script>alert(1)</script><a href="javascript:alert(1)">x</a></svg><math><mi>x</mi></math><p>ok</p>`,
+			want:    []string{"<p>ok</p>"},
+			notWant: []string{"svg", "math", "alert"},
+		},
+		{
+			name:    "comments dropped",
+			markup:  `<p>a<!-- secret --></p>`,
+			want:    []string{"<p>a</p>"},
+			notWant: []string{"secret"},
+		},
+		{
+			name:    "1x1 image dropped",
+			markup:  `<img src="/i.gif" width="1" height="1"><img src="/photo.jpg" width="1" height="200">`,
+			want:    []string{`<img src="https://example.com/photo.jpg" width="1" height="200"/>`},
+			notWant: []string{"i.gif"},
+		},
+		{
+			name:    "tracker host dropped",
+			markup:  `<img src="https://www.google-analytics.com/collect?v=1"><img src="https://example.com/beacon.gif">`,
+			notWant: []string{"<img"},
+		},
+		{
+			name:    "loaded pixel dropped",
+			markup:  `<img src="spacer.gif"><img src="hero.jpg">`,
+			pixels:  map[string]bool{"https://example.com/docs/spacer.gif": true},
+			want:    []string{`<img src="https://example.com/docs/hero.jpg"/>`},
+			notWant: []string{"spacer"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Sanitize(tt.markup, Options{Base: base, Pixels: tt.pixels})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !strings.HasPrefix(got, "<!DOCTYPE html>\n<html>") {
+				t.Errorf("output does not start with the doctype and html element: %q", got)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(got, want) {
+					t.Errorf("output lacks %q:\n%s", want, got)
+				}
+			}
+			for _, notWant := range tt.notWant {
+				if strings.Contains(got, notWant) {
+					t.Errorf("output contains %q:\n%s", notWant, got)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,64 @@
+package htmltext
+
+import (
+	"html"
+	"sort"
+	"strings"
+
+	nethtml "golang.org/x/net/html"
+)
+
+// Lines renders the tree under n one node per line, indented by depth,
+// so that two documents can be compared line by line: elements as their
+// start tag with the attributes sorted by name, and text with its
+// whitespace collapsed. Whitespace-only text, comments and doctypes are
+// left out.
+func Lines(n *nethtml.Node) []string {
+	var lines []string
+	var walk func(n *nethtml.Node, depth int)
+	walk = func(n *nethtml.Node, depth int) {
+		indent := strings.Repeat("  ", depth)
+		switch n.Type {
+		case nethtml.TextNode:
+			if text := strings.Join(strings.Fields(n.Data), " "); text != "" {
+				lines = append(lines, indent+text)
+			}
+			return
+		case nethtml.ElementNode:
+			lines = append(lines, indent+startTag(n))
+			depth++
+		case nethtml.CommentNode, nethtml.DoctypeNode:
+			return
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c, depth)
+		}
+	}
+	walk(n, 0)
+	return lines
+}
+
+// startTag renders the start tag of an element with sorted attributes.
+func startTag(n *nethtml.Node) string {
+	attrs := append([]nethtml.Attribute(nil), n.Attr...)
+	sort.SliceStable(attrs, func(i, j int) bool { return attrName(attrs[i]) < attrName(attrs[j]) })
+	var b strings.Builder
+	b.WriteString("<" + n.Data)
+	for _, a := range attrs {
+		b.WriteString(" " + attrName(a))
+		if a.Val != "" {
+			b.WriteString(`="` + html.EscapeString(a.Val) + `"`)
+		}
+	}
+	b.WriteString(">")
+	return b.String()
+}
+
+// attrName returns the name of an attribute with its namespace, e.g. of
+// xlink:href in SVG.
+func attrName(a nethtml.Attribute) string {
+	if a.Namespace != "" {
+		return a.Namespace + ":" + a.Key
+	}
+	return a.Key
+}
@@ -0,0 +1,170 @@
+// Package htmltext renders documents parsed by golang.org/x/net/html as
+// text: an approximation of innerText, for extracting text from pages
+// that need no rendering, and one node per line, for comparing them.
+package htmltext
+
+import (
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// hiddenElements are never rendered, so Text skips them.
+var hiddenElements = set("head", "script", "style", "template", "noscript", "iframe", "object", "embed",
+	"noembed", "noframes", "area", "audio", "video", "source", "track", "datalist", "select", "input", "textarea")
+
+// blockElements start and end a line of text.
+var blockElements = set("address", "article", "aside", "blockquote", "body", "caption", "dd", "details",
+	"dialog", "div", "dl", "dt", "fieldset", "figcaption", "figure", "footer", "form", "h1", "h2", "h3", "h4",
+	"h5", "h6", "header", "hgroup", "hr", "html", "legend", "li", "main", "menu", "nav", "ol", "pre", "section",
+	"summary", "table", "tbody", "tfoot", "thead", "tr", "ul")
+
+func set(names ...string) map[string]bool {
+	m := make(map[string]bool, len(names))
+	for _, name := range names {
+		m[name] = true
+	}
+	return m
+}
+
+// Text approximates the innerText of n, as a browser renders it without
+// styles: whitespace is collapsed except in <pre>, block elements are on
+// lines of their own, paragraphs are separated by a blank line, table cells
+// by tabs, and unrendered elements and those with the hidden attribute are
+// left out. The result is trimmed.
+func Text(n *html.Node) string {
+	w := &textWriter{}
+	if n.Type == html.ElementNode && hiddenElements[n.Data] {
+		// Like innerText, an element that is not rendered yields all of
+		// its text as it is
+		var walk func(n *html.Node)
+		walk = func(n *html.Node) {
+			if n.Type == html.TextNode {
+				w.b.WriteString(n.Data)
+			}
+			for c := n.FirstChild; c != nil; c = c.NextSibling {
+				walk(c)
+			}
+		}
+		walk(n)
+		return strings.TrimSpace(w.b.String())
+	}
+	w.node(n, false)
+	return strings.TrimSpace(w.b.String())
+}
+
+type textWriter struct {
+	b strings.Builder
+	// breaks is the number of line breaks due before the next text.
+	breaks int
+	// sep is the separator due before the next text on the same line.
+	sep string
+}
+
+func (w *textWriter) node(n *html.Node, pre bool) {
+	switch n.Type {
+	case html.TextNode:
+		if pre {
+			w.write(n.Data)
+			return
+		}
+		w.collapsed(n.Data)
+		return
+	case html.ElementNode:
+		if _, hidden := attr(n, "hidden"); hidden || hiddenElements[n.Data] {
+			return
+		}
+	case html.DocumentNode:
+	default:
+		// Comments and doctypes
+		return
+	}
+	tag := ""
+	if n.Type == html.ElementNode {
+		tag = n.Data
+	}
+
+	switch {
+	case tag == "br":
+		w.b.WriteString(strings.Repeat("\n", w.breaks))
+		w.b.WriteByte('\n')
+		w.breaks, w.sep = 0, ""
+		return
+	case tag == "p":
+		w.lineBreak(2)
+	case tag == "td" || tag == "th":
+		if w.b.Len() > 0 && w.breaks == 0 {
+			w.sep = "\t"
+		}
+	case blockElements[tag]:
+		w.lineBreak(1)
+	}
+	pre = pre || tag == "pre" || tag == "textarea" || tag == "listing"
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		w.node(c, pre)
+	}
+	switch {
+	case tag == "p":
+		w.lineBreak(2)
+	case blockElements[tag]:
+		w.lineBreak(1)
+	}
+}
+
+// collapsed writes text with runs of whitespace collapsed to one space.
+func (w *textWriter) collapsed(text string) {
+	words := strings.Fields(text)
+	if len(words) == 0 {
+		if text != "" && w.sep == "" {
+			w.sep = " "
+		}
+		return
+	}
+	if isSpace(text[0]) && w.sep == "" {
+		w.sep = " "
+	}
+	w.write(strings.Join(words, " "))
+	if isSpace(text[len(text)-1]) {
+		w.sep = " "
+	}
+}
+
+// write writes text after the line breaks or separator due, which are
+// dropped at the start of the output.
+func (w *textWriter) write(text string) {
+	if text == "" {
+		return
+	}
+	if w.b.Len() > 0 {
+		if w.breaks > 0 {
+			// A <br> already ended the line
+			if strings.HasSuffix(w.b.String(), "\n") {
+				w.breaks--
+			}
+			w.b.WriteString(strings.Repeat("\n", w.breaks))
+		} else {
+			w.b.WriteString(w.sep)
+		}
+	}
+	w.b.WriteString(text)
+	w.breaks, w.sep = 0, ""
+}
+
+func (w *textWriter) lineBreak(n int) {
+	w.breaks = max(w.breaks, n)
+	w.sep = ""
+}
+
+func isSpace(c byte) bool {
+	return strings.IndexByte(" \t\n\r\f", c) >= 0
+}
+
+// attr returns the value of the attribute name of n and whether it is set.
+func attr(n *html.Node, name string) (string, bool) {
+	for _, a := range n.Attr {
+		if a.Namespace == "" && a.Key == name {
+			return a.Val, true
+		}
+	}
+	return "", false
+}
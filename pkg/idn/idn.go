@@ -0,0 +1,239 @@
+// Package idn converts internationalized domain names between their Unicode
+// form and the ASCII "xn--" Punycode form DNS and browsers use, and flags
+// host names that may impersonate others with lookalike characters
+// (homograph attacks).
+//
+// Mapping is the subset of UTS #46 that matters for typed URLs: lowercasing,
+// fullwidth ASCII and ideographic full stops. Full Unicode normalization is
+// left to the browser.
+package idn
+
+import (
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const acePrefix = "xn--"
+
+// ToASCII returns host with every non-ASCII label Punycode-encoded, e.g.
+// "bücher.example" becomes "xn--bcher-kva.example". ASCII hosts are
+// returned lowercased.
+func ToASCII(host string) (string, error) {
+	labels := strings.Split(mapHost(host), ".")
+	for i, label := range labels {
+		if isASCII(label) {
+			continue
+		}
+		encoded, err := encode(label)
+		if err != nil {
+			return "", err
+		}
+		labels[i] = acePrefix + encoded
+		if len(labels[i]) > 63 {
+			return "", fmt.Errorf("label %q of %q is longer than 63 characters once encoded", label, host)
+		}
+	}
+	return strings.Join(labels, "."), nil
+}
+
+// ToUnicode returns host with every "xn--" label decoded, e.g.
+// "xn--bcher-kva.example" becomes "bücher.example". Labels that are not
+// valid Punycode are kept as they are.
+func ToUnicode(host string) string {
+	labels := strings.Split(host, ".")
+	for i, label := range labels {
+		if len(label) <= len(acePrefix) || !strings.EqualFold(label[:len(acePrefix)], acePrefix) {
+			continue
+		}
+		if decoded, err := decode(label[len(acePrefix):]); err == nil {
+			labels[i] = decoded
+		}
+	}
+	return strings.Join(labels, ".")
+}
+
+// IsIDN reports whether host has a non-ASCII or "xn--" label.
+func IsIDN(host string) bool {
+	return ToUnicode(host) != host || !isASCII(host)
+}
+
+// URLToASCII returns rawURL with its host converted by ToASCII, and rawURL
+// unchanged when its host is ASCII or it has none.
+func URLToASCII(rawURL string) (string, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || isASCII(u.Host) {
+		return rawURL, nil
+	}
+	host, err := ToASCII(u.Hostname())
+	if err != nil {
+		return "", err
+	}
+	if port := u.Port(); port != "" {
+		host += ":" + port
+	}
+	return strings.Replace(rawURL, u.Host, host, 1), nil
+}
+
+// URLToUnicode returns rawURL with its host converted by ToUnicode, and
+// rawURL unchanged when it has no IDN host.
+func URLToUnicode(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil || !IsIDN(u.Hostname()) {
+		return rawURL
+	}
+	host := ToUnicode(u.Hostname())
+	if port := u.Port(); port != "" {
+		host += ":" + port
+	}
+	return strings.Replace(rawURL, u.Host, host, 1)
+}
+
+// Homograph returns why host, in either form, may impersonate another host,
+// or nil if it looks safe:
+//   - a label mixes scripts, such as Latin and Cyrillic, other than the
+//     combinations of Latin, Han, kana, Hangul and Bopomofo used in Chinese,
+//     Japanese and Korean names
+//   - a label is all Cyrillic or Greek letters that look like Latin ones,
+//     such as "аррӏе" for "apple"
+//   - host contains invisible characters
+func Homograph(host string) []string {
+	var reasons []string
+	host = ToUnicode(mapHost(host))
+	for _, r := range host {
+		if invisible(r) {
+			reasons = append(reasons, fmt.Sprintf("contains invisible character U+%04X", r))
+			break
+		}
+	}
+	for _, label := range strings.Split(host, ".") {
+		if isASCII(label) {
+			continue
+		}
+		scripts := scriptsOf(label)
+		if len(scripts) > 1 && !cjk(scripts) {
+			reasons = append(reasons, fmt.Sprintf("label %q mixes %s", label, joinScripts(scripts)))
+		} else if len(scripts) == 1 && (scripts[0] == "Cyrillic" || scripts[0] == "Greek") && lookalike(label) {
+			reasons = append(reasons, fmt.Sprintf("label %q is %s letters that look like Latin ones", label, scripts[0]))
+		}
+	}
+	return reasons
+}
+
+// mapHost applies the UTS #46 mappings this package supports.
+func mapHost(host string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r == '\u3002' || r == '\uff0e' || r == '\uff61':
+			return '.'
+		case r >= '\uff01' && r <= '\uff5e':
+			// Fullwidth ASCII
+			return unicode.ToLower(r - 0xfee0)
+		}
+		return unicode.ToLower(r)
+	}, host)
+}
+
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			return false
+		}
+	}
+	return true
+}
+
+func invisible(r rune) bool {
+	switch r {
+	case '\u00ad', '\u034f', '\u115f', '\u1160', '\u180e', '\u200b', '\u200c', '\u200d', '\u2060', '\u3164', '\ufeff':
+		return true
+	}
+	return false
+}
+
+// scripts are those Homograph tells apart; letters of other scripts count
+// as "Other".
+var scripts = []struct {
+	name  string
+	table *unicode.RangeTable
+}{
+	{"Latin", unicode.Latin},
+	{"Cyrillic", unicode.Cyrillic},
+	{"Greek", unicode.Greek},
+	{"Armenian", unicode.Armenian},
+	{"Georgian", unicode.Georgian},
+	{"Hebrew", unicode.Hebrew},
+	{"Arabic", unicode.Arabic},
+	{"Cherokee", unicode.Cherokee},
+	{"Devanagari", unicode.Devanagari},
+	{"Thai", unicode.Thai},
+	{"Han", unicode.Han},
+	{"Hiragana", unicode.Hiragana},
+	{"Katakana", unicode.Katakana},
+	{"Hangul", unicode.Hangul},
+	{"Bopomofo", unicode.Bopomofo},
+}
+
+// scriptsOf lists the scripts of the letters of label, in order of first
+// appearance. Digits, hyphens and combining marks belong to no script.
+func scriptsOf(label string) []string {
+	var found []string
+	for _, r := range label {
+		if !unicode.IsLetter(r) {
+			continue
+		}
+		name := "Other"
+		for _, s := range scripts {
+			if unicode.Is(s.table, r) {
+				name = s.name
+				break
+			}
+		}
+		if !slices.Contains(found, name) {
+			found = append(found, name)
+		}
+	}
+	return found
+}
+
+// cjk reports whether scripts are a combination used in Chinese, Japanese
+// or Korean names.
+func cjk(scripts []string) bool {
+	for _, allowed := range [][]string{
+		{"Latin", "Han", "Hiragana", "Katakana"},
+		{"Latin", "Han", "Hangul"},
+		{"Latin", "Han", "Bopomofo"},
+	} {
+		ok := true
+		for _, s := range scripts {
+			ok = ok && slices.Contains(allowed, s)
+		}
+		if ok {
+			return true
+		}
+	}
+	return false
+}
+
+// latinLookalikes are Cyrillic and Greek lowercase letters rendered like
+// Latin ones in common fonts.
+const latinLookalikes = "аԁеһіјӏорԛсѕуԝхьαικνορυχ"
+
+func lookalike(label string) bool {
+	for _, r := range label {
+		if unicode.IsLetter(r) && !strings.ContainsRune(latinLookalikes, r) {
+			return false
+		}
+	}
+	return true
+}
+
+func joinScripts(scripts []string) string {
+	if len(scripts) == 2 {
+		return scripts[0] + " and " + scripts[1]
+	}
+	return strings.Join(scripts[:len(scripts)-1], ", ") + " and " + scripts[len(scripts)-1]
+}
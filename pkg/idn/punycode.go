@@ -0,0 +1,166 @@
+package idn
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Punycode parameters from RFC 3492.
+const (
+	base        = 36
+	tmin        = 1
+	tmax        = 26
+	skew        = 38
+	damp        = 700
+	initialBias = 72
+	initialN    = 128
+	maxInt      = 1<<31 - 1
+)
+
+// encode returns the Punycode encoding of label, without the ACE prefix.
+func encode(label string) (string, error) {
+	input := []rune(label)
+	var out strings.Builder
+	for _, r := range input {
+		if r < initialN {
+			out.WriteRune(r)
+		}
+	}
+	basic := out.Len()
+	handled := basic
+	if basic > 0 {
+		out.WriteByte('-')
+	}
+
+	n, delta, bias := initialN, 0, initialBias
+	for handled < len(input) {
+		m := rune(maxInt)
+		for _, r := range input {
+			if r >= rune(n) && r < m {
+				m = r
+			}
+		}
+		if int(m)-n > (maxInt-delta)/(handled+1) {
+			return "", fmt.Errorf("label %q is too long to encode", label)
+		}
+		delta += (int(m) - n) * (handled + 1)
+		n = int(m)
+		for _, r := range input {
+			if int(r) < n {
+				delta++
+			}
+			if int(r) != n {
+				continue
+			}
+			q := delta
+			for k := base; ; k += base {
+				t := threshold(k, bias)
+				if q < t {
+					break
+				}
+				out.WriteByte(digit(t + (q-t)%(base-t)))
+				q = (q - t) / (base - t)
+			}
+			out.WriteByte(digit(q))
+			bias = adapt(delta, handled+1, handled == basic)
+			delta = 0
+			handled++
+		}
+		delta++
+		n++
+	}
+	return out.String(), nil
+}
+
+// decode returns the label whose Punycode encoding is s, without the ACE
+// prefix.
+func decode(s string) (string, error) {
+	var output []rune
+	pos := 0
+	if b := strings.LastIndexByte(s, '-'); b >= 0 {
+		for _, r := range s[:b] {
+			if r >= initialN {
+				return "", fmt.Errorf("invalid punycode %q: non-ASCII basic code point", s)
+			}
+			output = append(output, r)
+		}
+		pos = b + 1
+	}
+
+	n, i, bias := initialN, 0, initialBias
+	for pos < len(s) {
+		oldi, w := i, 1
+		for k := base; ; k += base {
+			if pos == len(s) {
+				return "", fmt.Errorf("invalid punycode %q: truncated", s)
+			}
+			d, ok := digitValue(s[pos])
+			if !ok {
+				return "", fmt.Errorf("invalid punycode %q: bad digit %q", s, s[pos])
+			}
+			pos++
+			if d > (maxInt-i)/w {
+				return "", fmt.Errorf("invalid punycode %q: overflow", s)
+			}
+			i += d * w
+			t := threshold(k, bias)
+			if d < t {
+				break
+			}
+			w *= base - t
+		}
+		bias = adapt(i-oldi, len(output)+1, oldi == 0)
+		n += i / (len(output) + 1)
+		i %= len(output) + 1
+		if n > 0x10FFFF {
+			return "", fmt.Errorf("invalid punycode %q: code point out of range", s)
+		}
+		output = append(output[:i], append([]rune{rune(n)}, output[i:]...)...)
+		i++
+	}
+	return string(output), nil
+}
+
+func threshold(k, bias int) int {
+	switch {
+	case k <= bias:
+		return tmin
+	case k >= bias+tmax:
+		return tmax
+	}
+	return k - bias
+}
+
+func adapt(delta, points int, first bool) int {
+	if first {
+		delta /= damp
+	} else {
+		delta /= 2
+	}
+	delta += delta / points
+	k := 0
+	for delta > ((base-tmin)*tmax)/2 {
+		delta /= base - tmin
+		k += base
+	}
+	return k + (base-tmin+1)*delta/(delta+skew)
+}
+
+func digit(d int) byte {
+	if d < 26 {
+		return byte('a' + d)
+	}
+	return byte('0' + d - 26)
+}
+
+func digitValue(c byte) (int, bool) {
+	switch {
+	case c >= 'a' && c <= 'z':
+		return int(c - 'a'), true
+	case c >= 'A' && c <= 'Z':
+		return int(c - 'A'), true
+	case c >= '0' && c <= '9':
+		return int(c-'0') + 26, true
+	}
+	return 0, false
+}
@@ -0,0 +1,362 @@
+// Package imagediff compares a rendered image against an expected one,
+// such as a design exported from a mockup tool, and draws where they
+// differ. The expected image is resized to the width of the rendered one
+// and moved by a few pixels to line the two up before comparing, so
+// designs exported at another scale or with a slightly different margin
+// still compare pixel by pixel.
+package imagediff
+
+import (
+	"cmp"
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+	"slices"
+)
+
+// regionCell is the size of the cells differences are grouped in to find
+// the regions that differ.
+const regionCell = 16
+
+// Colors of the overlay.
+var (
+	diffColor    = color.RGBA{0xef, 0x44, 0x44, 0xff}
+	regionColor  = color.RGBA{0xb9, 0x1c, 0x1c, 0xff}
+	ignoreColor  = color.RGBA{0x3b, 0x82, 0xf6, 0xff}
+	uncoverColor = color.RGBA{0xd1, 0xd5, 0xdb, 0xff}
+)
+
+// Options control Compare.
+type Options struct {
+	// Threshold is the largest difference of a color channel, from 0 to
+	// 255, of pixels still counted as equal, so antialiasing and
+	// compression noise do not count as differences.
+	Threshold int
+	// MaxShift is how many pixels the expected image may be moved in each
+	// direction to line it up with the actual one. 0 compares the images
+	// at their top-left corners.
+	MaxShift int
+	// Ignore lists regions of the actual image that are not compared, in
+	// its pixels.
+	Ignore []image.Rectangle
+}
+
+// Rect is a region of the actual image, in its pixels.
+type Rect struct {
+	X      int `json:"x"`
+	Y      int `json:"y"`
+	Width  int `json:"width"`
+	Height int `json:"height"`
+}
+
+// Result is the outcome of Compare.
+type Result struct {
+	// Width and Height are the size of the actual image.
+	Width  int `json:"width"`
+	Height int `json:"height"`
+	// Scale is the factor the expected image was resized by to the width
+	// of the actual one, e.g. 0.5 for a design exported at 2x.
+	Scale float64 `json:"scale"`
+	// OffsetX and OffsetY are where the top-left corner of the resized
+	// expected image lies on the actual one once aligned.
+	OffsetX int `json:"offsetX"`
+	OffsetY int `json:"offsetY"`
+	// Compared counts the pixels of the actual image that were compared,
+	// Differing those of them that differ and Ratio their share.
+	Compared  int     `json:"compared"`
+	Differing int     `json:"differing"`
+	Ratio     float64 `json:"ratio"`
+	// Ignored counts the pixels in Options.Ignore, and Uncovered those the
+	// expected image does not cover, e.g. because the page is longer than
+	// the design. Neither is compared.
+	Ignored   int `json:"ignored"`
+	Uncovered int `json:"uncovered"`
+	// Regions are the boxes around the areas that differ, largest first.
+	Regions []Rect `json:"regions,omitempty"`
+	// Overlay is the actual image faded to gray, with differing pixels in
+	// red, boxes around the regions, ignored regions tinted blue and
+	// uncovered ones striped.
+	Overlay *image.RGBA `json:"-"`
+}
+
+// Compare compares actual against expected after resizing and aligning
+// expected, as described in the package documentation.
+func Compare(actual, expected image.Image, opts Options) *Result {
+	a := toRGBA(actual)
+	w, h := a.Rect.Dx(), a.Rect.Dy()
+	e := toRGBA(expected)
+	scale := 1.0
+	if ew := e.Rect.Dx(); ew != w && ew > 0 {
+		scale = float64(w) / float64(ew)
+		e = resize(e, w, max(1, int(math.Round(float64(e.Rect.Dy())*scale))))
+	}
+	offset := align(a, e, opts.MaxShift)
+
+	res := &Result{Width: w, Height: h, Scale: scale, OffsetX: offset.X, OffsetY: offset.Y}
+	ignored := make([]bool, w*h)
+	for _, r := range opts.Ignore {
+		r = r.Intersect(a.Rect)
+		for y := r.Min.Y; y < r.Max.Y; y++ {
+			for x := r.Min.X; x < r.Max.X; x++ {
+				ignored[y*w+x] = true
+			}
+		}
+	}
+
+	overlay := image.NewRGBA(a.Rect)
+	cols, rows := (w+regionCell-1)/regionCell, (h+regionCell-1)/regionCell
+	cells := make([]bool, cols*rows)
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			ac := a.RGBAAt(x, y)
+			faded := fade(ac)
+			ex, ey := x-offset.X, y-offset.Y
+			switch {
+			case ignored[y*w+x]:
+				res.Ignored++
+				overlay.SetRGBA(x, y, blend(faded, ignoreColor, 0.35))
+			case ex < 0 || ey < 0 || ex >= e.Rect.Dx() || ey >= e.Rect.Dy():
+				res.Uncovered++
+				if (x+y)/8%2 == 0 {
+					overlay.SetRGBA(x, y, uncoverColor)
+				} else {
+					overlay.SetRGBA(x, y, faded)
+				}
+			default:
+				res.Compared++
+				if channelDiff(ac, e.RGBAAt(ex, ey)) > opts.Threshold {
+					res.Differing++
+					cells[(y/regionCell)*cols+x/regionCell] = true
+					overlay.SetRGBA(x, y, diffColor)
+				} else {
+					overlay.SetRGBA(x, y, faded)
+				}
+			}
+		}
+	}
+	if res.Compared > 0 {
+		res.Ratio = float64(res.Differing) / float64(res.Compared)
+	}
+	res.Regions = regions(cells, cols, rows, w, h)
+	for _, r := range res.Regions {
+		outline(overlay, image.Rect(r.X, r.Y, r.X+r.Width, r.Y+r.Height), regionColor)
+	}
+	res.Overlay = overlay
+	return res
+}
+
+// toRGBA returns img as an RGBA image with its origin at 0,0, composited
+// over white so transparent parts of an export compare as the white page
+// behind them.
+func toRGBA(img image.Image) *image.RGBA {
+	b := img.Bounds()
+	out := image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(out, out.Rect, image.White, image.Point{}, draw.Src)
+	draw.Draw(out, out.Rect, img, b.Min, draw.Over)
+	return out
+}
+
+// resize scales img to w by h pixels, averaging the source pixels under
+// each pixel of the result.
+func resize(img *image.RGBA, w, h int) *image.RGBA {
+	out := image.NewRGBA(image.Rect(0, 0, w, h))
+	sw, sh := img.Rect.Dx(), img.Rect.Dy()
+	fx, fy := float64(sw)/float64(w), float64(sh)/float64(h)
+	for y := 0; y < h; y++ {
+		y0 := int(float64(y) * fy)
+		y1 := max(y0+1, min(sh, int(math.Ceil(float64(y+1)*fy))))
+		for x := 0; x < w; x++ {
+			x0 := int(float64(x) * fx)
+			x1 := max(x0+1, min(sw, int(math.Ceil(float64(x+1)*fx))))
+			var r, g, b, n int
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					c := img.RGBAAt(sx, sy)
+					r, g, b, n = r+int(c.R), g+int(c.G), b+int(c.B), n+1
+				}
+			}
+			out.SetRGBA(x, y, color.RGBA{uint8(r / n), uint8(g / n), uint8(b / n), 0xff})
+		}
+	}
+	return out
+}
+
+// align returns the offset of expected on actual, within maxShift pixels
+// in each direction, at which their gray levels differ least. It searches
+// a quarter-size copy of both first, then the pixels around the best
+// offset found there.
+func align(actual, expected *image.RGBA, maxShift int) image.Point {
+	if maxShift <= 0 {
+		return image.Point{}
+	}
+	factor := 4
+	if maxShift < factor {
+		factor = 1
+	}
+	ga, ge := grayOf(actual, factor), grayOf(expected, factor)
+	best := ga.bestOffset(ge, image.Point{}, maxShift/factor, 1)
+	if factor == 1 {
+		return best
+	}
+	ga, ge = grayOf(actual, 1), grayOf(expected, 1)
+	center := best.Mul(factor)
+	shift := factor
+	found := ga.bestOffset(ge, center, shift, 2)
+	// Stay within maxShift
+	found.X = max(-maxShift, min(maxShift, found.X))
+	found.Y = max(-maxShift, min(maxShift, found.Y))
+	return found
+}
+
+// grayImage is the gray levels of an image, row by row.
+type grayImage struct {
+	w, h int
+	pix  []uint8
+}
+
+// grayOf returns the gray levels of img, averaged over blocks of factor by
+// factor pixels.
+func grayOf(img *image.RGBA, factor int) grayImage {
+	w, h := img.Rect.Dx()/factor, img.Rect.Dy()/factor
+	g := grayImage{w: w, h: h, pix: make([]uint8, w*h)}
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			sum := 0
+			for dy := 0; dy < factor; dy++ {
+				for dx := 0; dx < factor; dx++ {
+					c := img.RGBAAt(x*factor+dx, y*factor+dy)
+					sum += (299*int(c.R) + 587*int(c.G) + 114*int(c.B)) / 1000
+				}
+			}
+			g.pix[y*w+x] = uint8(sum / (factor * factor))
+		}
+	}
+	return g
+}
+
+// bestOffset returns the offset of e on g, at most shift away from center
+// in each direction, with the lowest mean difference of gray levels,
+// sampling every step-th pixel. Offsets where the images overlap by less
+// than half of the smaller one are skipped.
+func (g grayImage) bestOffset(e grayImage, center image.Point, shift, step int) image.Point {
+	best, bestScore := center, math.Inf(1)
+	minOverlap := min(g.w, e.w) * min(g.h, e.h) / 2
+	for oy := center.Y - shift; oy <= center.Y+shift; oy++ {
+		for ox := center.X - shift; ox <= center.X+shift; ox++ {
+			x0, y0 := max(0, ox), max(0, oy)
+			x1, y1 := min(g.w, ox+e.w), min(g.h, oy+e.h)
+			if (x1-x0)*(y1-y0) < minOverlap || x1 <= x0 || y1 <= y0 {
+				continue
+			}
+			sum, n := 0, 0
+			for y := y0; y < y1; y += step {
+				row, erow := g.pix[y*g.w:], e.pix[(y-oy)*e.w:]
+				for x := x0; x < x1; x += step {
+					d := int(row[x]) - int(erow[x-ox])
+					if d < 0 {
+						d = -d
+					}
+					sum += d
+					n++
+				}
+			}
+			score := float64(sum) / float64(n)
+			// Prefer the smaller shift of equal scores
+			if score < bestScore || (score == bestScore && absSum(ox-center.X, oy-center.Y) < absSum(best.X-center.X, best.Y-center.Y)) {
+				best, bestScore = image.Pt(ox, oy), score
+			}
+		}
+	}
+	return best
+}
+
+func absSum(x, y int) int {
+	if x < 0 {
+		x = -x
+	}
+	if y < 0 {
+		y = -y
+	}
+	return x + y
+}
+
+// channelDiff returns the largest difference of the color channels of a
+// and b.
+func channelDiff(a, b color.RGBA) int {
+	d := 0
+	for _, pair := range [3][2]uint8{{a.R, b.R}, {a.G, b.G}, {a.B, b.B}} {
+		v := int(pair[0]) - int(pair[1])
+		if v < 0 {
+			v = -v
+		}
+		d = max(d, v)
+	}
+	return d
+}
+
+// fade turns c into a light gray, so the colors of differences stand out.
+func fade(c color.RGBA) color.RGBA {
+	l := (299*int(c.R) + 587*int(c.G) + 114*int(c.B)) / 1000
+	v := uint8(255 - (255-l)*2/5)
+	return color.RGBA{v, v, v, 0xff}
+}
+
+// blend mixes share of over into c.
+func blend(c, over color.RGBA, share float64) color.RGBA {
+	mix := func(a, b uint8) uint8 { return uint8(float64(a)*(1-share) + float64(b)*share) }
+	return color.RGBA{mix(c.R, over.R), mix(c.G, over.G), mix(c.B, over.B), 0xff}
+}
+
+// outline draws a 2 pixel border around r.
+func outline(img *image.RGBA, r image.Rectangle, c color.RGBA) {
+	r = r.Intersect(img.Rect)
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			if x < r.Min.X+2 || x >= r.Max.X-2 || y < r.Min.Y+2 || y >= r.Max.Y-2 {
+				img.SetRGBA(x, y, c)
+			}
+		}
+	}
+}
+
+// regions returns the boxes of the groups of adjacent cells with
+// differences, in pixels of a w by h image, largest first.
+func regions(cells []bool, cols, rows, w, h int) []Rect {
+	seen := make([]bool, len(cells))
+	var out []Rect
+	for start, diff := range cells {
+		if !diff || seen[start] {
+			continue
+		}
+		minX, minY, maxX, maxY := cols, rows, -1, -1
+		queue := []int{start}
+		seen[start] = true
+		for len(queue) > 0 {
+			i := queue[0]
+			queue = queue[1:]
+			cx, cy := i%cols, i/cols
+			minX, minY, maxX, maxY = min(minX, cx), min(minY, cy), max(maxX, cx), max(maxY, cy)
+			for _, n := range [4][2]int{{cx - 1, cy}, {cx + 1, cy}, {cx, cy - 1}, {cx, cy + 1}} {
+				if n[0] < 0 || n[1] < 0 || n[0] >= cols || n[1] >= rows {
+					continue
+				}
+				j := n[1]*cols + n[0]
+				if cells[j] && !seen[j] {
+					seen[j] = true
+					queue = append(queue, j)
+				}
+			}
+		}
+		x, y := minX*regionCell, minY*regionCell
+		out = append(out, Rect{
+			X: x, Y: y,
+			Width:  min(w, (maxX+1)*regionCell) - x,
+			Height: min(h, (maxY+1)*regionCell) - y,
+		})
+	}
+	slices.SortStableFunc(out, func(a, b Rect) int {
+		return cmp.Compare(b.Width*b.Height, a.Width*a.Height)
+	})
+	return out
+}
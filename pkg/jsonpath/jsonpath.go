@@ -0,0 +1,393 @@
+// Package jsonpath selects values from JSON documents with JSONPath
+// expressions such as "$.items[*].name".
+//
+// Supported are the root $, child names (.name, ['name']), wildcards (.*,
+// [*]), array indices including negative ones ([0], [-1]), slices
+// ([1:3], [::2]), unions ([0,2], ['a','b']) and recursive descent
+// (..name, ..*). Filter expressions are not.
+package jsonpath
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Path is a compiled JSONPath expression.
+type Path struct {
+	raw      string
+	segments []segment
+}
+
+// segment is one step of a path: selectors applied to every current value,
+// or to every current value and all of its descendants.
+type segment struct {
+	descendant bool
+	selectors  []selector
+}
+
+// selector picks children of a value: all of them, an object member, an
+// array element or an array slice.
+type selector struct {
+	wildcard bool
+	name     *string
+	index    *int
+	slice    *[3]*int
+}
+
+// String returns the expression as written.
+func (p *Path) String() string { return p.raw }
+
+// Compile parses a JSONPath expression.
+func Compile(expr string) (*Path, error) {
+	p := &Path{raw: expr}
+	s := strings.TrimSpace(expr)
+	if !strings.HasPrefix(s, "$") {
+		return nil, fmt.Errorf("invalid JSONPath %q: must start with $", expr)
+	}
+	s = s[1:]
+	for s != "" {
+		var seg segment
+		var err error
+		switch {
+		case strings.HasPrefix(s, ".."):
+			seg.descendant = true
+			s = s[2:]
+			if strings.HasPrefix(s, "[") {
+				seg.selectors, s, err = parseBracket(s)
+			} else {
+				seg.selectors, s, err = parseDotted(s)
+			}
+		case strings.HasPrefix(s, "."):
+			seg.selectors, s, err = parseDotted(s[1:])
+		case strings.HasPrefix(s, "["):
+			seg.selectors, s, err = parseBracket(s)
+		default:
+			err = fmt.Errorf("unexpected %q", s)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid JSONPath %q: %w", expr, err)
+		}
+		p.segments = append(p.segments, seg)
+	}
+	return p, nil
+}
+
+// parseDotted parses the name or * following a dot and returns the rest.
+func parseDotted(s string) ([]selector, string, error) {
+	end := strings.IndexAny(s, ".[")
+	if end < 0 {
+		end = len(s)
+	}
+	name := s[:end]
+	switch name {
+	case "":
+		return nil, s, fmt.Errorf("missing name after dot")
+	case "*":
+		return []selector{{wildcard: true}}, s[end:], nil
+	}
+	return []selector{{name: &name}}, s[end:], nil
+}
+
+// parseBracket parses a bracketed, comma-separated list of selectors and
+// returns the rest.
+func parseBracket(s string) ([]selector, string, error) {
+	s = s[1:]
+	var selectors []selector
+	for {
+		s = strings.TrimLeft(s, " ")
+		var sel selector
+		switch {
+		case s == "":
+			return nil, s, fmt.Errorf("missing ]")
+		case s[0] == '?':
+			return nil, s, fmt.Errorf("filter expressions are not supported")
+		case s[0] == '*':
+			sel.wildcard = true
+			s = s[1:]
+		case s[0] == '\'' || s[0] == '"':
+			name, rest, err := parseQuoted(s)
+			if err != nil {
+				return nil, s, err
+			}
+			sel.name, s = &name, rest
+		default:
+			end := strings.IndexAny(s, ",]")
+			if end < 0 {
+				return nil, s, fmt.Errorf("missing ]")
+			}
+			var err error
+			if sel, err = parseIndex(strings.TrimSpace(s[:end])); err != nil {
+				return nil, s, err
+			}
+			s = s[end:]
+		}
+		selectors = append(selectors, sel)
+		s = strings.TrimLeft(s, " ")
+		switch {
+		case strings.HasPrefix(s, ","):
+			s = s[1:]
+		case strings.HasPrefix(s, "]"):
+			return selectors, s[1:], nil
+		default:
+			return nil, s, fmt.Errorf("expected , or ] at %q", s)
+		}
+	}
+}
+
+// parseQuoted parses a quoted member name with backslash escapes and
+// returns the rest.
+func parseQuoted(s string) (string, string, error) {
+	quote := s[0]
+	var b strings.Builder
+	for i := 1; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == quote:
+			return b.String(), s[i+1:], nil
+		case c == '\\' && i+1 < len(s):
+			i++
+			b.WriteByte(s[i])
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return "", s, fmt.Errorf("unterminated string %s", s)
+}
+
+// parseIndex parses an array index or a start:end:step slice.
+func parseIndex(s string) (selector, error) {
+	if !strings.Contains(s, ":") {
+		i, err := strconv.Atoi(s)
+		if err != nil {
+			return selector{}, fmt.Errorf("invalid index %q", s)
+		}
+		return selector{index: &i}, nil
+	}
+	parts := strings.Split(s, ":")
+	if len(parts) > 3 {
+		return selector{}, fmt.Errorf("invalid slice %q", s)
+	}
+	var slice [3]*int
+	for i, part := range parts {
+		if part = strings.TrimSpace(part); part == "" {
+			continue
+		}
+		n, err := strconv.Atoi(part)
+		if err != nil {
+			return selector{}, fmt.Errorf("invalid slice %q", s)
+		}
+		slice[i] = &n
+	}
+	if slice[2] != nil && *slice[2] == 0 {
+		return selector{}, fmt.Errorf("invalid slice %q: step cannot be 0", s)
+	}
+	return selector{slice: &slice}, nil
+}
+
+// Select returns the values of doc the path selects, in document order,
+// as compact JSON.
+func (p *Path) Select(doc []byte) ([]json.RawMessage, error) {
+	root, err := decode(doc)
+	if err != nil {
+		return nil, err
+	}
+	nodes := []any{root}
+	for _, seg := range p.segments {
+		var next []any
+		for _, node := range nodes {
+			if seg.descendant {
+				for _, n := range descendants(node) {
+					next = append(next, seg.apply(n)...)
+				}
+			} else {
+				next = append(next, seg.apply(node)...)
+			}
+		}
+		nodes = next
+	}
+
+	values := make([]json.RawMessage, 0, len(nodes))
+	for _, node := range nodes {
+		data, err := json.Marshal(node)
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, data)
+	}
+	return values, nil
+}
+
+// apply returns the children of node the segment's selectors pick.
+func (seg segment) apply(node any) []any {
+	var out []any
+	for _, sel := range seg.selectors {
+		switch v := node.(type) {
+		case *object:
+			switch {
+			case sel.wildcard:
+				for _, key := range v.keys {
+					out = append(out, v.values[key])
+				}
+			case sel.name != nil:
+				if value, ok := v.values[*sel.name]; ok {
+					out = append(out, value)
+				}
+			}
+		case []any:
+			switch {
+			case sel.wildcard:
+				out = append(out, v...)
+			case sel.index != nil:
+				i := *sel.index
+				if i < 0 {
+					i += len(v)
+				}
+				if i >= 0 && i < len(v) {
+					out = append(out, v[i])
+				}
+			case sel.slice != nil:
+				for _, i := range sliceIndices(*sel.slice, len(v)) {
+					out = append(out, v[i])
+				}
+			}
+		}
+	}
+	return out
+}
+
+// sliceIndices returns the indices of an array of length n a start:end:step
+// slice selects, following Python's slicing rules.
+func sliceIndices(slice [3]*int, n int) []int {
+	step := 1
+	if slice[2] != nil {
+		step = *slice[2]
+	}
+	bound := func(p *int, def int) int {
+		if p == nil {
+			return def
+		}
+		i := *p
+		if i < 0 {
+			i += n
+		}
+		if step > 0 {
+			return min(max(i, 0), n)
+		}
+		return min(max(i, -1), n-1)
+	}
+	var indices []int
+	if step > 0 {
+		for i := bound(slice[0], 0); i < bound(slice[1], n); i += step {
+			indices = append(indices, i)
+		}
+	} else {
+		for i := bound(slice[0], n-1); i > bound(slice[1], -1); i += step {
+			indices = append(indices, i)
+		}
+	}
+	return indices
+}
+
+// descendants returns node and everything nested in it, in document order.
+func descendants(node any) []any {
+	out := []any{node}
+	switch v := node.(type) {
+	case *object:
+		for _, key := range v.keys {
+			out = append(out, descendants(v.values[key])...)
+		}
+	case []any:
+		for _, child := range v {
+			out = append(out, descendants(child)...)
+		}
+	}
+	return out
+}
+
+// object is a JSON object that keeps its members in document order, so
+// wildcards select them in the order they were written.
+type object struct {
+	keys   []string
+	values map[string]any
+}
+
+func (o *object) MarshalJSON() ([]byte, error) {
+	var b bytes.Buffer
+	b.WriteByte('{')
+	for i, key := range o.keys {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		k, err := json.Marshal(key)
+		if err != nil {
+			return nil, err
+		}
+		v, err := json.Marshal(o.values[key])
+		if err != nil {
+			return nil, err
+		}
+		b.Write(k)
+		b.WriteByte(':')
+		b.Write(v)
+	}
+	b.WriteByte('}')
+	return b.Bytes(), nil
+}
+
+// decode parses a JSON document into objects, []any, json.Number, string,
+// bool and nil, keeping numbers exactly as written.
+func decode(data []byte) (any, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	value, err := decodeValue(dec)
+	if err != nil {
+		return nil, fmt.Errorf("invalid JSON: %w", err)
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return nil, fmt.Errorf("invalid JSON: data after the top-level value")
+	}
+	return value, nil
+}
+
+func decodeValue(dec *json.Decoder) (any, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	switch tok {
+	case json.Delim('{'):
+		obj := &object{values: make(map[string]any)}
+		for dec.More() {
+			keyTok, err := dec.Token()
+			if err != nil {
+				return nil, err
+			}
+			key := keyTok.(string)
+			value, err := decodeValue(dec)
+			if err != nil {
+				return nil, err
+			}
+			if _, dup := obj.values[key]; !dup {
+				obj.keys = append(obj.keys, key)
+			}
+			obj.values[key] = value
+		}
+		_, err := dec.Token()
+		return obj, err
+	case json.Delim('['):
+		arr := []any{}
+		for dec.More() {
+			value, err := decodeValue(dec)
+			if err != nil {
+				return nil, err
+			}
+			arr = append(arr, value)
+		}
+		_, err := dec.Token()
+		return arr, err
+	}
+	return tok, nil
+}
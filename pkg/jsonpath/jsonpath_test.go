@@ -0,0 +1,149 @@
+package jsonpath
+
+import (
+	"strings"
+	"testing"
+)
+
+// store is the example document of the original JSONPath article.
+const store = `{
+  "store": {
+    "book": [
+      {"category": "reference", "author": "Nigel Rees", "title": "Sayings of the Century", "price": 8.95},
+      {"category": "fiction", "author": "Evelyn Waugh", "title": "Sword of Honour", "price": 12.99},
+      {"category": "fiction", "author": "Herman Melville", "title": "Moby Dick", "isbn": "0-553-21311-3", "price": 8.99},
+      {"category": "fiction", "author": "J. R. R. Tolkien", "title": "The Lord of the Rings", "isbn": "0-395-19395-8", "price": 22.99}
+    ],
+    "bicycle": {"color": "red", "price": 19.95}
+  },
+  "weird keys": {"a.b": 1, "it's": 2, "[x]": 3},
+  "numbers": [0, 1, 2, 3, 4, 5, 6, 7, 8, 9],
+  "exact": 1.10000000000000000001
+}`
+
+func TestSelect(t *testing.T) {
+	tests := []struct {
+		path string
+		// want is the selected values, joined by spaces
+		want string
+	}{
+		{"$.store.bicycle.color", `"red"`},
+		{"$['store']['bicycle']['color']", `"red"`},
+		{`$["store"].bicycle["color"]`, `"red"`},
+		{"$.store.book[*].author", `"Nigel Rees" "Evelyn Waugh" "Herman Melville" "J. R. R. Tolkien"`},
+		{"$.store.book.*.price", `8.95 12.99 8.99 22.99`},
+		{"$..author", `"Nigel Rees" "Evelyn Waugh" "Herman Melville" "J. R. R. Tolkien"`},
+		{"$.store..price", `8.95 12.99 8.99 22.99 19.95`},
+		{"$.store.bicycle.*", `"red" 19.95`},
+		{"$..book[2].title", `"Moby Dick"`},
+		{"$..book[-1].title", `"The Lord of the Rings"`},
+		{"$..book[0,1].title", `"Sayings of the Century" "Sword of Honour"`},
+		{"$..book[:2].price", `8.95 12.99`},
+		{"$..book[-2:].price", `8.99 22.99`},
+		{"$..book[1:3].category", `"fiction" "fiction"`},
+		{"$..book..isbn", `"0-553-21311-3" "0-395-19395-8"`},
+		{"$..[0].title", `"Sayings of the Century"`},
+		{"$['store']['bicycle']['color','price']", `"red" 19.95`},
+		{"$.numbers[::3]", "0 3 6 9"},
+		{"$.numbers[1:8:2]", "1 3 5 7"},
+		{"$.numbers[::-1]", "9 8 7 6 5 4 3 2 1 0"},
+		{"$.numbers[7:2:-2]", "7 5 3"},
+		{"$.numbers[-3:]", "7 8 9"},
+		{"$.numbers[:-8]", "0 1"},
+		{"$.numbers[20:]", ""},
+		{"$.numbers[-20:2]", "0 1"},
+		{"$.numbers[10]", ""},
+		{"$.numbers[-11]", ""},
+		{"$.numbers[ 1 , 3 ]", "1 3"},
+		{"$['weird keys']['a.b']", "1"},
+		{`$['weird keys']['it\'s']`, "2"},
+		{`$['weird keys']["[x]"]`, "3"},
+		{"$.missing", ""},
+		{"$.store.bicycle[0]", ""},
+		{"$.numbers.length", ""},
+		// Numbers are kept as written
+		{"$.exact", "1.10000000000000000001"},
+	}
+	for _, tt := range tests {
+		p, err := Compile(tt.path)
+		if err != nil {
+			t.Errorf("Compile(%q) error = %v", tt.path, err)
+			continue
+		}
+		values, err := p.Select([]byte(store))
+		if err != nil {
+			t.Errorf("Select(%q) error = %v", tt.path, err)
+			continue
+		}
+		got := make([]string, len(values))
+		for i, v := range values {
+			got[i] = string(v)
+		}
+		if strings.Join(got, " ") != tt.want {
+			t.Errorf("Select(%q) = %s, want %s", tt.path, strings.Join(got, " "), tt.want)
+		}
+	}
+}
+
+func TestSelectKeepsMemberOrder(t *testing.T) {
+	// Whole objects are selected with their members in document order
+	p, err := Compile("$.*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	values, err := p.Select([]byte(`{"z": 1, "a": {"y": 2, "b": 3}, "m": 4}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, v := range values {
+		got = append(got, string(v))
+	}
+	if want := `1 {"y":2,"b":3} 4`; strings.Join(got, " ") != want {
+		t.Errorf("Select() = %s, want %s", strings.Join(got, " "), want)
+	}
+}
+
+func TestCompileErrors(t *testing.T) {
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"", "must start with $"},
+		{"store.book", "must start with $"},
+		{"$store", `unexpected "store"`},
+		{"$.", "missing name after dot"},
+		{"$..", "missing name after dot"},
+		{"$.a[", "missing ]"},
+		{"$.a[0", "missing ]"},
+		{"$.a['b'", "expected , or ]"},
+		{"$.a['b", "unterminated string"},
+		{"$.a[?(@.price < 10)]", "filter expressions are not supported"},
+		{"$.a[x]", `invalid index "x"`},
+		{"$.a[1:2:3:4]", "invalid slice"},
+		{"$.a[1:x]", "invalid slice"},
+		{"$.a[::0]", "step cannot be 0"},
+		{"$.a['b' 'c']", "expected , or ]"},
+	}
+	for _, tt := range tests {
+		_, err := Compile(tt.path)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("Compile(%q) error = %v, want one containing %q", tt.path, err, tt.want)
+		}
+	}
+}
+
+func TestSelectInvalidJSON(t *testing.T) {
+	p, err := Compile("$.a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, doc := range []string{``, `{"a": 1`, `{"a": 1} {"a": 2}`, `{"a": 1} x`, `[1,]`} {
+		if _, err := p.Select([]byte(doc)); err == nil || !strings.Contains(err.Error(), "invalid JSON") {
+			t.Errorf("Select(%q) error = %v, want invalid JSON", doc, err)
+		}
+	}
+	if values, err := p.Select([]byte(" {\"a\": 1}\n")); err != nil || len(values) != 1 {
+		t.Errorf("Select() of a document with surrounding whitespace = %q, %v", values, err)
+	}
+}
@@ -0,0 +1,348 @@
+// Package miniyaml decodes the subset of YAML used by configuration files:
+// block mappings and sequences, plain and quoted scalars, flow sequences of
+// scalars and comments. Anchors, tags, multi-line scalars and multiple
+// documents are not supported.
+package miniyaml
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// line is a non-blank line with its comment removed.
+type line struct {
+	num    int
+	indent int
+	text   string
+}
+
+// parser walks the lines of a document.
+type parser struct {
+	lines []line
+	pos   int
+}
+
+// Unmarshal decodes data into v the way encoding/json decodes the
+// equivalent JSON document, so v's fields use json tags. Fields of the
+// document that v does not have are an error.
+func Unmarshal(data []byte, v any) error {
+	lines, err := split(string(data))
+	if err != nil {
+		return err
+	}
+	var doc any
+	if len(lines) > 0 {
+		p := &parser{lines: lines}
+		if doc, err = p.node(lines[0].indent); err != nil {
+			return err
+		}
+		if p.pos < len(p.lines) {
+			return p.errorf("unexpected content after the document")
+		}
+	}
+
+	js, err := json.Marshal(doc)
+	if err != nil {
+		return err
+	}
+	dec := json.NewDecoder(bytes.NewReader(js))
+	dec.DisallowUnknownFields()
+	return dec.Decode(v)
+}
+
+// split returns the lines of src that hold content, without comments.
+func split(src string) ([]line, error) {
+	var lines []line
+	for i, raw := range strings.Split(strings.ReplaceAll(src, "\r\n", "\n"), "\n") {
+		text := stripComment(raw)
+		trimmed := strings.TrimLeft(text, " ")
+		if strings.TrimSpace(trimmed) == "" || len(lines) == 0 && strings.TrimSpace(trimmed) == "---" {
+			continue
+		}
+		if strings.HasPrefix(trimmed, "\t") {
+			return nil, fmt.Errorf("line %d: tabs cannot be used for indentation", i+1)
+		}
+		lines = append(lines, line{num: i + 1, indent: len(text) - len(trimmed), text: strings.TrimRight(trimmed, " \t")})
+	}
+	return lines, nil
+}
+
+// stripComment removes a # comment, which starts the line or follows a
+// space outside quotes.
+func stripComment(s string) string {
+	var quote byte
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case quote != 0:
+			if c == '\\' && quote == '"' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '#' && (i == 0 || s[i-1] == ' ' || s[i-1] == '\t'):
+			return s[:i]
+		}
+	}
+	return s
+}
+
+func (p *parser) errorf(format string, args ...any) error {
+	num := 0
+	if p.pos < len(p.lines) {
+		num = p.lines[p.pos].num
+	} else if len(p.lines) > 0 {
+		num = p.lines[len(p.lines)-1].num
+	}
+	return fmt.Errorf("line %d: %s", num, fmt.Sprintf(format, args...))
+}
+
+// node parses the mapping, sequence or scalar starting at the current line,
+// which is indented by indent.
+func (p *parser) node(indent int) (any, error) {
+	l := p.lines[p.pos]
+	switch {
+	case isItem(l.text):
+		return p.sequence(indent)
+	case keyEnd(l.text) >= 0:
+		return p.mapping(indent)
+	default:
+		p.pos++
+		v, err := scalar(l.text)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", l.num, err)
+		}
+		return v, nil
+	}
+}
+
+// isItem reports whether text is a sequence item.
+func isItem(text string) bool {
+	return text == "-" || strings.HasPrefix(text, "- ")
+}
+
+// keyEnd returns the index of the colon ending the key of a mapping entry,
+// or -1 when text is not one.
+func keyEnd(text string) int {
+	var quote byte
+	for i := 0; i < len(text); i++ {
+		switch c := text[i]; {
+		case quote != 0:
+			if c == '\\' && quote == '"' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case i == 0 && (c == '"' || c == '\''):
+			quote = c
+		case c == '[' || c == '{':
+			if i == 0 {
+				return -1
+			}
+		case c == ':' && (i == len(text)-1 || text[i+1] == ' '):
+			return i
+		}
+	}
+	return -1
+}
+
+func (p *parser) sequence(indent int) (any, error) {
+	items := []any{}
+	for p.pos < len(p.lines) {
+		l := p.lines[p.pos]
+		if l.indent < indent {
+			break
+		}
+		if l.indent > indent {
+			return nil, p.errorf("unexpected indentation")
+		}
+		if !isItem(l.text) {
+			// A sequence under a mapping key may share its indentation
+			break
+		}
+
+		rest := strings.TrimLeft(strings.TrimPrefix(l.text, "-"), " ")
+		if rest == "" {
+			p.pos++
+			item, err := p.child(indent, false)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, item)
+			continue
+		}
+		// The item's content continues as if it started its own line, so
+		// "- key: value" opens a mapping indented to the key
+		p.lines[p.pos] = line{num: l.num, indent: l.indent + len(l.text) - len(rest), text: rest}
+		item, err := p.node(p.lines[p.pos].indent)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+func (p *parser) mapping(indent int) (any, error) {
+	m := map[string]any{}
+	for p.pos < len(p.lines) {
+		l := p.lines[p.pos]
+		if l.indent < indent || (l.indent == indent && isItem(l.text)) {
+			break
+		}
+		if l.indent > indent {
+			return nil, p.errorf("unexpected indentation")
+		}
+		end := keyEnd(l.text)
+		if end <= 0 {
+			return nil, p.errorf("expected KEY: VALUE")
+		}
+		// Plain keys are names as written, so "null" or "1.50" stay
+		// what they are rather than being read as values
+		name := l.text[:end]
+		if name[0] == '"' || name[0] == '\'' {
+			key, err := scalar(name)
+			if err != nil {
+				return nil, p.errorf("%v", err)
+			}
+			name = key.(string)
+		}
+		if _, ok := m[name]; ok {
+			return nil, p.errorf("duplicate key %q", name)
+		}
+
+		rest := strings.TrimSpace(l.text[end+1:])
+		p.pos++
+		var err error
+		if rest == "" {
+			if m[name], err = p.child(indent, true); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if m[name], err = scalar(rest); err != nil {
+			return nil, fmt.Errorf("line %d: %w", l.num, err)
+		}
+	}
+	return m, nil
+}
+
+// child parses the value of an entry whose content follows on the next
+// lines, indented further than indent. The value of a mapping key may also
+// be a sequence at the key's indentation. Without such lines it is null.
+func (p *parser) child(indent int, inMapping bool) (any, error) {
+	if p.pos >= len(p.lines) {
+		return nil, nil
+	}
+	l := p.lines[p.pos]
+	if l.indent > indent || (inMapping && l.indent == indent && isItem(l.text)) {
+		return p.node(l.indent)
+	}
+	return nil, nil
+}
+
+var (
+	intPattern   = regexp.MustCompile(`^[-+]?[0-9]+$`)
+	floatPattern = regexp.MustCompile(`^[-+]?([0-9]+\.[0-9]*|\.[0-9]+)([eE][-+]?[0-9]+)?$`)
+)
+
+// scalar converts a plain, quoted or flow sequence value.
+func scalar(s string) (any, error) {
+	switch {
+	case s == "":
+		return nil, nil
+	case s[0] == '"':
+		v, err := strconv.Unquote(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid double-quoted string %s", s)
+		}
+		return v, nil
+	case s[0] == '\'':
+		if len(s) < 2 || s[len(s)-1] != '\'' {
+			return nil, fmt.Errorf("invalid single-quoted string %s", s)
+		}
+		return strings.ReplaceAll(s[1:len(s)-1], "''", "'"), nil
+	case s[0] == '[':
+		return flowSequence(s)
+	case s == "{}":
+		return map[string]any{}, nil
+	case s[0] == '{' || s[0] == '|' || s[0] == '>' || s[0] == '&' || s[0] == '*' || s[0] == '!':
+		return nil, fmt.Errorf("unsupported YAML syntax %s", s)
+	}
+
+	switch s {
+	case "~", "null", "Null", "NULL":
+		return nil, nil
+	case "true", "True", "TRUE":
+		return true, nil
+	case "false", "False", "FALSE":
+		return false, nil
+	}
+	if intPattern.MatchString(s) {
+		if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+			return n, nil
+		}
+	}
+	if floatPattern.MatchString(s) {
+		if f, err := strconv.ParseFloat(s, 64); err == nil {
+			return f, nil
+		}
+	}
+	return s, nil
+}
+
+// flowSequence converts a one-line sequence of scalars such as [a, "b"].
+func flowSequence(s string) (any, error) {
+	if s[len(s)-1] != ']' {
+		return nil, fmt.Errorf("invalid flow sequence %s", s)
+	}
+	inner := strings.TrimSpace(s[1 : len(s)-1])
+	items := []any{}
+	if inner == "" {
+		return items, nil
+	}
+
+	var quote byte
+	start := 0
+	for i := 0; i <= len(inner); i++ {
+		if i < len(inner) {
+			c := inner[i]
+			switch {
+			case quote != 0:
+				if c == '\\' && quote == '"' {
+					i++
+				} else if c == quote {
+					quote = 0
+				}
+				continue
+			case c == '"' || c == '\'':
+				quote = c
+				continue
+			case c == '[' || c == ']' || c == '{' || c == '}':
+				return nil, fmt.Errorf("nested flow collections are not supported: %s", s)
+			case c != ',':
+				continue
+			}
+		}
+		text := strings.TrimSpace(inner[start:i])
+		if text == "" {
+			// A trailing comma ends the sequence
+			if i == len(inner) && start > 0 {
+				break
+			}
+			return nil, fmt.Errorf("empty item in flow sequence %s", s)
+		}
+		item, err := scalar(text)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+		start = i + 1
+	}
+	return items, nil
+}
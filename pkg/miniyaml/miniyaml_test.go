@@ -0,0 +1,107 @@
+package miniyaml
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestUnmarshal(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		// want is the document as JSON
+		want string
+	}{
+		{"empty", "", `null`},
+		{"comments only", "# nothing\n\n", `null`},
+		{"scalars", "s: text\ni: 42\nneg: -7\nf: 1.5\nexp: 1e3\nb: true\nB: FALSE\nn: ~\nnull: null\nempty:\n",
+			`{"B":false,"b":true,"empty":null,"exp":"1e3","f":1.5,"i":42,"n":null,"neg":-7,"null":null,"s":"text"}`},
+		{"quoted scalars", `a: "x: \"y\" # not a comment"` + "\nb: 'it''s'\nc: \"42\"\n", `{"a":"x: \"y\" # not a comment","b":"it's","c":"42"}`},
+		{"comments", "# header\na: 1 # trailing\nb: x#y\n", `{"a":1,"b":"x#y"}`},
+		{"document start", "---\na: 1\n", `{"a":1}`},
+		{"CRLF", "a: 1\r\nb: 2\r\n", `{"a":1,"b":2}`},
+		{"nested mapping", "a:\n  b:\n    c: 1\n  d: 2\n", `{"a":{"b":{"c":1},"d":2}}`},
+		{"sequence", "- a\n- 1\n-\n  - nested\n", `["a",1,["nested"]]`},
+		{"sequence under key", "targets:\n- https://a.example\n- https://b.example\nflags: {}\n", `{"flags":{},"targets":["https://a.example","https://b.example"]}`},
+		{"indented sequence under key", "targets:\n  - a\n  - b\n", `{"targets":["a","b"]}`},
+		{"mappings in sequence", "steps:\n  - click: \"#go\"\n    wait: 2\n  - type: x\n", `{"steps":[{"click":"#go","wait":2},{"type":"x"}]}`},
+		{"flow sequence", "a: [1, 'b, c', \"d\", ]\nb: []\n", `{"a":[1,"b, c","d"],"b":[]}`},
+		{"URL value", "url: https://example.com/a?b=c\n", `{"url":"https://example.com/a?b=c"}`},
+		{"quoted key", "\"a: b\": 1\n", `{"a: b":1}`},
+		{"plain keys as written", "1.50: a\ntrue: b\n~: c\n", `{"1.50":"a","true":"b","~":"c"}`},
+		{"top-level scalar", "hello\n", `"hello"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got any
+			if err := Unmarshal([]byte(tt.in), &got); err != nil {
+				t.Fatalf("Unmarshal() error = %v", err)
+			}
+			js, err := json.Marshal(got)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(js) != tt.want {
+				t.Errorf("Unmarshal() = %s, want %s", js, tt.want)
+			}
+		})
+	}
+}
+
+func TestUnmarshalErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		// want is part of the error message
+		want string
+	}{
+		{"tab indentation", "a:\n\tb: 1\n", "line 2: tabs"},
+		{"duplicate key", "a: 1\na: 2\n", `line 2: duplicate key "a"`},
+		{"unexpected indentation", "a: 1\n  b: 2\n", "line 2: unexpected indentation"},
+		{"not a mapping entry", "a: 1\nb\n", "line 2: expected KEY: VALUE"},
+		{"empty key", "a: 1\n: 2\n", "line 2: expected KEY: VALUE"},
+		{"content after document", "- a\nb: 1\n", "unexpected content after the document"},
+		{"anchor", "a: &x 1\n", "unsupported YAML syntax"},
+		{"block scalar", "a: |\n  text\n", "unsupported YAML syntax"},
+		{"flow mapping", "a: {b: 1}\n", "unsupported YAML syntax"},
+		{"nested flow sequence", "a: [[1]]\n", "nested flow collections"},
+		{"unterminated flow sequence", "a: [1, 2\n", "invalid flow sequence"},
+		{"empty flow item", "a: [1, , 2]\n", "empty item"},
+		{"flow comma only", "a: [,]\n", "empty item"},
+		{"unterminated double quote", "a: \"x\n", "invalid double-quoted string"},
+		{"unterminated single quote", "a: 'x\n", "invalid single-quoted string"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got any
+			err := Unmarshal([]byte(tt.in), &got)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Unmarshal() error = %v, want one containing %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestUnmarshalStruct(t *testing.T) {
+	type config struct {
+		Targets []string       `json:"targets"`
+		Flags   map[string]any `json:"flags"`
+		Count   int            `json:"count"`
+	}
+	var c config
+	if err := Unmarshal([]byte("targets: [a, b]\nflags:\n  screenshot: true\ncount: 3\n"), &c); err != nil {
+		t.Fatal(err)
+	}
+	if len(c.Targets) != 2 || c.Targets[1] != "b" || c.Flags["screenshot"] != true || c.Count != 3 {
+		t.Errorf("Unmarshal() = %+v", c)
+	}
+
+	// Fields the struct does not have are an error
+	if err := Unmarshal([]byte("target: a\n"), &c); err == nil || !strings.Contains(err.Error(), "unknown field") {
+		t.Errorf("Unmarshal() of an unknown field: error = %v", err)
+	}
+	if err := Unmarshal([]byte("count: many\n"), &c); err == nil {
+		t.Error("Unmarshal() of a string into an int succeeded")
+	}
+}
@@ -0,0 +1,159 @@
+// Package pii finds personal data in text, such as e-mail addresses, phone
+// numbers, payment card numbers and national ID numbers, and replaces it
+// with placeholders naming its kind. Detection is pattern based: card
+// numbers must pass the Luhn check, and phone numbers need a leading + or
+// the separators numbers are written with, so plain numbers such as
+// timestamps or order IDs are left alone.
+package pii
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Kinds of personal data, as counted by Redact.
+const (
+	Email      = "email"
+	CreditCard = "credit-card"
+	NationalID = "national-id"
+	Phone      = "phone"
+)
+
+// Kinds lists the kinds Redact finds, in the order it looks for them.
+var Kinds = []string{Email, CreditCard, NationalID, Phone}
+
+// Counts holds the number of replacements by kind.
+type Counts map[string]int
+
+// pattern finds one kind of personal data.
+type pattern struct {
+	kind        string
+	placeholder string
+	re          *regexp.Regexp
+	// valid, if set, rejects matches that only look like the kind.
+	valid func(match string) bool
+}
+
+var patterns = []pattern{
+	{
+		kind:        Email,
+		placeholder: "[EMAIL]",
+		re:          regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9-]+(?:\.[A-Za-z0-9-]+)*\.[A-Za-z]{2,}`),
+	},
+	{
+		// 13 to 19 digits, in groups separated by spaces or dashes
+		kind:        CreditCard,
+		placeholder: "[CREDIT-CARD]",
+		re:          regexp.MustCompile(`\d(?:[ -]?\d){12,18}`),
+		valid:       luhn,
+	},
+	{
+		// US social security numbers (not area 000, 666 or 9xx), UK
+		// national insurance numbers and Indian Aadhaar numbers as written
+		kind:        NationalID,
+		placeholder: "[NATIONAL-ID]",
+		re: regexp.MustCompile(`(?:[0-8]\d{2}-\d{2}-\d{4})` +
+			`|(?:[A-CEGHJ-PR-TW-Z][A-CEGHJ-NPR-TW-Z] ?\d{2} ?\d{2} ?\d{2} ?[A-D])` +
+			`|(?:[2-9]\d{3} \d{4} \d{4})`),
+		valid: func(match string) bool {
+			return !strings.HasPrefix(match, "000-") && !strings.HasPrefix(match, "666-")
+		},
+	},
+	{
+		kind:        Phone,
+		placeholder: "[PHONE]",
+		re:          regexp.MustCompile(`\+?(?:\(\d{1,4}\)[ .-]?)?\d(?:[ .-]?\(?\d\)?){6,16}`),
+		valid:       phone,
+	},
+}
+
+// Redact returns text with the personal data it finds replaced by
+// placeholders such as [EMAIL] and [PHONE], and how many it replaced of
+// each kind.
+func Redact(text string) (string, Counts) {
+	counts := Counts{}
+	for _, p := range patterns {
+		text = p.redact(text, counts)
+	}
+	return text, counts
+}
+
+// redact replaces the matches of p in text that stand on their own, rather
+// than being part of a longer word or number.
+func (p pattern) redact(text string, counts Counts) string {
+	var sb strings.Builder
+	last := 0
+	for _, loc := range p.re.FindAllStringIndex(text, -1) {
+		start, end := loc[0], loc[1]
+		if start > 0 && wordByte(text[start-1]) || end < len(text) && wordByte(text[end]) {
+			continue
+		}
+		match := text[start:end]
+		if p.valid != nil && !p.valid(match) {
+			continue
+		}
+		sb.WriteString(text[last:start])
+		sb.WriteString(p.placeholder)
+		last = end
+		counts[p.kind]++
+	}
+	if last == 0 {
+		return text
+	}
+	sb.WriteString(text[last:])
+	return sb.String()
+}
+
+// wordByte reports whether b continues a word or number next to a match.
+func wordByte(b byte) bool {
+	return b >= '0' && b <= '9' || b >= 'A' && b <= 'Z' || b >= 'a' && b <= 'z' || b == '_' || b == '@' || b == '+'
+}
+
+// digits returns the digits of s.
+func digits(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= '0' && r <= '9' {
+			return r
+		}
+		return -1
+	}, s)
+}
+
+// luhn reports whether the digits of s pass the Luhn check of payment card
+// numbers.
+func luhn(s string) bool {
+	d := digits(s)
+	sum := 0
+	for i := len(d) - 1; i >= 0; i-- {
+		n := int(d[i] - '0')
+		if (len(d)-i)%2 == 0 {
+			n *= 2
+			if n > 9 {
+				n -= 9
+			}
+		}
+		sum += n
+	}
+	return sum%10 == 0
+}
+
+// date matches the start of dates, which are written like phone numbers,
+// e.g. 2024-01-15 10 in "2024-01-15 10:30".
+var date = regexp.MustCompile(`^(?:\d{4}[-./]\d{1,2}[-./]\d{1,2}|\d{1,2}[-./]\d{1,2}[-./]\d{2,4})(?:\D|$)`)
+
+// phone reports whether s is written like a phone number: with a leading
+// + and 8 to 15 digits, or with 10 or 11 digits in separated groups or
+// with the area code in parentheses, and not like a date.
+func phone(s string) bool {
+	if date.MatchString(s) {
+		return false
+	}
+	n := len(digits(s))
+	if strings.HasPrefix(s, "+") {
+		return n >= 8 && n <= 15
+	}
+	if n < 10 || n > 11 {
+		return false
+	}
+	return strings.ContainsAny(s, " .-(")
+}
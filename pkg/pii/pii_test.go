@@ -0,0 +1,67 @@
+package pii
+
+import (
+	"maps"
+	"testing"
+)
+
+func TestRedact(t *testing.T) {
+	tests := []struct {
+		name   string
+		in     string
+		want   string
+		counts Counts
+	}{
+		{"nothing", "Order 12345 shipped at 10:30.", "Order 12345 shipped at 10:30.", Counts{}},
+		{"email", "Write to jane.doe+news@mail.example.co.uk today", "Write to [EMAIL] today", Counts{Email: 1}},
+		{"emails", "a@b.io, c@d.org", "[EMAIL], [EMAIL]", Counts{Email: 2}},
+		{"not an email", "user@localhost", "user@localhost", Counts{}},
+		{"card", "Card: 4111 1111 1111 1111.", "Card: [CREDIT-CARD].", Counts{CreditCard: 1}},
+		{"card with dashes", "5500-0000-0000-0004", "[CREDIT-CARD]", Counts{CreditCard: 1}},
+		{"card without separators", "pay with 4012888888881881 now", "pay with [CREDIT-CARD] now", Counts{CreditCard: 1}},
+		{"not a card", "ID 4111111111111112", "ID 4111111111111112", Counts{}},
+		{"SSN", "SSN 123-45-6789", "SSN [NATIONAL-ID]", Counts{NationalID: 1}},
+		{"SSN area 000", "000-12-3456", "000-12-3456", Counts{}},
+		{"SSN area 666", "666-12-3456", "666-12-3456", Counts{}},
+		{"NI number", "NI: AB 12 34 56 C", "NI: [NATIONAL-ID]", Counts{NationalID: 1}},
+		{"Aadhaar", "Aadhaar 2345 6789 0123", "Aadhaar [NATIONAL-ID]", Counts{NationalID: 1}},
+		{"international phone", "Call +49 30 1234567", "Call [PHONE]", Counts{Phone: 1}},
+		{"US phone", "(555) 123-4567 or 555.123.4567", "[PHONE] or [PHONE]", Counts{Phone: 2}},
+		{"plain number", "Ticket 5551234567", "Ticket 5551234567", Counts{}},
+		{"short number", "+1 234", "+1 234", Counts{}},
+		{"date", "2024-01-15 10:30", "2024-01-15 10:30", Counts{}},
+		{"part of a word", "x123-45-6789 and 123-45-6789y", "x123-45-6789 and 123-45-6789y", Counts{}},
+		{"mixed", "jane@example.com, +1 555 123 4567, 4111-1111-1111-1111",
+			"[EMAIL], [PHONE], [CREDIT-CARD]", Counts{Email: 1, Phone: 1, CreditCard: 1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, counts := Redact(tt.in)
+			if got != tt.want {
+				t.Errorf("Redact(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+			if !maps.Equal(counts, tt.counts) {
+				t.Errorf("Redact(%q) counts = %v, want %v", tt.in, counts, tt.counts)
+			}
+		})
+	}
+}
+
+func TestLuhn(t *testing.T) {
+	tests := []struct {
+		in   string
+		want bool
+	}{
+		{"4111111111111111", true},
+		{"4111 1111 1111 1111", true},
+		{"378282246310005", true},
+		{"6011111111111117", true},
+		{"4111111111111112", false},
+		{"1234567812345678", false},
+	}
+	for _, tt := range tests {
+		if got := luhn(tt.in); got != tt.want {
+			t.Errorf("luhn(%q) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+}
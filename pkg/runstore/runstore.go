@@ -0,0 +1,119 @@
+// Package runstore keeps what past runs saw of their pages, their title,
+// text and screenshot checksum, in the SQLite database of a sqlite: sink,
+// so the history of a page can be compared between dates.
+package runstore
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	// Registers the pure-Go "sqlite" driver, so builds need no C compiler
+	_ "modernc.org/sqlite"
+)
+
+// options make writers of the same database, such as the sink and
+// parallel targets of a batch, wait for each other instead of failing.
+const options = "?_pragma=busy_timeout(10000)&_pragma=journal_mode(WAL)"
+
+// schema creates the table runs are stored in, indexed for the runs of a
+// URL in time order.
+const schema = `CREATE TABLE IF NOT EXISTS runs (
+	id INTEGER PRIMARY KEY,
+	url TEXT NOT NULL,
+	recorded_at TEXT NOT NULL,
+	title TEXT NOT NULL,
+	content TEXT NOT NULL,
+	screenshot_sha256 TEXT NOT NULL
+);
+CREATE INDEX IF NOT EXISTS runs_url_time ON runs (url, recorded_at)`
+
+// timeLayout stores times in UTC with a fixed width, so that they sort as
+// text.
+const timeLayout = "2006-01-02T15:04:05.000000000Z"
+
+// Run is what one run saw of a page.
+type Run struct {
+	URL  string    `json:"url"`
+	Time time.Time `json:"time"`
+	// Title is the page's title.
+	Title string `json:"title"`
+	// Content is the visible text of the page.
+	Content string `json:"content"`
+	// ScreenshotSHA256 is the hex-encoded checksum of the page's
+	// screenshot, empty when none was taken.
+	ScreenshotSHA256 string `json:"screenshotSha256,omitempty"`
+}
+
+// Store holds the runs of the SQLite database at Path.
+type Store struct {
+	Path string
+
+	db *sql.DB
+}
+
+// Open opens the database at path, creating it and the runs table if
+// needed. Close it when done.
+func Open(path string) (*Store, error) {
+	db, err := sql.Open("sqlite", path+options)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open SQLite database %s: %w", path, err)
+	}
+	if _, err := db.Exec(schema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to create runs table in %s: %w", path, err)
+	}
+	return &Store{Path: path, db: db}, nil
+}
+
+// Record stores run.
+func (s *Store) Record(ctx context.Context, run Run) error {
+	_, err := s.db.ExecContext(ctx, `INSERT INTO runs (url, recorded_at, title, content, screenshot_sha256) VALUES (?, ?, ?, ?, ?)`,
+		run.URL, run.Time.UTC().Format(timeLayout), run.Title, run.Content, run.ScreenshotSHA256)
+	if err != nil {
+		return fmt.Errorf("failed to record run of %s in %s: %w", run.URL, s.Path, err)
+	}
+	return nil
+}
+
+// Runs returns the runs of url recorded from from until before to, oldest
+// first. A zero from or to leaves that end open.
+func (s *Store) Runs(ctx context.Context, url string, from, to time.Time) ([]Run, error) {
+	query := `SELECT recorded_at, title, content, screenshot_sha256 FROM runs WHERE url = ?`
+	args := []any{url}
+	if !from.IsZero() {
+		query += ` AND recorded_at >= ?`
+		args = append(args, from.UTC().Format(timeLayout))
+	}
+	if !to.IsZero() {
+		query += ` AND recorded_at < ?`
+		args = append(args, to.UTC().Format(timeLayout))
+	}
+	rows, err := s.db.QueryContext(ctx, query+` ORDER BY recorded_at, id`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read runs of %s from %s: %w", url, s.Path, err)
+	}
+	defer rows.Close()
+	runs := []Run{}
+	for rows.Next() {
+		run := Run{URL: url}
+		var at string
+		if err := rows.Scan(&at, &run.Title, &run.Content, &run.ScreenshotSHA256); err != nil {
+			return nil, fmt.Errorf("failed to read runs of %s from %s: %w", url, s.Path, err)
+		}
+		if run.Time, err = time.Parse(timeLayout, at); err != nil {
+			return nil, fmt.Errorf("invalid time %q of a run of %s in %s: %w", at, url, s.Path, err)
+		}
+		runs = append(runs, run)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read runs of %s from %s: %w", url, s.Path, err)
+	}
+	return runs, nil
+}
+
+// Close closes the database.
+func (s *Store) Close() error {
+	return s.db.Close()
+}
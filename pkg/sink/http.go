@@ -0,0 +1,55 @@
+package sink
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"time"
+)
+
+// HTTP POSTs each output to URL. The output name is sent in the
+// Content-Disposition header; the Location response header, when present,
+// is reported as the output's location.
+type HTTP struct {
+	URL    string
+	Client *http.Client
+}
+
+// Write implements Sink.
+func (h *HTTP) Write(ctx context.Context, name, contentType string, data []byte) (string, error) {
+	client := h.Client
+	if client == nil {
+		client = &http.Client{Timeout: 30 * time.Second}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, h.URL, bytes.NewReader(data))
+	if err != nil {
+		return "", fmt.Errorf("invalid sink URL %s: %w", h.URL, err)
+	}
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": name}))
+
+	slog.Debug("Posting output", "url", h.URL, "name", name, "size", len(data))
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to post %s to %s: %w", name, h.URL, err)
+	}
+	defer func() {
+		if err := resp.Body.Close(); err != nil {
+			slog.Warn("failed to close response body", "error", err)
+		}
+	}()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return "", fmt.Errorf("posting %s to %s returned status %d: %s", name, h.URL, resp.StatusCode, bytes.TrimSpace(body))
+	}
+
+	if location := resp.Header.Get("Location"); location != "" {
+		return location, nil
+	}
+	return h.URL, nil
+}
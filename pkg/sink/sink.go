@@ -0,0 +1,106 @@
+// Package sink provides the destinations screenshots, PDFs, extracted text
+// and reports are written to.
+package sink
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// Sink receives the named outputs produced by a run.
+type Sink interface {
+	// Write stores data under name and returns where it ended up (a file
+	// path or URL), or "" when the destination has no address, as with
+	// standard output.
+	Write(ctx context.Context, name, contentType string, data []byte) (string, error)
+}
+
+// Kinds lists the kinds of sink New accepts, as spelled in specs.
+var Kinds = []string{"file", "stdout", "http", "https", "s3", "sqlite"}
+
+// New returns the sink described by spec:
+//
+//	file          files in the current directory
+//	file:DIR      files in DIR
+//	stdout        standard output
+//	http(s)://... HTTP POST of each output to the URL
+//	s3://BUCKET/PREFIX  S3 PUT using the AWS_* environment credentials
+//	sqlite:PATH   rows of the SQLite database at PATH
+//
+// Sinks holding resources, such as the SQLite database, implement
+// io.Closer.
+func New(spec string) (Sink, error) {
+	slog.Debug("Creating output sink", "spec", spec)
+
+	switch {
+	case spec == "file":
+		return &File{Dir: "."}, nil
+	case strings.HasPrefix(spec, "file:"):
+		dir := strings.TrimPrefix(spec, "file:")
+		if dir == "" {
+			return nil, fmt.Errorf("invalid sink %q: missing directory after file:", spec)
+		}
+		return &File{Dir: dir}, nil
+	case spec == "stdout":
+		return &Stdout{}, nil
+	case strings.HasPrefix(spec, "http://"), strings.HasPrefix(spec, "https://"):
+		return &HTTP{URL: spec}, nil
+	case strings.HasPrefix(spec, "s3://"):
+		return NewS3(spec)
+	case strings.HasPrefix(spec, "sqlite:"):
+		path := strings.TrimPrefix(spec, "sqlite:")
+		if path == "" {
+			return nil, fmt.Errorf("invalid sink %q: missing database path after sqlite:", spec)
+		}
+		return NewSQLite(path)
+	default:
+		return nil, fmt.Errorf("unsupported sink %q (expected file, file:DIR, stdout, http(s)://URL, s3://BUCKET/PREFIX or sqlite:PATH)", spec)
+	}
+}
+
+// File writes each output as a file in Dir, creating Dir if needed.
+type File struct {
+	Dir string
+}
+
+// Write implements Sink.
+func (f *File) Write(ctx context.Context, name, contentType string, data []byte) (string, error) {
+	if err := os.MkdirAll(f.Dir, 0o755); err != nil {
+		return "", fmt.Errorf("failed to create output directory %q: %w", f.Dir, err)
+	}
+	fileName := filepath.Join(f.Dir, name)
+	slog.Debug("Writing output file", "fileName", fileName, "size", len(data))
+	if err := os.WriteFile(fileName, data, 0o644); err != nil {
+		return "", err
+	}
+	return fileName, nil
+}
+
+// Stdout writes each output to W, or to os.Stdout when W is nil.
+// Writes are serialized so concurrent outputs never interleave.
+type Stdout struct {
+	W io.Writer
+
+	mu sync.Mutex
+}
+
+// Write implements Sink.
+func (s *Stdout) Write(ctx context.Context, name, contentType string, data []byte) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	w := s.W
+	if w == nil {
+		w = os.Stdout
+	}
+	if _, err := w.Write(data); err != nil {
+		return "", err
+	}
+	return "", nil
+}
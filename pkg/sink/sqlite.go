@@ -0,0 +1,69 @@
+package sink
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"time"
+
+	// Registers the pure-Go "sqlite" driver, so builds need no C compiler
+	_ "modernc.org/sqlite"
+)
+
+// sqliteOptions make writers of the same database, such as parallel
+// targets of a batch, wait for each other instead of failing.
+const sqliteOptions = "?_pragma=busy_timeout(10000)&_pragma=journal_mode(WAL)"
+
+// sqliteSchema creates the table outputs are stored in.
+const sqliteSchema = `CREATE TABLE IF NOT EXISTS outputs (
+	id INTEGER PRIMARY KEY,
+	name TEXT NOT NULL,
+	content_type TEXT NOT NULL,
+	data BLOB NOT NULL,
+	created_at TEXT NOT NULL
+)`
+
+// SQLite stores each output as a row of the outputs table in the SQLite
+// database at Path, created if needed: its name, content type, content and
+// the time it was written, in RFC 3339 UTC.
+type SQLite struct {
+	Path string
+
+	db *sql.DB
+}
+
+// NewSQLite opens the database at path, creating it and the outputs table
+// if needed. Close it when done.
+func NewSQLite(path string) (*SQLite, error) {
+	db, err := sql.Open("sqlite", path+sqliteOptions)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open SQLite database %s: %w", path, err)
+	}
+	if _, err := db.Exec(sqliteSchema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to create outputs table in %s: %w", path, err)
+	}
+	return &SQLite{Path: path, db: db}, nil
+}
+
+// Write implements Sink. The location is the database path and the id of
+// the row, as PATH#ID.
+func (s *SQLite) Write(ctx context.Context, name, contentType string, data []byte) (string, error) {
+	slog.Debug("Storing output in SQLite", "path", s.Path, "name", name, "size", len(data))
+	res, err := s.db.ExecContext(ctx, `INSERT INTO outputs (name, content_type, data, created_at) VALUES (?, ?, ?, ?)`,
+		name, contentType, data, time.Now().UTC().Format(time.RFC3339))
+	if err != nil {
+		return "", fmt.Errorf("failed to store %s in %s: %w", name, s.Path, err)
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return "", fmt.Errorf("failed to store %s in %s: %w", name, s.Path, err)
+	}
+	return fmt.Sprintf("%s#%d", s.Path, id), nil
+}
+
+// Close closes the database.
+func (s *SQLite) Close() error {
+	return s.db.Close()
+}
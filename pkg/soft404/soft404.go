@@ -0,0 +1,123 @@
+// Package soft404 tells whether a page served with a success status is
+// actually an error page, a "soft 404", from signals collected on the
+// rendered page. Each heuristic that fires adds to a score, and pages
+// scoring at least Threshold are soft 404s, so no single weak hint such as
+// thin content flags a page on its own.
+package soft404
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// Threshold is the score from which a page is a soft 404.
+const Threshold = 4
+
+// Weights of the heuristics.
+const (
+	weightTitle   = 3
+	weightHeading = 3
+	weightURL     = 3
+	weightText    = 2
+	weightLayout  = 2
+	weightThin    = 1
+	weightNoindex = 1
+)
+
+// thinWords is the word count below which a page's content is thin.
+const thinWords = 80
+
+// textPrefix is how much of the body text is searched for error phrases;
+// further down, they are more likely part of real content.
+const textPrefix = 1000
+
+// errorPhrase matches what error pages say, in English and a few other
+// common languages.
+var errorPhrase = regexp.MustCompile(`(?i)\b(404|410)\b|page (was |is )?not found|not be found|(couldn'?t|could not|cannot|can'?t) be found|(does ?n[o']t|no longer) exists?|no longer available|page (is )?(unavailable|missing)|nothing (was )?found|(seite|datei) nicht gefunden|página no encontrada|page introuvable|pagina non trovata|pagina niet gevonden|страница не найдена`)
+
+// errorPath matches URL path segments of error pages that servers redirect
+// to, e.g. /404 or /errors/not-found.html.
+var errorPath = regexp.MustCompile(`(?i)/(404|410|not[-_]?found|page[-_]?not[-_]?found|errors?)(\.[a-z]+)?(/|$)`)
+
+// errorMarker matches ids and class names of error page layouts, e.g.
+// error-404, page-not-found or notfound.
+var errorMarker = regexp.MustCompile(`(?i)(^|[-_])(404|not[-_]?found|error[-_]?page|page[-_]?not[-_]?found)([-_]|$)|^error$`)
+
+// Signals is what a page reveals about whether it is an error page.
+type Signals struct {
+	// URL is the final URL of the page.
+	URL string
+	// Status is the document's HTTP status, 0 when unknown.
+	Status int
+	Title  string
+	// Headings are the texts of the page's h1 and h2 elements.
+	Headings []string
+	// Text is the start of the page's body text and Words the number of
+	// words of all of it.
+	Text  string
+	Words int
+	// Markers are the ids and class names of the root, body and main
+	// elements and their children.
+	Markers []string
+	// Robots is the content of the robots meta element.
+	Robots string
+}
+
+// Verdict is the outcome of Detect.
+type Verdict struct {
+	Soft404 bool     `json:"soft404"`
+	Score   int      `json:"score"`
+	Reasons []string `json:"reasons,omitempty"`
+}
+
+// Detect scores s and returns whether the page is a soft 404, with the
+// reasons behind the score. Pages served with an error status are hard
+// errors, not soft 404s, and score 0.
+func Detect(s Signals) Verdict {
+	var v Verdict
+	if s.Status >= 300 {
+		v.Reasons = []string{fmt.Sprintf("served with status %d", s.Status)}
+		return v
+	}
+	add := func(weight int, format string, args ...any) {
+		v.Score += weight
+		v.Reasons = append(v.Reasons, fmt.Sprintf(format, args...))
+	}
+
+	if m := errorPhrase.FindString(s.Title); m != "" {
+		add(weightTitle, "title %q says %q", s.Title, m)
+	}
+	for _, h := range s.Headings {
+		if m := errorPhrase.FindString(h); m != "" {
+			add(weightHeading, "heading %q says %q", h, m)
+			break
+		}
+	}
+	text := s.Text
+	if len(text) > textPrefix {
+		text = text[:textPrefix]
+	}
+	if m := errorPhrase.FindString(text); m != "" {
+		add(weightText, "text says %q", m)
+	}
+	if u, err := url.Parse(s.URL); err == nil && errorPath.MatchString(u.Path) {
+		add(weightURL, "URL path %q looks like an error page", u.Path)
+	}
+	for _, marker := range s.Markers {
+		if errorMarker.MatchString(marker) {
+			add(weightLayout, "error layout %q", marker)
+			break
+		}
+	}
+	if s.Words < thinWords {
+		add(weightThin, "thin content (%d words)", s.Words)
+	}
+	if strings.Contains(strings.ToLower(s.Robots), "noindex") {
+		add(weightNoindex, "robots %q", s.Robots)
+	}
+
+	v.Soft404 = v.Score >= Threshold
+	return v
+}
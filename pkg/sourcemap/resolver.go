@@ -0,0 +1,202 @@
+package sourcemap
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxFetchSize caps the size of scripts and source maps a Resolver loads.
+const maxFetchSize = 32 << 20
+
+// errNoSourceMap means a script does not reference a source map.
+var errNoSourceMap = errors.New("script has no source map")
+
+// Resolver maps positions in deployed scripts to their original sources.
+// It finds each script's map through its SourceMap header or
+// sourceMappingURL comment and caches it, including failures, so every
+// script is fetched at most once. It is safe for concurrent use.
+type Resolver struct {
+	// Client is used for http(s) URLs; nil means a client with a 30
+	// second timeout.
+	Client *http.Client
+
+	mu   sync.Mutex
+	maps map[string]*cachedMap
+}
+
+type cachedMap struct {
+	once sync.Once
+	m    *Map
+	err  error
+}
+
+// Resolve returns the original position of the zero-based line and column
+// in the script at scriptURL. It returns false when the script has no
+// usable source map or the map does not cover the position.
+func (r *Resolver) Resolve(ctx context.Context, scriptURL string, line, column int64) (Position, bool) {
+	if scriptURL == "" {
+		return Position{}, false
+	}
+	m, err := r.load(ctx, scriptURL)
+	if err != nil {
+		return Position{}, false
+	}
+	return m.Lookup(line, column)
+}
+
+// load returns the cached map of scriptURL, loading it on first use.
+func (r *Resolver) load(ctx context.Context, scriptURL string) (*Map, error) {
+	r.mu.Lock()
+	if r.maps == nil {
+		r.maps = make(map[string]*cachedMap)
+	}
+	entry, ok := r.maps[scriptURL]
+	if !ok {
+		entry = &cachedMap{}
+		r.maps[scriptURL] = entry
+	}
+	r.mu.Unlock()
+
+	entry.once.Do(func() {
+		entry.m, entry.err = r.fetchMap(ctx, scriptURL)
+		switch {
+		case errors.Is(entry.err, errNoSourceMap):
+			slog.Debug("Script has no source map", "url", scriptURL)
+		case entry.err != nil:
+			slog.Warn("Failed to load source map", "script", scriptURL, "error", entry.err)
+		}
+	})
+	return entry.m, entry.err
+}
+
+// fetchMap loads the script, locates its source map and parses it.
+func (r *Resolver) fetchMap(ctx context.Context, scriptURL string) (*Map, error) {
+	script, err := url.Parse(scriptURL)
+	if err != nil {
+		return nil, err
+	}
+	body, header, err := r.fetch(ctx, script)
+	if err != nil {
+		return nil, err
+	}
+	ref := header.Get("SourceMap")
+	if ref == "" {
+		ref = header.Get("X-SourceMap")
+	}
+	if ref == "" {
+		ref = sourceMappingURL(body)
+	}
+	if ref == "" {
+		return nil, errNoSourceMap
+	}
+
+	mapURL, err := script.Parse(ref)
+	if err != nil {
+		return nil, fmt.Errorf("invalid source map URL %q: %w", ref, err)
+	}
+	slog.Debug("Loading source map", "script", scriptURL, "map", truncate(mapURL.String(), 200))
+	data, _, err := r.fetch(ctx, mapURL)
+	if err != nil {
+		return nil, err
+	}
+	base := mapURL
+	if mapURL.Scheme == "data" {
+		// Sources of inline maps are relative to the script
+		base = script
+	}
+	return Parse(data, base)
+}
+
+// fetch returns the content at u, which may be an http(s), file or data URL.
+func (r *Resolver) fetch(ctx context.Context, u *url.URL) ([]byte, http.Header, error) {
+	switch u.Scheme {
+	case "data":
+		data, err := decodeDataURL(u.String())
+		return data, http.Header{}, err
+	case "file":
+		f, err := os.Open(u.Path)
+		if err != nil {
+			return nil, nil, err
+		}
+		defer func() {
+			if err := f.Close(); err != nil {
+				slog.Warn("failed to close script file", "path", u.Path, "error", err)
+			}
+		}()
+		data, err := io.ReadAll(io.LimitReader(f, maxFetchSize))
+		return data, http.Header{}, err
+	case "http", "https":
+	default:
+		return nil, nil, fmt.Errorf("unsupported URL scheme %q", u.Scheme)
+	}
+
+	client := r.Client
+	if client == nil {
+		client = &http.Client{Timeout: 30 * time.Second}
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer func() {
+		if err := resp.Body.Close(); err != nil {
+			slog.Warn("failed to close response body", "url", u.Redacted(), "error", err)
+		}
+	}()
+	if resp.StatusCode >= 300 {
+		return nil, nil, fmt.Errorf("GET %s: %s", u.Redacted(), resp.Status)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxFetchSize))
+	return data, resp.Header, err
+}
+
+// sourceMappingURL returns the URL of the last sourceMappingURL comment of
+// a script, or "".
+func sourceMappingURL(script []byte) string {
+	for _, marker := range [][]byte{[]byte("//# sourceMappingURL="), []byte("//@ sourceMappingURL=")} {
+		if i := bytes.LastIndex(script, marker); i >= 0 {
+			rest := script[i+len(marker):]
+			if end := bytes.IndexAny(rest, " \t\r\n"); end >= 0 {
+				rest = rest[:end]
+			}
+			return string(rest)
+		}
+	}
+	return ""
+}
+
+// decodeDataURL returns the content of a data: URL.
+func decodeDataURL(raw string) ([]byte, error) {
+	meta, data, ok := strings.Cut(strings.TrimPrefix(raw, "data:"), ",")
+	if !ok {
+		return nil, errors.New("invalid data URL")
+	}
+	if strings.HasSuffix(meta, ";base64") {
+		return base64.StdEncoding.DecodeString(data)
+	}
+	text, err := url.PathUnescape(data)
+	return []byte(text), err
+}
+
+// truncate shortens s, such as an inline data URL, for logging.
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n] + "..."
+}
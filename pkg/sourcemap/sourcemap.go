@@ -0,0 +1,184 @@
+// Package sourcemap maps positions in generated JavaScript back to the
+// original sources using Source Map revision 3 documents, and locates the
+// source maps of deployed scripts.
+package sourcemap
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// Position is a location in an original source file. Line and Column are
+// zero-based, like the positions Chrome reports.
+type Position struct {
+	Source string
+	Line   int64
+	Column int64
+	// Name is the original identifier at the position, if the map has it.
+	Name string
+}
+
+// Map is a parsed source map.
+type Map struct {
+	sources []string
+	names   []string
+	// lines holds the segments of every generated line, sorted by column.
+	lines [][]segment
+}
+
+// segment maps a generated column to an original position. source is -1
+// for segments without one, name -1 for segments without a name.
+type segment struct {
+	column       int64
+	source       int
+	sourceLine   int64
+	sourceColumn int64
+	name         int
+}
+
+type mapDocument struct {
+	Version    int               `json:"version"`
+	SourceRoot string            `json:"sourceRoot"`
+	Sources    []string          `json:"sources"`
+	Names      []string          `json:"names"`
+	Mappings   string            `json:"mappings"`
+	Sections   []json.RawMessage `json:"sections"`
+}
+
+// Parse parses a source map. Sources are resolved against its sourceRoot
+// and, when relative, against base, the URL the map was loaded from.
+func Parse(data []byte, base *url.URL) (*Map, error) {
+	// Maps may start with a line guarding against XSSI
+	if rest, ok := strings.CutPrefix(string(data), ")]}'"); ok {
+		_, rest, _ = strings.Cut(rest, "\n")
+		data = []byte(rest)
+	}
+	var doc mapDocument
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("invalid source map: %w", err)
+	}
+	if doc.Version != 3 {
+		return nil, fmt.Errorf("unsupported source map version %d", doc.Version)
+	}
+	if len(doc.Sections) > 0 {
+		return nil, errors.New("indexed source maps are not supported")
+	}
+
+	m := &Map{names: doc.Names}
+	for _, src := range doc.Sources {
+		m.sources = append(m.sources, resolveSource(doc.SourceRoot, src, base))
+	}
+	if err := m.decode(doc.Mappings); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// resolveSource joins root and src and resolves the result against base.
+func resolveSource(root, src string, base *url.URL) string {
+	if root != "" && !strings.HasSuffix(root, "/") {
+		root += "/"
+	}
+	joined := root + src
+	u, err := url.Parse(joined)
+	if err != nil || u.IsAbs() || base == nil {
+		return joined
+	}
+	return base.ResolveReference(u).String()
+}
+
+// decode parses the VLQ-encoded mappings.
+func (m *Map) decode(mappings string) error {
+	var source, name int
+	var sourceLine, sourceColumn int64
+	for _, line := range strings.Split(mappings, ";") {
+		var segments []segment
+		var column int64
+		for _, field := range strings.Split(line, ",") {
+			if field == "" {
+				continue
+			}
+			values, err := decodeVLQ(field)
+			if err != nil {
+				return err
+			}
+			column += values[0]
+			seg := segment{column: column, source: -1, name: -1}
+			switch len(values) {
+			case 1:
+			case 4, 5:
+				source += int(values[1])
+				sourceLine += values[2]
+				sourceColumn += values[3]
+				if source < 0 || source >= len(m.sources) {
+					return fmt.Errorf("source map segment refers to source %d of %d", source, len(m.sources))
+				}
+				seg.source, seg.sourceLine, seg.sourceColumn = source, sourceLine, sourceColumn
+				if len(values) == 5 {
+					name += int(values[4])
+					seg.name = name
+				}
+			default:
+				return fmt.Errorf("invalid source map segment %q", field)
+			}
+			segments = append(segments, seg)
+		}
+		sort.SliceStable(segments, func(i, j int) bool { return segments[i].column < segments[j].column })
+		m.lines = append(m.lines, segments)
+	}
+	return nil
+}
+
+const base64Digits = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789+/"
+
+// decodeVLQ decodes the base64 VLQ values of one segment.
+func decodeVLQ(field string) ([]int64, error) {
+	var values []int64
+	var value int64
+	var shift uint
+	for i := 0; i < len(field); i++ {
+		digit := strings.IndexByte(base64Digits, field[i])
+		if digit < 0 || shift > 60 {
+			return nil, fmt.Errorf("invalid source map segment %q", field)
+		}
+		value |= int64(digit&31) << shift
+		if digit&32 != 0 {
+			shift += 5
+			continue
+		}
+		if value&1 != 0 {
+			value = -(value >> 1)
+		} else {
+			value >>= 1
+		}
+		values = append(values, value)
+		value, shift = 0, 0
+	}
+	if shift != 0 {
+		return nil, fmt.Errorf("truncated source map segment %q", field)
+	}
+	return values, nil
+}
+
+// Lookup returns the original position of the zero-based generated line
+// and column: that of the closest mapping at or before the column.
+func (m *Map) Lookup(line, column int64) (Position, bool) {
+	if line < 0 || line >= int64(len(m.lines)) {
+		return Position{}, false
+	}
+	segments := m.lines[line]
+	i := sort.Search(len(segments), func(i int) bool { return segments[i].column > column }) - 1
+	if i < 0 || segments[i].source < 0 {
+		return Position{}, false
+	}
+	seg := segments[i]
+	pos := Position{Source: m.sources[seg.source], Line: seg.sourceLine, Column: seg.sourceColumn}
+	if seg.name >= 0 && seg.name < len(m.names) {
+		pos.Name = m.names[seg.name]
+	}
+	return pos, true
+}
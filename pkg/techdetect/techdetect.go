@@ -0,0 +1,451 @@
+// Package techdetect identifies the technologies a web page is built with,
+// such as JavaScript frameworks, CMSs, analytics and tag managers, from
+// signals collected on the rendered page. Rules follow Wappalyzer's model:
+// a technology is detected when any of its patterns matches, and the first
+// capture group of a matching pattern, if any, is its version.
+package techdetect
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Categories of the built-in rules.
+const (
+	CategoryFramework  = "JavaScript frameworks"
+	CategoryLibrary    = "JavaScript libraries"
+	CategoryCMS        = "CMS"
+	CategoryEcommerce  = "Ecommerce"
+	CategoryAnalytics  = "Analytics"
+	CategoryTagManager = "Tag managers"
+	CategoryWebServer  = "Web servers"
+	CategoryCDN        = "CDN"
+	CategoryPaaS       = "PaaS"
+	CategoryLanguage   = "Programming languages"
+)
+
+// Signals is what a page reveals about how it was built.
+type Signals struct {
+	// HTML is the rendered document's markup.
+	HTML string
+	// ScriptSrcs are the src URLs of the document's script elements.
+	ScriptSrcs []string
+	// Meta maps the lower-cased name (or property) of meta elements to
+	// their content.
+	Meta map[string]string
+	// Headers are the main document's response headers.
+	Headers map[string]string
+	// Cookies are the names of the page's cookies.
+	Cookies []string
+	// Globals maps the JavaScript global paths listed by Globals that are
+	// defined on the page to their value: strings and numbers as text,
+	// anything else as "".
+	Globals map[string]string
+}
+
+// Technology is a detected technology.
+type Technology struct {
+	Name       string   `json:"name"`
+	Categories []string `json:"categories"`
+	Version    string   `json:"version,omitempty"`
+}
+
+// Rule describes how to recognize a technology. Patterns are regular
+// expressions matched case-insensitively; an empty pattern in a map only
+// requires the key (header, meta element or global) to be present.
+type Rule struct {
+	Name       string
+	Categories []string
+	HTML       []string
+	ScriptSrc  []string
+	Meta       map[string]string
+	Headers    map[string]string
+	// Cookies are patterns matched against cookie names.
+	Cookies []string
+	// Globals maps dotted global paths, e.g. "jQuery.fn.jquery", to a
+	// pattern for their value.
+	Globals map[string]string
+	// Implies lists technologies a detection of this one implies.
+	Implies []string
+}
+
+// Rules are the built-in detection rules.
+var Rules = []Rule{
+	// JavaScript frameworks
+	{
+		Name: "React", Categories: []string{CategoryFramework},
+		HTML:      []string{`<[^>]+\sdata-reactroot`},
+		ScriptSrc: []string{`/react(?:-dom)?(?:@|[.-])(\d+\.\d+\.\d+)`},
+		Globals:   map[string]string{"React.version": `^([\d.]+)`},
+	},
+	{
+		Name: "Next.js", Categories: []string{CategoryFramework},
+		HTML:    []string{`<script[^>]+id="__NEXT_DATA__"`},
+		Headers: map[string]string{"x-powered-by": `^Next\.js ?([\d.]+)?`},
+		Globals: map[string]string{"__NEXT_DATA__": "", "next.version": `^([\d.]+)`},
+		Implies: []string{"React"},
+	},
+	{
+		Name: "Gatsby", Categories: []string{CategoryFramework},
+		HTML:    []string{`<div id="___gatsby"`},
+		Meta:    map[string]string{"generator": `^Gatsby(?: ([\d.]+))?`},
+		Implies: []string{"React"},
+	},
+	{
+		Name: "Vue.js", Categories: []string{CategoryFramework},
+		HTML:    []string{`<[^>]+\sdata-v-[0-9a-f]{8}`},
+		Globals: map[string]string{"Vue.version": `^([\d.]+)`, "__VUE__": ""},
+	},
+	{
+		Name: "Nuxt.js", Categories: []string{CategoryFramework},
+		HTML:    []string{`<div id="__nuxt"`},
+		Globals: map[string]string{"__NUXT__": "", "$nuxt": ""},
+		Implies: []string{"Vue.js"},
+	},
+	{
+		Name: "Angular", Categories: []string{CategoryFramework},
+		HTML:    []string{`<[^>]+\sng-version="([\d.]+)"`},
+		Globals: map[string]string{"ng.coreTokens": ""},
+	},
+	{
+		Name: "AngularJS", Categories: []string{CategoryFramework},
+		HTML:    []string{`<[^>]+\sng-app[=\s>]`},
+		Globals: map[string]string{"angular.version.full": `^([\d.]+)`},
+	},
+	{
+		Name: "Svelte", Categories: []string{CategoryFramework},
+		HTML: []string{`<[^>]+class="[^"]*\bsvelte-[a-z0-9]{5,}`},
+	},
+	{
+		Name: "Ember.js", Categories: []string{CategoryFramework},
+		Globals: map[string]string{"Ember.VERSION": `^([\d.]+)`},
+	},
+	{
+		Name: "Alpine.js", Categories: []string{CategoryFramework},
+		HTML:    []string{`<[^>]+\sx-data[=\s>]`},
+		Globals: map[string]string{"Alpine.version": `^([\d.]+)`},
+	},
+
+	// JavaScript libraries
+	{
+		Name: "jQuery", Categories: []string{CategoryLibrary},
+		ScriptSrc: []string{`jquery[.-](\d+\.\d+\.\d+)(?:\.min)?\.js`, `/jquery(?:\.min)?\.js`},
+		Globals:   map[string]string{"jQuery.fn.jquery": `^([\d.]+)`},
+	},
+	{
+		Name: "Bootstrap", Categories: []string{CategoryLibrary},
+		HTML:      []string{`<link[^>]+bootstrap(?:[.-](\d+\.\d+\.\d+))?(?:\.min)?\.css`},
+		ScriptSrc: []string{`bootstrap(?:[.-](\d+\.\d+\.\d+))?(?:\.bundle)?(?:\.min)?\.js`},
+	},
+
+	// CMS and ecommerce
+	{
+		Name: "WordPress", Categories: []string{CategoryCMS},
+		HTML:      []string{`/wp-(?:content|includes)/`},
+		ScriptSrc: []string{`/wp-(?:content|includes)/`},
+		Meta:      map[string]string{"generator": `^WordPress ?([\d.]+)?`},
+	},
+	{
+		Name: "WooCommerce", Categories: []string{CategoryEcommerce},
+		HTML:    []string{`<[^>]+class="[^"]*\bwoocommerce\b`},
+		Meta:    map[string]string{"generator": `^WooCommerce ?([\d.]+)?`},
+		Implies: []string{"WordPress"},
+	},
+	{
+		Name: "Drupal", Categories: []string{CategoryCMS},
+		Meta:    map[string]string{"generator": `^Drupal ?(\d+)?`},
+		Headers: map[string]string{"x-generator": `^Drupal ?(\d+)?`},
+		Globals: map[string]string{"Drupal": ""},
+	},
+	{
+		Name: "Joomla", Categories: []string{CategoryCMS},
+		Meta: map[string]string{"generator": `Joomla!`},
+	},
+	{
+		Name: "Ghost", Categories: []string{CategoryCMS},
+		Meta: map[string]string{"generator": `^Ghost ?([\d.]+)?`},
+	},
+	{
+		Name: "Hugo", Categories: []string{CategoryCMS},
+		Meta: map[string]string{"generator": `^Hugo ([\d.]+)`},
+	},
+	{
+		Name: "Wix", Categories: []string{CategoryCMS},
+		Meta:    map[string]string{"generator": `Wix\.com`},
+		Headers: map[string]string{"x-wix-request-id": ""},
+	},
+	{
+		Name: "Squarespace", Categories: []string{CategoryCMS},
+		HTML:    []string{`<!-- This is Squarespace\. -->`},
+		Globals: map[string]string{"Squarespace": ""},
+	},
+	{
+		Name: "Shopify", Categories: []string{CategoryEcommerce},
+		ScriptSrc: []string{`cdn\.shopify\.com/`},
+		Headers:   map[string]string{"x-shopid": ""},
+		Globals:   map[string]string{"Shopify.shop": ""},
+	},
+	{
+		Name: "Magento", Categories: []string{CategoryEcommerce},
+		ScriptSrc: []string{`/static/(?:version\d+/)?frontend/`, `/mage/`},
+		Globals:   map[string]string{"Mage": ""},
+	},
+
+	// Analytics
+	{
+		Name: "Google Analytics", Categories: []string{CategoryAnalytics},
+		ScriptSrc: []string{`google-analytics\.com/(?:ga|urchin|analytics)\.js`, `googletagmanager\.com/gtag/js`},
+		Cookies:   []string{`^_ga$`, `^_gid$`, `^_ga_`},
+		Globals:   map[string]string{"gtag": "", "ga": "", "GoogleAnalyticsObject": ""},
+	},
+	{
+		Name: "Matomo", Categories: []string{CategoryAnalytics},
+		ScriptSrc: []string{`/(?:matomo|piwik)\.js`},
+		Cookies:   []string{`^_pk_id`},
+		Globals:   map[string]string{"Matomo": "", "Piwik": ""},
+	},
+	{
+		Name: "Plausible", Categories: []string{CategoryAnalytics},
+		ScriptSrc: []string{`plausible\.io/js/`},
+		Globals:   map[string]string{"plausible": ""},
+	},
+	{
+		Name: "Hotjar", Categories: []string{CategoryAnalytics},
+		ScriptSrc: []string{`static\.hotjar\.com/`},
+		Globals:   map[string]string{"hj": "", "hjSiteSettings": ""},
+	},
+	{
+		Name: "Mixpanel", Categories: []string{CategoryAnalytics},
+		ScriptSrc: []string{`cdn\.mxpnl\.com/`},
+		Globals:   map[string]string{"mixpanel.__loaded": ""},
+	},
+	{
+		Name: "Segment", Categories: []string{CategoryAnalytics},
+		ScriptSrc: []string{`cdn\.segment\.(?:com|io)/analytics\.js`},
+		Globals:   map[string]string{"analytics.VERSION": `^([\d.]+)`},
+	},
+	{
+		Name: "Microsoft Clarity", Categories: []string{CategoryAnalytics},
+		ScriptSrc: []string{`clarity\.ms/tag/`},
+		Globals:   map[string]string{"clarity": ""},
+	},
+	{
+		Name: "Facebook Pixel", Categories: []string{CategoryAnalytics},
+		ScriptSrc: []string{`connect\.facebook\.net/[^/]+/fbevents\.js`},
+		Globals:   map[string]string{"fbq": ""},
+	},
+
+	// Tag managers
+	{
+		Name: "Google Tag Manager", Categories: []string{CategoryTagManager},
+		HTML:      []string{`googletagmanager\.com/ns\.html`},
+		ScriptSrc: []string{`googletagmanager\.com/gtm\.js`},
+		Globals:   map[string]string{"google_tag_manager": ""},
+	},
+	{
+		Name: "Tealium", Categories: []string{CategoryTagManager},
+		ScriptSrc: []string{`tags\.tiqcdn\.com/utag/`},
+		Globals:   map[string]string{"utag": ""},
+	},
+	{
+		Name: "Adobe Experience Platform Launch", Categories: []string{CategoryTagManager},
+		ScriptSrc: []string{`assets\.adobedtm\.com/`},
+		Globals:   map[string]string{"_satellite": ""},
+	},
+
+	// Servers, CDNs and hosting
+	{
+		Name: "Nginx", Categories: []string{CategoryWebServer},
+		Headers: map[string]string{"server": `nginx(?:/([\d.]+))?`},
+	},
+	{
+		Name: "Apache HTTP Server", Categories: []string{CategoryWebServer},
+		Headers: map[string]string{"server": `^Apache(?:/([\d.]+))?`},
+	},
+	{
+		Name: "Cloudflare", Categories: []string{CategoryCDN},
+		Headers: map[string]string{"cf-ray": "", "server": `^cloudflare$`},
+	},
+	{
+		Name: "Amazon CloudFront", Categories: []string{CategoryCDN},
+		Headers: map[string]string{"x-amz-cf-id": "", "via": `CloudFront`},
+	},
+	{
+		Name: "Fastly", Categories: []string{CategoryCDN},
+		Headers: map[string]string{"x-fastly-request-id": "", "x-served-by": `^cache-`},
+	},
+	{
+		Name: "Varnish", Categories: []string{CategoryWebServer},
+		Headers: map[string]string{"via": `varnish`, "x-varnish": ""},
+	},
+	{
+		Name: "Vercel", Categories: []string{CategoryPaaS},
+		Headers: map[string]string{"x-vercel-id": "", "server": `^Vercel$`},
+	},
+	{
+		Name: "Netlify", Categories: []string{CategoryPaaS},
+		Headers: map[string]string{"x-nf-request-id": "", "server": `^Netlify`},
+	},
+	{
+		Name: "PHP", Categories: []string{CategoryLanguage},
+		Headers: map[string]string{"x-powered-by": `^PHP/?([\d.]+)?`},
+		Cookies: []string{`^PHPSESSID$`},
+	},
+	{
+		Name: "Express", Categories: []string{CategoryFramework},
+		Headers: map[string]string{"x-powered-by": `^Express$`},
+	},
+}
+
+// compiled is a Rule with its patterns compiled.
+type compiled struct {
+	rule      *Rule
+	html      []*regexp.Regexp
+	scriptSrc []*regexp.Regexp
+	meta      map[string]*regexp.Regexp
+	headers   map[string]*regexp.Regexp
+	cookies   []*regexp.Regexp
+	globals   map[string]*regexp.Regexp
+}
+
+var compileRules = sync.OnceValue(func() []*compiled {
+	one := func(p string) *regexp.Regexp {
+		if p == "" {
+			return nil
+		}
+		return regexp.MustCompile("(?i)" + p)
+	}
+	list := func(ps []string) []*regexp.Regexp {
+		var res []*regexp.Regexp
+		for _, p := range ps {
+			res = append(res, one(p))
+		}
+		return res
+	}
+	keyed := func(m map[string]string) map[string]*regexp.Regexp {
+		res := make(map[string]*regexp.Regexp, len(m))
+		for k, p := range m {
+			res[k] = one(p)
+		}
+		return res
+	}
+
+	rules := make([]*compiled, 0, len(Rules))
+	for i := range Rules {
+		r := &Rules[i]
+		rules = append(rules, &compiled{
+			rule:      r,
+			html:      list(r.HTML),
+			scriptSrc: list(r.ScriptSrc),
+			meta:      keyed(r.Meta),
+			headers:   keyed(r.Headers),
+			cookies:   list(r.Cookies),
+			globals:   keyed(r.Globals),
+		})
+	}
+	return rules
+})
+
+// Globals returns the JavaScript global paths the rules inspect, for
+// filling Signals.Globals.
+func Globals() []string {
+	var paths []string
+	seen := make(map[string]bool)
+	for _, r := range Rules {
+		for path := range r.Globals {
+			if !seen[path] {
+				seen[path] = true
+				paths = append(paths, path)
+			}
+		}
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// Detect returns the technologies the rules find in s, sorted by name.
+func Detect(s *Signals) []Technology {
+	headers := make(map[string]string, len(s.Headers))
+	for name, value := range s.Headers {
+		headers[strings.ToLower(name)] = value
+	}
+
+	found := make(map[string]*Technology)
+	for _, c := range compileRules() {
+		version, ok := c.match(s, headers)
+		if !ok {
+			continue
+		}
+		found[c.rule.Name] = &Technology{Name: c.rule.Name, Categories: c.rule.Categories, Version: version}
+	}
+
+	// Implied technologies are added without a version
+	for _, c := range compileRules() {
+		if found[c.rule.Name] == nil {
+			continue
+		}
+		for _, name := range c.rule.Implies {
+			if found[name] != nil {
+				continue
+			}
+			for _, other := range Rules {
+				if other.Name == name {
+					found[name] = &Technology{Name: name, Categories: other.Categories}
+				}
+			}
+		}
+	}
+
+	techs := make([]Technology, 0, len(found))
+	for _, t := range found {
+		techs = append(techs, *t)
+	}
+	sort.Slice(techs, func(i, j int) bool { return techs[i].Name < techs[j].Name })
+	return techs
+}
+
+// match reports whether any pattern of c matches s, and the first version
+// a matching pattern captured.
+func (c *compiled) match(s *Signals, headers map[string]string) (version string, ok bool) {
+	try := func(re *regexp.Regexp, value string) {
+		if re == nil {
+			ok = true
+			return
+		}
+		m := re.FindStringSubmatch(value)
+		if m == nil {
+			return
+		}
+		ok = true
+		if version == "" && len(m) > 1 {
+			version = m[1]
+		}
+	}
+	keyed := func(patterns map[string]*regexp.Regexp, values map[string]string) {
+		for key, re := range patterns {
+			if value, present := values[key]; present {
+				try(re, value)
+			}
+		}
+	}
+
+	for _, re := range c.html {
+		try(re, s.HTML)
+	}
+	for _, re := range c.scriptSrc {
+		for _, src := range s.ScriptSrcs {
+			try(re, src)
+		}
+	}
+	for _, re := range c.cookies {
+		for _, name := range s.Cookies {
+			try(re, name)
+		}
+	}
+	keyed(c.meta, s.Meta)
+	keyed(c.headers, headers)
+	keyed(c.globals, s.Globals)
+	return version, ok
+}
@@ -0,0 +1,368 @@
+// Package textdiff compares texts line by line and writes the differences
+// as a unified diff for reading in a terminal: colored, with context lines
+// around each change, and optionally down to the words that changed.
+package textdiff
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+)
+
+// Op is the kind of an Edit.
+type Op int
+
+const (
+	Equal Op = iota
+	Delete
+	Insert
+)
+
+// Edit is a line, or a word, kept, deleted from the first text or
+// inserted from the second.
+type Edit struct {
+	Op   Op
+	Text string
+}
+
+// maxEditDistance bounds the search for the shortest edit script, whose
+// memory grows with its square. Texts further apart are shown as one
+// replaced block.
+const maxEditDistance = 2000
+
+// Diff returns a shortest list of edits turning a into b.
+func Diff(a, b []string) []Edit {
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+	edits := make([]Edit, 0, len(a)+len(b))
+	for _, s := range a[:prefix] {
+		edits = append(edits, Edit{Equal, s})
+	}
+	edits = append(edits, myers(a[prefix:len(a)-suffix], b[prefix:len(b)-suffix])...)
+	for _, s := range a[len(a)-suffix:] {
+		edits = append(edits, Edit{Equal, s})
+	}
+	return edits
+}
+
+// myers finds the shortest edit script of a and b with Myers' O(ND)
+// algorithm, keeping the furthest reaching paths of each step to walk
+// back along.
+func myers(a, b []string) []Edit {
+	n, m := len(a), len(b)
+	limit := n + m
+	if n == 0 || m == 0 || limit == 0 {
+		return replaced(a, b)
+	}
+	v := make([]int, 2*limit+1)
+	var trace [][]int
+	for d := 0; d <= limit; d++ {
+		if d > maxEditDistance {
+			return replaced(a, b)
+		}
+		trace = append(trace, append([]int(nil), v[limit-d:limit+d+1]...))
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[limit+k-1] < v[limit+k+1]) {
+				x = v[limit+k+1]
+			} else {
+				x = v[limit+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[limit+k] = x
+			if x >= n && y >= m {
+				return backtrack(trace, a, b)
+			}
+		}
+	}
+	return replaced(a, b)
+}
+
+// backtrack walks the furthest reaching paths of myers back from the end
+// of both texts, trace[d] holding those before step d, for k from -d to d.
+func backtrack(trace [][]int, a, b []string) []Edit {
+	x, y := len(a), len(b)
+	var reversed []Edit
+	for d := len(trace) - 1; d > 0; d-- {
+		v := trace[d]
+		k := x - y
+		prevK := k - 1
+		if k == -d || (k != d && v[k-1+d] < v[k+1+d]) {
+			prevK = k + 1
+		}
+		prevX := v[prevK+d]
+		prevY := prevX - prevK
+		for x > prevX && y > prevY {
+			reversed = append(reversed, Edit{Equal, a[x-1]})
+			x--
+			y--
+		}
+		if x == prevX {
+			reversed = append(reversed, Edit{Insert, b[y-1]})
+			y--
+		} else {
+			reversed = append(reversed, Edit{Delete, a[x-1]})
+			x--
+		}
+	}
+	for x > 0 && y > 0 {
+		reversed = append(reversed, Edit{Equal, a[x-1]})
+		x--
+		y--
+	}
+	edits := make([]Edit, len(reversed))
+	for i, e := range reversed {
+		edits[len(reversed)-1-i] = e
+	}
+	return edits
+}
+
+// replaced returns the edits deleting all of a and inserting all of b.
+func replaced(a, b []string) []Edit {
+	edits := make([]Edit, 0, len(a)+len(b))
+	for _, s := range a {
+		edits = append(edits, Edit{Delete, s})
+	}
+	for _, s := range b {
+		edits = append(edits, Edit{Insert, s})
+	}
+	return edits
+}
+
+// Stat counts the deleted and inserted lines of edits.
+func Stat(edits []Edit) (deleted, inserted int) {
+	for _, e := range edits {
+		switch e.Op {
+		case Delete:
+			deleted++
+		case Insert:
+			inserted++
+		}
+	}
+	return deleted, inserted
+}
+
+// Hunk is a run of changes with the lines of context around them.
+// Starts are 1-based line numbers.
+type Hunk struct {
+	AStart, ALines int
+	BStart, BLines int
+	Edits          []Edit
+}
+
+// Hunks groups edits into hunks with up to context unchanged lines
+// before and after each change, merging changes closer than twice that.
+func Hunks(edits []Edit, context int) []Hunk {
+	context = max(context, 0)
+	var hunks []Hunk
+	for i := 0; i < len(edits); {
+		if edits[i].Op == Equal {
+			i++
+			continue
+		}
+		start := max(i-context, 0)
+		// Extend over changes separated by at most 2*context equal lines
+		end := i
+		for end < len(edits) {
+			if edits[end].Op != Equal {
+				end++
+				continue
+			}
+			run := end
+			for run < len(edits) && edits[run].Op == Equal {
+				run++
+			}
+			if run == len(edits) || run-end > 2*context {
+				end = min(end+context, len(edits))
+				break
+			}
+			end = run
+		}
+		hunks = append(hunks, newHunk(edits, start, end))
+		i = end
+	}
+	return hunks
+}
+
+// newHunk returns the hunk of edits[start:end], numbering its lines by
+// the edits before it.
+func newHunk(edits []Edit, start, end int) Hunk {
+	a, b := 1, 1
+	for _, e := range edits[:start] {
+		if e.Op != Insert {
+			a++
+		}
+		if e.Op != Delete {
+			b++
+		}
+	}
+	h := Hunk{AStart: a, BStart: b, Edits: edits[start:end]}
+	for _, e := range h.Edits {
+		if e.Op != Insert {
+			h.ALines++
+		}
+		if e.Op != Delete {
+			h.BLines++
+		}
+	}
+	// An empty side starts at the line before it, as in diff -u
+	if h.ALines == 0 {
+		h.AStart--
+	}
+	if h.BLines == 0 {
+		h.BStart--
+	}
+	return h
+}
+
+// Options control how Write renders a diff.
+type Options struct {
+	// Context is the number of unchanged lines shown around changes.
+	Context int
+	// Words shows changed lines once, with the words deleted and
+	// inserted marked within them, rather than as removed and added lines;
+	// lines are then not prefixed, as in git's word diff.
+	Words bool
+	// Color uses ANSI colors: red for deletions, green for insertions.
+	// Without it, word changes are marked [-deleted-] and {+inserted+}.
+	Color bool
+}
+
+// ANSI escape sequences of Write.
+const (
+	bold  = "\x1b[1m"
+	red   = "\x1b[31m"
+	green = "\x1b[32m"
+	cyan  = "\x1b[36m"
+	reset = "\x1b[0m"
+)
+
+// Write writes the differences of a and b, named nameA and nameB, to w as
+// a unified diff, and reports whether they differ. Nothing is written for
+// equal texts.
+func Write(w io.Writer, nameA, nameB string, a, b []string, opts Options) (bool, error) {
+	hunks := Hunks(Diff(a, b), opts.Context)
+	if len(hunks) == 0 {
+		return false, nil
+	}
+	p := &printer{w: w, opts: opts}
+	p.line(bold, "--- "+nameA)
+	p.line(bold, "+++ "+nameB)
+	for _, h := range hunks {
+		p.line(cyan, fmt.Sprintf("@@ -%s +%s @@", span(h.AStart, h.ALines), span(h.BStart, h.BLines)))
+		edits := h.Edits
+		for i := 0; i < len(edits); {
+			if edits[i].Op == Equal {
+				if opts.Words {
+					p.line("", edits[i].Text)
+				} else {
+					p.line("", " "+edits[i].Text)
+				}
+				i++
+				continue
+			}
+			// A block of changed lines
+			j := i
+			var deleted, inserted []string
+			for ; j < len(edits) && edits[j].Op != Equal; j++ {
+				if edits[j].Op == Delete {
+					deleted = append(deleted, edits[j].Text)
+				} else {
+					inserted = append(inserted, edits[j].Text)
+				}
+			}
+			if opts.Words {
+				p.words(deleted, inserted)
+			} else {
+				for _, s := range deleted {
+					p.line(red, "-"+s)
+				}
+				for _, s := range inserted {
+					p.line(green, "+"+s)
+				}
+			}
+			i = j
+		}
+	}
+	return true, p.err
+}
+
+// span renders the start and length of a hunk side as diff -u does.
+func span(start, lines int) string {
+	if lines == 1 {
+		return fmt.Sprint(start)
+	}
+	return fmt.Sprintf("%d,%d", start, lines)
+}
+
+// printer writes the lines of a diff, keeping the first error.
+type printer struct {
+	w    io.Writer
+	opts Options
+	err  error
+}
+
+func (p *printer) line(color, text string) {
+	if p.opts.Color && color != "" {
+		text = color + text + reset
+	}
+	p.write(text + "\n")
+}
+
+func (p *printer) write(s string) {
+	if p.err == nil {
+		_, p.err = io.WriteString(p.w, s)
+	}
+}
+
+// tokens splits text into words, runs of whitespace, single other
+// characters and line breaks, so word diffs keep punctuation apart.
+var tokens = regexp.MustCompile(`\n|[\p{L}\p{N}_]+|[^\S\n]+|[^\p{L}\p{N}_\s]`)
+
+// words writes a block of deleted and inserted lines once, the words
+// that changed marked within them.
+func (p *printer) words(deleted, inserted []string) {
+	a := tokens.FindAllString(strings.Join(deleted, "\n"), -1)
+	b := tokens.FindAllString(strings.Join(inserted, "\n"), -1)
+	var line strings.Builder
+	flush := func() {
+		p.write(line.String() + "\n")
+		line.Reset()
+	}
+	for _, e := range Diff(a, b) {
+		if e.Text == "\n" {
+			// Lines break where either text does
+			flush()
+			continue
+		}
+		switch e.Op {
+		case Equal:
+			line.WriteString(e.Text)
+		case Delete:
+			line.WriteString(p.mark(e.Text, red, "[-", "-]"))
+		case Insert:
+			line.WriteString(p.mark(e.Text, green, "{+", "+}"))
+		}
+	}
+	flush()
+}
+
+// mark marks a deleted or inserted word with color, or with the
+// markers of git's plain word diff.
+func (p *printer) mark(text, color, open, close string) string {
+	if p.opts.Color {
+		return color + text + reset
+	}
+	return open + text + close
+}
@@ -0,0 +1,52 @@
+package textnorm
+
+import (
+	"encoding/binary"
+	"fmt"
+	"strings"
+	"unicode/utf16"
+)
+
+// Encodings lists the encodings accepted by Encode. utf-8-bom and utf-16le
+// start with a byte order mark, which Windows tools use to detect them.
+var Encodings = []string{"utf-8", "utf-8-bom", "utf-16le"}
+
+// EOLs lists the line endings accepted by Encode.
+var EOLs = []string{"lf", "crlf"}
+
+// Charset returns the charset parameter of a content type for encoding.
+func Charset(encoding string) string {
+	if encoding == "utf-16le" {
+		return "utf-16le"
+	}
+	return "utf-8"
+}
+
+// Encode converts text to the line ending eol and encodes it.
+func Encode(text, encoding, eol string) ([]byte, error) {
+	switch eol {
+	case "lf":
+		text = strings.ReplaceAll(text, "\r\n", "\n")
+	case "crlf":
+		text = strings.ReplaceAll(strings.ReplaceAll(text, "\r\n", "\n"), "\n", "\r\n")
+	default:
+		return nil, fmt.Errorf("unsupported line ending %q (expected one of %s)", eol, strings.Join(EOLs, ", "))
+	}
+
+	switch encoding {
+	case "utf-8":
+		return []byte(text), nil
+	case "utf-8-bom":
+		return append([]byte{0xEF, 0xBB, 0xBF}, text...), nil
+	case "utf-16le":
+		units := utf16.Encode([]rune(text))
+		data := make([]byte, 2, 2+2*len(units))
+		binary.LittleEndian.PutUint16(data, 0xFEFF)
+		for _, u := range units {
+			data = binary.LittleEndian.AppendUint16(data, u)
+		}
+		return data, nil
+	default:
+		return nil, fmt.Errorf("unsupported text encoding %q (expected one of %s)", encoding, strings.Join(Encodings, ", "))
+	}
+}
@@ -0,0 +1,128 @@
+// Package textnorm post-processes extracted text so it diffs and embeds
+// consistently regardless of how the page encoded it.
+package textnorm
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// Forms lists the Unicode normalization forms accepted by ValidateForm.
+var Forms = []string{"nfc", "nfd", "nfkc", "nfkd"}
+
+// forms maps the spellings ValidateForm returns to their forms.
+var forms = map[string]norm.Form{"NFC": norm.NFC, "NFD": norm.NFD, "NFKC": norm.NFKC, "NFKD": norm.NFKD}
+
+// ValidateForm checks that form is one of Forms (case-insensitive) and
+// returns it in upper case, as Normalize takes it.
+func ValidateForm(form string) (string, error) {
+	for _, f := range Forms {
+		if strings.EqualFold(form, f) {
+			return strings.ToUpper(f), nil
+		}
+	}
+	return "", fmt.Errorf("unsupported normalization form %q (expected one of %s)", form, strings.Join(Forms, ", "))
+}
+
+// Normalize returns text in the normalization form returned by
+// ValidateForm.
+func Normalize(text, form string) (string, error) {
+	f, ok := forms[form]
+	if !ok {
+		return "", fmt.Errorf("unsupported normalization form %q (expected one of %s)", form, strings.Join(Forms, ", "))
+	}
+	return f.String(text), nil
+}
+
+// Characters composing emoji with those before or after them.
+const (
+	zwj    = 0x200D
+	vs15   = 0xFE0E
+	vs16   = 0xFE0F
+	keycap = 0x20E3
+)
+
+// StripEmoji removes emoji: pictographs shown as emoji, by default or
+// through U+FE0F, flags, keycaps and the joiners, variation selectors,
+// skin tones and tags composing them. Pictographs shown as text, such as
+// a plain (c) sign, and joiners outside of emoji, as in Indic scripts, are
+// kept.
+func StripEmoji(text string) string {
+	runes := []rune(text)
+	var b strings.Builder
+	b.Grow(len(text))
+	in := false
+	for i, r := range runes {
+		rest := runes[i+1:]
+		switch {
+		case in && (emojiPart(r) || runes[i-1] == zwj && unicode.Is(extendedPictographic, r)):
+		case emojiBase(r, rest):
+			in = true
+		default:
+			in = false
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// emojiBase reports whether r, followed by rest, starts an emoji.
+func emojiBase(r rune, rest []rune) bool {
+	var next rune
+	if len(rest) > 0 {
+		next = rest[0]
+	}
+	switch {
+	case unicode.Is(emojiPresentation, r):
+		return next != vs15
+	case unicode.Is(extendedPictographic, r):
+		return next == vs16 || isSkinTone(next)
+	case r == '#' || r == '*' || r >= '0' && r <= '9':
+		return next == keycap || next == vs16 && len(rest) > 1 && rest[1] == keycap
+	}
+	return false
+}
+
+// emojiPart reports whether r continues the emoji before it.
+func emojiPart(r rune) bool {
+	return r == zwj || r == vs15 || r == vs16 || r == keycap || isSkinTone(r) ||
+		r >= 0xE0020 && r <= 0xE007F // tags of subdivision flags
+}
+
+func isSkinTone(r rune) bool {
+	return r >= 0x1F3FB && r <= 0x1F3FF
+}
+
+// isZeroWidth reports characters that render as nothing but break string
+// comparison: zero-width spaces and joiners, word joiner and BOM.
+func isZeroWidth(r rune) bool {
+	switch r {
+	case 0x200B, 0x200C, 0x200D, 0x2060, 0xFEFF, 0x00AD:
+		return true
+	}
+	return false
+}
+
+// CollapseWhitespace removes zero-width characters, turns every run of
+// whitespace within a line into a single space, trims each line and drops
+// blank lines, preserving line structure otherwise.
+func CollapseWhitespace(text string) string {
+	text = strings.Map(func(r rune) rune {
+		if isZeroWidth(r) {
+			return -1
+		}
+		return r
+	}, text)
+
+	var lines []string
+	for _, line := range strings.FieldsFunc(text, func(r rune) bool { return r == '\n' || r == '\r' }) {
+		line = strings.Join(strings.FieldsFunc(line, unicode.IsSpace), " ")
+		if line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
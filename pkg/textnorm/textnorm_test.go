@@ -0,0 +1,143 @@
+package textnorm
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestStripEmoji(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want string
+	}{
+		{"plain text", "Hello, world", "Hello, world"},
+		{"emoji presentation", "Launch 🚀 now", "Launch  now"},
+		{"emoticon", "ok 😀", "ok "},
+		{"skin tone", "wave 👋🏽!", "wave !"},
+		{"zwj family", "a👨\u200d👩\u200d👧\u200d👦b", "ab"},
+		{"zwj with text-default heart", "👩\u200d❤\u200d👨 love", " love"},
+		{"flag", "🇩🇪 Berlin", " Berlin"},
+		{"subdivision flag", "🏴\U000e0067\U000e0062\U000e0073\U000e0063\U000e0074\U000e007f Scotland", " Scotland"},
+		{"keycap", "press 1\ufe0f\u20e3 or #\u20e3", "press  or "},
+		{"text-default with vs16", "I ❤\ufe0f Go", "I  Go"},
+		{"text-default alone", "I ❤ Go", "I ❤ Go"},
+		{"copyright sign kept", "© 2024 ACME™", "© 2024 ACME™"},
+		{"copyright as emoji", "©\ufe0f 2024", " 2024"},
+		{"emoji presentation as text", "⌚\ufe0e time", "⌚\ufe0e time"},
+		{"misc symbols not emoji", "☊ ☋ ♩ ♪", "☊ ☋ ♩ ♪"},
+		{"arrows kept", "← → ↑ ↓", "← → ↑ ↓"},
+		{"star emoji", "rated ⭐ 5", "rated  5"},
+		{"digits kept", "call 1 800 #2", "call 1 800 #2"},
+		{"indic joiner kept", "क्\u200dष", "क्\u200dष"},
+		{"cjk kept", "日本語テキスト", "日本語テキスト"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := StripEmoji(tt.text); got != tt.want {
+				t.Errorf("StripEmoji(%q) = %q, want %q", tt.text, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		form string
+		text string
+		want string
+	}{
+		{"nfc", "e\u0301", "é"},
+		{"NFD", "é", "e\u0301"},
+		{"nfkc", "ｆｕｌｌ１２３ ﬁ", "full123 fi"},
+		{"nfkd", "ñ²", "n\u03032"},
+		{"nfc", "plain", "plain"},
+	}
+	for _, tt := range tests {
+		form, err := ValidateForm(tt.form)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := Normalize(tt.text, form)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tt.want {
+			t.Errorf("Normalize(%q, %s) = %q, want %q", tt.text, form, got, tt.want)
+		}
+	}
+	if _, err := ValidateForm("nfx"); err == nil {
+		t.Error("ValidateForm accepted nfx")
+	}
+	if _, err := Normalize("x", "nfc"); err == nil {
+		t.Error("Normalize accepted a form not returned by ValidateForm")
+	}
+}
+
+func TestCollapseWhitespace(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want string
+	}{
+		{"empty", "", ""},
+		{"plain", "one two", "one two"},
+		{"runs of spaces", "  one \t  two\u00a0\u2003three  ", "one two three"},
+		{"lines kept", "one\ntwo\r\nthree\rfour", "one\ntwo\nthree\nfour"},
+		{"blank lines dropped", "\n\none\n   \n\t\ntwo\n\n", "one\ntwo"},
+		{"zero-width removed", "zero\u200bwidth\ufeff \u200d\n\u200b\nend", "zerowidth\nend"},
+		{"whitespace only", " \t\r\n ", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := CollapseWhitespace(tt.text); got != tt.want {
+				t.Errorf("CollapseWhitespace(%q) = %q, want %q", tt.text, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEncode(t *testing.T) {
+	tests := []struct {
+		text     string
+		encoding string
+		eol      string
+		want     []byte
+	}{
+		{"a\nb\r\nc", "utf-8", "lf", []byte("a\nb\nc")},
+		{"a\nb\r\nc\n", "utf-8", "crlf", []byte("a\r\nb\r\nc\r\n")},
+		{"é", "utf-8", "lf", []byte{0xC3, 0xA9}},
+		{"a\n", "utf-8-bom", "crlf", []byte{0xEF, 0xBB, 0xBF, 'a', '\r', '\n'}},
+		{"", "utf-8-bom", "lf", []byte{0xEF, 0xBB, 0xBF}},
+		{"a\né", "utf-16le", "crlf", []byte{0xFF, 0xFE, 'a', 0, '\r', 0, '\n', 0, 0xE9, 0}},
+		// Characters outside the BMP are written as surrogate pairs
+		{"😀", "utf-16le", "lf", []byte{0xFF, 0xFE, 0x3D, 0xD8, 0x00, 0xDE}},
+		{"", "utf-16le", "lf", []byte{0xFF, 0xFE}},
+	}
+	for _, tt := range tests {
+		got, err := Encode(tt.text, tt.encoding, tt.eol)
+		if err != nil {
+			t.Errorf("Encode(%q, %s, %s) error = %v", tt.text, tt.encoding, tt.eol, err)
+			continue
+		}
+		if !bytes.Equal(got, tt.want) {
+			t.Errorf("Encode(%q, %s, %s) = % x, want % x", tt.text, tt.encoding, tt.eol, got, tt.want)
+		}
+	}
+
+	if _, err := Encode("x", "latin-1", "lf"); err == nil {
+		t.Error("Encode accepted latin-1")
+	}
+	if _, err := Encode("x", "utf-8", "cr"); err == nil {
+		t.Error("Encode accepted the line ending cr")
+	}
+	for _, encoding := range Encodings {
+		want := "utf-8"
+		if encoding == "utf-16le" {
+			want = "utf-16le"
+		}
+		if got := Charset(encoding); got != want {
+			t.Errorf("Charset(%s) = %s, want %s", encoding, got, want)
+		}
+	}
+}
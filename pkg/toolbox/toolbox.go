@@ -0,0 +1,211 @@
+// Package toolbox captures screenshots, PDFs and text of web pages in
+// memory, for Go programs that would otherwise run the that-cli-web-toolbox
+// binary and read its output files.
+//
+// Every call loads the page in a browser of its own, started for the call
+// and closed when it returns, unless Options.RemoteDebuggingPort names a
+// running Chrome (or the address a daemon hands out). Bound calls with the
+// context: it covers starting Chrome, loading the page and capturing it.
+//
+//	png, meta, err := toolbox.Screenshot(ctx, "https://example.com", &toolbox.Options{FullPage: true})
+//
+// For several operations on one page, or pages in parallel, use package
+// chromedphelper, which these functions are built on.
+package toolbox
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	chromedphelper "github.com/pesarkhobeee/that-cli-web-toolbox/pkg/chromedp"
+)
+
+// Options configure how the page is loaded and captured. The zero value
+// (or nil) loads the page with a default viewport and captures it as
+// PNG.
+type Options struct {
+	// Delay is how many seconds to wait after the page loads.
+	Delay int
+	// RemoteDebuggingPort, e.g. "localhost:9222", uses that Chrome
+	// instead of starting one.
+	RemoteDebuggingPort string
+	// JS is run after the delay, like --js.
+	JS string
+	// Steps are interaction steps run after JS, in the syntax of --step,
+	// e.g. "click:#accept".
+	Steps []string
+	// Headers are sent with every request of the page.
+	Headers map[string]string
+	// Cookies are set before navigation.
+	Cookies []chromedphelper.Cookie
+	// BasicAuth, if set, answers HTTP authentication challenges.
+	BasicAuth *chromedphelper.Credentials
+	// Emulation, if set, sets the viewport, device or dark mode.
+	Emulation *chromedphelper.Emulation
+	// Locale, e.g. "de", is sent as Accept-Language and used for the
+	// page's Intl formatting.
+	Locale string
+
+	// Selector restricts Screenshot to the first matching element and
+	// ExtractText to the matching elements.
+	Selector string
+	// FullPage makes Screenshot capture the whole page rather than the
+	// viewport. It is ignored with Selector.
+	FullPage bool
+	// Format is the image format of Screenshot, PNG when empty.
+	Format chromedphelper.ImageFormat
+	// Quality is the compression quality from 1 to 100 for JPEG and WebP.
+	Quality int
+	// OmitBackground, BeyondViewport and FromSurface control how Screenshot
+	// captures the page, see chromedphelper.ScreenshotOptions.
+	OmitBackground bool
+	BeyondViewport *bool
+	FromSurface    *bool
+}
+
+// Meta describes the page a capture was taken of.
+type Meta struct {
+	// URL is the requested URL.
+	URL string
+	// Page holds the final URL, title, canonical URL, description and
+	// language of the document.
+	Page *chromedphelper.PageMetadata
+	// Redirects are the hops from URL to the final page.
+	Redirects []chromedphelper.RedirectHop
+	// ContentType is the MIME type of the returned data.
+	ContentType string
+}
+
+// Screenshot returns a screenshot of the page at url: of its viewport, the
+// whole page with opts.FullPage, or the first element matching
+// opts.Selector.
+func Screenshot(ctx context.Context, url string, opts *Options) ([]byte, Meta, error) {
+	if opts == nil {
+		opts = &Options{}
+	}
+	shot := chromedphelper.ScreenshotOptions{
+		Format:         opts.Format,
+		Quality:        opts.Quality,
+		OmitBackground: opts.OmitBackground,
+		BeyondViewport: opts.BeyondViewport,
+		FromSurface:    opts.FromSurface,
+	}
+	if shot.Format == "" {
+		shot.Format = chromedphelper.PNG
+	}
+	if opts.Quality < 0 || opts.Quality > 100 {
+		return nil, Meta{}, fmt.Errorf("quality must be between 1 and 100")
+	}
+
+	var data []byte
+	meta, err := capture(ctx, url, opts, func(b *chromedphelper.Browser) error {
+		var err error
+		switch {
+		case opts.Selector != "":
+			data, err = b.ScreenshotElementAs(ctx, opts.Selector, shot)
+		case opts.FullPage:
+			data, err = b.ScreenshotFullPage(ctx, shot)
+		default:
+			data, err = b.ScreenshotViewport(ctx, shot)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to take screenshot: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, Meta{}, err
+	}
+	meta.ContentType = shot.Format.ContentType()
+	return data, meta, nil
+}
+
+// PDF returns the page at url printed to PDF.
+func PDF(ctx context.Context, url string, opts *Options) ([]byte, Meta, error) {
+	var data []byte
+	meta, err := capture(ctx, url, opts, func(b *chromedphelper.Browser) error {
+		var err error
+		if data, err = b.PrintToPDF(ctx); err != nil {
+			return fmt.Errorf("failed to print PDF: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, Meta{}, err
+	}
+	meta.ContentType = "application/pdf"
+	return data, meta, nil
+}
+
+// ExtractText returns the visible text of the page at url, or with
+// opts.Selector the text of every matching element, one per line, as
+// --gettextbycssselector prints it.
+func ExtractText(ctx context.Context, url string, opts *Options) (string, Meta, error) {
+	var text string
+	meta, err := capture(ctx, url, opts, func(b *chromedphelper.Browser) error {
+		if opts == nil || opts.Selector == "" {
+			var err error
+			if text, err = b.GetBodyText(ctx); err != nil {
+				return fmt.Errorf("failed to get body text: %w", err)
+			}
+			return nil
+		}
+		texts, err := b.GetTextsBySelector(ctx, opts.Selector)
+		if err != nil {
+			return fmt.Errorf("failed to get text for selector %q: %w", opts.Selector, err)
+		}
+		text = strings.Join(texts, "\n")
+		return nil
+	})
+	if err != nil {
+		return "", Meta{}, err
+	}
+	meta.ContentType = "text/plain; charset=utf-8"
+	return text, meta, nil
+}
+
+// capture loads url in a new browser configured by opts, runs fn on it and
+// describes the page.
+func capture(ctx context.Context, url string, opts *Options, fn func(b *chromedphelper.Browser) error) (Meta, error) {
+	if opts == nil {
+		opts = &Options{}
+	}
+	steps := make([]chromedphelper.Step, 0, len(opts.Steps))
+	for _, spec := range opts.Steps {
+		step, err := chromedphelper.ParseStep(spec)
+		if err != nil {
+			return Meta{}, err
+		}
+		steps = append(steps, step)
+	}
+	if opts.Emulation != nil {
+		if err := opts.Emulation.Validate(); err != nil {
+			return Meta{}, err
+		}
+	}
+
+	b, err := chromedphelper.InitializeChromedpContext(ctx, url, 0, opts.Delay, opts.RemoteDebuggingPort, opts.JS)
+	if err != nil {
+		return Meta{}, fmt.Errorf("failed to initialize browser: %w", err)
+	}
+	defer b.Cancel()
+	b.Steps = steps
+	b.Headers = opts.Headers
+	b.Cookies = opts.Cookies
+	b.BasicAuth = opts.BasicAuth
+	b.Emulation = opts.Emulation
+	b.Locale = opts.Locale
+
+	if err := b.NavigateAndPrepare(ctx); err != nil {
+		return Meta{}, fmt.Errorf("failed to load page: %w", err)
+	}
+	if err := fn(b); err != nil {
+		return Meta{}, err
+	}
+	page, err := b.GetPageMetadata(ctx)
+	if err != nil {
+		return Meta{}, fmt.Errorf("failed to get page metadata: %w", err)
+	}
+	return Meta{URL: url, Page: page, Redirects: b.Redirects()}, nil
+}
@@ -0,0 +1,229 @@
+// Package tor talks to a local Tor daemon's control port, so captures
+// routed through Tor can switch to new circuits (and exit addresses).
+package tor
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// Controller is an authenticated control port connection. It is safe for
+// concurrent use.
+type Controller struct {
+	mu   sync.Mutex
+	conn *textproto.Conn
+}
+
+// Dial connects to the control port at addr and authenticates with
+// password, or, if it is empty, with the method the daemon offers: none,
+// or the cookie file (CookieAuthentication 1) through SAFECOOKIE.
+func Dial(ctx context.Context, addr, password string) (*Controller, error) {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to Tor control port %s: %w", addr, err)
+	}
+	c := &Controller{conn: textproto.NewConn(conn)}
+	if err := c.authenticate(password); err != nil {
+		_ = c.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+// authenticate sends AUTHENTICATE using password or the cookie file.
+func (c *Controller) authenticate(password string) error {
+	if password != "" {
+		if _, err := c.cmd("AUTHENTICATE %s", quote(password)); err != nil {
+			return fmt.Errorf("failed to authenticate to Tor: %w", err)
+		}
+		return nil
+	}
+
+	info, err := c.cmd("PROTOCOLINFO 1")
+	if err != nil {
+		return fmt.Errorf("failed to query Tor protocol info: %w", err)
+	}
+	methods, cookieFile := parseAuthInfo(info)
+	switch {
+	case methods["NULL"]:
+		_, err = c.cmd("AUTHENTICATE")
+	case methods["SAFECOOKIE"] && cookieFile != "":
+		return c.safeCookie(cookieFile)
+	default:
+		return fmt.Errorf("tor control port requires a password")
+	}
+	if err != nil {
+		return fmt.Errorf("failed to authenticate to Tor: %w", err)
+	}
+	return nil
+}
+
+// cookieSize is the size of Tor's authentication cookie.
+const cookieSize = 32
+
+// Keys of the HMACs proving knowledge of the cookie in SAFECOOKIE.
+const (
+	serverHashKey = "Tor safe cookie authentication server-to-controller hash"
+	clientHashKey = "Tor safe cookie authentication controller-to-server hash"
+)
+
+// safeCookie authenticates with the cookie in cookieFile without revealing
+// it: the daemon proves it knows the cookie before the controller answers
+// with a hash of its own, so a control port naming a file it could not read
+// learns nothing of it.
+func (c *Controller) safeCookie(cookieFile string) error {
+	cookie, err := readCookie(cookieFile)
+	if err != nil {
+		return err
+	}
+	clientNonce := make([]byte, 32)
+	if _, err := rand.Read(clientNonce); err != nil {
+		return fmt.Errorf("failed to create Tor auth nonce: %w", err)
+	}
+	reply, err := c.cmd("AUTHCHALLENGE SAFECOOKIE %s", hex.EncodeToString(clientNonce))
+	if err != nil {
+		return fmt.Errorf("failed to authenticate to Tor: %w", err)
+	}
+	serverHash, serverNonce, err := parseChallenge(reply)
+	if err != nil {
+		return err
+	}
+	message := slices.Concat(cookie, clientNonce, serverNonce)
+	if !hmac.Equal(serverHash, cookieHMAC(serverHashKey, message)) {
+		return fmt.Errorf("tor control port does not know the auth cookie in %s", cookieFile)
+	}
+	if _, err := c.cmd("AUTHENTICATE %s", hex.EncodeToString(cookieHMAC(clientHashKey, message))); err != nil {
+		return fmt.Errorf("failed to authenticate to Tor: %w", err)
+	}
+	return nil
+}
+
+// readCookie reads the auth cookie from file, which must be a regular file
+// named by an absolute path and of the cookie's size, so that a control
+// port cannot make the controller read devices, pipes or other large
+// files.
+func readCookie(file string) ([]byte, error) {
+	if !filepath.IsAbs(file) {
+		return nil, fmt.Errorf("tor auth cookie path %q is not absolute", file)
+	}
+	info, err := os.Lstat(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read Tor auth cookie: %w", err)
+	}
+	if !info.Mode().IsRegular() || info.Size() != cookieSize {
+		return nil, fmt.Errorf("tor auth cookie %s is not a regular file of %d bytes", file, cookieSize)
+	}
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read Tor auth cookie: %w", err)
+	}
+	defer f.Close()
+	cookie := make([]byte, cookieSize)
+	if _, err := io.ReadFull(f, cookie); err != nil {
+		return nil, fmt.Errorf("failed to read Tor auth cookie: %w", err)
+	}
+	return cookie, nil
+}
+
+// parseChallenge extracts the server's hash and nonce from an AUTHCHALLENGE
+// reply.
+func parseChallenge(reply string) (serverHash, serverNonce []byte, err error) {
+	rest, ok := strings.CutPrefix(reply, "AUTHCHALLENGE ")
+	if !ok {
+		return nil, nil, fmt.Errorf("invalid Tor auth challenge %q", reply)
+	}
+	for _, field := range strings.Fields(rest) {
+		key, value, _ := strings.Cut(field, "=")
+		switch key {
+		case "SERVERHASH":
+			serverHash, err = hex.DecodeString(value)
+		case "SERVERNONCE":
+			serverNonce, err = hex.DecodeString(value)
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid Tor auth challenge %q: %w", reply, err)
+		}
+	}
+	if len(serverHash) != sha256.Size || len(serverNonce) != 32 {
+		return nil, nil, fmt.Errorf("invalid Tor auth challenge %q", reply)
+	}
+	return serverHash, serverNonce, nil
+}
+
+// cookieHMAC returns the HMAC-SHA256 of message with key.
+func cookieHMAC(key string, message []byte) []byte {
+	mac := hmac.New(sha256.New, []byte(key))
+	mac.Write(message)
+	return mac.Sum(nil)
+}
+
+// NewCircuit asks Tor to use new circuits for subsequent connections.
+// Tor rate-limits this signal, applying it at most every few seconds.
+func (c *Controller) NewCircuit() error {
+	if _, err := c.cmd("SIGNAL NEWNYM"); err != nil {
+		return fmt.Errorf("failed to request new Tor circuit: %w", err)
+	}
+	return nil
+}
+
+// Close closes the connection.
+func (c *Controller) Close() error {
+	return c.conn.Close()
+}
+
+// cmd sends a command and returns the reply text of a 250 response.
+func (c *Controller) cmd(format string, args ...any) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	id, err := c.conn.Cmd(format, args...)
+	if err != nil {
+		return "", err
+	}
+	c.conn.StartResponse(id)
+	defer c.conn.EndResponse(id)
+	_, msg, err := c.conn.ReadResponse(250)
+	return msg, err
+}
+
+// parseAuthInfo extracts the auth methods and cookie file from a
+// PROTOCOLINFO reply.
+func parseAuthInfo(info string) (methods map[string]bool, cookieFile string) {
+	methods = make(map[string]bool)
+	for _, line := range strings.Split(info, "\n") {
+		rest, ok := strings.CutPrefix(line, "AUTH ")
+		if !ok {
+			continue
+		}
+		for _, field := range strings.Fields(rest) {
+			if list, ok := strings.CutPrefix(field, "METHODS="); ok {
+				for _, m := range strings.Split(list, ",") {
+					methods[m] = true
+				}
+			}
+		}
+		if _, file, ok := strings.Cut(rest, `COOKIEFILE="`); ok {
+			cookieFile, _, _ = strings.Cut(file, `"`)
+			cookieFile = strings.ReplaceAll(cookieFile, `\\`, `\`)
+		}
+	}
+	return methods, cookieFile
+}
+
+// quote returns s as a control protocol QuotedString.
+func quote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
@@ -0,0 +1,151 @@
+package tor
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeTor serves one control connection, offering SAFECOOKIE with
+// cookieFile and proving knowledge of cookie, and reports the commands it
+// received.
+func fakeTor(t *testing.T, cookieFile string, cookie []byte) (addr string, commands <-chan []string) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	received := make(chan []string, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		var cmds []string
+		defer func() { received <- cmds }()
+		serverNonce := make([]byte, 32)
+		rand.Read(serverNonce)
+		var clientNonce []byte
+		r := bufio.NewReader(conn)
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			cmd := strings.TrimSpace(line)
+			cmds = append(cmds, cmd)
+			switch {
+			case cmd == "PROTOCOLINFO 1":
+				fmt.Fprintf(conn, "250-PROTOCOLINFO 1\r\n250-AUTH METHODS=COOKIE,SAFECOOKIE COOKIEFILE=%q\r\n250-VERSION Tor=\"0.4.8.10\"\r\n250 OK\r\n", cookieFile)
+			case strings.HasPrefix(cmd, "AUTHCHALLENGE SAFECOOKIE "):
+				clientNonce, _ = hex.DecodeString(strings.TrimPrefix(cmd, "AUTHCHALLENGE SAFECOOKIE "))
+				hash := cookieHMAC(serverHashKey, slices.Concat(cookie, clientNonce, serverNonce))
+				fmt.Fprintf(conn, "250 AUTHCHALLENGE SERVERHASH=%X SERVERNONCE=%X\r\n", hash, serverNonce)
+			case strings.HasPrefix(cmd, "AUTHENTICATE "):
+				want := cookieHMAC(clientHashKey, slices.Concat(cookie, clientNonce, serverNonce))
+				if strings.EqualFold(strings.TrimPrefix(cmd, "AUTHENTICATE "), hex.EncodeToString(want)) {
+					fmt.Fprint(conn, "250 OK\r\n")
+				} else {
+					fmt.Fprint(conn, "515 Authentication failed\r\n")
+				}
+			default:
+				fmt.Fprint(conn, "510 Unrecognized command\r\n")
+			}
+		}
+	}()
+	return ln.Addr().String(), received
+}
+
+func writeCookie(t *testing.T, data []byte) string {
+	t.Helper()
+	file := filepath.Join(t.TempDir(), "control_auth_cookie")
+	if err := os.WriteFile(file, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return file
+}
+
+func TestSafeCookie(t *testing.T) {
+	cookie := make([]byte, cookieSize)
+	rand.Read(cookie)
+	other := make([]byte, cookieSize)
+	rand.Read(other)
+
+	tests := []struct {
+		name string
+		// file is the cookie the client reads, known is the one the
+		// daemon knows
+		file, known []byte
+		wantErr     bool
+	}{
+		{"matching cookie", cookie, cookie, false},
+		{"daemon does not know the cookie", cookie, other, true},
+		{"short file", cookie[:16], cookie, true},
+		{"long file", append(slices.Clone(cookie), '\n'), cookie, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file := writeCookie(t, tt.file)
+			addr, commands := fakeTor(t, file, tt.known)
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			c, err := Dial(ctx, addr, "")
+			if err == nil {
+				c.Close()
+			}
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Dial() error = %v, want error %v", err, tt.wantErr)
+			}
+			cmds := <-commands
+			for _, cmd := range cmds {
+				// The raw cookie is never sent
+				if strings.Contains(strings.ToLower(cmd), hex.EncodeToString(cookie)) {
+					t.Errorf("the cookie was sent in %q", cmd)
+				}
+			}
+			authenticated := slices.ContainsFunc(cmds, func(cmd string) bool { return strings.HasPrefix(cmd, "AUTHENTICATE ") })
+			if authenticated == tt.wantErr {
+				t.Errorf("commands %q, want AUTHENTICATE sent: %v", cmds, !tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestReadCookie(t *testing.T) {
+	cookie := writeCookie(t, make([]byte, cookieSize))
+	link := filepath.Join(t.TempDir(), "link")
+	if err := os.Symlink(cookie, link); err != nil {
+		t.Skipf("cannot create symlinks: %v", err)
+	}
+	tests := []struct {
+		name    string
+		file    string
+		wantErr bool
+	}{
+		{"cookie", cookie, false},
+		{"relative path", "control_auth_cookie", true},
+		{"directory", t.TempDir(), true},
+		{"symlink", link, true},
+		{"missing", filepath.Join(t.TempDir(), "missing"), true},
+		{"device", os.DevNull, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := readCookie(tt.file)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("readCookie(%q) error = %v, want error %v", tt.file, err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,365 @@
+// Package tracing records OpenTelemetry spans and exports them to an OTLP
+// collector over HTTP with JSON encoding. Spans are passed down through
+// contexts; without a Tracer in the context, starting a span does nothing,
+// so instrumented code needs no checks of its own.
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// exportInterval is how often finished spans are sent.
+	exportInterval = 5 * time.Second
+	// maxBatch is the number of finished spans that triggers an export
+	// before the interval has passed.
+	maxBatch = 512
+	// maxQueue bounds the spans kept while the collector is unreachable.
+	maxQueue = 8192
+)
+
+// OTLP span kinds and status codes.
+const (
+	kindInternal = 1
+	kindClient   = 3
+
+	statusError = 2
+)
+
+// Attribute is a key-value pair describing a span.
+type Attribute struct {
+	Key   string
+	Value any
+}
+
+// String returns a string attribute.
+func String(key, value string) Attribute { return Attribute{Key: key, Value: value} }
+
+// Int returns an integer attribute.
+func Int(key string, value int) Attribute { return Attribute{Key: key, Value: int64(value)} }
+
+// Bool returns a boolean attribute.
+func Bool(key string, value bool) Attribute { return Attribute{Key: key, Value: value} }
+
+// Tracer collects finished spans and exports them in the background.
+type Tracer struct {
+	endpoint string
+	service  string
+	client   *http.Client
+
+	mu      sync.Mutex
+	spans   []*Span
+	flush   chan struct{}
+	stop    chan struct{}
+	stopped chan struct{}
+}
+
+// New returns a Tracer exporting the spans of service to the OTLP/HTTP
+// collector at endpoint, e.g. http://localhost:4318. The traces path
+// /v1/traces is appended unless endpoint already ends with it. Call
+// Shutdown to export the remaining spans.
+func New(endpoint, service string, client *http.Client) (*Tracer, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("invalid OTLP endpoint %q (expected an http(s) URL such as http://localhost:4318)", endpoint)
+	}
+	if !strings.HasSuffix(u.Path, "/v1/traces") {
+		u.Path = strings.TrimSuffix(u.Path, "/") + "/v1/traces"
+	}
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+
+	t := &Tracer{
+		endpoint: u.String(),
+		service:  service,
+		client:   client,
+		flush:    make(chan struct{}, 1),
+		stop:     make(chan struct{}),
+		stopped:  make(chan struct{}),
+	}
+	go t.loop()
+	return t, nil
+}
+
+// loop exports finished spans every exportInterval, or earlier when a
+// full batch is waiting, until Shutdown.
+func (t *Tracer) loop() {
+	defer close(t.stopped)
+	ticker := time.NewTicker(exportInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-t.flush:
+		case <-t.stop:
+			return
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), t.client.Timeout+time.Second)
+		t.export(ctx)
+		cancel()
+	}
+}
+
+// Shutdown stops the background export and sends the remaining spans
+// within ctx. Spans ended afterwards are dropped.
+func (t *Tracer) Shutdown(ctx context.Context) error {
+	if t == nil {
+		return nil
+	}
+	close(t.stop)
+	<-t.stopped
+	return t.export(ctx)
+}
+
+// finish queues a span that has ended.
+func (t *Tracer) finish(s *Span) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if len(t.spans) >= maxQueue {
+		return
+	}
+	t.spans = append(t.spans, s)
+	if len(t.spans) >= maxBatch {
+		select {
+		case t.flush <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// export sends the queued spans. Spans are dropped when the collector
+// rejects them or cannot be reached, so a missing collector never grows
+// memory or blocks the caller for long.
+func (t *Tracer) export(ctx context.Context) error {
+	t.mu.Lock()
+	spans := t.spans
+	t.spans = nil
+	t.mu.Unlock()
+	if len(spans) == 0 {
+		return nil
+	}
+
+	body, err := json.Marshal(t.request(spans))
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	slog.Debug("Exporting spans", "endpoint", t.endpoint, "spans", len(spans))
+	resp, err := t.client.Do(req)
+	if err != nil {
+		slog.Warn("Failed to export spans", "endpoint", t.endpoint, "spans", len(spans), "error", err)
+		return fmt.Errorf("failed to export spans to %s: %w", t.endpoint, err)
+	}
+	defer func() {
+		if err := resp.Body.Close(); err != nil {
+			slog.Warn("failed to close response body", "error", err)
+		}
+	}()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		slog.Warn("OTLP collector rejected spans", "endpoint", t.endpoint, "status", resp.StatusCode, "spans", len(spans))
+		return fmt.Errorf("exporting spans to %s returned status %d: %s", t.endpoint, resp.StatusCode, bytes.TrimSpace(msg))
+	}
+	return nil
+}
+
+// request builds the OTLP ExportTraceServiceRequest for spans.
+func (t *Tracer) request(spans []*Span) map[string]any {
+	encoded := make([]map[string]any, 0, len(spans))
+	for _, s := range spans {
+		encoded = append(encoded, s.encode())
+	}
+	return map[string]any{
+		"resourceSpans": []map[string]any{{
+			"resource": map[string]any{
+				"attributes": encodeAttributes([]Attribute{String("service.name", t.service)}),
+			},
+			"scopeSpans": []map[string]any{{
+				"scope": map[string]any{"name": "github.com/pesarkhobeee/that-cli-web-toolbox"},
+				"spans": encoded,
+			}},
+		}},
+	}
+}
+
+// Span is one timed operation of a trace. All methods may be called on a
+// nil Span, which records nothing.
+type Span struct {
+	tracer  *Tracer
+	traceID [16]byte
+	spanID  [8]byte
+	parent  [8]byte
+	name    string
+	kind    int
+	start   time.Time
+
+	mu    sync.Mutex
+	end   time.Time
+	attrs []Attribute
+	err   string
+}
+
+type tracerKey struct{}
+type spanKey struct{}
+
+// WithTracer returns a copy of ctx in which spans without a parent are
+// started on t.
+func WithTracer(ctx context.Context, t *Tracer) context.Context {
+	if t == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, tracerKey{}, t)
+}
+
+// Enabled reports whether spans started from ctx are recorded.
+func Enabled(ctx context.Context) bool {
+	return SpanFromContext(ctx) != nil || ctx.Value(tracerKey{}) != nil
+}
+
+// SpanFromContext returns the span ctx was derived from, or nil.
+func SpanFromContext(ctx context.Context) *Span {
+	s, _ := ctx.Value(spanKey{}).(*Span)
+	return s
+}
+
+// Start begins a span named name as a child of the span in ctx, or as the
+// root of a new trace on the Tracer given to WithTracer. It returns a
+// context carrying the new span. Without either, it returns ctx and a nil
+// Span.
+func Start(ctx context.Context, name string, attrs ...Attribute) (context.Context, *Span) {
+	parent := SpanFromContext(ctx)
+	t, _ := ctx.Value(tracerKey{}).(*Tracer)
+	if parent != nil {
+		t = parent.tracer
+	}
+	if t == nil {
+		return ctx, nil
+	}
+
+	s := &Span{tracer: t, name: name, kind: kindInternal, start: time.Now(), attrs: attrs}
+	if parent != nil {
+		s.traceID = parent.traceID
+		s.parent = parent.spanID
+	} else {
+		_, _ = rand.Read(s.traceID[:])
+	}
+	_, _ = rand.Read(s.spanID[:])
+	return context.WithValue(ctx, spanKey{}, s), s
+}
+
+// SetAttributes adds attributes to the span.
+func (s *Span) SetAttributes(attrs ...Attribute) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.attrs = append(s.attrs, attrs...)
+}
+
+// RecordError marks the span as failed with err; a nil err is ignored.
+func (s *Span) RecordError(err error) {
+	if s == nil || err == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.err = err.Error()
+}
+
+// End finishes the span and queues it for export. Later calls do nothing.
+func (s *Span) End() {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	if !s.end.IsZero() {
+		s.mu.Unlock()
+		return
+	}
+	s.end = time.Now()
+	s.mu.Unlock()
+	s.tracer.finish(s)
+}
+
+// Record adds a finished child span for an outgoing call that was timed
+// elsewhere, such as a protocol command; errMsg is empty on success.
+func (s *Span) Record(name string, start, end time.Time, errMsg string, attrs ...Attribute) {
+	if s == nil {
+		return
+	}
+	child := &Span{
+		tracer:  s.tracer,
+		traceID: s.traceID,
+		parent:  s.spanID,
+		name:    name,
+		kind:    kindClient,
+		start:   start,
+		end:     end,
+		attrs:   attrs,
+		err:     errMsg,
+	}
+	_, _ = rand.Read(child.spanID[:])
+	s.tracer.finish(child)
+}
+
+// encode returns the span in the OTLP JSON encoding.
+func (s *Span) encode() map[string]any {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	span := map[string]any{
+		"traceId":           hex.EncodeToString(s.traceID[:]),
+		"spanId":            hex.EncodeToString(s.spanID[:]),
+		"name":              s.name,
+		"kind":              s.kind,
+		"startTimeUnixNano": strconv.FormatInt(s.start.UnixNano(), 10),
+		"endTimeUnixNano":   strconv.FormatInt(s.end.UnixNano(), 10),
+		"attributes":        encodeAttributes(s.attrs),
+	}
+	if s.parent != [8]byte{} {
+		span["parentSpanId"] = hex.EncodeToString(s.parent[:])
+	}
+	if s.err != "" {
+		span["status"] = map[string]any{"code": statusError, "message": s.err}
+	}
+	return span
+}
+
+// encodeAttributes returns attrs as OTLP KeyValues.
+func encodeAttributes(attrs []Attribute) []map[string]any {
+	encoded := make([]map[string]any, 0, len(attrs))
+	for _, a := range attrs {
+		var value map[string]any
+		switch v := a.Value.(type) {
+		case string:
+			value = map[string]any{"stringValue": v}
+		case int64:
+			value = map[string]any{"intValue": strconv.FormatInt(v, 10)}
+		case bool:
+			value = map[string]any{"boolValue": v}
+		default:
+			value = map[string]any{"stringValue": fmt.Sprint(v)}
+		}
+		encoded = append(encoded, map[string]any{"key": a.Key, "value": value})
+	}
+	return encoded
+}
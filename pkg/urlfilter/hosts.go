@@ -0,0 +1,98 @@
+package urlfilter
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/pesarkhobeee/that-cli-web-toolbox/pkg/idn"
+)
+
+// Hosts is an allowlist of hosts network requests may go to.
+//
+// A pattern is a host name, matching only that host, or "*." followed by
+// a domain, matching its subdomains but not the domain itself. Matching
+// ignores case and ports, and internationalized hosts match in either
+// form.
+type Hosts struct {
+	exact    map[string]bool
+	suffixes []string
+	patterns []string
+}
+
+// NewHosts compiles the host patterns. It returns nil when the list is
+// empty; a nil Hosts allows every URL.
+func NewHosts(patterns []string) (*Hosts, error) {
+	h := &Hosts{exact: make(map[string]bool)}
+	for _, raw := range patterns {
+		p := strings.ToLower(strings.TrimSpace(raw))
+		if p == "" {
+			continue
+		}
+		domain, wildcard := strings.CutPrefix(p, "*.")
+		if domain == "" || strings.ContainsAny(domain, "*/:@ ") {
+			return nil, fmt.Errorf("invalid host pattern %q (expected a host such as example.com or *.example.com)", raw)
+		}
+		// Requests go to internationalized hosts by their Punycode form
+		domain, err := idn.ToASCII(domain)
+		if err != nil {
+			return nil, fmt.Errorf("invalid host pattern %q: %w", raw, err)
+		}
+		if wildcard {
+			h.suffixes = append(h.suffixes, "."+domain)
+		} else {
+			h.exact[domain] = true
+		}
+		h.patterns = append(h.patterns, p)
+	}
+	if len(h.patterns) == 0 {
+		return nil, nil
+	}
+	return h, nil
+}
+
+// Patterns returns the patterns of the allowlist, lowercased.
+func (h *Hosts) Patterns() []string {
+	if h == nil {
+		return nil
+	}
+	return h.patterns
+}
+
+// Allowed reports whether a request to rawURL may be made. URLs that do
+// not reach the network, such as data:, blob:, about: and file: URLs, are
+// always allowed; other URLs must have an allowed host.
+func (h *Hosts) Allowed(rawURL string) bool {
+	if h == nil {
+		return true
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return false
+	}
+	switch strings.ToLower(u.Scheme) {
+	case "data", "blob", "about", "file", "javascript":
+		return true
+	}
+	return h.AllowedHost(u.Hostname())
+}
+
+// AllowedHost reports whether host matches a pattern.
+func (h *Hosts) AllowedHost(host string) bool {
+	if h == nil {
+		return true
+	}
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+	if host == "" {
+		return false
+	}
+	if h.exact[host] {
+		return true
+	}
+	for _, suffix := range h.suffixes {
+		if strings.HasSuffix(host, suffix) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,96 @@
+// Package urlfilter decides which URLs may be visited using allow and deny
+// lists of wildcard patterns.
+package urlfilter
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// Filter holds compiled allow and deny patterns.
+//
+// A pattern starting with "/" is matched against the URL path, anything
+// else against the whole URL. "*" matches any run of characters, including
+// "/". Patterns must match completely, so "/admin" does not match
+// "/admin/delete" but "/admin*" does.
+type Filter struct {
+	allow []pattern
+	deny  []pattern
+}
+
+type pattern struct {
+	raw    string
+	re     *regexp.Regexp
+	onPath bool
+}
+
+// New compiles the allow and deny patterns. It returns nil when both lists
+// are empty; a nil Filter allows every URL.
+func New(allow, deny []string) (*Filter, error) {
+	if len(allow) == 0 && len(deny) == 0 {
+		return nil, nil
+	}
+	f := &Filter{}
+	var err error
+	if f.allow, err = compile(allow); err != nil {
+		return nil, err
+	}
+	if f.deny, err = compile(deny); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+func compile(patterns []string) ([]pattern, error) {
+	var compiled []pattern
+	for _, raw := range patterns {
+		raw = strings.TrimSpace(raw)
+		if raw == "" {
+			continue
+		}
+		parts := strings.Split(raw, "*")
+		for i, part := range parts {
+			parts[i] = regexp.QuoteMeta(part)
+		}
+		re, err := regexp.Compile("^" + strings.Join(parts, ".*") + "$")
+		if err != nil {
+			return nil, fmt.Errorf("invalid URL pattern %q: %w", raw, err)
+		}
+		compiled = append(compiled, pattern{raw: raw, re: re, onPath: strings.HasPrefix(raw, "/")})
+	}
+	return compiled, nil
+}
+
+// Allowed reports whether rawURL may be visited: it must not match any
+// deny pattern and, if there are allow patterns, must match one of them.
+func (f *Filter) Allowed(rawURL string) bool {
+	if f == nil {
+		return true
+	}
+	if match(f.deny, rawURL) {
+		return false
+	}
+	return len(f.allow) == 0 || match(f.allow, rawURL)
+}
+
+func match(patterns []pattern, rawURL string) bool {
+	path := rawURL
+	if u, err := url.Parse(rawURL); err == nil {
+		path = u.Path
+		if path == "" {
+			path = "/"
+		}
+	}
+	for _, p := range patterns {
+		subject := rawURL
+		if p.onPath {
+			subject = path
+		}
+		if p.re.MatchString(subject) {
+			return true
+		}
+	}
+	return false
+}
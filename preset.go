@@ -0,0 +1,55 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+
+	chromedphelper "github.com/pesarkhobeee/that-cli-web-toolbox/pkg/chromedp"
+)
+
+// presetAction extracts the page's main items for --preset as normalized
+// JSON objects, from its structured data, meta tags and heuristic
+// selectors.
+type presetAction struct{ noopAction }
+
+func (a *presetAction) Name() string             { return "preset" }
+func (a *presetAction) Enabled(cfg *Config) bool { return cfg.Preset != "" }
+func (a *presetAction) Flags() []string          { return []string{"--preset"} }
+
+func (a *presetAction) Validate(cfg *Config) error {
+	if !slices.Contains(chromedphelper.Presets, cfg.Preset) {
+		return fmt.Errorf("invalid --preset %q (expected %s)", cfg.Preset, strings.Join(chromedphelper.Presets, ", "))
+	}
+	return nil
+}
+
+func (a *presetAction) Execute(ctx context.Context, run *Run) error {
+	slog.Info("Extracting with preset", "preset", run.Config.Preset)
+	e, err := run.Browser.ExtractPreset(ctx, run.Config.Preset)
+	if err != nil {
+		return err
+	}
+	if e.Len() == 0 {
+		slog.Warn("Preset found nothing on the page", "preset", e.Preset, "url", e.URL)
+	}
+	run.Result.Extraction = e
+	return nil
+}
+
+func (a *presetAction) Report(ctx context.Context, run *Run) error {
+	data, err := json.MarshalIndent(run.Result.Extraction, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode extraction: %w", err)
+	}
+	return writeTextAs(ctx, run, fmt.Sprintf("%s_%s.json", run.Config.Preset, timestamp()), "application/json; charset=utf-8", string(data))
+}
+
+// formatExtraction renders the number of items a preset extracted for the
+// batch summary, e.g. "2 product items".
+func formatExtraction(e *chromedphelper.Extraction) string {
+	return fmt.Sprintf("%d %s items", e.Len(), e.Preset)
+}
@@ -0,0 +1,360 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	chromedphelper "github.com/pesarkhobeee/that-cli-web-toolbox/pkg/chromedp"
+)
+
+type previewConfig struct {
+	WatchFiles      bool
+	Page            string
+	Port            int
+	Output          string
+	FullPage        bool
+	Viewport        string
+	Device          string
+	Delay           int
+	Timeout         int
+	Interval        time.Duration
+	ExpectSelectors []string
+	Assertions      []string
+	ExpectText      string
+}
+
+var previewCfg previewConfig
+
+var previewCmd = &cobra.Command{
+	Use:   "preview DIR",
+	Short: "Serve a local build, screenshot it and re-render on file changes",
+	Long: `Serve the files of DIR, e.g. the output directory of a build, on a local
+port, load --page in the browser and write a screenshot of it to --output.
+
+With --watch-files the command keeps running: whenever a file under DIR is
+added, removed or changed, the page is loaded again and the screenshot
+rewritten, so an image viewer showing it follows the build. Hidden files and
+directories are not watched. Stop it with Ctrl-C.
+
+--expect-selector, --assert and --expect-text are checked on every render
+and their outcome printed with it. Without --watch-files the exit code is 3
+when a check fails.`,
+	Example: `  # Screenshot the home page of the build in ./dist
+  that-cli-web-toolbox preview ./dist
+
+  # Re-render on every rebuild and check that the header is visible each time
+  that-cli-web-toolbox preview ./dist --watch-files --assert "header:visible"
+
+  # A mobile screenshot of a sub page
+  that-cli-web-toolbox preview ./dist --watch-files --page /pricing.html --device "iPhone 15" --output pricing.png`,
+	RunE: runPreview,
+	Args: cobra.ExactArgs(1),
+	// Failed checks are reported with each render, not with usage
+	SilenceUsage:  true,
+	SilenceErrors: true,
+}
+
+func init() {
+	previewCmd.Flags().BoolVar(&previewCfg.WatchFiles, "watch-files", false, "Keep running and re-render whenever a file under DIR changes")
+	previewCmd.Flags().StringVar(&previewCfg.Page, "page", "/", "Path of the page to render, relative to DIR")
+	previewCmd.Flags().IntVar(&previewCfg.Port, "port", 0, "Port to serve DIR on, on 127.0.0.1 (0 picks a free port)")
+	previewCmd.Flags().StringVarP(&previewCfg.Output, "output", "o", "preview.png", "Screenshot file; its extension (png, jpg or webp) sets the format")
+	previewCmd.Flags().BoolVar(&previewCfg.FullPage, "full-page", false, "Capture the whole page instead of the viewport")
+	previewCmd.Flags().StringVar(&previewCfg.Viewport, "viewport", "", "Viewport size as WIDTHxHEIGHT, e.g. 1280x800")
+	previewCmd.Flags().StringVar(&previewCfg.Device, "device", "", "Emulate a device preset, e.g. \"iPhone 15\"")
+	previewCmd.Flags().IntVarP(&previewCfg.Delay, "delay", "d", 0, "Delay in seconds after the page loads, before the screenshot")
+	previewCmd.Flags().IntVarP(&previewCfg.Timeout, "timeout", "t", 30, "Maximum time in seconds for each render")
+	previewCmd.Flags().DurationVar(&previewCfg.Interval, "interval", 500*time.Millisecond, "How often to look for changed files with --watch-files")
+	previewCmd.Flags().StringArrayVar(&previewCfg.ExpectSelectors, "expect-selector", nil, "Check that an element matches this CSS selector on every render (repeatable)")
+	previewCmd.Flags().StringArrayVar(&previewCfg.Assertions, "assert", nil, "Check that elements are in a state on every render, as SELECTOR:visible, :hidden, :enabled, :disabled, :count>=N or :text~=REGEXP (repeatable)")
+	previewCmd.Flags().StringVar(&previewCfg.ExpectText, "expect-text", "", "Check that the page's text matches this regular expression on every render")
+	rootCmd.AddCommand(previewCmd)
+}
+
+func runPreview(cmd *cobra.Command, args []string) error {
+	setupLogging(cfg.LogLevel)
+
+	dir := args[0]
+	if info, err := os.Stat(dir); err != nil {
+		return err
+	} else if !info.IsDir() {
+		return fmt.Errorf("%s is not a directory", dir)
+	}
+	if previewCfg.Port < 0 || previewCfg.Port > 65535 {
+		return fmt.Errorf("invalid --port %d", previewCfg.Port)
+	}
+	if previewCfg.Timeout < 1 {
+		return fmt.Errorf("--timeout must be at least 1")
+	}
+	if previewCfg.Delay < 0 {
+		return fmt.Errorf("--delay cannot be negative")
+	}
+	if previewCfg.Interval <= 0 {
+		return fmt.Errorf("--interval must be positive")
+	}
+	format, err := chromedphelper.ParseImageFormat(strings.TrimPrefix(filepath.Ext(previewCfg.Output), "."))
+	if err != nil {
+		return fmt.Errorf("unsupported --output %s: %w", previewCfg.Output, err)
+	}
+	emulation, err := parseEmulation(&Config{Viewport: previewCfg.Viewport, Device: previewCfg.Device})
+	if err != nil {
+		return err
+	}
+	if err := validateSelectors("--expect-selector", previewCfg.ExpectSelectors); err != nil {
+		return err
+	}
+	expectations, err := parseExpectations(previewCfg.ExpectSelectors, previewCfg.Assertions, previewCfg.ExpectText)
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	listener, err := net.Listen("tcp", fmt.Sprintf("127.0.0.1:%d", previewCfg.Port))
+	if err != nil {
+		return fmt.Errorf("failed to listen: %w", err)
+	}
+	server := &http.Server{Handler: previewHandler(dir), ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			slog.Error("Preview server failed", "error", err)
+		}
+	}()
+	defer server.Close()
+	target := "http://" + listener.Addr().String() + "/" + strings.TrimPrefix(previewCfg.Page, "/")
+	slog.Info("Serving preview", "dir", dir, "url", target)
+
+	root, err := chromedphelper.InitializeChromedpContext(ctx, target, 0, previewCfg.Delay, cfg.RemoteDebuggingPort, "", managedBrowserOptions("")...)
+	if err != nil {
+		slog.Error("Failed to initialize browser", "error", err)
+		return fmt.Errorf("failed to initialize browser: %w", err)
+	}
+	defer root.Cancel()
+	root.Emulation = emulation
+
+	failed, err := renderPreview(ctx, root, format, expectations)
+	if !previewCfg.WatchFiles {
+		if err != nil {
+			slog.Error("Failed to render preview", "error", err)
+			return err
+		}
+		if failed {
+			return &exitError{code: exitAssertion, err: errReported}
+		}
+		return nil
+	}
+	if err != nil {
+		slog.Error("Failed to render preview, waiting for changes", "error", err)
+	}
+
+	fmt.Printf("Watching %s for changes, press Ctrl-C to stop\n", dir)
+	last, err := snapshotFiles(dir)
+	if err != nil {
+		return err
+	}
+	ticker := time.NewTicker(previewCfg.Interval)
+	defer ticker.Stop()
+	// Files changed since the last render; a render waits for a tick
+	// without changes, so a rebuild writing many files renders once
+	var pending []string
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+		current, err := snapshotFiles(dir)
+		if err != nil {
+			// A build may be replacing the directory; look again next tick
+			slog.Debug("Failed to read watched files", "dir", dir, "error", err)
+			continue
+		}
+		if changed := changedFiles(last, current); len(changed) > 0 {
+			slog.Debug("Files changed", "files", changed)
+			pending = append(pending, changed...)
+			last = current
+			continue
+		}
+		if len(pending) == 0 {
+			continue
+		}
+		fmt.Printf("Changed: %s\n", summarizeChanges(pending))
+		pending = nil
+		if _, err := renderPreview(ctx, root, format, expectations); err != nil && ctx.Err() == nil {
+			slog.Error("Failed to render preview, waiting for changes", "error", err)
+		}
+	}
+}
+
+// previewHandler serves dir without caching, so every render loads the
+// files as they are on disk.
+func previewHandler(dir string) http.Handler {
+	files := http.FileServer(http.Dir(dir))
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "no-store")
+		files.ServeHTTP(w, r)
+	})
+}
+
+// renderPreview loads the page in a new tab of root, writes its screenshot
+// to --output and checks the expectations, printing the outcome. It
+// reports whether a check failed, or the error when the page could not be
+// rendered.
+func renderPreview(ctx context.Context, root *chromedphelper.Browser, format chromedphelper.ImageFormat, exp chromedphelper.Expectations) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(previewCfg.Timeout)*time.Second)
+	defer cancel()
+
+	start := time.Now()
+	checks, err := previewOnce(ctx, root, format, exp)
+	if err != nil {
+		return false, fmt.Errorf("failed to render %s: %w", root.TargetURL, err)
+	}
+	line := fmt.Sprintf("Rendered %s -> %s (%s)", root.TargetURL, previewCfg.Output, time.Since(start).Round(time.Millisecond))
+	failed := chromedphelper.CheckFailures(checks)
+	if len(checks) > 0 {
+		line += fmt.Sprintf(", checks: %d passed, %d failed", len(checks)-failed, failed)
+	}
+	fmt.Println(line)
+	for _, c := range checks {
+		if !c.Passed {
+			fmt.Printf("  FAIL %s: expected %s, got %s\n", c.Name, c.Expected, c.Actual)
+		}
+	}
+	return failed > 0, nil
+}
+
+func previewOnce(ctx context.Context, root *chromedphelper.Browser, format chromedphelper.ImageFormat, exp chromedphelper.Expectations) ([]chromedphelper.CheckResult, error) {
+	tab, err := root.NewTab(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tab.Cancel()
+
+	if err := tab.NavigateAndPrepare(ctx); err != nil {
+		return nil, err
+	}
+	opts := chromedphelper.ScreenshotOptions{Format: format}
+	var image []byte
+	if previewCfg.FullPage {
+		image, err = tab.ScreenshotFullPage(ctx, opts)
+	} else {
+		image, err = tab.ScreenshotViewport(ctx, opts)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := writePreview(previewCfg.Output, image); err != nil {
+		return nil, err
+	}
+	if exp.Empty() {
+		return nil, nil
+	}
+	return tab.Check(ctx, exp)
+}
+
+// writePreview replaces the screenshot at once, so image viewers reloading
+// it never read a partial file.
+func writePreview(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := os.Remove(tmp.Name()); err != nil && !errors.Is(err, os.ErrNotExist) {
+			slog.Warn("failed to remove temporary preview file", "file", tmp.Name(), "error", err)
+		}
+	}()
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// fileState is what snapshotFiles compares to tell a file changed.
+type fileState struct {
+	size    int64
+	modTime time.Time
+}
+
+// snapshotFiles returns the state of the files under dir, by path
+// relative to it, skipping hidden files and directories and the files
+// written by renders, which would otherwise trigger the next one.
+func snapshotFiles(dir string) (map[string]fileState, error) {
+	output, _ := filepath.Abs(previewCfg.Output)
+	files := make(map[string]fileState)
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if abs, _ := filepath.Abs(path); abs == output || strings.HasPrefix(abs, output+".") {
+			return nil
+		}
+		if path != dir && strings.HasPrefix(d.Name(), ".") {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		rel, _ := filepath.Rel(dir, path)
+		files[rel] = fileState{size: info.Size(), modTime: info.ModTime()}
+		return nil
+	})
+	return files, err
+}
+
+// changedFiles returns the paths added, removed or changed from before to
+// after.
+func changedFiles(before, after map[string]fileState) []string {
+	var changed []string
+	for path, state := range after {
+		if prev, ok := before[path]; !ok || prev.size != state.size || !prev.modTime.Equal(state.modTime) {
+			changed = append(changed, path)
+		}
+	}
+	for path := range before {
+		if _, ok := after[path]; !ok {
+			changed = append(changed, path)
+		}
+	}
+	return changed
+}
+
+// summarizeChanges names up to three changed files and counts the rest.
+func summarizeChanges(changed []string) string {
+	sort.Strings(changed)
+	changed = slices.Compact(changed)
+	if len(changed) <= 3 {
+		return strings.Join(changed, ", ")
+	}
+	return fmt.Sprintf("%s and %d more", strings.Join(changed[:3], ", "), len(changed)-3)
+}
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"log/slog"
+	"net"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	chromedphelper "github.com/pesarkhobeee/that-cli-web-toolbox/pkg/chromedp"
+)
+
+// Strategies accepted by --proxy-strategy.
+const (
+	proxyRoundRobin = "round-robin"
+	proxyPerHost    = "per-host"
+)
+
+const (
+	// proxyCheckTimeout bounds the health check of one proxy.
+	proxyCheckTimeout = 5 * time.Second
+	// proxyAttempts is how many proxies a target is tried with before it
+	// fails, when the proxies it got turn out to be dead.
+	proxyAttempts = 3
+)
+
+// proxyEntry is one proxy of the --proxy-pool file.
+type proxyEntry struct {
+	// Server is scheme://host:port, as Chrome expects it.
+	Server string
+	Auth   *chromedphelper.Credentials
+	dead   bool
+}
+
+// proxyPool hands out the live proxies of --proxy-pool and blacklists
+// those that stop working.
+type proxyPool struct {
+	strategy string
+
+	mu      sync.Mutex
+	proxies []*proxyEntry
+	next    int
+}
+
+// loadProxyPool reads proxies from path, one per line as
+// [scheme://][user:pass@]host:port; the scheme defaults to http. Blank lines
+// and lines starting with # are skipped.
+func loadProxyPool(path, strategy string) (*proxyPool, error) {
+	if strategy != proxyRoundRobin && strategy != proxyPerHost {
+		return nil, fmt.Errorf("invalid proxy strategy %q (expected %s or %s)", strategy, proxyRoundRobin, proxyPerHost)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read proxy pool %q: %w", path, err)
+	}
+	defer func() {
+		if err := f.Close(); err != nil {
+			slog.Warn("failed to close proxy pool file", "file", path, "error", err)
+		}
+	}()
+
+	pool := &proxyPool{strategy: strategy}
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		// The line may hold credentials, so errors only name its number
+		p, err := parseProxy(line)
+		if err != nil {
+			return nil, fmt.Errorf("invalid proxy on line %d of %q: %w", n, path, err)
+		}
+		pool.proxies = append(pool.proxies, p)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read proxy pool %q: %w", path, err)
+	}
+	if len(pool.proxies) == 0 {
+		return nil, fmt.Errorf("proxy pool %q lists no proxies", path)
+	}
+	slog.Debug("Proxy pool loaded", "file", path, "proxies", len(pool.proxies), "strategy", strategy)
+	return pool, nil
+}
+
+// parseProxy parses one proxy pool line. Errors do not quote spec.
+func parseProxy(spec string) (*proxyEntry, error) {
+	if !strings.Contains(spec, "://") {
+		spec = "http://" + spec
+	}
+	u, err := url.Parse(spec)
+	if err != nil || u.Hostname() == "" || u.Port() == "" {
+		return nil, fmt.Errorf("expected [scheme://][user:pass@]host:port")
+	}
+	switch u.Scheme {
+	case "http", "https", "socks4", "socks5":
+	default:
+		return nil, fmt.Errorf("scheme must be http, https, socks4 or socks5, got %q", u.Scheme)
+	}
+
+	p := &proxyEntry{Server: u.Scheme + "://" + u.Host}
+	if u.User != nil {
+		if u.Scheme != "http" && u.Scheme != "https" {
+			return nil, fmt.Errorf("credentials are only supported for http and https proxies, not %s", p.Server)
+		}
+		password, _ := u.User.Password()
+		p.Auth = &chromedphelper.Credentials{Username: u.User.Username(), Password: password}
+	}
+	return p, nil
+}
+
+// healthCheck connects to every proxy in parallel and blacklists those
+// that don't accept connections. It fails when none is left.
+func (pp *proxyPool) healthCheck(ctx context.Context) error {
+	var wg sync.WaitGroup
+	for _, p := range pp.proxies {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			u, _ := url.Parse(p.Server)
+			d := net.Dialer{Timeout: proxyCheckTimeout}
+			conn, err := d.DialContext(ctx, "tcp", u.Host)
+			if err != nil {
+				pp.blacklist(p, err)
+				return
+			}
+			if err := conn.Close(); err != nil {
+				slog.Debug("failed to close proxy health check connection", "proxy", p.Server, "error", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if _, err := pp.pick(""); err != nil {
+		return err
+	}
+	return nil
+}
+
+// pick returns a live proxy for target: the next one in turn, or with
+// the per-host strategy the same one for every target on a host.
+func (pp *proxyPool) pick(target string) (*proxyEntry, error) {
+	pp.mu.Lock()
+	defer pp.mu.Unlock()
+
+	var live []*proxyEntry
+	for _, p := range pp.proxies {
+		if !p.dead {
+			live = append(live, p)
+		}
+	}
+	if len(live) == 0 {
+		return nil, errors.New("all proxies in the proxy pool are dead")
+	}
+
+	if pp.strategy == proxyPerHost {
+		h := fnv.New32a()
+		if u, err := url.Parse(target); err == nil {
+			h.Write([]byte(u.Host))
+		}
+		return live[int(h.Sum32()%uint32(len(live)))], nil
+	}
+	p := live[pp.next%len(live)]
+	pp.next++
+	return p, nil
+}
+
+// blacklist stops handing out p.
+func (pp *proxyPool) blacklist(p *proxyEntry, cause error) {
+	pp.mu.Lock()
+	defer pp.mu.Unlock()
+	if !p.dead {
+		slog.Warn("Blacklisting dead proxy", "proxy", p.Server, "error", cause)
+		p.dead = true
+	}
+}
+
+// proxyFailed reports whether err means the page could not be loaded
+// because of the proxy rather than the site.
+func proxyFailed(err error) bool {
+	if err == nil {
+		return false
+	}
+	msg := err.Error()
+	for _, code := range []string{"ERR_PROXY_", "ERR_TUNNEL_CONNECTION_FAILED", "ERR_SOCKS_CONNECTION_FAILED", "ERR_NO_SUPPORTED_PROXIES"} {
+		if strings.Contains(msg, code) {
+			return true
+		}
+	}
+	return false
+}
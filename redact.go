@@ -0,0 +1,55 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/pesarkhobeee/that-cli-web-toolbox/pkg/pii"
+)
+
+// redactAction reports how much personal data --redact-pii replaced in the
+// extracted text. The replacing itself is part of normalizeText.
+type redactAction struct{ noopAction }
+
+func (a *redactAction) Name() string             { return "redact-pii" }
+func (a *redactAction) Enabled(cfg *Config) bool { return cfg.RedactPII }
+func (a *redactAction) Flags() []string          { return []string{"--redact-pii"} }
+
+func (a *redactAction) Validate(cfg *Config) error {
+	if !cfg.GetBody && len(cfg.GetTextByCssSelector) == 0 {
+		return fmt.Errorf("--redact-pii requires --body or --gettextbycssselector")
+	}
+	return nil
+}
+
+func (a *redactAction) Report(ctx context.Context, run *Run) error {
+	// Batch runs list the counts in the batch summary instead
+	if run.Batch || structuredFormat(run.Config.OutputFormat) {
+		return nil
+	}
+	fmt.Printf("Redacted: %s\n", formatRedactions(run.Result.Redactions))
+	return nil
+}
+
+// redactPII replaces the personal data in text with placeholders and adds
+// the replacements to the run's counts.
+func redactPII(run *Run, text string) string {
+	text, counts := pii.Redact(text)
+	run.Result.AddRedactions(counts)
+	return text
+}
+
+// formatRedactions renders redaction counts, e.g. "2 email, 1 phone".
+func formatRedactions(counts map[string]int) string {
+	var parts []string
+	for _, kind := range pii.Kinds {
+		if counts[kind] > 0 {
+			parts = append(parts, fmt.Sprintf("%d %s", counts[kind], kind))
+		}
+	}
+	if len(parts) == 0 {
+		return "nothing"
+	}
+	return strings.Join(parts, ", ")
+}
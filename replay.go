@@ -0,0 +1,36 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+
+	"github.com/pesarkhobeee/that-cli-web-toolbox/pkg/har"
+)
+
+// loadReplay reads the HAR file of --replay-har and indexes its entries.
+func loadReplay(path string) (*har.Replay, error) {
+	h, err := har.Load(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load --replay-har: %w", err)
+	}
+	replay := har.NewReplay(h)
+	if replay.Len() == 0 {
+		return nil, fmt.Errorf("--replay-har %s has no entries with an http(s) URL", path)
+	}
+	if replay.Bodiless > 0 {
+		// Such as the files of --har, which records no bodies
+		slog.Warn("Responses of the HAR have no recorded body and are replayed empty; export the HAR with content", "file", path, "count", replay.Bodiless)
+	}
+	slog.Debug("HAR loaded for replay", "file", path, "entries", replay.Len(), "creator", h.Log.Creator.Name)
+	return replay, nil
+}
+
+// formatReplay renders how a page's requests were served from the HAR,
+// e.g. "42 replayed (3 approximately), 2 not in the HAR".
+func formatReplay(stats *har.ReplayStats) string {
+	s := fmt.Sprintf("%d replayed", stats.Served)
+	if stats.Approximate > 0 {
+		s += fmt.Sprintf(" (%d approximately)", stats.Approximate)
+	}
+	return s + fmt.Sprintf(", %d not in the HAR", len(stats.Missed))
+}
@@ -0,0 +1,235 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/pflag"
+
+	chromedphelper "github.com/pesarkhobeee/that-cli-web-toolbox/pkg/chromedp"
+)
+
+// manifest is the reproducibility manifest of --manifest: what a page's
+// outputs were rendered with, to reproduce them or tell why they drifted.
+type manifest struct {
+	Target     string                         `json:"target"`
+	URL        string                         `json:"url,omitempty"`
+	CapturedAt time.Time                      `json:"capturedAt"`
+	Toolbox    manifestToolbox                `json:"toolbox"`
+	Chrome     *chromedphelper.BrowserVersion `json:"chrome"`
+	Command    []string                       `json:"command"`
+	// Flags are the flags set on the command line, by name.
+	Flags     map[string]string         `json:"flags"`
+	Rendering *chromedphelper.Rendering `json:"rendering"`
+	Files     []chromedphelper.File     `json:"files"`
+}
+
+type manifestToolbox struct {
+	Version  string `json:"version"`
+	Go       string `json:"go"`
+	Platform string `json:"platform"`
+}
+
+// manifestAction writes a manifest next to the page's outputs, with the
+// versions of the toolbox and Chrome, the flags, the viewport and the
+// fonts they were rendered with, and the checksums of the outputs of the
+// actions before it.
+type manifestAction struct {
+	noopAction
+	url        string
+	capturedAt time.Time
+}
+
+func (a *manifestAction) Name() string             { return "manifest" }
+func (a *manifestAction) Enabled(cfg *Config) bool { return cfg.Manifest }
+func (a *manifestAction) Flags() []string          { return []string{"--manifest"} }
+
+func (a *manifestAction) Execute(ctx context.Context, run *Run) error {
+	slog.Info("Recording rendering environment")
+	var err error
+	a.capturedAt = time.Now().UTC()
+	if a.url, err = run.Browser.CurrentURL(ctx); err != nil {
+		return err
+	}
+	if run.Result.Chrome, err = run.Browser.Version(ctx); err != nil {
+		return fmt.Errorf("failed to get Chrome's version: %w", err)
+	}
+	if run.Result.Rendering, err = run.Browser.Rendering(ctx); err != nil {
+		return fmt.Errorf("failed to get rendering environment: %w", err)
+	}
+	return nil
+}
+
+func (a *manifestAction) Report(ctx context.Context, run *Run) error {
+	m := manifest{
+		Target:     run.Result.Target,
+		URL:        a.url,
+		CapturedAt: a.capturedAt,
+		Toolbox:    manifestToolbox{Version: version, Go: runtime.Version(), Platform: runtime.GOOS + "/" + runtime.GOARCH},
+		Chrome:     run.Result.Chrome,
+		Command:    redactArgs(os.Args),
+		Flags:      changedFlags(rootCmd.Flags()),
+		Rendering:  run.Result.Rendering,
+		Files:      append([]chromedphelper.File{}, run.Result.Files...),
+	}
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode manifest: %w", err)
+	}
+	return writeArtifact(ctx, run, "manifest", "", "Manifest", fmt.Sprintf("manifest_%s.json", timestamp()), "application/json", data)
+}
+
+// changedFlags returns the flags of flags set on the command line, with
+// the values of secretFlags replaced like in the command.
+func changedFlags(flags *pflag.FlagSet) map[string]string {
+	set := make(map[string]string)
+	flags.Visit(func(f *pflag.Flag) {
+		value := f.Value.String()
+		if secretFlags["--"+f.Name] {
+			value = "REDACTED"
+		}
+		set[f.Name] = value
+	})
+	return set
+}
+
+// requireChromeAction fails pages rendered by a Chrome whose version is
+// outside --require-chrome, before navigating, so captures are not
+// silently made with another Chrome than the one they were approved with.
+type requireChromeAction struct {
+	noopAction
+	bounds []versionBound
+}
+
+func (a *requireChromeAction) Name() string             { return "require-chrome" }
+func (a *requireChromeAction) Enabled(cfg *Config) bool { return cfg.RequireChrome != "" }
+func (a *requireChromeAction) Flags() []string          { return []string{"--require-chrome"} }
+
+func (a *requireChromeAction) Validate(cfg *Config) error {
+	var err error
+	if a.bounds, err = parseVersionRequirement(cfg.RequireChrome); err != nil {
+		return fmt.Errorf("invalid --require-chrome %q: %w", cfg.RequireChrome, err)
+	}
+	return nil
+}
+
+func (a *requireChromeAction) Prepare(ctx context.Context, run *Run) error {
+	v, err := run.Browser.Version(ctx)
+	if err != nil {
+		return &exitError{code: exitBrowser, err: fmt.Errorf("failed to get Chrome's version: %w", err)}
+	}
+	number := v.Number()
+	ok, err := satisfiesVersion(number, a.bounds)
+	if err != nil {
+		return &exitError{code: exitBrowser, err: fmt.Errorf("failed to read Chrome's version %q: %w", v.Product, err)}
+	}
+	if !ok {
+		slog.Error("Chrome version outside --require-chrome", "version", number, "require", run.Config.RequireChrome)
+		return &exitError{code: exitBrowser, err: fmt.Errorf("Chrome %s does not satisfy --require-chrome %q", number, run.Config.RequireChrome)}
+	}
+	slog.Debug("Chrome version satisfies --require-chrome", "version", number, "require", run.Config.RequireChrome)
+	return nil
+}
+
+// versionBound is one comparison of a version requirement, such as >=120.
+// Only the components it names are compared, so <125 holds for every
+// 124.x and =131.0.6778 for every build of 131.0.6778.
+type versionBound struct {
+	op    string
+	parts []int
+}
+
+// versionOps are the operators of a version requirement, longest first.
+var versionOps = []string{">=", "<=", "!=", "==", ">", "<", "="}
+
+// parseVersionRequirement parses comparisons separated by spaces, all of
+// which must hold, e.g. ">=120 <125". A version without an operator must
+// match.
+func parseVersionRequirement(s string) ([]versionBound, error) {
+	fields := strings.Fields(s)
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("empty requirement")
+	}
+	var bounds []versionBound
+	for _, field := range fields {
+		b := versionBound{op: "="}
+		for _, op := range versionOps {
+			if rest, ok := strings.CutPrefix(field, op); ok {
+				b.op, field = op, rest
+				break
+			}
+		}
+		if b.op == "==" {
+			b.op = "="
+		}
+		parts, err := parseVersionParts(field)
+		if err != nil {
+			return nil, err
+		}
+		b.parts = parts
+		bounds = append(bounds, b)
+	}
+	return bounds, nil
+}
+
+func parseVersionParts(s string) ([]int, error) {
+	var parts []int
+	for _, p := range strings.Split(s, ".") {
+		n, err := strconv.Atoi(p)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("invalid version %q, expected numbers separated by dots such as 120 or 131.0.6778.85", s)
+		}
+		parts = append(parts, n)
+	}
+	return parts, nil
+}
+
+// satisfiesVersion reports whether version meets every bound.
+func satisfiesVersion(version string, bounds []versionBound) (bool, error) {
+	parts, err := parseVersionParts(version)
+	if err != nil {
+		return false, err
+	}
+	for _, b := range bounds {
+		c := 0
+		for i, want := range b.parts {
+			got := 0
+			if i < len(parts) {
+				got = parts[i]
+			}
+			if got != want {
+				c = 1
+				if got < want {
+					c = -1
+				}
+				break
+			}
+		}
+		var ok bool
+		switch b.op {
+		case ">=":
+			ok = c >= 0
+		case "<=":
+			ok = c <= 0
+		case ">":
+			ok = c > 0
+		case "<":
+			ok = c < 0
+		case "!=":
+			ok = c != 0
+		default:
+			ok = c == 0
+		}
+		if !ok {
+			return false, nil
+		}
+	}
+	return true, nil
+}
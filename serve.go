@@ -0,0 +1,719 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"regexp"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/pesarkhobeee/that-cli-web-toolbox/pkg/apiclient"
+	chromedphelper "github.com/pesarkhobeee/that-cli-web-toolbox/pkg/chromedp"
+	"github.com/pesarkhobeee/that-cli-web-toolbox/pkg/tracing"
+)
+
+const (
+	// maxRequestBody bounds the JSON body of an API request.
+	maxRequestBody = 1 << 20
+	// shutdownGrace is the default of --drain-timeout: how long in-flight
+	// work may take to finish after an interrupt before it is aborted.
+	shutdownGrace = 30 * time.Second
+	// retryAfter is the Retry-After, in seconds, of requests turned away
+	// while busy or shutting down.
+	retryAfter = "1"
+)
+
+// Errors of requests the server turns away.
+var (
+	errBusy     = errors.New("too many concurrent requests, retry later")
+	errDraining = errors.New("server is shutting down")
+)
+
+type serveConfig struct {
+	Listen             string
+	MaxPages           int
+	MaxConcurrent      int
+	MaxBrowserLifetime time.Duration
+	DrainTimeout       time.Duration
+	APIKeys            string
+	TenantBy           string
+	MaxTenants         int
+	MaxJobs            int
+	JobTimeout         int
+	JobTTL             time.Duration
+	Timeout            int
+	Delay              int
+}
+
+var serveCfg serveConfig
+
+var serveCmd = &cobra.Command{
+	Use:   "serve",
+	Short: "Serve screenshots, PDFs and text extraction over an HTTP API",
+	Long: `Start an HTTP API backed by one persistent Chrome instance, or the
+instance given with --remote-debugging-port.
+
+Endpoints (all POST endpoints take a JSON body):
+  POST /screenshot  returns the image
+  POST /pdf         returns application/pdf
+  POST /extract     returns page metadata and body text (or the text of
+                    every element matching "selector") as JSON
+  POST /check       evaluates the expect* fields and returns the checks as
+                    JSON, with status 200 if all passed and 417 otherwise
+  POST /jobs        queues the work of one of the above, named by "type",
+                    and returns the job, 202 Accepted
+  GET  /jobs/{id}   returns the state of a job
+  GET  /jobs/{id}/result
+                    returns what the job's endpoint would have
+  GET  /usage       returns the usage of the calling API key (--api-keys)
+  GET  /openapi.yaml
+                    returns the OpenAPI 3 definition of the API
+  GET  /healthz     returns 200 while the server is up
+
+Request fields:
+  url        http(s) URL to load (required)
+  selector   element to capture (/screenshot) or extract (/extract)
+  delay      seconds to wait after load (default --delay)
+  timeout    seconds for the whole request, at most --timeout
+  viewport   WIDTHxHEIGHT, e.g. 1280x800
+  device     device preset, e.g. "iPhone 12"
+  darkMode   emulate prefers-color-scheme: dark
+  fullPage   capture the whole page (default true)
+  format     png, jpeg or webp (default jpeg, png with selector)
+  quality    1 to 100 for jpeg and webp (default 90)
+  js         JavaScript run after the delay
+  steps      interaction steps, as for --step
+  headers    extra HTTP headers, as an object
+  cookies    cookies, as in a --cookies-file
+  expectSelectors, expectText, expectStatus, maxLoadTime
+             assertions for /check, as for the --expect-* flags and
+             --max-load-time (e.g. "5s")
+
+Errors are returned as {"error": "..."} with status 400 for bad requests,
+504 when the request timed out and 500 otherwise. At most --max-pages pages
+are open at once; further requests wait for a free page. With
+--max-concurrent, requests beyond that many in flight, waiting ones
+included, are turned away at once with status 429 and a Retry-After header,
+so clients back off instead of piling up.
+
+On SIGTERM or an interrupt the server drains: it stops accepting
+connections, answers new requests on open ones and /healthz with 503, and
+lets in-flight requests finish for up to --drain-timeout before aborting
+them. --max-browser-lifetime restarts Chrome periodically: once it has run
+that long, new requests use a fresh Chrome and the old one is closed when
+its last request is done.
+
+Jobs do not hold a connection open while the page renders, for big PDFs
+and slow pages: clients poll GET /jobs/{id} until its status is done or
+failed, or pass "callbackUrl" to be sent the job when it has finished.
+At most --max-jobs jobs may be unfinished, each gets up to --job-timeout
+seconds, and finished jobs are kept for --job-ttl. Jobs still running on
+shutdown are drained like requests.
+
+With --api-keys FILE, every request but /healthz and /openapi.yaml must present a key of the
+file as "Authorization: Bearer KEY" or "X-API-Key: KEY", or is answered
+with 401. The file lists one key per line as NAME KEY [LIMIT/UNIT], e.g.
+"search-team 6f1c9e2a8b4d7f30 60/m"; a key with a limit making more
+requests is answered with 429 and a Retry-After header. Requests are
+logged with the name of their key, and the usage of every key is logged on
+shutdown.
+
+Without --tenant-by, all requests share Chrome's cookies, storage and cache.
+--tenant-by key or --tenant-by header:NAME gives every tenant, named by the
+API key or by the header NAME, a profile of its own: its pages keep
+cookies and storage between its requests, never see those of other
+tenants, and it only sees its own jobs. With --api-keys, tenants named by
+the header belong to the key: the same name sent with two keys names two
+tenants. At most --max-tenants profiles are kept open.`,
+	Example: `  # Start the API on port 8080 with up to 4 concurrent pages
+  that-cli-web-toolbox serve --listen :8080 --max-pages 4
+
+  # Take a screenshot through the API
+  curl -X POST localhost:8080/screenshot -d '{"url":"https://example.com","viewport":"1280x800"}' > page.jpg
+
+  # Extract the text of every heading
+  curl -X POST localhost:8080/extract -d '{"url":"https://example.com","selector":"h1, h2"}'
+
+  # Print a big PDF in the background and get told when it is done
+  curl -X POST localhost:8080/jobs -d '{"type":"pdf","url":"https://example.com/report","callbackUrl":"https://ci.example.com/hooks/pdf"}'
+
+  # Keep the sessions of the teams calling the API apart
+  that-cli-web-toolbox serve --listen :8080 --tenant-by header:X-Tenant
+
+  # Require API keys and call the API with one
+  that-cli-web-toolbox serve --listen :8080 --api-keys /etc/toolbox/api-keys
+  curl -H "Authorization: Bearer $KEY" -X POST localhost:8080/pdf -d '{"url":"https://example.com"}' > page.pdf`,
+	RunE: runServe,
+	Args: cobra.NoArgs,
+}
+
+func init() {
+	serveCmd.Flags().StringVar(&serveCfg.Listen, "listen", ":8080", "Address to listen on")
+	serveCmd.Flags().IntVar(&serveCfg.MaxPages, "max-pages", 4, "Maximum number of pages open at the same time")
+	serveCmd.Flags().IntVar(&serveCfg.MaxConcurrent, "max-concurrent", 0,
+		"Answer requests beyond this many in flight, including those waiting for a page, with 429; 0 lets them wait")
+	serveCmd.Flags().DurationVar(&serveCfg.MaxBrowserLifetime, "max-browser-lifetime", 0,
+		"Replace Chrome with a fresh instance once it has run this long, e.g. 6h; 0 keeps it")
+	serveCmd.Flags().DurationVar(&serveCfg.DrainTimeout, "drain-timeout", shutdownGrace,
+		"How long in-flight requests may take to finish on shutdown before they are aborted")
+	serveCmd.Flags().StringVar(&serveCfg.TenantBy, "tenant-by", "",
+		"Isolate tenants, named by the API key (key) or a request header (header:NAME), in profiles of their own")
+	serveCmd.Flags().IntVar(&serveCfg.MaxTenants, "max-tenants", 100,
+		"Maximum number of tenant profiles kept open; the least recently used idle one is closed beyond it")
+	serveCmd.Flags().IntVar(&serveCfg.MaxJobs, "max-jobs", 100,
+		"Maximum number of unfinished jobs of POST /jobs; further jobs are answered with 429")
+	serveCmd.Flags().IntVar(&serveCfg.JobTimeout, "job-timeout", 300, "Maximum time in seconds for one job")
+	serveCmd.Flags().DurationVar(&serveCfg.JobTTL, "job-ttl", time.Hour, "How long finished jobs and their results are kept")
+	serveCmd.Flags().StringVar(&serveCfg.APIKeys, "api-keys", "",
+		"File of API keys, one per line as NAME KEY [LIMIT/UNIT]; requests without one of them are rejected")
+	serveCmd.Flags().IntVarP(&serveCfg.Timeout, "timeout", "t", 30,
+		"Maximum time in seconds for one request, including waiting for a free page")
+	serveCmd.Flags().IntVarP(&serveCfg.Delay, "delay", "d", 2, "Default delay in seconds to ensure rendering")
+	rootCmd.AddCommand(serveCmd)
+}
+
+func runServe(cmd *cobra.Command, args []string) error {
+	setupLogging(cfg.LogLevel)
+
+	// Validate limits
+	if serveCfg.MaxPages < 1 {
+		return fmt.Errorf("--max-pages must be at least 1")
+	}
+	if serveCfg.Timeout < 1 {
+		return fmt.Errorf("--timeout must be at least 1")
+	}
+	if serveCfg.Delay < 0 {
+		return fmt.Errorf("--delay cannot be negative")
+	}
+	if serveCfg.MaxConcurrent < 0 {
+		return fmt.Errorf("--max-concurrent cannot be negative")
+	}
+	if serveCfg.MaxBrowserLifetime < 0 {
+		return fmt.Errorf("--max-browser-lifetime cannot be negative")
+	}
+	if serveCfg.MaxBrowserLifetime > 0 && cfg.RemoteDebuggingPort != "" {
+		return fmt.Errorf("--max-browser-lifetime cannot be used with --remote-debugging-port, whose browser is not started by serve")
+	}
+	if serveCfg.DrainTimeout < 0 {
+		return fmt.Errorf("--drain-timeout cannot be negative")
+	}
+	if serveCfg.MaxTenants < 1 {
+		return fmt.Errorf("--max-tenants must be at least 1")
+	}
+	if serveCfg.MaxJobs < 1 {
+		return fmt.Errorf("--max-jobs must be at least 1")
+	}
+	if serveCfg.JobTimeout < 1 {
+		return fmt.Errorf("--job-timeout must be at least 1")
+	}
+	if serveCfg.JobTTL <= 0 {
+		return fmt.Errorf("--job-ttl must be positive")
+	}
+
+	api := &apiServer{}
+	if serveCfg.APIKeys != "" {
+		keys, err := loadAPIKeys(serveCfg.APIKeys)
+		if err != nil {
+			return err
+		}
+		api.keys = keys
+		defer keys.logUsage()
+	}
+	tenants, err := parseTenancy(serveCfg.TenantBy, api.keys != nil)
+	if err != nil {
+		return err
+	}
+	api.tenants = tenants
+
+	ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// The browser and requests outlive ctx so in-flight requests can finish
+	// on shutdown
+	baseCtx, stopTracing, err := startTracing(context.Background())
+	if err != nil {
+		return err
+	}
+	defer stopTracing()
+	pool, err := chromedphelper.NewPool(baseCtx, serveCfg.MaxPages, serveCfg.Delay, cfg.RemoteDebuggingPort, "", managedBrowserOptions("")...)
+	if err != nil {
+		slog.Error("Failed to initialize browser", "error", err)
+		return fmt.Errorf("failed to initialize browser: %w", err)
+	}
+	defer pool.Close()
+	pool.MaxLifetime = serveCfg.MaxBrowserLifetime
+	pool.MaxProfiles = serveCfg.MaxTenants
+
+	api.pool = pool
+	if serveCfg.MaxConcurrent > 0 {
+		api.admit = make(chan struct{}, serveCfg.MaxConcurrent)
+	}
+	// Requests still running when the drain times out are aborted
+	requestCtx, abortRequests := context.WithCancel(baseCtx)
+	defer abortRequests()
+	api.jobs = newJobQueue(requestCtx, serveCfg.MaxJobs, serveCfg.JobTTL, time.Duration(serveCfg.JobTimeout)*time.Second)
+
+	mux := http.NewServeMux()
+	for name, h := range api.handlers() {
+		mux.HandleFunc("POST /"+name, api.handle(h))
+	}
+	mux.HandleFunc("POST /jobs", api.submitJob)
+	mux.HandleFunc("GET /jobs/{id}", api.getJob)
+	mux.HandleFunc("GET /jobs/{id}/result", api.jobResult)
+	mux.HandleFunc("GET /usage", api.usage)
+	mux.HandleFunc("GET /openapi.yaml", func(w http.ResponseWriter, r *http.Request) {
+		writeAPIData(w, "application/yaml", apiclient.Spec)
+	})
+	mux.HandleFunc("GET /healthz", func(w http.ResponseWriter, r *http.Request) {
+		// Load balancers stop routing to a draining server
+		if api.draining.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	})
+
+	srv := &http.Server{
+		Addr:              serveCfg.Listen,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+		BaseContext:       func(net.Listener) context.Context { return requestCtx },
+	}
+	serveErr := make(chan error, 1)
+	go func() {
+		slog.Info("Serving HTTP API", "address", serveCfg.Listen, "maxPages", serveCfg.MaxPages, "maxConcurrent", serveCfg.MaxConcurrent,
+			"authenticated", api.keys != nil, "tenantBy", serveCfg.TenantBy)
+		serveErr <- srv.ListenAndServe()
+	}()
+
+	select {
+	case err := <-serveErr:
+		return fmt.Errorf("failed to serve: %w", err)
+	case <-ctx.Done():
+	}
+
+	api.draining.Store(true)
+	slog.Info("Shutting down, draining in-flight requests", "timeout", serveCfg.DrainTimeout)
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), serveCfg.DrainTimeout)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		slog.Warn("In-flight requests did not finish in time, aborting them", "timeout", serveCfg.DrainTimeout)
+		abortRequests()
+		if closeErr := srv.Close(); closeErr != nil {
+			slog.Warn("Failed to close server", "error", closeErr)
+		}
+		return fmt.Errorf("failed to drain in-flight requests: %w", err)
+	}
+	if err := api.jobs.wait(shutdownCtx); err != nil {
+		slog.Warn("Jobs did not finish in time, aborting them", "timeout", serveCfg.DrainTimeout)
+		abortRequests()
+		return fmt.Errorf("failed to drain jobs: %w", err)
+	}
+	slog.Info("All requests drained")
+	return nil
+}
+
+// apiRequest is the JSON body accepted by every endpoint. Its fields are
+// those of apiclient.Request, the request of the API's contract.
+type apiRequest struct {
+	apiclient.Request
+
+	// expectations are parsed from the Expect fields by decodeRequest.
+	expectations chromedphelper.Expectations
+	// tenant is the tenant of the request for --tenant-by.
+	tenant string
+}
+
+// badRequest marks errors caused by the request rather than the page.
+type badRequest struct{ error }
+
+// apiServer serves API requests from a pool of tabs.
+type apiServer struct {
+	pool *chromedphelper.Pool
+	// admit holds a token per request in flight for --max-concurrent, or
+	// is nil without a limit.
+	admit chan struct{}
+	// draining is set on shutdown, when new requests are turned away.
+	draining atomic.Bool
+	// keys authenticates requests for --api-keys, or is nil without.
+	keys *apiKeys
+	// tenants tells the tenant of requests for --tenant-by, or is nil
+	// without.
+	tenants *tenancy
+	jobs    *jobQueue
+}
+
+// handlers returns the handler of each POST endpoint, by path and job
+// type.
+func (s *apiServer) handlers() map[string]handler {
+	return map[string]handler{
+		apiclient.JobScreenshot: s.screenshot,
+		apiclient.JobPDF:        s.pdf,
+		apiclient.JobExtract:    s.extract,
+		apiclient.JobCheck:      s.check,
+	}
+}
+
+// handler produces the response for a loaded page.
+type handler func(ctx context.Context, tab *chromedphelper.Browser, req *apiRequest, w http.ResponseWriter) error
+
+// handle decodes the request, loads the page in a tab of the pool within
+// the request's timeout and passes it to h. Errors are written as JSON.
+func (s *apiServer) handle(h handler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		if s.draining.Load() {
+			w.Header().Set("Connection", "close")
+			w.Header().Set("Retry-After", retryAfter)
+			writeAPIError(w, errDraining)
+			return
+		}
+		key, ok := s.authorize(w, r)
+		if !ok {
+			return
+		}
+		failed := true
+		if key != nil {
+			defer func() { key.record(time.Since(start), failed) }()
+		}
+		if s.admit != nil {
+			select {
+			case s.admit <- struct{}{}:
+				defer func() { <-s.admit }()
+			default:
+				slog.Warn("Too many concurrent requests, turning one away", "path", r.URL.Path, "maxConcurrent", cap(s.admit))
+				w.Header().Set("Retry-After", retryAfter)
+				writeAPIError(w, errBusy)
+				return
+			}
+		}
+
+		req, err := decodeRequest(w, r)
+		if err != nil {
+			writeAPIError(w, err)
+			return
+		}
+		if req.tenant, err = s.tenants.tenant(r, key); err != nil {
+			writeAPIError(w, err)
+			return
+		}
+
+		timeout := serveCfg.Timeout
+		if req.Timeout > 0 && req.Timeout < timeout {
+			timeout = req.Timeout
+		}
+		ctx, cancel := context.WithTimeout(r.Context(), time.Duration(timeout)*time.Second)
+		defer cancel()
+		ctx, span := tracing.Start(ctx, r.Pattern, tracing.String("url.full", req.URL))
+		defer span.End()
+
+		if err := s.serve(ctx, req, w, h); err != nil {
+			span.RecordError(err)
+			slog.Error("Request failed", "path", r.URL.Path, "url", req.URL, "key", key.name(), "tenant", req.tenant, "error", err)
+			writeAPIError(w, err)
+			return
+		}
+		failed = false
+		slog.Info("Request completed", "path", r.URL.Path, "url", req.URL, "key", key.name(), "tenant", req.tenant, "duration", time.Since(start).Round(time.Millisecond))
+	}
+}
+
+// authorize checks the API key of r against --api-keys and its rate limit,
+// writing the error when it fails. Without --api-keys every request is
+// authorized, with a nil key.
+func (s *apiServer) authorize(w http.ResponseWriter, r *http.Request) (*apiKey, bool) {
+	if s.keys == nil {
+		return nil, true
+	}
+	key, err := s.keys.authenticate(r)
+	if err != nil {
+		slog.Warn("Unauthorized request", "path", r.URL.Path, "remote", r.RemoteAddr)
+		w.Header().Set("WWW-Authenticate", `Bearer realm="that-cli-web-toolbox"`)
+		writeAPIError(w, err)
+		return nil, false
+	}
+	if ok, wait := key.allow(time.Now()); !ok {
+		slog.Warn("Rate limit exceeded", "path", r.URL.Path, "key", key.Name, "retryAfter", wait.Round(time.Millisecond))
+		w.Header().Set("Retry-After", retryAfterSeconds(wait))
+		writeAPIError(w, errRateLimited)
+		return nil, false
+	}
+	return key, true
+}
+
+// usage responds with the usage of the calling API key.
+func (s *apiServer) usage(w http.ResponseWriter, r *http.Request) {
+	if s.keys == nil {
+		writeAPIError(w, badRequest{errors.New("usage is only accounted with --api-keys")})
+		return
+	}
+	key, err := s.keys.authenticate(r)
+	if err != nil {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="that-cli-web-toolbox"`)
+		writeAPIError(w, err)
+		return
+	}
+	data, err := json.Marshal(key.Usage())
+	if err != nil {
+		writeAPIError(w, err)
+		return
+	}
+	writeAPIData(w, "application/json", data)
+}
+
+// serve runs h on a freshly prepared tab for req.
+func (s *apiServer) serve(ctx context.Context, req *apiRequest, w http.ResponseWriter, h handler) error {
+	steps := make([]chromedphelper.Step, 0, len(req.Steps))
+	for _, spec := range req.Steps {
+		step, err := chromedphelper.ParseStep(spec)
+		if err != nil {
+			return badRequest{err}
+		}
+		steps = append(steps, step)
+	}
+	emulation, err := parseEmulation(&Config{Viewport: req.Viewport, Device: req.Device, DarkMode: req.DarkMode})
+	if err != nil {
+		return badRequest{err}
+	}
+
+	var opts []chromedphelper.TabOption
+	if req.tenant != "" {
+		opts = append(opts, chromedphelper.WithTabProfile(req.tenant))
+	}
+	tab, err := s.pool.Acquire(ctx, req.URL, opts...)
+	if err != nil {
+		return fmt.Errorf("failed to open tab: %w", err)
+	}
+	defer s.pool.Release(tab)
+
+	tab.Delay = serveCfg.Delay
+	if req.Delay != nil {
+		tab.Delay = *req.Delay
+	}
+	tab.JSCode = req.JS
+	tab.Steps = steps
+	tab.Headers = req.Headers
+	tab.Cookies = make([]chromedphelper.Cookie, len(req.Cookies))
+	for i, c := range req.Cookies {
+		tab.Cookies[i] = chromedphelper.Cookie(c)
+	}
+	tab.Emulation = emulation
+
+	if err := tab.NavigateAndPrepare(ctx); err != nil {
+		return fmt.Errorf("failed to load page: %w", err)
+	}
+	return h(ctx, tab, req, w)
+}
+
+// decodeRequest reads and validates the JSON body.
+func decodeRequest(w http.ResponseWriter, r *http.Request) (*apiRequest, error) {
+	var req apiRequest
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRequestBody))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&req); err != nil {
+		return nil, badRequest{fmt.Errorf("invalid request body: %w", err)}
+	}
+	if err := req.validate(); err != nil {
+		return nil, err
+	}
+	return &req, nil
+}
+
+// validate checks the request's fields and parses its expectations.
+func (req *apiRequest) validate() error {
+	// Only remote pages may be loaded; file:// would expose the server's disk
+	u, err := url.Parse(req.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return badRequest{fmt.Errorf("url must be an absolute http or https URL, got %q", req.URL)}
+	}
+	if req.Delay != nil && *req.Delay < 0 {
+		return badRequest{fmt.Errorf("delay cannot be negative")}
+	}
+	if req.Timeout < 0 {
+		return badRequest{fmt.Errorf("timeout cannot be negative")}
+	}
+	if req.Format != "" {
+		if _, err := chromedphelper.ParseImageFormat(req.Format); err != nil {
+			return badRequest{err}
+		}
+	}
+	if req.Quality < 0 || req.Quality > 100 {
+		return badRequest{fmt.Errorf("quality must be between 1 and 100")}
+	}
+	if format, _ := chromedphelper.ParseImageFormat(req.Format); req.OmitBackground && format == chromedphelper.JPEG {
+		return badRequest{fmt.Errorf("omitBackground needs format png or webp, since jpeg has no transparency")}
+	}
+
+	req.expectations = chromedphelper.Expectations{Selectors: req.ExpectSelectors, Status: req.ExpectStatus}
+	if req.ExpectText != "" {
+		if req.expectations.Text, err = regexp.Compile(req.ExpectText); err != nil {
+			return badRequest{fmt.Errorf("invalid expectText regexp: %w", err)}
+		}
+	}
+	if req.MaxLoadTime != "" {
+		if req.expectations.MaxLoadTime, err = time.ParseDuration(req.MaxLoadTime); err != nil {
+			return badRequest{fmt.Errorf("invalid maxLoadTime: %w", err)}
+		}
+	}
+	return nil
+}
+
+// screenshot responds with a screenshot of the page or of req.Selector.
+func (s *apiServer) screenshot(ctx context.Context, tab *chromedphelper.Browser, req *apiRequest, w http.ResponseWriter) error {
+	opts := chromedphelper.ScreenshotOptions{
+		Format:         chromedphelper.JPEG,
+		Quality:        90,
+		OmitBackground: req.OmitBackground,
+		BeyondViewport: req.CaptureBeyondViewport,
+		FromSurface:    req.FromSurface,
+	}
+	if req.Selector != "" || req.OmitBackground {
+		opts.Format = chromedphelper.PNG
+	}
+	if req.Format != "" {
+		// Validated in decodeRequest
+		opts.Format, _ = chromedphelper.ParseImageFormat(req.Format)
+	}
+	if req.Quality > 0 {
+		opts.Quality = req.Quality
+	}
+
+	var data []byte
+	var err error
+	switch {
+	case req.Selector != "":
+		data, err = tab.ScreenshotElementAs(ctx, req.Selector, opts)
+	case req.FullPage == nil || *req.FullPage:
+		data, err = tab.ScreenshotFullPage(ctx, opts)
+	default:
+		data, err = tab.ScreenshotViewport(ctx, opts)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to take screenshot: %w", err)
+	}
+	return writeAPIData(w, opts.Format.ContentType(), data)
+}
+
+// pdf responds with the page printed to PDF.
+func (s *apiServer) pdf(ctx context.Context, tab *chromedphelper.Browser, req *apiRequest, w http.ResponseWriter) error {
+	data, err := tab.PrintToPDF(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to print PDF: %w", err)
+	}
+	return writeAPIData(w, "application/pdf", data)
+}
+
+// extract responds with a Result holding the page metadata and either the
+// body text or the text of every element matching req.Selector.
+func (s *apiServer) extract(ctx context.Context, tab *chromedphelper.Browser, req *apiRequest, w http.ResponseWriter) error {
+	result := &chromedphelper.Result{Target: req.URL, Redirects: tab.Redirects()}
+
+	meta, err := tab.GetPageMetadata(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get page metadata: %w", err)
+	}
+	result.Page = meta
+
+	if req.Selector != "" {
+		texts, err := tab.GetTextsBySelector(ctx, req.Selector)
+		if err != nil {
+			return fmt.Errorf("failed to get text for selector %q: %w", req.Selector, err)
+		}
+		result.Selectors = []chromedphelper.SelectorResult{{Selector: req.Selector, Elements: texts}}
+	} else {
+		if result.Body, err = tab.GetBodyText(ctx); err != nil {
+			return fmt.Errorf("failed to get body text: %w", err)
+		}
+	}
+
+	data, err := json.Marshal(result)
+	if err != nil {
+		return err
+	}
+	return writeAPIData(w, "application/json", data)
+}
+
+// check responds with a Result holding the outcome of req's expectations.
+// The status is 417 Expectation Failed when any of them did not hold.
+func (s *apiServer) check(ctx context.Context, tab *chromedphelper.Browser, req *apiRequest, w http.ResponseWriter) error {
+	if req.expectations.Empty() {
+		return badRequest{fmt.Errorf("at least one of expectSelectors, expectText, expectStatus or maxLoadTime is required")}
+	}
+	checks, err := tab.Check(ctx, req.expectations)
+	if err != nil {
+		return fmt.Errorf("failed to check page: %w", err)
+	}
+	result := &chromedphelper.Result{Target: req.URL, Redirects: tab.Redirects(), Checks: checks}
+
+	data, err := json.Marshal(result)
+	if err != nil {
+		return err
+	}
+	if chromedphelper.CheckFailures(checks) > 0 {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusExpectationFailed)
+		if _, err := w.Write(data); err != nil {
+			slog.Warn("Failed to write response", "error", err)
+		}
+		return nil
+	}
+	return writeAPIData(w, "application/json", data)
+}
+
+// writeAPIData writes a successful response.
+func writeAPIData(w http.ResponseWriter, contentType string, data []byte) error {
+	w.Header().Set("Content-Type", contentType)
+	if _, err := w.Write(data); err != nil {
+		slog.Warn("Failed to write response", "error", err)
+	}
+	return nil
+}
+
+// writeAPIError writes err as a JSON error with a status matching its cause.
+func writeAPIError(w http.ResponseWriter, err error) {
+	status := http.StatusInternalServerError
+	var bad badRequest
+	var pathology *chromedphelper.PathologyError
+	var crash *chromedphelper.CrashError
+	switch {
+	case errors.As(err, &bad):
+		status = http.StatusBadRequest
+	case errors.Is(err, errUnauthorized):
+		status = http.StatusUnauthorized
+	case errors.Is(err, errJobNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, errJobPending):
+		status = http.StatusConflict
+	case errors.Is(err, errBusy), errors.Is(err, errRateLimited), errors.Is(err, errQueueFull),
+		errors.Is(err, chromedphelper.ErrTooManyProfiles):
+		status = http.StatusTooManyRequests
+	case errors.Is(err, errDraining):
+		status = http.StatusServiceUnavailable
+	case errors.As(err, &pathology), errors.As(err, &crash):
+		// The target misbehaves, not the API
+		status = http.StatusBadGateway
+	case errors.Is(err, chromedphelper.ErrSelectorNotFound):
+		status = http.StatusUnprocessableEntity
+	case errors.Is(err, chromedphelper.ErrChromeNotFound), errors.Is(err, chromedphelper.ErrRemoteUnreachable):
+		status = http.StatusServiceUnavailable
+	case errors.Is(err, context.DeadlineExceeded):
+		status = http.StatusGatewayTimeout
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(map[string]string{"error": err.Error()}); err != nil {
+		slog.Warn("Failed to write error response", "error", err)
+	}
+}
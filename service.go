@@ -0,0 +1,524 @@
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"os/user"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/pesarkhobeee/that-cli-web-toolbox/pkg/textnorm"
+)
+
+const (
+	// serviceDir names the runtime directory of system units and the data
+	// directory of Windows tasks.
+	serviceDir = "that-cli-web-toolbox"
+	// serviceSocket is the file name of the daemon's default socket.
+	serviceSocket = "toolbox.sock"
+	// serviceListen is the default address of serve as a service: the API
+	// has no authentication, so it is not exposed to the network unasked.
+	serviceListen = "127.0.0.1:8080"
+)
+
+// serviceModes are the commands that can be installed as a service.
+var serviceModes = []string{"daemon", "serve"}
+
+type serviceConfig struct {
+	Name  string
+	RunAs string
+}
+
+var serviceCfg serviceConfig
+
+var serviceCmd = &cobra.Command{
+	Use:   "service",
+	Short: "Install the daemon or serve mode as a systemd unit or Windows task",
+	Long: `Install, start, stop and uninstall the daemon or serve subcommand as a
+service that starts with the machine, instead of writing unit files by hand.
+
+On Linux, install writes a systemd unit and enables it. Run as root, it is a
+system unit in /etc/systemd/system, run as the user given with --run-as
+(default: the user who ran sudo), since Chrome does not run as root without
+giving up its sandbox. Otherwise it is a user unit in
+~/.config/systemd/user, which starts when the user logs in, or at boot after
+"loginctl enable-linger".
+
+On Windows, where a service must speak the service control protocol, the
+service is a scheduled task of the current user, run when they log on with
+their own rights, like a user unit. The command line is kept in the task
+itself.
+
+Flags after -- are passed to the installed command. Without them, daemon
+listens on toolbox.sock in the runtime directory (/run/that-cli-web-toolbox
+for system units, $XDG_RUNTIME_DIR for user units,
+%LOCALAPPDATA%\that-cli-web-toolbox on Windows), and serve listens on 127.0.0.1:8080. The service restarts when it
+fails.`,
+	Example: `  # Install and start the daemon for the current user
+  that-cli-web-toolbox service install daemon
+  that-cli-web-toolbox service start daemon
+
+  # Install the HTTP API system-wide with 8 pages, run as the user "toolbox"
+  sudo that-cli-web-toolbox service install serve --run-as toolbox -- --listen :8080 --max-pages 8
+
+  # Remove it again
+  sudo that-cli-web-toolbox service uninstall serve`,
+}
+
+var serviceInstallCmd = &cobra.Command{
+	Use:   "install MODE [-- FLAGS...]",
+	Short: "Install and enable daemon or serve as a service",
+	RunE:  runServiceInstall,
+	Args:  cobra.MinimumNArgs(1),
+}
+
+var serviceUninstallCmd = &cobra.Command{
+	Use:   "uninstall MODE",
+	Short: "Stop and remove the service",
+	RunE:  runServiceUninstall,
+	Args:  cobra.ExactArgs(1),
+}
+
+var serviceStartCmd = &cobra.Command{
+	Use:   "start MODE",
+	Short: "Start the installed service",
+	RunE:  func(cmd *cobra.Command, args []string) error { return controlService(args[0], "start") },
+	Args:  cobra.ExactArgs(1),
+}
+
+var serviceStopCmd = &cobra.Command{
+	Use:   "stop MODE",
+	Short: "Stop the running service",
+	RunE:  func(cmd *cobra.Command, args []string) error { return controlService(args[0], "stop") },
+	Args:  cobra.ExactArgs(1),
+}
+
+func init() {
+	serviceCmd.PersistentFlags().StringVar(&serviceCfg.Name, "name", "",
+		"Name of the unit or task, to install a mode more than once (default: that-cli-web-toolbox-MODE)")
+	serviceInstallCmd.Flags().StringVar(&serviceCfg.RunAs, "run-as", "",
+		"User a system unit runs as (default: the user who ran sudo)")
+	serviceCmd.AddCommand(serviceInstallCmd, serviceUninstallCmd, serviceStartCmd, serviceStopCmd)
+	rootCmd.AddCommand(serviceCmd)
+}
+
+// serviceName returns the unit or task name of mode.
+func serviceName(mode string) (string, error) {
+	if !containsString(serviceModes, mode) {
+		return "", fmt.Errorf("unknown service mode %q (expected %s)", mode, strings.Join(serviceModes, " or "))
+	}
+	if serviceCfg.Name != "" {
+		return serviceCfg.Name, nil
+	}
+	return "that-cli-web-toolbox-" + mode, nil
+}
+
+func runServiceInstall(cmd *cobra.Command, args []string) error {
+	setupLogging(cfg.LogLevel)
+	mode, extra := args[0], args[1:]
+	name, err := serviceName(mode)
+	if err != nil {
+		return err
+	}
+
+	switch runtime.GOOS {
+	case "linux":
+		err = installSystemd(name, mode, extra)
+	case "windows":
+		err = installWindowsTask(name, mode, extra)
+	default:
+		err = fmt.Errorf("installing a service is not supported on %s", runtime.GOOS)
+	}
+	if err != nil {
+		slog.Error("Failed to install service", "name", name, "error", err)
+		return fmt.Errorf("failed to install %s: %w", name, err)
+	}
+	fmt.Printf("Start it with: %s service start %s\n", filepath.Base(os.Args[0]), serviceArgs(mode))
+	return nil
+}
+
+func runServiceUninstall(cmd *cobra.Command, args []string) error {
+	setupLogging(cfg.LogLevel)
+	name, err := serviceName(args[0])
+	if err != nil {
+		return err
+	}
+
+	switch runtime.GOOS {
+	case "linux":
+		err = uninstallSystemd(name)
+	case "windows":
+		err = uninstallWindowsTask(name)
+	default:
+		err = fmt.Errorf("installing a service is not supported on %s", runtime.GOOS)
+	}
+	if err != nil {
+		slog.Error("Failed to uninstall service", "name", name, "error", err)
+		return fmt.Errorf("failed to uninstall %s: %w", name, err)
+	}
+	fmt.Printf("Uninstalled %s\n", name)
+	return nil
+}
+
+// controlService starts or stops the service of mode.
+func controlService(mode, action string) error {
+	setupLogging(cfg.LogLevel)
+	name, err := serviceName(mode)
+	if err != nil {
+		return err
+	}
+
+	switch runtime.GOOS {
+	case "linux":
+		err = systemctl(os.Geteuid() == 0, action, name)
+	case "windows":
+		task := map[string]string{"start": "/Run", "stop": "/End"}[action]
+		err = runQuiet(exec.Command("schtasks", task, "/TN", name))
+	default:
+		err = fmt.Errorf("installing a service is not supported on %s", runtime.GOOS)
+	}
+	if err != nil {
+		slog.Error("Failed to "+action+" service", "name", name, "error", err)
+		return fmt.Errorf("failed to %s %s: %w", action, name, err)
+	}
+	fmt.Printf("%s %s\n", map[string]string{"start": "Started", "stop": "Stopped"}[action], name)
+	return nil
+}
+
+// serviceArgs returns mode with --name, as start and stop need it.
+func serviceArgs(mode string) string {
+	if serviceCfg.Name != "" {
+		return mode + " --name " + serviceCfg.Name
+	}
+	return mode
+}
+
+// serviceCommand returns the command line of the service: this executable
+// running mode with extra, preceded by the defaults for the flags extra
+// leaves out. socket is the daemon's default socket.
+func serviceCommand(mode string, extra []string, socket string) ([]string, error) {
+	exe, err := os.Executable()
+	if err != nil {
+		return nil, fmt.Errorf("failed to locate the executable: %w", err)
+	}
+	if resolved, err := filepath.EvalSymlinks(exe); err == nil {
+		exe = resolved
+	}
+	command := []string{exe, mode}
+	switch {
+	case mode == "daemon" && !hasFlag(extra, "socket"):
+		command = append(command, "--socket", socket)
+		fmt.Printf("Clients connect with: --via %s\n", socket)
+	case mode == "serve" && !hasFlag(extra, "listen"):
+		command = append(command, "--listen", serviceListen)
+	}
+	return append(command, extra...), nil
+}
+
+// hasFlag reports whether args set the long flag name.
+func hasFlag(args []string, name string) bool {
+	for _, arg := range args {
+		if arg == "--"+name || strings.HasPrefix(arg, "--"+name+"=") {
+			return true
+		}
+	}
+	return false
+}
+
+// systemdUnitDir returns where units are installed: system units when
+// running as root, the user's units otherwise.
+func systemdUnitDir(system bool) (string, error) {
+	if system {
+		return "/etc/systemd/system", nil
+	}
+	config := os.Getenv("XDG_CONFIG_HOME")
+	if config == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		config = filepath.Join(home, ".config")
+	}
+	return filepath.Join(config, "systemd", "user"), nil
+}
+
+// systemctl runs systemctl on the system or the user's service manager.
+func systemctl(system bool, args ...string) error {
+	if !system {
+		args = append([]string{"--user"}, args...)
+	}
+	return runQuiet(exec.Command("systemctl", args...))
+}
+
+// installSystemd writes, loads and enables the unit of name.
+func installSystemd(name, mode string, extra []string) error {
+	system := os.Geteuid() == 0
+	runAs := serviceCfg.RunAs
+	if system && runAs == "" {
+		runAs = os.Getenv("SUDO_USER")
+	}
+	if system && (runAs == "" || runAs == "root") {
+		return fmt.Errorf("a system unit needs --run-as USER, since Chrome does not run as root")
+	}
+	if !system && runAs != "" {
+		return fmt.Errorf("--run-as only applies to system units; run install as root")
+	}
+	if runAs != "" {
+		if _, err := user.Lookup(runAs); err != nil {
+			return fmt.Errorf("invalid --run-as: %w", err)
+		}
+	}
+
+	socket := filepath.Join("/run", serviceDir, serviceSocket)
+	if !system {
+		runtimeDir := os.Getenv("XDG_RUNTIME_DIR")
+		if runtimeDir == "" {
+			runtimeDir = filepath.Join("/run/user", strconv.Itoa(os.Getuid()))
+		}
+		socket = filepath.Join(runtimeDir, serviceSocket)
+	}
+	command, err := serviceCommand(mode, extra, socket)
+	if err != nil {
+		return err
+	}
+
+	dir, err := systemdUnitDir(system)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	path := filepath.Join(dir, name+".service")
+	slog.Debug("Writing systemd unit", "file", path)
+	if err := os.WriteFile(path, []byte(systemdUnit(mode, command, system, runAs)), 0o644); err != nil {
+		return err
+	}
+	if err := systemctl(system, "daemon-reload"); err != nil {
+		return fmt.Errorf("wrote %s but could not reload systemd: %w", path, err)
+	}
+	if err := systemctl(system, "enable", name); err != nil {
+		return fmt.Errorf("wrote %s but could not enable it: %w", path, err)
+	}
+	scope := "user unit"
+	if system {
+		scope = "system unit running as " + runAs
+	}
+	fmt.Printf("Installed %s as a systemd %s in %s\n", name, scope, path)
+	return nil
+}
+
+// systemdUnit renders the unit running command.
+func systemdUnit(mode string, command []string, system bool, runAs string) string {
+	quoted := make([]string, len(command))
+	for i, arg := range command {
+		quoted[i] = systemdQuote(arg)
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "[Unit]\nDescription=%s %s\n", handlerName, mode)
+	// User managers cannot order themselves after system targets
+	if system {
+		b.WriteString("Wants=network-online.target\nAfter=network-online.target\n")
+	}
+	fmt.Fprintf(&b, "\n[Service]\nType=simple\nExecStart=%s\nRestart=on-failure\nRestartSec=5\n", strings.Join(quoted, " "))
+	if system {
+		fmt.Fprintf(&b, "User=%s\n", runAs)
+		if mode == "daemon" {
+			fmt.Fprintf(&b, "RuntimeDirectory=%s\n", serviceDir)
+		}
+	}
+	target := "default.target"
+	if system {
+		target = "multi-user.target"
+	}
+	fmt.Fprintf(&b, "\n[Install]\nWantedBy=%s\n", target)
+	return b.String()
+}
+
+// systemdQuote quotes arg for ExecStart, escaping systemd's specifiers and
+// variable expansion.
+func systemdQuote(arg string) string {
+	r := strings.NewReplacer("%", "%%", "$", "$$")
+	if arg != "" && !strings.ContainsAny(arg, " \t\n\"'\\;") {
+		return r.Replace(arg)
+	}
+	r = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "%", "%%", "$", "$$")
+	return `"` + r.Replace(arg) + `"`
+}
+
+func uninstallSystemd(name string) error {
+	system := os.Geteuid() == 0
+	// A unit that is not running or not enabled is fine
+	if err := systemctl(system, "disable", "--now", name); err != nil {
+		slog.Debug("Could not disable unit", "name", name, "error", err)
+	}
+	dir, err := systemdUnitDir(system)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(filepath.Join(dir, name+".service")); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return systemctl(system, "daemon-reload")
+}
+
+// windowsServiceDir returns the directory of the daemon's default socket
+// in the current user's local data, which other users cannot write to.
+func windowsServiceDir() (string, error) {
+	local := os.Getenv("LOCALAPPDATA")
+	if local == "" {
+		var err error
+		if local, err = os.UserCacheDir(); err != nil {
+			return "", err
+		}
+	}
+	return filepath.Join(local, serviceDir), nil
+}
+
+// installWindowsTask registers a task running the command of mode as the
+// current user, with their own rights, when they log on. The command is
+// part of the task definition, given to schtasks as XML since /TR cannot
+// hold long command lines, so no file the task runs can be changed after.
+func installWindowsTask(name, mode string, extra []string) error {
+	dir, err := windowsServiceDir()
+	if err != nil {
+		return err
+	}
+	command, err := serviceCommand(mode, extra, filepath.Join(dir, serviceSocket))
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return err
+	}
+	current, err := user.Current()
+	if err != nil {
+		return fmt.Errorf("failed to look up the current user: %w", err)
+	}
+	definition, err := windowsTask(current.Username, command)
+	if err != nil {
+		return err
+	}
+	f, err := os.CreateTemp("", name+"-*.xml")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	_, err = f.Write(definition)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	if err := runQuiet(exec.Command("schtasks", "/Create", "/TN", name, "/XML", f.Name(), "/F")); err != nil {
+		return fmt.Errorf("could not register the task: %w", err)
+	}
+	fmt.Printf("Installed %s as a scheduled task run when %s logs on\n", name, current.Username)
+	return nil
+}
+
+// windowsTaskXML is a task definition of the Task Scheduler schema.
+type windowsTaskXML struct {
+	XMLName     xml.Name `xml:"http://schemas.microsoft.com/windows/2004/02/mit/task Task"`
+	Version     string   `xml:"version,attr"`
+	Description string   `xml:"RegistrationInfo>Description"`
+	LogonUser   string   `xml:"Triggers>LogonTrigger>UserId"`
+	Principal   struct {
+		ID        string `xml:"id,attr"`
+		UserID    string `xml:"UserId"`
+		LogonType string `xml:"LogonType"`
+		RunLevel  string `xml:"RunLevel"`
+	} `xml:"Principals>Principal"`
+	Settings struct {
+		MultipleInstancesPolicy    string `xml:"MultipleInstancesPolicy"`
+		DisallowStartIfOnBatteries bool   `xml:"DisallowStartIfOnBatteries"`
+		StopIfGoingOnBatteries     bool   `xml:"StopIfGoingOnBatteries"`
+		ExecutionTimeLimit         string `xml:"ExecutionTimeLimit"`
+		RestartInterval            string `xml:"RestartOnFailure>Interval"`
+		RestartCount               int    `xml:"RestartOnFailure>Count"`
+	}
+	Actions struct {
+		Context   string `xml:"Context,attr"`
+		Command   string `xml:"Exec>Command"`
+		Arguments string `xml:"Exec>Arguments,omitempty"`
+	}
+}
+
+// windowsTask returns the definition of a task running command as
+// username at logon, with least privilege, restarted when it fails, in the
+// UTF-16 schtasks reads.
+func windowsTask(username string, command []string) ([]byte, error) {
+	var task windowsTaskXML
+	task.Version = "1.2"
+	task.Description = "that-cli-web-toolbox " + command[1]
+	task.LogonUser = username
+	task.Principal.ID = "Author"
+	task.Principal.UserID = username
+	task.Principal.LogonType = "InteractiveToken"
+	task.Principal.RunLevel = "LeastPrivilege"
+	task.Settings.MultipleInstancesPolicy = "IgnoreNew"
+	task.Settings.ExecutionTimeLimit = "PT0S"
+	task.Settings.RestartInterval = "PT1M"
+	task.Settings.RestartCount = 999
+	task.Actions.Context = "Author"
+	task.Actions.Command = command[0]
+	args := make([]string, len(command)-1)
+	for i, arg := range command[1:] {
+		args[i] = windowsArg(arg)
+	}
+	task.Actions.Arguments = strings.Join(args, " ")
+	data, err := xml.MarshalIndent(task, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode task: %w", err)
+	}
+	return textnorm.Encode(`<?xml version="1.0" encoding="UTF-16"?>`+"\n"+string(data)+"\n", "utf-16le", "crlf")
+}
+
+// windowsArg quotes arg for a Windows command line, as parsed by
+// CommandLineToArgvW and the C runtime: like syscall.EscapeArg, which is
+// only built on Windows.
+func windowsArg(arg string) string {
+	if arg == "" {
+		return `""`
+	}
+	if !strings.ContainsAny(arg, " \t\n\v\"") {
+		return arg
+	}
+	var b strings.Builder
+	b.WriteByte('"')
+	slashes := 0
+	for i := 0; i < len(arg); i++ {
+		switch c := arg[i]; c {
+		case '\\':
+			slashes++
+		case '"':
+			// Backslashes before a quote are escaped, and the quote too
+			b.WriteString(strings.Repeat(`\`, slashes+1))
+			slashes = 0
+		default:
+			slashes = 0
+		}
+		b.WriteByte(arg[i])
+	}
+	// Backslashes before the closing quote are escaped
+	b.WriteString(strings.Repeat(`\`, slashes))
+	b.WriteByte('"')
+	return b.String()
+}
+
+func uninstallWindowsTask(name string) error {
+	// A task that is not running cannot be ended, which is fine
+	if err := runQuiet(exec.Command("schtasks", "/End", "/TN", name)); err != nil {
+		slog.Debug("Could not end task", "name", name, "error", err)
+	}
+	return runQuiet(exec.Command("schtasks", "/Delete", "/TN", name, "/F"))
+}
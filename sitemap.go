@@ -0,0 +1,155 @@
+package main
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pesarkhobeee/that-cli-web-toolbox/pkg/events"
+	"github.com/pesarkhobeee/that-cli-web-toolbox/pkg/sink"
+)
+
+// sitemapEntry is a successfully loaded page listed by --emit-sitemap.
+type sitemapEntry struct {
+	Loc string
+	// LastMod comes from the document's Last-Modified header and is zero
+	// when the server did not send one.
+	LastMod time.Time
+}
+
+// sitemapAction records the final URL and Last-Modified header of the
+// page's main document.
+type sitemapAction struct {
+	noopAction
+
+	mu        sync.Mutex
+	documents []events.RequestFinished
+}
+
+func (a *sitemapAction) Name() string             { return "sitemap" }
+func (a *sitemapAction) Enabled(cfg *Config) bool { return cfg.EmitSitemap != "" }
+func (a *sitemapAction) Flags() []string          { return []string{"--emit-sitemap"} }
+
+func (a *sitemapAction) Prepare(ctx context.Context, run *Run) error {
+	// The document response arrives during navigation
+	stream := run.Browser.Events()
+	go func() {
+		for ev := range stream {
+			req, ok := ev.(events.RequestFinished)
+			if !ok || req.ResourceType != "Document" {
+				continue
+			}
+			a.mu.Lock()
+			a.documents = append(a.documents, req)
+			a.mu.Unlock()
+		}
+	}()
+	return nil
+}
+
+func (a *sitemapAction) Execute(ctx context.Context, run *Run) error {
+	meta, err := run.Browser.GetPageMetadata(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get page metadata: %w", err)
+	}
+
+	entry := &sitemapEntry{Loc: meta.URL}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for _, doc := range a.documents {
+		if normalizeURL(doc.URL) != normalizeURL(meta.URL) {
+			continue
+		}
+		if doc.Failed || doc.Status >= 400 {
+			slog.Warn("Page not added to sitemap", "url", meta.URL, "status", doc.Status, "error", doc.ErrorText)
+			return nil
+		}
+		if lastMod := headerValue(doc.ResponseHeaders, "Last-Modified"); lastMod != "" {
+			if t, err := http.ParseTime(lastMod); err == nil {
+				entry.LastMod = t.UTC()
+			} else {
+				slog.Debug("Ignoring invalid Last-Modified header", "url", meta.URL, "value", lastMod)
+			}
+		}
+	}
+	slog.Debug("Sitemap entry recorded", "loc", entry.Loc, "lastmod", entry.LastMod)
+	run.Sitemap = entry
+	return nil
+}
+
+// headerValue returns the value of the named header, matched
+// case-insensitively as HTTP/2 lower-cases header names.
+func headerValue(headers map[string]string, name string) string {
+	for k, v := range headers {
+		if strings.EqualFold(k, name) {
+			return v
+		}
+	}
+	return ""
+}
+
+// sitemapURLSet is the XML document defined by sitemaps.org.
+type sitemapURLSet struct {
+	XMLName xml.Name     `xml:"urlset"`
+	Xmlns   string       `xml:"xmlns,attr"`
+	URLs    []sitemapURL `xml:"url"`
+}
+
+type sitemapURL struct {
+	Loc     string `xml:"loc"`
+	LastMod string `xml:"lastmod,omitempty"`
+}
+
+// writeSitemap writes an XML sitemap of the http(s) entries, sorted and
+// de-duplicated by URL, to the artifact sink under name.
+func writeSitemap(ctx context.Context, artifacts sink.Sink, name string, entries []*sitemapEntry) error {
+	urlSet := sitemapURLSet{Xmlns: "http://www.sitemaps.org/schemas/sitemap/0.9"}
+	seen := make(map[string]bool)
+	for _, e := range entries {
+		if e == nil || !(strings.HasPrefix(e.Loc, "http://") || strings.HasPrefix(e.Loc, "https://")) {
+			continue
+		}
+		key := normalizeURL(e.Loc)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		u := sitemapURL{Loc: e.Loc}
+		if !e.LastMod.IsZero() {
+			u.LastMod = e.LastMod.Format(time.RFC3339)
+		}
+		urlSet.URLs = append(urlSet.URLs, u)
+	}
+	sort.Slice(urlSet.URLs, func(i, j int) bool { return urlSet.URLs[i].Loc < urlSet.URLs[j].Loc })
+
+	data, err := xml.MarshalIndent(urlSet, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode sitemap: %w", err)
+	}
+	data = append([]byte(xml.Header), append(data, '\n')...)
+
+	return writeReport(ctx, artifacts, "Sitemap", name, "application/xml", data)
+}
+
+// writeReport writes a report covering the whole run, such as a sitemap,
+// to the artifact sink. Unlike writeArtifact, the name is not prefixed and
+// the report is not attributed to a single target's result.
+func writeReport(ctx context.Context, artifacts sink.Sink, label, name, contentType string, data []byte) error {
+	slog.Debug("Saving "+label, "fileName", name, "size", len(data))
+	location, err := artifacts.Write(ctx, name, contentType, data)
+	if err != nil {
+		slog.Error("Failed to save "+label, "fileName", name, "error", err)
+		return fmt.Errorf("failed to save %s %q: %w", label, name, err)
+	}
+	slog.Info(label+" saved successfully", "fileName", name, "location", location)
+	if location != "" && !structuredOutput() {
+		fmt.Printf("%s saved as %s\n", label, location)
+	}
+	return nil
+}
@@ -0,0 +1,238 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	chromedphelper "github.com/pesarkhobeee/that-cli-web-toolbox/pkg/chromedp"
+)
+
+// settleWindow is how long after the delay requests of a page still count
+// towards the delay learned for its site, so a delay that is too short
+// can grow.
+const settleWindow = time.Second
+
+// siteSettings is what --site-settings keeps about a site.
+type siteSettings struct {
+	// Delay is the learned rendering delay in seconds, at most --delay. It
+	// is nil until a page of the site loaded successfully, and after one
+	// failed.
+	Delay *int `json:"delay,omitempty"`
+	// Headers are sent with every request of the site's pages, unless
+	// --header sets the same header. They are not learned; add them to
+	// the file by hand.
+	Headers map[string]string `json:"headers,omitempty"`
+	// ConsentSelector is the cookie banner button found and clicked on
+	// the site, which is clicked again on later page loads.
+	ConsentSelector string `json:"consentSelector,omitempty"`
+	// Runs counts the page loads of the site.
+	Runs    int       `json:"runs"`
+	LastRun time.Time `json:"lastRun"`
+}
+
+// siteSettingsStore holds the per-site settings of --site-settings, by
+// host, and writes them back to its file with save.
+type siteSettingsStore struct {
+	path string
+
+	mu    sync.Mutex
+	sites map[string]*siteSettings
+	dirty bool
+}
+
+// loadSiteSettings reads the settings file at path. A missing file is an
+// empty store, which save creates.
+func loadSiteSettings(path string) (*siteSettingsStore, error) {
+	s := &siteSettingsStore{path: path, sites: make(map[string]*siteSettings)}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		slog.Debug("Site settings file does not exist yet", "file", path)
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &s.sites); err != nil {
+		return nil, fmt.Errorf("invalid site settings file %s: %w", path, err)
+	}
+	for host, site := range s.sites {
+		if site == nil {
+			return nil, fmt.Errorf("invalid site settings file %s: %q has no settings", path, host)
+		}
+	}
+	slog.Debug("Site settings loaded", "file", path, "sites", len(s.sites))
+	return s, nil
+}
+
+// siteKey returns the host, with its port, the settings of target are
+// kept under, or "" for targets that are not http(s) URLs.
+func siteKey(target string) string {
+	u, err := url.Parse(target)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return ""
+	}
+	return strings.ToLower(u.Host)
+}
+
+// siteVisit is a page load applyTarget prepared, for learn.
+type siteVisit struct {
+	key string
+	// delay is the delay the page was loaded with.
+	delay int
+	// probed is set when the page looked for any known consent banner.
+	probed bool
+}
+
+// applyTarget sets the stored settings of target's site on b: the learned
+// delay, the headers --header does not set, and which consent banner to
+// dismiss. Targets under a --consent-states state keep their banner. It
+// returns nil when nothing is to be learned from the page load.
+func (s *siteSettingsStore) applyTarget(b *chromedphelper.Browser, target batchTarget) *siteVisit {
+	if s == nil {
+		return nil
+	}
+	key := siteKey(target.URL)
+	if key == "" {
+		return nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	visit := &siteVisit{key: key, delay: b.Delay}
+	site := s.sites[key]
+	if site == nil {
+		site = &siteSettings{}
+	}
+	if site.Delay != nil && *site.Delay < b.Delay {
+		slog.Debug("Using learned delay", "target", target.URL, "delay", *site.Delay)
+		b.Delay = *site.Delay
+		visit.delay = *site.Delay
+	}
+	if len(site.Headers) > 0 {
+		headers := make(map[string]string, len(b.Headers)+len(site.Headers))
+		for name, value := range site.Headers {
+			headers[name] = value
+		}
+		for name, value := range b.Headers {
+			for stored := range headers {
+				if strings.EqualFold(stored, name) {
+					delete(headers, stored)
+				}
+			}
+			headers[name] = value
+		}
+		b.Headers = headers
+	}
+	if target.Consent == "" {
+		if site.ConsentSelector != "" {
+			b.DismissConsent = []string{site.ConsentSelector}
+		} else {
+			b.DismissConsent = chromedphelper.ConsentButtons
+			visit.probed = true
+		}
+	}
+	return visit
+}
+
+// learn updates the settings of the visited site from the page load,
+// which failed when err is set. A failed page load drops the learned
+// delay, so the next one gets all of --delay; after a successful one it
+// follows how long the page kept loading, growing at once but shrinking
+// by at most a second per page load.
+func (s *siteSettingsStore) learn(ctx context.Context, visit *siteVisit, b *chromedphelper.Browser, err error) {
+	if s == nil || visit == nil {
+		return
+	}
+
+	var settle time.Duration
+	measured := false
+	if err == nil {
+		window := time.Duration(visit.delay)*time.Second + settleWindow
+		var measureErr error
+		if settle, measureErr = b.SettleTime(ctx, window); measureErr != nil {
+			slog.Debug("Could not measure how long the page kept loading", "target", b.TargetURL, "error", measureErr)
+		}
+		measured = measureErr == nil
+	}
+	consent := b.ConsentDismissed()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	site := s.sites[visit.key]
+	if site == nil {
+		site = &siteSettings{}
+		s.sites[visit.key] = site
+	}
+	site.Runs++
+	site.LastRun = time.Now().UTC()
+	s.dirty = true
+
+	switch {
+	case err != nil:
+		site.Delay = nil
+	case measured:
+		delay := min(int(math.Ceil(settle.Seconds())), cfg.Delay)
+		if site.Delay != nil && delay < *site.Delay-1 {
+			delay = *site.Delay - 1
+		}
+		site.Delay = &delay
+		slog.Debug("Learned delay", "site", visit.key, "delay", delay, "settle", settle)
+	}
+	if visit.probed && consent != "" {
+		slog.Info("Learned consent banner of site", "site", visit.key, "selector", consent)
+		site.ConsentSelector = consent
+	}
+}
+
+// save writes the settings back to the file if they changed, replacing it
+// at once so runs reading it never see a partial file. Of concurrent runs
+// with the same file, the last one to finish wins.
+func (s *siteSettingsStore) save() error {
+	if s == nil {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.dirty {
+		return nil
+	}
+
+	data, err := json.MarshalIndent(s.sites, "", "  ")
+	if err != nil {
+		return err
+	}
+	// The temporary file is only readable by its owner, as headers may
+	// hold credentials
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".*")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := os.Remove(tmp.Name()); err != nil && !errors.Is(err, os.ErrNotExist) {
+			slog.Warn("failed to remove temporary site settings file", "file", tmp.Name(), "error", err)
+		}
+	}()
+	if _, err := tmp.Write(append(data, '\n')); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), s.path); err != nil {
+		return err
+	}
+	s.dirty = false
+	slog.Debug("Site settings saved", "file", s.path, "sites", len(s.sites))
+	return nil
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/pesarkhobeee/that-cli-web-toolbox/pkg/soft404"
+)
+
+// soft404Action flags pages that --detect-soft-404 finds are error pages
+// served with a success status, and fails them like a failed check.
+type soft404Action struct{ noopAction }
+
+func (a *soft404Action) Name() string             { return "soft-404" }
+func (a *soft404Action) Enabled(cfg *Config) bool { return cfg.DetectSoft404 }
+func (a *soft404Action) Flags() []string          { return []string{"--detect-soft-404"} }
+
+func (a *soft404Action) Execute(ctx context.Context, run *Run) error {
+	slog.Info("Checking for a soft 404")
+	signals, err := run.Browser.Soft404Signals(ctx)
+	if err != nil {
+		return err
+	}
+	verdict := soft404.Detect(*signals)
+	slog.Debug("Soft 404 scored", "url", signals.URL, "score", verdict.Score, "reasons", verdict.Reasons)
+	run.Result.Soft404 = &verdict
+	return nil
+}
+
+func (a *soft404Action) Report(ctx context.Context, run *Run) error {
+	verdict := run.Result.Soft404
+	// Batch runs list the verdict in the summary instead
+	if !run.Batch && !structuredFormat(run.Config.OutputFormat) {
+		fmt.Printf("Soft 404: %s\n", formatSoft404(verdict))
+	}
+	if verdict.Soft404 {
+		return &exitError{code: exitAssertion, err: fmt.Errorf("page is a soft 404: %s", strings.Join(verdict.Reasons, "; "))}
+	}
+	return nil
+}
+
+// formatSoft404 renders a verdict for the report and the batch summary.
+func formatSoft404(v *soft404.Verdict) string {
+	outcome := "no"
+	if v.Soft404 {
+		outcome = "yes"
+	}
+	if len(v.Reasons) == 0 {
+		return fmt.Sprintf("%s (score %d of %d)", outcome, v.Score, soft404.Threshold)
+	}
+	return fmt.Sprintf("%s (score %d of %d: %s)", outcome, v.Score, soft404.Threshold, strings.Join(v.Reasons, "; "))
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"github.com/pesarkhobeee/that-cli-web-toolbox/pkg/events"
+	"github.com/pesarkhobeee/that-cli-web-toolbox/pkg/sourcemap"
+)
+
+// resolveException sets the original source positions of ex and its stack
+// frames for --resolve-sourcemaps and logs the resolved stack trace.
+func resolveException(ctx context.Context, resolver *sourcemap.Resolver, ex *events.Exception) {
+	resolve := func(url string, line, column int64) *events.SourcePosition {
+		pos, ok := resolver.Resolve(ctx, url, line, column)
+		if !ok {
+			return nil
+		}
+		return &events.SourcePosition{Source: pos.Source, Line: pos.Line, Column: pos.Column, Name: pos.Name}
+	}
+
+	ex.Original = resolve(ex.URL, ex.Line, ex.Column)
+	resolved := ex.Original != nil
+	for i := range ex.StackTrace {
+		frame := &ex.StackTrace[i]
+		frame.Original = resolve(frame.URL, frame.Line, frame.Column)
+		resolved = resolved || frame.Original != nil
+	}
+	if !resolved {
+		return
+	}
+
+	slog.Error("JavaScript exception resolved through source maps", "text", ex.Text, "at", formatSourcePosition(ex.Original))
+	for _, frame := range ex.StackTrace {
+		if frame.Original == nil {
+			slog.Info("Stack trace frame", "function", frame.Function, "at", fmt.Sprintf("%s:%d:%d", frame.URL, frame.Line+1, frame.Column+1))
+			continue
+		}
+		function := frame.Function
+		if frame.Original.Name != "" {
+			function = frame.Original.Name
+		}
+		slog.Info("Stack trace frame", "function", function, "at", formatSourcePosition(frame.Original))
+	}
+}
+
+// formatSourcePosition renders pos as file:line:column with one-based line
+// and column, as editors and stack traces show them.
+func formatSourcePosition(pos *events.SourcePosition) string {
+	if pos == nil {
+		return ""
+	}
+	return fmt.Sprintf("%s:%d:%d", pos.Source, pos.Line+1, pos.Column+1)
+}
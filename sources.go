@@ -0,0 +1,249 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pesarkhobeee/that-cli-web-toolbox/pkg/browserdata"
+)
+
+// defaultProfile is the value of a bare --from-history.
+const defaultProfile = "default"
+
+// targetSourcesEnabled reports whether --from-bookmarks or --from-history
+// was given.
+func targetSourcesEnabled(cfg *Config) bool {
+	return cfg.FromBookmarks != "" || cfg.FromHistory != ""
+}
+
+// validateTargetSources checks the flags selecting targets from bookmarks
+// and history and returns the parsed --source-match and --source-since.
+func validateTargetSources(cfg *Config, now time.Time) (*regexp.Regexp, time.Time, error) {
+	if !targetSourcesEnabled(cfg) {
+		for _, f := range []struct {
+			flag string
+			set  bool
+		}{{"--source-match", cfg.SourceMatch != ""}, {"--source-since", cfg.SourceSince != ""}, {"--pick", cfg.Pick}} {
+			if f.set {
+				slog.Error(f.flag + " specified without --from-bookmarks or --from-history")
+				return nil, time.Time{}, fmt.Errorf("%s requires --from-bookmarks or --from-history", f.flag)
+			}
+		}
+		return nil, time.Time{}, nil
+	}
+
+	var match *regexp.Regexp
+	if cfg.SourceMatch != "" {
+		var err error
+		if match, err = regexp.Compile(cfg.SourceMatch); err != nil {
+			slog.Error("Invalid --source-match regexp", "pattern", cfg.SourceMatch, "error", err)
+			return nil, time.Time{}, fmt.Errorf("invalid --source-match regexp: %w", err)
+		}
+	}
+	var since time.Time
+	if cfg.SourceSince != "" {
+		var err error
+		if since, err = parseSince(cfg.SourceSince, now); err != nil {
+			slog.Error("Invalid --source-since", "value", cfg.SourceSince, "error", err)
+			return nil, time.Time{}, err
+		}
+	}
+	return match, since, nil
+}
+
+// parseSince returns the time a --source-since value names: a duration
+// back from now, where d and w count days and weeks (e.g. 7d, 36h), or a
+// date or time in the local zone (e.g. 2026-10-01, 2026-10-01T09:00).
+func parseSince(value string, now time.Time) (time.Time, error) {
+	for unit, day := range map[string]time.Duration{"d": 24 * time.Hour, "w": 7 * 24 * time.Hour} {
+		if n, ok := strings.CutSuffix(value, unit); ok {
+			if count, err := strconv.Atoi(n); err == nil && count >= 0 {
+				return now.Add(-time.Duration(count) * day), nil
+			}
+		}
+	}
+	if d, err := time.ParseDuration(value); err == nil && d >= 0 {
+		return now.Add(-d), nil
+	}
+	for _, layout := range []string{"2006-01-02", "2006-01-02T15:04", "2006-01-02T15:04:05"} {
+		if t, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid --source-since %q (expected a duration such as 7d or 36h, or a date such as 2026-10-01)", value)
+}
+
+// loadTargetSources returns the URLs of the bookmarks and history entries
+// selected by --source-match, --source-since and --pick, most recent
+// history first and bookmarks in file order.
+func loadTargetSources(cfg *Config, match *regexp.Regexp, since time.Time) ([]string, error) {
+	var entries []browserdata.Entry
+	if cfg.FromBookmarks != "" {
+		slog.Debug("Reading bookmarks", "file", cfg.FromBookmarks)
+		bookmarks, err := browserdata.ReadBookmarks(cfg.FromBookmarks)
+		if err != nil {
+			slog.Error("Failed to read bookmarks", "file", cfg.FromBookmarks, "error", err)
+			return nil, fmt.Errorf("failed to read bookmarks %q: %w", cfg.FromBookmarks, err)
+		}
+		entries = append(entries, bookmarks...)
+	}
+	if cfg.FromHistory != "" {
+		profile := cfg.FromHistory
+		if profile == defaultProfile {
+			var err error
+			if profile, err = browserdata.DefaultChromeProfile(); err != nil {
+				return nil, fmt.Errorf("failed to locate the Chrome profile: %w", err)
+			}
+		}
+		slog.Debug("Reading history", "profile", profile)
+		history, err := browserdata.ReadChromeHistory(profile)
+		if err != nil {
+			slog.Error("Failed to read history", "profile", profile, "error", err)
+			return nil, fmt.Errorf("failed to read Chrome history from %q: %w", profile, err)
+		}
+		entries = append(entries, history...)
+	}
+
+	selected := filterEntries(entries, match, since)
+	slog.Info("Selected targets from bookmarks and history", "entries", len(entries), "selected", len(selected))
+	if len(selected) == 0 {
+		return nil, fmt.Errorf("none of the %d bookmarks and history entries match", len(entries))
+	}
+	if cfg.Pick {
+		var err error
+		if selected, err = pickEntries(os.Stdin, os.Stderr, selected); err != nil {
+			return nil, err
+		}
+	}
+
+	urls := make([]string, 0, len(selected))
+	for _, e := range selected {
+		urls = append(urls, e.URL)
+	}
+	return urls, nil
+}
+
+// filterEntries returns the http(s) entries whose URL, title or folder
+// matches match and that were added or visited at or after since, once per
+// URL.
+func filterEntries(entries []browserdata.Entry, match *regexp.Regexp, since time.Time) []browserdata.Entry {
+	seen := make(map[string]bool)
+	var selected []browserdata.Entry
+	for _, e := range entries {
+		// Skip javascript: bookmarklets, chrome:// pages and the like
+		if !strings.HasPrefix(e.URL, "http://") && !strings.HasPrefix(e.URL, "https://") {
+			continue
+		}
+		if match != nil && !match.MatchString(e.URL) && !match.MatchString(e.Title) && !match.MatchString(e.Folder) {
+			continue
+		}
+		if !since.IsZero() && e.Time.Before(since) {
+			continue
+		}
+		if seen[e.URL] {
+			continue
+		}
+		seen[e.URL] = true
+		selected = append(selected, e)
+	}
+	return selected
+}
+
+// pickEntries lists entries on w and returns those chosen on r, as numbers
+// and ranges such as 1-3,7; an empty answer or "all" keeps every entry.
+func pickEntries(r io.Reader, w io.Writer, entries []browserdata.Entry) ([]browserdata.Entry, error) {
+	for i, e := range entries {
+		when := "          "
+		if !e.Time.IsZero() {
+			when = e.Time.Local().Format("2006-01-02")
+		}
+		title := e.Title
+		if e.Folder != "" {
+			title = e.Folder + ": " + title
+		}
+		fmt.Fprintf(w, "%4d  %s  %s\n      %s\n", i+1, when, title, e.URL)
+	}
+	fmt.Fprintf(w, "Capture which pages? (e.g. 1-3,7; empty for all, q to quit): ")
+
+	line, err := bufio.NewReader(r).ReadString('\n')
+	if err != nil && (err != io.EOF || line == "") {
+		return nil, fmt.Errorf("no selection read: %w", err)
+	}
+	answer := strings.TrimSpace(line)
+	switch strings.ToLower(answer) {
+	case "", "all":
+		return entries, nil
+	case "q", "quit":
+		return nil, fmt.Errorf("no pages selected")
+	}
+
+	var picked []browserdata.Entry
+	chosen := make(map[int]bool)
+	for _, part := range strings.Split(answer, ",") {
+		part = strings.TrimSpace(part)
+		first, last, isRange := strings.Cut(part, "-")
+		if !isRange {
+			last = first
+		}
+		from, err1 := strconv.Atoi(strings.TrimSpace(first))
+		to, err2 := strconv.Atoi(strings.TrimSpace(last))
+		if err1 != nil || err2 != nil || from < 1 || to > len(entries) || from > to {
+			return nil, fmt.Errorf("invalid selection %q (expected numbers from 1 to %d, e.g. 1-3,7)", part, len(entries))
+		}
+		for i := from; i <= to; i++ {
+			if !chosen[i] {
+				chosen[i] = true
+				picked = append(picked, entries[i-1])
+			}
+		}
+	}
+	return picked, nil
+}
+
+// collectInputs returns the targets of the command line args, --input-file,
+// --from-bookmarks and --from-history, or, without any, the page of the
+// --load-state state, which it returns too.
+func collectInputs(cfg *Config, args []string) ([]string, *savedState, error) {
+	inputs := args
+	if cfg.InputFile != "" {
+		lines, err := readInputFile(cfg.InputFile)
+		if err != nil {
+			slog.Error("Failed to read input file", "file", cfg.InputFile, "error", err)
+			return nil, nil, fmt.Errorf("failed to read input file %q: %w", cfg.InputFile, err)
+		}
+		inputs = append(inputs, lines...)
+	}
+	match, since, err := validateTargetSources(cfg, time.Now())
+	if err != nil {
+		return nil, nil, err
+	}
+	if targetSourcesEnabled(cfg) {
+		urls, err := loadTargetSources(cfg, match, since)
+		if err != nil {
+			return nil, nil, err
+		}
+		inputs = append(inputs, urls...)
+	}
+	var state *savedState
+	if cfg.LoadState != "" {
+		if state, err = loadState(cfg.LoadState); err != nil {
+			return nil, nil, err
+		}
+		if len(inputs) == 0 && state.URL != "" {
+			slog.Info("Resuming the page of the saved state", "url", state.URL)
+			inputs = append(inputs, state.URL)
+		}
+	}
+	if len(inputs) == 0 {
+		slog.Error("No target URL or file path provided")
+		return nil, nil, fmt.Errorf("target URL or file path is required")
+	}
+	return inputs, state, nil
+}
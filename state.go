@@ -0,0 +1,204 @@
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"time"
+
+	chromedphelper "github.com/pesarkhobeee/that-cli-web-toolbox/pkg/chromedp"
+)
+
+// Files of a --save-state archive. cookies.json is written like
+// --save-cookies, so it can be extracted and used with --cookies-file.
+const (
+	stateManifestFile = "state.json"
+	stateCookiesFile  = "cookies.json"
+	stateStorageFile  = "storage.json"
+)
+
+// stateVersion is the version of the archive format, increased when it
+// changes incompatibly.
+const stateVersion = 1
+
+// stateManifest describes a saved state: the page it was saved on.
+type stateManifest struct {
+	Version int       `json:"version"`
+	URL     string    `json:"url"`
+	SavedAt time.Time `json:"savedAt"`
+}
+
+// savedState is the page state of --save-state and --load-state: the
+// page's cookies, the web storage of its origin and its URL.
+type savedState struct {
+	URL     string
+	Cookies []chromedphelper.Cookie
+	Storage *chromedphelper.WebStorage
+}
+
+// saveStateAction writes the page's state to --save-state after the JS and
+// steps have run, so a later run can resume the session with --load-state.
+type saveStateAction struct{ noopAction }
+
+func (a *saveStateAction) Name() string             { return "save-state" }
+func (a *saveStateAction) Enabled(cfg *Config) bool { return cfg.SaveState != "" }
+func (a *saveStateAction) Flags() []string          { return []string{"--save-state"} }
+
+func (a *saveStateAction) Execute(ctx context.Context, run *Run) error {
+	slog.Info("Saving page state")
+	var state savedState
+	var err error
+	if state.URL, err = run.Browser.CurrentURL(ctx); err != nil {
+		return err
+	}
+	if state.Cookies, err = run.Browser.GetCookies(ctx); err != nil {
+		return err
+	}
+	if state.Storage, err = run.Browser.GetStorage(ctx); err != nil {
+		return err
+	}
+	data, err := encodeState(&state, time.Now())
+	if err != nil {
+		return fmt.Errorf("failed to encode page state: %w", err)
+	}
+
+	// Like --save-cookies, the state holds credentials: write it straight
+	// to a private local file rather than through the sink
+	path := run.Config.SaveState
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		slog.Error("Failed to save page state", "file", path, "error", err)
+		return fmt.Errorf("failed to save page state %q: %w", path, err)
+	}
+	slog.Info("Page state saved successfully", "file", path, "url", state.URL, "cookies", len(state.Cookies),
+		"localStorage", len(state.Storage.Local), "sessionStorage", len(state.Storage.Session))
+	if !structuredFormat(run.Config.OutputFormat) {
+		fmt.Printf("Page state saved as %s\n", path)
+	}
+	return nil
+}
+
+// encodeState returns state as a gzipped tar archive.
+func encodeState(state *savedState, now time.Time) ([]byte, error) {
+	files := []struct {
+		name  string
+		value any
+	}{
+		{stateManifestFile, stateManifest{Version: stateVersion, URL: state.URL, SavedAt: now.UTC()}},
+		{stateCookiesFile, state.Cookies},
+		{stateStorageFile, state.Storage},
+	}
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for _, f := range files {
+		data, err := json.MarshalIndent(f.value, "", "  ")
+		if err != nil {
+			return nil, err
+		}
+		data = append(data, '\n')
+		hdr := &tar.Header{Name: f.name, Mode: 0o600, Size: int64(len(data)), ModTime: now}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return nil, err
+		}
+		if _, err := tw.Write(data); err != nil {
+			return nil, err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return nil, err
+	}
+	if err := gz.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// loadState reads a --save-state archive.
+func loadState(path string) (*savedState, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		slog.Error("Failed to read page state", "file", path, "error", err)
+		return nil, fmt.Errorf("failed to read page state %q: %w", path, err)
+	}
+	defer func() {
+		if err := f.Close(); err != nil {
+			slog.Warn("failed to close page state", "file", path, "error", err)
+		}
+	}()
+	state, err := decodeState(f)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse page state %q: %w", path, err)
+	}
+	slog.Debug("Page state loaded", "file", path, "url", state.URL, "cookies", len(state.Cookies))
+	return state, nil
+}
+
+// decodeState parses the gzipped tar archive written by encodeState.
+func decodeState(r io.Reader) (*savedState, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+	tr := tar.NewReader(gz)
+	var manifest *stateManifest
+	state := &savedState{}
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		var target any
+		switch hdr.Name {
+		case stateManifestFile:
+			manifest = &stateManifest{}
+			target = manifest
+		case stateCookiesFile:
+			target = &state.Cookies
+		case stateStorageFile:
+			target = &state.Storage
+		default:
+			// Left for newer versions of the format
+			continue
+		}
+		if err := json.NewDecoder(tr).Decode(target); err != nil {
+			return nil, fmt.Errorf("invalid %s: %w", hdr.Name, err)
+		}
+	}
+	if manifest == nil {
+		return nil, fmt.Errorf("not a page state archive: %s is missing", stateManifestFile)
+	}
+	if manifest.Version > stateVersion {
+		return nil, fmt.Errorf("page state of version %d needs a newer release (this one reads up to version %d)", manifest.Version, stateVersion)
+	}
+	state.URL = manifest.URL
+	return state, nil
+}
+
+// validateSavedState checks that --save-cookies, --save-state and
+// --export-auth have a single target, since the cookies saved from several
+// tabs would overwrite each other.
+func validateSavedState(cfg *Config, targets int) error {
+	if cfg.SaveCookies != "" && targets > 1 {
+		slog.Error("--save-cookies specified with several targets")
+		return fmt.Errorf("--save-cookies requires a single target")
+	}
+	if cfg.SaveState != "" && targets > 1 {
+		slog.Error("--save-state specified with several targets")
+		return fmt.Errorf("--save-state requires a single target")
+	}
+	if cfg.ExportAuth != "" && targets > 1 {
+		slog.Error("--export-auth specified with several targets")
+		return fmt.Errorf("--export-auth requires a single target")
+	}
+	return nil
+}
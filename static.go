@@ -0,0 +1,351 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/andybalholm/cascadia"
+
+	chromedphelper "github.com/pesarkhobeee/that-cli-web-toolbox/pkg/chromedp"
+	"github.com/pesarkhobeee/that-cli-web-toolbox/pkg/htmltext"
+	"github.com/pesarkhobeee/that-cli-web-toolbox/pkg/sink"
+)
+
+// maxStaticBytes caps the HTML read for a target without --max-bytes.
+const maxStaticBytes = 10 << 20
+
+// errRender is returned by runStaticTarget when a target has to be loaded
+// in the browser: without --no-browser and --auto, or, as an escalation,
+// when --auto finds the static HTML is not enough.
+var errRender = errors.New("target must be rendered in the browser")
+
+// escalation is the errRender of a target --auto fetched but found to need
+// the browser, with the reason why.
+type escalation struct{ reason string }
+
+func (e *escalation) Error() string        { return errRender.Error() + ": " + e.reason }
+func (e *escalation) Is(target error) bool { return target == errRender }
+
+// recordEscalation notes in the result of the browser run why --auto
+// rendered the target, if err is an escalation.
+func recordEscalation(run *Run, err error) {
+	var e *escalation
+	if run != nil && errors.As(err, &e) {
+		run.Result.Escalated = e.reason
+	}
+}
+
+// mountPoints are the elements single-page app frameworks (React,
+// Next.js, Vue, Nuxt, Gatsby, Svelte, Angular) render into.
+const mountPoints = "#root, #app, #__next, #__nuxt, #___gatsby, #svelte, [data-reactroot], [ng-app], [ng-version], app-root"
+
+// scriptRendered returns why the page looks like its scripts render its
+// content, or "" when its HTML seems complete: it has no text, a mount
+// point of a framework is empty, or a <noscript> asks for JavaScript.
+func scriptRendered(doc *goquery.Document) string {
+	// The parser always inserts a body
+	if htmltext.Text(doc.Find("body").Get(0)) == "" {
+		return "the HTML has no text"
+	}
+	reason := ""
+	doc.Find(mountPoints).EachWithBreak(func(_ int, s *goquery.Selection) bool {
+		if htmltext.Text(s.Get(0)) != "" {
+			return true
+		}
+		name := goquery.NodeName(s)
+		if id, ok := s.Attr("id"); ok {
+			name += "#" + id
+		}
+		reason = fmt.Sprintf("framework mount point %s is empty", name)
+		return false
+	})
+	if reason != "" {
+		return reason
+	}
+	doc.Find("noscript").EachWithBreak(func(_ int, s *goquery.Selection) bool {
+		if strings.Contains(strings.ToLower(htmltext.Text(s.Get(0))), "javascript") {
+			reason = "a <noscript> asks for JavaScript"
+			return false
+		}
+		return true
+	})
+	return reason
+}
+
+// staticSelectors are the --gettextbycssselector selectors compiled for
+// --no-browser and --auto, in the order of the flags. They are nil when
+// --auto renders every target because a selector is beyond cascadia.
+var staticSelectors []cascadia.Selector
+
+// staticMode reports whether targets may be handled without a browser.
+func staticMode() bool {
+	return (cfg.NoBrowser || cfg.Auto) && staticSelectors != nil
+}
+
+// validateStatic checks that --no-browser and --auto are only combined
+// with what can be done without rendering, and compiles the selectors.
+func validateStatic(cfg *Config, pipeline []Action, setup *pageSetup) error {
+	if !cfg.NoBrowser && !cfg.Auto {
+		return nil
+	}
+	if cfg.NoBrowser && cfg.Auto {
+		return fmt.Errorf("--no-browser and --auto are mutually exclusive, use only one")
+	}
+	flag := "--no-browser"
+	if cfg.Auto {
+		flag = "--auto"
+	}
+	for _, a := range pipeline {
+		// A sqlite: sink's history only records the pages that are rendered
+		if a.Name() != "selector" && a.Name() != "redact-pii" && a.Name() != "history" {
+			return fmt.Errorf("%s only supports --gettextbycssselector, not the %s action", flag, a.Name())
+		}
+	}
+	browserOnly := []struct {
+		set  bool
+		name string
+	}{
+		{cfg.JS != "" || cfg.JSFile != "", "--js and --js-file"},
+		{len(setup.Steps) > 0, "interaction steps"},
+		{setup.Ready != nil, "--ready-strategy"},
+		{len(cfg.ConsentStates) > 0, "--consent-states"},
+		{cfg.OnlyLang != "", "--only-lang"},
+		{cfg.AsGooglebot, "--as-googlebot"},
+		{cfg.Tor, "--tor"},
+		{cfg.ProxyPool != "", "--proxy-pool"},
+		{cfg.Untrusted, "--untrusted"},
+		{cfg.ReplayHAR != "", "--replay-har"},
+	}
+	for _, o := range browserOnly {
+		if o.set {
+			return fmt.Errorf("%s cannot be used with %s, which need the browser", flag, o.name)
+		}
+	}
+
+	selectors := make([]cascadia.Selector, 0, len(cfg.GetTextByCssSelector))
+	for _, s := range cfg.GetTextByCssSelector {
+		sel, err := cascadia.Compile(s)
+		if err != nil && cfg.Auto {
+			// Chrome may know the selector, or report it invalid itself
+			slog.Info("Selector needs the browser, rendering every target", "selector", s, "reason", err)
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("%s cannot evaluate selector %q: %w", flag, s, err)
+		}
+		selectors = append(selectors, sel)
+	}
+	staticSelectors = selectors
+	return nil
+}
+
+// runStaticTarget extracts the --gettextbycssselector text of target from
+// its HTML, fetched with a plain HTTP client. It returns errRender when the
+// target has to be loaded in the browser instead. Like runBatchTarget, the
+// returned Run is never nil otherwise.
+func runStaticTarget(ctx context.Context, target batchTarget, setup *pageSetup, prefix string, batch bool, artifacts, text sink.Sink) (*Run, error) {
+	if !staticMode() {
+		return nil, errRender
+	}
+	run := &Run{
+		Config:    &cfg,
+		Artifacts: artifacts,
+		Text:      text,
+		Prefix:    prefix,
+		Batch:     batch,
+		Result:    &chromedphelper.Result{Target: target.URL, Locale: target.Locale, Consent: target.Consent, Static: true},
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(cfg.Timeout)*time.Second)
+	defer cancel()
+
+	doc, reason, err := fetchStatic(ctx, target, setup)
+	if err != nil {
+		return run, err
+	}
+	if reason == "" && doc != nil {
+		if r := scriptRendered(doc); r != "" && cfg.Auto {
+			reason = r
+		} else if r != "" {
+			slog.Warn("Target seems to render its content with scripts, which --no-browser does not run", "target", target.URL, "reason", r)
+		}
+	}
+	// Elements missing from the HTML may be rendered by the page's scripts
+	if reason == "" && cfg.Auto {
+		for i, sel := range staticSelectors {
+			if doc.FindMatcher(sel).Length() == 0 {
+				reason = fmt.Sprintf("selector %q matches nothing in the HTML", cfg.GetTextByCssSelector[i])
+				break
+			}
+		}
+	}
+	if reason != "" {
+		if cfg.NoBrowser {
+			return run, fmt.Errorf("cannot extract text without a browser: %s", reason)
+		}
+		slog.Info("Rendering target in the browser", "target", target.URL, "reason", reason)
+		return nil, &escalation{reason: reason}
+	}
+
+	for i, sel := range staticSelectors {
+		texts := []string{}
+		for _, n := range doc.FindMatcher(sel).Nodes {
+			if t := htmltext.Text(n); t != "" {
+				if t, err = normalizeText(ctx, run, t); err != nil {
+					return run, err
+				}
+				texts = append(texts, t)
+			}
+		}
+		slog.Debug("Extracted text without a browser", "selector", cfg.GetTextByCssSelector[i], "elements", len(texts))
+		run.Result.Selectors = append(run.Result.Selectors, chromedphelper.SelectorResult{
+			Selector: cfg.GetTextByCssSelector[i],
+			Elements: texts,
+		})
+	}
+	slog.Info("Extracted text without a browser", "target", target.URL)
+	if err := (&selectorAction{}).Report(ctx, run); err != nil {
+		return run, err
+	}
+	if cfg.RedactPII {
+		return run, (&redactAction{}).Report(ctx, run)
+	}
+	return run, nil
+}
+
+// fetchStatic fetches and parses the HTML of target with the headers,
+// cookies and credentials the browser would send. A non-empty reason tells
+// why the response cannot be handled without rendering it.
+func fetchStatic(ctx context.Context, target batchTarget, setup *pageSetup) (doc *goquery.Document, reason string, err error) {
+	limit := int64(maxStaticBytes)
+	if setup.MaxBytes > 0 {
+		limit = setup.MaxBytes
+	}
+
+	u, err := url.Parse(target.URL)
+	if err != nil {
+		return nil, "", fmt.Errorf("invalid target %q: %w", target.URL, err)
+	}
+	if u.Scheme == "file" {
+		switch strings.ToLower(filepath.Ext(u.Path)) {
+		case ".html", ".htm", ".xhtml":
+		default:
+			return nil, "it is not an HTML file", nil
+		}
+		f, err := os.Open(u.Path)
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to read %q: %w", u.Path, err)
+		}
+		defer f.Close()
+		doc, err := goquery.NewDocumentFromReader(io.LimitReader(f, limit))
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to read %q: %w", u.Path, err)
+		}
+		return doc, "", nil
+	}
+
+	req, err := newTargetRequest(ctx, target, setup, u)
+	if err != nil {
+		return nil, "", err
+	}
+	if req.Header.Get("Accept") == "" {
+		req.Header.Set("Accept", "text/html,application/xhtml+xml;q=0.9,*/*;q=0.8")
+	}
+	client := newTargetClient(setup)
+	slog.Debug("Fetching target without a browser", "target", target.URL)
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to fetch %q: %w", target.URL, err)
+	}
+	defer resp.Body.Close()
+
+	mediaType, params, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if mediaType != "text/html" && mediaType != "application/xhtml+xml" {
+		return nil, fmt.Sprintf("it is served as %q, not HTML", mediaType), nil
+	}
+	if charset := strings.ToLower(params["charset"]); charset != "" && charset != "utf-8" && charset != "utf8" && charset != "us-ascii" {
+		return nil, fmt.Sprintf("it is served in charset %s", charset), nil
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, limit))
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to read %q: %w", target.URL, err)
+	}
+	slog.Debug("Fetched target without a browser", "target", target.URL, "status", resp.StatusCode, "size", len(data))
+	doc, err = goquery.NewDocumentFromReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to parse %q: %w", target.URL, err)
+	}
+	return doc, "", nil
+}
+
+// newTargetRequest returns a GET request of target, parsed as u, with the
+// headers, cookies and credentials the browser would send.
+func newTargetRequest(ctx context.Context, target batchTarget, setup *pageSetup, u *url.URL) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target.URL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("invalid target %q: %w", target.URL, err)
+	}
+	if target.Locale != "" {
+		req.Header.Set("Accept-Language", target.Locale)
+	}
+	for name, value := range setup.Headers {
+		req.Header.Set(name, value)
+	}
+	if setup.BasicAuth != nil {
+		req.SetBasicAuth(setup.BasicAuth.Username, setup.BasicAuth.Password)
+	}
+	for _, c := range setup.Cookies {
+		if cookieMatches(c, u) {
+			req.AddCookie(&http.Cookie{Name: c.Name, Value: c.Value})
+		}
+	}
+	return req, nil
+}
+
+// newTargetClient returns an HTTP client for requests of newTargetRequest,
+// following redirects only where --allow and --allow-hosts let the
+// browser go.
+func newTargetClient(setup *pageSetup) *http.Client {
+	client := newHTTPClient(time.Duration(cfg.Timeout) * time.Second)
+	client.CheckRedirect = func(r *http.Request, via []*http.Request) error {
+		if len(via) >= 10 {
+			return errors.New("stopped after 10 redirects")
+		}
+		if !setup.Filter.Allowed(r.URL.String()) || !setup.AllowedHosts.Allowed(r.URL.String()) {
+			return fmt.Errorf("redirect to %s is not allowed", r.URL)
+		}
+		return nil
+	}
+	return client
+}
+
+// cookieMatches reports whether the browser would send c to u. Cookies
+// without a domain are set for the target, so they match it.
+func cookieMatches(c chromedphelper.Cookie, u *url.URL) bool {
+	if c.Secure && u.Scheme != "https" {
+		return false
+	}
+	if domain := strings.TrimPrefix(strings.ToLower(c.Domain), "."); domain != "" {
+		host := strings.ToLower(u.Hostname())
+		if host != domain && !strings.HasSuffix(host, "."+domain) {
+			return false
+		}
+	}
+	path := u.Path
+	if path == "" {
+		path = "/"
+	}
+	return c.Path == "" || strings.HasPrefix(path, c.Path)
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+
+	chromedphelper "github.com/pesarkhobeee/that-cli-web-toolbox/pkg/chromedp"
+)
+
+// loadSteps parses the steps from --steps-file followed by the --step flags.
+func loadSteps(specs []string, file string) ([]chromedphelper.Step, error) {
+	if file != "" {
+		lines, err := readStepsFile(file)
+		if err != nil {
+			slog.Error("Failed to read steps file", "file", file, "error", err)
+			return nil, fmt.Errorf("failed to read steps file %q: %w", file, err)
+		}
+		specs = append(lines, specs...)
+	}
+
+	steps := make([]chromedphelper.Step, 0, len(specs))
+	for _, spec := range specs {
+		step, err := chromedphelper.ParseStep(spec)
+		if err != nil {
+			return nil, err
+		}
+		steps = append(steps, step)
+	}
+	return steps, nil
+}
+
+// stepSpecs returns the --step flags followed by the steps written as
+// --click-at and then --tap-at.
+func stepSpecs(cfg *Config) []string {
+	specs := append([]string(nil), cfg.Steps...)
+	for _, point := range cfg.ClickAt {
+		specs = append(specs, string(chromedphelper.StepClickAt)+":"+point)
+	}
+	for _, point := range cfg.TapAt {
+		specs = append(specs, string(chromedphelper.StepTapAt)+":"+point)
+	}
+	return specs
+}
+
+// readStepsFile returns the steps listed in path, one KIND:ARGS per line.
+// The file may also be written as a YAML list of strings; leading "- "
+// markers and surrounding quotes are removed. Blank lines and lines
+// starting with # are skipped.
+func readStepsFile(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := f.Close(); err != nil {
+			slog.Warn("failed to close steps file", "file", path, "error", err)
+		}
+	}()
+
+	var specs []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") || line == "---" {
+			continue
+		}
+		if rest, ok := strings.CutPrefix(line, "- "); ok {
+			line = strings.TrimSpace(rest)
+		}
+		if len(line) >= 2 && (line[0] == '"' || line[0] == '\'') && line[len(line)-1] == line[0] {
+			line = line[1 : len(line)-1]
+		}
+		specs = append(specs, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	slog.Debug("Steps file loaded", "file", path, "steps", len(specs))
+	return specs, nil
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	chromedphelper "github.com/pesarkhobeee/that-cli-web-toolbox/pkg/chromedp"
+	"github.com/pesarkhobeee/that-cli-web-toolbox/pkg/events"
+)
+
+// summaryAction reports a triage overview of the page for --summary:
+// title, final URL, status, meta description, word count, console errors,
+// requests and load time.
+type summaryAction struct {
+	noopAction
+
+	mu            sync.Mutex
+	consoleErrors int
+	requests      int
+}
+
+func (a *summaryAction) Name() string             { return "summary" }
+func (a *summaryAction) Enabled(cfg *Config) bool { return cfg.Summary }
+func (a *summaryAction) Flags() []string          { return []string{"--summary"} }
+
+func (a *summaryAction) Prepare(ctx context.Context, run *Run) error {
+	// Console errors and requests must be counted from the start of
+	// navigation
+	stream := run.Browser.Events()
+	go func() {
+		for ev := range stream {
+			a.mu.Lock()
+			switch ev := ev.(type) {
+			case events.ConsoleMessage:
+				if ev.Type == "error" || ev.Type == "assert" {
+					a.consoleErrors++
+				}
+			case events.Exception:
+				a.consoleErrors++
+			case events.RequestFinished:
+				a.requests++
+			}
+			a.mu.Unlock()
+		}
+	}()
+	return nil
+}
+
+func (a *summaryAction) Execute(ctx context.Context, run *Run) error {
+	summary, err := run.Browser.Summary(ctx)
+	if err != nil {
+		slog.Error("Failed to summarize page", "error", err)
+		return fmt.Errorf("failed to summarize page: %w", err)
+	}
+	a.mu.Lock()
+	summary.ConsoleErrors = a.consoleErrors
+	summary.Requests = a.requests
+	a.mu.Unlock()
+
+	run.Result.Summary = summary
+	return nil
+}
+
+func (a *summaryAction) Report(ctx context.Context, run *Run) error {
+	// Batch runs list the summary in the batch summary instead
+	if run.Batch || structuredFormat(run.Config.OutputFormat) {
+		return nil
+	}
+	s := run.Result.Summary
+	fmt.Println("Summary:")
+	fmt.Printf("  Title:          %s\n", s.Title)
+	fmt.Printf("  URL:            %s\n", s.URL)
+	fmt.Printf("  Status:         %s\n", formatStatus(s.Status))
+	fmt.Printf("  Description:    %s\n", s.Description)
+	fmt.Printf("  Words:          %d\n", s.WordCount)
+	fmt.Printf("  Console errors: %d\n", s.ConsoleErrors)
+	fmt.Printf("  Requests:       %d\n", s.Requests)
+	fmt.Printf("  Load time:      %s\n", formatLoadTime(s.LoadTimeMS))
+	return nil
+}
+
+// formatSummary renders s on one line for the batch summary.
+func formatSummary(s *chromedphelper.PageSummary) string {
+	return fmt.Sprintf("%s, %q, %d words, %d console errors, %d requests, loaded in %s",
+		formatStatus(s.Status), s.Title, s.WordCount, s.ConsoleErrors, s.Requests, formatLoadTime(s.LoadTimeMS))
+}
+
+// formatStatus renders an HTTP status that is 0 when unknown.
+func formatStatus(status int) string {
+	if status == 0 {
+		return "unknown"
+	}
+	return fmt.Sprint(status)
+}
+
+// formatLoadTime renders a load time in milliseconds that is 0 when the
+// page did not finish loading.
+func formatLoadTime(ms int64) string {
+	if ms == 0 {
+		return "not loaded"
+	}
+	return (time.Duration(ms) * time.Millisecond).String()
+}
@@ -0,0 +1,491 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"os"
+	"path"
+	"regexp"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/spf13/pflag"
+
+	chromedphelper "github.com/pesarkhobeee/that-cli-web-toolbox/pkg/chromedp"
+	"github.com/pesarkhobeee/that-cli-web-toolbox/pkg/chromefortesting"
+	"github.com/pesarkhobeee/that-cli-web-toolbox/pkg/sink"
+)
+
+// supportBundleMaxOutputs bounds the size of the outputs copied into a
+// support bundle; later outputs are only listed.
+const supportBundleMaxOutputs = 64 << 20
+
+// supportBundleChromeTimeout bounds, in seconds, starting Chrome to read
+// its version for the bundle.
+const supportBundleChromeTimeout = 10
+
+// supportEnv are the environment variables recorded in a support bundle:
+// those that change how Chrome starts and pages load. Credentials in
+// proxy URLs are redacted.
+var supportEnv = []string{
+	"HTTP_PROXY", "HTTPS_PROXY", "NO_PROXY", "ALL_PROXY",
+	"http_proxy", "https_proxy", "no_proxy", "all_proxy",
+	"LANG", "LC_ALL", "TZ", "DISPLAY", "WAYLAND_DISPLAY",
+	"XDG_CACHE_HOME", "XDG_RUNTIME_DIR", "NO_COLOR", "CI", "GITHUB_ACTIONS",
+}
+
+// supportBundle collects what a run did for --support-bundle: its
+// debug log, whatever --log-level shows, and its outputs.
+type supportBundle struct {
+	start    time.Time
+	log      syncBuffer
+	outcomes *runOutcomes
+
+	mu      sync.Mutex
+	outputs []bundleOutput
+	size    int
+}
+
+// bundleOutput is an output of the run; Data is nil when it did not fit
+// under supportBundleMaxOutputs.
+type bundleOutput struct {
+	Name        string `json:"name"`
+	Location    string `json:"location"`
+	ContentType string `json:"contentType"`
+	Size        int    `json:"size"`
+	Included    bool   `json:"included"`
+	Data        []byte `json:"-"`
+}
+
+// newSupportBundle starts collecting a run, teeing the default logger
+// into the bundle at debug level.
+func newSupportBundle() *supportBundle {
+	b := &supportBundle{start: time.Now()}
+	debug := slog.NewTextHandler(&b.log, &slog.HandlerOptions{Level: slog.LevelDebug})
+	slog.SetDefault(slog.New(teeHandler{slog.Default().Handler(), debug}))
+	return b
+}
+
+// watch records the outcomes of the run's targets. It does nothing on a
+// nil bundle, as do the other methods.
+func (b *supportBundle) watch(outcomes *runOutcomes) {
+	if b == nil {
+		return
+	}
+	b.outcomes = outcomes
+}
+
+// wrap returns s copying every output written to it into the bundle.
+func (b *supportBundle) wrap(s sink.Sink) sink.Sink {
+	if b == nil {
+		return s
+	}
+	return &bundleSink{Sink: s, bundle: b}
+}
+
+// add records an output of the run, with credentials scrubbed from it.
+func (b *supportBundle) add(name, location, contentType string, data []byte) {
+	data = scrubOutput(name, contentType, data)
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	o := bundleOutput{Name: name, Location: location, ContentType: contentType, Size: len(data)}
+	if b.size+len(data) <= supportBundleMaxOutputs {
+		o.Included = true
+		o.Data = data
+		b.size += len(data)
+	}
+	b.outputs = append(b.outputs, o)
+}
+
+// supportRun is run.json of a support bundle.
+type supportRun struct {
+	Toolbox  manifestToolbox `json:"toolbox"`
+	Command  []string        `json:"command"`
+	Start    time.Time       `json:"start"`
+	Duration string          `json:"duration"`
+	Outcome  string          `json:"outcome"`
+	Error    string          `json:"error,omitempty"`
+	ExitCode int             `json:"exitCode"`
+	Outputs  []bundleOutput  `json:"outputs"`
+}
+
+// supportEnvironment is environment.json of a support bundle.
+type supportEnvironment struct {
+	OS           string                          `json:"os"`
+	CPUs         int                             `json:"cpus"`
+	Container    bool                            `json:"container"`
+	Env          map[string]string               `json:"env"`
+	CacheDir     string                          `json:"cacheDir"`
+	Installed    []chromefortesting.Installation `json:"installedBrowsers"`
+	SystemChrome bool                            `json:"systemChrome"`
+	HeadlessMode string                          `json:"headlessMode,omitempty"`
+	RemotePort   string                          `json:"remoteDebuggingPort,omitempty"`
+	// Chrome is nil when its version could not be read, see ChromeError.
+	Chrome      *chromedphelper.BrowserVersion `json:"chrome,omitempty"`
+	ChromeError string                         `json:"chromeError,omitempty"`
+}
+
+// supportResult is an entry of results.json of a support bundle.
+type supportResult struct {
+	Target   string                 `json:"target"`
+	Error    string                 `json:"error,omitempty"`
+	Duration string                 `json:"duration"`
+	Result   *chromedphelper.Result `json:"result,omitempty"`
+}
+
+// write writes the bundle of a run that ended with err to path. It
+// returns err, or the failure to write the bundle when the run itself
+// succeeded, like --audit-log.
+func (b *supportBundle) write(ctx context.Context, path string, flags *pflag.FlagSet, err error) error {
+	run := supportRun{
+		Toolbox:  manifestToolbox{Version: version, Go: runtime.Version(), Platform: runtime.GOOS + "/" + runtime.GOARCH},
+		Command:  redactArgs(os.Args),
+		Start:    b.start.UTC(),
+		Duration: time.Since(b.start).Round(time.Millisecond).String(),
+		Outcome:  "success",
+	}
+	if err != nil {
+		run.Outcome = "failure"
+		run.Error = err.Error()
+		run.ExitCode = exitCode(err)
+	}
+	env := supportEnvironmentOf(ctx)
+	var results []supportResult
+	if b.outcomes != nil {
+		for _, r := range b.outcomes.all() {
+			res := supportResult{Target: r.Target, Duration: r.Duration.Round(time.Millisecond).String(), Result: r.Result}
+			if r.Err != nil {
+				res.Error = r.Err.Error()
+			}
+			results = append(results, res)
+		}
+	}
+	b.mu.Lock()
+	run.Outputs = append([]bundleOutput(nil), b.outputs...)
+	b.mu.Unlock()
+
+	slog.Debug("Writing support bundle", "file", path, "outputs", len(run.Outputs))
+	if bundleErr := b.writeZip(path, run, effectiveFlags(flags), env, results); bundleErr != nil {
+		slog.Error("Failed to write support bundle", "file", path, "error", bundleErr)
+		if err == nil {
+			return fmt.Errorf("failed to write support bundle to %q: %w", path, bundleErr)
+		}
+		return err
+	}
+	if err != nil {
+		slog.Info("Support bundle written, attach it to the bug report", "file", path)
+	} else {
+		slog.Info("Support bundle written", "file", path)
+	}
+	return err
+}
+
+func (b *supportBundle) writeZip(file string, run supportRun, config map[string]string, env *supportEnvironment, results []supportResult) (err error) {
+	f, err := os.Create(file)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+	}()
+	zw := zip.NewWriter(f)
+	add := func(name string, data []byte) error {
+		w, err := zw.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate, Modified: b.start})
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(data)
+		return err
+	}
+	files := []struct {
+		name string
+		v    any
+	}{{"run.json", run}, {"config.json", config}, {"environment.json", env}, {"results.json", results}}
+	for _, file := range files {
+		data, err := json.MarshalIndent(file.v, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to encode %s: %w", file.name, err)
+		}
+		// The results hold the requests of --emit-curl and failed requests
+		data = scrubJSON(data)
+		if err := add(file.name, data); err != nil {
+			return err
+		}
+	}
+	if err := add("log.txt", b.log.Bytes()); err != nil {
+		return err
+	}
+	for _, o := range run.Outputs {
+		if o.Included {
+			if err := add(bundlePath(o.Name), o.Data); err != nil {
+				return err
+			}
+		}
+	}
+	return zw.Close()
+}
+
+// bundlePath returns where an output is stored in the bundle, under
+// outputs/ whatever its name.
+func bundlePath(name string) string {
+	name = strings.TrimLeft(path.Clean("/"+strings.ReplaceAll(name, `\`, "/")), "/")
+	return "outputs/" + name
+}
+
+// supportEnvironmentOf describes the machine and the Chrome the run used.
+// Chrome is started, or the one of --remote-debugging-port contacted,
+// again to read its version; when that fails the reason is recorded.
+func supportEnvironmentOf(ctx context.Context) *supportEnvironment {
+	env := &supportEnvironment{
+		OS:           runtime.GOOS + "/" + runtime.GOARCH,
+		CPUs:         runtime.NumCPU(),
+		Env:          make(map[string]string),
+		CacheDir:     toolCache().Dir,
+		SystemChrome: cfg.SystemChrome,
+		HeadlessMode: cfg.HeadlessMode,
+		RemotePort:   cfg.RemoteDebuggingPort,
+	}
+	if _, err := os.Stat("/.dockerenv"); err == nil {
+		env.Container = true
+	}
+	for _, name := range supportEnv {
+		if value, ok := os.LookupEnv(name); ok {
+			env.Env[name] = redactURL(value)
+		}
+	}
+	var err error
+	if env.Installed, err = browserStore().List(); err != nil {
+		slog.Debug("Could not list installed browsers", "error", err)
+	}
+	if cfg.NoBrowser {
+		return env
+	}
+	if env.Chrome, err = chromeVersion(ctx, cfg.HeadlessMode, supportBundleChromeTimeout); err != nil {
+		slog.Debug("Could not read Chrome's version for the support bundle", "error", err)
+		env.ChromeError = err.Error()
+	}
+	return env
+}
+
+// effectiveFlags returns every flag of flags with the value the run used,
+// from the command line, --config or the default, secretFlags and the
+// credentials of URLs redacted.
+func effectiveFlags(flags *pflag.FlagSet) map[string]string {
+	values := make(map[string]string)
+	flags.VisitAll(func(f *pflag.Flag) {
+		value := f.Value.String()
+		if secretFlags["--"+f.Name] && value != f.DefValue {
+			value = "REDACTED"
+		}
+		values[f.Name] = redactURL(value)
+	})
+	return values
+}
+
+// redactURL replaces the password of a URL, e.g. of a proxy, in s.
+func redactURL(s string) string {
+	u, err := url.Parse(s)
+	if err != nil || u.User == nil {
+		return s
+	}
+	if _, ok := u.User.Password(); ok {
+		u.User = url.UserPassword(u.User.Username(), "REDACTED")
+	}
+	return u.String()
+}
+
+// credentialHeaders are the headers whose values are scrubbed from the
+// outputs in a support bundle.
+var credentialHeaders = map[string]bool{
+	"authorization":       true,
+	"proxy-authorization": true,
+	"cookie":              true,
+	"set-cookie":          true,
+}
+
+// curlCredential matches a credential header or the body of a command of
+// --emit-curl, quoted by shellQuote.
+var curlCredential = regexp.MustCompile(`(?i)(-H '(?:authorization|proxy-authorization|cookie): |--data-raw ')(?:[^']|'\\'')*'`)
+
+// scrubOutput returns data with the credential headers and request bodies
+// in it redacted: of HAR and other JSON outputs, and of the commands of
+// --emit-curl. Other outputs are returned as they are.
+func scrubOutput(name, contentType string, data []byte) []byte {
+	switch {
+	case strings.HasPrefix(contentType, "application/json") || strings.HasSuffix(name, ".har"):
+		return scrubJSON(data)
+	case strings.HasPrefix(contentType, "text/x-shellscript"):
+		return []byte(scrubCurl(string(data)))
+	}
+	return data
+}
+
+// scrubCurl redacts the credential headers and bodies of curl commands.
+func scrubCurl(s string) string {
+	return curlCredential.ReplaceAllString(s, "${1}REDACTED'")
+}
+
+// scrubJSON redacts credentials in a JSON document: the values of
+// credentialHeaders, as object members or HAR name/value pairs, HAR
+// cookies, request bodies and curl commands. data is returned as it is
+// when it is not JSON.
+func scrubJSON(data []byte) []byte {
+	var v any
+	if err := json.Unmarshal(data, &v); err != nil {
+		return data
+	}
+	scrubbed, err := json.MarshalIndent(scrubValue(v), "", "  ")
+	if err != nil {
+		return data
+	}
+	return scrubbed
+}
+
+func scrubValue(v any) any {
+	switch v := v.(type) {
+	case map[string]any:
+		// A HAR header or cookie is an object with a name and a value
+		if name, ok := v["name"].(string); ok && credentialHeaders[strings.ToLower(name)] {
+			if _, ok := v["value"]; ok {
+				v["value"] = "REDACTED"
+			}
+		}
+		for key, value := range v {
+			switch {
+			case credentialHeaders[strings.ToLower(key)]:
+				v[key] = "REDACTED"
+			case key == "cookies":
+				v[key] = redactCookies(value)
+			case key == "postData":
+				v[key] = redactPostData(value)
+			default:
+				v[key] = scrubValue(value)
+			}
+		}
+	case []any:
+		for i, value := range v {
+			v[i] = scrubValue(value)
+		}
+	case string:
+		return scrubCurl(v)
+	}
+	return v
+}
+
+// redactCookies redacts the values of the cookies of a HAR request or
+// response.
+func redactCookies(v any) any {
+	cookies, ok := v.([]any)
+	if !ok {
+		return scrubValue(v)
+	}
+	for _, c := range cookies {
+		if cookie, ok := c.(map[string]any); ok {
+			if _, ok := cookie["value"]; ok {
+				cookie["value"] = "REDACTED"
+			}
+		}
+	}
+	return cookies
+}
+
+// redactPostData redacts a request body: the string of a finished request
+// or the text and parameters of a HAR request.
+func redactPostData(v any) any {
+	switch v := v.(type) {
+	case string:
+		if v != "" {
+			return "REDACTED"
+		}
+	case map[string]any:
+		if _, ok := v["text"]; ok {
+			v["text"] = "REDACTED"
+		}
+		delete(v, "params")
+	}
+	return v
+}
+
+// bundleSink is a Sink copying the outputs written through it into a
+// support bundle.
+type bundleSink struct {
+	sink.Sink
+	bundle *supportBundle
+}
+
+// Write implements sink.Sink.
+func (s *bundleSink) Write(ctx context.Context, name, contentType string, data []byte) (string, error) {
+	location, err := s.Sink.Write(ctx, name, contentType, data)
+	if err != nil {
+		return location, err
+	}
+	s.bundle.add(name, location, contentType, data)
+	return location, nil
+}
+
+// teeHandler passes log records to each of its handlers that takes them.
+type teeHandler []slog.Handler
+
+func (t teeHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	for _, h := range t {
+		if h.Enabled(ctx, level) {
+			return true
+		}
+	}
+	return false
+}
+
+func (t teeHandler) Handle(ctx context.Context, r slog.Record) error {
+	var err error
+	for _, h := range t {
+		if h.Enabled(ctx, r.Level) {
+			if hErr := h.Handle(ctx, r.Clone()); err == nil {
+				err = hErr
+			}
+		}
+	}
+	return err
+}
+
+func (t teeHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	tee := make(teeHandler, len(t))
+	for i, h := range t {
+		tee[i] = h.WithAttrs(attrs)
+	}
+	return tee
+}
+
+func (t teeHandler) WithGroup(name string) slog.Handler {
+	tee := make(teeHandler, len(t))
+	for i, h := range t {
+		tee[i] = h.WithGroup(name)
+	}
+	return tee
+}
+
+// syncBuffer is a bytes.Buffer safe for concurrent use.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) Bytes() []byte {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]byte(nil), b.buf.Bytes()...)
+}
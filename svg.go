@@ -0,0 +1,56 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	chromedphelper "github.com/pesarkhobeee/that-cli-web-toolbox/pkg/chromedp"
+)
+
+// svgAction exports the first element matching each --svg selector as a
+// standalone SVG document.
+type svgAction struct {
+	noopAction
+	exports []*chromedphelper.SVGExport
+}
+
+func (a *svgAction) Name() string             { return "svg" }
+func (a *svgAction) Enabled(cfg *Config) bool { return len(cfg.SVG) > 0 }
+func (a *svgAction) Flags() []string          { return []string{"--svg"} }
+
+func (a *svgAction) Validate(cfg *Config) error {
+	return validateSelectors("--svg", cfg.SVG)
+}
+
+func (a *svgAction) Execute(ctx context.Context, run *Run) error {
+	a.exports = nil
+	for _, selector := range run.Config.SVG {
+		slog.Info("Exporting element as SVG", "selector", selector)
+		export, err := run.Browser.ElementSVG(ctx, selector)
+		if err != nil {
+			slog.Error("Failed to export element as SVG", "selector", selector, "error", err)
+			return fmt.Errorf("failed to export %q as SVG: %w", selector, err)
+		}
+		switch export.Kind {
+		case chromedphelper.SVGForeignObject:
+			slog.Warn("Element is not an SVG, embedding its HTML, which browsers render but many design tools do not", "selector", selector)
+		case chromedphelper.SVGRaster:
+			slog.Warn("Element is a canvas, embedding it as a PNG image", "selector", selector)
+		}
+		a.exports = append(a.exports, export)
+	}
+	return nil
+}
+
+func (a *svgAction) Report(ctx context.Context, run *Run) error {
+	ts := timestamp()
+	for i, export := range a.exports {
+		selector := run.Config.SVG[i]
+		fileName := fmt.Sprintf("svg-%d_%s.svg", i+1, ts)
+		if err := writeArtifact(ctx, run, "svg", selector, "SVG of "+selector, fileName, "image/svg+xml", []byte(export.SVG)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
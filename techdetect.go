@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+
+	"github.com/pesarkhobeee/that-cli-web-toolbox/pkg/events"
+	"github.com/pesarkhobeee/that-cli-web-toolbox/pkg/techdetect"
+)
+
+// techAction reports the frameworks, CMS, analytics, tag managers and
+// servers --tech-detect finds on the rendered page.
+type techAction struct {
+	noopAction
+
+	mu        sync.Mutex
+	documents []events.RequestFinished
+}
+
+func (a *techAction) Name() string             { return "tech" }
+func (a *techAction) Enabled(cfg *Config) bool { return cfg.TechDetect }
+func (a *techAction) Flags() []string          { return []string{"--tech-detect"} }
+
+func (a *techAction) Prepare(ctx context.Context, run *Run) error {
+	// Server and CDN rules need the document's response headers
+	stream := run.Browser.Events()
+	go func() {
+		for ev := range stream {
+			req, ok := ev.(events.RequestFinished)
+			if !ok || req.ResourceType != "Document" {
+				continue
+			}
+			a.mu.Lock()
+			a.documents = append(a.documents, req)
+			a.mu.Unlock()
+		}
+	}()
+	return nil
+}
+
+func (a *techAction) Execute(ctx context.Context, run *Run) error {
+	signals, err := run.Browser.TechSignals(ctx, techdetect.Globals())
+	if err != nil {
+		return err
+	}
+	meta, err := run.Browser.GetPageMetadata(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get page metadata: %w", err)
+	}
+	a.mu.Lock()
+	for _, doc := range a.documents {
+		if normalizeURL(doc.URL) == normalizeURL(meta.URL) {
+			signals.Headers = doc.ResponseHeaders
+		}
+	}
+	a.mu.Unlock()
+
+	run.Result.Technologies = techdetect.Detect(signals)
+	slog.Debug("Technologies detected", "url", meta.URL, "count", len(run.Result.Technologies))
+	return nil
+}
+
+func (a *techAction) Report(ctx context.Context, run *Run) error {
+	// Batch runs list the technologies in the summary instead
+	if run.Batch || structuredFormat(run.Config.OutputFormat) {
+		return nil
+	}
+	fmt.Println("Technologies:")
+	if len(run.Result.Technologies) == 0 {
+		fmt.Println("  none detected")
+	}
+	for _, t := range run.Result.Technologies {
+		name := t.Name
+		if t.Version != "" {
+			name += " " + t.Version
+		}
+		fmt.Printf("  %s (%s)\n", name, strings.Join(t.Categories, ", "))
+	}
+	return nil
+}
+
+// formatTechnologies renders detected technologies for the batch summary.
+func formatTechnologies(techs []techdetect.Technology) string {
+	if len(techs) == 0 {
+		return "none detected"
+	}
+	names := make([]string, 0, len(techs))
+	for _, t := range techs {
+		names = append(names, t.Name)
+	}
+	return strings.Join(names, ", ")
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+// Sources of the tenant of a request, for --tenant-by.
+const (
+	tenantByKey    = "key"
+	tenantByHeader = "header:"
+)
+
+// tenantName is what a tenant taken from a header may be called.
+var tenantName = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+
+// tenancy tells which tenant a request belongs to. Every tenant's pages
+// open in a profile of its own, so tenants share no cookies, storage or
+// cache, and see only their own jobs.
+type tenancy struct {
+	// header is the header naming the tenant, or empty to use the name of
+	// the request's API key.
+	header string
+}
+
+// parseTenancy parses --tenant-by: "key" or "header:NAME". An empty spec
+// means no tenancy.
+func parseTenancy(spec string, keys bool) (*tenancy, error) {
+	switch {
+	case spec == "":
+		return nil, nil
+	case spec == tenantByKey:
+		if !keys {
+			return nil, fmt.Errorf("--tenant-by key needs --api-keys")
+		}
+		return &tenancy{}, nil
+	case strings.HasPrefix(spec, tenantByHeader) && len(spec) > len(tenantByHeader):
+		return &tenancy{header: http.CanonicalHeaderKey(strings.TrimPrefix(spec, tenantByHeader))}, nil
+	}
+	return nil, fmt.Errorf("invalid --tenant-by %q (expected key or header:NAME)", spec)
+}
+
+// tenant returns the tenant of r, whose API key is key. With API keys, a
+// tenant named by the header is namespaced by the key, as KEY/NAME, so
+// that a key cannot reach the profile of another key's tenant by sending
+// its name.
+func (t *tenancy) tenant(r *http.Request, key *apiKey) (string, error) {
+	if t == nil {
+		return "", nil
+	}
+	if t.header == "" {
+		return key.name(), nil
+	}
+	name := r.Header.Get(t.header)
+	if !tenantName.MatchString(name) {
+		return "", badRequest{fmt.Errorf("header %s must name the tenant in 1 to 64 letters, digits, dots, dashes or underscores", t.header)}
+	}
+	if key != nil {
+		// Names from the header hold no slash, so this cannot collide
+		return key.name() + "/" + name, nil
+	}
+	return name, nil
+}
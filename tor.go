@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+
+	chromedphelper "github.com/pesarkhobeee/that-cli-web-toolbox/pkg/chromedp"
+	"github.com/pesarkhobeee/that-cli-web-toolbox/pkg/tor"
+)
+
+// torPasswordEnv names the environment variable holding the Tor control
+// port password, kept out of flags so it doesn't show up in process lists.
+const torPasswordEnv = "TOR_CONTROL_PASSWORD"
+
+// launchOptions returns how Chrome must be started for the flags in cfg,
+// the loaded --ca-bundle and --allow-hosts.
+func launchOptions(cfg *Config) []chromedphelper.LaunchOption {
+	var opts []chromedphelper.LaunchOption
+	if cfg.Tor {
+		opts = append(opts, chromedphelper.WithProxy("socks5://"+cfg.TorSocks))
+	}
+	if len(caCerts) > 0 {
+		opts = append(opts, chromedphelper.WithCABundle(caCerts))
+	}
+	if allowedHosts != nil {
+		opts = append(opts, chromedphelper.WithAllowedHosts(allowedHosts))
+	}
+	if cfg.Untrusted {
+		opts = append(opts, chromedphelper.WithUntrusted())
+	}
+	if cfg.Headful {
+		opts = append(opts, chromedphelper.WithHeadful())
+	}
+	if cfg.HeadlessMode != "" {
+		opts = append(opts, chromedphelper.WithHeadlessMode(cfg.HeadlessMode))
+	}
+	// Invocations sharing the daemon's Chrome share nothing else
+	if cfg.Via != "" {
+		opts = append(opts, chromedphelper.WithIsolatedSession())
+	} else if cfg.RemoteDebuggingPort == "" {
+		opts = append(opts, managedBrowserOptions(cfg.HeadlessMode)...)
+	}
+	return opts
+}
+
+// circuitRotator renews the Tor circuit every few page loads, so the
+// monitored sites see changing exit addresses.
+type circuitRotator struct {
+	tor   *tor.Controller
+	every int
+
+	mu    sync.Mutex
+	loads int
+}
+
+// next counts a page load about to start and requests a new circuit when
+// the previous one has served its share. A nil rotator does nothing.
+func (r *circuitRotator) next() error {
+	if r == nil {
+		return nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.loads++
+	if r.loads == 1 || (r.loads-1)%r.every != 0 {
+		return nil
+	}
+	slog.Info("Requesting new Tor circuit", "pageLoads", r.loads-1)
+	if err := r.tor.NewCircuit(); err != nil {
+		return fmt.Errorf("failed to renew Tor circuit: %w", err)
+	}
+	return nil
+}
+
+// validateTor checks the flags routing Chrome through Tor.
+func validateTor(cfg *Config) error {
+	if cfg.Tor && cfg.RemoteDebuggingPort != "" {
+		slog.Error("--tor specified with --remote-debugging-port")
+		return fmt.Errorf("--tor cannot be used with --remote-debugging-port; start that Chrome with --proxy-server instead")
+	}
+	if cfg.TorRotate < 0 {
+		slog.Error("Invalid Tor rotation value", "torRotate", cfg.TorRotate)
+		return fmt.Errorf("tor rotation cannot be negative: %d", cfg.TorRotate)
+	}
+	if cfg.TorRotate > 0 && !cfg.Tor {
+		slog.Error("--tor-rotate specified without --tor")
+		return fmt.Errorf("--tor-rotate requires --tor")
+	}
+	if cfg.ProxyPool != "" && cfg.Tor {
+		slog.Error("--proxy-pool specified with --tor")
+		return fmt.Errorf("--proxy-pool and --tor are mutually exclusive, use only one")
+	}
+	return nil
+}
+
+// startCircuitRotation connects to Tor's control port for --tor-rotate,
+// when there are several targets to rotate between, and returns the
+// rotator and a function closing the connection. Without rotation both are
+// nil-safe no-ops.
+func startCircuitRotation(ctx context.Context, cfg *Config, targets int) (*circuitRotator, func(), error) {
+	if cfg.TorRotate <= 0 || targets <= 1 {
+		return nil, func() {}, nil
+	}
+	controller, err := tor.Dial(ctx, cfg.TorControl, os.Getenv(torPasswordEnv))
+	if err != nil {
+		slog.Error("Failed to connect to Tor control port", "address", cfg.TorControl, "error", err)
+		return nil, nil, err
+	}
+	stop := func() {
+		if err := controller.Close(); err != nil {
+			slog.Warn("failed to close Tor control connection", "error", err)
+		}
+	}
+	return &circuitRotator{tor: controller, every: cfg.TorRotate}, stop, nil
+}
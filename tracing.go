@@ -0,0 +1,41 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/pesarkhobeee/that-cli-web-toolbox/pkg/tracing"
+)
+
+// tracingService is the service.name of exported spans.
+const tracingService = "that-cli-web-toolbox"
+
+// startTracing returns ctx carrying a tracer exporting to --otel-endpoint,
+// and a function exporting the remaining spans, to be deferred. Without
+// --otel-endpoint ctx is returned unchanged.
+func startTracing(ctx context.Context) (context.Context, func(), error) {
+	if cfg.OtelEndpoint == "" {
+		return ctx, func() {}, nil
+	}
+	tracer, err := tracing.New(cfg.OtelEndpoint, tracingService, newHTTPClient(10*time.Second))
+	if err != nil {
+		return ctx, nil, err
+	}
+	slog.Debug("Exporting traces", "endpoint", cfg.OtelEndpoint)
+
+	shutdown := func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if err := tracer.Shutdown(ctx); err != nil {
+			slog.Warn("Failed to export remaining spans", "error", err)
+		}
+	}
+	return tracing.WithTracer(ctx, tracer), shutdown, nil
+}
+
+// endSpan records err on span and ends it.
+func endSpan(span *tracing.Span, err error) {
+	span.RecordError(err)
+	span.End()
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"strings"
+)
+
+// untrustedMaxTimeout is the longest --timeout, in seconds, --untrusted
+// allows, so a hostile document cannot hold the browser for long.
+const untrustedMaxTimeout = 30
+
+// untrustedTarget reports whether --untrusted may load target: a local
+// file, read and rendered without file: access, or a URL whose host is in
+// --allow-hosts.
+func untrustedTarget(target string) bool {
+	if strings.HasPrefix(target, "file://") {
+		return true
+	}
+	return allowedHosts != nil && allowedHosts.Allowed(target)
+}
+
+// validateUntrusted checks that --untrusted, which launches a locked-down
+// Chrome of its own, is not combined with another browser or proxy, and
+// that the timeout, including --delay, stays short.
+func validateUntrusted(cfg *Config) error {
+	if cfg.Untrusted && cfg.RemoteDebuggingPort != "" {
+		slog.Error("--untrusted specified with --remote-debugging-port")
+		return fmt.Errorf("--untrusted cannot be used with --remote-debugging-port; it launches a locked-down Chrome of its own")
+	}
+	if cfg.Untrusted && (cfg.Tor || cfg.ProxyPool != "") {
+		slog.Error("--untrusted specified with a proxy", "tor", cfg.Tor, "proxyPool", cfg.ProxyPool)
+		return fmt.Errorf("--untrusted cannot be used with --tor or --proxy-pool; its traffic goes through an allowlist proxy of its own")
+	}
+	if cfg.Untrusted && cfg.Timeout > untrustedMaxTimeout {
+		slog.Error("Timeout too long for --untrusted", "timeout", cfg.Timeout, "max", untrustedMaxTimeout)
+		return fmt.Errorf("--untrusted allows a timeout of at most %ds, got %ds (including --delay)", untrustedMaxTimeout, cfg.Timeout)
+	}
+	return nil
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"html/template"
+	"log/slog"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pesarkhobeee/that-cli-web-toolbox/pkg/sink"
+)
+
+// thumbnailScale is how much the viewport is shrunk for thumbnails in the
+// visual sitemap.
+const thumbnailScale = 0.25
+
+// visualPage is a page shown in the --visual-sitemap report.
+type visualPage struct {
+	URL       string
+	Title     string
+	Thumbnail []byte
+}
+
+// visualSitemapAction captures a thumbnail of the viewport and the page
+// title for the visual sitemap.
+type visualSitemapAction struct{ noopAction }
+
+func (a *visualSitemapAction) Name() string             { return "visual-sitemap" }
+func (a *visualSitemapAction) Enabled(cfg *Config) bool { return cfg.VisualSitemap != "" }
+func (a *visualSitemapAction) Flags() []string          { return []string{"--visual-sitemap"} }
+
+func (a *visualSitemapAction) Execute(ctx context.Context, run *Run) error {
+	slog.Info("Capturing thumbnail for visual sitemap")
+	meta, err := run.Browser.GetPageMetadata(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get page metadata: %w", err)
+	}
+	thumbnail, err := run.Browser.TakeThumbnail(ctx, thumbnailScale)
+	if err != nil {
+		return fmt.Errorf("failed to capture thumbnail: %w", err)
+	}
+	run.Visual = &visualPage{URL: meta.URL, Title: meta.Title, Thumbnail: thumbnail}
+	return nil
+}
+
+// siteNode is one level of the site hierarchy: a site root or a path
+// segment. Page is nil for intermediate paths that were not captured.
+type siteNode struct {
+	Name     string
+	Page     *visualPage
+	Children []*siteNode
+}
+
+// child returns the child named name, adding it if needed.
+func (n *siteNode) child(name string) *siteNode {
+	for _, c := range n.Children {
+		if c.Name == name {
+			return c
+		}
+	}
+	c := &siteNode{Name: name}
+	n.Children = append(n.Children, c)
+	return c
+}
+
+// sort orders the subtree by name.
+func (n *siteNode) sort() {
+	sort.Slice(n.Children, func(i, j int) bool { return n.Children[i].Name < n.Children[j].Name })
+	for _, c := range n.Children {
+		c.sort()
+	}
+}
+
+// buildSiteTree arranges pages by origin and URL path.
+func buildSiteTree(pages []*visualPage) []*siteNode {
+	root := &siteNode{}
+	for _, p := range pages {
+		if p == nil {
+			continue
+		}
+		u, err := url.Parse(p.URL)
+		if err != nil {
+			slog.Warn("Skipping page with invalid URL in visual sitemap", "url", p.URL)
+			continue
+		}
+		node := root.child(u.Scheme + "://" + u.Host)
+		for _, segment := range strings.Split(strings.Trim(u.Path, "/"), "/") {
+			if segment != "" {
+				node = node.child(segment)
+			}
+		}
+		if u.RawQuery != "" {
+			node = node.child("?" + u.RawQuery)
+		}
+		node.Page = p
+	}
+	root.sort()
+	return root.Children
+}
+
+var visualSitemapTemplate = template.Must(template.New("visual-sitemap").Funcs(template.FuncMap{
+	"thumbnail": func(data []byte) template.URL {
+		return template.URL("data:image/jpeg;base64," + base64.StdEncoding.EncodeToString(data))
+	},
+}).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>Visual sitemap</title>
+<style>
+body { font-family: system-ui, sans-serif; margin: 2em; color: #222; }
+ul { list-style: none; padding-left: 1.5em; border-left: 1px solid #ddd; }
+li { margin: 0.75em 0; }
+.page { display: inline-flex; gap: 1em; align-items: flex-start; }
+.page img { width: 240px; border: 1px solid #ccc; box-shadow: 0 1px 3px rgba(0,0,0,.15); }
+.segment { color: #666; }
+.title { font-weight: 600; }
+small { color: #888; }
+</style>
+</head>
+<body>
+<h1>Visual sitemap</h1>
+<p><small>{{.Pages}} pages captured {{.Generated}}</small></p>
+<ul>
+{{range .Roots}}{{template "node" .}}{{end}}
+</ul>
+</body>
+</html>
+{{define "node"}}<li>
+{{if .Page}}<a class="page" href="{{.Page.URL}}"><img src="{{thumbnail .Page.Thumbnail}}" alt=""><span><span class="title">{{if .Page.Title}}{{.Page.Title}}{{else}}{{.Name}}{{end}}</span><br><small>{{.Page.URL}}</small></span></a>
+{{else}}<span class="segment">{{.Name}}/</span>
+{{end}}{{if .Children}}<ul>
+{{range .Children}}{{template "node" .}}{{end}}</ul>
+{{end}}</li>
+{{end}}`))
+
+// writeVisualSitemap writes an HTML page showing the thumbnails of pages
+// arranged by URL hierarchy to the artifact sink under name.
+func writeVisualSitemap(ctx context.Context, artifacts sink.Sink, name string, pages []*visualPage) error {
+	count := 0
+	for _, p := range pages {
+		if p != nil {
+			count++
+		}
+	}
+
+	var buf bytes.Buffer
+	err := visualSitemapTemplate.Execute(&buf, map[string]any{
+		"Roots":     buildSiteTree(pages),
+		"Pages":     count,
+		"Generated": time.Now().Format(time.RFC1123),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to render visual sitemap: %w", err)
+	}
+	return writeReport(ctx, artifacts, "Visual sitemap", name, "text/html; charset=utf-8", buf.Bytes())
+}
@@ -0,0 +1,345 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/exec"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	chromedphelper "github.com/pesarkhobeee/that-cli-web-toolbox/pkg/chromedp"
+)
+
+type initConfig struct {
+	Output string
+	NoTest bool
+	Force  bool
+}
+
+var initCfg initConfig
+
+var initCmd = &cobra.Command{
+	Use:   "init",
+	Short: "Build a capture config by answering a few questions",
+	Long: `Ask what to capture, on which screen, when the page is ready and where
+the outputs go, try the answers on a sample page, and write them to a
+config file to run again with --config, without looking up any flag.
+
+The config file is YAML: the sample page under "targets" and the flags by
+name under "flags". Edit it to add flags the questions don't cover; pages
+and flags given on the command line win over the file's.`,
+	Example: `  # Answer the questions, then run the config on its sample page
+  that-cli-web-toolbox init
+  that-cli-web-toolbox --config capture.yaml
+
+  # Run it on other pages
+  that-cli-web-toolbox --config capture.yaml https://example.com/pricing https://example.com/about
+
+  # Write another config without trying it
+  that-cli-web-toolbox init --output mobile.yaml --no-test`,
+	RunE:          runInit,
+	Args:          cobra.NoArgs,
+	SilenceUsage:  true,
+	SilenceErrors: true,
+}
+
+func init() {
+	initCmd.Flags().StringVarP(&initCfg.Output, "output", "o", "capture.yaml", "Config file to write")
+	initCmd.Flags().BoolVar(&initCfg.NoTest, "no-test", false, "Write the config without trying it on the sample page")
+	initCmd.Flags().BoolVar(&initCfg.Force, "force", false, "Overwrite an existing config file without asking")
+	rootCmd.AddCommand(initCmd)
+}
+
+// wizardAction is an answer to "what to capture" and the flag it sets.
+type wizardAction struct {
+	label string
+	flag  string
+}
+
+var wizardActions = []wizardAction{
+	{"Screenshot", "screenshot"},
+	{"PDF", "printtopdf"},
+	{"Text of the page", "body"},
+	{"HTML of the page", "html"},
+	{"Text of the elements matching a CSS selector", "gettextbycssselector"},
+	{"Console messages of the page", "consolelog"},
+}
+
+// wizard asks the questions of init on in and writes them to out.
+type wizard struct {
+	in  *bufio.Reader
+	out io.Writer
+}
+
+// ask asks question until check accepts the answer, def for an empty one,
+// and returns it. A nil check accepts any answer.
+func (w *wizard) ask(question, def string, check func(string) error) (string, error) {
+	for {
+		if def != "" {
+			fmt.Fprintf(w.out, "%s [%s]: ", question, def)
+		} else {
+			fmt.Fprintf(w.out, "%s: ", question)
+		}
+		answer, err := w.in.ReadString('\n')
+		answer = strings.TrimSpace(answer)
+		if err != nil && (err != io.EOF || answer == "") {
+			if err == io.EOF {
+				fmt.Fprintln(w.out)
+				return "", fmt.Errorf("no answer to %q, init needs a terminal", question)
+			}
+			return "", err
+		}
+		if answer == "" {
+			answer = def
+		}
+		if check == nil {
+			return answer, nil
+		}
+		if err := check(answer); err != nil {
+			fmt.Fprintf(w.out, "  %v\n", err)
+			continue
+		}
+		return answer, nil
+	}
+}
+
+// confirm asks a yes/no question.
+func (w *wizard) confirm(question string, def bool) (bool, error) {
+	hint := "y/N"
+	if def {
+		hint = "Y/n"
+	}
+	answer, err := w.ask(question+" ("+hint+")", "", func(s string) error {
+		switch strings.ToLower(s) {
+		case "", "y", "yes", "n", "no":
+			return nil
+		}
+		return fmt.Errorf("answer y or n")
+	})
+	if err != nil {
+		return false, err
+	}
+	switch strings.ToLower(answer) {
+	case "y", "yes":
+		return true, nil
+	case "n", "no":
+		return false, nil
+	}
+	return def, nil
+}
+
+// questions asks for the sample page and the flags of the capture.
+func (w *wizard) questions() (*captureConfig, error) {
+	c := &captureConfig{Flags: make(map[string]any)}
+
+	target, err := w.ask("Sample page to try the config on (URL or file)", "https://example.com", nil)
+	if err != nil {
+		return nil, err
+	}
+	c.Targets = []string{target}
+
+	fmt.Fprintln(w.out, "\nWhat should be captured?")
+	for i, a := range wizardActions {
+		fmt.Fprintf(w.out, "  %d) %s\n", i+1, a.label)
+	}
+	var picked []int
+	if _, err := w.ask("Numbers, separated by commas", "1", func(s string) error {
+		picked = nil
+		for _, field := range strings.Split(s, ",") {
+			n, err := strconv.Atoi(strings.TrimSpace(field))
+			if err != nil || n < 1 || n > len(wizardActions) {
+				return fmt.Errorf("expected numbers from 1 to %d, e.g. 1,3", len(wizardActions))
+			}
+			if !slices.Contains(picked, n-1) {
+				picked = append(picked, n-1)
+			}
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	for _, i := range picked {
+		switch flag := wizardActions[i].flag; flag {
+		case "screenshot":
+			c.Flags[flag] = true
+			full, err := w.confirm("Screenshot the whole page, not only the first screen?", true)
+			if err != nil {
+				return nil, err
+			}
+			if !full {
+				c.Flags["full-page"] = false
+			}
+		case "gettextbycssselector":
+			selector, err := w.ask("CSS selector of the elements, e.g. h1 or .price", "", func(s string) error {
+				if s == "" {
+					return fmt.Errorf("a selector is required")
+				}
+				return nil
+			})
+			if err != nil {
+				return nil, err
+			}
+			c.Flags[flag] = []string{selector}
+		default:
+			c.Flags[flag] = true
+		}
+	}
+
+	fmt.Fprintf(w.out, "\nScreen to render on: desktop, a device (%s) or WIDTHxHEIGHT\n", strings.Join(chromedphelper.Devices(), ", "))
+	screen, err := w.ask("Screen", "desktop", func(s string) error {
+		if s == "desktop" || slices.Contains(chromedphelper.Devices(), s) {
+			return nil
+		}
+		if _, err := parseEmulation(&Config{Viewport: s}); err != nil {
+			return fmt.Errorf("expected desktop, a device name or a size such as 1280x800")
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case screen == "desktop":
+		c.Flags["viewport"] = "1280x800"
+	case slices.Contains(chromedphelper.Devices(), screen):
+		c.Flags["device"] = screen
+	default:
+		c.Flags["viewport"] = screen
+	}
+
+	fmt.Fprintln(w.out, "\nWhen is the page ready to capture?")
+	fmt.Fprintln(w.out, "  load           when it has loaded")
+	fmt.Fprintln(w.out, "  network-idle   when it stopped loading data, for pages that load content later")
+	fmt.Fprintln(w.out, "  selector:SEL   when an element matching the CSS selector SEL shows")
+	fmt.Fprintln(w.out, "  react, nextjs, vue, angular   when the app of that framework has started")
+	ready, err := w.ask("Ready", "load", func(s string) error {
+		if s == "load" {
+			return nil
+		}
+		_, err := chromedphelper.ParseReadiness(s)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if ready != "load" {
+		c.Flags["ready-strategy"] = ready
+	}
+	delay, err := w.ask("Seconds to wait after that, for animations", "2", func(s string) error {
+		if n, err := strconv.Atoi(s); err != nil || n < 0 {
+			return fmt.Errorf("expected a number of seconds, e.g. 2")
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if delay != "2" {
+		n, _ := strconv.Atoi(delay)
+		c.Flags["delay"] = n
+	}
+
+	fmt.Fprintln(w.out)
+	dir, err := w.ask("Directory to write the outputs to", ".", nil)
+	if err != nil {
+		return nil, err
+	}
+	if dir != "." {
+		c.Flags["sink"] = "file:" + dir
+	}
+	format, err := w.ask("Report: text, or json for other programs", formatText, func(s string) error {
+		if s != formatText && s != formatJSON {
+			return fmt.Errorf("expected text or json")
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if format != formatText {
+		c.Flags["output-format"] = format
+	}
+	return c, nil
+}
+
+func runInit(cmd *cobra.Command, args []string) error {
+	setupLogging(cfg.LogLevel)
+	w := &wizard{in: bufio.NewReader(cmd.InOrStdin()), out: cmd.OutOrStdout()}
+
+	if _, err := os.Stat(initCfg.Output); err == nil && !initCfg.Force {
+		overwrite, err := w.confirm(fmt.Sprintf("%s exists, overwrite it?", initCfg.Output), false)
+		if err != nil {
+			return err
+		}
+		if !overwrite {
+			return fmt.Errorf("%s exists, choose another file with --output", initCfg.Output)
+		}
+	}
+
+	c, err := w.questions()
+	if err != nil {
+		return err
+	}
+	header := fmt.Sprintf("Written by \"that-cli-web-toolbox init\". Run it with:\n  that-cli-web-toolbox --config %s [URL...]\nPages given on the command line replace the targets.", initCfg.Output)
+	data := c.encode(header)
+
+	if !initCfg.NoTest {
+		fmt.Fprintf(w.out, "\nTrying the config on %s...\n\n", c.Targets[0])
+		if err := tryCaptureConfig(cmd, data); err != nil {
+			slog.Warn("Test run failed", "error", err)
+			fmt.Fprintln(w.out)
+			keep, err := w.confirm("The test run failed, see above. Write the config anyway?", false)
+			if err != nil {
+				return err
+			}
+			if !keep {
+				return fmt.Errorf("test run failed, config not written")
+			}
+		}
+	}
+
+	if err := os.WriteFile(initCfg.Output, data, 0o644); err != nil {
+		return fmt.Errorf("failed to write config file: %w", err)
+	}
+	fmt.Fprintf(w.out, "\nWrote %s. Run it with:\n  that-cli-web-toolbox --config %s\n", initCfg.Output, initCfg.Output)
+	return nil
+}
+
+// tryCaptureConfig runs the tool with the config file data, on its targets.
+func tryCaptureConfig(cmd *cobra.Command, data []byte) error {
+	f, err := os.CreateTemp("", "that-cli-web-toolbox-init-*.yaml")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	exe, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	run := exec.CommandContext(cmd.Context(), exe, "--config", f.Name(), "--loglevel", cfg.LogLevel)
+	run.Stdout = cmd.OutOrStdout()
+	run.Stderr = cmd.ErrOrStderr()
+	if err := run.Run(); err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			return fmt.Errorf("exit code %d", exitErr.ExitCode())
+		}
+		return err
+	}
+	return nil
+}